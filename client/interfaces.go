@@ -442,6 +442,9 @@ type ContainerFileArgs struct {
 
 	// File write mode (overwrite or append)
 	WriteMode string
+
+	// POSIX ACL in the getfacl text format (requires the file_acl API extension)
+	ACL string
 }
 
 // The ContainerFileResponse struct is used as part of the response for a container file download
@@ -458,6 +461,9 @@ type ContainerFileResponse struct {
 	// File type (file or directory)
 	Type string
 
+	// POSIX ACL in the getfacl text format, if any
+	ACL string
+
 	// If a directory, the list of files inside it
 	Entries []string
 }
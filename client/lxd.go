@@ -146,7 +146,11 @@ func lxdParseResponse(resp *http.Response) (*api.Response, string, error) {
 
 	// Handle errors
 	if response.Type == api.ErrorResponse {
-		return nil, "", fmt.Errorf(response.Error)
+		return nil, "", api.ResponseError{
+			StatusCode: response.Code,
+			Code:       response.ErrorID,
+			Message:    response.Error,
+		}
 	}
 
 	return &response, etag, nil
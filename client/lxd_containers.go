@@ -876,6 +876,7 @@ func (r *ProtocolLXD) GetContainerFile(containerName string, path string) (io.Re
 		GID:  gid,
 		Mode: mode,
 		Type: fileType,
+		ACL:  resp.Header.Get("X-LXD-ACL"),
 	}
 
 	if fileResp.Type == "directory" {
@@ -962,6 +963,14 @@ func (r *ProtocolLXD) CreateContainerFile(containerName string, path string, arg
 		req.Header.Set("X-LXD-write", args.WriteMode)
 	}
 
+	if args.ACL != "" {
+		if !r.HasExtension("file_acl") {
+			return fmt.Errorf("The server is missing the required \"file_acl\" API extension")
+		}
+
+		req.Header.Set("X-LXD-ACL", args.ACL)
+	}
+
 	// Send the request
 	resp, err := r.do(req)
 	if err != nil {
@@ -1778,6 +1787,10 @@ func (r *ProtocolLXD) CreateContainerBackup(containerName string, backup api.Con
 		return nil, fmt.Errorf("The server is missing the required \"container_backup\" API extension")
 	}
 
+	if backup.Xattrs && !r.HasExtension("backup_xattrs") {
+		return nil, fmt.Errorf("The server is missing the required \"backup_xattrs\" API extension")
+	}
+
 	// Send the request
 	op, _, err := r.queryOperation("POST", fmt.Sprintf("/containers/%s/backups",
 		url.QueryEscape(containerName)), backup, "")
@@ -0,0 +1,108 @@
+# Starting point policy for LXD API authorization.
+#
+# Point LXD at the decision with:
+#   lxc config set auth.opa_endpoint http://127.0.0.1:8181/v1/data/lxd/authz
+#
+# LXD sends the following input for every request from a trusted client
+# (the local unix socket and cluster members bypass the policy):
+#   user:         certificate fingerprint or Candid identity
+#   protocol:     "tls" or "candid"
+#   method:       HTTP method
+#   path:         request path (e.g. /1.0/containers/c1)
+#   query:        query string values
+#   project:      project the request applies to
+#   content_type: Content-Type header of the request
+#   body:         decoded JSON request body, if any (null for file uploads)
+#
+# Users are mapped to roles through data.lxd.users, for example:
+#   {"lxd": {"users": {"alice": {"role": "admin"},
+#                      "bob": {"role": "operator", "projects": ["dev"]},
+#                      "carol": {"role": "viewer"}}}}
+# Users not listed keep full access, like plain LXD certificate trust.
+package lxd.authz
+
+default allow = false
+
+read_methods = {"GET", "HEAD"}
+
+# Endpoints which give root-equivalent access to the host
+privileged_paths = [
+	"/1.0/certificates",
+	"/1.0/cluster",
+	"/1.0/networks",
+	"/1.0/storage-pools",
+	"/1.0/webhooks",
+]
+
+user = data.lxd.users[input.user]
+
+allow {
+	not data.lxd.users[input.user]
+}
+
+allow {
+	user.role == "admin"
+}
+
+# Viewers can read everything but the server wide settings.
+allow {
+	user.role == "viewer"
+	read_methods[input.method]
+	not privileged
+}
+
+# Operators manage containers, images and profiles in their projects but
+# can't change the server or use privileged container settings.
+allow {
+	user.role == "operator"
+	project_allowed
+	not privileged
+	not input.path == "/1.0"
+	not privileged_config
+}
+
+allow {
+	user.role == "operator"
+	project_allowed
+	input.path == "/1.0"
+	read_methods[input.method]
+}
+
+project_allowed {
+	not user.projects
+}
+
+project_allowed {
+	user.projects[_] == input.project
+}
+
+privileged {
+	prefix := privileged_paths[_]
+	startswith(input.path, prefix)
+}
+
+privileged_config {
+	input.body.config[key]
+	privileged_keys[key]
+}
+
+privileged_config {
+	device := input.body.devices[_]
+	device.type == "disk"
+	device.source
+}
+
+privileged_keys = {
+	"raw.apparmor",
+	"raw.idmap",
+	"raw.lxc",
+	"raw.seccomp",
+	"security.privileged",
+	"security.nesting",
+}
+
+message = msg {
+	not allow
+	user.role
+	msg := sprintf("User %q with role %q isn't allowed to %s %s", [input.user, user.role, input.method, input.path])
+}
@@ -22,11 +22,12 @@ type cmdExport struct {
 
 	flagContainerOnly    bool
 	flagOptimizedStorage bool
+	flagXattrs           bool
 }
 
 func (c *cmdExport) Command() *cobra.Command {
 	cmd := &cobra.Command{}
-	cmd.Use = i18n.G("export [<remote>:]<container> [target] [--container-only] [--optimized-storage]")
+	cmd.Use = i18n.G("export [<remote>:]<container> [target] [--container-only] [--optimized-storage] [--xattrs]")
 	cmd.Short = i18n.G("Export container backups")
 	cmd.Long = cli.FormatSection(i18n.G("Description"), i18n.G(
 		`Export containers as backup tarballs.`))
@@ -39,6 +40,8 @@ func (c *cmdExport) Command() *cobra.Command {
 		i18n.G("Whether or not to only backup the container (without snapshots)"))
 	cmd.Flags().BoolVar(&c.flagOptimizedStorage, "optimized-storage", false,
 		i18n.G("Use storage driver optimized format (can only be restored on a similar pool)"))
+	cmd.Flags().BoolVar(&c.flagXattrs, "xattrs", false,
+		i18n.G("Preserve the extended attributes of the files"))
 
 	return cmd
 }
@@ -68,6 +71,7 @@ func (c *cmdExport) Run(cmd *cobra.Command, args []string) error {
 		ExpiryDate:       time.Now().Add(30 * time.Minute),
 		ContainerOnly:    c.flagContainerOnly,
 		OptimizedStorage: c.flagOptimizedStorage,
+		Xattrs:           c.flagXattrs,
 	}
 
 	op, err := d.CreateContainerBackup(name, req)
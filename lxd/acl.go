@@ -0,0 +1,279 @@
+package main
+
+import (
+	"encoding/binary"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Extended attributes holding the POSIX ACLs of a path
+const (
+	aclXattrAccess  = "system.posix_acl_access"
+	aclXattrDefault = "system.posix_acl_default"
+)
+
+// POSIX ACL entry tags, as found in <linux/posix_acl.h>
+const (
+	aclUserObj  = 0x01
+	aclUser     = 0x02
+	aclGroupObj = 0x04
+	aclGroup    = 0x08
+	aclMask     = 0x10
+	aclOther    = 0x20
+)
+
+const aclXattrVersion = 2
+const aclUndefinedID = 0xffffffff
+
+var errACLNotSupported = fmt.Errorf("POSIX ACLs aren't supported by the filesystem")
+
+// aclEntry is an entry of a POSIX ACL.
+type aclEntry struct {
+	tag  uint16
+	perm uint16
+	id   uint32
+}
+
+var aclTagNames = map[uint16]string{
+	aclUserObj:  "user",
+	aclUser:     "user",
+	aclGroupObj: "group",
+	aclGroup:    "group",
+	aclMask:     "mask",
+	aclOther:    "other",
+}
+
+// aclParse parses an ACL in the getfacl text format, with entries separated
+// by newlines or commas, and returns its access and default entries. User and
+// group qualifiers must be numeric ids since names can't be resolved against
+// the container.
+func aclParse(text string) ([]aclEntry, []aclEntry, error) {
+	access := []aclEntry{}
+	def := []aclEntry{}
+
+	text = strings.Replace(text, "\n", ",", -1)
+	for _, entry := range strings.Split(text, ",") {
+		// Strip comments, including the "#effective:" ones of getfacl
+		idx := strings.Index(entry, "#")
+		if idx >= 0 {
+			entry = entry[:idx]
+		}
+
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+
+		fields := strings.Split(entry, ":")
+		isDefault := false
+		if fields[0] == "default" || fields[0] == "d" {
+			isDefault = true
+			fields = fields[1:]
+		}
+
+		if len(fields) != 3 {
+			return nil, nil, fmt.Errorf("Invalid ACL entry '%s'", entry)
+		}
+
+		e := aclEntry{id: aclUndefinedID}
+		switch fields[0] {
+		case "user", "u":
+			e.tag = aclUserObj
+			if fields[1] != "" {
+				e.tag = aclUser
+			}
+		case "group", "g":
+			e.tag = aclGroupObj
+			if fields[1] != "" {
+				e.tag = aclGroup
+			}
+		case "mask", "m":
+			e.tag = aclMask
+		case "other", "o":
+			e.tag = aclOther
+		default:
+			return nil, nil, fmt.Errorf("Invalid ACL entry type '%s'", fields[0])
+		}
+
+		if e.tag == aclUser || e.tag == aclGroup {
+			id, err := strconv.ParseUint(fields[1], 10, 32)
+			if err != nil || id == aclUndefinedID {
+				return nil, nil, fmt.Errorf("Invalid ACL qualifier '%s', only numeric ids are supported", fields[1])
+			}
+
+			e.id = uint32(id)
+		} else if fields[1] != "" {
+			return nil, nil, fmt.Errorf("Invalid ACL entry '%s'", entry)
+		}
+
+		for _, c := range fields[2] {
+			switch c {
+			case 'r':
+				e.perm |= 4
+			case 'w':
+				e.perm |= 2
+			case 'x':
+				e.perm |= 1
+			case '-':
+			default:
+				return nil, nil, fmt.Errorf("Invalid ACL permissions '%s'", fields[2])
+			}
+		}
+
+		if isDefault {
+			def = append(def, e)
+		} else {
+			access = append(access, e)
+		}
+	}
+
+	return aclComplete(access), aclComplete(def), nil
+}
+
+// aclComplete sorts the entries the way the kernel expects them and, like
+// setfacl, adds the mask entry needed by ACLs with named users or groups.
+func aclComplete(entries []aclEntry) []aclEntry {
+	sort.SliceStable(entries, func(i, j int) bool {
+		if entries[i].tag != entries[j].tag {
+			return entries[i].tag < entries[j].tag
+		}
+
+		return entries[i].id < entries[j].id
+	})
+
+	hasMask := false
+	needsMask := false
+	mask := aclEntry{tag: aclMask, id: aclUndefinedID}
+	for _, e := range entries {
+		switch e.tag {
+		case aclMask:
+			hasMask = true
+		case aclUser, aclGroup:
+			needsMask = true
+			mask.perm |= e.perm
+		case aclGroupObj:
+			mask.perm |= e.perm
+		}
+	}
+
+	if !needsMask || hasMask {
+		return entries
+	}
+
+	// The mask goes right before the other entry
+	i := 0
+	for i < len(entries) && entries[i].tag < aclMask {
+		i++
+	}
+
+	entries = append(entries, aclEntry{})
+	copy(entries[i+1:], entries[i:])
+	entries[i] = mask
+
+	return entries
+}
+
+// aclEncode returns the value of the extended attribute holding the entries.
+func aclEncode(entries []aclEntry) []byte {
+	buf := make([]byte, 4+8*len(entries))
+	binary.LittleEndian.PutUint32(buf, aclXattrVersion)
+	for i, e := range entries {
+		binary.LittleEndian.PutUint16(buf[4+8*i:], e.tag)
+		binary.LittleEndian.PutUint16(buf[6+8*i:], e.perm)
+		binary.LittleEndian.PutUint32(buf[8+8*i:], e.id)
+	}
+
+	return buf
+}
+
+// aclDecode returns the entries held by the value of an ACL extended
+// attribute.
+func aclDecode(buf []byte) ([]aclEntry, error) {
+	if len(buf) < 4 || (len(buf)-4)%8 != 0 {
+		return nil, fmt.Errorf("Invalid ACL of %d bytes", len(buf))
+	}
+
+	version := binary.LittleEndian.Uint32(buf)
+	if version != aclXattrVersion {
+		return nil, fmt.Errorf("Unsupported ACL version %d", version)
+	}
+
+	entries := []aclEntry{}
+	for i := 4; i < len(buf); i += 8 {
+		e := aclEntry{
+			tag:  binary.LittleEndian.Uint16(buf[i:]),
+			perm: binary.LittleEndian.Uint16(buf[i+2:]),
+			id:   binary.LittleEndian.Uint32(buf[i+4:]),
+		}
+
+		_, ok := aclTagNames[e.tag]
+		if !ok {
+			return nil, fmt.Errorf("Invalid ACL entry type %d", e.tag)
+		}
+
+		entries = append(entries, e)
+	}
+
+	return entries, nil
+}
+
+// aclFormat returns the access and default entries in the getfacl text
+// format, using commas as separators so that it fits in a HTTP header.
+func aclFormat(access []aclEntry, def []aclEntry) string {
+	lines := []string{}
+
+	format := func(prefix string, entries []aclEntry) {
+		for _, e := range entries {
+			qualifier := ""
+			if e.tag == aclUser || e.tag == aclGroup {
+				qualifier = fmt.Sprintf("%d", e.id)
+			}
+
+			perm := []byte("---")
+			if e.perm&4 != 0 {
+				perm[0] = 'r'
+			}
+
+			if e.perm&2 != 0 {
+				perm[1] = 'w'
+			}
+
+			if e.perm&1 != 0 {
+				perm[2] = 'x'
+			}
+
+			lines = append(lines, fmt.Sprintf("%s%s:%s:%s", prefix, aclTagNames[e.tag], qualifier, perm))
+		}
+	}
+
+	format("", access)
+	format("default:", def)
+
+	return strings.Join(lines, ",")
+}
+
+// aclShift maps the named user and group entries using the given function,
+// as done with the file owner when the container isn't running.
+func aclShift(entries []aclEntry, shift func(uid int64, gid int64) (int64, int64)) error {
+	for i, e := range entries {
+		var id int64
+		switch e.tag {
+		case aclUser:
+			id, _ = shift(int64(e.id), -1)
+		case aclGroup:
+			_, id = shift(-1, int64(e.id))
+		default:
+			continue
+		}
+
+		if id < 0 {
+			return fmt.Errorf("Id %d of ACL entry isn't mapped", e.id)
+		}
+
+		entries[i].id = uint32(id)
+	}
+
+	return nil
+}
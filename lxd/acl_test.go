@@ -0,0 +1,61 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestACLParse(t *testing.T) {
+	access, def, err := aclParse(`# file: srv
+# owner: root
+user::rwx
+user:1000:r-x
+group::r--
+group:100:rw-	#effective:r--
+other::---
+default:user::rwx
+default:group::r-x
+default:other::r--`)
+	require.NoError(t, err)
+
+	assert.Equal(t, []aclEntry{
+		{aclUserObj, 7, aclUndefinedID},
+		{aclUser, 5, 1000},
+		{aclGroupObj, 4, aclUndefinedID},
+		{aclGroup, 6, 100},
+		{aclMask, 7, aclUndefinedID},
+		{aclOther, 0, aclUndefinedID},
+	}, access)
+
+	assert.Equal(t, []aclEntry{
+		{aclUserObj, 7, aclUndefinedID},
+		{aclGroupObj, 5, aclUndefinedID},
+		{aclOther, 4, aclUndefinedID},
+	}, def)
+}
+
+func TestACLParseInvalid(t *testing.T) {
+	for _, text := range []string{"user:root:rwx", "user::rwz", "mask:1:rwx", "foo::rwx", "user:rwx"} {
+		_, _, err := aclParse(text)
+		assert.Error(t, err, text)
+	}
+}
+
+func TestACLEncodeDecode(t *testing.T) {
+	access, def, err := aclParse("u::rw-,g:42:r--,g::r--,o::r--,m::r--,d:u::rwx")
+	require.NoError(t, err)
+
+	buf := aclEncode(access)
+	assert.Len(t, buf, 4+8*5)
+
+	decoded, err := aclDecode(buf)
+	require.NoError(t, err)
+	assert.Equal(t, access, decoded)
+
+	assert.Equal(t, "user::rw-,group::r--,group:42:r--,mask::r--,other::r--,default:user::rwx", aclFormat(decoded, def))
+
+	_, err = aclDecode(buf[:7])
+	assert.Error(t, err)
+}
@@ -11,6 +11,7 @@ import (
 	"github.com/lxc/lxd/lxd/cluster"
 	"github.com/lxc/lxd/lxd/config"
 	"github.com/lxc/lxd/lxd/db"
+	"github.com/lxc/lxd/lxd/keystore"
 	"github.com/lxc/lxd/lxd/node"
 	"github.com/lxc/lxd/lxd/util"
 	"github.com/lxc/lxd/shared"
@@ -33,11 +34,13 @@ var api10 = []Command{
 	aliasCmd,
 	aliasesCmd,
 	api10Cmd,
+	certificatesReloadCmd,
 	certificateFingerprintCmd,
 	certificatesCmd,
 	clusterCmd,
 	clusterNodeCmd,
 	clusterNodesCmd,
+	configSchemaCmd,
 	containerBackupCmd,
 	containerBackupExportCmd,
 	containerBackupsCmd,
@@ -45,6 +48,7 @@ var api10 = []Command{
 	containerConsoleCmd,
 	containerExecCmd,
 	containerFileCmd,
+	containerFileSearchCmd,
 	containerLogCmd,
 	containerLogsCmd,
 	containerMetadataCmd,
@@ -53,13 +57,47 @@ var api10 = []Command{
 	containerSnapshotCmd,
 	containerSnapshotsCmd,
 	containerStateCmd,
+	containerEnergyCmd,
+	containerPerfPageFaultsCmd,
+	containerMountsCmd,
+	containerBandwidthCmd,
+	containerDriftCmd,
+	containerCgroupTraceCmd,
+	containerRootfsSyncCmd,
+	containerPidMapCmd,
+	containerPidMapEntryCmd,
+	containerEbpfCmd,
+	containerEbpfProgramCmd,
+	containerCoredumpsCmd,
+	containerCoredumpCmd,
+	containerCronJobsCmd,
+	containerCronJobCmd,
+	containerCronJobRunsCmd,
+	containerNetworkRoutesCmd,
+	containerNetworkRouteCmd,
+	containerNetworkARPCmd,
+	containerNetworkARPEntryCmd,
+	containerCapabilitiesCmd,
+	containerSeccompProfileCmd,
+	containerEncryptionKeyCmd,
+	debugPprofCmd,
 	eventsCmd,
+	eventsSSECmd,
+	imagesBuildCmd,
+	imagesGCCmd,
+	imagesImportVagrantCmd,
 	imageCmd,
 	imageExportCmd,
 	imageRefreshCmd,
+	imageReplicasCmd,
 	imagesCmd,
 	imageSecretCmd,
+	imageSignatureCmd,
 	networkCmd,
+	networkForwardsCmd,
+	networkForwardCmd,
+	networkLoadBalancersCmd,
+	networkLoadBalancerCmd,
 	networkLeasesCmd,
 	networksCmd,
 	networkStateCmd,
@@ -73,16 +111,28 @@ var api10 = []Command{
 	projectsCmd,
 	serverResourceCmd,
 	serverResourceCmd,
+	infinibandResourcesCmd,
+	pciResourcesCmd,
 	storagePoolCmd,
 	storagePoolResourcesCmd,
+	storagePoolOvercommitCmd,
+	storagePoolCompressCmd,
+	storagePoolDedupScrubCmd,
 	storagePoolsCmd,
 	storagePoolVolumesCmd,
 	storagePoolVolumesTypeCmd,
+	storagePoolVolumeTypeResizeCmd,
 	storagePoolVolumeTypeContainerCmd,
 	storagePoolVolumeTypeCustomCmd,
 	storagePoolVolumeTypeImageCmd,
 	storagePoolVolumeSnapshotsTypeCmd,
 	storagePoolVolumeSnapshotTypeCmd,
+	webhookCmd,
+	webhooksCmd,
+	webhookStatusCmd,
+	terraformStateCmd,
+	buildCacheStatsCmd,
+	healthCmd,
 }
 
 func api10Get(d *Daemon, r *http.Request) Response {
@@ -206,6 +256,8 @@ func api10Get(d *Daemon, r *http.Request) Response {
 		"uevent_injection":   fmt.Sprintf("%v", d.os.UeventInjection),
 		"unpriv_fscaps":      fmt.Sprintf("%v", d.os.VFS3Fscaps),
 		"shiftfs":            fmt.Sprintf("%v", d.os.Shiftfs),
+		"time_namespace":     fmt.Sprintf("%v", d.os.TimeNamespace),
+		"seccomp_listener":   fmt.Sprintf("%v", d.os.SeccompListener),
 	}
 
 	drivers := readStoragePoolDriversCache()
@@ -324,6 +376,7 @@ func doApi10Update(d *Daemon, req api.ServerPut, patch bool) Response {
 
 	nodeChanged := map[string]string{}
 	var newNodeConfig *node.Config
+	var newCert *shared.CertInfo
 	err := d.db.Transaction(func(tx *db.NodeTx) error {
 		var err error
 		newNodeConfig, err = node.ConfigLoad(tx)
@@ -345,7 +398,25 @@ func doApi10Update(d *Daemon, req api.ServerPut, patch bool) Response {
 		} else {
 			nodeChanged, err = newNodeConfig.Replace(nodeValues)
 		}
-		return err
+		if err != nil {
+			return err
+		}
+
+		// Load the server certificate from the new key store or files
+		// before the change is committed, the daemon wouldn't start
+		// otherwise
+		_, storeChanged := nodeChanged["core.key_store"]
+		_, uriChanged := nodeChanged["core.key_store_uri"]
+		_, certChanged := nodeChanged["core.https_cert"]
+		_, keyChanged := nodeChanged["core.https_key"]
+		if storeChanged || uriChanged || certChanged || keyChanged {
+			newCert, err = serverCertLoad(d.os.VarDir, newNodeConfig)
+			if err != nil {
+				return err
+			}
+		}
+
+		return nil
 	})
 	if err != nil {
 		switch err.(type) {
@@ -356,6 +427,18 @@ func doApi10Update(d *Daemon, req api.ServerPut, patch bool) Response {
 		}
 	}
 
+	// Switch to the new certificate, new connections use it right away
+	if newCert != nil {
+		serverCertUpdate(d, newCert)
+
+		// Only now can the private key leave the disk for good
+		store, _ := newNodeConfig.KeyStore()
+		err = keystore.RemoveKey(d.os.VarDir, store)
+		if err != nil {
+			return SmartError(err)
+		}
+	}
+
 	// Then deal with cluster wide configuration
 	var clusterChanged map[string]string
 	var newClusterConfig *cluster.Config
@@ -415,6 +498,7 @@ func doApi10Update(d *Daemon, req api.ServerPut, patch bool) Response {
 func doApi10UpdateTriggers(d *Daemon, nodeChanged, clusterChanged map[string]string, nodeConfig *node.Config, clusterConfig *cluster.Config) error {
 	maasChanged := false
 	candidChanged := false
+	ldapChanged := false
 
 	for key := range clusterChanged {
 		switch key {
@@ -444,6 +528,45 @@ func doApi10UpdateTriggers(d *Daemon, nodeChanged, clusterChanged map[string]str
 			if !d.os.MockMode {
 				d.taskPruneImages.Reset()
 			}
+		case "images.gc_interval":
+			if !d.os.MockMode {
+				d.taskImagesGC.Reset()
+			}
+		case "containers.default_oom_score_adj":
+			if !d.os.MockMode {
+				go containerOOMScoreAdjDefaultUpdate(d, clusterConfig.ContainersDefaultOOMScoreAdj())
+			}
+		case "snapshots.retention_interval":
+			if !d.os.MockMode {
+				d.taskPruneSnapshots.Reset()
+			}
+		case "containers.drift_check_interval":
+			if !d.os.MockMode {
+				d.taskContainerDrift.Reset()
+			}
+		case "core.events_buffer":
+			eventsSetHistorySize(int(clusterConfig.EventsBuffer()))
+		case "core.debug_pprof":
+			debugPprofSetRates(clusterConfig.DebugPprof())
+		case "core.coredump_capture":
+			err := coredumpSetCapture(d.os.ExecPath, clusterConfig.CoredumpCapture())
+			if err != nil {
+				return err
+			}
+		case "auth.ldap.base_dn":
+			fallthrough
+		case "auth.ldap.bind_dn":
+			fallthrough
+		case "auth.ldap.bind_password":
+			fallthrough
+		case "auth.ldap.group_filter":
+			fallthrough
+		case "auth.ldap.uri":
+			fallthrough
+		case "auth.ldap.user_filter":
+			ldapChanged = true
+		case "auth.opa_endpoint":
+			d.opaEndpointSet(clusterConfig.OPAEndpoint())
 		}
 	}
 
@@ -490,6 +613,10 @@ func doApi10UpdateTriggers(d *Daemon, nodeChanged, clusterChanged map[string]str
 		}
 	}
 
+	if ldapChanged {
+		d.setupLDAPAuthentication(clusterConfig)
+	}
+
 	if candidChanged {
 		endpoint := clusterConfig.CandidEndpoint()
 		endpointKey := clusterConfig.CandidEndpointKey()
@@ -16,6 +16,7 @@ import (
 	lxd "github.com/lxc/lxd/client"
 	"github.com/lxc/lxd/lxd/cluster"
 	"github.com/lxc/lxd/lxd/db"
+	"github.com/lxc/lxd/lxd/keystore"
 	"github.com/lxc/lxd/lxd/node"
 	"github.com/lxc/lxd/lxd/util"
 	"github.com/lxc/lxd/shared"
@@ -182,6 +183,27 @@ func clusterPut(d *Daemon, r *http.Request) Response {
 		return clusterPutDisable(d)
 	}
 
+	// Cluster members share their private key, which can't be exported
+	// from a key store
+	keyStore, _, err := node.KeyStore(d.db)
+	if err != nil {
+		return SmartError(err)
+	}
+
+	if keyStore != keystore.File {
+		return BadRequest(fmt.Errorf("Clustering requires core.key_store to be set to file"))
+	}
+
+	// Cluster members share their certificate too
+	certFilename, keyFilename, err := node.HTTPSCert(d.db)
+	if err != nil {
+		return SmartError(err)
+	}
+
+	if certFilename != "" || keyFilename != "" {
+		return BadRequest(fmt.Errorf("Clustering requires core.https_cert and core.https_key to be unset"))
+	}
+
 	// Depending on the provided parameters we either bootstrap a brand new
 	// cluster with this node as first node, or perform a request to join a
 	// given cluster.
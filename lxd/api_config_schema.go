@@ -0,0 +1,18 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+)
+
+var configSchemaCmd = Command{
+	name:         "config/schema",
+	untrustedGet: true,
+	get:          configSchemaGet,
+}
+
+// configSchemaGet returns the JSON schema of the container and device
+// configuration keys, generated from the structs in config_schema.go.
+func configSchemaGet(d *Daemon, r *http.Request) Response {
+	return SyncResponse(true, json.RawMessage(configSchema))
+}
@@ -36,6 +36,7 @@ var apiInternal = []Command{
 	internalContainerOnNetworkUpCmd,
 	internalContainerOnStopCmd,
 	internalContainersCmd,
+	internalCoredumpsCmd,
 	internalSQLCmd,
 	internalClusterAcceptCmd,
 	internalClusterRebalanceCmd,
@@ -0,0 +1,78 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/pprof"
+	"runtime"
+
+	"github.com/gorilla/mux"
+
+	"github.com/lxc/lxd/lxd/cluster"
+	"github.com/lxc/lxd/shared"
+)
+
+var debugPprofCmd = Command{
+	name: "debug/pprof/{profile}",
+	get:  debugPprofGet,
+}
+
+// Profiles which may be retrieved, "cpu" being sampled for a number of seconds
+var debugPprofProfiles = []string{"allocs", "block", "cpu", "goroutine", "heap", "mutex"}
+
+// Sampling of blocking events (one per blocked interval of that many
+// nanoseconds) and of mutex contention (one in that many events), recording
+// every event being too expensive for a running daemon.
+const debugPprofBlockRate = 10000
+const debugPprofMutexFraction = 100
+
+// debugPprofSetRates turns the sampling of blocking events and mutex
+// contention on or off, the block and mutex profiles being empty otherwise.
+func debugPprofSetRates(enabled bool) {
+	if enabled {
+		runtime.SetBlockProfileRate(debugPprofBlockRate)
+		runtime.SetMutexProfileFraction(debugPprofMutexFraction)
+	} else {
+		runtime.SetBlockProfileRate(0)
+		runtime.SetMutexProfileFraction(0)
+	}
+}
+
+func debugPprofGet(d *Daemon, r *http.Request) Response {
+	enabled, err := cluster.ConfigGetBool(d.cluster, "core.debug_pprof")
+	if err != nil {
+		return SmartError(err)
+	}
+
+	if !enabled {
+		return Forbidden(fmt.Errorf("Profiling is disabled, set core.debug_pprof to enable it"))
+	}
+
+	profile := mux.Vars(r)["profile"]
+	if !shared.StringInSlice(profile, debugPprofProfiles) {
+		return NotFound(fmt.Errorf("Unknown profile '%s'", profile))
+	}
+
+	return &debugPprofResponse{req: r, profile: profile}
+}
+
+// debugPprofResponse renders a profile in the pprof format.
+type debugPprofResponse struct {
+	req     *http.Request
+	profile string
+}
+
+func (r *debugPprofResponse) Render(w http.ResponseWriter) error {
+	if r.profile == "cpu" {
+		// Sampled for the number of seconds passed as "seconds", 30 by default
+		pprof.Profile(w, r.req)
+		return nil
+	}
+
+	pprof.Handler(r.profile).ServeHTTP(w, r.req)
+	return nil
+}
+
+func (r *debugPprofResponse) String() string {
+	return fmt.Sprintf("pprof profile %s", r.profile)
+}
@@ -148,7 +148,7 @@ func apiProjectGet(d *Daemon, r *http.Request) Response {
 		return err
 	})
 	if err != nil {
-		return SmartError(err)
+		return SmartError(withNotFoundCode(api.ErrProjectNotFound, err))
 	}
 
 	etag := []interface{}{
@@ -0,0 +1,275 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/lxc/lxd/lxd/db"
+	"github.com/lxc/lxd/lxd/util"
+	"github.com/lxc/lxd/shared"
+	"github.com/lxc/lxd/shared/api"
+)
+
+var terraformStateCmd = Command{
+	name: "terraform/state",
+	get:  terraformStateGet,
+}
+
+// Resource types of the LXD Terraform provider
+var terraformResourceTypes = []string{"lxd_container", "lxd_network", "lxd_profile", "lxd_storage_pool", "lxd_volume"}
+
+var terraformInvalidName = regexp.MustCompile("[^a-zA-Z0-9_-]")
+
+// terraformState collects the resources of a state, making sure their names
+// are valid and unique Terraform identifiers.
+type terraformState struct {
+	resources []api.TerraformResource
+	names     map[string]bool
+}
+
+func (s *terraformState) add(resourceType string, name string, attributes map[string]interface{}) {
+	name = terraformInvalidName.ReplaceAllString(name, "_")
+	if name == "" || (name[0] >= '0' && name[0] <= '9') {
+		name = "_" + name
+	}
+
+	unique := name
+	for i := 2; s.names[resourceType+"."+unique]; i++ {
+		unique = fmt.Sprintf("%s_%d", name, i)
+	}
+	s.names[resourceType+"."+unique] = true
+
+	s.resources = append(s.resources, api.TerraformResource{
+		Mode:     "managed",
+		Type:     resourceType,
+		Name:     unique,
+		Provider: "provider.lxd",
+		Instances: []api.TerraformResourceInstance{
+			{SchemaVersion: 0, Attributes: attributes},
+		},
+	})
+}
+
+// terraformDevices converts devices to the provider's device blocks.
+func terraformDevices(devices map[string]map[string]string) []map[string]interface{} {
+	names := []string{}
+	for name := range devices {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	result := []map[string]interface{}{}
+	for _, name := range names {
+		properties := map[string]string{}
+		for k, v := range devices[name] {
+			if k != "type" {
+				properties[k] = v
+			}
+		}
+
+		result = append(result, map[string]interface{}{
+			"name":       name,
+			"type":       devices[name]["type"],
+			"properties": properties,
+		})
+	}
+
+	return result
+}
+
+// terraformLineage returns a lineage which is stable for this server, derived
+// from its certificate.
+func terraformLineage(d *Daemon) string {
+	fingerprint := d.endpoints.NetworkCert().Fingerprint()
+	if len(fingerprint) < 32 {
+		return ""
+	}
+
+	return fmt.Sprintf("%s-%s-%s-%s-%s", fingerprint[0:8], fingerprint[8:12], fingerprint[12:16], fingerprint[16:20], fingerprint[20:32])
+}
+
+// terraformStateGet returns the containers, networks, profiles, storage pools
+// and custom volumes as a Terraform state, so that they can be imported in
+// Terraform configurations using the LXD provider.
+func terraformStateGet(d *Daemon, r *http.Request) Response {
+	project := projectParam(r)
+
+	wanted := terraformResourceTypes
+	resourceType := r.FormValue("resource_type")
+	if resourceType != "" {
+		wanted = []string{}
+		for _, entry := range strings.Split(resourceType, ",") {
+			if !shared.StringInSlice(entry, terraformResourceTypes) {
+				return BadRequest(fmt.Errorf("Unknown resource type '%s'", entry))
+			}
+
+			wanted = append(wanted, entry)
+		}
+	}
+
+	state := &terraformState{names: map[string]bool{}}
+
+	if shared.StringInSlice("lxd_container", wanted) {
+		var containers []db.Container
+		err := d.cluster.Transaction(func(tx *db.ClusterTx) error {
+			var err error
+			containers, err = tx.ContainerList(db.ContainerFilter{Project: project, Type: int(db.CTypeRegular)})
+			return err
+		})
+		if err != nil {
+			return SmartError(err)
+		}
+
+		for _, c := range containers {
+			config := map[string]string{}
+			limits := map[string]string{}
+			for k, v := range c.Config {
+				if strings.HasPrefix(k, "limits.") {
+					limits[strings.TrimPrefix(k, "limits.")] = v
+				} else if !strings.HasPrefix(k, "volatile.") && !strings.HasPrefix(k, "image.") {
+					config[k] = v
+				}
+			}
+
+			state.add("lxd_container", c.Name, map[string]interface{}{
+				"id":        c.Name,
+				"name":      c.Name,
+				"image":     c.Config["volatile.base_image"],
+				"profiles":  c.Profiles,
+				"ephemeral": c.Ephemeral,
+				"config":    config,
+				"limits":    limits,
+				"device":    terraformDevices(c.Devices),
+				"target":    c.Node,
+			})
+		}
+	}
+
+	if shared.StringInSlice("lxd_profile", wanted) {
+		var profiles []db.Profile
+		err := d.cluster.Transaction(func(tx *db.ClusterTx) error {
+			var err error
+			profiles, err = tx.ProfileList(db.ProfileFilter{Project: project})
+			return err
+		})
+		if err != nil {
+			return SmartError(err)
+		}
+
+		for _, p := range profiles {
+			state.add("lxd_profile", p.Name, map[string]interface{}{
+				"id":          p.Name,
+				"name":        p.Name,
+				"description": p.Description,
+				"config":      p.Config,
+				"device":      terraformDevices(p.Devices),
+			})
+		}
+	}
+
+	if shared.StringInSlice("lxd_network", wanted) {
+		names, err := d.cluster.Networks()
+		if err != nil {
+			return SmartError(err)
+		}
+
+		for _, name := range names {
+			_, network, err := d.cluster.NetworkGet(name)
+			if err != nil {
+				return SmartError(err)
+			}
+
+			state.add("lxd_network", name, map[string]interface{}{
+				"id":          name,
+				"name":        name,
+				"description": network.Description,
+				"config":      network.Config,
+				"type":        network.Type,
+				"managed":     network.Managed,
+			})
+		}
+	}
+
+	pools := []string{}
+	if shared.StringInSlice("lxd_storage_pool", wanted) || shared.StringInSlice("lxd_volume", wanted) {
+		var err error
+		pools, err = d.cluster.StoragePools()
+		if err != nil && err != db.ErrNoSuchObject {
+			return SmartError(err)
+		}
+	}
+
+	for _, name := range pools {
+		poolID, pool, err := d.cluster.StoragePoolGet(name)
+		if err != nil {
+			return SmartError(err)
+		}
+
+		if shared.StringInSlice("lxd_storage_pool", wanted) {
+			state.add("lxd_storage_pool", name, map[string]interface{}{
+				"id":     name,
+				"name":   name,
+				"driver": pool.Driver,
+				"config": pool.Config,
+			})
+		}
+
+		if !shared.StringInSlice("lxd_volume", wanted) {
+			continue
+		}
+
+		volumes, err := d.cluster.StoragePoolVolumesGet(project, poolID, []int{storagePoolVolumeTypeCustom})
+		if err != nil && err != db.ErrNoSuchObject {
+			return SmartError(err)
+		}
+
+		for _, volume := range volumes {
+			// Snapshots aren't resources of their own
+			if strings.Contains(volume.Name, shared.SnapshotDelimiter) {
+				continue
+			}
+
+			state.add("lxd_volume", fmt.Sprintf("%s_%s", name, volume.Name), map[string]interface{}{
+				"id":     fmt.Sprintf("%s/%s/%s", name, volume.Name, volume.Type),
+				"name":   volume.Name,
+				"pool":   name,
+				"type":   volume.Type,
+				"config": volume.Config,
+				"target": volume.Location,
+			})
+		}
+	}
+
+	result := api.TerraformState{
+		Version:          4,
+		TerraformVersion: "0.12.0",
+		Serial:           time.Now().Unix(),
+		Lineage:          terraformLineage(d),
+		Outputs:          map[string]interface{}{},
+		Resources:        state.resources,
+	}
+
+	if result.Resources == nil {
+		result.Resources = []api.TerraformResource{}
+	}
+
+	return &terraformStateResponse{state: result}
+}
+
+// terraformStateResponse renders the state as is, without the usual LXD
+// response envelope, so that it can be fed to Terraform directly.
+type terraformStateResponse struct {
+	state api.TerraformState
+}
+
+func (r *terraformStateResponse) Render(w http.ResponseWriter) error {
+	return util.WriteJSON(w, r.state, debug)
+}
+
+func (r *terraformStateResponse) String() string {
+	return "terraform state"
+}
@@ -8,6 +8,7 @@ import (
 	"os/exec"
 	"path/filepath"
 	"strings"
+	"syscall"
 	"time"
 
 	"golang.org/x/net/context"
@@ -48,6 +49,7 @@ func backupLoadByName(s *state.State, project, name string) (*backup, error) {
 		expiryDate:       args.ExpiryDate,
 		containerOnly:    args.ContainerOnly,
 		optimizedStorage: args.OptimizedStorage,
+		xattrs:           args.Xattrs,
 	}, nil
 }
 
@@ -91,6 +93,7 @@ type backup struct {
 	expiryDate       time.Time
 	containerOnly    bool
 	optimizedStorage bool
+	xattrs           bool
 }
 
 type backupInfo struct {
@@ -153,6 +156,7 @@ func (b *backup) Render() *api.ContainerBackup {
 		ExpiryDate:       b.expiryDate,
 		ContainerOnly:    b.containerOnly,
 		OptimizedStorage: b.optimizedStorage,
+		Xattrs:           b.xattrs,
 	}
 }
 
@@ -385,10 +389,30 @@ func backupCreateTarball(s *state.State, path string, backup backup) error {
 		os.RemoveAll(backupPath)
 	}()
 
-	args := []string{"-cf", backupPath, "--xattrs", "-C", path, "--transform", "s,^./,backup/,", "."}
-	_, err = shared.RunCommand("tar", args...)
-	if err != nil {
-		return err
+	if backup.xattrs {
+		filter, err := cluster.ConfigGetString(s.Cluster, "backups.xattr_filter")
+		if err != nil {
+			return err
+		}
+
+		prefixes := []string{}
+		for _, prefix := range strings.Split(filter, ",") {
+			prefix = strings.TrimSpace(prefix)
+			if prefix != "" {
+				prefixes = append(prefixes, prefix)
+			}
+		}
+
+		err = backupWriteTarball(path, backupPath, prefixes)
+		if err != nil {
+			return err
+		}
+	} else {
+		args := []string{"-cf", backupPath, "--xattrs", "-C", path, "--transform", "s,^./,backup/,", "."}
+		_, err = shared.RunCommand("tar", args...)
+		if err != nil {
+			return err
+		}
 	}
 
 	err = os.RemoveAll(path)
@@ -444,6 +468,132 @@ func backupCreateTarball(s *state.State, path string, backup backup) error {
 	return nil
 }
 
+// backupWriteTarball archives the content of path under backup/ in a tarball,
+// storing the extended attributes whose name starts with one of the prefixes
+// of the filter (or all of them if the filter is empty) as PAX records.
+func backupWriteTarball(path string, target string, filter []string) error {
+	f, err := os.Create(target)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	tw := tar.NewWriter(f)
+	linkmap := map[uint64]string{}
+
+	err = filepath.Walk(path, func(p string, fi os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+
+		rel, err := filepath.Rel(path, p)
+		if err != nil {
+			return err
+		}
+
+		link := ""
+		if fi.Mode()&os.ModeSymlink == os.ModeSymlink {
+			link, err = os.Readlink(p)
+			if err != nil {
+				return err
+			}
+		}
+
+		hdr, err := tar.FileInfoHeader(fi, link)
+		if err != nil {
+			return err
+		}
+
+		hdr.Name = filepath.Join("backup", rel)
+		if fi.IsDir() {
+			hdr.Name += "/"
+		}
+
+		// Only keep numeric ownership, names may differ on restore
+		hdr.Uname = ""
+		hdr.Gname = ""
+
+		stat, ok := fi.Sys().(*syscall.Stat_t)
+		if ok {
+			hdr.Uid = int(stat.Uid)
+			hdr.Gid = int(stat.Gid)
+
+			// If it's a hardlink we've already seen use the old name
+			if fi.Mode().IsRegular() && stat.Nlink > 1 {
+				firstpath, found := linkmap[uint64(stat.Ino)]
+				if found {
+					hdr.Typeflag = tar.TypeLink
+					hdr.Linkname = firstpath
+					hdr.Size = 0
+				} else {
+					linkmap[uint64(stat.Ino)] = hdr.Name
+				}
+			}
+		}
+
+		// Enumerate the xattrs (for real files only)
+		if link == "" {
+			xattrs, err := shared.GetAllXattr(p)
+			if err != nil {
+				return fmt.Errorf("Failed to read xattr for '%s': %s", p, err)
+			}
+
+			for name, value := range xattrs {
+				if !backupXattrAllowed(name, filter) {
+					continue
+				}
+
+				if hdr.Xattrs == nil {
+					hdr.Xattrs = map[string]string{}
+				}
+
+				hdr.Xattrs[name] = value
+			}
+		}
+
+		err = tw.WriteHeader(hdr)
+		if err != nil {
+			return fmt.Errorf("Failed to write tar header: %s", err)
+		}
+
+		if hdr.Typeflag == tar.TypeReg {
+			src, err := os.Open(p)
+			if err != nil {
+				return err
+			}
+			defer src.Close()
+
+			_, err = io.Copy(tw, src)
+			if err != nil {
+				return fmt.Errorf("Failed to copy file content: %s", err)
+			}
+		}
+
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	return tw.Close()
+}
+
+// backupXattrAllowed returns whether an extended attribute matches the
+// backups.xattr_filter prefixes.
+func backupXattrAllowed(name string, filter []string) bool {
+	if len(filter) == 0 {
+		return true
+	}
+
+	for _, prefix := range filter {
+		if strings.HasPrefix(name, prefix) {
+			return true
+		}
+	}
+
+	return false
+}
+
 func pruneExpiredContainerBackupsTask(d *Daemon) (task.Func, task.Schedule) {
 	f := func(ctx context.Context) {
 		opRun := func(op *operation) error {
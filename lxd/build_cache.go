@@ -0,0 +1,286 @@
+package main
+
+import (
+	"crypto/sha256"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"sync"
+
+	"github.com/pkg/errors"
+
+	"github.com/lxc/lxd/lxd/cluster"
+	"github.com/lxc/lxd/lxd/db"
+	"github.com/lxc/lxd/shared"
+	"github.com/lxc/lxd/shared/api"
+	"github.com/lxc/lxd/shared/logger"
+
+	log "github.com/lxc/lxd/shared/log15"
+)
+
+var buildCacheStatsCmd = Command{
+	name: "cache/stats",
+	get:  buildCacheStatsGet,
+}
+
+// Build cache counters since the daemon started
+var buildCacheCounters struct {
+	sync.Mutex
+	hits      int64
+	misses    int64
+	evictions int64
+}
+
+// buildCacheBaseHash returns the root of the hash chain of containers created
+// from the given image.
+func buildCacheBaseHash(fingerprint string) string {
+	return fmt.Sprintf("%x", sha256.Sum256([]byte("image\n"+fingerprint)))
+}
+
+// buildCacheStepHash returns the hash of the layer obtained by applying the
+// given build step on top of the parent layer.
+func buildCacheStepHash(parent string, step string) string {
+	return fmt.Sprintf("%x", sha256.Sum256([]byte(parent+"\n"+step)))
+}
+
+// buildCacheParentHash returns the hash of the current layer of a container.
+func buildCacheParentHash(c container) string {
+	hash := c.LocalConfig()["volatile.build.hash"]
+	if hash != "" {
+		return hash
+	}
+
+	return buildCacheBaseHash(c.LocalConfig()["volatile.base_image"])
+}
+
+// buildCacheLookup returns the image holding the last cached layer of the
+// build steps applied to the base image, along with the hash of that layer and
+// the number of steps it covers. The base image is returned if no step is
+// cached.
+func buildCacheLookup(d *Daemon, project string, base *api.Image, steps []string) (*api.Image, string, int, error) {
+	entries, err := d.cluster.ImagesBuildCache()
+	if err != nil {
+		return nil, "", 0, err
+	}
+
+	// Images are shared with the default project unless the project has
+	// its own
+	imageProject := project
+	err = d.cluster.Transaction(func(tx *db.ClusterTx) error {
+		enabled, err := tx.ProjectHasImages(project)
+		if err != nil {
+			return err
+		}
+
+		if !enabled {
+			imageProject = "default"
+		}
+
+		return nil
+	})
+	if err != nil {
+		return nil, "", 0, err
+	}
+
+	cached := map[string]string{}
+	for _, entry := range entries {
+		if entry.Project == imageProject {
+			cached[entry.Hash] = entry.Fingerprint
+		}
+	}
+
+	hash := base.Properties["build.hash"]
+	if hash == "" {
+		hash = buildCacheBaseHash(base.Fingerprint)
+	}
+
+	info := base
+	count := 0
+	for _, step := range steps {
+		next := buildCacheStepHash(hash, step)
+		fingerprint, ok := cached[next]
+		if !ok {
+			break
+		}
+
+		_, image, err := d.cluster.ImageGet(project, fingerprint, false, true)
+		if err != nil {
+			break
+		}
+
+		info = image
+		hash = next
+		count++
+	}
+
+	buildCacheCounters.Lock()
+	buildCacheCounters.hits += int64(count)
+	buildCacheCounters.misses += int64(len(steps) - count)
+	buildCacheCounters.Unlock()
+
+	return info, hash, count, nil
+}
+
+// buildCacheStore publishes a container snapshot as an image indexed by the
+// hash of the layer, then evicts the least recently used layers if the cache
+// grew past its maximum size.
+func buildCacheStore(d *Daemon, r *http.Request, op *operation, c container, snapshot string, step string) error {
+	parent := buildCacheParentHash(c)
+	hash := buildCacheStepHash(parent, step)
+
+	builddir, err := ioutil.TempDir(shared.VarPath("images"), "lxd_build_")
+	if err != nil {
+		return err
+	}
+	defer os.RemoveAll(builddir)
+
+	req := api.ImagesPost{
+		Source: &api.ImagesPostSource{
+			Type: "snapshot",
+			Name: snapshot,
+		},
+	}
+	req.Properties = map[string]string{
+		"build.hash":   hash,
+		"build.parent": parent,
+		"build.step":   step,
+		"description":  fmt.Sprintf("Build cache layer of %s (%s)", c.Name(), step),
+	}
+
+	imagePublishLock.Lock()
+	info, err := imgPostContInfo(d, r, req, op, builddir)
+	imagePublishLock.Unlock()
+
+	if err != nil {
+		if info == nil {
+			return err
+		}
+
+		// An identical image already exists, index it under this layer
+		err = buildCacheAttach(d, projectParam(r), info.Fingerprint, req.Properties)
+		if err != nil {
+			return err
+		}
+	}
+
+	// Further layers chain from this one
+	err = c.ConfigKeySet("volatile.build.hash", hash)
+	if err != nil {
+		return err
+	}
+
+	return buildCacheEvict(d)
+}
+
+// buildCacheAttach records the build properties of a layer on an already
+// existing image with the same content.
+func buildCacheAttach(d *Daemon, project string, fingerprint string, properties map[string]string) error {
+	id, image, err := d.cluster.ImageGet(project, fingerprint, false, true)
+	if err != nil {
+		return err
+	}
+
+	// Keep the hash of a layer which already points to this image, as
+	// replacing it would break the chain of its children.
+	if image.Properties["build.hash"] != "" {
+		return nil
+	}
+
+	props := map[string]string{}
+	for k, v := range image.Properties {
+		props[k] = v
+	}
+
+	for _, key := range []string{"build.hash", "build.parent", "build.step"} {
+		props[key] = properties[key]
+	}
+
+	return d.cluster.ImageUpdate(id, image.Filename, image.Size, image.Public, image.AutoUpdate, image.Architecture, image.CreatedAt, image.ExpiresAt, props)
+}
+
+// buildCacheSize returns the maximum size in bytes of the build cache.
+func buildCacheSize(d *Daemon) (int64, error) {
+	var size int64
+	err := d.cluster.Transaction(func(tx *db.ClusterTx) error {
+		config, err := cluster.ConfigLoad(tx)
+		if err != nil {
+			return errors.Wrap(err, "Failed to load cluster configuration")
+		}
+		size = config.BuildCacheSize()
+		return nil
+	})
+	if err != nil {
+		return -1, err
+	}
+
+	return size, nil
+}
+
+// buildCacheEvict deletes the least recently used cached layers until the
+// cache fits in images.build_cache_size.
+func buildCacheEvict(d *Daemon) error {
+	limit, err := buildCacheSize(d)
+	if err != nil || limit <= 0 {
+		return err
+	}
+
+	entries, err := d.cluster.ImagesBuildCache()
+	if err != nil {
+		return err
+	}
+
+	size := int64(0)
+	for _, entry := range entries {
+		size += entry.Size
+	}
+
+	for _, entry := range entries {
+		if size <= limit {
+			break
+		}
+
+		logger.Info("Evicting build cache layer", log.Ctx{"project": entry.Project, "fingerprint": entry.Fingerprint, "hash": entry.Hash})
+		err := imagePrune(d, entry.Project, entry.Fingerprint)
+		if err != nil {
+			return err
+		}
+
+		size -= entry.Size
+
+		buildCacheCounters.Lock()
+		buildCacheCounters.evictions++
+		buildCacheCounters.Unlock()
+	}
+
+	return nil
+}
+
+func buildCacheStatsGet(d *Daemon, r *http.Request) Response {
+	entries, err := d.cluster.ImagesBuildCache()
+	if err != nil {
+		return SmartError(err)
+	}
+
+	maxSize, err := buildCacheSize(d)
+	if err != nil {
+		return SmartError(err)
+	}
+
+	stats := api.BuildCacheStats{}
+	stats.MaxSize = maxSize
+	stats.Entries = int64(len(entries))
+	for _, entry := range entries {
+		stats.Size += entry.Size
+	}
+
+	buildCacheCounters.Lock()
+	stats.Hits = buildCacheCounters.hits
+	stats.Misses = buildCacheCounters.misses
+	stats.Evictions = buildCacheCounters.evictions
+	buildCacheCounters.Unlock()
+
+	stats.Images = imageCacheStats()
+
+	return SyncResponse(true, stats)
+}
@@ -0,0 +1,105 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+
+	"github.com/lxc/lxd/lxd/db"
+	"github.com/lxc/lxd/lxd/keystore"
+	"github.com/lxc/lxd/lxd/node"
+	"github.com/lxc/lxd/shared"
+	"github.com/lxc/lxd/shared/api"
+	"github.com/lxc/lxd/shared/logger"
+
+	log "github.com/lxc/lxd/shared/log15"
+)
+
+var certificatesReloadCmd = Command{
+	name: "certificates/reload",
+	post: certificatesReloadPost,
+}
+
+// serverCertLoad loads the certificate of the network endpoint, from
+// core.https_cert and core.https_key if set or else from the var dir along
+// with the key store holding its private key.
+func serverCertLoad(dir string, config *node.Config) (*shared.CertInfo, error) {
+	store, uri := config.KeyStore()
+	certFilename, keyFilename := config.HTTPSCert()
+	if certFilename == "" && keyFilename == "" {
+		return keystore.LoadCert(dir, store, uri)
+	}
+
+	if certFilename == "" || keyFilename == "" {
+		return nil, fmt.Errorf("core.https_cert and core.https_key must be set together")
+	}
+
+	if store != "" && store != keystore.File {
+		return nil, fmt.Errorf("core.https_cert can't be used along with the %s key store", store)
+	}
+
+	return keystore.LoadCertFiles(dir, certFilename, keyFilename)
+}
+
+// serverCertLoadOrDefault loads the certificate of the network endpoint when
+// LXD starts, falling back to the one of the var dir should the one set in
+// core.https_cert and core.https_key fail to load (e.g. once expired) rather
+// than keeping LXD from starting.
+func serverCertLoadOrDefault(dir string, config *node.Config) (*shared.CertInfo, error) {
+	cert, err := serverCertLoad(dir, config)
+	if err == nil {
+		return cert, nil
+	}
+
+	certFilename, keyFilename := config.HTTPSCert()
+	if certFilename == "" && keyFilename == "" {
+		return nil, err
+	}
+
+	logger.Error("Failed to load the server certificate set in core.https_cert, using the default one", log.Ctx{"err": err})
+
+	store, uri := config.KeyStore()
+	return keystore.LoadCert(dir, store, uri)
+}
+
+// serverCertUpdate switches the network endpoint to a new certificate. The
+// connections already established keep using the previous one.
+func serverCertUpdate(d *Daemon, cert *shared.CertInfo) {
+	oldCert := d.endpoints.NetworkCert()
+	d.endpoints.NetworkUpdateCert(cert)
+	keystore.Close(oldCert)
+
+	fingerprint := cert.Fingerprint()
+	logger.Info("Updated the server certificate", log.Ctx{"fingerprint": fingerprint})
+	eventSendLifecycle("", "certificate-reloaded", "/1.0", map[string]interface{}{
+		"fingerprint": fingerprint,
+	})
+}
+
+// /1.0/certificates/reload
+// Load the server certificate again, such as after its renewal
+func certificatesReloadPost(d *Daemon, r *http.Request) Response {
+	var config *node.Config
+	err := d.db.Transaction(func(tx *db.NodeTx) error {
+		var err error
+		config, err = node.ConfigLoad(tx)
+		return err
+	})
+	if err != nil {
+		return SmartError(err)
+	}
+
+	cert, err := serverCertLoad(d.os.VarDir, config)
+	if err != nil {
+		return BadRequest(err)
+	}
+
+	serverCertUpdate(d, cert)
+
+	store, _ := config.KeyStore()
+	err = keystore.RemoveKey(d.os.VarDir, store)
+	if err != nil {
+		return SmartError(err)
+	}
+
+	return SyncResponse(true, api.CertificatesReload{Fingerprint: cert.Fingerprint()})
+}
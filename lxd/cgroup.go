@@ -5,7 +5,10 @@ import (
 	"io/ioutil"
 	"os"
 	"path"
+	"strconv"
 	"strings"
+
+	"github.com/lxc/lxd/shared/api"
 )
 
 func getInitCgroupPath(controller string) string {
@@ -59,3 +62,64 @@ func cGroupSet(controller, cgroup, file string, value string) error {
 
 	return ioutil.WriteFile(path, []byte(value), 0755)
 }
+
+// cgroupMemoryStatV2 maps the keys of the unified hierarchy's memory.stat to
+// their cgroup v1 equivalent.
+var cgroupMemoryStatV2 = map[string]string{
+	"anon":        "rss",
+	"file":        "cache",
+	"file_mapped": "mapped_file",
+}
+
+// cgroupMemoryStats parses the content of a memory.stat cgroup file, from
+// either cgroup v1 or the unified hierarchy. The hierarchical (total_*) values
+// of cgroup v1 are used when available, so that nested cgroups are accounted
+// for.
+func cgroupMemoryStats(content string) api.ContainerStateMemoryStats {
+	values := map[string]int64{}
+	total := map[string]int64{}
+
+	for _, line := range strings.Split(content, "\n") {
+		fields := strings.Fields(line)
+		if len(fields) != 2 {
+			continue
+		}
+
+		value, err := strconv.ParseInt(fields[1], 10, 64)
+		if err != nil {
+			continue
+		}
+
+		key := fields[0]
+		if strings.HasPrefix(key, "total_") {
+			total[strings.TrimPrefix(key, "total_")] = value
+			continue
+		}
+
+		v1Key, ok := cgroupMemoryStatV2[key]
+		if ok {
+			key = v1Key
+		}
+
+		values[key] = value
+	}
+
+	for key, value := range total {
+		values[key] = value
+	}
+
+	return api.ContainerStateMemoryStats{
+		RSS:               values["rss"],
+		Cache:             values["cache"],
+		MappedFile:        values["mapped_file"],
+		PageFaults:        values["pgfault"],
+		MajorPageFaults:   values["pgmajfault"],
+		InactiveAnon:      values["inactive_anon"],
+		ActiveAnon:        values["active_anon"],
+		InactiveFile:      values["inactive_file"],
+		ActiveFile:        values["active_file"],
+		Unevictable:       values["unevictable"],
+		SlabReclaimable:   values["slab_reclaimable"],
+		SlabUnreclaimable: values["slab_unreclaimable"],
+	}
+}
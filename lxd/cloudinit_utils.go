@@ -0,0 +1,95 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"syscall"
+
+	"github.com/lxc/lxd/shared"
+)
+
+// Address and interface used to serve the NoCloud datasource inside containers
+const cloudInitMetadataAddress = "169.254.169.254"
+const cloudInitMetadataDevice = "lxdmeta0"
+
+func cloudInitEnabled(config map[string]string) bool {
+	return config["user.cloud-init.user-data"] != "" || config["user.cloud-init.meta-data"] != ""
+}
+
+func cloudInitWriteSeed(c container, path string) error {
+	err := os.MkdirAll(path, 0711)
+	if err != nil {
+		return err
+	}
+
+	config := c.ExpandedConfig()
+
+	metaData := config["user.cloud-init.meta-data"]
+	if metaData == "" {
+		metaData = fmt.Sprintf("instance-id: %s\nlocal-hostname: %s\n", c.Name(), c.Name())
+	}
+
+	files := map[string]string{
+		"meta-data":   metaData,
+		"user-data":   config["user.cloud-init.user-data"],
+		"vendor-data": "",
+	}
+
+	for name, content := range files {
+		err := ioutil.WriteFile(filepath.Join(path, name), []byte(content), 0600)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func killCloudInitServer(pidPath string) error {
+	// Get the contents of the pid file
+	contents, err := ioutil.ReadFile(pidPath)
+	if err != nil {
+		return err
+	}
+	pidString := strings.TrimSpace(string(contents))
+
+	// Check if the process still exists
+	if !shared.PathExists(fmt.Sprintf("/proc/%s", pidString)) {
+		os.Remove(pidPath)
+		return nil
+	}
+
+	// Check if it's the metadata server
+	cmdArgs, err := ioutil.ReadFile(fmt.Sprintf("/proc/%s/cmdline", pidString))
+	if err != nil {
+		os.Remove(pidPath)
+		return nil
+	}
+
+	cmdFields := strings.Split(string(bytes.TrimRight(cmdArgs, string("\x00"))), string(byte(0)))
+	if len(cmdFields) < 5 || cmdFields[1] != "forknet" || cmdFields[2] != "metadata" {
+		os.Remove(pidPath)
+		return nil
+	}
+
+	// Parse the pid
+	pidInt, err := strconv.Atoi(pidString)
+	if err != nil {
+		return err
+	}
+
+	// Actually kill the process
+	err = syscall.Kill(pidInt, syscall.SIGKILL)
+	if err != nil {
+		return err
+	}
+
+	// Cleanup
+	os.Remove(pidPath)
+	return nil
+}
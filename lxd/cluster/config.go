@@ -5,14 +5,18 @@ import (
 	"encoding/hex"
 	"fmt"
 	"io"
+	"net/url"
 	"os/exec"
+	"path/filepath"
 	"strconv"
+	"strings"
 	"time"
 
 	"golang.org/x/crypto/scrypt"
 
 	"github.com/lxc/lxd/lxd/config"
 	"github.com/lxc/lxd/lxd/db"
+	"github.com/lxc/lxd/shared"
 	"github.com/pkg/errors"
 )
 
@@ -39,6 +43,30 @@ func ConfigLoad(tx *db.ClusterTx) (*Config, error) {
 	return &Config{tx: tx, m: m}, nil
 }
 
+// MemoryBalloonInterval returns how often the memory limits of the containers
+// with a memory balloon are adjusted.
+func (c *Config) MemoryBalloonInterval() time.Duration {
+	n := c.m.GetInt64("core.memory_balloon_interval")
+	return time.Duration(n) * time.Second
+}
+
+// EventsBuffer returns the number of events kept around to be replayed to
+// reconnecting event stream clients.
+func (c *Config) EventsBuffer() int64 {
+	return c.m.GetInt64("core.events_buffer")
+}
+
+// CoredumpCapture returns whether the core dumps of the processes of the
+// containers are captured by LXD.
+func (c *Config) CoredumpCapture() bool {
+	return c.m.GetBool("core.coredump_capture")
+}
+
+// DebugPprof returns whether the pprof profiles are served by the REST API.
+func (c *Config) DebugPprof() bool {
+	return c.m.GetBool("core.debug_pprof")
+}
+
 // HTTPSAllowedHeaders returns the relevant CORS setting.
 func (c *Config) HTTPSAllowedHeaders() string {
 	return c.m.GetString("core.https_allowed_headers")
@@ -85,12 +113,80 @@ func (c *Config) CandidDomains() string {
 	return c.m.GetString("candid.domains")
 }
 
+// OPAEndpoint returns the URL of the policy decision used to authorize API
+// requests, if any.
+func (c *Config) OPAEndpoint() string {
+	return c.m.GetString("auth.opa_endpoint")
+}
+
+// LDAPURI returns the URI of the LDAP server used to authenticate clients, if
+// any.
+func (c *Config) LDAPURI() string {
+	return c.m.GetString("auth.ldap.uri")
+}
+
+// LDAPBind returns the DN and password used to bind to the LDAP server.
+func (c *Config) LDAPBind() (string, string) {
+	return c.m.GetString("auth.ldap.bind_dn"), c.m.GetString("auth.ldap.bind_password")
+}
+
+// LDAPBaseDN returns the DN under which users and groups are searched.
+func (c *Config) LDAPBaseDN() string {
+	return c.m.GetString("auth.ldap.base_dn")
+}
+
+// LDAPUserFilter returns the filter used to find the user matching a client
+// certificate common name.
+func (c *Config) LDAPUserFilter() string {
+	return c.m.GetString("auth.ldap.user_filter")
+}
+
+// LDAPGroupFilter returns the filter a user DN must match to be trusted, if
+// any.
+func (c *Config) LDAPGroupFilter() string {
+	return c.m.GetString("auth.ldap.group_filter")
+}
+
+// BuildCacheSize returns the maximum size in bytes of the container build
+// cache.
+func (c *Config) BuildCacheSize() int64 {
+	size, _ := shared.ParseByteSizeString(c.m.GetString("images.build_cache_size"))
+	return size
+}
+
 // AutoUpdateInterval returns the configured images auto update interval.
 func (c *Config) AutoUpdateInterval() time.Duration {
 	n := c.m.GetInt64("images.auto_update_interval")
 	return time.Duration(n) * time.Hour
 }
 
+// ImagesGCInterval returns the configured interval of the removal of the
+// images which aren't used anymore.
+func (c *Config) ImagesGCInterval() time.Duration {
+	n := c.m.GetInt64("images.gc_interval")
+	return time.Duration(n) * time.Hour
+}
+
+// ContainersDefaultOOMScoreAdj returns the OOM killer score adjustment of the
+// containers which don't set oom_score_adj.
+func (c *Config) ContainersDefaultOOMScoreAdj() int64 {
+	return c.m.GetInt64("containers.default_oom_score_adj")
+}
+
+// SnapshotsRetentionInterval returns the configured interval of the
+// enforcement of the snapshot retention policy of the containers.
+func (c *Config) SnapshotsRetentionInterval() time.Duration {
+	n := c.m.GetInt64("snapshots.retention_interval")
+	return time.Duration(n) * time.Minute
+}
+
+// ContainersDriftCheckInterval returns the configured interval of the
+// detection of the containers whose config drifted from their profiles.
+func (c *Config) ContainersDriftCheckInterval() time.Duration {
+	n := c.m.GetInt64("containers.drift_check_interval")
+	return time.Duration(n) * time.Minute
+}
+
 // RemoteCacheExpiry returns the configured expiration value for remote images
 // expiration.
 func (c *Config) RemoteCacheExpiry() int64 {
@@ -119,6 +215,24 @@ func (c *Config) MAASController() (string, string) {
 	return url, key
 }
 
+// SecretsBackend returns the backend secrets injected in exec sessions are
+// fetched from ("none", "vault" or "file").
+func (c *Config) SecretsBackend() string {
+	return c.m.GetString("secrets.backend")
+}
+
+// SecretsVault returns the URL of the Vault KV mount secrets are read from and
+// the token used to authenticate to it.
+func (c *Config) SecretsVault() (string, string) {
+	return c.m.GetString("secrets.vault.url"), c.m.GetString("secrets.vault.token")
+}
+
+// SecretsFilePath returns the directory secrets are read from when using the
+// file backend.
+func (c *Config) SecretsFilePath() string {
+	return c.m.GetString("secrets.file.path")
+}
+
 // OfflineThreshold returns the configured heartbeat threshold, i.e. the
 // number of seconds before after which an unresponsive node is considered
 // offline..
@@ -132,6 +246,12 @@ func (c *Config) ImagesMinimalReplica() int64 {
 	return c.m.GetInt64("cluster.images_minimal_replica")
 }
 
+// ImagesReplicationFactor returns the number of nodes which must have a copy
+// of a new image before its creation completes.
+func (c *Config) ImagesReplicationFactor() int64 {
+	return c.m.GetInt64("images.replication_factor")
+}
+
 // Dump current configuration keys and their values. Keys with values matching
 // their defaults are omitted.
 func (c *Config) Dump() map[string]interface{} {
@@ -221,27 +341,58 @@ func configGet(cluster *db.Cluster) (*Config, error) {
 
 // ConfigSchema defines available server configuration keys.
 var ConfigSchema = config.Schema{
-	"backups.compression_algorithm":  {Default: "gzip", Validator: validateCompression},
-	"cluster.offline_threshold":      {Type: config.Int64, Default: offlineThresholdDefault(), Validator: offlineThresholdValidator},
-	"cluster.images_minimal_replica": {Type: config.Int64, Default: "3", Validator: imageMinimalReplicaValidator},
-	"core.https_allowed_headers":     {},
-	"core.https_allowed_methods":     {},
-	"core.https_allowed_origin":      {},
-	"core.https_allowed_credentials": {Type: config.Bool},
-	"core.proxy_http":                {},
-	"core.proxy_https":               {},
-	"core.proxy_ignore_hosts":        {},
-	"core.trust_password":            {Hidden: true, Setter: passwordSetter},
-	"candid.api.key":                 {},
-	"candid.api.url":                 {},
-	"candid.domains":                 {},
-	"candid.expiry":                  {Type: config.Int64, Default: "3600"},
-	"images.auto_update_cached":      {Type: config.Bool, Default: "true"},
-	"images.auto_update_interval":    {Type: config.Int64, Default: "6"},
-	"images.compression_algorithm":   {Default: "gzip", Validator: validateCompression},
-	"images.remote_cache_expiry":     {Type: config.Int64, Default: "10"},
-	"maas.api.key":                   {},
-	"maas.api.url":                   {},
+	"auth.ldap.base_dn":                {},
+	"auth.ldap.bind_dn":                {},
+	"auth.ldap.bind_password":          {Hidden: true},
+	"auth.ldap.group_filter":           {Validator: ldapFilterValidator},
+	"auth.ldap.uri":                    {Validator: ldapURIValidator},
+	"auth.ldap.user_filter":            {Default: "(uid=%s)", Validator: ldapFilterValidator},
+	"auth.opa_endpoint":                {Validator: opaEndpointValidator},
+	"backups.compression_algorithm":    {Default: "gzip", Validator: validateCompression},
+	"backups.xattr_filter":             {},
+	"cluster.offline_threshold":        {Type: config.Int64, Default: offlineThresholdDefault(), Validator: offlineThresholdValidator},
+	"cluster.images_minimal_replica":   {Type: config.Int64, Default: "3", Validator: imageMinimalReplicaValidator},
+	"containers.default_oom_score_adj": {Type: config.Int64, Default: "0", Validator: shared.IsOOMScoreAdj},
+	"containers.drift_check_interval":  {Type: config.Int64, Default: "60", Validator: driftCheckIntervalValidator},
+	"core.console_mock_mode":           {Type: config.Bool},
+	"core.coredump_capture":            {Type: config.Bool},
+	"core.debug_pprof":                 {Type: config.Bool},
+	"core.events_buffer":               {Type: config.Int64, Default: "1000", Validator: eventsBufferValidator},
+	"core.idempotency_ttl":             {Type: config.Int64, Default: "300", Validator: idempotencyTTLValidator},
+	"core.memory_balloon_interval":     {Type: config.Int64, Default: "10", Validator: memoryBalloonIntervalValidator},
+	"core.https_allowed_headers":       {},
+	"core.https_allowed_methods":       {},
+	"core.https_allowed_origin":        {},
+	"core.https_allowed_credentials":   {Type: config.Bool},
+	"core.proxy_http":                  {},
+	"core.proxy_https":                 {},
+	"core.proxy_ignore_hosts":          {},
+	"core.trust_password":              {Hidden: true, Setter: passwordSetter},
+	"candid.api.key":                   {},
+	"candid.api.url":                   {},
+	"candid.domains":                   {},
+	"candid.expiry":                    {Type: config.Int64, Default: "3600"},
+	"images.auto_update_cached":        {Type: config.Bool, Default: "true"},
+	"images.auto_update_interval":      {Type: config.Int64, Default: "6"},
+	"images.build_cache_size":          {Default: "10GB", Validator: buildCacheSizeValidator},
+	"images.compression_algorithm":     {Default: "gzip", Validator: validateCompression},
+	"images.gc_interval":               {Type: config.Int64, Default: "24"},
+	"images.gc_min_age":                {Type: config.Int64, Default: "7"},
+	"images.remote_cache_expiry":       {Type: config.Int64, Default: "10"},
+	"images.replication_factor":        {Type: config.Int64, Default: "1", Validator: imageReplicationFactorValidator},
+	"images.require_signature":         {Type: config.Bool, Default: "false"},
+	"images.signature_policy":          {Validator: imageSignaturePolicyValidator},
+	"maas.api.key":                     {},
+	"maas.api.url":                     {},
+	"secrets.backend":                  {Default: "none", Validator: secretsBackendValidator},
+	"secrets.file.path":                {},
+	"secrets.vault.token":              {Hidden: true},
+	"secrets.vault.url":                {Validator: secretsVaultURLValidator},
+	"snapshots.retention_interval":     {Type: config.Int64, Default: "60", Validator: snapshotsRetentionIntervalValidator},
+	"storage.max_concurrent_ops":       {Type: config.Int64, Default: "4", Validator: storageOpsValidator},
+	"storage.ops_wait_timeout":         {Type: config.Int64, Default: "60", Validator: storageOpsValidator},
+	"storage.overcommit_limit":         {Default: "0", Validator: overcommitLimitValidator},
+	"storage.quota_threshold":          {Type: config.Int64, Default: "95", Validator: quotaThresholdValidator},
 
 	// Keys deprecated since the implementation of the storage api.
 	"storage.lvm_fstype":           {Setter: deprecatedStorage, Default: "ext4"},
@@ -286,6 +437,190 @@ func imageMinimalReplicaValidator(value string) error {
 	return nil
 }
 
+func driftCheckIntervalValidator(value string) error {
+	interval, err := strconv.Atoi(value)
+	if err != nil {
+		return fmt.Errorf("Drift check interval is not a number")
+	}
+
+	if interval < 0 {
+		return fmt.Errorf("Invalid value for drift check interval")
+	}
+
+	return nil
+}
+
+func snapshotsRetentionIntervalValidator(value string) error {
+	interval, err := strconv.Atoi(value)
+	if err != nil {
+		return fmt.Errorf("Snapshot retention interval is not a number")
+	}
+
+	if interval < 1 {
+		return fmt.Errorf("Invalid value for snapshot retention interval")
+	}
+
+	return nil
+}
+
+func imageReplicationFactorValidator(value string) error {
+	count, err := strconv.Atoi(value)
+	if err != nil {
+		return fmt.Errorf("Image replication factor is not a number")
+	}
+
+	if count < 1 {
+		return fmt.Errorf("Invalid value for image replication factor")
+	}
+
+	return nil
+}
+
+func opaEndpointValidator(value string) error {
+	if value == "" {
+		return nil
+	}
+
+	u, err := url.Parse(value)
+	if err != nil {
+		return err
+	}
+
+	if u.Scheme != "http" && u.Scheme != "https" {
+		return fmt.Errorf("Policy endpoint must be an http or https URL")
+	}
+
+	return nil
+}
+
+func secretsBackendValidator(value string) error {
+	return shared.IsOneOf(value, []string{"none", "vault", "file"})
+}
+
+func secretsVaultURLValidator(value string) error {
+	if value == "" {
+		return nil
+	}
+
+	u, err := url.Parse(value)
+	if err != nil {
+		return err
+	}
+
+	if u.Scheme != "http" && u.Scheme != "https" {
+		return fmt.Errorf("Vault URL must be an http or https URL")
+	}
+
+	return nil
+}
+
+func ldapURIValidator(value string) error {
+	if value == "" {
+		return nil
+	}
+
+	u, err := url.Parse(value)
+	if err != nil {
+		return err
+	}
+
+	if u.Scheme != "ldap" && u.Scheme != "ldaps" {
+		return fmt.Errorf("LDAP URI must use the ldap or ldaps scheme")
+	}
+
+	return nil
+}
+
+func ldapFilterValidator(value string) error {
+	if value == "" {
+		return nil
+	}
+
+	if strings.Count(value, "%s") != 1 {
+		return fmt.Errorf("LDAP filter must contain a single %%s placeholder")
+	}
+
+	return nil
+}
+
+func eventsBufferValidator(value string) error {
+	count, err := strconv.Atoi(value)
+	if err != nil {
+		return fmt.Errorf("Events buffer size is not a number")
+	}
+
+	if count < 0 {
+		return fmt.Errorf("Events buffer size can't be negative")
+	}
+
+	return nil
+}
+
+func idempotencyTTLValidator(value string) error {
+	ttl, err := strconv.Atoi(value)
+	if err != nil {
+		return fmt.Errorf("Idempotency TTL is not a number")
+	}
+
+	if ttl < 0 {
+		return fmt.Errorf("Idempotency TTL can't be negative")
+	}
+
+	return nil
+}
+
+func memoryBalloonIntervalValidator(value string) error {
+	interval, err := strconv.Atoi(value)
+	if err != nil {
+		return fmt.Errorf("Memory balloon interval is not a number")
+	}
+
+	if interval < 1 {
+		return fmt.Errorf("Memory balloon interval must be at least one second")
+	}
+
+	return nil
+}
+
+func quotaThresholdValidator(value string) error {
+	threshold, err := strconv.Atoi(value)
+	if err != nil {
+		return fmt.Errorf("Quota threshold is not a number")
+	}
+
+	if threshold < 0 || threshold > 100 {
+		return fmt.Errorf("Quota threshold must be a percentage between 0 and 100")
+	}
+
+	return nil
+}
+
+func storageOpsValidator(value string) error {
+	n, err := strconv.Atoi(value)
+	if err != nil {
+		return fmt.Errorf("Value is not a number")
+	}
+
+	if n < 0 {
+		return fmt.Errorf("Value must be positive")
+	}
+
+	return nil
+}
+
+func overcommitLimitValidator(value string) error {
+	limit, err := strconv.ParseFloat(value, 64)
+	if err != nil {
+		return fmt.Errorf("Overcommit limit is not a number")
+	}
+
+	if limit < 0 {
+		return fmt.Errorf("Overcommit limit must be positive")
+	}
+
+	return nil
+}
+
 func passwordSetter(value string) (string, error) {
 	// Nothing to do on unset
 	if value == "" {
@@ -310,6 +645,19 @@ func passwordSetter(value string) (string, error) {
 	return value, nil
 }
 
+func buildCacheSizeValidator(value string) error {
+	_, err := shared.ParseByteSizeString(value)
+	return err
+}
+
+func imageSignaturePolicyValidator(value string) error {
+	if value != "" && !filepath.IsAbs(value) {
+		return fmt.Errorf("The signature policy must be an absolute path")
+	}
+
+	return nil
+}
+
 func validateCompression(value string) error {
 	if value == "none" {
 		return nil
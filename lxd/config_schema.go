@@ -0,0 +1,266 @@
+package main
+
+//go:generate lxd-generate config schema -t config_schema.gen.go
+
+// The structs below document the container and device configuration keys. They
+// aren't used at runtime but their tags are turned into the JSON schema
+// returned by /1.0/config/schema.
+//
+// Supported tags are: key (name of the key, trailing "*" for namespaces),
+// type (to override the type derived from the field, e.g. "size"), default,
+// values (comma separated list of valid values), pattern (regular expression
+// the value must match), required, live (whether the key can be changed on a
+// running container, "n/a" for the keys not affecting it) and description.
+
+// containerConfigSchema documents the container configuration keys.
+type containerConfigSchema struct {
+	_ struct{} `schema:"container"`
+
+	BandwidthAlertBytes                  int64  `key:"bandwidth.alert_bytes" live:"yes" description:"Monthly network traffic (received and sent, in bytes) over which an alert event is emitted"`
+	BandwidthQuotaBytes                  int64  `key:"bandwidth.quota_bytes" live:"yes" description:"Monthly network traffic quota (received and sent, in bytes), an event is emitted when it's exceeded"`
+	BandwidthResetOn                     int64  `key:"bandwidth.reset_on" default:"1" live:"yes" description:"Day of the month (UTC) on which the monthly network traffic resets"`
+	BootAutostart                        bool   `key:"boot.autostart" live:"n/a" description:"Always start the container when LXD starts (if not set, restore last state)"`
+	BootAutostartDelay                   int64  `key:"boot.autostart.delay" default:"0" live:"n/a" description:"Number of seconds to wait after the container started before starting the next one"`
+	BootAutostartPriority                int64  `key:"boot.autostart.priority" default:"0" live:"n/a" description:"What order to start the containers in (starting with highest)"`
+	BootDepends                          string `key:"boot.depends" live:"n/a" description:"Comma separated list of containers (in the same project) to wait for before starting"`
+	BootDependsMaxWait                   int64  `key:"boot.depends.max_wait" default:"300" live:"n/a" description:"Maximum number of seconds to wait for the dependencies to be healthy"`
+	BootHealthCheckCommand               string `key:"boot.health_check.command" live:"yes" description:"Command run inside the container to check whether it is healthy (exit code 0)"`
+	BootHealthCheckInterval              int64  `key:"boot.health_check.interval" default:"5" live:"yes" description:"Number of seconds between two health checks"`
+	BootHealthCheckTimeout               int64  `key:"boot.health_check.timeout" default:"10" live:"yes" description:"Number of seconds after which a health check is considered as failed"`
+	BootHostHooksTimeout                 int64  `key:"boot.host_hooks.timeout" default:"30" live:"yes" description:"Seconds to wait for a host hook to complete before it is killed"`
+	BootHostShutdownTimeout              int64  `key:"boot.host_shutdown_timeout" default:"30" live:"yes" description:"Seconds to wait for container to shutdown before it is force stopped"`
+	BootStopPriority                     int64  `key:"boot.stop.priority" default:"0" live:"n/a" description:"What order to shutdown the containers (starting with highest)"`
+	ConsoleClipboardPassthrough          bool   `key:"console.clipboard_passthrough" default:"false" live:"yes" description:"Whether OSC 52 clipboard sequences from the console are sent to the control socket of the console sessions"`
+	CoredumpsRetention                   int64  `key:"coredumps.retention" default:"10" live:"yes" description:"Number of core dumps of the processes of the container kept by LXD (0 to not capture them)"`
+	CoredumpsSizeLimit                   string `key:"coredumps.size_limit" type:"size" default:"1GB" live:"yes" description:"Total size of the compressed core dumps of the container kept by LXD"`
+	Environment                          string `key:"environment.*" live:"yes" description:"key/value environment variables to export to the container and set on exec"`
+	HooksPostSnapshotCommand             string `key:"hooks.post_snapshot_command" live:"yes" description:"Command run inside the container after a snapshot of it is taken"`
+	HooksPreSnapshotCommand              string `key:"hooks.pre_snapshot_command" live:"yes" description:"Command run inside the container before a snapshot of it is taken, the snapshot being aborted if it fails"`
+	HooksSecret                          string `key:"hooks.secret" live:"yes" description:"Secret the events posted to the hooks of the container are signed with (HMAC-SHA256)"`
+	HooksSnapshotTimeout                 int64  `key:"hooks.snapshot_timeout" default:"30" live:"yes" description:"Seconds the snapshot hooks of the container may run for"`
+	HooksSnapshotUrl                     string `key:"hooks.snapshot_url" live:"yes" description:"HTTPS URL the snapshot events of the container are posted to"`
+	HooksStartUrl                        string `key:"hooks.start_url" live:"yes" description:"HTTPS URL the start events of the container are posted to"`
+	HooksStopUrl                         string `key:"hooks.stop_url" live:"yes" description:"HTTPS URL the stop and crash events of the container are posted to"`
+	LimitsCpu                            string `key:"limits.cpu" pattern:"^[0-9]+([-,][0-9]+)*$" live:"yes" description:"Number or range of CPUs to expose to the container"`
+	LimitsCpuAllowance                   string `key:"limits.cpu.allowance" default:"100%" live:"yes" description:"How much of the CPU can be used. Can be a percentage (e.g. 50%) for a soft limit or hard a chunk of time (25ms/100ms)"`
+	LimitsCpuNodes                       string `key:"limits.cpu.nodes" pattern:"^[0-9]+([-,][0-9]+)*$" live:"yes" description:"Comma separated list of NUMA nodes to bind the CPUs and memory of the container to (combined with a CPU count in limits.cpu, the CPUs are picked from the nodes with the most idle CPUs)"`
+	LimitsCpuPeriod                      string `key:"limits.cpu.period" live:"yes" description:"CFS scheduler period (e.g. 100ms) over which the container gets as many periods worth of CPU time as it has CPUs in limits.cpu (hard limit through cpu.max or cpu.cfs_quota_us)"`
+	LimitsCpuPriority                    int64  `key:"limits.cpu.priority" default:"10" live:"yes" description:"CPU scheduling priority compared to other containers sharing the same CPUs (overcommit) (integer between 0 and 10)"`
+	LimitsDiskPriority                   int64  `key:"limits.disk.priority" default:"5" live:"yes" description:"When under load, how much priority to give to the container's I/O requests (integer between 0 and 10)"`
+	LimitsFilesWarning                   string `key:"limits.files_warning" default:"80%" pattern:"^[0-9]+%?$" live:"yes" description:"Number of files open by a process (or percentage of the soft limit of limits.kernel.nofile) over which a fd.limit_approaching event is emitted"`
+	LimitsKernel                         string `key:"limits.kernel.*" live:"no" description:"This limits kernel resources per container (e.g. number of open files)"`
+	LimitsMemory                         string `key:"limits.memory" type:"size" live:"yes" description:"Percentage of the host's memory or fixed value in bytes (various suffixes supported, see below)"`
+	LimitsMemoryBalloonStep              string `key:"limits.memory.balloon.step" type:"size" default:"128MB" live:"yes" description:"Amount by which the memory balloon shrinks or grows the memory limit at each adjustment"`
+	LimitsMemoryEnforce                  string `key:"limits.memory.enforce" default:"hard" values:"soft,hard" live:"yes" description:"If hard, container can't exceed its memory limit. If soft, the container can exceed its memory limit when extra host memory is available."`
+	LimitsMemoryGuarantee                string `key:"limits.memory.guarantee" type:"size" live:"yes" description:"Amount of memory the kernel never reclaims from the container, set as cgroup2 memory.min (percentage of the host's memory or fixed value in bytes)"`
+	LimitsMemoryLow                      string `key:"limits.memory.low" type:"size" live:"yes" description:"Amount of memory the kernel only reclaims from the container when no unprotected memory is left, set as cgroup2 memory.low (percentage of the host's memory or fixed value in bytes)"`
+	LimitsMemoryMax                      string `key:"limits.memory.max" type:"size" live:"yes" description:"Upper bound of the memory limit adjusted by the memory balloon according to the host's memory pressure (percentage of the host's memory or fixed value in bytes)"`
+	LimitsMemoryMin                      string `key:"limits.memory.min" type:"size" live:"yes" description:"Lower bound of the memory limit adjusted by the memory balloon (percentage of the host's memory or fixed value in bytes)"`
+	LimitsMemorySwap                     bool   `key:"limits.memory.swap" default:"true" live:"yes" description:"Whether to allow some of the container's memory to be swapped out to disk"`
+	LimitsMemorySwapPriority             int64  `key:"limits.memory.swap.priority" default:"10" live:"yes" description:"The higher this is set, the least likely the container is to be swapped to disk (integer between 0 and 10)"`
+	LimitsNetworkPriority                int64  `key:"limits.network.priority" default:"0" live:"yes" description:"When under load, how much priority to give to the container's network requests (integer between 0 and 10)"`
+	LimitsProcesses                      int64  `key:"limits.processes" live:"yes" description:"Maximum number of processes that can run in the container"`
+	LimitsUlimits                        string `key:"limits.ulimits" live:"yes" description:"YAML list of process resource limits (like [{type: nofile, soft: 1024, hard: 2048}]) of the container, applied to the processes executed in it right away and to its init process on restart"`
+	LinuxKernelModules                   string `key:"linux.kernel_modules" live:"yes" description:"Comma separated list of kernel modules to load before starting the container"`
+	LinuxThpMode                         string `key:"linux.thp_mode" values:"always,madvise,never" live:"no" description:"Transparent huge pages mode of the container (only never can be enforced per container, the other modes depend on the host)"`
+	MigrationIncrementalMemory           bool   `key:"migration.incremental.memory" default:"false" live:"yes" description:"Incremental memory transfer of the container's memory to reduce downtime."`
+	MigrationIncrementalMemoryGoal       int64  `key:"migration.incremental.memory.goal" default:"70" live:"yes" description:"Percentage of memory to have in sync before stopping the container."`
+	MigrationIncrementalMemoryIterations int64  `key:"migration.incremental.memory.iterations" default:"10" live:"yes" description:"Maximum number of transfer operations to go through before stopping the container."`
+	NvidiaDriverCapabilities             string `key:"nvidia.driver.capabilities" default:"compute,utility" live:"no" description:"What driver capabilities the container needs (sets libnvidia-container NVIDIA_DRIVER_CAPABILITIES)"`
+	NvidiaRuntime                        bool   `key:"nvidia.runtime" default:"false" live:"no" description:"Pass the host NVIDIA and CUDA runtime libraries into the container"`
+	NvidiaRequireCuda                    string `key:"nvidia.require.cuda" live:"no" description:"Version expression for the required CUDA version (sets libnvidia-container NVIDIA_REQUIRE_CUDA)"`
+	NvidiaRequireDriver                  string `key:"nvidia.require.driver" live:"no" description:"Version expression for the required driver version (sets libnvidia-container NVIDIA_REQUIRE_DRIVER)"`
+	OomScoreAdj                          int64  `key:"oom_score_adj" live:"yes" description:"OOM killer score adjustment of the container's processes (between -1000 and 1000, defaults to the server's containers.default_oom_score_adj)"`
+	PriorityCpu                          string `key:"priority.cpu" live:"yes" description:"CPU scheduling class (low, medium, high or critical) or cpu.weight (integer between 1 and 10000), overrides limits.cpu.priority"`
+	PriorityIo                           string `key:"priority.io" live:"yes" description:"I/O scheduling class (low, medium, high or critical) or io.weight (integer between 1 and 10000), overrides limits.disk.priority"`
+	RawApparmor                          string `key:"raw.apparmor" type:"blob" live:"yes" description:"Apparmor profile entries to be appended to the generated profile"`
+	RawIdmap                             string `key:"raw.idmap" type:"blob" live:"no" description:"Raw idmap configuration (e.g. 'both 1000 1000')"`
+	RawLxc                               string `key:"raw.lxc" type:"blob" live:"no" description:"Raw LXC configuration to be appended to the generated one"`
+	RawSeccomp                           string `key:"raw.seccomp" type:"blob" live:"no" description:"Raw Seccomp configuration"`
+	RestartInterval                      string `key:"restart.interval" default:"5s" live:"n/a" description:"Base delay before restarting a container that stopped on its own, doubled after every retry"`
+	RestartMaxRetries                    int64  `key:"restart.max_retries" default:"5" live:"n/a" description:"How many times to restart the container before giving up"`
+	RestartPolicy                        string `key:"restart.policy" default:"never" values:"on-failure,always,never" live:"n/a" description:"When to restart the container if it stops on its own (on-failure, always or never)"`
+	SecurityConsoleAuth                  string `key:"security.console_auth" values:"password" live:"yes" description:"Authentication required before granting access to the console (currently only 'password')"`
+	SecurityConsoleAuthPassword          string `key:"security.console_auth.password" live:"yes" description:"Password of the console when security.console_auth is set to 'password', stored hashed"`
+	SecurityCapabilitiesAdd              string `key:"security.capabilities.add" pattern:"^[A-Za-z_, ]*$" live:"no" description:"Comma-separated list of capabilities kept in the bounding set of privileged containers"`
+	SecurityCapabilitiesDrop             string `key:"security.capabilities.drop" pattern:"^[A-Za-z_, ]*$" live:"no" description:"Comma-separated list of capabilities dropped from the bounding set"`
+	SecurityDevlxd                       bool   `key:"security.devlxd" default:"true" live:"yes" description:"Controls the presence of /dev/lxd in the container"`
+	SecurityDevlxdImages                 bool   `key:"security.devlxd.images" default:"false" live:"yes" description:"Controls the availability of the /1.0/images API over devlxd"`
+	SecurityHideFirmware                 bool   `key:"security.hide_firmware" default:"false" live:"no" description:"Hides /sys/firmware (including the UEFI variables) from the container under an empty tmpfs"`
+	SecurityHideModuleParams             bool   `key:"security.hide_module_params" default:"false" live:"no" description:"Hides the parameters of the kernel modules (/sys/module/*/parameters) from the container under empty tmpfs"`
+	SecurityIdmapBase                    int64  `key:"security.idmap.base" live:"no" description:"The base host ID to use for the allocation (overrides auto-detection)"`
+	SecurityIdmapIsolated                bool   `key:"security.idmap.isolated" default:"false" live:"no" description:"Use an idmap for this container that is unique among containers with isolated set."`
+	SecurityIdmapSize                    int64  `key:"security.idmap.size" live:"no" description:"The size of the idmap to use"`
+	SecurityIpc                          string `key:"security.ipc" default:"isolated" live:"no" description:"IPC namespace of the container (isolated, shared with another container or host, the latter requiring a privileged container)"`
+	SecurityIpcSharedWith                string `key:"security.ipc.shared_with" live:"no" description:"Name of the running container whose IPC namespace is shared when security.ipc is shared"`
+	SecurityNesting                      bool   `key:"security.nesting" default:"false" live:"yes" description:"Support running lxd (nested) inside the container"`
+	SecurityPrivileged                   bool   `key:"security.privileged" default:"false" live:"no" description:"Runs the container in privileged mode"`
+	SecurityProcFilter                   bool   `key:"security.proc_filter" default:"false" live:"no" description:"Hides the /proc entries of the processes outside of the container (open and openat syscalls forwarded to LXD)"`
+	SecurityProtectionDelete             bool   `key:"security.protection.delete" default:"false" live:"yes" description:"Prevents the container from being deleted"`
+	SecurityProtectionShift              bool   `key:"security.protection.shift" default:"false" live:"yes" description:"Prevents the container's filesystem from being uid/gid shifted on startup"`
+	SecuritySeccompLogOnly               bool   `key:"security.seccomp.log_only" default:"false" live:"no" description:"Log the syscalls of the container which aren't denied instead of allowing them, to generate a syscall whitelist"`
+	SecuritySeccompPathRules             string `key:"security.seccomp.path_rules" live:"yes" description:"Comma separated list of <source>=<target> directories, mkdir and symlink calls of the container under source being redirected to target (requires Linux 5.5 or higher)"`
+	SecuritySecretsWhitelist             string `key:"security.secrets.whitelist" live:"yes" description:"Comma separated list of glob patterns of the secrets which can be injected in exec sessions of the container"`
+	SecuritySyscallsBlacklist            string `key:"security.syscalls.blacklist" live:"no" description:"A '\\n' separated list of syscalls to blacklist"`
+	SecuritySyscallsBlacklistCompat      bool   `key:"security.syscalls.blacklist_compat" default:"false" live:"no" description:"On x86_64 this enables blocking of compat_* syscalls, it is a no-op on other arches"`
+	SecuritySyscallsBlacklistDefault     bool   `key:"security.syscalls.blacklist_default" default:"true" live:"no" description:"Enables the default syscall blacklist"`
+	SecuritySyscallsWhitelist            string `key:"security.syscalls.whitelist" live:"no" description:"A '\\n' separated list of syscalls to whitelist (mutually exclusive with security.syscalls.blacklist*)"`
+	SecurityTimeNamespace                bool   `key:"security.time_namespace" default:"false" live:"no" description:"Run the container in its own time namespace (requires Linux 5.6 or higher)"`
+	SecurityTimeNamespaceOffsetSeconds   int64  `key:"security.time_namespace.offset_seconds" default:"0" live:"no" description:"Offset in seconds applied to the monotonic and boot clocks of the container's time namespace"`
+	SnapshotsSchedule                    string `key:"snapshots.schedule" live:"n/a" description:"Cron expression ('<minute> <hour> <dom> <month> <dow>')"`
+	SnapshotsScheduleStopped             bool   `key:"snapshots.schedule.stopped" default:"false" live:"n/a" description:"Controls whether or not stopped containers are to be snapshoted automatically"`
+	SnapshotsPattern                     string `key:"snapshots.pattern" default:"snap%d" live:"n/a" description:"Pongo2 template string which represents the snapshot name (used for scheduled snapshots and unnamed snapshots)"`
+	SnapshotsExpiry                      string `key:"snapshots.expiry" live:"n/a" description:"Controls when snapshots are to be deleted (expects expression like '1M 2H 3d 4w 5m 6y')"`
+	SnapshotsMaxCount                    int64  `key:"snapshots.max_count" default:"0" live:"n/a" description:"Maximum number of snapshots to keep, the oldest ones being deleted first (0 for no limit)"`
+	User                                 string `key:"user.*" live:"yes" description:"Free form user key/value storage (can be used in search)"`
+	Image                                string `key:"image.*" live:"yes" description:"Copy of the image properties at time of creation"`
+	Volatile                             string `key:"volatile.*" live:"n/a" description:"Used internally by LXD to store settings that are specific to a specific container instance"`
+}
+
+// deviceNicConfigSchema documents the nic device configuration keys.
+type deviceNicConfigSchema struct {
+	_ struct{} `schema:"device" device:"nic"`
+
+	Nictype               string `key:"nictype" required:"yes" live:"no" description:"The device type, one of 'bridged', 'macvlan', 'p2p', 'physical', or 'sriov'"`
+	LimitsIngress         string `key:"limits.ingress" type:"size" live:"yes" description:"I/O limit in bit/s for incoming traffic (various suffixes supported, see below)"`
+	LimitsEgress          string `key:"limits.egress" type:"size" live:"yes" description:"I/O limit in bit/s for outgoing traffic (various suffixes supported, see below)"`
+	LimitsMax             string `key:"limits.max" live:"yes" description:"Same as modifying both limits.ingress and limits.egress"`
+	Name                  string `key:"name" default:"kernel assigned" live:"no" description:"The name of the interface inside the container"`
+	HostName              string `key:"host_name" default:"randomly assigned" live:"no" description:"The name of the interface inside the host"`
+	Hwaddr                string `key:"hwaddr" default:"randomly assigned" live:"no" description:"The MAC address of the new interface"`
+	Mtu                   int64  `key:"mtu" default:"parent MTU" live:"no" description:"The MTU of the new interface"`
+	Parent                string `key:"parent" required:"yes" live:"no" description:"The name of the host device or bridge"`
+	Vlan                  int64  `key:"vlan" live:"no" description:"The VLAN ID to attach to"`
+	Ipv4Address           string `key:"ipv4.address" live:"no" description:"An IPv4 address to assign to the container through DHCP"`
+	Ipv6Address           string `key:"ipv6.address" live:"no" description:"An IPv6 address to assign to the container through DHCP"`
+	SecurityMacFiltering  bool   `key:"security.mac_filtering" default:"false" live:"no" description:"Prevent the container from spoofing another's MAC address"`
+	MaasSubnetIpv4        string `key:"maas.subnet.ipv4" live:"no" description:"MAAS IPv4 subnet to register the container in"`
+	MaasSubnetIpv6        string `key:"maas.subnet.ipv6" live:"no" description:"MAAS IPv6 subnet to register the container in"`
+	MdnsAnnounce          bool   `key:"mdns.announce" default:"false" live:"no" description:"Announce the container as '<name>.local' over mDNS (bridged only when the bridge is a fan network)"`
+	Queues                int64  `key:"queues" default:"1" live:"no" description:"Number of receive and transmit queues of the interface, 0 for one per CPU of the container (bridged and p2p only)"`
+	KernelModules         string `key:"kernel_modules" live:"no" description:"Space separated list of kernel modules to load before setting up the device"`
+	KernelModulesOptional bool   `key:"kernel_modules_optional" default:"false" live:"no" description:"Only log a warning if one of the kernel_modules fails to load"`
+}
+
+// deviceInfinibandConfigSchema documents the infiniband device configuration keys.
+type deviceInfinibandConfigSchema struct {
+	_ struct{} `schema:"device" device:"infiniband"`
+
+	Nictype string `key:"nictype" required:"yes" live:"no" description:"The device type, one of 'physical', or 'sriov'"`
+	Name    string `key:"name" default:"kernel assigned" live:"no" description:"The name of the interface inside the container"`
+	Hwaddr  string `key:"hwaddr" default:"randomly assigned" live:"no" description:"The MAC address of the new interface"`
+	Mtu     int64  `key:"mtu" default:"parent MTU" live:"no" description:"The MTU of the new interface"`
+	Parent  string `key:"parent" required:"yes" live:"no" description:"The name of the host device or bridge"`
+}
+
+// deviceDiskConfigSchema documents the disk device configuration keys.
+type deviceDiskConfigSchema struct {
+	_ struct{} `schema:"device" device:"disk"`
+
+	LimitsRead  string `key:"limits.read" type:"size" live:"yes" description:"I/O limit in byte/s (various suffixes supported, see below) or in iops (must be suffixed with 'iops')"`
+	LimitsWrite string `key:"limits.write" type:"size" live:"yes" description:"I/O limit in byte/s (various suffixes supported, see below) or in iops (must be suffixed with 'iops')"`
+	LimitsMax   string `key:"limits.max" live:"yes" description:"Same as modifying both limits.read and limits.write"`
+	Path        string `key:"path" required:"yes" live:"no" description:"Path inside the container where the disk will be mounted"`
+	Source      string `key:"source" required:"yes" live:"no" description:"Path on the host, either to a file/directory or to a block device"`
+	Optional    bool   `key:"optional" default:"false" live:"no" description:"Controls whether to fail if the source doesn't exist"`
+	Readonly    bool   `key:"readonly" default:"false" live:"no" description:"Controls whether to make the mount read-only"`
+	Size        string `key:"size" type:"size" live:"no" description:"Disk size in bytes (various suffixes supported, see below). This is only supported for the rootfs (/)."`
+	Recursive   bool   `key:"recursive" default:"false" live:"no" description:"Whether or not to recursively mount the source path"`
+	Pool        string `key:"pool" live:"no" description:"The storage pool the disk device belongs to. This is only applicable for storage volumes managed by LXD."`
+	Propagation string `key:"propagation" live:"no" description:"Controls how a bind-mount is shared between the container and the host. (Can be one of 'private', the default, or 'shared', 'slave', 'unbindable',  'rshared', 'rslave', 'runbindable',  'rprivate'. Please see the Linux Kernel [shared subtree](https://www.kernel.org/doc/Documentation/filesystems/sharedsubtree.txt) documentation for a full explanation)"`
+}
+
+// deviceUnixCharConfigSchema documents the unix-char device configuration keys.
+type deviceUnixCharConfigSchema struct {
+	_ struct{} `schema:"device" device:"unix-char"`
+
+	Source   string `key:"source" live:"no" description:"Path on the host"`
+	Path     string `key:"path" live:"no" description:"Path inside the container(one of 'source' and 'path' must be set)"`
+	Major    int64  `key:"major" default:"device on host" live:"no" description:"Device major number"`
+	Minor    int64  `key:"minor" default:"device on host" live:"no" description:"Device minor number"`
+	Uid      int64  `key:"uid" default:"0" live:"no" description:"UID of the device owner in the container"`
+	Gid      int64  `key:"gid" default:"0" live:"no" description:"GID of the device owner in the container"`
+	Mode     string `key:"mode" pattern:"^0?[0-7]{3}$" default:"0660" live:"no" description:"Mode of the device in the container"`
+	Required bool   `key:"required" default:"true" live:"no" description:"Whether or not this device is required to start the container."`
+}
+
+// deviceUnixBlockConfigSchema documents the unix-block device configuration keys.
+type deviceUnixBlockConfigSchema struct {
+	_ struct{} `schema:"device" device:"unix-block"`
+
+	Source   string `key:"source" live:"no" description:"Path on the host"`
+	Path     string `key:"path" live:"no" description:"Path inside the container(one of 'source' and 'path' must be set)"`
+	Major    int64  `key:"major" default:"device on host" live:"no" description:"Device major number"`
+	Minor    int64  `key:"minor" default:"device on host" live:"no" description:"Device minor number"`
+	Uid      int64  `key:"uid" default:"0" live:"no" description:"UID of the device owner in the container"`
+	Gid      int64  `key:"gid" default:"0" live:"no" description:"GID of the device owner in the container"`
+	Mode     string `key:"mode" pattern:"^0?[0-7]{3}$" default:"0660" live:"no" description:"Mode of the device in the container"`
+	Required bool   `key:"required" default:"true" live:"no" description:"Whether or not this device is required to start the container."`
+}
+
+// deviceUsbConfigSchema documents the usb device configuration keys.
+type deviceUsbConfigSchema struct {
+	_ struct{} `schema:"device" device:"usb"`
+
+	Vendorid              string `key:"vendorid" live:"no" description:"The vendor id of the USB device."`
+	Productid             string `key:"productid" live:"no" description:"The product id of the USB device."`
+	Uid                   int64  `key:"uid" default:"0" live:"no" description:"UID of the device owner in the container"`
+	Gid                   int64  `key:"gid" default:"0" live:"no" description:"GID of the device owner in the container"`
+	Mode                  string `key:"mode" pattern:"^0?[0-7]{3}$" default:"0660" live:"no" description:"Mode of the device in the container"`
+	Required              bool   `key:"required" default:"false" live:"no" description:"Whether or not this device is required to start the container. (The default is no, and all devices are hot-pluggable.)"`
+	KernelModules         string `key:"kernel_modules" live:"no" description:"Space separated list of kernel modules to load before setting up the device"`
+	KernelModulesOptional bool   `key:"kernel_modules_optional" default:"false" live:"no" description:"Only log a warning if one of the kernel_modules fails to load"`
+}
+
+// deviceGpuConfigSchema documents the gpu device configuration keys.
+type deviceGpuConfigSchema struct {
+	_ struct{} `schema:"device" device:"gpu"`
+
+	Vendorid                 string `key:"vendorid" live:"no" description:"The vendor id of the GPU device."`
+	Productid                string `key:"productid" live:"no" description:"The product id of the GPU device."`
+	Id                       string `key:"id" live:"no" description:"The card id of the GPU device."`
+	Pci                      string `key:"pci" live:"no" description:"The pci address of the GPU device."`
+	Uid                      int64  `key:"uid" default:"0" live:"no" description:"UID of the device owner in the container"`
+	Gid                      int64  `key:"gid" default:"0" live:"no" description:"GID of the device owner in the container"`
+	Mode                     string `key:"mode" pattern:"^0?[0-7]{3}$" default:"0660" live:"no" description:"Mode of the device in the container"`
+	KernelModules            string `key:"kernel_modules" live:"no" description:"Space separated list of kernel modules to load before setting up the device"`
+	KernelModulesOptional    bool   `key:"kernel_modules_optional" default:"false" live:"no" description:"Only log a warning if one of the kernel_modules fails to load"`
+	GpuMpsEnabled            bool   `key:"gpu.mps.enabled" default:"false" live:"no" description:"Share the NVIDIA GPU with other containers through the NVIDIA Multi-Process Service (MPS)"`
+	GpuMpsLimitActiveThreads int64  `key:"gpu.mps.limit_active_threads" live:"no" description:"Percentage of the GPU threads available to the CUDA clients of this container (requires gpu.mps.enabled)"`
+}
+
+// devicePciConfigSchema documents the pci device configuration keys.
+type devicePciConfigSchema struct {
+	_ struct{} `schema:"device" device:"pci"`
+
+	Address string `key:"address" required:"yes" pattern:"^[0-9a-f]{4}:[0-9a-f]{2}:[0-9a-f]{2}\\.[0-7]$" live:"no" description:"PCI address of the device on the host (e.g. 0000:03:00.0)"`
+	Vfio    bool   `key:"vfio" default:"true" live:"no" description:"Pass the device through with VFIO, binding its IOMMU group to vfio-pci while the container runs"`
+}
+
+// deviceIsoConfigSchema documents the iso device configuration keys.
+type deviceIsoConfigSchema struct {
+	_ struct{} `schema:"device" device:"iso"`
+
+	Source string `key:"source" required:"yes" live:"no" description:"Path on the host of the live ISO image to boot the container from"`
+}
+
+// deviceProxyConfigSchema documents the proxy device configuration keys.
+type deviceProxyConfigSchema struct {
+	_ struct{} `schema:"device" device:"proxy"`
+
+	Listen        string `key:"listen" required:"yes" live:"yes" description:"The address and port to bind and listen"`
+	Connect       string `key:"connect" required:"yes" live:"yes" description:"The address and port to connect to"`
+	Bind          string `key:"bind" default:"host" live:"yes" description:"Which side to bind on (host/container)"`
+	Uid           int64  `key:"uid" default:"0" live:"yes" description:"UID of the owner of the listening Unix socket"`
+	Gid           int64  `key:"gid" default:"0" live:"yes" description:"GID of the owner of the listening Unix socket"`
+	Mode          string `key:"mode" pattern:"^0?[0-7]{3}$" default:"0755" live:"yes" description:"Mode for the listening Unix socket"`
+	Nat           bool   `key:"nat" default:"false" live:"yes" description:"Whether to optimize proxying via NAT"`
+	ProxyProtocol bool   `key:"proxy_protocol" default:"false" live:"yes" description:"Whether to use the HAProxy PROXY protocol to transmit sender information"`
+	SecurityUid   int64  `key:"security.uid" default:"0" live:"yes" description:"What UID to drop privilege to"`
+	SecurityGid   int64  `key:"security.gid" default:"0" live:"yes" description:"What GID to drop privilege to"`
+}
+
+// deviceNoneConfigSchema documents the none device type, which has no keys.
+type deviceNoneConfigSchema struct {
+	_ struct{} `schema:"device" device:"none"`
+}
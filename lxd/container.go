@@ -3,6 +3,7 @@ package main
 import (
 	"fmt"
 	"io"
+	"net"
 	"os"
 	"os/exec"
 	"path/filepath"
@@ -152,6 +153,14 @@ func containerValidDeviceConfigKey(t, k string) bool {
 			return true
 		case "maas.subnet.ipv6":
 			return true
+		case "mdns.announce":
+			return true
+		case "queues":
+			return true
+		case "kernel_modules":
+			return true
+		case "kernel_modules_optional":
+			return true
 		default:
 			return false
 		}
@@ -196,6 +205,10 @@ func containerValidDeviceConfigKey(t, k string) bool {
 			return true
 		case "required":
 			return true
+		case "kernel_modules":
+			return true
+		case "kernel_modules_optional":
+			return true
 		default:
 			return false
 		}
@@ -215,11 +228,30 @@ func containerValidDeviceConfigKey(t, k string) bool {
 			return true
 		case "uid":
 			return true
+		case "kernel_modules":
+			return true
+		case "kernel_modules_optional":
+			return true
+		case "gpu.mps.enabled":
+			return true
+		case "gpu.mps.limit_active_threads":
+			return true
+		default:
+			return false
+		}
+	case "pci":
+		switch k {
+		case "address":
+			return true
+		case "vfio":
+			return true
 		default:
 			return false
 		}
 	case "infiniband":
 		switch k {
+		case "guid":
+			return true
 		case "hwaddr":
 			return true
 		case "mtu":
@@ -230,6 +262,8 @@ func containerValidDeviceConfigKey(t, k string) bool {
 			return true
 		case "parent":
 			return true
+		case "pkey":
+			return true
 		default:
 			return false
 		}
@@ -258,6 +292,43 @@ func containerValidDeviceConfigKey(t, k string) bool {
 		default:
 			return false
 		}
+	case "iso":
+		switch k {
+		case "source":
+			return true
+		default:
+			return false
+		}
+	case "tun":
+		switch k {
+		case "gid":
+			return true
+		case "mode":
+			return true
+		case "name":
+			return true
+		case "socket":
+			return true
+		case "uid":
+			return true
+		default:
+			return false
+		}
+	case "vlan":
+		switch k {
+		case "ipv4.address":
+			return true
+		case "ipv6.address":
+			return true
+		case "name":
+			return true
+		case "parent":
+			return true
+		case "vid":
+			return true
+		default:
+			return false
+		}
 	case "none":
 		return false
 	default:
@@ -281,6 +352,10 @@ func allowedUnprivilegedOnlyMap(rawIdmap string) error {
 }
 
 func containerValidConfig(sysOS *sys.OS, config map[string]string, profile bool, expanded bool) error {
+	return withErrorCode(api.ErrInvalidConfig, doContainerValidConfig(sysOS, config, profile, expanded))
+}
+
+func doContainerValidConfig(sysOS *sys.OS, config map[string]string, profile bool, expanded bool) error {
 	if config == nil {
 		return nil
 	}
@@ -294,6 +369,10 @@ func containerValidConfig(sysOS *sys.OS, config map[string]string, profile bool,
 			return fmt.Errorf("Image keys can only be set on containers")
 		}
 
+		if profile && k == "security.console_auth.password" {
+			return fmt.Errorf("security.console_auth.password can only be set on containers")
+		}
+
 		err := containerValidConfigKey(sysOS, k, v)
 		if err != nil {
 			return err
@@ -314,6 +393,65 @@ func containerValidConfig(sysOS *sys.OS, config map[string]string, profile bool,
 		return fmt.Errorf("security.syscalls.whitelist is mutually exclusive with security.syscalls.blacklist*")
 	}
 
+	if shared.IsTrue(config["security.seccomp.log_only"]) && (rawSeccomp || whitelist) {
+		return fmt.Errorf("security.seccomp.log_only is mutually exclusive with raw.seccomp and security.syscalls.whitelist")
+	}
+
+	capsAdd, _ := shared.ParseCapabilities(config["security.capabilities.add"])
+	capsDrop, _ := shared.ParseCapabilities(config["security.capabilities.drop"])
+	for _, name := range capsAdd {
+		if shared.StringInSlice(name, capsDrop) {
+			return fmt.Errorf("Capability %s can't be both added and dropped", strings.ToUpper("cap_"+name))
+		}
+	}
+
+	// Unprivileged containers already get all capabilities within their
+	// user namespace
+	if expanded && len(capsAdd) > 0 && !shared.IsTrue(config["security.privileged"]) {
+		return fmt.Errorf("security.capabilities.add can only be set on privileged containers")
+	}
+
+	if expanded && config["limits.ulimits"] != "" {
+		err := ulimitsValidate(config)
+		if err != nil {
+			return err
+		}
+	}
+
+	if config["limits.memory.max"] != "" && config["limits.memory"] != "" {
+		return fmt.Errorf("limits.memory can't be set along with limits.memory.max")
+	}
+
+	if expanded && config["limits.memory.min"] != "" && config["limits.memory.max"] != "" {
+		min, max, _, err := containerBalloonLimits(config)
+		if err != nil {
+			return err
+		}
+
+		if min > max {
+			return fmt.Errorf("limits.memory.min can't be higher than limits.memory.max")
+		}
+	}
+
+	if config["limits.cpu.period"] != "" && config["limits.cpu.allowance"] != "" && !strings.HasSuffix(config["limits.cpu.allowance"], "%") {
+		return fmt.Errorf("limits.cpu.period can't be set along with a time based limits.cpu.allowance")
+	}
+
+	if expanded && config["limits.cpu.nodes"] != "" {
+		err := deviceNumaValidate(config["limits.cpu.nodes"])
+		if err != nil {
+			return err
+		}
+	}
+
+	if expanded && config["security.ipc"] == "host" && !shared.IsTrue(config["security.privileged"]) {
+		return fmt.Errorf("security.ipc can only be set to host on privileged containers")
+	}
+
+	if expanded && config["security.ipc"] == "shared" && config["security.ipc.shared_with"] == "" {
+		return fmt.Errorf("security.ipc.shared_with must be set when security.ipc is shared")
+	}
+
 	if expanded && (config["security.privileged"] == "" || !shared.IsTrue(config["security.privileged"])) && sysOS.IdmapSet == nil {
 		return fmt.Errorf("LXD doesn't have a uid/gid allocation. In this mode, only privileged containers are supported")
 	}
@@ -335,20 +473,39 @@ func containerValidConfig(sysOS *sys.OS, config map[string]string, profile bool,
 	return nil
 }
 
+// Device types implemented by LXD itself
+var deviceBuiltinTypes = []string{"disk", "gpu", "infiniband", "iso", "nic", "none", "pci", "proxy", "tun", "unix-block", "unix-char", "usb", "vlan"}
+
 func containerValidDevices(cluster *db.Cluster, devices types.Devices, profile bool, expanded bool) error {
+	return withErrorCode(api.ErrInvalidConfig, doContainerValidDevices(cluster, devices, profile, expanded))
+}
+
+func doContainerValidDevices(cluster *db.Cluster, devices types.Devices, profile bool, expanded bool) error {
 	// Empty device list
 	if devices == nil {
 		return nil
 	}
 
 	var diskDevicePaths []string
+	isoDevices := 0
+
 	// Check each device individually
 	for name, m := range devices {
 		if m["type"] == "" {
 			return fmt.Errorf("Missing device type for device '%s'", name)
 		}
 
-		if !shared.StringInSlice(m["type"], []string{"disk", "gpu", "infiniband", "nic", "none", "proxy", "unix-block", "unix-char", "usb"}) {
+		p, isPlugin := devicePlugins[m["type"]]
+		if isPlugin {
+			err := p.Validate(m)
+			if err != nil {
+				return fmt.Errorf("Invalid configuration for device '%s': %v", name, err)
+			}
+
+			continue
+		}
+
+		if !shared.StringInSlice(m["type"], deviceBuiltinTypes) {
 			return fmt.Errorf("Invalid device type for device '%s'", name)
 		}
 
@@ -358,6 +515,19 @@ func containerValidDevices(cluster *db.Cluster, devices types.Devices, profile b
 			}
 		}
 
+		for _, module := range strings.Fields(m["kernel_modules"]) {
+			if !deviceKernelModuleRegexp.MatchString(module) {
+				return fmt.Errorf("Invalid kernel module '%s' on device '%s'", module, name)
+			}
+		}
+
+		if m["kernel_modules_optional"] != "" {
+			err := shared.IsBool(m["kernel_modules_optional"])
+			if err != nil {
+				return fmt.Errorf("Invalid value for kernel_modules_optional on device '%s': %v", name, err)
+			}
+		}
+
 		if m["type"] == "nic" {
 			if m["nictype"] == "" {
 				return fmt.Errorf("Missing nic type")
@@ -384,6 +554,28 @@ func containerValidDevices(cluster *db.Cluster, devices types.Devices, profile b
 					return err
 				}
 			}
+
+			if m["mdns.announce"] != "" {
+				err := shared.IsBool(m["mdns.announce"])
+				if err != nil {
+					return fmt.Errorf("Invalid value for mdns.announce on device '%s': %v", name, err)
+				}
+
+				if !shared.StringInSlice(m["nictype"], []string{"bridged", "physical"}) {
+					return fmt.Errorf("mDNS announcements are only supported on bridged and physical nics")
+				}
+			}
+
+			if m["queues"] != "" {
+				_, err := strconv.ParseUint(m["queues"], 10, 32)
+				if err != nil {
+					return fmt.Errorf("Invalid value for queues on device '%s': %v", name, err)
+				}
+
+				if !shared.StringInSlice(m["nictype"], []string{"bridged", "p2p"}) {
+					return fmt.Errorf("Multiple queues are only supported on bridged and p2p nics")
+				}
+			}
 		} else if m["type"] == "infiniband" {
 			if m["nictype"] == "" {
 				return fmt.Errorf("Missing nic type")
@@ -396,6 +588,24 @@ func containerValidDevices(cluster *db.Cluster, devices types.Devices, profile b
 			if m["parent"] == "" {
 				return fmt.Errorf("Missing parent for %s type nic", m["nictype"])
 			}
+
+			if (m["guid"] != "" || m["pkey"] != "") && m["nictype"] != "sriov" {
+				return fmt.Errorf("guid and pkey can only be set on sriov infiniband devices")
+			}
+
+			if m["guid"] != "" {
+				_, err := net.ParseMAC(m["guid"])
+				if err != nil || len(strings.Split(m["guid"], ":")) != 8 {
+					return fmt.Errorf("Invalid infiniband GUID: %s", m["guid"])
+				}
+			}
+
+			if m["pkey"] != "" {
+				_, err := strconv.ParseUint(m["pkey"], 0, 16)
+				if err != nil {
+					return fmt.Errorf("Invalid infiniband partition key: %s", m["pkey"])
+				}
+			}
 		} else if m["type"] == "disk" {
 			if !expanded && !shared.StringInSlice(m["path"], diskDevicePaths) {
 				diskDevicePaths = append(diskDevicePaths, m["path"])
@@ -485,6 +695,11 @@ func containerValidDevices(cluster *db.Cluster, devices types.Devices, profile b
 			}
 		} else if m["type"] == "usb" {
 			// Nothing needed for usb.
+		} else if m["type"] == "pci" {
+			err := pciValidDevice(m, expanded)
+			if err != nil {
+				return err
+			}
 		} else if m["type"] == "gpu" {
 			if m["pci"] != "" && !shared.PathExists(fmt.Sprintf("/sys/bus/pci/devices/%s", m["pci"])) {
 				return fmt.Errorf("Invalid PCI address (no device found): %s", m["pci"])
@@ -497,6 +712,24 @@ func containerValidDevices(cluster *db.Cluster, devices types.Devices, profile b
 			if m["id"] != "" && (m["pci"] != "" || m["productid"] != "" || m["vendorid"] != "") {
 				return fmt.Errorf("Cannot use pci, productid or vendorid when id is set")
 			}
+
+			if m["gpu.mps.enabled"] != "" {
+				err := shared.IsBool(m["gpu.mps.enabled"])
+				if err != nil {
+					return fmt.Errorf("Invalid value for gpu.mps.enabled: %v", err)
+				}
+			}
+
+			if m["gpu.mps.limit_active_threads"] != "" {
+				if !shared.IsTrue(m["gpu.mps.enabled"]) {
+					return fmt.Errorf("gpu.mps.limit_active_threads requires gpu.mps.enabled")
+				}
+
+				limit, err := strconv.Atoi(m["gpu.mps.limit_active_threads"])
+				if err != nil || limit < 1 || limit > 100 {
+					return fmt.Errorf("Invalid value for gpu.mps.limit_active_threads, must be a percentage between 1 and 100: %s", m["gpu.mps.limit_active_threads"])
+				}
+			}
 		} else if m["type"] == "proxy" {
 			if m["listen"] == "" {
 				return fmt.Errorf("Proxy device entry is missing the required \"listen\" property")
@@ -543,6 +776,47 @@ func containerValidDevices(cluster *db.Cluster, devices types.Devices, profile b
 				}
 			}
 
+		} else if m["type"] == "tun" {
+			if m["name"] == "" {
+				return fmt.Errorf("TUN device entry is missing the required \"name\" property")
+			}
+
+			if m["mode"] != "" && !shared.StringInSlice(m["mode"], []string{"tun", "tap"}) {
+				return fmt.Errorf("Invalid TUN device mode '%s' (must be tun or tap)", m["mode"])
+			}
+
+			for _, key := range []string{"uid", "gid"} {
+				if m[key] == "" {
+					continue
+				}
+
+				_, err := strconv.ParseUint(m[key], 10, 32)
+				if err != nil {
+					return fmt.Errorf("Invalid value for TUN device %s: %s", key, m[key])
+				}
+			}
+
+			if m["socket"] != "" && !filepath.IsAbs(m["socket"]) {
+				return fmt.Errorf("The TUN device socket must be an absolute path")
+			}
+		} else if m["type"] == "vlan" {
+			err := vlanDeviceValidate(m)
+			if err != nil {
+				return err
+			}
+		} else if m["type"] == "iso" {
+			if m["source"] == "" {
+				return fmt.Errorf("ISO device entry is missing the required \"source\" property")
+			}
+
+			if !filepath.IsAbs(m["source"]) {
+				return fmt.Errorf("The ISO device source must be an absolute path")
+			}
+
+			isoDevices++
+			if isoDevices > 1 {
+				return fmt.Errorf("Only one ISO device can be used")
+			}
 		} else if m["type"] == "none" {
 			continue
 		} else {
@@ -596,6 +870,8 @@ type container interface {
 	FilePull(srcpath string, dstpath string) (int64, int64, os.FileMode, string, []string, error)
 	FilePush(type_ string, srcpath string, dstpath string, uid int64, gid int64, mode int, write string) error
 	FileRemove(path string) error
+	FileACLGet(path string) (string, error)
+	FileACLSet(path string, acl string) error
 
 	// Console - Allocate and run a console tty.
 	//
@@ -680,6 +956,7 @@ type container interface {
 	CurrentIdmap() (*idmap.IdmapSet, error)
 	DiskIdmap() (*idmap.IdmapSet, error)
 	NextIdmap() (*idmap.IdmapSet, error)
+	RemapFilesystem(oldIdmap *idmap.IdmapSet, newIdmap *idmap.IdmapSet) error
 }
 
 // Loader functions
@@ -753,6 +1030,11 @@ func containerCreateFromBackup(s *state.State, info backupInfo, data io.ReadSeek
 	}
 	data.Seek(0, 0)
 
+	// Security xattrs can only be restored by the real root user
+	if os.Geteuid() != 0 || s.OS.RunningInUserNS {
+		tarArgs = append([]string{"--xattrs-exclude=security.*"}, tarArgs...)
+	}
+
 	// Unpack tarball
 	err = pool.ContainerBackupLoad(info, data, tarArgs)
 	if err != nil {
@@ -862,7 +1144,7 @@ func containerCreateFromImage(d *Daemon, args db.ContainerArgs, hash string, tra
 	return c, nil
 }
 
-func containerCreateAsCopy(s *state.State, args db.ContainerArgs, sourceContainer container, containerOnly bool, refresh bool) (container, error) {
+func containerCreateAsCopy(s *state.State, args db.ContainerArgs, sourceContainer container, containerOnly bool, refresh bool, copyMode string) (container, error) {
 	var ct container
 	var err error
 
@@ -993,14 +1275,26 @@ func containerCreateAsCopy(s *state.State, args db.ContainerArgs, sourceContaine
 			return nil, err
 		}
 	} else {
-		err = ct.Storage().ContainerCopy(ct, sourceContainer, containerOnly)
+		copyMode, err = containerCopyMode(ct, sourceContainer, copyMode)
 		if err != nil {
-			if !refresh {
-				ct.Delete()
-			}
+			ct.Delete()
+			return nil, err
+		}
 
+		err = ct.Storage().ContainerCopyWithMode(ct, sourceContainer, containerOnly, copyMode)
+		if err != nil {
+			ct.Delete()
 			return nil, err
 		}
+
+		// Keep track of the container the data is shared with
+		if copyMode == "reflink" {
+			err = ct.ConfigKeySet("volatile.base_container", sourceContainer.Name())
+			if err != nil {
+				ct.Delete()
+				return nil, err
+			}
+		}
 	}
 
 	// Apply any post-storage configuration.
@@ -1030,7 +1324,56 @@ func containerCreateAsCopy(s *state.State, args db.ContainerArgs, sourceContaine
 	return ct, nil
 }
 
+// containerCopyMode validates the requested copy mode against the modes
+// supported by the storage driver, picking the most efficient one if unset.
+// Copies across storage pools are always full copies.
+func containerCopyMode(target container, source container, mode string) (string, error) {
+	targetPool, err := target.StoragePool()
+	if err != nil {
+		return "", err
+	}
+
+	sourcePool, err := source.StoragePool()
+	if err != nil {
+		return "", err
+	}
+
+	if sourcePool != targetPool {
+		if mode != "" && mode != "copy" {
+			return "", fmt.Errorf("The \"%s\" copy mode requires the source and target containers to share a storage pool", mode)
+		}
+
+		return "copy", nil
+	}
+
+	modes := target.Storage().ContainerCopyModes()
+	if mode == "" {
+		return modes[0], nil
+	}
+
+	if !shared.StringInSlice(mode, modes) {
+		return "", fmt.Errorf("The \"%s\" copy mode isn't supported by the %s storage pool", mode, target.Storage().GetStorageTypeName())
+	}
+
+	return mode, nil
+}
+
 func containerCreateAsSnapshot(s *state.State, args db.ContainerArgs, sourceContainer container) (container, error) {
+	// Let the applications of the container flush their state, the
+	// snapshot being aborted if they can't
+	_, snapshotName, _ := containerGetParentAndSnapshotName(args.Name)
+	err := containerSnapshotHook(sourceContainer, "hooks.pre_snapshot_command", snapshotName)
+	if err != nil {
+		return nil, errors.Wrap(err, "Pre-snapshot hook")
+	}
+
+	defer func() {
+		err := containerSnapshotHook(sourceContainer, "hooks.post_snapshot_command", snapshotName)
+		if err != nil {
+			logger.Warn("Post-snapshot hook failed", log.Ctx{"container": sourceContainer.Name(), "project": sourceContainer.Project(), "err": err})
+		}
+	}()
+
 	// Deal with state
 	if args.Stateful {
 		if !sourceContainer.IsRunning() {
@@ -1113,6 +1456,15 @@ func containerCreateAsSnapshot(s *state.State, args db.ContainerArgs, sourceCont
 		map[string]interface{}{
 			"snapshot_name": args.Name,
 		})
+	containerHookSend(sourceContainer, "snapshot", map[string]interface{}{
+		"snapshot_name": args.Name,
+	})
+
+	// Enforce the retention policy now that there's one more snapshot
+	err = containerSnapshotsRetention(sourceContainer)
+	if err != nil {
+		logger.Warn("Failed to enforce the snapshot retention policy", log.Ctx{"container": sourceContainer.Name(), "project": sourceContainer.Project(), "err": err})
+	}
 
 	return c, nil
 }
@@ -1152,10 +1504,19 @@ func containerCreateInternal(s *state.State, args db.ContainerArgs) (container,
 
 		// Unset expiry date since containers don't expire
 		args.ExpiryDate = time.Time{}
+
+		// Containers can't be exempt from the snapshot retention policy
+		args.Config = snapshotConfigWithoutKeep(args.Config)
+	}
+
+	// Hash the console password
+	err := consolePasswordHash(args.Config)
+	if err != nil {
+		return nil, err
 	}
 
 	// Validate container config
-	err := containerValidConfig(s.OS, args.Config, false, false)
+	err = containerValidConfig(s.OS, args.Config, false, false)
 	if err != nil {
 		return nil, err
 	}
@@ -1280,6 +1641,14 @@ func containerCreateInternal(s *state.State, args db.ContainerArgs) (container,
 		return nil, errors.Wrap(err, "Create LXC container")
 	}
 
+	// Keep the image the container is based on from being garbage collected
+	if args.Config["volatile.base_image"] != "" {
+		err = s.Cluster.ImageRefCountUpdate(args.Project, args.Config["volatile.base_image"], 1)
+		if err != nil {
+			logger.Warn("Failed to update image reference count", log.Ctx{"image": args.Config["volatile.base_image"], "err": err})
+		}
+	}
+
 	return c, nil
 }
 
@@ -1353,7 +1722,7 @@ func containerLoadByProjectAndName(s *state.State, project, name string) (contai
 		return nil
 	})
 	if err != nil {
-		return nil, err
+		return nil, withNotFoundCode(api.ErrContainerNotFound, err)
 	}
 
 	args := db.ContainerToArgs(container)
@@ -1703,33 +2072,25 @@ func pruneExpiredContainerSnapshotsTask(d *Daemon) (task.Func, task.Schedule) {
 			return
 		}
 
-		// Figure out which need snapshotting (if any)
-		expiredSnapshots := []container{}
+		// Figure out which snapshots break their retention policy (if any)
+		now := time.Now()
+		prunes := []snapshotPrune{}
 		for _, c := range allContainers {
-			snapshots, err := c.Snapshots()
+			toPrune, err := containerSnapshotsToPrune(c, now)
 			if err != nil {
 				logger.Error("Failed to list snapshots", log.Ctx{"err": err, "container": c.Name(), "project": c.Project()})
 				continue
 			}
 
-			for _, snapshot := range snapshots {
-				if snapshot.ExpiryDate().IsZero() {
-					// Snapshot doesn't expire
-					continue
-				}
-
-				if time.Now().Unix()-snapshot.ExpiryDate().Unix() >= 0 {
-					expiredSnapshots = append(expiredSnapshots, snapshot)
-				}
-			}
+			prunes = append(prunes, toPrune...)
 		}
 
-		if len(expiredSnapshots) == 0 {
+		if len(prunes) == 0 {
 			return
 		}
 
 		opRun := func(op *operation) error {
-			return pruneExpiredContainerSnapshots(ctx, d, expiredSnapshots)
+			return pruneExpiredContainerSnapshots(ctx, d, prunes)
 		}
 
 		op, err := operationCreate(d.cluster, "", operationClassTask, db.OperationSnapshotsExpire, nil, nil, opRun, nil, nil)
@@ -1750,7 +2111,18 @@ func pruneExpiredContainerSnapshotsTask(d *Daemon) (task.Func, task.Schedule) {
 
 	first := true
 	schedule := func() (time.Duration, error) {
-		interval := time.Minute
+		var interval time.Duration
+		err := d.cluster.Transaction(func(tx *db.ClusterTx) error {
+			config, err := cluster.ConfigLoad(tx)
+			if err != nil {
+				return errors.Wrap(err, "failed to load cluster configuration")
+			}
+			interval = config.SnapshotsRetentionInterval()
+			return nil
+		})
+		if err != nil {
+			return 0, err
+		}
 
 		if first {
 			first = false
@@ -1763,16 +2135,8 @@ func pruneExpiredContainerSnapshotsTask(d *Daemon) (task.Func, task.Schedule) {
 	return f, schedule
 }
 
-func pruneExpiredContainerSnapshots(ctx context.Context, d *Daemon, snapshots []container) error {
-	// Find snapshots to delete
-	for _, snapshot := range snapshots {
-		err := snapshot.Delete()
-		if err != nil {
-			return errors.Wrapf(err, "Failed to delete expired snapshot '%s' in project '%s'", snapshot.Name(), snapshot.Project())
-		}
-	}
-
-	return nil
+func pruneExpiredContainerSnapshots(ctx context.Context, d *Daemon, prunes []snapshotPrune) error {
+	return containerSnapshotsPrune(prunes)
 }
 
 func containerDetermineNextSnapshotName(d *Daemon, c container, defaultPattern string) (string, error) {
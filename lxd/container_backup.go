@@ -152,6 +152,7 @@ func containerBackupsPost(d *Daemon, r *http.Request) Response {
 			ExpiryDate:       req.ExpiryDate,
 			ContainerOnly:    req.ContainerOnly,
 			OptimizedStorage: req.OptimizedStorage,
+			Xattrs:           req.Xattrs,
 		}
 
 		err := backupCreate(d.State(), args, c)
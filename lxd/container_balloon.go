@@ -0,0 +1,215 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"io/ioutil"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+
+	"github.com/lxc/lxd/lxd/cluster"
+	"github.com/lxc/lxd/lxd/db"
+	"github.com/lxc/lxd/lxd/task"
+	"github.com/lxc/lxd/shared"
+	"github.com/lxc/lxd/shared/logger"
+
+	log "github.com/lxc/lxd/shared/log15"
+)
+
+// Share of the host memory left available under which the memory balloons
+// shrink, and over which they grow back
+const (
+	balloonShrinkThreshold = 10
+	balloonGrowThreshold   = 20
+)
+
+// Default amount by which the memory limit is adjusted at each interval
+const balloonDefaultStep = 128 * 1024 * 1024
+
+// balloonParseLimit parses a memory limit expressed either as a percentage of
+// the host's memory or as a size in bytes.
+func balloonParseLimit(value string, memoryTotal int64) (int64, error) {
+	if strings.HasSuffix(value, "%") {
+		percent, err := strconv.ParseInt(strings.TrimSuffix(value, "%"), 10, 64)
+		if err != nil {
+			return -1, err
+		}
+
+		return (memoryTotal / 100) * percent, nil
+	}
+
+	return shared.ParseByteSizeString(value)
+}
+
+// containerBalloonLimits returns the lower and upper bounds of the memory
+// limit of a container along with the adjustment step. The upper bound is -1
+// if the container doesn't have a memory balloon.
+func containerBalloonLimits(config map[string]string) (int64, int64, int64, error) {
+	if config["limits.memory.max"] == "" {
+		return -1, -1, -1, nil
+	}
+
+	memoryTotal, err := shared.DeviceTotalMemory()
+	if err != nil {
+		return -1, -1, -1, err
+	}
+
+	max, err := balloonParseLimit(config["limits.memory.max"], memoryTotal)
+	if err != nil {
+		return -1, -1, -1, err
+	}
+
+	min := int64(0)
+	if config["limits.memory.min"] != "" {
+		min, err = balloonParseLimit(config["limits.memory.min"], memoryTotal)
+		if err != nil {
+			return -1, -1, -1, err
+		}
+	}
+
+	step := int64(balloonDefaultStep)
+	if config["limits.memory.balloon.step"] != "" {
+		step, err = balloonParseLimit(config["limits.memory.balloon.step"], memoryTotal)
+		if err != nil {
+			return -1, -1, -1, err
+		}
+	}
+
+	return min, max, step, nil
+}
+
+// balloonPressure returns -1 if the host is short on memory, 1 if there's
+// plenty of memory available and 0 otherwise, given the content of
+// /proc/meminfo.
+func balloonPressure(meminfo string) int {
+	values := map[string]int64{}
+	scan := bufio.NewScanner(strings.NewReader(meminfo))
+	for scan.Scan() {
+		fields := strings.Fields(scan.Text())
+		if len(fields) < 2 {
+			continue
+		}
+
+		value, err := strconv.ParseInt(fields[1], 10, 64)
+		if err != nil {
+			continue
+		}
+
+		values[strings.TrimSuffix(fields[0], ":")] = value
+	}
+
+	total := values["MemTotal"]
+	available, ok := values["MemAvailable"]
+	if total <= 0 || !ok {
+		return 0
+	}
+
+	percent := available * 100 / total
+	if percent < balloonShrinkThreshold {
+		return -1
+	}
+
+	if percent > balloonGrowThreshold {
+		return 1
+	}
+
+	return 0
+}
+
+// balloonNextLimit returns the memory limit a container should get next,
+// shrinking or growing the current one by a step within its bounds.
+func balloonNextLimit(current int64, min int64, max int64, step int64, pressure int) int64 {
+	next := current + int64(pressure)*step
+	if next < min {
+		next = min
+	}
+
+	if next > max {
+		next = max
+	}
+
+	return next
+}
+
+// containerBalloonAdjust adjusts the memory limit of the running containers
+// with a memory balloon according to the memory pressure of the host.
+func containerBalloonAdjust(d *Daemon) {
+	if !d.os.CGroupMemoryController {
+		return
+	}
+
+	meminfo, err := ioutil.ReadFile("/proc/meminfo")
+	if err != nil {
+		return
+	}
+
+	pressure := balloonPressure(string(meminfo))
+
+	containers, err := containerLoadNodeAll(d.State())
+	if err != nil {
+		logger.Error("Failed to load containers for memory balloon", log.Ctx{"err": err})
+		return
+	}
+
+	for _, c := range containers {
+		if !c.IsRunning() || c.ExpandedConfig()["limits.memory.enforce"] == "soft" {
+			continue
+		}
+
+		min, max, step, err := containerBalloonLimits(c.ExpandedConfig())
+		if err != nil || max < 0 {
+			continue
+		}
+
+		value, err := c.CGroupGet("memory.limit_in_bytes")
+		if err != nil {
+			continue
+		}
+
+		current, err := strconv.ParseInt(value, 10, 64)
+		if err != nil {
+			continue
+		}
+
+		next := balloonNextLimit(current, min, max, step, pressure)
+		if next == current {
+			continue
+		}
+
+		err = c.CGroupSet("memory.limit_in_bytes", fmt.Sprintf("%d", next))
+		if err != nil {
+			logger.Error("Failed to adjust memory balloon", log.Ctx{"container": c.Name(), "project": c.Project(), "err": err})
+			continue
+		}
+
+		logger.Debug("Adjusted memory balloon", log.Ctx{"container": c.Name(), "project": c.Project(), "limit": next})
+	}
+}
+
+func containerBalloonTask(d *Daemon) (task.Func, task.Schedule) {
+	f := func(ctx context.Context) {
+		containerBalloonAdjust(d)
+	}
+
+	schedule := func() (time.Duration, error) {
+		var interval time.Duration
+		err := d.cluster.Transaction(func(tx *db.ClusterTx) error {
+			config, err := cluster.ConfigLoad(tx)
+			if err != nil {
+				return errors.Wrap(err, "failed to load cluster configuration")
+			}
+			interval = config.MemoryBalloonInterval()
+			return nil
+		})
+		if err != nil {
+			return 0, err
+		}
+		return interval, nil
+	}
+
+	return f, schedule
+}
@@ -0,0 +1,33 @@
+package main
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestBalloonPressure(t *testing.T) {
+	meminfo := `MemTotal:        1000000 kB
+MemFree:           20000 kB
+MemAvailable:      %d kB
+Buffers:           10000 kB
+`
+
+	cases := map[int64]int{50000: -1, 150000: 0, 500000: 1}
+	for available, pressure := range cases {
+		assert.Equal(t, pressure, balloonPressure(fmt.Sprintf(meminfo, available)), available)
+	}
+
+	assert.Equal(t, 0, balloonPressure("MemTotal: 1000000 kB\n"))
+}
+
+func TestBalloonNextLimit(t *testing.T) {
+	assert.Equal(t, int64(768), balloonNextLimit(1024, 512, 2048, 256, -1))
+	assert.Equal(t, int64(512), balloonNextLimit(600, 512, 2048, 256, -1))
+	assert.Equal(t, int64(2048), balloonNextLimit(1900, 512, 2048, 256, 1))
+	assert.Equal(t, int64(1024), balloonNextLimit(1024, 512, 2048, 256, 0))
+
+	// Unlimited cgroups get capped to the maximum right away
+	assert.Equal(t, int64(2048), balloonNextLimit(9223372036854771712, 512, 2048, 256, 0))
+}
@@ -0,0 +1,338 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gorilla/mux"
+
+	"github.com/lxc/lxd/lxd/db"
+	"github.com/lxc/lxd/lxd/task"
+	"github.com/lxc/lxd/shared/api"
+	"github.com/lxc/lxd/shared/logger"
+
+	log "github.com/lxc/lxd/shared/log15"
+)
+
+var containerBandwidthCmd = Command{
+	name: "containers/{name}/bandwidth",
+	get:  containerBandwidthGet,
+}
+
+// Format of the days the traffic is accounted by
+const bandwidthDateFormat = "2006-01-02"
+
+// How long the daily traffic of the containers is kept
+const bandwidthHistoryDays = 400
+
+// bandwidthCounters holds the traffic counters of a network interface.
+type bandwidthCounters struct {
+	hostName string
+	rxBytes  uint64
+	txBytes  uint64
+}
+
+// bandwidthResetDay returns the day of the month on which the monthly traffic
+// of a container resets.
+func bandwidthResetDay(config map[string]string) (int, error) {
+	if config["bandwidth.reset_on"] == "" {
+		return 1, nil
+	}
+
+	return strconv.Atoi(config["bandwidth.reset_on"])
+}
+
+// bandwidthResetDate returns the day of the given month on which the monthly
+// traffic resets, the last day of the month for the days it doesn't have.
+func bandwidthResetDate(year int, month time.Month, resetDay int) time.Time {
+	days := time.Date(year, month+1, 0, 0, 0, 0, 0, time.UTC).Day()
+	if resetDay > days {
+		resetDay = days
+	}
+
+	return time.Date(year, month, resetDay, 0, 0, 0, 0, time.UTC)
+}
+
+// bandwidthPeriod returns the first and last days of the monthly accounting
+// period the given date belongs to.
+func bandwidthPeriod(date time.Time, resetDay int) (time.Time, time.Time) {
+	date = date.UTC()
+	year, month, _ := date.Date()
+
+	start := bandwidthResetDate(year, month, resetDay)
+	if date.Before(start) {
+		start = bandwidthResetDate(year, month-1, resetDay)
+	}
+
+	end := bandwidthResetDate(start.Year(), start.Month()+1, resetDay).AddDate(0, 0, -1)
+	return start, end
+}
+
+// bandwidthSummarize adds up the daily traffic of a container (oldest first)
+// into monthly summaries.
+func bandwidthSummarize(days []db.ContainerBandwidth, resetDay int) ([]api.ContainerBandwidthMonth, error) {
+	months := []api.ContainerBandwidthMonth{}
+	for _, day := range days {
+		date, err := time.Parse(bandwidthDateFormat, day.Date)
+		if err != nil {
+			return nil, err
+		}
+
+		start, end := bandwidthPeriod(date, resetDay)
+		if len(months) == 0 || months[len(months)-1].Start != start.Format(bandwidthDateFormat) {
+			months = append(months, api.ContainerBandwidthMonth{
+				Start: start.Format(bandwidthDateFormat),
+				End:   end.Format(bandwidthDateFormat),
+			})
+		}
+
+		months[len(months)-1].RxBytes += day.RxBytes
+		months[len(months)-1].TxBytes += day.TxBytes
+	}
+
+	return months, nil
+}
+
+// bandwidthDelta returns the traffic since the previous reading of a counter,
+// which restarts from zero when the interface gets recreated.
+func bandwidthDelta(previous uint64, current uint64) uint64 {
+	if current < previous {
+		return current
+	}
+
+	return current - previous
+}
+
+// bandwidthThresholdCrossed returns whether the total traffic went over the
+// given threshold, if any.
+func bandwidthThresholdCrossed(threshold string, before int64, after int64) bool {
+	limit, err := strconv.ParseInt(threshold, 10, 64)
+	if err != nil || limit <= 0 {
+		return false
+	}
+
+	return before < limit && after >= limit
+}
+
+func bandwidthReadCounter(hostName string, counter string) (uint64, error) {
+	content, err := ioutil.ReadFile(fmt.Sprintf("/sys/class/net/%s/statistics/%s", hostName, counter))
+	if err != nil {
+		return 0, err
+	}
+
+	return strconv.ParseUint(strings.TrimSpace(string(content)), 10, 64)
+}
+
+// bandwidthReadDevices returns the counters of the host side of the network
+// interfaces of a container, as seen from the container (the host side
+// receives what the container sends).
+func bandwidthReadDevices(c container) map[string]bandwidthCounters {
+	result := map[string]bandwidthCounters{}
+	for _, name := range c.ExpandedDevices().DeviceNames() {
+		if c.ExpandedDevices()[name]["type"] != "nic" {
+			continue
+		}
+
+		hostName := c.LocalConfig()[fmt.Sprintf("volatile.%s.host_name", name)]
+		if hostName == "" {
+			continue
+		}
+
+		rx, err := bandwidthReadCounter(hostName, "tx_bytes")
+		if err != nil {
+			continue
+		}
+
+		tx, err := bandwidthReadCounter(hostName, "rx_bytes")
+		if err != nil {
+			continue
+		}
+
+		result[name] = bandwidthCounters{hostName: hostName, rxBytes: rx, txBytes: tx}
+	}
+
+	return result
+}
+
+// containerBandwidthAccount records the traffic of the running containers
+// since the previous readings of their counters, emitting events when their
+// monthly traffic goes over their alert threshold or quota.
+func containerBandwidthAccount(d *Daemon, counters map[string]map[string]bandwidthCounters) {
+	containers, err := containerLoadNodeAll(d.State())
+	if err != nil {
+		logger.Error("Failed to load containers for bandwidth accounting", log.Ctx{"err": err})
+		return
+	}
+
+	now := time.Now().UTC()
+	seen := map[string]bool{}
+	for _, c := range containers {
+		if !c.IsRunning() {
+			continue
+		}
+
+		key := projectPrefix(c.Project(), c.Name())
+		seen[key] = true
+
+		current := bandwidthReadDevices(c)
+		previous, ok := counters[key]
+		counters[key] = current
+
+		// Only start counting from the first reading
+		if !ok {
+			continue
+		}
+
+		var rx, tx uint64
+		for name, device := range current {
+			last, ok := previous[name]
+			if !ok || last.hostName != device.hostName {
+				// New interface, counting from zero
+				last = bandwidthCounters{}
+			}
+
+			rx += bandwidthDelta(last.rxBytes, device.rxBytes)
+			tx += bandwidthDelta(last.txBytes, device.txBytes)
+		}
+
+		if rx == 0 && tx == 0 {
+			continue
+		}
+
+		err := containerBandwidthRecord(d, c, now, int64(rx), int64(tx))
+		if err != nil {
+			logger.Error("Failed to record container bandwidth", log.Ctx{"container": c.Name(), "project": c.Project(), "err": err})
+		}
+	}
+
+	for key := range counters {
+		if !seen[key] {
+			delete(counters, key)
+		}
+	}
+}
+
+func containerBandwidthRecord(d *Daemon, c container, now time.Time, rx int64, tx int64) error {
+	resetDay, err := bandwidthResetDay(c.ExpandedConfig())
+	if err != nil {
+		return err
+	}
+
+	start, _ := bandwidthPeriod(now, resetDay)
+	days, err := d.cluster.ContainerBandwidthGet(c.Id(), start.Format(bandwidthDateFormat))
+	if err != nil {
+		return err
+	}
+
+	before := int64(0)
+	for _, day := range days {
+		before += day.RxBytes + day.TxBytes
+	}
+
+	err = d.cluster.ContainerBandwidthAdd(c.Id(), now.Format(bandwidthDateFormat), rx, tx)
+	if err != nil {
+		return err
+	}
+
+	after := before + rx + tx
+	for _, threshold := range []struct {
+		key    string
+		action string
+	}{
+		{"bandwidth.alert_bytes", "container-bandwidth-alert"},
+		{"bandwidth.quota_bytes", "container-bandwidth-quota-exceeded"},
+	} {
+		limit := c.ExpandedConfig()[threshold.key]
+		if !bandwidthThresholdCrossed(limit, before, after) {
+			continue
+		}
+
+		logger.Warn("Container went over its bandwidth threshold", log.Ctx{"container": c.Name(), "project": c.Project(), "threshold": threshold.key, "bytes": after})
+		eventSendLifecycle(c.Project(), threshold.action,
+			fmt.Sprintf("/1.0/containers/%s", c.Name()), map[string]interface{}{
+				"bytes":        after,
+				"limit":        limit,
+				"period_start": start.Format(bandwidthDateFormat),
+			})
+	}
+
+	return nil
+}
+
+func containerBandwidthTask(d *Daemon) (task.Func, task.Schedule) {
+	counters := map[string]map[string]bandwidthCounters{}
+	lastPrune := time.Time{}
+
+	f := func(ctx context.Context) {
+		containerBandwidthAccount(d, counters)
+
+		// Drop the oldest history once a day
+		if time.Since(lastPrune) < 24*time.Hour {
+			return
+		}
+		lastPrune = time.Now()
+
+		before := time.Now().UTC().AddDate(0, 0, -bandwidthHistoryDays).Format(bandwidthDateFormat)
+		err := d.cluster.ContainerBandwidthPrune(before)
+		if err != nil {
+			logger.Error("Failed to prune container bandwidth history", log.Ctx{"err": err})
+		}
+	}
+
+	return f, task.Every(time.Minute)
+}
+
+func containerBandwidthGet(d *Daemon, r *http.Request) Response {
+	project := projectParam(r)
+	name := mux.Vars(r)["name"]
+
+	// Handle requests targeted to a container on a different node
+	response, err := ForwardedResponseIfContainerIsRemote(d, r, project, name)
+	if err != nil {
+		return SmartError(err)
+	}
+	if response != nil {
+		return response
+	}
+
+	c, err := containerLoadByProjectAndName(d.State(), project, name)
+	if err != nil {
+		return SmartError(err)
+	}
+
+	resetDay, err := bandwidthResetDay(c.ExpandedConfig())
+	if err != nil {
+		return InternalError(err)
+	}
+
+	days, err := d.cluster.ContainerBandwidthGet(c.Id(), "")
+	if err != nil {
+		return SmartError(err)
+	}
+
+	months, err := bandwidthSummarize(days, resetDay)
+	if err != nil {
+		return InternalError(err)
+	}
+
+	result := api.ContainerBandwidth{
+		ResetOn: resetDay,
+		Daily:   []api.ContainerBandwidthDay{},
+		Monthly: months,
+	}
+
+	for _, day := range days {
+		result.Daily = append(result.Daily, api.ContainerBandwidthDay{
+			Date:    day.Date,
+			RxBytes: day.RxBytes,
+			TxBytes: day.TxBytes,
+		})
+	}
+
+	return SyncResponse(true, result)
+}
@@ -0,0 +1,81 @@
+package main
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/lxc/lxd/lxd/db"
+	"github.com/lxc/lxd/shared/api"
+)
+
+func TestBandwidthResetDay(t *testing.T) {
+	day, err := bandwidthResetDay(map[string]string{})
+	require.NoError(t, err)
+	assert.Equal(t, 1, day)
+
+	day, err = bandwidthResetDay(map[string]string{"bandwidth.reset_on": "15"})
+	require.NoError(t, err)
+	assert.Equal(t, 15, day)
+}
+
+func TestBandwidthPeriod(t *testing.T) {
+	cases := []struct {
+		date     string
+		resetDay int
+		start    string
+		end      string
+	}{
+		{"2019-05-01", 1, "2019-05-01", "2019-05-31"},
+		{"2019-05-31", 1, "2019-05-01", "2019-05-31"},
+		{"2019-05-14", 15, "2019-04-15", "2019-05-14"},
+		{"2019-05-15", 15, "2019-05-15", "2019-06-14"},
+		{"2019-01-10", 15, "2018-12-15", "2019-01-14"},
+		{"2019-02-28", 31, "2019-02-28", "2019-03-30"},
+		{"2019-02-27", 31, "2019-01-31", "2019-02-27"},
+	}
+
+	for _, c := range cases {
+		date, err := time.Parse(bandwidthDateFormat, c.date)
+		require.NoError(t, err)
+
+		start, end := bandwidthPeriod(date, c.resetDay)
+		assert.Equal(t, c.start, start.Format(bandwidthDateFormat), c.date)
+		assert.Equal(t, c.end, end.Format(bandwidthDateFormat), c.date)
+	}
+}
+
+func TestBandwidthSummarize(t *testing.T) {
+	days := []db.ContainerBandwidth{
+		{Date: "2019-04-20", RxBytes: 1, TxBytes: 2},
+		{Date: "2019-05-09", RxBytes: 10, TxBytes: 20},
+		{Date: "2019-05-10", RxBytes: 100, TxBytes: 200},
+		{Date: "2019-05-11", RxBytes: 1000, TxBytes: 2000},
+	}
+
+	months, err := bandwidthSummarize(days, 10)
+	require.NoError(t, err)
+	assert.Equal(t, []api.ContainerBandwidthMonth{
+		{Start: "2019-04-10", End: "2019-05-09", RxBytes: 11, TxBytes: 22},
+		{Start: "2019-05-10", End: "2019-06-09", RxBytes: 1100, TxBytes: 2200},
+	}, months)
+
+	_, err = bandwidthSummarize([]db.ContainerBandwidth{{Date: "invalid"}}, 1)
+	assert.Error(t, err)
+}
+
+func TestBandwidthDelta(t *testing.T) {
+	assert.Equal(t, uint64(50), bandwidthDelta(100, 150))
+	assert.Equal(t, uint64(20), bandwidthDelta(100, 20))
+}
+
+func TestBandwidthThresholdCrossed(t *testing.T) {
+	assert.True(t, bandwidthThresholdCrossed("100", 90, 110))
+	assert.True(t, bandwidthThresholdCrossed("100", 90, 100))
+	assert.False(t, bandwidthThresholdCrossed("100", 100, 110))
+	assert.False(t, bandwidthThresholdCrossed("100", 10, 20))
+	assert.False(t, bandwidthThresholdCrossed("", 10, 20))
+	assert.False(t, bandwidthThresholdCrossed("0", 0, 20))
+}
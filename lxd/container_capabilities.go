@@ -0,0 +1,206 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/gorilla/mux"
+
+	"github.com/lxc/lxd/lxd/sys"
+	"github.com/lxc/lxd/shared"
+	"github.com/lxc/lxd/shared/api"
+)
+
+var containerCapabilitiesCmd = Command{
+	name: "containers/{name}/capabilities",
+	get:  containerCapabilitiesGet,
+}
+
+// Capability sets found in /proc/<pid>/status
+var capabilitySets = []string{"CapInh", "CapPrm", "CapEff", "CapBnd", "CapAmb"}
+
+// containerCapabilitiesDropped returns the capabilities removed from the
+// bounding set of the container, using the LXC capability names.
+func containerCapabilitiesDropped(os *sys.OS, config map[string]string, privileged bool) ([]string, error) {
+	toDrop := []string{}
+	if privileged {
+		// Base config
+		toDrop = []string{"sys_time", "sys_module", "sys_rawio"}
+		if !os.AppArmorStacking || os.AppArmorStacked {
+			toDrop = append(toDrop, "mac_admin", "mac_override")
+		}
+	}
+
+	capsAdd, err := shared.ParseCapabilities(config["security.capabilities.add"])
+	if err != nil {
+		return nil, err
+	}
+
+	capsDrop, err := shared.ParseCapabilities(config["security.capabilities.drop"])
+	if err != nil {
+		return nil, err
+	}
+
+	caps := []string{}
+	for _, name := range append(toDrop, capsDrop...) {
+		if !shared.StringInSlice(name, capsAdd) && !shared.StringInSlice(name, caps) {
+			caps = append(caps, name)
+		}
+	}
+
+	return caps, nil
+}
+
+// capabilitiesSupported returns the bitmask of all the capabilities supported
+// by the kernel.
+func capabilitiesSupported() uint64 {
+	lastCap := 40
+	content, err := ioutil.ReadFile("/proc/sys/kernel/cap_last_cap")
+	if err == nil {
+		value, err := strconv.Atoi(strings.TrimSpace(string(content)))
+		if err == nil && value >= 0 && value < 64 {
+			lastCap = value
+		}
+	}
+
+	return (uint64(1) << uint(lastCap+1)) - 1
+}
+
+// capabilitiesParseStatus returns the name of a process and its capability
+// sets, given the content of /proc/<pid>/status.
+func capabilitiesParseStatus(status string) (string, map[string]uint64, error) {
+	name := ""
+	sets := map[string]uint64{}
+
+	scan := bufio.NewScanner(strings.NewReader(status))
+	for scan.Scan() {
+		fields := strings.SplitN(scan.Text(), ":", 2)
+		if len(fields) != 2 {
+			continue
+		}
+
+		key := fields[0]
+		value := strings.TrimSpace(fields[1])
+		if key == "Name" {
+			name = value
+			continue
+		}
+
+		if !shared.StringInSlice(key, capabilitySets) {
+			continue
+		}
+
+		mask, err := strconv.ParseUint(value, 16, 64)
+		if err != nil {
+			return "", nil, fmt.Errorf("Invalid %s capability set '%s'", key, value)
+		}
+
+		sets[key] = mask
+	}
+
+	return name, sets, nil
+}
+
+// containerProcesses returns the host pids of the processes of the container,
+// starting from its init process.
+func containerProcesses(pid int) []int64 {
+	pids := []int64{int64(pid)}
+
+	// Go through the pid list, adding new pids at the end so we go through them all
+	for i := 0; i < len(pids); i++ {
+		fname := fmt.Sprintf("/proc/%d/task/%d/children", pids[i], pids[i])
+		fcont, err := ioutil.ReadFile(fname)
+		if err != nil {
+			// the process terminated during execution of this loop
+			continue
+		}
+
+		for _, field := range strings.Fields(string(fcont)) {
+			pid, err := strconv.ParseInt(field, 10, 64)
+			if err == nil {
+				pids = append(pids, pid)
+			}
+		}
+	}
+
+	return pids
+}
+
+func containerCapabilitiesGet(d *Daemon, r *http.Request) Response {
+	project := projectParam(r)
+	name := mux.Vars(r)["name"]
+
+	// Handle requests targeted to a container on a different node
+	response, err := ForwardedResponseIfContainerIsRemote(d, r, project, name)
+	if err != nil {
+		return SmartError(err)
+	}
+	if response != nil {
+		return response
+	}
+
+	c, err := containerLoadByProjectAndName(d.State(), project, name)
+	if err != nil {
+		return SmartError(err)
+	}
+
+	if !c.IsRunning() {
+		return BadRequest(withErrorCode(api.ErrContainerNotRunning, fmt.Errorf("Container is not running")))
+	}
+
+	dropped, err := containerCapabilitiesDropped(d.os, c.ExpandedConfig(), c.IsPrivileged())
+	if err != nil {
+		return SmartError(err)
+	}
+
+	// Everything the bounding set of the container wasn't stripped of
+	granted := capabilitiesSupported() &^ shared.EncodeCapabilities(dropped)
+
+	result := api.ContainerCapabilities{
+		Granted:   shared.DecodeCapabilities(granted),
+		Processes: []api.ContainerCapabilitiesProcess{},
+	}
+
+	effective := uint64(0)
+	anomalies := uint64(0)
+	for _, pid := range containerProcesses(c.InitPID()) {
+		content, err := ioutil.ReadFile(fmt.Sprintf("/proc/%d/status", pid))
+		if err != nil {
+			// The process exited in the meantime
+			continue
+		}
+
+		procName, sets, err := capabilitiesParseStatus(string(content))
+		if err != nil {
+			return InternalError(err)
+		}
+
+		all := uint64(0)
+		for _, mask := range sets {
+			all |= mask
+		}
+
+		effective |= sets["CapEff"]
+		anomalies |= all &^ granted
+
+		result.Processes = append(result.Processes, api.ContainerCapabilitiesProcess{
+			PID:         pid,
+			Name:        procName,
+			Inheritable: shared.DecodeCapabilities(sets["CapInh"]),
+			Permitted:   shared.DecodeCapabilities(sets["CapPrm"]),
+			Effective:   shared.DecodeCapabilities(sets["CapEff"]),
+			Bounding:    shared.DecodeCapabilities(sets["CapBnd"]),
+			Ambient:     shared.DecodeCapabilities(sets["CapAmb"]),
+			Anomalies:   shared.DecodeCapabilities(all &^ granted),
+		})
+	}
+
+	result.Effective = shared.DecodeCapabilities(effective)
+	result.Anomalies = shared.DecodeCapabilities(anomalies)
+
+	return SyncResponse(true, result)
+}
@@ -0,0 +1,307 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/gorilla/mux"
+
+	"github.com/lxc/lxd/shared"
+	"github.com/lxc/lxd/shared/api"
+	"github.com/lxc/lxd/shared/logger"
+)
+
+var containerCgroupTraceCmd = Command{
+	name: "containers/{name}/cgroup/trace",
+	get:  containerCgroupTraceGet,
+}
+
+// How often the cgroup files are read, on top of the inotify notifications
+// of the files supporting them
+const cgroupTracePollInterval = 250 * time.Millisecond
+
+// cgroupTraceSource is a cgroup file whose values are turned into events.
+type cgroupTraceSource struct {
+	subsystem string
+	path      string
+
+	// Event type of each traced key of the file (empty for single value
+	// files)
+	events map[string]string
+
+	// Divisor of the values, to normalize their unit
+	divisors map[string]int64
+
+	values map[string]int64
+}
+
+// cgroupTraceFiles lists the traced files of each cgroup version.
+var cgroupTraceFiles = map[string][]cgroupTraceSource{
+	"v1": {
+		{subsystem: "cpu", path: "cpu.stat", events: map[string]string{"nr_throttled": "throttled_periods", "throttled_time": "throttled_usec"}, divisors: map[string]int64{"throttled_time": 1000}},
+		{subsystem: "memory", path: "memory.oom_control", events: map[string]string{"under_oom": "oom", "oom_kill": "oom_kill"}},
+		{subsystem: "memory", path: "memory.failcnt", events: map[string]string{"": "max"}},
+		{subsystem: "freezer", path: "freezer.state", events: map[string]string{"": "frozen"}},
+	},
+	"v2": {
+		{subsystem: "cpu", path: "cpu.stat", events: map[string]string{"nr_throttled": "throttled_periods", "throttled_usec": "throttled_usec"}},
+		{subsystem: "memory", path: "memory.events", events: map[string]string{"low": "low", "high": "high", "max": "max", "oom": "oom", "oom_kill": "oom_kill"}},
+		{subsystem: "cgroup", path: "cgroup.events", events: map[string]string{"frozen": "frozen", "populated": "populated"}},
+	},
+}
+
+// cgroupTraceParse parses the content of a cgroup file made of either "key
+// value" lines or a single value. Freezer states count as 1 when frozen and 0
+// otherwise.
+func cgroupTraceParse(content string) map[string]int64 {
+	values := map[string]int64{}
+	for _, line := range strings.Split(strings.TrimSpace(content), "\n") {
+		fields := strings.Fields(line)
+		switch len(fields) {
+		case 1:
+			if fields[0] == "FROZEN" {
+				values[""] = 1
+			} else if fields[0] == "THAWED" || fields[0] == "FREEZING" {
+				values[""] = 0
+			} else {
+				value, err := strconv.ParseInt(fields[0], 10, 64)
+				if err == nil {
+					values[""] = value
+				}
+			}
+		case 2:
+			value, err := strconv.ParseInt(fields[1], 10, 64)
+			if err == nil {
+				values[fields[0]] = value
+			}
+		}
+	}
+
+	return values
+}
+
+// cgroupTraceSources returns the traced files of the cgroups of a process.
+func cgroupTraceSources(pid int) ([]*cgroupTraceSource, error) {
+	f, err := os.Open(fmt.Sprintf("/proc/%d/cgroup", pid))
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	sources := []*cgroupTraceSource{}
+	add := func(version string, dir string, subsystem string) {
+		for _, file := range cgroupTraceFiles[version] {
+			if subsystem != "" && file.subsystem != subsystem {
+				continue
+			}
+
+			path := filepath.Join(dir, file.path)
+			if !shared.PathExists(path) {
+				continue
+			}
+
+			source := file
+			source.path = path
+			source.values = map[string]int64{}
+			sources = append(sources, &source)
+		}
+	}
+
+	scan := bufio.NewScanner(f)
+	for scan.Scan() {
+		fields := strings.SplitN(scan.Text(), ":", 3)
+		if len(fields) != 3 {
+			continue
+		}
+
+		// Ignore the sub-cgroup the container's init may have moved to
+		cgroup := strings.TrimSuffix(fields[2], "/init.scope")
+
+		if fields[1] == "" {
+			dir := filepath.Join("/sys/fs/cgroup/unified", cgroup)
+			if !shared.PathExists(dir) {
+				dir = filepath.Join("/sys/fs/cgroup", cgroup)
+			}
+
+			add("v2", dir, "")
+			continue
+		}
+
+		for _, controller := range strings.Split(fields[1], ",") {
+			if controller == "cpu" || controller == "memory" || controller == "freezer" {
+				add("v1", filepath.Join("/sys/fs/cgroup", fields[1], cgroup), controller)
+			}
+		}
+	}
+
+	return sources, scan.Err()
+}
+
+// read reads the file and returns the events for the values which changed
+// since the previous read.
+func (s *cgroupTraceSource) read(name string, initial bool) ([]api.ContainerCgroupEvent, error) {
+	content, err := ioutil.ReadFile(s.path)
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now().UnixNano()
+	events := []api.ContainerCgroupEvent{}
+	for key, value := range cgroupTraceParse(string(content)) {
+		eventType, ok := s.events[key]
+		if !ok {
+			continue
+		}
+
+		divisor, ok := s.divisors[key]
+		if ok {
+			value = value / divisor
+		}
+
+		previous, ok := s.values[key]
+		s.values[key] = value
+		if initial || (ok && previous == value) {
+			continue
+		}
+
+		events = append(events, api.ContainerCgroupEvent{
+			Subsystem: s.subsystem,
+			Type:      eventType,
+			Value:     value,
+			Container: name,
+			Timestamp: now,
+		})
+	}
+
+	return events, nil
+}
+
+func containerCgroupTraceGet(d *Daemon, r *http.Request) Response {
+	project := projectParam(r)
+	name := mux.Vars(r)["name"]
+
+	// Handle requests targeted to a container on a different node
+	response, err := ForwardedResponseIfContainerIsRemote(d, r, project, name)
+	if err != nil {
+		return SmartError(err)
+	}
+	if response != nil {
+		return response
+	}
+
+	c, err := containerLoadByProjectAndName(d.State(), project, name)
+	if err != nil {
+		return SmartError(err)
+	}
+
+	if !c.IsRunning() {
+		return BadRequest(withErrorCode(api.ErrContainerNotRunning, fmt.Errorf("Container is not running")))
+	}
+
+	sources, err := cgroupTraceSources(c.InitPID())
+	if err != nil {
+		return InternalError(err)
+	}
+
+	if len(sources) == 0 {
+		return NotImplemented(fmt.Errorf("No traceable cgroup found for the container"))
+	}
+
+	return &containerCgroupTraceServe{req: r, container: c, sources: sources}
+}
+
+type containerCgroupTraceServe struct {
+	req       *http.Request
+	container container
+	sources   []*cgroupTraceSource
+}
+
+func (r *containerCgroupTraceServe) Render(w http.ResponseWriter) error {
+	conn, err := shared.WebsocketUpgrader.Upgrade(w, r.req, nil)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+
+	// Detect the client going away
+	done := make(chan struct{})
+	go func() {
+		for {
+			_, _, err := conn.NextReader()
+			if err != nil {
+				close(done)
+				return
+			}
+		}
+	}()
+
+	// Get woken up as soon as the files supporting notifications change
+	wake := make(chan struct{}, 1)
+	fd, err := syscall.InotifyInit1(syscall.IN_CLOEXEC | syscall.IN_NONBLOCK)
+	if err != nil {
+		logger.Debugf("Failed to initialize inotify, only polling cgroups: %v", err)
+	} else {
+		for _, source := range r.sources {
+			syscall.InotifyAddWatch(fd, source.path, syscall.IN_MODIFY)
+		}
+
+		inotify := os.NewFile(uintptr(fd), "inotify")
+		defer inotify.Close()
+
+		go func() {
+			buf := make([]byte, 4096)
+			for {
+				_, err := inotify.Read(buf)
+				if err != nil {
+					return
+				}
+
+				select {
+				case wake <- struct{}{}:
+				default:
+				}
+			}
+		}()
+	}
+
+	ticker := time.NewTicker(cgroupTracePollInterval)
+	defer ticker.Stop()
+
+	initial := true
+	for {
+		for _, source := range r.sources {
+			events, err := source.read(r.container.Name(), initial)
+			if err != nil {
+				// The cgroup goes away with the container
+				return nil
+			}
+
+			for _, event := range events {
+				err := conn.WriteJSON(event)
+				if err != nil {
+					return nil
+				}
+			}
+		}
+		initial = false
+
+		select {
+		case <-done:
+			return nil
+		case <-wake:
+		case <-ticker.C:
+		}
+	}
+}
+
+func (r *containerCgroupTraceServe) String() string {
+	return "container cgroup trace handler"
+}
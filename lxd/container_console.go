@@ -1,13 +1,16 @@
 package main
 
 import (
+	"encoding/hex"
 	"encoding/json"
 	"fmt"
+	"io"
 	"io/ioutil"
 	"net/http"
 	"os"
 	"os/exec"
 	"strconv"
+	"strings"
 	"sync"
 	"syscall"
 
@@ -21,6 +24,8 @@ import (
 	"github.com/lxc/lxd/shared"
 	"github.com/lxc/lxd/shared/api"
 	"github.com/lxc/lxd/shared/logger"
+
+	log "github.com/lxc/lxd/shared/log15"
 )
 
 type consoleWs struct {
@@ -36,9 +41,15 @@ type consoleWs struct {
 	// websocket connections to bridge pty fds to
 	conns map[int]*websocket.Conn
 
+	// read-only websocket connections getting the output of the pty fds
+	viewers map[int][]*consoleViewer
+
 	// locks needed to access the "conns" member
 	connsLock sync.Mutex
 
+	// lock needed to write to the control socket
+	controlLock sync.Mutex
+
 	// channel to wait until all websockets are properly connected
 	allConnected chan bool
 
@@ -53,8 +64,18 @@ type consoleWs struct {
 
 	// terminal height
 	height int
+
+	// whether this is a mock session, not attached to any container
+	mock bool
 }
 
+// Prompt of the mock console sessions
+const consoleMockPrompt = "mock> "
+
+// Prefix of the container names getting a mock console session when
+// core.console_mock_mode is enabled
+const consoleMockPrefix = "mock-"
+
 func (s *consoleWs) Metadata() interface{} {
 	fds := shared.Jmap{}
 	for fd, secret := range s.fds {
@@ -65,6 +86,10 @@ func (s *consoleWs) Metadata() interface{} {
 		}
 	}
 
+	if s.mock {
+		return shared.Jmap{"fds": fds, "mock": true}
+	}
+
 	return shared.Jmap{"fds": fds}
 }
 
@@ -74,13 +99,25 @@ func (s *consoleWs) Connect(op *operation, r *http.Request, w http.ResponseWrite
 		return fmt.Errorf("missing secret")
 	}
 
+	viewer := r.FormValue("role") == "viewer"
+
 	for fd, fdSecret := range s.fds {
 		if secret == fdSecret {
+			// Only the console itself can be viewed
+			if viewer && fd != 0 {
+				return os.ErrPermission
+			}
+
 			conn, err := shared.WebsocketUpgrader.Upgrade(w, r, nil)
 			if err != nil {
 				return err
 			}
 
+			if viewer {
+				s.addViewer(fd, conn)
+				return nil
+			}
+
 			s.connsLock.Lock()
 			s.conns[fd] = conn
 			s.connsLock.Unlock()
@@ -124,6 +161,23 @@ func (s *consoleWs) Do(op *operation) error {
 		shared.SetSize(int(master.Fd()), s.width, s.height)
 	}
 
+	// Authenticate the user before granting access to the console
+	if !s.mock && s.container.ExpandedConfig()["security.console_auth"] == "password" {
+		s.connsLock.Lock()
+		conn := s.conns[0]
+		s.connsLock.Unlock()
+
+		err = consolePasswordAuthenticate(conn, s.container.LocalConfig()["security.console_auth.password"])
+		if err != nil {
+			logger.Warn("Console authentication failed", log.Ctx{"container": s.container.Name(), "err": err})
+			conn.WriteMessage(websocket.BinaryMessage, []byte("\r\nAuthentication failed\r\n"))
+			conn.Close()
+			master.Close()
+			slave.Close()
+			return fmt.Errorf("Console authentication failed")
+		}
+	}
+
 	controlExit := make(chan bool)
 	var wgEOF sync.WaitGroup
 
@@ -200,8 +254,14 @@ func (s *consoleWs) Do(op *operation) error {
 		conn := s.conns[0]
 		s.connsLock.Unlock()
 
+		var r io.ReadCloser = master
+		if !s.mock && shared.IsTrue(s.container.ExpandedConfig()["console.clipboard_passthrough"]) {
+			r = &consoleClipboardReader{ReadCloser: master, clipboard: s.sendClipboard}
+		}
+		r = &consoleViewersReader{ReadCloser: r, ws: s}
+
 		logger.Debugf("Starting to mirror websocket")
-		readDone, writeDone := shared.WebsocketConsoleMirror(conn, master, master)
+		readDone, writeDone := shared.WebsocketConsoleMirror(conn, master, r)
 
 		<-readDone
 		logger.Debugf("Finished to read websocket")
@@ -210,6 +270,7 @@ func (s *consoleWs) Do(op *operation) error {
 		logger.Debugf("Finished to mirror websocket")
 
 		conn.Close()
+		s.closeViewers(0)
 		wgEOF.Done()
 	}()
 
@@ -231,7 +292,13 @@ func (s *consoleWs) Do(op *operation) error {
 		return cmdErr
 	}
 
-	consCmd := s.container.Console(slave)
+	var consCmd *exec.Cmd
+	if s.mock {
+		consCmd = consoleMockCommand(slave)
+	} else {
+		consCmd = s.container.Console(slave)
+	}
+
 	consCmd.Start()
 	consolePidChan <- consCmd.Process.Pid
 	err = consCmd.Wait()
@@ -252,6 +319,116 @@ func (s *consoleWs) Do(op *operation) error {
 	return finisher(err)
 }
 
+// sendClipboard sends the data of an OSC 52 sequence from the console to the
+// control socket, if connected.
+func (s *consoleWs) sendClipboard(data string) {
+	s.connsLock.Lock()
+	conn := s.conns[-1]
+	s.connsLock.Unlock()
+
+	if conn == nil {
+		return
+	}
+
+	msg := api.ContainerConsoleControl{
+		Command: "clipboard",
+		Args:    map[string]string{"data": data},
+	}
+
+	s.controlLock.Lock()
+	err := conn.WriteJSON(msg)
+	s.controlLock.Unlock()
+	if err != nil {
+		logger.Debugf("Failed to send clipboard to control socket: %s", err)
+	}
+}
+
+// consoleMockCommand returns a command echoing back each line written to the
+// console, after a prompt.
+func consoleMockCommand(slave *os.File) *exec.Cmd {
+	script := fmt.Sprintf(`printf '%[1]s'; while IFS= read -r line; do printf '%%s\n' "$line"; printf '%[1]s'; done`, consoleMockPrompt)
+
+	cmd := exec.Command("/bin/sh", "-c", script)
+	cmd.Stdin = slave
+	cmd.Stdout = slave
+	cmd.Stderr = slave
+
+	return cmd
+}
+
+// consolePasswordHash replaces a clear text security.console_auth.password
+// in the config with its hash, leaving an already hashed value alone.
+func consolePasswordHash(config map[string]string) error {
+	password := config["security.console_auth.password"]
+	if password == "" {
+		return nil
+	}
+
+	_, err := hex.DecodeString(password)
+	if err == nil && len(password) == 192 {
+		return nil
+	}
+
+	hash, err := util.PasswordHash(password)
+	if err != nil {
+		return err
+	}
+
+	config["security.console_auth.password"] = hash
+	return nil
+}
+
+// consolePasswordAuthenticate prompts for the password of the container over
+// the console websocket and checks it against the stored hash.
+func consolePasswordAuthenticate(conn *websocket.Conn, secret string) error {
+	write := func(msg string) error {
+		return conn.WriteMessage(websocket.BinaryMessage, []byte(msg))
+	}
+
+	// The client sends raw keystrokes, so accumulate them until enter is pressed
+	readLine := func() (string, error) {
+		line := []byte{}
+		for {
+			_, buf, err := conn.ReadMessage()
+			if err != nil {
+				return "", err
+			}
+
+			for _, c := range buf {
+				switch c {
+				case '\r', '\n':
+					err := write("\r\n")
+					if err != nil {
+						return "", err
+					}
+
+					return string(line), nil
+				case 0x7f, 0x08:
+					if len(line) > 0 {
+						line = line[:len(line)-1]
+					}
+				case 0x03, 0x04:
+					return "", fmt.Errorf("Authentication aborted by the client")
+				default:
+					line = append(line, c)
+				}
+			}
+		}
+	}
+
+	err := write("Password: ")
+	if err != nil {
+		return err
+	}
+
+	password, err := readLine()
+	if err != nil {
+		return err
+	}
+
+	return util.PasswordCheck(secret, password)
+}
+
 func containerConsolePost(d *Daemon, r *http.Request) Response {
 	project := projectParam(r)
 	name := mux.Vars(r)["name"]
@@ -267,6 +444,18 @@ func containerConsolePost(d *Daemon, r *http.Request) Response {
 		return BadRequest(err)
 	}
 
+	// Mock sessions don't need any container
+	if strings.HasPrefix(name, consoleMockPrefix) {
+		mock, err := cluster.ConfigGetBool(d.cluster, "core.console_mock_mode")
+		if err != nil {
+			return SmartError(err)
+		}
+
+		if mock {
+			return containerConsoleMockPost(d, project, name, post)
+		}
+	}
+
 	// Forward the request if the container is remote.
 	cert := d.endpoints.NetworkCert()
 	client, err := cluster.ConnectIfContainerIsRemote(d.cluster, project, name, cert)
@@ -290,7 +479,7 @@ func containerConsolePost(d *Daemon, r *http.Request) Response {
 		return SmartError(err)
 	}
 
-	err = fmt.Errorf("Container is not running")
+	err = withErrorCode(api.ErrContainerNotRunning, fmt.Errorf("Container is not running"))
 	if !c.IsRunning() {
 		return BadRequest(err)
 	}
@@ -341,6 +530,44 @@ func containerConsolePost(d *Daemon, r *http.Request) Response {
 	return OperationResponse(op)
 }
 
+// containerConsoleMockPost starts a mock console session, echoing back the
+// input of the client.
+func containerConsoleMockPost(d *Daemon, project string, name string, post api.ContainerConsolePost) Response {
+	ws := &consoleWs{}
+	ws.mock = true
+	ws.fds = map[int]string{}
+	ws.rootUid = int64(os.Getuid())
+	ws.rootGid = int64(os.Getgid())
+
+	var err error
+	ws.conns = map[int]*websocket.Conn{}
+	ws.conns[-1] = nil
+	ws.conns[0] = nil
+	for i := -1; i < len(ws.conns)-1; i++ {
+		ws.fds[i], err = shared.RandomCryptoString()
+		if err != nil {
+			return InternalError(err)
+		}
+	}
+
+	ws.allConnected = make(chan bool, 1)
+	ws.controlConnected = make(chan bool, 1)
+
+	ws.width = post.Width
+	ws.height = post.Height
+
+	resources := map[string][]string{}
+	resources["containers"] = []string{name}
+
+	op, err := operationCreate(d.cluster, project, operationClassWebsocket, db.OperationConsoleShow,
+		resources, ws.Metadata(), ws.Do, nil, ws.Connect)
+	if err != nil {
+		return InternalError(err)
+	}
+
+	return OperationResponse(op)
+}
+
 func containerConsoleLogGet(d *Daemon, r *http.Request) Response {
 	project := projectParam(r)
 	name := mux.Vars(r)["name"]
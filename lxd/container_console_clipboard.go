@@ -0,0 +1,98 @@
+package main
+
+import (
+	"bytes"
+	"encoding/base64"
+	"io"
+)
+
+// Start of the OSC 52 (set clipboard) escape sequences
+var consoleOSC52Prefix = []byte("\x1b]52;")
+
+// Longest OSC 52 sequence kept around while waiting for its terminator
+const consoleOSC52MaxLength = 1024 * 1024
+
+// consoleOSC52Parser extracts the clipboard data of the OSC 52 escape
+// sequences (ESC ] 52 ; <selection> ; <base64 data> BEL or ESC \) from the
+// output of a console, which may be split across several reads.
+type consoleOSC52Parser struct {
+	pending []byte
+}
+
+// feed parses more output of the console, returning the base64 encoded
+// clipboard data of the sequences it completes.
+func (p *consoleOSC52Parser) feed(buf []byte) []string {
+	data := append(p.pending, buf...)
+	p.pending = nil
+
+	result := []string{}
+	for {
+		start := bytes.Index(data, consoleOSC52Prefix)
+		if start < 0 {
+			// Keep the start of a sequence cut by the end of the read
+			for n := len(consoleOSC52Prefix) - 1; n > 0; n-- {
+				if bytes.HasSuffix(data, consoleOSC52Prefix[:n]) {
+					p.pending = append([]byte{}, consoleOSC52Prefix[:n]...)
+					break
+				}
+			}
+
+			return result
+		}
+
+		data = data[start+len(consoleOSC52Prefix):]
+
+		end, endLength := bytes.IndexByte(data, '\a'), 1
+		st := bytes.Index(data, []byte("\x1b\\"))
+		if st >= 0 && (end < 0 || st < end) {
+			end, endLength = st, 2
+		}
+
+		if end < 0 {
+			if len(data) < consoleOSC52MaxLength {
+				p.pending = append(append([]byte{}, consoleOSC52Prefix...), data...)
+			}
+
+			return result
+		}
+
+		sequence := data[:end]
+		data = data[end+endLength:]
+
+		// Skip the selection parameter
+		i := bytes.IndexByte(sequence, ';')
+		if i < 0 {
+			continue
+		}
+
+		clipboard := string(sequence[i+1:])
+
+		// Ignore the clipboard queries and invalid data
+		_, err := base64.StdEncoding.DecodeString(clipboard)
+		if clipboard == "?" || err != nil {
+			continue
+		}
+
+		result = append(result, clipboard)
+	}
+}
+
+// consoleClipboardReader passes through the output of a console, calling
+// clipboard with the data of each OSC 52 sequence it contains.
+type consoleClipboardReader struct {
+	io.ReadCloser
+
+	parser    consoleOSC52Parser
+	clipboard func(data string)
+}
+
+func (r *consoleClipboardReader) Read(p []byte) (int, error) {
+	n, err := r.ReadCloser.Read(p)
+	if n > 0 {
+		for _, data := range r.parser.feed(p[:n]) {
+			r.clipboard(data)
+		}
+	}
+
+	return n, err
+}
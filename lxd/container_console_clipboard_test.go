@@ -0,0 +1,51 @@
+package main
+
+import (
+	"bytes"
+	"io/ioutil"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestConsoleOSC52Parser(t *testing.T) {
+	cases := []struct {
+		name   string
+		reads  []string
+		result []string
+	}{
+		{"bel", []string{"foo\x1b]52;c;aGVsbG8=\abar"}, []string{"aGVsbG8="}},
+		{"st", []string{"\x1b]52;;aGVsbG8=\x1b\\"}, []string{"aGVsbG8="}},
+		{"several", []string{"\x1b]52;c;Zm9v\a\x1b]52;p;YmFy\a"}, []string{"Zm9v", "YmFy"}},
+		{"split", []string{"foo\x1b]5", "2;c;aGVs", "bG8=\x1b", "\\"}, []string{"aGVsbG8="}},
+		{"query", []string{"\x1b]52;c;?\a"}, []string{}},
+		{"invalid", []string{"\x1b]52;c;not base64!\a"}, []string{}},
+		{"clear", []string{"\x1b]52;c;\a"}, []string{""}},
+		{"other", []string{"\x1b]0;title\a\x1b]52"}, []string{}},
+	}
+
+	for _, c := range cases {
+		parser := consoleOSC52Parser{}
+		result := []string{}
+		for _, read := range c.reads {
+			result = append(result, parser.feed([]byte(read))...)
+		}
+
+		assert.Equal(t, c.result, result, c.name)
+	}
+}
+
+func TestConsoleClipboardReader(t *testing.T) {
+	output := "ls\r\n\x1b]52;c;aGVsbG8=\a$ "
+	clipboard := []string{}
+
+	r := &consoleClipboardReader{
+		ReadCloser: ioutil.NopCloser(bytes.NewBufferString(output)),
+		clipboard:  func(data string) { clipboard = append(clipboard, data) },
+	}
+
+	buf, err := ioutil.ReadAll(r)
+	assert.NoError(t, err)
+	assert.Equal(t, output, string(buf))
+	assert.Equal(t, []string{"aGVsbG8="}, clipboard)
+}
@@ -0,0 +1,148 @@
+package main
+
+import (
+	"io"
+	"io/ioutil"
+	"time"
+
+	"github.com/gorilla/websocket"
+
+	"github.com/lxc/lxd/shared/logger"
+)
+
+// Reason given to the viewers of a console session when it ends
+const consoleViewersCloseReason = "The primary console session disconnected"
+
+// Number of outputs queued for a viewer, which is dropped once they're all
+// pending so that a slow viewer can't hold the console session back
+const consoleViewersQueue = 64
+
+// How long sending some output to a viewer may take
+const consoleViewersWriteTimeout = 10 * time.Second
+
+// consoleViewer is a read-only viewer of a console session.
+type consoleViewer struct {
+	conn *websocket.Conn
+	out  chan []byte
+
+	// Message sent once the queued output was, if any
+	closeMsg []byte
+}
+
+// addViewer adds a read-only viewer of the given fd, whose input is
+// discarded.
+func (s *consoleWs) addViewer(fd int, conn *websocket.Conn) {
+	viewer := &consoleViewer{conn: conn, out: make(chan []byte, consoleViewersQueue)}
+
+	s.connsLock.Lock()
+	if s.viewers == nil {
+		s.viewers = map[int][]*consoleViewer{}
+	}
+	s.viewers[fd] = append(s.viewers[fd], viewer)
+	s.connsLock.Unlock()
+
+	go func() {
+		for {
+			_, r, err := conn.NextReader()
+			if err != nil {
+				break
+			}
+
+			io.Copy(ioutil.Discard, r)
+		}
+
+		s.removeViewer(fd, viewer, nil)
+	}()
+
+	go s.writeViewer(fd, viewer)
+}
+
+// removeViewer removes a viewer of the given fd, its connection being closed
+// once its queued output and the given close message, if any, were sent.
+func (s *consoleWs) removeViewer(fd int, viewer *consoleViewer, closeMsg []byte) {
+	s.connsLock.Lock()
+	defer s.connsLock.Unlock()
+
+	for i, v := range s.viewers[fd] {
+		if v == viewer {
+			s.viewers[fd] = append(s.viewers[fd][:i], s.viewers[fd][i+1:]...)
+			viewer.closeMsg = closeMsg
+			close(viewer.out)
+			break
+		}
+	}
+}
+
+// writeViewer sends the queued output to a viewer until it's removed.
+func (s *consoleWs) writeViewer(fd int, viewer *consoleViewer) {
+	defer viewer.conn.Close()
+
+	for buf := range viewer.out {
+		viewer.conn.SetWriteDeadline(time.Now().Add(consoleViewersWriteTimeout))
+		err := viewer.conn.WriteMessage(websocket.BinaryMessage, buf)
+		if err != nil {
+			logger.Debugf("Failed to send console output to viewer: %s", err)
+			s.removeViewer(fd, viewer, nil)
+			return
+		}
+	}
+
+	if viewer.closeMsg != nil {
+		viewer.conn.SetWriteDeadline(time.Now().Add(consoleViewersWriteTimeout))
+		viewer.conn.WriteMessage(websocket.CloseMessage, viewer.closeMsg)
+	}
+}
+
+// sendViewers queues output of the given fd for its viewers, dropping those
+// too slow to keep up.
+func (s *consoleWs) sendViewers(fd int, buf []byte) {
+	out := append([]byte{}, buf...)
+	slow := []*consoleViewer{}
+
+	s.connsLock.Lock()
+	for _, viewer := range s.viewers[fd] {
+		select {
+		case viewer.out <- out:
+		default:
+			slow = append(slow, viewer)
+		}
+	}
+	s.connsLock.Unlock()
+
+	for _, viewer := range slow {
+		logger.Debugf("Dropping console viewer unable to keep up with the output")
+		s.removeViewer(fd, viewer, nil)
+	}
+}
+
+// closeViewers tells the viewers of the given fd that the session ended and
+// disconnects them.
+func (s *consoleWs) closeViewers(fd int) {
+	closeMsg := websocket.FormatCloseMessage(websocket.CloseNormalClosure, consoleViewersCloseReason)
+
+	s.connsLock.Lock()
+	defer s.connsLock.Unlock()
+
+	for _, viewer := range s.viewers[fd] {
+		viewer.closeMsg = closeMsg
+		close(viewer.out)
+	}
+	delete(s.viewers, fd)
+}
+
+// consoleViewersReader passes through the output of a console, copying it to
+// the read-only viewers of the session.
+type consoleViewersReader struct {
+	io.ReadCloser
+
+	ws *consoleWs
+}
+
+func (r *consoleViewersReader) Read(p []byte) (int, error) {
+	n, err := r.ReadCloser.Read(p)
+	if n > 0 {
+		r.ws.sendViewers(0, p[:n])
+	}
+
+	return n, err
+}
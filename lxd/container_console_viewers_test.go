@@ -0,0 +1,85 @@
+package main
+
+import (
+	"bytes"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gorilla/websocket"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// Return a websocket connection to a test server, along with the server side
+// of the connection.
+func newConsoleViewerConn(t *testing.T) (*websocket.Conn, *websocket.Conn, func()) {
+	upgrader := websocket.Upgrader{}
+	serverConns := make(chan *websocket.Conn, 1)
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		conn, err := upgrader.Upgrade(w, r, nil)
+		require.NoError(t, err)
+		serverConns <- conn
+	}))
+
+	client, _, err := websocket.DefaultDialer.Dial("ws"+strings.TrimPrefix(server.URL, "http"), nil)
+	require.NoError(t, err)
+
+	return client, <-serverConns, func() {
+		client.Close()
+		server.Close()
+	}
+}
+
+func TestConsoleViewers(t *testing.T) {
+	client, conn, cleanup := newConsoleViewerConn(t)
+	defer cleanup()
+
+	s := &consoleWs{}
+	s.addViewer(0, conn)
+
+	r := &consoleViewersReader{ReadCloser: ioutil.NopCloser(bytes.NewBufferString("$ ls\r\n")), ws: s}
+	buf, err := ioutil.ReadAll(r)
+	require.NoError(t, err)
+	assert.Equal(t, "$ ls\r\n", string(buf))
+
+	mt, msg, err := client.ReadMessage()
+	require.NoError(t, err)
+	assert.Equal(t, websocket.BinaryMessage, mt)
+	assert.Equal(t, "$ ls\r\n", string(msg))
+
+	s.closeViewers(0)
+
+	_, _, err = client.ReadMessage()
+	closeErr, ok := err.(*websocket.CloseError)
+	require.True(t, ok, err)
+	assert.Equal(t, websocket.CloseNormalClosure, closeErr.Code)
+	assert.Equal(t, consoleViewersCloseReason, closeErr.Text)
+	assert.Len(t, s.viewers[0], 0)
+}
+
+func TestConsoleViewersSlow(t *testing.T) {
+	_, conn, cleanup := newConsoleViewerConn(t)
+	defer cleanup()
+
+	s := &consoleWs{}
+	s.addViewer(0, conn)
+
+	dropped := func() bool {
+		s.connsLock.Lock()
+		defer s.connsLock.Unlock()
+
+		return len(s.viewers[0]) == 0
+	}
+
+	// The client never reads, the output piling up until it's dropped
+	buf := make([]byte, 64*1024)
+	for i := 0; i < 1024 && !dropped(); i++ {
+		s.sendViewers(0, buf)
+	}
+
+	assert.True(t, dropped())
+}
@@ -0,0 +1,750 @@
+package main
+
+import (
+	"compress/gzip"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"math"
+	"net/http"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/gorilla/mux"
+
+	"github.com/lxc/lxd/lxd/state"
+	"github.com/lxc/lxd/shared"
+	"github.com/lxc/lxd/shared/api"
+	"github.com/lxc/lxd/shared/logger"
+
+	log "github.com/lxc/lxd/shared/log15"
+)
+
+var containerCoredumpsCmd = Command{
+	name: "containers/{name}/coredumps",
+	get:  containerCoredumpsGet,
+}
+
+var containerCoredumpCmd = Command{
+	name: "containers/{name}/coredumps/{id}",
+	get:  containerCoredumpGet,
+}
+
+var internalCoredumpsCmd = Command{
+	name: "coredumps",
+	get:  internalCoredumpsGet,
+	post: internalCoredumpsPost,
+}
+
+// The kernel truncates core patterns longer than this
+const coredumpPatternMaxLength = 127
+
+var coredumpIDRegexp = regexp.MustCompile(`^[0-9]+-[0-9]+$`)
+
+// coredumpLock serializes the writes to the core dump directories, so that
+// concurrent crashes don't race when applying the retention limits.
+var coredumpLock sync.Mutex
+
+// coredumpPattern returns the core pattern piping the core dumps to the
+// forkcoredump helper of LXD.
+func coredumpPattern(execPath string, varDir string) (string, error) {
+	pattern := fmt.Sprintf("|%s forkcoredump %s %%P %%s %%t %%e", execPath, varDir)
+	if len(pattern) > coredumpPatternMaxLength {
+		return "", fmt.Errorf("The core pattern exceeds %d characters: %s", coredumpPatternMaxLength, pattern)
+	}
+
+	return pattern, nil
+}
+
+// coredumpSetCapture sets or restores the core pattern of the host. The
+// previous pattern is saved when enabling the capture, to be restored when
+// disabling it.
+func coredumpSetCapture(execPath string, enabled bool) error {
+	pattern, err := coredumpPattern(execPath, shared.VarPath())
+	if err != nil {
+		return err
+	}
+
+	content, err := ioutil.ReadFile("/proc/sys/kernel/core_pattern")
+	if err != nil {
+		return err
+	}
+	current := strings.TrimSuffix(string(content), "\n")
+
+	savedPath := shared.VarPath("coredump_pattern")
+	if enabled {
+		if current == pattern {
+			return nil
+		}
+
+		err = ioutil.WriteFile(savedPath, []byte(current), 0600)
+		if err != nil {
+			return err
+		}
+
+		return ioutil.WriteFile("/proc/sys/kernel/core_pattern", []byte(pattern), 0644)
+	}
+
+	if current != pattern {
+		return nil
+	}
+
+	previous := "core"
+	content, err = ioutil.ReadFile(savedPath)
+	if err == nil {
+		previous = string(content)
+	} else if !os.IsNotExist(err) {
+		return err
+	}
+
+	err = ioutil.WriteFile("/proc/sys/kernel/core_pattern", []byte(previous), 0644)
+	if err != nil {
+		return err
+	}
+
+	return os.Remove(savedPath)
+}
+
+// coredumpPatternSaved returns the core pattern of the host from before the
+// capture was enabled.
+func coredumpPatternSaved(varDir string) (string, error) {
+	content, err := ioutil.ReadFile(filepath.Join(varDir, "coredump_pattern"))
+	if err != nil {
+		if os.IsNotExist(err) {
+			return "core", nil
+		}
+
+		return "", err
+	}
+
+	return string(content), nil
+}
+
+// coredumpExpandPattern expands the specifiers of a core pattern, as listed
+// in core(5), given their values.
+func coredumpExpandPattern(pattern string, values map[byte]string) string {
+	var buf strings.Builder
+
+	for i := 0; i < len(pattern); i++ {
+		if pattern[i] != '%' {
+			buf.WriteByte(pattern[i])
+			continue
+		}
+
+		i++
+		if i == len(pattern) {
+			break
+		}
+
+		if pattern[i] == '%' {
+			buf.WriteByte('%')
+			continue
+		}
+
+		// Unknown specifiers are dropped, as done by the kernel
+		buf.WriteString(values[pattern[i]])
+	}
+
+	return buf.String()
+}
+
+// coredumpProcessValues returns the values of the specifiers of a core
+// pattern for a process being dumped, from what the forkcoredump helper is
+// given and /proc.
+func coredumpProcessValues(hostPid string, signal string, timestamp string, command string) (map[byte]string, error) {
+	values := map[byte]string{
+		'P': hostPid,
+		'p': hostPid,
+		'I': hostPid,
+		'i': hostPid,
+		's': signal,
+		't': timestamp,
+		'e': command,
+
+		// Not exposed through /proc, that of a regular dump
+		'd': "1",
+	}
+
+	hostname, err := os.Hostname()
+	if err != nil {
+		return nil, err
+	}
+	values['h'] = hostname
+
+	content, err := ioutil.ReadFile(fmt.Sprintf("/proc/%s/status", hostPid))
+	if err != nil {
+		return nil, err
+	}
+
+	for _, line := range strings.Split(string(content), "\n") {
+		fields := strings.Fields(line)
+		if len(fields) < 2 {
+			continue
+		}
+
+		switch fields[0] {
+		case "Uid:":
+			values['u'] = fields[1]
+		case "Gid:":
+			values['g'] = fields[1]
+		case "NSpid:":
+			values['p'] = fields[len(fields)-1]
+			values['i'] = values['p']
+		}
+	}
+
+	exe, err := os.Readlink(fmt.Sprintf("/proc/%s/exe", hostPid))
+	if err == nil {
+		values['E'] = strings.Replace(exe, "/", "!", -1)
+	}
+
+	values['c'] = "0"
+	limits, err := ioutil.ReadFile(fmt.Sprintf("/proc/%s/limits", hostPid))
+	if err == nil {
+		values['c'] = coredumpParseCoreLimit(string(limits))
+	}
+
+	return values, nil
+}
+
+// coredumpParseCoreLimit returns the soft RLIMIT_CORE of a process, as passed
+// for the %c specifier, given the content of /proc/<pid>/limits.
+func coredumpParseCoreLimit(limits string) string {
+	for _, line := range strings.Split(limits, "\n") {
+		if !strings.HasPrefix(line, "Max core file size") {
+			continue
+		}
+
+		fields := strings.Fields(strings.TrimPrefix(line, "Max core file size"))
+		if len(fields) == 0 {
+			break
+		}
+
+		if fields[0] == "unlimited" {
+			return strconv.FormatUint(^uint64(0), 10)
+		}
+
+		return fields[0]
+	}
+
+	return "0"
+}
+
+// coredumpPassOn hands the core dump of a process which doesn't belong to a
+// container to the core pattern of the host from before the capture was
+// enabled, either piping it to a helper or writing it to a file as the
+// kernel would have.
+func coredumpPassOn(varDir string, values map[byte]string, r io.Reader) error {
+	pattern, err := coredumpPatternSaved(varDir)
+	if err != nil {
+		return err
+	}
+
+	// Never loop back to LXD
+	if strings.Contains(pattern, " forkcoredump ") {
+		return nil
+	}
+
+	if strings.HasPrefix(pattern, "|") {
+		// Split before expanding, as done by the kernel, so that the
+		// values controlled by the process can't add arguments
+		args := strings.Fields(strings.TrimPrefix(pattern, "|"))
+		if len(args) == 0 {
+			return nil
+		}
+
+		for i := range args {
+			args[i] = coredumpExpandPattern(args[i], values)
+		}
+
+		cmd := exec.Command(args[0], args[1:]...)
+		cmd.Dir = "/"
+		cmd.Stdin = r
+		return cmd.Run()
+	}
+
+	limit, err := strconv.ParseUint(values['c'], 10, 64)
+	if err != nil {
+		return err
+	}
+
+	// Nothing is dumped to files past a core file size of zero
+	if limit == 0 || pattern == "" {
+		return nil
+	}
+
+	// The kernel writes the dumps of setuid processes as root then, which
+	// can't be emulated safely with the credentials of the process
+	content, err := ioutil.ReadFile("/proc/sys/fs/suid_dumpable")
+	if err != nil {
+		return err
+	}
+
+	if strings.TrimSpace(string(content)) == "2" {
+		return fmt.Errorf("Core dumps aren't written to files with fs.suid_dumpable set to 2")
+	}
+
+	path := coredumpExpandPattern(pattern, values)
+	if !strings.Contains(pattern, "%p") {
+		content, err := ioutil.ReadFile("/proc/sys/kernel/core_uses_pid")
+		if err == nil && strings.TrimSpace(string(content)) != "0" {
+			path = fmt.Sprintf("%s.%s", path, values['p'])
+		}
+	}
+
+	uid, err := strconv.ParseUint(values['u'], 10, 32)
+	if err != nil {
+		return err
+	}
+
+	gid, err := strconv.ParseUint(values['g'], 10, 32)
+	if err != nil {
+		return err
+	}
+
+	// The file is written with the credentials of the process, relative
+	// to its working directory
+	var out io.Reader = r
+	if limit < uint64(math.MaxInt64) {
+		out = io.LimitReader(r, int64(limit))
+	}
+
+	cmd := exec.Command("dd", fmt.Sprintf("of=%s", path), "status=none")
+	cmd.Dir = fmt.Sprintf("/proc/%s/cwd", values['P'])
+	cmd.Stdin = out
+	cmd.SysProcAttr = &syscall.SysProcAttr{Credential: &syscall.Credential{Uid: uint32(uid), Gid: uint32(gid)}}
+	return cmd.Run()
+}
+
+// coredumpParsePPid returns the parent pid of a process, given the content
+// of /proc/<pid>/status.
+func coredumpParsePPid(status string) (int64, error) {
+	for _, line := range strings.Split(status, "\n") {
+		fields := strings.SplitN(line, ":", 2)
+		if len(fields) == 2 && fields[0] == "PPid" {
+			return strconv.ParseInt(strings.TrimSpace(fields[1]), 10, 64)
+		}
+	}
+
+	return -1, fmt.Errorf("No PPid field")
+}
+
+// coredumpFindContainer returns the running container a process belongs to,
+// walking up its ancestors until reaching the init process of a container,
+// along with the pid of the process in the container.
+func coredumpFindContainer(s *state.State, hostPid int64) (container, int64, error) {
+	cts, err := containerLoadNodeAll(s)
+	if err != nil {
+		return nil, -1, err
+	}
+
+	inits := map[int64]container{}
+	for _, c := range cts {
+		if c.IsRunning() {
+			inits[int64(c.InitPID())] = c
+		}
+	}
+
+	pid := hostPid
+	for pid > 1 {
+		c, ok := inits[pid]
+		if ok {
+			containerPid, err := coredumpContainerPid(hostPid, pid)
+			if err != nil {
+				return nil, -1, err
+			}
+
+			return c, containerPid, nil
+		}
+
+		content, err := ioutil.ReadFile(fmt.Sprintf("/proc/%d/status", pid))
+		if err != nil {
+			return nil, -1, err
+		}
+
+		pid, err = coredumpParsePPid(string(content))
+		if err != nil {
+			return nil, -1, err
+		}
+	}
+
+	return nil, -1, fmt.Errorf("Process %d doesn't belong to a container", hostPid)
+}
+
+// coredumpContainerPid returns the pid of a process in the pid namespace of
+// the container whose init process is given.
+func coredumpContainerPid(hostPid int64, initPid int64) (int64, error) {
+	nspids := map[int64][]int64{}
+	for _, pid := range []int64{hostPid, initPid} {
+		content, err := ioutil.ReadFile(fmt.Sprintf("/proc/%d/status", pid))
+		if err != nil {
+			return -1, err
+		}
+
+		nspids[pid], err = pidMapParseNSpid(string(content))
+		if err != nil {
+			return -1, err
+		}
+	}
+
+	level := len(nspids[initPid]) - 1
+	if level >= len(nspids[hostPid]) {
+		return -1, fmt.Errorf("Process %d isn't in the pid namespace of the container", hostPid)
+	}
+
+	return nspids[hostPid][level], nil
+}
+
+// coredumpLimits returns the maximum number and total size of the core
+// dumps kept for a container.
+func coredumpLimits(config map[string]string) (int, int64, error) {
+	retention := 10
+	if config["coredumps.retention"] != "" {
+		value, err := strconv.Atoi(config["coredumps.retention"])
+		if err != nil {
+			return -1, -1, err
+		}
+
+		retention = value
+	}
+
+	sizeLimit := int64(1024 * 1024 * 1024)
+	if config["coredumps.size_limit"] != "" {
+		value, err := shared.ParseByteSizeString(config["coredumps.size_limit"])
+		if err != nil {
+			return -1, -1, err
+		}
+
+		sizeLimit = value
+	}
+
+	return retention, sizeLimit, nil
+}
+
+// coredumpList returns the core dumps stored in a directory, newest first.
+func coredumpList(dir string) ([]api.ContainerCoredump, error) {
+	dumps := []api.ContainerCoredump{}
+
+	entries, err := ioutil.ReadDir(dir)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return dumps, nil
+		}
+
+		return nil, err
+	}
+
+	for _, entry := range entries {
+		if !strings.HasSuffix(entry.Name(), ".json") {
+			continue
+		}
+
+		content, err := ioutil.ReadFile(filepath.Join(dir, entry.Name()))
+		if err != nil {
+			return nil, err
+		}
+
+		dump := api.ContainerCoredump{}
+		err = json.Unmarshal(content, &dump)
+		if err != nil {
+			return nil, err
+		}
+
+		dumps = append(dumps, dump)
+	}
+
+	sort.SliceStable(dumps, func(i, j int) bool {
+		if dumps[i].Timestamp.Equal(dumps[j].Timestamp) {
+			return dumps[i].ID > dumps[j].ID
+		}
+
+		return dumps[i].Timestamp.After(dumps[j].Timestamp)
+	})
+
+	return dumps, nil
+}
+
+// coredumpPrune returns the core dumps exceeding the retention limits, given
+// the dumps sorted newest first.
+func coredumpPrune(dumps []api.ContainerCoredump, retention int, sizeLimit int64) []api.ContainerCoredump {
+	total := int64(0)
+	for i, dump := range dumps {
+		total += dump.Size
+		if i >= retention || total > sizeLimit {
+			return dumps[i:]
+		}
+	}
+
+	return nil
+}
+
+// coredumpRemove deletes a core dump along with its metadata.
+func coredumpRemove(dir string, id string) error {
+	err := os.Remove(filepath.Join(dir, id+".core.gz"))
+	if err != nil && !os.IsNotExist(err) {
+		return err
+	}
+
+	return os.Remove(filepath.Join(dir, id+".json"))
+}
+
+// coredumpSizeWriter fails writes once more than limit bytes were written.
+type coredumpSizeWriter struct {
+	w     io.Writer
+	size  int64
+	limit int64
+}
+
+func (w *coredumpSizeWriter) Write(p []byte) (int, error) {
+	w.size += int64(len(p))
+	if w.size > w.limit {
+		return 0, fmt.Errorf("The compressed core dump exceeds %d bytes", w.limit)
+	}
+
+	return w.w.Write(p)
+}
+
+// coredumpStore compresses a core dump into a directory, then removes the
+// oldest dumps exceeding the retention limits.
+func coredumpStore(dir string, dump api.ContainerCoredump, r io.Reader, retention int, sizeLimit int64) (api.ContainerCoredump, error) {
+	coredumpLock.Lock()
+	defer coredumpLock.Unlock()
+
+	err := os.MkdirAll(dir, 0700)
+	if err != nil {
+		return dump, err
+	}
+
+	path := filepath.Join(dir, dump.ID+".core.gz")
+	f, err := os.OpenFile(path+".tmp", os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0600)
+	if err != nil {
+		return dump, err
+	}
+	defer os.Remove(path + ".tmp")
+	defer f.Close()
+
+	counter := &coredumpSizeWriter{w: f, limit: sizeLimit}
+	gz := gzip.NewWriter(counter)
+	_, err = io.Copy(gz, r)
+	if err != nil {
+		return dump, err
+	}
+
+	err = gz.Close()
+	if err != nil {
+		return dump, err
+	}
+
+	err = f.Close()
+	if err != nil {
+		return dump, err
+	}
+	dump.Size = counter.size
+
+	err = os.Rename(path+".tmp", path)
+	if err != nil {
+		return dump, err
+	}
+
+	data, err := json.Marshal(dump)
+	if err != nil {
+		return dump, err
+	}
+
+	err = ioutil.WriteFile(filepath.Join(dir, dump.ID+".json"), data, 0600)
+	if err != nil {
+		os.Remove(path)
+		return dump, err
+	}
+
+	dumps, err := coredumpList(dir)
+	if err != nil {
+		return dump, err
+	}
+
+	for _, old := range coredumpPrune(dumps, retention, sizeLimit) {
+		err := coredumpRemove(dir, old.ID)
+		if err != nil {
+			return dump, err
+		}
+	}
+
+	return dump, nil
+}
+
+// internalCoredumpsGet tells the forkcoredump helper whether a process belongs
+// to a container, the dumps of the other processes being passed on to the
+// previous core pattern.
+func internalCoredumpsGet(d *Daemon, r *http.Request) Response {
+	hostPid, err := strconv.ParseInt(r.FormValue("pid"), 10, 64)
+	if err != nil {
+		return BadRequest(fmt.Errorf("Invalid pid: %v", err))
+	}
+
+	_, _, err = coredumpFindContainer(d.State(), hostPid)
+	if err != nil {
+		return NotFound(err)
+	}
+
+	return EmptySyncResponse
+}
+
+func internalCoredumpsPost(d *Daemon, r *http.Request) Response {
+	// Drain the dump on failure, the kernel waits for the helper to read it
+	defer io.Copy(ioutil.Discard, r.Body)
+
+	hostPid, err := strconv.ParseInt(r.FormValue("pid"), 10, 64)
+	if err != nil {
+		return BadRequest(fmt.Errorf("Invalid pid: %v", err))
+	}
+
+	signal, err := strconv.Atoi(r.FormValue("signal"))
+	if err != nil {
+		return BadRequest(fmt.Errorf("Invalid signal: %v", err))
+	}
+
+	timestamp, err := strconv.ParseInt(r.FormValue("timestamp"), 10, 64)
+	if err != nil {
+		return BadRequest(fmt.Errorf("Invalid timestamp: %v", err))
+	}
+
+	c, pid, err := coredumpFindContainer(d.State(), hostPid)
+	if err != nil {
+		return NotFound(err)
+	}
+
+	retention, sizeLimit, err := coredumpLimits(c.ExpandedConfig())
+	if err != nil {
+		return InternalError(err)
+	}
+
+	if retention == 0 {
+		return EmptySyncResponse
+	}
+
+	dump := api.ContainerCoredump{
+		ID:        fmt.Sprintf("%d-%d", timestamp, pid),
+		PID:       pid,
+		HostPID:   hostPid,
+		Signal:    signal,
+		Command:   r.FormValue("command"),
+		Timestamp: time.Unix(timestamp, 0).UTC(),
+	}
+
+	dump, err = coredumpStore(filepath.Join(c.Path(), "coredumps"), dump, r.Body, retention, sizeLimit)
+	if err != nil {
+		logger.Error("Failed to store core dump", log.Ctx{"container": c.Name(), "pid": pid, "err": err})
+		return InternalError(err)
+	}
+
+	logger.Info("Stored core dump", log.Ctx{"container": c.Name(), "pid": pid, "signal": signal, "command": dump.Command, "size": dump.Size})
+	return EmptySyncResponse
+}
+
+// containerCoredumpsLoad loads the container targeted by the request, the
+// boolean being true if its storage had to be started.
+func containerCoredumpsLoad(d *Daemon, r *http.Request) (container, bool, Response) {
+	project := projectParam(r)
+	name := mux.Vars(r)["name"]
+
+	// Core dumps are stored with the container
+	response, err := ForwardedResponseIfContainerIsRemote(d, r, project, name)
+	if err != nil {
+		return nil, false, SmartError(err)
+	}
+	if response != nil {
+		return nil, false, response
+	}
+
+	c, err := containerLoadByProjectAndName(d.State(), project, name)
+	if err != nil {
+		return nil, false, SmartError(err)
+	}
+
+	ourStart, err := c.StorageStart()
+	if err != nil {
+		return nil, false, InternalError(err)
+	}
+
+	return c, ourStart, nil
+}
+
+func containerCoredumpsGet(d *Daemon, r *http.Request) Response {
+	c, ourStart, resp := containerCoredumpsLoad(d, r)
+	if resp != nil {
+		return resp
+	}
+	if ourStart {
+		defer c.StorageStop()
+	}
+
+	dumps, err := coredumpList(filepath.Join(c.Path(), "coredumps"))
+	if err != nil {
+		return InternalError(err)
+	}
+
+	return SyncResponse(true, dumps)
+}
+
+func containerCoredumpGet(d *Daemon, r *http.Request) Response {
+	id := mux.Vars(r)["id"]
+	if !coredumpIDRegexp.MatchString(id) {
+		return BadRequest(fmt.Errorf("Invalid core dump ID '%s'", id))
+	}
+
+	c, ourStart, resp := containerCoredumpsLoad(d, r)
+	if resp != nil {
+		return resp
+	}
+
+	ent := fileResponseEntry{
+		path:     filepath.Join(c.Path(), "coredumps", id+".core.gz"),
+		filename: id + ".core.gz",
+	}
+
+	if !shared.PathExists(ent.path) {
+		if ourStart {
+			c.StorageStop()
+		}
+
+		return NotFound(fmt.Errorf("No core dump '%s' in the container", id))
+	}
+
+	if !ourStart {
+		return FileResponse(r, []fileResponseEntry{ent}, nil, false)
+	}
+
+	// The storage is stopped before the response is sent, serve a copy
+	defer c.StorageStop()
+
+	temp, err := ioutil.TempFile("", "lxd_coredump_")
+	if err != nil {
+		return InternalError(err)
+	}
+	defer temp.Close()
+
+	src, err := os.Open(ent.path)
+	if err != nil {
+		os.Remove(temp.Name())
+		return InternalError(err)
+	}
+	defer src.Close()
+
+	_, err = io.Copy(temp, src)
+	if err != nil {
+		os.Remove(temp.Name())
+		return InternalError(err)
+	}
+
+	ent.path = temp.Name()
+	return FileResponse(r, []fileResponseEntry{ent}, nil, true)
+}
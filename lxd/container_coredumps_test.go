@@ -0,0 +1,147 @@
+package main
+
+import (
+	"bytes"
+	"compress/gzip"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/lxc/lxd/shared/api"
+)
+
+func TestCoredumpPattern(t *testing.T) {
+	pattern, err := coredumpPattern("/usr/bin/lxd", "/var/lib/lxd")
+	require.NoError(t, err)
+	assert.Equal(t, "|/usr/bin/lxd forkcoredump /var/lib/lxd %P %s %t %e", pattern)
+
+	_, err = coredumpPattern("/usr/bin/lxd", "/"+strings.Repeat("a", 120))
+	assert.Error(t, err)
+}
+
+func TestCoredumpParsePPid(t *testing.T) {
+	ppid, err := coredumpParsePPid("Name:\tnginx\nPid:\t4321\nPPid:\t4300\n")
+	require.NoError(t, err)
+	assert.Equal(t, int64(4300), ppid)
+
+	_, err = coredumpParsePPid("Name:\tnginx\n")
+	assert.Error(t, err)
+}
+
+func TestCoredumpLimits(t *testing.T) {
+	retention, sizeLimit, err := coredumpLimits(map[string]string{})
+	require.NoError(t, err)
+	assert.Equal(t, 10, retention)
+	assert.Equal(t, int64(1024*1024*1024), sizeLimit)
+
+	retention, sizeLimit, err = coredumpLimits(map[string]string{"coredumps.retention": "3", "coredumps.size_limit": "10MB"})
+	require.NoError(t, err)
+	assert.Equal(t, 3, retention)
+	assert.Equal(t, int64(10*1000*1000), sizeLimit)
+}
+
+func TestCoredumpPrune(t *testing.T) {
+	dumps := []api.ContainerCoredump{
+		{ID: "3-1", Size: 10},
+		{ID: "2-1", Size: 10},
+		{ID: "1-1", Size: 10},
+	}
+
+	assert.Len(t, coredumpPrune(dumps, 10, 100), 0)
+	assert.Equal(t, dumps[2:], coredumpPrune(dumps, 2, 100))
+	assert.Equal(t, dumps[1:], coredumpPrune(dumps, 10, 15))
+}
+
+func TestCoredumpStore(t *testing.T) {
+	dir, err := ioutil.TempDir("", "lxd_coredumps_")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	for i := int64(1); i <= 3; i++ {
+		dump := api.ContainerCoredump{ID: strings.Repeat("1", int(i)) + "-42", PID: 42, Timestamp: time.Unix(i, 0).UTC()}
+		_, err := coredumpStore(dir, dump, bytes.NewReader([]byte("core")), 2, 1024)
+		require.NoError(t, err)
+	}
+
+	dumps, err := coredumpList(dir)
+	require.NoError(t, err)
+	require.Len(t, dumps, 2)
+	assert.Equal(t, "111-42", dumps[0].ID)
+	assert.Equal(t, "11-42", dumps[1].ID)
+	assert.False(t, dumps[0].Size == 0)
+
+	f, err := os.Open(filepath.Join(dir, "111-42.core.gz"))
+	require.NoError(t, err)
+	defer f.Close()
+
+	gz, err := gzip.NewReader(f)
+	require.NoError(t, err)
+
+	content, err := ioutil.ReadAll(gz)
+	require.NoError(t, err)
+	assert.Equal(t, "core", string(content))
+
+	_, err = os.Stat(filepath.Join(dir, "1-42.json"))
+	assert.True(t, os.IsNotExist(err))
+
+	dump := api.ContainerCoredump{ID: "4-42", Timestamp: time.Unix(4, 0).UTC()}
+	_, err = coredumpStore(dir, dump, bytes.NewReader(bytes.Repeat([]byte("x"), 4096)), 2, 10)
+	assert.Error(t, err)
+}
+
+func TestCoredumpExpandPattern(t *testing.T) {
+	values := map[byte]string{'p': "42", 'P': "4242", 'e': "nginx", 'E': "!usr!sbin!nginx"}
+
+	assert.Equal(t, "/var/crash/core.nginx.42", coredumpExpandPattern("/var/crash/core.%e.%p", values))
+	assert.Equal(t, "/usr/share/apport/apport 4242 !usr!sbin!nginx", coredumpExpandPattern("/usr/share/apport/apport %P %E", values))
+	assert.Equal(t, "core.% .", coredumpExpandPattern("core.%% %z.%", values))
+}
+
+func TestCoredumpParseCoreLimit(t *testing.T) {
+	limits := "Limit                     Soft Limit           Hard Limit           Units\nMax core file size        0                    unlimited            bytes\n"
+	assert.Equal(t, "0", coredumpParseCoreLimit(limits))
+
+	limits = "Max core file size        unlimited            unlimited            bytes\n"
+	assert.Equal(t, "18446744073709551615", coredumpParseCoreLimit(limits))
+}
+
+func TestCoredumpPassOn(t *testing.T) {
+	dir, err := ioutil.TempDir("", "lxd-coredumps-")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	cp, err := exec.LookPath("cp")
+	if err != nil {
+		t.Skip("cp not available")
+	}
+
+	pattern := fmt.Sprintf("|%s /dev/stdin %s/core.%%e.%%P", cp, dir)
+	require.NoError(t, ioutil.WriteFile(filepath.Join(dir, "coredump_pattern"), []byte(pattern), 0600))
+
+	values := map[byte]string{'P': "4242", 'e': "nginx"}
+	require.NoError(t, coredumpPassOn(dir, values, strings.NewReader("core")))
+
+	content, err := ioutil.ReadFile(filepath.Join(dir, "core.nginx.4242"))
+	require.NoError(t, err)
+	assert.Equal(t, "core", string(content))
+
+	// Spaces in the values don't add arguments
+	values['e'] = "nginx extra"
+	require.NoError(t, coredumpPassOn(dir, values, strings.NewReader("core")))
+
+	content, err = ioutil.ReadFile(filepath.Join(dir, "core.nginx extra.4242"))
+	require.NoError(t, err)
+	assert.Equal(t, "core", string(content))
+
+	// Back to LXD
+	require.NoError(t, ioutil.WriteFile(filepath.Join(dir, "coredump_pattern"), []byte("|/usr/bin/lxd forkcoredump /var/lib/lxd %P %s %t %e"), 0600))
+	assert.NoError(t, coredumpPassOn(dir, values, strings.NewReader("core")))
+}
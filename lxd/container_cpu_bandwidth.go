@@ -0,0 +1,94 @@
+package main
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// containerCPUBandwidth returns the CFS quota and period (in microseconds) of
+// a container, a quota of -1 meaning no limit.
+//
+// A time based limits.cpu.allowance gives them directly. Otherwise, with
+// limits.cpu.period set, the container gets as many periods worth of CPU time
+// as it's allowed CPUs in limits.cpu.
+func containerCPUBandwidth(config map[string]string) (int64, int64, error) {
+	allowance := config["limits.cpu.allowance"]
+	if allowance != "" && !strings.HasSuffix(allowance, "%") {
+		_, quota, period, err := deviceParseCPU(allowance, "")
+		if err != nil {
+			return -1, -1, err
+		}
+
+		quotaInt, err := strconv.ParseInt(quota, 10, 64)
+		if err != nil {
+			return -1, -1, err
+		}
+
+		periodInt, err := strconv.ParseInt(period, 10, 64)
+		if err != nil {
+			return -1, -1, err
+		}
+
+		return quotaInt, periodInt, nil
+	}
+
+	if config["limits.cpu.period"] == "" {
+		return -1, 100000, nil
+	}
+
+	duration, err := time.ParseDuration(config["limits.cpu.period"])
+	if err != nil {
+		return -1, -1, err
+	}
+	period := int64(duration / time.Microsecond)
+
+	cpus := config["limits.cpu"]
+	if cpus == "" {
+		return -1, period, nil
+	}
+
+	count, err := strconv.Atoi(cpus)
+	if err != nil {
+		// Pinned to a set of CPUs
+		set, err := parseCpuset(cpus)
+		if err != nil {
+			return -1, -1, err
+		}
+
+		count = len(set)
+	}
+
+	return int64(count) * period, period, nil
+}
+
+// containerCPUMax formats a CFS quota and period as expected by the cgroup2
+// cpu.max file.
+func containerCPUMax(quota int64, period int64) string {
+	if quota < 0 {
+		return fmt.Sprintf("max %d", period)
+	}
+
+	return fmt.Sprintf("%d %d", quota, period)
+}
+
+// setCPUBandwidth applies the CFS quota and period of the running container,
+// through cpu.max on the unified hierarchy.
+func (c *containerLXC) setCPUBandwidth() error {
+	quota, period, err := containerCPUBandwidth(c.expandedConfig)
+	if err != nil {
+		return err
+	}
+
+	if c.state.OS.CGroupCPUUnified {
+		return c.CGroupSet("cpu.max", containerCPUMax(quota, period))
+	}
+
+	err = c.CGroupSet("cpu.cfs_period_us", fmt.Sprintf("%d", period))
+	if err != nil {
+		return err
+	}
+
+	return c.CGroupSet("cpu.cfs_quota_us", fmt.Sprintf("%d", quota))
+}
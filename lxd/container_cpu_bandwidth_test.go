@@ -0,0 +1,40 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestContainerCPUBandwidth(t *testing.T) {
+	cases := []struct {
+		config map[string]string
+		quota  int64
+		period int64
+	}{
+		{map[string]string{}, -1, 100000},
+		{map[string]string{"limits.cpu": "2"}, -1, 100000},
+		{map[string]string{"limits.cpu.allowance": "50%"}, -1, 100000},
+		{map[string]string{"limits.cpu.allowance": "25ms/100ms"}, 25000, 100000},
+		{map[string]string{"limits.cpu.period": "100ms"}, -1, 100000},
+		{map[string]string{"limits.cpu": "2", "limits.cpu.period": "100ms"}, 200000, 100000},
+		{map[string]string{"limits.cpu": "0-3", "limits.cpu.period": "50ms"}, 200000, 50000},
+		{map[string]string{"limits.cpu": "1,3", "limits.cpu.period": "10ms", "limits.cpu.allowance": "50%"}, 20000, 10000},
+	}
+
+	for _, c := range cases {
+		quota, period, err := containerCPUBandwidth(c.config)
+		require.NoError(t, err)
+		assert.Equal(t, c.quota, quota, "%v", c.config)
+		assert.Equal(t, c.period, period, "%v", c.config)
+	}
+
+	_, _, err := containerCPUBandwidth(map[string]string{"limits.cpu": "2", "limits.cpu.period": "foo"})
+	assert.Error(t, err)
+}
+
+func TestContainerCPUMax(t *testing.T) {
+	assert.Equal(t, "max 100000", containerCPUMax(-1, 100000))
+	assert.Equal(t, "200000 100000", containerCPUMax(200000, 100000))
+}
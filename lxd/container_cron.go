@@ -0,0 +1,423 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gorilla/mux"
+	"github.com/pkg/errors"
+	"gopkg.in/robfig/cron.v2"
+
+	"github.com/lxc/lxd/lxd/db"
+	"github.com/lxc/lxd/lxd/task"
+	"github.com/lxc/lxd/lxd/util"
+	"github.com/lxc/lxd/shared"
+	"github.com/lxc/lxd/shared/api"
+	"github.com/lxc/lxd/shared/logger"
+	"github.com/lxc/lxd/shared/version"
+
+	log "github.com/lxc/lxd/shared/log15"
+)
+
+var containerCronJobsCmd = Command{
+	name: "containers/{name}/cron",
+	get:  containerCronJobsGet,
+	post: containerCronJobsPost,
+}
+
+var containerCronJobCmd = Command{
+	name:   "containers/{name}/cron/{id}",
+	get:    containerCronJobGet,
+	delete: containerCronJobDelete,
+}
+
+var containerCronJobRunsCmd = Command{
+	name: "containers/{name}/cron/{id}/runs",
+	get:  containerCronJobRunsGet,
+}
+
+// Number of runs kept for each scheduled command
+const cronRunsKept = 50
+
+// Maximum size of the captured output of a run, only the end of longer
+// outputs being kept
+const cronMaxOutputBytes = 64 * 1024
+
+// cronRunning tracks the scheduled commands being run, so that a command
+// still running when it's due again isn't run twice.
+var cronRunning = map[int64]bool{}
+var cronRunningLock sync.Mutex
+
+// cronValidateSchedule checks a cron expression, using the same format as
+// the snapshots.schedule key.
+func cronValidateSchedule(schedule string) error {
+	if len(strings.Fields(schedule)) != 5 {
+		return fmt.Errorf("Schedule must be of the form: <minute> <hour> <day-of-month> <month> <day-of-week>")
+	}
+
+	_, err := cron.Parse(fmt.Sprintf("* %s", schedule))
+	if err != nil {
+		return errors.Wrap(err, "Error parsing schedule")
+	}
+
+	return nil
+}
+
+// cronScheduleDue returns whether a cron expression matches the minute of
+// the given time.
+func cronScheduleDue(schedule string, now time.Time) bool {
+	// Extend our schedule to one that is accepted by the used cron parser
+	sched, err := cron.Parse(fmt.Sprintf("* %s", schedule))
+	if err != nil {
+		return false
+	}
+
+	// Ignore everything that is more precise than minutes.
+	next := sched.Next(now).Truncate(time.Minute)
+	return now.Truncate(time.Minute).Equal(next)
+}
+
+// cronReadOutput returns the captured output of a run, keeping its last
+// maxBytes bytes.
+func cronReadOutput(f *os.File, maxBytes int64) ([]byte, error) {
+	if f == nil {
+		return []byte{}, nil
+	}
+
+	fi, err := f.Stat()
+	if err != nil {
+		return nil, err
+	}
+
+	offset := int64(0)
+	if fi.Size() > maxBytes {
+		offset = fi.Size() - maxBytes
+	}
+
+	_, err = f.Seek(offset, io.SeekStart)
+	if err != nil {
+		return nil, err
+	}
+
+	return ioutil.ReadAll(f)
+}
+
+func cronJobToAPI(job db.ContainerCronJob) api.ContainerCronJob {
+	return api.ContainerCronJob{
+		ID:            job.ID,
+		Command:       job.Command,
+		Schedule:      job.Schedule,
+		Timeout:       job.Timeout,
+		CaptureOutput: job.CaptureOutput,
+		CreatedAt:     job.CreationDate,
+	}
+}
+
+// cronRun runs a scheduled command and records the result.
+func cronRun(d *Daemon, c container, job db.ContainerCronJob) {
+	run := db.ContainerCronJobRun{
+		JobID:     job.ID,
+		StartDate: time.Now().UTC(),
+		ExitCode:  -1,
+		Stdout:    []byte{},
+		Stderr:    []byte{},
+	}
+
+	err := cronExec(c, job, &run)
+	if err != nil {
+		run.Error = err.Error()
+	}
+	run.EndDate = time.Now().UTC()
+
+	err = d.cluster.ContainerCronJobRunCreate(run, cronRunsKept)
+	if err != nil {
+		logger.Error("Failed to record scheduled command run", log.Ctx{"container": c.Name(), "job": job.ID, "err": err})
+	}
+}
+
+// cronExec runs a scheduled command inside a container, filling the exit
+// code and output of the run.
+func cronExec(c container, job db.ContainerCronJob, run *db.ContainerCronJobRun) error {
+	if !c.IsRunning() {
+		return fmt.Errorf("Container is not running")
+	}
+
+	if c.IsFrozen() {
+		return fmt.Errorf("Container is frozen")
+	}
+
+	var stdout, stderr *os.File
+	if job.CaptureOutput {
+		var err error
+
+		stdout, err = ioutil.TempFile("", "lxd_cron_stdout_")
+		if err != nil {
+			return err
+		}
+		defer os.Remove(stdout.Name())
+		defer stdout.Close()
+
+		stderr, err = ioutil.TempFile("", "lxd_cron_stderr_")
+		if err != nil {
+			return err
+		}
+		defer os.Remove(stderr.Name())
+		defer stderr.Close()
+	}
+
+	post := api.ContainerExecPost{
+		Command: job.Command,
+		Timeout: job.Timeout,
+	}
+
+	exitCode, cmdErr := execRun(c, post, execEnvironment(c, nil), stdout, stderr)
+	run.ExitCode = exitCode
+
+	var err error
+	run.Stdout, err = cronReadOutput(stdout, cronMaxOutputBytes)
+	if err != nil {
+		return err
+	}
+
+	run.Stderr, err = cronReadOutput(stderr, cronMaxOutputBytes)
+	if err != nil {
+		return err
+	}
+
+	return cmdErr
+}
+
+func containerCronTask(d *Daemon) (task.Func, task.Schedule) {
+	f := func(ctx context.Context) {
+		now := time.Now()
+
+		jobs, err := d.cluster.ContainerCronJobs(-1)
+		if err != nil {
+			logger.Error("Failed to load scheduled commands", log.Ctx{"err": err})
+			return
+		}
+
+		if len(jobs) == 0 {
+			return
+		}
+
+		// Only run the commands of the local containers
+		allContainers, err := containerLoadNodeAll(d.State())
+		if err != nil {
+			logger.Error("Failed to load containers for scheduled commands", log.Ctx{"err": err})
+			return
+		}
+
+		containers := map[int]container{}
+		for _, c := range allContainers {
+			containers[c.Id()] = c
+		}
+
+		for _, job := range jobs {
+			c, ok := containers[job.ContainerID]
+			if !ok || !cronScheduleDue(job.Schedule, now) {
+				continue
+			}
+
+			cronRunningLock.Lock()
+			if cronRunning[job.ID] {
+				cronRunningLock.Unlock()
+				logger.Warn("Skipping scheduled command still running", log.Ctx{"container": c.Name(), "job": job.ID})
+				continue
+			}
+			cronRunning[job.ID] = true
+			cronRunningLock.Unlock()
+
+			go func(c container, job db.ContainerCronJob) {
+				defer func() {
+					cronRunningLock.Lock()
+					delete(cronRunning, job.ID)
+					cronRunningLock.Unlock()
+				}()
+
+				cronRun(d, c, job)
+			}(c, job)
+		}
+	}
+
+	first := true
+	schedule := func() (time.Duration, error) {
+		interval := time.Minute
+
+		if first {
+			first = false
+			return interval, task.ErrSkip
+		}
+
+		return interval, nil
+	}
+
+	return f, schedule
+}
+
+// containerCronLoad loads the container targeted by the request, along with
+// the scheduled command if an ID is given.
+func containerCronLoad(d *Daemon, r *http.Request) (container, *db.ContainerCronJob, Response) {
+	project := projectParam(r)
+	name := mux.Vars(r)["name"]
+
+	c, err := containerLoadByProjectAndName(d.State(), project, name)
+	if err != nil {
+		return nil, nil, SmartError(err)
+	}
+
+	if mux.Vars(r)["id"] == "" {
+		return c, nil, nil
+	}
+
+	id, err := strconv.ParseInt(mux.Vars(r)["id"], 10, 64)
+	if err != nil {
+		return nil, nil, BadRequest(fmt.Errorf("Invalid scheduled command ID"))
+	}
+
+	job, err := d.cluster.ContainerCronJobGet(id)
+	if err != nil {
+		return nil, nil, SmartError(err)
+	}
+
+	if job.ContainerID != c.Id() {
+		return nil, nil, NotFound(fmt.Errorf("No scheduled command %d in the container", id))
+	}
+
+	return c, job, nil
+}
+
+func containerCronJobsGet(d *Daemon, r *http.Request) Response {
+	c, _, resp := containerCronLoad(d, r)
+	if resp != nil {
+		return resp
+	}
+
+	jobs, err := d.cluster.ContainerCronJobs(c.Id())
+	if err != nil {
+		return SmartError(err)
+	}
+
+	if util.IsRecursionRequest(r) {
+		result := []api.ContainerCronJob{}
+		for _, job := range jobs {
+			result = append(result, cronJobToAPI(job))
+		}
+
+		return SyncResponse(true, result)
+	}
+
+	result := []string{}
+	for _, job := range jobs {
+		result = append(result, fmt.Sprintf("/%s/containers/%s/cron/%d", version.APIVersion, c.Name(), job.ID))
+	}
+
+	return SyncResponse(true, result)
+}
+
+func containerCronJobsPost(d *Daemon, r *http.Request) Response {
+	c, _, resp := containerCronLoad(d, r)
+	if resp != nil {
+		return resp
+	}
+
+	req := api.ContainerCronJobsPost{}
+	err := shared.ReadToJSON(r.Body, &req)
+	if err != nil {
+		return BadRequest(err)
+	}
+
+	if len(req.Command) == 0 {
+		return BadRequest(fmt.Errorf("No command specified"))
+	}
+
+	err = cronValidateSchedule(req.Schedule)
+	if err != nil {
+		return BadRequest(err)
+	}
+
+	if req.Timeout < 0 {
+		return BadRequest(fmt.Errorf("Invalid timeout: %d", req.Timeout))
+	}
+
+	id, err := d.cluster.ContainerCronJobCreate(db.ContainerCronJob{
+		ContainerID:   c.Id(),
+		Command:       req.Command,
+		Schedule:      req.Schedule,
+		Timeout:       req.Timeout,
+		CaptureOutput: req.CaptureOutput,
+		CreationDate:  time.Now().UTC(),
+	})
+	if err != nil {
+		return SmartError(err)
+	}
+
+	return SyncResponseLocation(true, nil, fmt.Sprintf("/%s/containers/%s/cron/%d", version.APIVersion, c.Name(), id))
+}
+
+func containerCronJobGet(d *Daemon, r *http.Request) Response {
+	_, job, resp := containerCronLoad(d, r)
+	if resp != nil {
+		return resp
+	}
+
+	return SyncResponse(true, cronJobToAPI(*job))
+}
+
+func containerCronJobDelete(d *Daemon, r *http.Request) Response {
+	_, job, resp := containerCronLoad(d, r)
+	if resp != nil {
+		return resp
+	}
+
+	err := d.cluster.ContainerCronJobDelete(job.ID)
+	if err != nil {
+		return SmartError(err)
+	}
+
+	return EmptySyncResponse
+}
+
+func containerCronJobRunsGet(d *Daemon, r *http.Request) Response {
+	_, job, resp := containerCronLoad(d, r)
+	if resp != nil {
+		return resp
+	}
+
+	limit := 10
+	if r.FormValue("limit") != "" {
+		value, err := strconv.Atoi(r.FormValue("limit"))
+		if err != nil || value <= 0 {
+			return BadRequest(fmt.Errorf("Invalid limit: %s", r.FormValue("limit")))
+		}
+
+		limit = value
+	}
+
+	runs, err := d.cluster.ContainerCronJobRuns(job.ID, limit)
+	if err != nil {
+		return SmartError(err)
+	}
+
+	result := []api.ContainerCronJobRun{}
+	for _, run := range runs {
+		result = append(result, api.ContainerCronJobRun{
+			StartedAt:  run.StartDate,
+			FinishedAt: run.EndDate,
+			ExitCode:   run.ExitCode,
+			Stdout:     string(run.Stdout),
+			Stderr:     string(run.Stderr),
+			Error:      run.Error,
+		})
+	}
+
+	return SyncResponse(true, result)
+}
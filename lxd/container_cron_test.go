@@ -0,0 +1,49 @@
+package main
+
+import (
+	"io/ioutil"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCronValidateSchedule(t *testing.T) {
+	assert.NoError(t, cronValidateSchedule("0 * * * *"))
+	assert.NoError(t, cronValidateSchedule("*/15 2 * * 1-5"))
+	assert.Error(t, cronValidateSchedule("@hourly"))
+	assert.Error(t, cronValidateSchedule("0 * * *"))
+	assert.Error(t, cronValidateSchedule("61 * * * *"))
+}
+
+func TestCronScheduleDue(t *testing.T) {
+	now := time.Date(2019, 10, 2, 13, 0, 12, 0, time.Local)
+	assert.True(t, cronScheduleDue("0 * * * *", now))
+	assert.True(t, cronScheduleDue("* * * * *", now))
+	assert.False(t, cronScheduleDue("30 * * * *", now))
+	assert.False(t, cronScheduleDue("0 12 * * *", now))
+}
+
+func TestCronReadOutput(t *testing.T) {
+	output, err := cronReadOutput(nil, 10)
+	require.NoError(t, err)
+	assert.Equal(t, []byte{}, output)
+
+	f, err := ioutil.TempFile("", "lxd_cron_")
+	require.NoError(t, err)
+	defer os.Remove(f.Name())
+	defer f.Close()
+
+	_, err = f.Write([]byte("0123456789abcdef"))
+	require.NoError(t, err)
+
+	output, err = cronReadOutput(f, 6)
+	require.NoError(t, err)
+	assert.Equal(t, "abcdef", string(output))
+
+	output, err = cronReadOutput(f, 100)
+	require.NoError(t, err)
+	assert.Equal(t, "0123456789abcdef", string(output))
+}
@@ -6,6 +6,7 @@ import (
 
 	"github.com/gorilla/mux"
 	"github.com/lxc/lxd/lxd/db"
+	"github.com/lxc/lxd/shared/api"
 )
 
 func containerDelete(d *Daemon, r *http.Request) Response {
@@ -27,7 +28,7 @@ func containerDelete(d *Daemon, r *http.Request) Response {
 	}
 
 	if c.IsRunning() {
-		return BadRequest(fmt.Errorf("container is running"))
+		return BadRequest(withErrorCode(api.ErrContainerRunning, fmt.Errorf("container is running")))
 	}
 
 	rmct := func(op *operation) error {
@@ -0,0 +1,170 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/lxc/lxd/lxd/state"
+	"github.com/lxc/lxd/shared"
+	"github.com/lxc/lxd/shared/api"
+	"github.com/lxc/lxd/shared/logger"
+
+	log "github.com/lxc/lxd/shared/log15"
+)
+
+// Defaults of the boot.depends.max_wait, boot.health_check.interval and
+// boot.health_check.timeout keys, in seconds
+const (
+	containerDependsMaxWait      = 300
+	containerHealthCheckInterval = 5
+	containerHealthCheckTimeout  = 10
+)
+
+// containerDependencies returns the names of the containers a container
+// depends on, given its expanded config.
+func containerDependencies(config map[string]string) []string {
+	names := []string{}
+	for _, name := range strings.Split(config["boot.depends"], ",") {
+		name = strings.TrimSpace(name)
+		if name != "" && !shared.StringInSlice(name, names) {
+			names = append(names, name)
+		}
+	}
+
+	return names
+}
+
+// containerConfigSeconds returns the value of a config key counted in
+// seconds, or the given default.
+func containerConfigSeconds(config map[string]string, key string, defaultValue int64) time.Duration {
+	value, err := strconv.ParseInt(config[key], 10, 64)
+	if err != nil || value < 0 {
+		value = defaultValue
+	}
+
+	return time.Duration(value) * time.Second
+}
+
+// containerDependencyOrder reorders a list of containers so that each
+// container comes after the ones of the same project it depends on, keeping
+// the original order otherwise. Dependency cycles are broken arbitrarily.
+func containerDependencyOrder(containers []container) []container {
+	byName := map[string]container{}
+	for _, c := range containers {
+		byName[projectPrefix(c.Project(), c.Name())] = c
+	}
+
+	result := []container{}
+	visited := map[string]bool{}
+
+	var visit func(c container)
+	visit = func(c container) {
+		key := projectPrefix(c.Project(), c.Name())
+		if visited[key] {
+			return
+		}
+		visited[key] = true
+
+		for _, name := range containerDependencies(c.ExpandedConfig()) {
+			dep, ok := byName[projectPrefix(c.Project(), name)]
+			if ok {
+				visit(dep)
+			}
+		}
+
+		result = append(result, c)
+	}
+
+	for _, c := range containers {
+		visit(c)
+	}
+
+	return result
+}
+
+// containerHealthCheck runs the boot.health_check.command of a running
+// container and returns whether it succeeded. Containers without health
+// check are healthy as soon as they run.
+func containerHealthCheck(c container) (bool, error) {
+	if !c.IsRunning() {
+		return false, nil
+	}
+
+	command := c.ExpandedConfig()["boot.health_check.command"]
+	if command == "" {
+		return true, nil
+	}
+
+	devNull, err := os.OpenFile(os.DevNull, os.O_RDWR, 0)
+	if err != nil {
+		return false, err
+	}
+	defer devNull.Close()
+
+	post := api.ContainerExecPost{
+		Command: []string{"/bin/sh", "-c", command},
+		Timeout: int(containerConfigSeconds(c.ExpandedConfig(), "boot.health_check.timeout", containerHealthCheckTimeout) / time.Second),
+	}
+
+	env := map[string]string{
+		"PATH": "/usr/local/sbin:/usr/local/bin:/usr/sbin:/usr/bin:/sbin:/bin",
+		"HOME": "/root",
+	}
+
+	code, err := execRun(c, post, env, devNull, devNull)
+	if err != nil {
+		return false, err
+	}
+
+	return code == 0, nil
+}
+
+// containerWaitDependencies waits for the containers a container depends on
+// to be running and healthy, for up to boot.depends.max_wait seconds.
+func containerWaitDependencies(s *state.State, c container) error {
+	dependencies := containerDependencies(c.ExpandedConfig())
+	if len(dependencies) == 0 {
+		return nil
+	}
+
+	maxWait := containerConfigSeconds(c.ExpandedConfig(), "boot.depends.max_wait", containerDependsMaxWait)
+	deadline := time.Now().Add(maxWait)
+
+	for _, name := range dependencies {
+		if name == c.Name() {
+			continue
+		}
+
+		dep, err := containerLoadByProjectAndName(s, c.Project(), name)
+		if err != nil {
+			return fmt.Errorf("Failed to load dependency '%s': %v", name, err)
+		}
+
+		interval := containerConfigSeconds(dep.ExpandedConfig(), "boot.health_check.interval", containerHealthCheckInterval)
+		if interval < time.Second {
+			interval = time.Second
+		}
+
+		for {
+			healthy, err := containerHealthCheck(dep)
+			if err != nil {
+				logger.Debug("Dependency health check failed", log.Ctx{"container": c.Name(), "dependency": name, "err": err})
+			}
+
+			if healthy {
+				break
+			}
+
+			if time.Now().Add(interval).After(deadline) {
+				return fmt.Errorf("Dependency '%s' wasn't healthy within %s", name, maxWait)
+			}
+
+			time.Sleep(interval)
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,260 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/gorilla/mux"
+	"github.com/pkg/errors"
+
+	"github.com/lxc/lxd/lxd/cluster"
+	"github.com/lxc/lxd/lxd/db"
+	"github.com/lxc/lxd/lxd/task"
+	"github.com/lxc/lxd/shared"
+	"github.com/lxc/lxd/shared/api"
+	"github.com/lxc/lxd/shared/logger"
+
+	log "github.com/lxc/lxd/shared/log15"
+)
+
+var containerDriftCmd = Command{
+	name: "containers/{name}/drift",
+	get:  containerDriftGet,
+	post: containerDriftPost,
+}
+
+// containerDriftIgnored returns whether a config key is managed by LXD for
+// the container itself rather than inherited from the profiles.
+func containerDriftIgnored(key string) bool {
+	return strings.HasPrefix(key, "volatile.") || strings.HasPrefix(key, "image.")
+}
+
+// containerDriftCompute compares the local config of a container with the
+// config of its profiles.
+func containerDriftCompute(local map[string]string, profiles []api.Profile) api.ContainerDrift {
+	drift := api.ContainerDrift{
+		Added:   map[string]string{},
+		Removed: map[string]string{},
+		Changed: map[string]api.ContainerDriftValue{},
+	}
+
+	profileConfig := db.ProfilesExpandConfig(nil, profiles)
+	for k, v := range local {
+		if containerDriftIgnored(k) {
+			continue
+		}
+
+		profileValue, ok := profileConfig[k]
+		if !ok {
+			if v != "" {
+				drift.Added[k] = v
+			}
+
+			continue
+		}
+
+		if v == profileValue {
+			continue
+		}
+
+		if v == "" {
+			drift.Removed[k] = profileValue
+			continue
+		}
+
+		drift.Changed[k] = api.ContainerDriftValue{Profile: profileValue, Container: v}
+	}
+
+	return drift
+}
+
+// containerDriftEmpty returns whether a container has no drift.
+func containerDriftEmpty(drift api.ContainerDrift) bool {
+	return len(drift.Added) == 0 && len(drift.Removed) == 0 && len(drift.Changed) == 0
+}
+
+// containerDriftRemediated returns the local config of a container without
+// any drift from its profiles.
+func containerDriftRemediated(local map[string]string) map[string]string {
+	config := map[string]string{}
+	for k, v := range local {
+		if containerDriftIgnored(k) {
+			config[k] = v
+		}
+	}
+
+	return config
+}
+
+func containerDriftGetForContainer(d *Daemon, c container) (api.ContainerDrift, error) {
+	profiles, err := d.cluster.ProfilesGet(c.Project(), c.Profiles())
+	if err != nil {
+		return api.ContainerDrift{}, err
+	}
+
+	return containerDriftCompute(c.LocalConfig(), profiles), nil
+}
+
+// containerDriftCheck emits an event for each container whose drift from its
+// profiles changed since the previous check.
+func containerDriftCheck(d *Daemon, reported map[string]string) {
+	containers, err := containerLoadNodeAll(d.State())
+	if err != nil {
+		logger.Error("Failed to load containers for drift detection", log.Ctx{"err": err})
+		return
+	}
+
+	seen := map[string]bool{}
+	for _, c := range containers {
+		if c.IsSnapshot() {
+			continue
+		}
+
+		drift, err := containerDriftGetForContainer(d, c)
+		if err != nil {
+			logger.Error("Failed to check container drift", log.Ctx{"container": c.Name(), "project": c.Project(), "err": err})
+			continue
+		}
+
+		if containerDriftEmpty(drift) {
+			continue
+		}
+
+		key := projectPrefix(c.Project(), c.Name())
+		seen[key] = true
+
+		data, err := json.Marshal(drift)
+		if err != nil {
+			continue
+		}
+
+		if reported[key] == string(data) {
+			continue
+		}
+		reported[key] = string(data)
+
+		logger.Info("Container configuration drifted from its profiles", log.Ctx{"container": c.Name(), "project": c.Project()})
+		eventSendLifecycle(c.Project(), "container-drift-detected",
+			fmt.Sprintf("/1.0/containers/%s", c.Name()), map[string]interface{}{
+				"drift": drift,
+			})
+	}
+
+	for key := range reported {
+		if !seen[key] {
+			delete(reported, key)
+		}
+	}
+}
+
+func containerDriftTask(d *Daemon) (task.Func, task.Schedule) {
+	reported := map[string]string{}
+
+	f := func(ctx context.Context) {
+		containerDriftCheck(d, reported)
+	}
+
+	schedule := func() (time.Duration, error) {
+		var interval time.Duration
+		err := d.cluster.Transaction(func(tx *db.ClusterTx) error {
+			config, err := cluster.ConfigLoad(tx)
+			if err != nil {
+				return errors.Wrap(err, "failed to load cluster configuration")
+			}
+			interval = config.ContainersDriftCheckInterval()
+			return nil
+		})
+		if err != nil {
+			return 0, err
+		}
+
+		return interval, nil
+	}
+
+	return f, schedule
+}
+
+// containerDriftLoad loads the container targeted by a drift request.
+func containerDriftLoad(d *Daemon, r *http.Request) (container, Response) {
+	project := projectParam(r)
+	name := mux.Vars(r)["name"]
+
+	// Handle requests targeted to a container on a different node
+	response, err := ForwardedResponseIfContainerIsRemote(d, r, project, name)
+	if err != nil {
+		return nil, SmartError(err)
+	}
+	if response != nil {
+		return nil, response
+	}
+
+	c, err := containerLoadByProjectAndName(d.State(), project, name)
+	if err != nil {
+		return nil, SmartError(err)
+	}
+
+	return c, nil
+}
+
+func containerDriftGet(d *Daemon, r *http.Request) Response {
+	c, resp := containerDriftLoad(d, r)
+	if resp != nil {
+		return resp
+	}
+
+	drift, err := containerDriftGetForContainer(d, c)
+	if err != nil {
+		return SmartError(err)
+	}
+
+	return SyncResponse(true, drift)
+}
+
+func containerDriftPost(d *Daemon, r *http.Request) Response {
+	c, resp := containerDriftLoad(d, r)
+	if resp != nil {
+		return resp
+	}
+
+	req := api.ContainerDriftPost{}
+	err := shared.ReadToJSON(r.Body, &req)
+	if err != nil {
+		return BadRequest(err)
+	}
+
+	drift, err := containerDriftGetForContainer(d, c)
+	if err != nil {
+		return SmartError(err)
+	}
+
+	if !req.Remediate || containerDriftEmpty(drift) {
+		return SyncResponse(true, drift)
+	}
+
+	args := db.ContainerArgs{
+		Architecture: c.Architecture(),
+		Config:       containerDriftRemediated(c.LocalConfig()),
+		Description:  c.Description(),
+		Devices:      c.LocalDevices(),
+		Ephemeral:    c.IsEphemeral(),
+		Profiles:     c.Profiles(),
+		Project:      c.Project(),
+	}
+
+	err = c.Update(args, true)
+	if err != nil {
+		return SmartError(err)
+	}
+
+	eventSendLifecycle(c.Project(), "container-drift-remediated",
+		fmt.Sprintf("/1.0/containers/%s", c.Name()), map[string]interface{}{
+			"drift": drift,
+		})
+
+	// Report the drift which was reverted
+	return SyncResponse(true, drift)
+}
@@ -0,0 +1,48 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/lxc/lxd/shared/api"
+)
+
+func TestContainerDriftCompute(t *testing.T) {
+	profiles := []api.Profile{
+		{ProfilePut: api.ProfilePut{Config: map[string]string{"limits.cpu": "2", "limits.memory": "1GB"}}},
+		{ProfilePut: api.ProfilePut{Config: map[string]string{"limits.memory": "2GB", "security.nesting": "true"}}},
+	}
+
+	local := map[string]string{
+		"limits.cpu":                "2",
+		"limits.memory":             "4GB",
+		"security.nesting":          "",
+		"boot.autostart":            "true",
+		"volatile.eth0.hwaddr":      "00:16:3e:00:00:01",
+		"image.os":                  "ubuntu",
+		"volatile.last_state.power": "RUNNING",
+	}
+
+	drift := containerDriftCompute(local, profiles)
+	assert.Equal(t, map[string]string{"boot.autostart": "true"}, drift.Added)
+	assert.Equal(t, map[string]string{"security.nesting": "true"}, drift.Removed)
+	assert.Equal(t, map[string]api.ContainerDriftValue{"limits.memory": {Profile: "2GB", Container: "4GB"}}, drift.Changed)
+	assert.False(t, containerDriftEmpty(drift))
+
+	drift = containerDriftCompute(containerDriftRemediated(local), profiles)
+	assert.True(t, containerDriftEmpty(drift))
+}
+
+func TestContainerDriftRemediated(t *testing.T) {
+	local := map[string]string{
+		"limits.memory":        "4GB",
+		"volatile.eth0.hwaddr": "00:16:3e:00:00:01",
+		"image.os":             "ubuntu",
+	}
+
+	assert.Equal(t, map[string]string{
+		"volatile.eth0.hwaddr": "00:16:3e:00:00:01",
+		"image.os":             "ubuntu",
+	}, containerDriftRemediated(local))
+}
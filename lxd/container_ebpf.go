@@ -0,0 +1,461 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/cilium/ebpf"
+	"github.com/cilium/ebpf/link"
+	"github.com/gorilla/mux"
+
+	"github.com/lxc/lxd/shared"
+	"github.com/lxc/lxd/shared/api"
+	"github.com/lxc/lxd/shared/logger"
+	"github.com/lxc/lxd/shared/version"
+
+	log "github.com/lxc/lxd/shared/log15"
+)
+
+var containerEbpfCmd = Command{
+	name: "containers/{name}/ebpf",
+	get:  containerEbpfGet,
+	post: containerEbpfPost,
+}
+
+var containerEbpfProgramCmd = Command{
+	name:   "containers/{name}/ebpf/{id}",
+	get:    containerEbpfProgramGet,
+	delete: containerEbpfProgramDelete,
+}
+
+// Maximum size of the uploaded eBPF object files
+const ebpfMaxObjectSize = 64 * 1024 * 1024
+
+// Capabilities allowing to load eBPF programs, CAP_SYS_ADMIN being used by
+// kernels older than 5.8
+const (
+	capSysAdmin = 21
+	capBPF      = 39
+)
+
+// ebpfHookProgramTypes lists the supported hooks and the type of the programs
+// which can be attached to them.
+var ebpfHookProgramTypes = map[string]ebpf.ProgramType{
+	"kprobe":     ebpf.Kprobe,
+	"uprobe":     ebpf.Kprobe,
+	"cgroup/skb": ebpf.CGroupSKB,
+}
+
+// ebpfProgram is an eBPF program loaded into a container.
+type ebpfProgram struct {
+	info       api.ContainerEbpfProgram
+	collection *ebpf.Collection
+	link       link.Link
+}
+
+func (p *ebpfProgram) unload() error {
+	err := p.link.Close()
+	p.collection.Close()
+	return err
+}
+
+// ebpfRegistry tracks the eBPF programs loaded into the containers. Programs
+// are detached when LXD exits, along with its file descriptors.
+type ebpfRegistry struct {
+	mu       sync.Mutex
+	programs map[string]map[uint32]*ebpfProgram
+}
+
+var ebpfPrograms = &ebpfRegistry{programs: map[string]map[uint32]*ebpfProgram{}}
+
+func ebpfContainerKey(c container) string {
+	return projectPrefix(c.Project(), c.Name())
+}
+
+func (r *ebpfRegistry) add(c container, program *ebpfProgram) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	key := ebpfContainerKey(c)
+	if r.programs[key] == nil {
+		r.programs[key] = map[uint32]*ebpfProgram{}
+	}
+
+	r.programs[key][program.info.ID] = program
+}
+
+func (r *ebpfRegistry) list(c container) []api.ContainerEbpfProgram {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	programs := []api.ContainerEbpfProgram{}
+	for _, program := range r.programs[ebpfContainerKey(c)] {
+		programs = append(programs, program.info)
+	}
+
+	sort.Slice(programs, func(i, j int) bool { return programs[i].ID < programs[j].ID })
+	return programs
+}
+
+func (r *ebpfRegistry) get(c container, id uint32) (api.ContainerEbpfProgram, bool) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	program, ok := r.programs[ebpfContainerKey(c)][id]
+	if !ok {
+		return api.ContainerEbpfProgram{}, false
+	}
+
+	return program.info, true
+}
+
+// remove detaches and unloads a program, returning false if it doesn't exist.
+func (r *ebpfRegistry) remove(c container, id uint32) (bool, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	key := ebpfContainerKey(c)
+	program, ok := r.programs[key][id]
+	if !ok {
+		return false, nil
+	}
+
+	delete(r.programs[key], id)
+	if len(r.programs[key]) == 0 {
+		delete(r.programs, key)
+	}
+
+	return true, program.unload()
+}
+
+// removeAll unloads all the programs of a container, once it stopped.
+func (r *ebpfRegistry) removeAll(c container) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	key := ebpfContainerKey(c)
+	for id, program := range r.programs[key] {
+		err := program.unload()
+		if err != nil {
+			logger.Warn("Failed to unload eBPF program", log.Ctx{"container": c.Name(), "id": id, "err": err})
+		}
+	}
+
+	delete(r.programs, key)
+}
+
+// ebpfCheckCapabilities makes sure that LXD is allowed to load eBPF programs.
+func ebpfCheckCapabilities() error {
+	content, err := ioutil.ReadFile("/proc/self/status")
+	if err != nil {
+		return err
+	}
+
+	_, sets, err := capabilitiesParseStatus(string(content))
+	if err != nil {
+		return err
+	}
+
+	if sets["CapEff"]&(1<<capBPF) == 0 && sets["CapEff"]&(1<<capSysAdmin) == 0 {
+		return fmt.Errorf("LXD lacks the CAP_BPF capability required to load eBPF programs")
+	}
+
+	return nil
+}
+
+// ebpfCgroupPath returns the directory of the cgroup2 cgroup of a process.
+func ebpfCgroupPath(pid int) (string, error) {
+	content, err := ioutil.ReadFile(fmt.Sprintf("/proc/%d/cgroup", pid))
+	if err != nil {
+		return "", err
+	}
+
+	path, err := memoryProtectionCGroupPath(string(content))
+	if err != nil {
+		return "", err
+	}
+
+	// Ignore the sub-cgroup the container's init may have moved to
+	path = strings.TrimSuffix(path, "/init.scope")
+
+	for _, root := range []string{"/sys/fs/cgroup/unified", "/sys/fs/cgroup"} {
+		dir := filepath.Join(root, path)
+		if shared.PathExists(filepath.Join(dir, "cgroup.procs")) && shared.PathExists(filepath.Join(root, "cgroup.controllers")) {
+			return dir, nil
+		}
+	}
+
+	return "", fmt.Errorf("The cgroup2 hierarchy isn't mounted")
+}
+
+// ebpfSelectProgram returns the program of a collection to attach, which
+// must be named if the object holds several programs.
+func ebpfSelectProgram(programs map[string]*ebpf.ProgramSpec, name string) (string, error) {
+	if name != "" {
+		_, ok := programs[name]
+		if !ok {
+			return "", fmt.Errorf("No program named '%s' in the object", name)
+		}
+
+		return name, nil
+	}
+
+	if len(programs) != 1 {
+		names := []string{}
+		for programName := range programs {
+			names = append(names, programName)
+		}
+		sort.Strings(names)
+
+		return "", fmt.Errorf("The object holds several programs (%s), one must be selected", strings.Join(names, ", "))
+	}
+
+	for programName := range programs {
+		name = programName
+	}
+
+	return name, nil
+}
+
+// ebpfAttach attaches a program to a hook of a container. Kernel probes apply
+// to the whole host, user probes to a binary of the container and cgroup
+// programs to the cgroup of the container.
+func ebpfAttach(c container, prog *ebpf.Program, hook string, target string) (link.Link, error) {
+	switch hook {
+	case "kprobe":
+		if target == "" {
+			return nil, fmt.Errorf("Missing kernel symbol to probe")
+		}
+
+		return link.Kprobe(target, prog, nil)
+	case "uprobe":
+		fields := strings.SplitN(target, ":", 2)
+		if len(fields) != 2 || !strings.HasPrefix(fields[0], "/") || fields[1] == "" {
+			return nil, fmt.Errorf("Invalid uprobe target '%s', expecting <path>:<symbol>", target)
+		}
+
+		// Follow the symlinks within the rootfs of the container
+		search := &fileSearch{rootfs: fmt.Sprintf("/proc/%d/root", c.InitPID())}
+		path, err := search.resolve(fields[0], 40)
+		if err != nil {
+			return nil, err
+		}
+
+		executable, err := link.OpenExecutable(filepath.Join(search.rootfs, path))
+		if err != nil {
+			return nil, err
+		}
+
+		return executable.Uprobe(fields[1], prog, nil)
+	case "cgroup/skb":
+		attach := ebpf.AttachCGroupInetIngress
+		if target == "egress" {
+			attach = ebpf.AttachCGroupInetEgress
+		} else if target != "" && target != "ingress" {
+			return nil, fmt.Errorf("Invalid cgroup/skb target '%s', expecting ingress or egress", target)
+		}
+
+		path, err := ebpfCgroupPath(c.InitPID())
+		if err != nil {
+			return nil, err
+		}
+
+		return link.AttachCgroup(link.CgroupOptions{Path: path, Attach: attach, Program: prog})
+	}
+
+	return nil, fmt.Errorf("Unsupported hook '%s'", hook)
+}
+
+// ebpfLoad loads the program of an eBPF object file and attaches it.
+func ebpfLoad(c container, object []byte, name string, hook string, target string) (*ebpfProgram, error) {
+	programType, ok := ebpfHookProgramTypes[hook]
+	if !ok {
+		return nil, fmt.Errorf("Unsupported hook '%s'", hook)
+	}
+
+	spec, err := ebpf.LoadCollectionSpecFromReader(bytes.NewReader(object))
+	if err != nil {
+		return nil, fmt.Errorf("Invalid eBPF object: %v", err)
+	}
+
+	name, err = ebpfSelectProgram(spec.Programs, name)
+	if err != nil {
+		return nil, err
+	}
+
+	if spec.Programs[name].Type != programType {
+		return nil, fmt.Errorf("Program '%s' is of type %s, which can't be attached to a %s hook", name, spec.Programs[name].Type, hook)
+	}
+
+	// Relocations of CO-RE objects are applied against the BTF of the
+	// running kernel
+	collection, err := ebpf.NewCollection(spec)
+	if err != nil {
+		return nil, fmt.Errorf("Failed to load eBPF object: %v", err)
+	}
+
+	prog := collection.Programs[name]
+	info, err := prog.Info()
+	if err != nil {
+		collection.Close()
+		return nil, err
+	}
+
+	id, ok := info.ID()
+	if !ok {
+		collection.Close()
+		return nil, fmt.Errorf("The kernel doesn't report the IDs of eBPF programs")
+	}
+
+	l, err := ebpfAttach(c, prog, hook, target)
+	if err != nil {
+		collection.Close()
+		return nil, err
+	}
+
+	program := &ebpfProgram{
+		info: api.ContainerEbpfProgram{
+			ID:       uint32(id),
+			Name:     name,
+			Hook:     hook,
+			Target:   target,
+			Tag:      info.Tag,
+			LoadedAt: time.Now().UTC(),
+		},
+		collection: collection,
+		link:       l,
+	}
+
+	return program, nil
+}
+
+// containerEbpfLoad loads the running container targeted by the request.
+func containerEbpfLoad(d *Daemon, r *http.Request) (container, Response) {
+	project := projectParam(r)
+	name := mux.Vars(r)["name"]
+
+	// Programs live on the node the container runs on
+	response, err := ForwardedResponseIfContainerIsRemote(d, r, project, name)
+	if err != nil {
+		return nil, SmartError(err)
+	}
+	if response != nil {
+		return nil, response
+	}
+
+	c, err := containerLoadByProjectAndName(d.State(), project, name)
+	if err != nil {
+		return nil, SmartError(err)
+	}
+
+	if !c.IsRunning() {
+		return nil, BadRequest(withErrorCode(api.ErrContainerNotRunning, fmt.Errorf("Container is not running")))
+	}
+
+	return c, nil
+}
+
+func containerEbpfGet(d *Daemon, r *http.Request) Response {
+	c, resp := containerEbpfLoad(d, r)
+	if resp != nil {
+		return resp
+	}
+
+	return SyncResponse(true, ebpfPrograms.list(c))
+}
+
+func containerEbpfPost(d *Daemon, r *http.Request) Response {
+	c, resp := containerEbpfLoad(d, r)
+	if resp != nil {
+		return resp
+	}
+
+	err := ebpfCheckCapabilities()
+	if err != nil {
+		return Forbidden(err)
+	}
+
+	hook := r.FormValue("hook")
+	if hook == "" {
+		return BadRequest(fmt.Errorf("Missing hook argument"))
+	}
+
+	object, err := ioutil.ReadAll(io.LimitReader(r.Body, ebpfMaxObjectSize+1))
+	if err != nil {
+		return InternalError(err)
+	}
+
+	if len(object) > ebpfMaxObjectSize {
+		return BadRequest(fmt.Errorf("The eBPF object exceeds %d bytes", ebpfMaxObjectSize))
+	}
+
+	program, err := ebpfLoad(c, object, r.FormValue("program"), hook, r.FormValue("target"))
+	if err != nil {
+		return BadRequest(err)
+	}
+
+	ebpfPrograms.add(c, program)
+
+	url := fmt.Sprintf("/%s/containers/%s/ebpf/%d", version.APIVersion, c.Name(), program.info.ID)
+	return SyncResponseLocation(true, program.info, url)
+}
+
+func containerEbpfProgramID(r *http.Request) (uint32, error) {
+	id, err := strconv.ParseUint(mux.Vars(r)["id"], 10, 32)
+	if err != nil {
+		return 0, fmt.Errorf("Invalid program ID '%s'", mux.Vars(r)["id"])
+	}
+
+	return uint32(id), nil
+}
+
+func containerEbpfProgramGet(d *Daemon, r *http.Request) Response {
+	id, err := containerEbpfProgramID(r)
+	if err != nil {
+		return BadRequest(err)
+	}
+
+	c, resp := containerEbpfLoad(d, r)
+	if resp != nil {
+		return resp
+	}
+
+	program, ok := ebpfPrograms.get(c, id)
+	if !ok {
+		return NotFound(fmt.Errorf("No eBPF program %d in the container", id))
+	}
+
+	return SyncResponse(true, program)
+}
+
+func containerEbpfProgramDelete(d *Daemon, r *http.Request) Response {
+	id, err := containerEbpfProgramID(r)
+	if err != nil {
+		return BadRequest(err)
+	}
+
+	c, resp := containerEbpfLoad(d, r)
+	if resp != nil {
+		return resp
+	}
+
+	found, err := ebpfPrograms.remove(c, id)
+	if !found {
+		return NotFound(fmt.Errorf("No eBPF program %d in the container", id))
+	}
+
+	if err != nil {
+		return InternalError(err)
+	}
+
+	return EmptySyncResponse
+}
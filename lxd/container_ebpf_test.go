@@ -0,0 +1,32 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/cilium/ebpf"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestEbpfSelectProgram(t *testing.T) {
+	single := map[string]*ebpf.ProgramSpec{"trace_open": {Type: ebpf.Kprobe}}
+
+	name, err := ebpfSelectProgram(single, "")
+	require.NoError(t, err)
+	assert.Equal(t, "trace_open", name)
+
+	_, err = ebpfSelectProgram(single, "missing")
+	assert.Error(t, err)
+
+	several := map[string]*ebpf.ProgramSpec{
+		"trace_open":  {Type: ebpf.Kprobe},
+		"filter_drop": {Type: ebpf.CGroupSKB},
+	}
+
+	_, err = ebpfSelectProgram(several, "")
+	assert.EqualError(t, err, "The object holds several programs (filter_drop, trace_open), one must be selected")
+
+	name, err = ebpfSelectProgram(several, "filter_drop")
+	require.NoError(t, err)
+	assert.Equal(t, "filter_drop", name)
+}
@@ -0,0 +1,465 @@
+package main
+
+import (
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gorilla/mux"
+	"github.com/gorilla/websocket"
+
+	"github.com/lxc/lxd/shared"
+	"github.com/lxc/lxd/shared/api"
+	"github.com/lxc/lxd/shared/logger"
+)
+
+const raplPath = "/sys/class/powercap"
+
+// How far back samples are kept to compute the average power
+const energySampleWindow = 5 * time.Second
+
+// How long the energy of a container is tracked for after its last sample
+const energyStateExpiry = 10 * time.Minute
+
+// Clock ticks per second of the CPU times of /proc/stat (USER_HZ)
+const energyUserHZ = 100
+
+var containerEnergyCmd = Command{
+	name: "containers/{name}/energy",
+	get:  containerEnergyGet,
+}
+
+// raplZone is a RAPL zone (package) or sub-zone (core, uncore or dram).
+type raplZone struct {
+	path   string
+	domain string
+}
+
+// energyReading holds the RAPL counters of the CPU packages a container can
+// run on along with the CPU time used on each of them, by the container and
+// by the whole host.
+type energyReading struct {
+	time      time.Time
+	zones     map[string]raplZone
+	pkgs      map[string]int
+	counters  map[string]uint64
+	maxes     map[string]uint64
+	usage     map[int]uint64
+	hostUsage map[int]uint64
+}
+
+type energySample struct {
+	time    time.Time
+	domains map[string]float64
+}
+
+// energyState is the energy attributed to a container since it's been
+// tracked, along with the samples of the last few seconds.
+type energyState struct {
+	last    *energyReading
+	energy  map[string]float64
+	samples []energySample
+}
+
+var energyStatesLock sync.Mutex
+var energyStates = map[string]*energyState{}
+
+func raplAvailable() bool {
+	return shared.PathExists(filepath.Join(raplPath, "intel-rapl:0", "energy_uj"))
+}
+
+func raplReadUint(path string) (uint64, error) {
+	content, err := ioutil.ReadFile(path)
+	if err != nil {
+		return 0, err
+	}
+
+	return strconv.ParseUint(strings.TrimSpace(string(content)), 10, 64)
+}
+
+// raplPackageZones returns the RAPL zone of a CPU package and its sub-zones.
+func raplPackageZones(pkg int) ([]raplZone, error) {
+	zones := []raplZone{}
+
+	pkgPath := filepath.Join(raplPath, fmt.Sprintf("intel-rapl:%d", pkg))
+	paths, err := filepath.Glob(filepath.Join(raplPath, fmt.Sprintf("intel-rapl:%d:*", pkg)))
+	if err != nil {
+		return nil, err
+	}
+	paths = append([]string{pkgPath}, paths...)
+
+	for _, path := range paths {
+		name, err := ioutil.ReadFile(filepath.Join(path, "name"))
+		if err != nil {
+			continue
+		}
+
+		domain := strings.TrimSpace(string(name))
+		if strings.HasPrefix(domain, "package-") {
+			domain = "package"
+		}
+
+		zones = append(zones, raplZone{path: path, domain: domain})
+	}
+
+	return zones, nil
+}
+
+// raplDelta returns the energy counted between two readings of a RAPL
+// counter, which wraps around at max.
+func raplDelta(old uint64, new uint64, max uint64) uint64 {
+	if new >= old {
+		return new - old
+	}
+
+	if max == 0 || old > max {
+		return 0
+	}
+
+	return max - old + new
+}
+
+// energyParseUsagePercpu returns the CPU time used on each CPU, in
+// nanoseconds, given the content of cpuacct.usage_percpu.
+func energyParseUsagePercpu(content string) (map[int]uint64, error) {
+	usage := map[int]uint64{}
+	for cpu, field := range strings.Fields(content) {
+		value, err := strconv.ParseUint(field, 10, 64)
+		if err != nil {
+			return nil, err
+		}
+
+		usage[cpu] = value
+	}
+
+	return usage, nil
+}
+
+// energyParseProcStat returns the busy time of each CPU of the host, in
+// nanoseconds, given the content of /proc/stat.
+func energyParseProcStat(content string) (map[int]uint64, error) {
+	usage := map[int]uint64{}
+	for _, line := range strings.Split(content, "\n") {
+		fields := strings.Fields(line)
+		if len(fields) < 9 || !strings.HasPrefix(fields[0], "cpu") || fields[0] == "cpu" {
+			continue
+		}
+
+		cpu, err := strconv.Atoi(strings.TrimPrefix(fields[0], "cpu"))
+		if err != nil {
+			return nil, err
+		}
+
+		// user, nice, system, irq, softirq and steal
+		busy := uint64(0)
+		for _, i := range []int{1, 2, 3, 6, 7, 8} {
+			value, err := strconv.ParseUint(fields[i], 10, 64)
+			if err != nil {
+				return nil, err
+			}
+
+			busy += value
+		}
+
+		usage[cpu] = busy * uint64(time.Second) / energyUserHZ
+	}
+
+	return usage, nil
+}
+
+// energyShare returns the share of the energy of a CPU package attributed to
+// a container, the CPU time it used on the package over the CPU time used on
+// it by the whole host.
+func energyShare(usage uint64, hostUsage uint64) float64 {
+	if hostUsage == 0 {
+		return 0
+	}
+
+	share := float64(usage) / float64(hostUsage)
+	if share > 1 {
+		return 1
+	}
+
+	return share
+}
+
+// containerEnergyRead reads the RAPL counters of the CPU packages a container
+// can run on and the CPU time used on each of them.
+func containerEnergyRead(c container) (*energyReading, error) {
+	cpuset, err := c.CGroupGet("cpuset.effective_cpus")
+	if err != nil {
+		return nil, err
+	}
+
+	cpus, err := parseCpuset(cpuset)
+	if err != nil {
+		return nil, err
+	}
+
+	percpu, err := c.CGroupGet("cpuacct.usage_percpu")
+	if err != nil {
+		return nil, err
+	}
+
+	usage, err := energyParseUsagePercpu(percpu)
+	if err != nil {
+		return nil, err
+	}
+
+	content, err := ioutil.ReadFile("/proc/stat")
+	if err != nil {
+		return nil, err
+	}
+
+	hostUsage, err := energyParseProcStat(string(content))
+	if err != nil {
+		return nil, err
+	}
+
+	reading := &energyReading{
+		time:      time.Now(),
+		zones:     map[string]raplZone{},
+		pkgs:      map[string]int{},
+		counters:  map[string]uint64{},
+		maxes:     map[string]uint64{},
+		usage:     map[int]uint64{},
+		hostUsage: map[int]uint64{},
+	}
+
+	// Packages of the CPUs of the host
+	cpuPkgs := map[int]int{}
+	for cpu := range hostUsage {
+		pkg, err := raplReadUint(fmt.Sprintf("/sys/devices/system/cpu/cpu%d/topology/physical_package_id", cpu))
+		if err != nil {
+			continue
+		}
+
+		cpuPkgs[cpu] = int(pkg)
+		reading.hostUsage[int(pkg)] += hostUsage[cpu]
+		reading.usage[int(pkg)] += usage[cpu]
+	}
+
+	pkgs := map[int]bool{}
+	for _, cpu := range cpus {
+		pkg, ok := cpuPkgs[int(cpu)]
+		if ok {
+			pkgs[pkg] = true
+		}
+	}
+
+	for pkg := range pkgs {
+		zones, err := raplPackageZones(pkg)
+		if err != nil {
+			return nil, err
+		}
+
+		for _, zone := range zones {
+			energy, err := raplReadUint(filepath.Join(zone.path, "energy_uj"))
+			if err != nil {
+				return nil, err
+			}
+
+			max, err := raplReadUint(filepath.Join(zone.path, "max_energy_range_uj"))
+			if err != nil {
+				max = 0
+			}
+
+			reading.zones[zone.path] = zone
+			reading.pkgs[zone.path] = pkg
+			reading.counters[zone.path] = energy
+			reading.maxes[zone.path] = max
+		}
+	}
+
+	return reading, nil
+}
+
+// energyAttribute returns the energy of each RAPL domain attributed to a
+// container between two readings, in Joules.
+func energyAttribute(old *energyReading, new *energyReading) map[string]float64 {
+	domains := map[string]float64{}
+
+	for path, zone := range new.zones {
+		counter, ok := old.counters[path]
+		if !ok {
+			continue
+		}
+
+		// CPUs going offline make the CPU times go backwards
+		pkg := new.pkgs[path]
+		if new.usage[pkg] < old.usage[pkg] || new.hostUsage[pkg] < old.hostUsage[pkg] {
+			continue
+		}
+
+		share := energyShare(new.usage[pkg]-old.usage[pkg], new.hostUsage[pkg]-old.hostUsage[pkg])
+		delta := raplDelta(counter, new.counters[path], new.maxes[path])
+		domains[zone.domain] += float64(delta) / 1000000 * share
+	}
+
+	return domains
+}
+
+// containerEnergyRender takes a new reading, attributing the energy used
+// since the previous one to the container based on its CPU usage, and
+// computes the current power from the samples of the last few seconds.
+func containerEnergyRender(c container) (*api.ContainerEnergy, error) {
+	reading, err := containerEnergyRead(c)
+	if err != nil {
+		return nil, err
+	}
+
+	key := fmt.Sprintf("%s/%s", c.Project(), c.Name())
+
+	energyStatesLock.Lock()
+	// Forget the containers which stopped being sampled
+	for k, state := range energyStates {
+		if reading.time.Sub(state.last.time) > energyStateExpiry {
+			delete(energyStates, k)
+		}
+	}
+
+	state, ok := energyStates[key]
+	if !ok {
+		state = &energyState{last: reading, energy: map[string]float64{}}
+		energyStates[key] = state
+	}
+
+	for domain, energy := range energyAttribute(state.last, reading) {
+		state.energy[domain] += energy
+	}
+	state.last = reading
+
+	sample := energySample{time: reading.time, domains: map[string]float64{}}
+	for domain, energy := range state.energy {
+		sample.domains[domain] = energy
+	}
+
+	samples := []energySample{}
+	for _, s := range state.samples {
+		if sample.time.Sub(s.time) <= energySampleWindow {
+			samples = append(samples, s)
+		}
+	}
+	samples = append(samples, sample)
+	state.samples = samples
+	energyStatesLock.Unlock()
+
+	result := api.ContainerEnergy{Domains: map[string]api.ContainerEnergyDomain{}}
+	oldest := samples[0]
+	elapsed := sample.time.Sub(oldest.time).Seconds()
+
+	for domain, energy := range sample.domains {
+		entry := api.ContainerEnergyDomain{Energy: energy}
+		if elapsed > 0 {
+			entry.Power = (energy - oldest.domains[domain]) / elapsed
+		}
+
+		result.Domains[domain] = entry
+
+		// Core, uncore and DRAM are already accounted for in the package
+		if domain == "package" {
+			result.Energy += entry.Energy
+			result.Power += entry.Power
+		}
+	}
+
+	return &result, nil
+}
+
+func containerEnergyGet(d *Daemon, r *http.Request) Response {
+	project := projectParam(r)
+	name := mux.Vars(r)["name"]
+
+	// Handle requests targeted to a container on a different node
+	response, err := ForwardedResponseIfContainerIsRemote(d, r, project, name)
+	if err != nil {
+		return SmartError(err)
+	}
+	if response != nil {
+		return response
+	}
+
+	if !raplAvailable() {
+		return NotImplemented(fmt.Errorf("Intel RAPL isn't available on this system"))
+	}
+
+	c, err := containerLoadByProjectAndName(d.State(), project, name)
+	if err != nil {
+		return SmartError(err)
+	}
+
+	if !c.IsRunning() {
+		return BadRequest(withErrorCode(api.ErrContainerNotRunning, fmt.Errorf("Container is not running")))
+	}
+
+	if websocket.IsWebSocketUpgrade(r) {
+		return &containerEnergyServe{req: r, container: c}
+	}
+
+	energy, err := containerEnergyRender(c)
+	if err != nil {
+		return InternalError(err)
+	}
+
+	return SyncResponse(true, energy)
+}
+
+type containerEnergyServe struct {
+	req       *http.Request
+	container container
+}
+
+func (r *containerEnergyServe) Render(w http.ResponseWriter) error {
+	conn, err := shared.WebsocketUpgrader.Upgrade(w, r.req, nil)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+
+	// Detect the client going away
+	done := make(chan struct{})
+	go func() {
+		for {
+			_, _, err := conn.NextReader()
+			if err != nil {
+				close(done)
+				return
+			}
+		}
+	}()
+
+	ticker := time.NewTicker(time.Second)
+	defer ticker.Stop()
+
+	for {
+		if !r.container.IsRunning() {
+			return nil
+		}
+
+		energy, err := containerEnergyRender(r.container)
+		if err != nil {
+			logger.Debugf("Failed to retrieve container energy: %v", err)
+			return nil
+		}
+
+		err = conn.WriteJSON(energy)
+		if err != nil {
+			return nil
+		}
+
+		select {
+		case <-done:
+			return nil
+		case <-ticker.C:
+		}
+	}
+}
+
+func (r *containerEnergyServe) String() string {
+	return "container energy handler"
+}
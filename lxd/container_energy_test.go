@@ -0,0 +1,73 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRaplDelta(t *testing.T) {
+	assert.Equal(t, uint64(100), raplDelta(1000, 1100, 262143328850))
+	assert.Equal(t, uint64(150), raplDelta(262143328800, 100, 262143328850))
+
+	// Unknown range
+	assert.Equal(t, uint64(0), raplDelta(1000, 100, 0))
+}
+
+func TestEnergyParseProcStat(t *testing.T) {
+	content := `cpu  10 0 10 100 0 0 0 0 0 0
+cpu0 4 1 3 50 7 1 1 0 0 0
+cpu1 6 0 7 50 0 0 0 2 0 0
+intr 1234
+`
+
+	usage, err := energyParseProcStat(content)
+	assert.NoError(t, err)
+	assert.Equal(t, map[int]uint64{0: 100000000, 1: 150000000}, usage)
+}
+
+func TestEnergyParseUsagePercpu(t *testing.T) {
+	usage, err := energyParseUsagePercpu("1500 0 2500 \n")
+	assert.NoError(t, err)
+	assert.Equal(t, map[int]uint64{0: 1500, 1: 0, 2: 2500}, usage)
+
+	_, err = energyParseUsagePercpu("15a0")
+	assert.Error(t, err)
+}
+
+func TestEnergyAttribute(t *testing.T) {
+	zones := map[string]raplZone{
+		"intel-rapl:0":   {path: "intel-rapl:0", domain: "package"},
+		"intel-rapl:0:0": {path: "intel-rapl:0:0", domain: "core"},
+		"intel-rapl:1":   {path: "intel-rapl:1", domain: "package"},
+	}
+	pkgs := map[string]int{"intel-rapl:0": 0, "intel-rapl:0:0": 0, "intel-rapl:1": 1}
+	maxes := map[string]uint64{"intel-rapl:0": 1000000000, "intel-rapl:0:0": 1000000000, "intel-rapl:1": 1000000000}
+
+	old := &energyReading{
+		zones:     zones,
+		pkgs:      pkgs,
+		maxes:     maxes,
+		counters:  map[string]uint64{"intel-rapl:0": 10000000, "intel-rapl:0:0": 5000000, "intel-rapl:1": 999000000},
+		usage:     map[int]uint64{0: 1000, 1: 1000},
+		hostUsage: map[int]uint64{0: 4000, 1: 4000},
+	}
+
+	new := &energyReading{
+		zones:     zones,
+		pkgs:      pkgs,
+		maxes:     maxes,
+		counters:  map[string]uint64{"intel-rapl:0": 30000000, "intel-rapl:0:0": 13000000, "intel-rapl:1": 3000000},
+		usage:     map[int]uint64{0: 2000, 1: 1000},
+		hostUsage: map[int]uint64{0: 8000, 1: 6000},
+	}
+
+	// A quarter of the CPU time of package 0 and none of package 1
+	domains := energyAttribute(old, new)
+	assert.InDelta(t, 5, domains["package"], 0.0001)
+	assert.InDelta(t, 2, domains["core"], 0.0001)
+
+	// Idle host
+	assert.Equal(t, float64(0), energyShare(0, 0))
+	assert.Equal(t, float64(1), energyShare(3000, 2000))
+}
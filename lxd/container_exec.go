@@ -1,8 +1,10 @@
 package main
 
 import (
+	"bytes"
 	"encoding/json"
 	"fmt"
+	"io"
 	"io/ioutil"
 	"net/http"
 	"os"
@@ -12,6 +14,7 @@ import (
 	"strings"
 	"sync"
 	"syscall"
+	"time"
 
 	"github.com/gorilla/mux"
 	"github.com/gorilla/websocket"
@@ -31,6 +34,9 @@ type execWs struct {
 	container container
 	env       map[string]string
 
+	// Secrets are kept out of the operation metadata
+	secretEnv map[string]string
+
 	rootUid          int64
 	rootGid          int64
 	conns            map[int]*websocket.Conn
@@ -41,6 +47,193 @@ type execWs struct {
 	fds              map[int]string
 	width            int
 	height           int
+	timeout          time.Duration
+}
+
+// Grace period between SIGTERM and SIGKILL when terminating a command
+const execTerminateGracePeriod = 5 * time.Second
+
+// execMonitor watches a running command until done is closed, terminating it
+// if it runs past the timeout or if the size of its recorded output goes past
+// maxOutputBytes. The returned channel gets the limit which was hit, if any.
+func execMonitor(pid int, timeout time.Duration, maxOutputBytes int64, outputs []*os.File, done chan struct{}) chan error {
+	result := make(chan error, 1)
+
+	// Nothing to enforce
+	if timeout <= 0 && maxOutputBytes <= 0 {
+		result <- nil
+		return result
+	}
+
+	go func() {
+		var deadline <-chan time.Time
+		if timeout > 0 {
+			timer := time.NewTimer(timeout)
+			defer timer.Stop()
+			deadline = timer.C
+		}
+
+		ticker := time.NewTicker(250 * time.Millisecond)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-done:
+				result <- nil
+				return
+			case <-deadline:
+				execTerminate(pid, done)
+				result <- fmt.Errorf("Command timed out after %s", timeout)
+				return
+			case <-ticker.C:
+				if maxOutputBytes <= 0 {
+					continue
+				}
+
+				size := int64(0)
+				for _, output := range outputs {
+					if output == nil {
+						continue
+					}
+
+					fi, err := output.Stat()
+					if err == nil {
+						size += fi.Size()
+					}
+				}
+
+				if size > maxOutputBytes {
+					execTerminate(pid, done)
+					result <- fmt.Errorf("Command output exceeded the limit of %d bytes", maxOutputBytes)
+					return
+				}
+			}
+		}
+	}()
+
+	return result
+}
+
+// execTerminate sends SIGTERM to a command, followed by SIGKILL if it's still
+// running after the grace period.
+func execTerminate(pid int, done chan struct{}) {
+	err := syscall.Kill(pid, syscall.SIGTERM)
+	if err != nil {
+		logger.Debugf("Failed to send SIGTERM to pid %d", pid)
+	}
+
+	select {
+	case <-done:
+	case <-time.After(execTerminateGracePeriod):
+		err := syscall.Kill(pid, syscall.SIGKILL)
+		if err != nil {
+			logger.Debugf("Failed to send SIGKILL to pid %d", pid)
+		}
+	}
+}
+
+// execExitCode returns the exit code of a command from the result of its
+// Wait().
+func execExitCode(err error) int {
+	if err == nil {
+		return 0
+	}
+
+	exitErr, ok := err.(*exec.ExitError)
+	if ok {
+		status, ok := exitErr.Sys().(syscall.WaitStatus)
+		if ok {
+			if status.Signaled() {
+				// 128 + n == Fatal error signal "n"
+				return 128 + int(status.Signal())
+			}
+
+			return status.ExitStatus()
+		}
+	}
+
+	return -1
+}
+
+// execEnvironment returns the environment of a command run in a container,
+// the environment.* keys of the container being overridden by the given
+// variables.
+func execEnvironment(c container, environment map[string]string) map[string]string {
+	env := map[string]string{}
+
+	for k, v := range c.ExpandedConfig() {
+		if strings.HasPrefix(k, "environment.") {
+			env[strings.TrimPrefix(k, "environment.")] = v
+		}
+	}
+
+	if environment != nil {
+		for k, v := range environment {
+			env[k] = v
+		}
+	}
+
+	// Point CUDA clients to the NVIDIA MPS pipe directory
+	_, ok := env["CUDA_MPS_PIPE_DIRECTORY"]
+	if !ok {
+		for _, m := range c.ExpandedDevices() {
+			if deviceWantsMPS(m) {
+				env["CUDA_MPS_PIPE_DIRECTORY"] = "/dev/nvidia-mps"
+				break
+			}
+		}
+	}
+
+	// Set default value for PATH
+	_, ok = env["PATH"]
+	if !ok {
+		env["PATH"] = "/usr/local/sbin:/usr/local/bin:/usr/sbin:/usr/bin:/sbin:/bin"
+		if c.FileExists("/snap") == nil {
+			env["PATH"] = fmt.Sprintf("%s:/snap/bin", env["PATH"])
+		}
+	}
+
+	// Set default value for HOME
+	_, ok = env["HOME"]
+	if !ok {
+		env["HOME"] = "/root"
+	}
+
+	// Set default value for USER
+	_, ok = env["USER"]
+	if !ok {
+		env["USER"] = "root"
+	}
+
+	// Set default value for USER
+	_, ok = env["LANG"]
+	if !ok {
+		env["LANG"] = "C.UTF-8"
+	}
+
+	return env
+}
+
+// execRun runs a command to completion, enforcing the timeout and output size
+// limits of the request.
+func execRun(c container, post api.ContainerExecPost, env map[string]string, stdout *os.File, stderr *os.File) (int, error) {
+	if post.Timeout <= 0 && post.MaxOutputBytes <= 0 {
+		_, cmdResult, _, cmdErr := c.Exec(post.Command, env, nil, stdout, stderr, true)
+		return cmdResult, cmdErr
+	}
+
+	cmd, _, attachedPid, err := c.Exec(post.Command, env, nil, stdout, stderr, false)
+	if err != nil {
+		return -1, err
+	}
+
+	done := make(chan struct{})
+	limit := execMonitor(attachedPid, time.Duration(post.Timeout)*time.Second, post.MaxOutputBytes, []*os.File{stdout, stderr}, done)
+
+	err = cmd.Wait()
+	close(done)
+
+	return execExitCode(err), <-limit
 }
 
 func (s *execWs) Metadata() interface{} {
@@ -306,7 +499,16 @@ func (s *execWs) Do(op *operation) error {
 		return cmdErr
 	}
 
-	cmd, _, attachedPid, err := s.container.Exec(s.command, s.env, stdin, stdout, stderr, false)
+	env := map[string]string{}
+	for k, v := range s.env {
+		env[k] = v
+	}
+
+	for k, v := range s.secretEnv {
+		env[k] = v
+	}
+
+	cmd, _, attachedPid, err := s.container.Exec(s.command, env, stdin, stdout, stderr, false)
 	if err != nil {
 		return err
 	}
@@ -315,25 +517,31 @@ func (s *execWs) Do(op *operation) error {
 		attachedChildIsBorn <- attachedPid
 	}
 
+	done := make(chan struct{})
+	limit := execMonitor(attachedPid, s.timeout, 0, nil, done)
+
 	err = cmd.Wait()
+	close(done)
+	limitErr := <-limit
+
 	if err == nil {
-		return finisher(0, nil)
+		return finisher(0, limitErr)
 	}
 
 	exitErr, ok := err.(*exec.ExitError)
 	if ok {
 		status, ok := exitErr.Sys().(syscall.WaitStatus)
 		if ok {
-			return finisher(status.ExitStatus(), nil)
+			return finisher(status.ExitStatus(), limitErr)
 		}
 
 		if status.Signaled() {
 			// 128 + n == Fatal error signal "n"
-			return finisher(128+int(status.Signal()), nil)
+			return finisher(128+int(status.Signal()), limitErr)
 		}
 	}
 
-	return finisher(-1, nil)
+	return finisher(-1, limitErr)
 }
 
 func containerExecPost(d *Daemon, r *http.Request) Response {
@@ -341,12 +549,68 @@ func containerExecPost(d *Daemon, r *http.Request) Response {
 	name := mux.Vars(r)["name"]
 
 	post := api.ContainerExecPost{}
-	buf, err := ioutil.ReadAll(r.Body)
-	if err != nil {
-		return BadRequest(err)
+	var buf []byte
+	var stdin io.Reader
+	var err error
+
+	if r.Header.Get("Content-Type") == execStdinContentType {
+		// Forward the request as is if the container is remote, as the
+		// input of the command follows the exec request in the body
+		cert := d.endpoints.NetworkCert()
+		client, err := cluster.ConnectIfContainerIsRemote(d.cluster, project, name, cert)
+		if err != nil {
+			return SmartError(err)
+		}
+
+		if client != nil {
+			return ForwardedResponse(client, r)
+		}
+
+		stdin, err = execStdinDecode(r.Body, &post)
+		if err != nil {
+			return BadRequest(err)
+		}
+
+		if post.Mode != "stdin" {
+			return BadRequest(fmt.Errorf("Requests of type %s require the stdin mode", execStdinContentType))
+		}
+	} else {
+		buf, err = ioutil.ReadAll(r.Body)
+		if err != nil {
+			return BadRequest(err)
+		}
+
+		if err := json.Unmarshal(buf, &post); err != nil {
+			return BadRequest(err)
+		}
+
+		if post.Mode == "stdin" {
+			return BadRequest(fmt.Errorf("The stdin mode requires a request of type %s", execStdinContentType))
+		}
+	}
+
+	if post.Mode != "" && post.Mode != "stdin" {
+		return BadRequest(fmt.Errorf("Invalid mode: %s", post.Mode))
+	}
+
+	if post.Mode == "stdin" && (post.WaitForWS || post.Interactive || post.RecordOutput) {
+		return BadRequest(fmt.Errorf("The stdin mode can't be combined with wait-for-websocket, interactive or record-output"))
 	}
 
-	if err := json.Unmarshal(buf, &post); err != nil {
+	if post.Timeout < 0 {
+		return BadRequest(fmt.Errorf("Invalid timeout: %d", post.Timeout))
+	}
+
+	if post.MaxOutputBytes < 0 {
+		return BadRequest(fmt.Errorf("Invalid maximum output size: %d", post.MaxOutputBytes))
+	}
+
+	if post.MaxOutputBytes > 0 && (!post.RecordOutput || post.WaitForWS) {
+		return BadRequest(fmt.Errorf("max_output_bytes requires record-output"))
+	}
+
+	err = execValidateEnvironment(post.Environment)
+	if err != nil {
 		return BadRequest(err)
 	}
 
@@ -357,6 +621,11 @@ func containerExecPost(d *Daemon, r *http.Request) Response {
 		return SmartError(err)
 	}
 
+	if client != nil && post.DryRun {
+		r.Body = ioutil.NopCloser(bytes.NewReader(buf))
+		return ForwardedResponse(client, r)
+	}
+
 	if client != nil {
 		url := fmt.Sprintf("/containers/%s/exec?project=%s", name, project)
 		op, _, err := client.RawOperation("POST", url, post, "")
@@ -374,52 +643,43 @@ func containerExecPost(d *Daemon, r *http.Request) Response {
 	}
 
 	if !c.IsRunning() {
-		return BadRequest(fmt.Errorf("Container is not running"))
+		return BadRequest(withErrorCode(api.ErrContainerNotRunning, fmt.Errorf("Container is not running")))
 	}
 
 	if c.IsFrozen() {
 		return BadRequest(fmt.Errorf("Container is frozen"))
 	}
 
-	env := map[string]string{}
-
-	for k, v := range c.ExpandedConfig() {
-		if strings.HasPrefix(k, "environment.") {
-			env[strings.TrimPrefix(k, "environment.")] = v
-		}
+	resp := containerQuotaCheck(d, c)
+	if resp != nil {
+		return resp
 	}
 
-	if post.Environment != nil {
-		for k, v := range post.Environment {
-			env[k] = v
-		}
+	env := execEnvironment(c, post.Environment)
+
+	if post.DryRun {
+		return execDryRun(c, post, env)
 	}
 
-	// Set default value for PATH
-	_, ok := env["PATH"]
-	if !ok {
-		env["PATH"] = "/usr/local/sbin:/usr/local/bin:/usr/sbin:/usr/bin:/sbin:/bin"
-		if c.FileExists("/snap") == nil {
-			env["PATH"] = fmt.Sprintf("%s:/snap/bin", env["PATH"])
+	secretEnv := map[string]string{}
+	if len(post.SecretRefs) > 0 {
+		secrets, err := execSecretsCheck(c, post.SecretRefs)
+		if err != nil {
+			return Forbidden(err)
 		}
-	}
 
-	// Set default value for HOME
-	_, ok = env["HOME"]
-	if !ok {
-		env["HOME"] = "/root"
+		secretEnv, err = execSecretsFetch(d, secrets)
+		if err != nil {
+			return InternalError(err)
+		}
 	}
 
-	// Set default value for USER
-	_, ok = env["USER"]
-	if !ok {
-		env["USER"] = "root"
-	}
+	if post.Mode == "stdin" {
+		for k, v := range secretEnv {
+			env[k] = v
+		}
 
-	// Set default value for USER
-	_, ok = env["LANG"]
-	if !ok {
-		env["LANG"] = "C.UTF-8"
+		return execStdin(r, c, post, env, stdin)
 	}
 
 	if post.WaitForWS {
@@ -455,9 +715,11 @@ func containerExecPost(d *Daemon, r *http.Request) Response {
 		ws.command = post.Command
 		ws.container = c
 		ws.env = env
+		ws.secretEnv = secretEnv
 
 		ws.width = post.Width
 		ws.height = post.Height
+		ws.timeout = time.Duration(post.Timeout) * time.Second
 
 		resources := map[string][]string{}
 		resources["containers"] = []string{ws.container.Name()}
@@ -470,6 +732,10 @@ func containerExecPost(d *Daemon, r *http.Request) Response {
 		return OperationResponse(op)
 	}
 
+	for k, v := range secretEnv {
+		env[k] = v
+	}
+
 	run := func(op *operation) error {
 		var cmdErr error
 		var cmdResult int
@@ -490,7 +756,7 @@ func containerExecPost(d *Daemon, r *http.Request) Response {
 			defer stderr.Close()
 
 			// Run the command
-			_, cmdResult, _, cmdErr = c.Exec(post.Command, env, nil, stdout, stderr, true)
+			cmdResult, cmdErr = execRun(c, post, env, stdout, stderr)
 
 			// Update metadata with the right URLs
 			metadata["return"] = cmdResult
@@ -499,7 +765,7 @@ func containerExecPost(d *Daemon, r *http.Request) Response {
 				"2": fmt.Sprintf("/%s/containers/%s/logs/%s", version.APIVersion, c.Name(), filepath.Base(stderr.Name())),
 			}
 		} else {
-			_, cmdResult, _, cmdErr = c.Exec(post.Command, env, nil, nil, nil, true)
+			cmdResult, cmdErr = execRun(c, post, env, nil, nil)
 			metadata["return"] = cmdResult
 		}
 
@@ -0,0 +1,100 @@
+package main
+
+import (
+	"fmt"
+	"path/filepath"
+	"strings"
+
+	"github.com/lxc/lxd/shared/api"
+)
+
+// execValidateEnvironment checks the names of the environment variables of
+// an exec request.
+func execValidateEnvironment(env map[string]string) error {
+	for k := range env {
+		if k == "" || strings.ContainsAny(k, "=\x00") {
+			return fmt.Errorf("Invalid environment variable name '%s'", k)
+		}
+	}
+
+	return nil
+}
+
+// execResolveCommand returns the path of the executable of a command, looked
+// up in the given PATH the same way execvp does if it's not a path already.
+func execResolveCommand(command string, path string, exists func(path string) bool) (string, error) {
+	if command == "" {
+		return "", fmt.Errorf("No command specified")
+	}
+
+	if strings.Contains(command, "/") {
+		if !exists(command) {
+			return "", fmt.Errorf("Command '%s' not found in the container", command)
+		}
+
+		return command, nil
+	}
+
+	for _, dir := range filepath.SplitList(path) {
+		if dir == "" {
+			dir = "."
+		}
+
+		candidate := filepath.Join(dir, command)
+		if exists(candidate) {
+			return candidate, nil
+		}
+	}
+
+	return "", fmt.Errorf("Command '%s' not found in PATH of the container", command)
+}
+
+// execDryRun resolves what an exec request would run without running it.
+func execDryRun(c container, post api.ContainerExecPost, env map[string]string) Response {
+	if len(post.Command) == 0 {
+		return BadRequest(fmt.Errorf("No command specified"))
+	}
+
+	exists := func(path string) bool {
+		return c.FileExists(path) == nil
+	}
+
+	executable, err := execResolveCommand(post.Command[0], env["PATH"], exists)
+	if err != nil {
+		return BadRequest(err)
+	}
+
+	// The command is run from the home directory
+	cwd := env["HOME"]
+	if !exists(cwd) {
+		return BadRequest(fmt.Errorf("Working directory '%s' doesn't exist in the container", cwd))
+	}
+
+	secrets, err := execSecretsCheck(c, post.SecretRefs)
+	if err != nil {
+		return Forbidden(err)
+	}
+
+	result := api.ContainerExecDryRun{
+		Command:     append([]string{executable}, post.Command[1:]...),
+		Environment: env,
+		Secrets:     []string{},
+		Cwd:         cwd,
+	}
+
+	for _, secret := range secrets {
+		result.Secrets = append(result.Secrets, secret.variable)
+	}
+
+	// The command is run as root in the container
+	idmapset, err := c.CurrentIdmap()
+	if err != nil {
+		return InternalError(err)
+	}
+
+	if idmapset != nil {
+		result.HostUID, result.HostGID = idmapset.ShiftIntoNs(0, 0)
+	}
+
+	return SyncResponse(true, result)
+}
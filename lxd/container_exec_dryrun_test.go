@@ -0,0 +1,39 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestExecValidateEnvironment(t *testing.T) {
+	assert.NoError(t, execValidateEnvironment(map[string]string{"FOO": "a=b"}))
+	assert.NoError(t, execValidateEnvironment(nil))
+	assert.Error(t, execValidateEnvironment(map[string]string{"FOO=BAR": "a"}))
+	assert.Error(t, execValidateEnvironment(map[string]string{"": "a"}))
+}
+
+func TestExecResolveCommand(t *testing.T) {
+	files := map[string]bool{"/usr/bin/apt": true, "/bin/sh": true, "/opt/run.sh": true}
+	exists := func(path string) bool {
+		return files[path]
+	}
+
+	path, err := execResolveCommand("apt", "/usr/local/bin:/usr/bin:/bin", exists)
+	require.NoError(t, err)
+	assert.Equal(t, "/usr/bin/apt", path)
+
+	path, err = execResolveCommand("/opt/run.sh", "/usr/bin", exists)
+	require.NoError(t, err)
+	assert.Equal(t, "/opt/run.sh", path)
+
+	_, err = execResolveCommand("sh", "/usr/bin", exists)
+	assert.Error(t, err)
+
+	_, err = execResolveCommand("/opt/missing", "/usr/bin", exists)
+	assert.Error(t, err)
+
+	_, err = execResolveCommand("", "/usr/bin", exists)
+	assert.Error(t, err)
+}
@@ -0,0 +1,197 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"path"
+	"path/filepath"
+	"regexp"
+	"strings"
+
+	"github.com/lxc/lxd/lxd/cluster"
+	"github.com/lxc/lxd/lxd/db"
+	"github.com/lxc/lxd/lxd/util"
+)
+
+var execSecretNameRegexp = regexp.MustCompile(`^[A-Za-z0-9_.-]+$`)
+var execSecretVariableRegexp = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*$`)
+
+// execSecretRef is a secret requested for an exec session, along with the
+// environment variable it's injected as.
+type execSecretRef struct {
+	variable string
+	name     string
+}
+
+// execSecretParseRef parses a secret reference, either the name of the
+// secret or <variable>=<name>. The variable defaults to the upper-cased name
+// of the secret, dots and dashes replaced by underscores.
+func execSecretParseRef(ref string) (execSecretRef, error) {
+	secret := execSecretRef{name: ref}
+
+	fields := strings.SplitN(ref, "=", 2)
+	if len(fields) == 2 {
+		secret.variable = fields[0]
+		secret.name = fields[1]
+	} else {
+		secret.variable = strings.ToUpper(strings.NewReplacer(".", "_", "-", "_").Replace(ref))
+	}
+
+	if !execSecretNameRegexp.MatchString(secret.name) {
+		return execSecretRef{}, fmt.Errorf("Invalid secret name '%s'", secret.name)
+	}
+
+	if !execSecretVariableRegexp.MatchString(secret.variable) {
+		return execSecretRef{}, fmt.Errorf("Invalid environment variable name '%s'", secret.variable)
+	}
+
+	return secret, nil
+}
+
+// execSecretAllowed returns whether a secret matches one of the patterns of
+// the security.secrets.whitelist key of a container.
+func execSecretAllowed(whitelist string, name string) bool {
+	for _, pattern := range strings.Split(whitelist, ",") {
+		pattern = strings.TrimSpace(pattern)
+		if pattern == "" {
+			continue
+		}
+
+		match, _ := path.Match(pattern, name)
+		if match {
+			return true
+		}
+	}
+
+	return false
+}
+
+// execSecretFetchFile reads a secret from a directory holding a file per
+// secret.
+func execSecretFetchFile(dir string, name string) (string, error) {
+	if dir == "" {
+		return "", fmt.Errorf("No secrets.file.path configured")
+	}
+
+	content, err := ioutil.ReadFile(filepath.Join(dir, name))
+	if err != nil {
+		return "", err
+	}
+
+	return strings.TrimSuffix(string(content), "\n"), nil
+}
+
+// execSecretFetchVault reads a secret from a Vault KV mount, the secret value
+// being stored under its "value" key. Both version 1 and 2 of the KV secrets
+// engine are supported.
+func execSecretFetchVault(client *http.Client, url string, token string, name string) (string, error) {
+	if url == "" {
+		return "", fmt.Errorf("No secrets.vault.url configured")
+	}
+
+	req, err := http.NewRequest("GET", fmt.Sprintf("%s/%s", strings.TrimSuffix(url, "/"), name), nil)
+	if err != nil {
+		return "", err
+	}
+	req.Header.Set("X-Vault-Token", token)
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("Failed to fetch secret '%s' from Vault: %s", name, resp.Status)
+	}
+
+	body := struct {
+		Data map[string]interface{} `json:"data"`
+	}{}
+
+	err = json.NewDecoder(resp.Body).Decode(&body)
+	if err != nil {
+		return "", err
+	}
+
+	data := body.Data
+	inner, ok := data["data"].(map[string]interface{})
+	if ok {
+		data = inner
+	}
+
+	value, ok := data["value"].(string)
+	if !ok {
+		return "", fmt.Errorf("Secret '%s' has no value", name)
+	}
+
+	return value, nil
+}
+
+// execSecretsCheck parses the secret references of an exec request and makes
+// sure that the container is allowed to access them.
+func execSecretsCheck(c container, refs []string) ([]execSecretRef, error) {
+	secrets := []execSecretRef{}
+	for _, ref := range refs {
+		secret, err := execSecretParseRef(ref)
+		if err != nil {
+			return nil, err
+		}
+
+		if !execSecretAllowed(c.ExpandedConfig()["security.secrets.whitelist"], secret.name) {
+			return nil, fmt.Errorf("Secret '%s' isn't allowed for this container", secret.name)
+		}
+
+		secrets = append(secrets, secret)
+	}
+
+	return secrets, nil
+}
+
+// execSecretsFetch fetches secrets from the configured backend, returning
+// the environment variables they're injected as.
+func execSecretsFetch(d *Daemon, secrets []execSecretRef) (map[string]string, error) {
+	var config *cluster.Config
+	err := d.cluster.Transaction(func(tx *db.ClusterTx) error {
+		var err error
+		config, err = cluster.ConfigLoad(tx)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	var fetch func(name string) (string, error)
+	switch config.SecretsBackend() {
+	case "vault":
+		client, err := util.HTTPClient("", d.proxy)
+		if err != nil {
+			return nil, err
+		}
+
+		url, token := config.SecretsVault()
+		fetch = func(name string) (string, error) {
+			return execSecretFetchVault(client, url, token, name)
+		}
+	case "file":
+		fetch = func(name string) (string, error) {
+			return execSecretFetchFile(config.SecretsFilePath(), name)
+		}
+	default:
+		return nil, fmt.Errorf("No secrets backend configured")
+	}
+
+	env := map[string]string{}
+	for _, secret := range secrets {
+		value, err := fetch(secret.name)
+		if err != nil {
+			return nil, err
+		}
+
+		env[secret.variable] = value
+	}
+
+	return env, nil
+}
@@ -0,0 +1,84 @@
+package main
+
+import (
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestExecSecretParseRef(t *testing.T) {
+	secret, err := execSecretParseRef("db.password")
+	require.NoError(t, err)
+	assert.Equal(t, execSecretRef{variable: "DB_PASSWORD", name: "db.password"}, secret)
+
+	secret, err = execSecretParseRef("PGPASSWORD=db-password")
+	require.NoError(t, err)
+	assert.Equal(t, execSecretRef{variable: "PGPASSWORD", name: "db-password"}, secret)
+
+	for _, ref := range []string{"", "../etc/shadow", "a/b", "1VAR=name", "VAR=", "A B=name"} {
+		_, err := execSecretParseRef(ref)
+		assert.Error(t, err, ref)
+	}
+}
+
+func TestExecSecretAllowed(t *testing.T) {
+	assert.False(t, execSecretAllowed("", "db_password"))
+	assert.True(t, execSecretAllowed("db_*", "db_password"))
+	assert.True(t, execSecretAllowed("api_key, db_*", "db_password"))
+	assert.False(t, execSecretAllowed("api_*,db_user", "db_password"))
+}
+
+func TestExecSecretFetchFile(t *testing.T) {
+	dir, err := ioutil.TempDir("", "lxd_secrets_")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	err = ioutil.WriteFile(filepath.Join(dir, "db_password"), []byte("hunter2\n"), 0600)
+	require.NoError(t, err)
+
+	value, err := execSecretFetchFile(dir, "db_password")
+	require.NoError(t, err)
+	assert.Equal(t, "hunter2", value)
+
+	_, err = execSecretFetchFile(dir, "missing")
+	assert.Error(t, err)
+}
+
+func TestExecSecretFetchVault(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("X-Vault-Token") != "token" {
+			w.WriteHeader(http.StatusForbidden)
+			return
+		}
+
+		switch r.URL.Path {
+		case "/v1/kv/v1_secret":
+			w.Write([]byte(`{"data": {"value": "one"}}`))
+		case "/v1/kv/data/v2_secret":
+			w.Write([]byte(`{"data": {"data": {"value": "two"}, "metadata": {}}}`))
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer server.Close()
+
+	value, err := execSecretFetchVault(server.Client(), server.URL+"/v1/kv", "token", "v1_secret")
+	require.NoError(t, err)
+	assert.Equal(t, "one", value)
+
+	value, err = execSecretFetchVault(server.Client(), server.URL+"/v1/kv/data/", "token", "v2_secret")
+	require.NoError(t, err)
+	assert.Equal(t, "two", value)
+
+	_, err = execSecretFetchVault(server.Client(), server.URL+"/v1/kv", "token", "missing")
+	assert.Error(t, err)
+
+	_, err = execSecretFetchVault(server.Client(), server.URL+"/v1/kv", "wrong", "v1_secret")
+	assert.Error(t, err)
+}
@@ -0,0 +1,156 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"strconv"
+	"time"
+
+	"github.com/lxc/lxd/shared/api"
+	"github.com/lxc/lxd/shared/logger"
+
+	log "github.com/lxc/lxd/shared/log15"
+)
+
+// Content type of the exec requests followed by the input of the command
+const execStdinContentType = "application/octet-stream"
+
+// Trailer of the exec responses in stdin mode holding the exit code
+const execExitCodeHeader = "X-LXD-Exit-Code"
+
+// execStdinDecode decodes the exec request at the start of a request body
+// of type application/octet-stream, returning the rest of the body (after a
+// newline, if any) which is the input of the command.
+func execStdinDecode(body io.Reader, post *api.ContainerExecPost) (io.Reader, error) {
+	decoder := json.NewDecoder(body)
+	err := decoder.Decode(post)
+	if err != nil {
+		return nil, err
+	}
+
+	stdin := bufio.NewReader(io.MultiReader(decoder.Buffered(), body))
+	next, err := stdin.Peek(1)
+	if err == nil && next[0] == '\n' {
+		stdin.Discard(1)
+	}
+
+	return stdin, nil
+}
+
+// execStdinSpool writes the input of a command to an unlinked temporary file.
+func execStdinSpool(stdin io.Reader) (*os.File, error) {
+	f, err := ioutil.TempFile("", "lxd_exec_stdin_")
+	if err != nil {
+		return nil, err
+	}
+	os.Remove(f.Name())
+
+	_, err = io.Copy(f, stdin)
+	if err == nil {
+		_, err = f.Seek(0, 0)
+	}
+	if err != nil {
+		f.Close()
+		return nil, err
+	}
+
+	return f, nil
+}
+
+// execStdinResponse runs a command with the input sent in the request body,
+// streaming its standard output as the response body and sending its exit
+// code as a trailer.
+type execStdinResponse struct {
+	req       *http.Request
+	container container
+	post      api.ContainerExecPost
+	env       map[string]string
+	stdin     *os.File
+}
+
+// execStdin returns the response running a command in stdin mode.
+//
+// The request body can't be read anymore once the response started, so the
+// input is read in full before the command starts.
+func execStdin(r *http.Request, c container, post api.ContainerExecPost, env map[string]string, stdin io.Reader) Response {
+	f, err := execStdinSpool(stdin)
+	if err != nil {
+		return BadRequest(err)
+	}
+
+	return &execStdinResponse{req: r, container: c, post: post, env: env, stdin: f}
+}
+
+func (r *execStdinResponse) Render(w http.ResponseWriter) error {
+	defer r.stdin.Close()
+
+	stdoutR, stdoutW, err := os.Pipe()
+	if err != nil {
+		return err
+	}
+	defer stdoutR.Close()
+
+	cmd, _, attachedPid, err := r.container.Exec(r.post.Command, r.env, r.stdin, stdoutW, nil, false)
+	stdoutW.Close()
+	if err != nil {
+		return err
+	}
+
+	// Terminate the command on timeout or when the client goes away
+	ctx := r.req.Context()
+	if r.post.Timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, time.Duration(r.post.Timeout)*time.Second)
+		defer cancel()
+	}
+
+	done := make(chan struct{})
+	go func() {
+		select {
+		case <-ctx.Done():
+			logger.Debug("Terminating command run in stdin mode", log.Ctx{"container": r.container.Name(), "err": ctx.Err()})
+			execTerminate(attachedPid, done)
+		case <-done:
+		}
+	}()
+
+	w.Header().Set("Content-Type", "application/octet-stream")
+	w.Header().Set("Trailer", execExitCodeHeader)
+	w.WriteHeader(http.StatusOK)
+
+	flusher, _ := w.(http.Flusher)
+	buf := make([]byte, 32*1024)
+	for {
+		n, err := stdoutR.Read(buf)
+		if n > 0 {
+			_, err := w.Write(buf[:n])
+			if err != nil {
+				break
+			}
+
+			if flusher != nil {
+				flusher.Flush()
+			}
+		}
+
+		if err != nil {
+			break
+		}
+	}
+
+	err = cmd.Wait()
+	close(done)
+
+	w.Header().Set(execExitCodeHeader, strconv.Itoa(execExitCode(err)))
+	return nil
+}
+
+func (r *execStdinResponse) String() string {
+	return fmt.Sprintf("exec of %v in stdin mode", r.post.Command)
+}
@@ -0,0 +1,48 @@
+package main
+
+import (
+	"bytes"
+	"io/ioutil"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/lxc/lxd/shared/api"
+)
+
+func TestExecStdinDecode(t *testing.T) {
+	body := bytes.NewBufferString(`{"command": ["sh"], "mode": "stdin"}` + "\necho hello\n")
+
+	post := api.ContainerExecPost{}
+	stdin, err := execStdinDecode(body, &post)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"sh"}, post.Command)
+	assert.Equal(t, "stdin", post.Mode)
+
+	input, err := ioutil.ReadAll(stdin)
+	require.NoError(t, err)
+	assert.Equal(t, "echo hello\n", string(input))
+
+	// Binary input right after the request
+	body = bytes.NewBufferString(`{"command": ["cat"], "mode": "stdin"}` + "\x00\x01\x02")
+	stdin, err = execStdinDecode(body, &post)
+	require.NoError(t, err)
+
+	input, err = ioutil.ReadAll(stdin)
+	require.NoError(t, err)
+	assert.Equal(t, []byte{0, 1, 2}, input)
+
+	_, err = execStdinDecode(bytes.NewBufferString("echo hello"), &post)
+	assert.Error(t, err)
+}
+
+func TestExecStdinSpool(t *testing.T) {
+	f, err := execStdinSpool(bytes.NewBufferString("echo hello\n"))
+	require.NoError(t, err)
+	defer f.Close()
+
+	input, err := ioutil.ReadAll(f)
+	require.NoError(t, err)
+	assert.Equal(t, "echo hello\n", string(input))
+}
@@ -0,0 +1,217 @@
+package main
+
+import (
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/lxc/lxd/lxd/state"
+	"github.com/lxc/lxd/shared/logger"
+
+	log "github.com/lxc/lxd/shared/log15"
+)
+
+// The exit statuses of the init processes of the containers aren't passed to
+// the post-stop hook by liblxc, they're instead collected from the exit
+// events of the proc connector of the kernel.
+const (
+	containerExitCnIdxProc         = 1
+	containerExitCnValProc         = 1
+	containerExitProcCnMcastListen = 1
+	containerExitProcEventExit     = 0x80000000
+)
+
+// How long the post-stop hook waits for the exit event of the init process
+const containerExitTimeout = time.Second
+
+type containerExit struct {
+	pid    int
+	status syscall.WaitStatus
+	done   chan struct{}
+}
+
+var containerExitsLock sync.Mutex
+
+// Init processes watched, by container ID and by PID
+var containerExits = map[int]*containerExit{}
+var containerExitPids = map[int]*containerExit{}
+
+// containerExitListen subscribes to the exit events of the processes of the
+// host, recording those of the watched init processes.
+func containerExitListen() error {
+	fd, err := syscall.Socket(syscall.AF_NETLINK, syscall.SOCK_DGRAM|syscall.SOCK_CLOEXEC, syscall.NETLINK_CONNECTOR)
+	if err != nil {
+		return err
+	}
+
+	// The kernel picks the port ID, the uevent listener using the PID
+	err = syscall.Bind(fd, &syscall.SockaddrNetlink{Family: syscall.AF_NETLINK, Groups: containerExitCnIdxProc})
+	if err != nil {
+		syscall.Close(fd)
+		return err
+	}
+
+	_, err = syscall.Write(fd, containerExitListenMessage())
+	if err != nil {
+		syscall.Close(fd)
+		return err
+	}
+
+	go func() {
+		b := make([]byte, 8192)
+		for {
+			n, err := syscall.Read(fd, b)
+			if err != nil {
+				if err == syscall.ENOBUFS {
+					logger.Warn("Lost some exit events of the container init processes")
+				}
+
+				continue
+			}
+
+			msgs, err := syscall.ParseNetlinkMessage(b[:n])
+			if err != nil {
+				continue
+			}
+
+			for _, msg := range msgs {
+				pid, status, ok := containerExitParse(msg.Data)
+				if !ok {
+					continue
+				}
+
+				containerExitsLock.Lock()
+				exit, ok := containerExitPids[pid]
+				if ok {
+					delete(containerExitPids, pid)
+					exit.status = status
+					close(exit.done)
+				}
+				containerExitsLock.Unlock()
+			}
+		}
+	}()
+
+	return nil
+}
+
+// containerExitListenMessage returns the message subscribing a netlink socket
+// to the events of the proc connector.
+func containerExitListenMessage() []byte {
+	msg := make([]byte, syscall.NLMSG_HDRLEN+20+4)
+	seccompNativeEndian.PutUint32(msg[0:4], uint32(len(msg)))
+	seccompNativeEndian.PutUint16(msg[4:6], syscall.NLMSG_DONE)
+
+	cn := msg[syscall.NLMSG_HDRLEN:]
+	seccompNativeEndian.PutUint32(cn[0:4], containerExitCnIdxProc)
+	seccompNativeEndian.PutUint32(cn[4:8], containerExitCnValProc)
+	seccompNativeEndian.PutUint16(cn[16:18], 4)
+	seccompNativeEndian.PutUint32(cn[20:24], containerExitProcCnMcastListen)
+
+	return msg
+}
+
+// containerExitParse returns the PID and wait status of a process from an
+// event of the proc connector, if it's the exit of a whole process.
+func containerExitParse(cn []byte) (int, syscall.WaitStatus, bool) {
+	// Connector header followed by the event and the PID, TGID and exit
+	// code of the exit event
+	if len(cn) < 20+16+12 {
+		return 0, 0, false
+	}
+
+	event := cn[20:]
+	if seccompNativeEndian.Uint32(event[0:4]) != containerExitProcEventExit {
+		return 0, 0, false
+	}
+
+	pid := int(seccompNativeEndian.Uint32(event[16:20]))
+	tgid := int(seccompNativeEndian.Uint32(event[20:24]))
+	if pid != tgid {
+		return 0, 0, false
+	}
+
+	return pid, syscall.WaitStatus(seccompNativeEndian.Uint32(event[24:28])), true
+}
+
+// containerExitWatch records the exit status of the init process of a
+// container which just started.
+func containerExitWatch(c container) {
+	pid := c.InitPID()
+	if pid <= 0 {
+		return
+	}
+
+	exit := &containerExit{pid: pid, done: make(chan struct{})}
+
+	containerExitsLock.Lock()
+	defer containerExitsLock.Unlock()
+
+	old, ok := containerExits[c.Id()]
+	if ok {
+		delete(containerExitPids, old.pid)
+	}
+
+	containerExits[c.Id()] = exit
+	containerExitPids[pid] = exit
+}
+
+// containerExitWatchRunning watches the init processes of the containers
+// already running when LXD starts.
+func containerExitWatchRunning(s *state.State) {
+	containers, err := containerLoadNodeAll(s)
+	if err != nil {
+		logger.Warn("Failed to load the containers to watch the exit of", log.Ctx{"err": err})
+		return
+	}
+
+	for _, c := range containers {
+		if c.IsRunning() {
+			containerExitWatch(c)
+		}
+	}
+}
+
+// containerExitFailed returns whether the init process of a container which
+// stopped failed, that is exited with a non-zero code or was killed by a
+// signal other than those of a shutdown or reboot from within the container.
+// Stops whose exit status isn't known aren't reported as failures.
+func containerExitFailed(c container) bool {
+	containerExitsLock.Lock()
+	exit, ok := containerExits[c.Id()]
+	delete(containerExits, c.Id())
+	containerExitsLock.Unlock()
+
+	if !ok {
+		return false
+	}
+
+	select {
+	case <-exit.done:
+	case <-time.After(containerExitTimeout):
+		containerExitsLock.Lock()
+		delete(containerExitPids, exit.pid)
+		containerExitsLock.Unlock()
+
+		logger.Warn("Unknown exit status of the container init process", log.Ctx{"container": c.Name(), "pid": exit.pid})
+		return false
+	}
+
+	return containerExitStatusFailed(exit.status)
+}
+
+// containerExitStatusFailed returns whether the wait status of the init
+// process of a container is the one of a failure.
+func containerExitStatusFailed(status syscall.WaitStatus) bool {
+	if status.Exited() {
+		return status.ExitStatus() != 0
+	}
+
+	// The kernel kills the init process of a PID namespace calling
+	// reboot(2) with SIGINT for a shutdown and SIGHUP for a reboot
+	if status.Signaled() {
+		return status.Signal() != syscall.SIGINT && status.Signal() != syscall.SIGHUP
+	}
+
+	return true
+}
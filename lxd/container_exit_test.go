@@ -0,0 +1,60 @@
+package main
+
+import (
+	"syscall"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// containerExitTestEvent returns a proc connector event of the given type
+// for a task of a process.
+func containerExitTestEvent(what uint32, pid int, tgid int, code uint32) []byte {
+	cn := make([]byte, 20+40)
+	seccompNativeEndian.PutUint32(cn[0:4], containerExitCnIdxProc)
+	seccompNativeEndian.PutUint32(cn[4:8], containerExitCnValProc)
+	seccompNativeEndian.PutUint16(cn[16:18], 40)
+
+	event := cn[20:]
+	seccompNativeEndian.PutUint32(event[0:4], what)
+	seccompNativeEndian.PutUint32(event[16:20], uint32(pid))
+	seccompNativeEndian.PutUint32(event[20:24], uint32(tgid))
+	seccompNativeEndian.PutUint32(event[24:28], code)
+	seccompNativeEndian.PutUint32(event[28:32], uint32(syscall.SIGCHLD))
+
+	return cn
+}
+
+func TestContainerExitParse(t *testing.T) {
+	pid, status, ok := containerExitParse(containerExitTestEvent(containerExitProcEventExit, 42, 42, 1<<8))
+	assert.True(t, ok)
+	assert.Equal(t, 42, pid)
+	assert.True(t, status.Exited())
+	assert.Equal(t, 1, status.ExitStatus())
+
+	// Threads
+	_, _, ok = containerExitParse(containerExitTestEvent(containerExitProcEventExit, 43, 42, 0))
+	assert.False(t, ok)
+
+	// Forks
+	_, _, ok = containerExitParse(containerExitTestEvent(0x00000001, 42, 42, 0))
+	assert.False(t, ok)
+
+	_, _, ok = containerExitParse(make([]byte, 20))
+	assert.False(t, ok)
+}
+
+func TestContainerExitStatusFailed(t *testing.T) {
+	cases := map[syscall.WaitStatus]bool{
+		0:                                   false,
+		1 << 8:                              true,
+		syscall.WaitStatus(syscall.SIGINT):  false,
+		syscall.WaitStatus(syscall.SIGHUP):  false,
+		syscall.WaitStatus(syscall.SIGKILL): true,
+		syscall.WaitStatus(syscall.SIGSEGV): true,
+	}
+
+	for status, failed := range cases {
+		assert.Equal(t, failed, containerExitStatusFailed(status), "%#x", uint32(status))
+	}
+}
@@ -39,6 +39,11 @@ func containerFileHandler(d *Daemon, r *http.Request) Response {
 	case "GET":
 		return containerFileGet(c, path, r)
 	case "POST":
+		resp := containerQuotaCheck(d, c)
+		if resp != nil {
+			return resp
+		}
+
 		return containerFilePost(c, path, r)
 	case "DELETE":
 		return containerFileDelete(c, path, r)
@@ -75,6 +80,19 @@ func containerFileGet(c container, path string, r *http.Request) Response {
 		"X-LXD-type": type_,
 	}
 
+	// Add the POSIX ACL if the filesystem supports them
+	if type_ == "file" || type_ == "directory" {
+		acl, err := c.FileACLGet(path)
+		if err != nil && err != errACLNotSupported {
+			os.Remove(temp.Name())
+			return SmartError(err)
+		}
+
+		if acl != "" {
+			headers["X-LXD-ACL"] = acl
+		}
+	}
+
 	if type_ == "file" || type_ == "symlink" {
 		// Make a file response struct
 		files := make([]fileResponseEntry, 1)
@@ -100,6 +118,18 @@ func containerFilePost(c container, path string, r *http.Request) Response {
 		return BadRequest(fmt.Errorf("Bad file write mode: %s", write))
 	}
 
+	acl := r.Header.Get("X-LXD-ACL")
+	if acl != "" {
+		if type_ == "symlink" {
+			return BadRequest(fmt.Errorf("ACLs can't be set on symlinks"))
+		}
+
+		_, _, err := aclParse(acl)
+		if err != nil {
+			return BadRequest(err)
+		}
+	}
+
 	if type_ == "file" {
 		// Write file content to a tempfile
 		temp, err := ioutil.TempFile("", "lxd_forkputfile_")
@@ -122,7 +152,7 @@ func containerFilePost(c container, path string, r *http.Request) Response {
 			return InternalError(err)
 		}
 
-		return EmptySyncResponse
+		return containerFileACLSet(c, path, acl)
 	} else if type_ == "symlink" {
 		target, err := ioutil.ReadAll(r.Body)
 		if err != nil {
@@ -139,12 +169,28 @@ func containerFilePost(c container, path string, r *http.Request) Response {
 		if err != nil {
 			return InternalError(err)
 		}
-		return EmptySyncResponse
+		return containerFileACLSet(c, path, acl)
 	} else {
 		return BadRequest(fmt.Errorf("Bad file type: %s", type_))
 	}
 }
 
+// containerFileACLSet applies the ACL passed along with a pushed file.
+func containerFileACLSet(c container, path string, acl string) Response {
+	if acl == "" {
+		return EmptySyncResponse
+	}
+
+	err := c.FileACLSet(path, acl)
+	if err == errACLNotSupported {
+		return BadRequest(err)
+	} else if err != nil {
+		return InternalError(err)
+	}
+
+	return EmptySyncResponse
+}
+
 func containerFileDelete(c container, path string, r *http.Request) Response {
 	err := c.FileRemove(path)
 	if err != nil {
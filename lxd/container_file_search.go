@@ -0,0 +1,377 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gorilla/mux"
+	"golang.org/x/sys/unix"
+
+	"github.com/lxc/lxd/shared/api"
+)
+
+var containerFileSearchCmd = Command{
+	name: "containers/{name}/files/search",
+	get:  containerFileSearchGet,
+}
+
+// fileSearch walks a directory tree of a container rootfs looking for the
+// files matching a pattern. Paths are resolved within the rootfs, symlinks
+// pointing to absolute paths staying inside of it.
+//
+// The container may change its rootfs while it's searched, so all the paths
+// are resolved one component at a time relative to a pinned file descriptor
+// of the rootfs, never following symlinks behind the search's back.
+type fileSearch struct {
+	rootfs       string
+	match        func(path string) bool
+	maxDepth     int
+	maxResults   int
+	maxLinkDepth int
+	results      []api.ContainerFileSearchResult
+
+	rootfd int
+}
+
+// run searches the directory at the given path of the container.
+func (s *fileSearch) run(root string) error {
+	var err error
+	s.rootfd, err = unix.Open(s.rootfs, unix.O_PATH|unix.O_DIRECTORY|unix.O_CLOEXEC, 0)
+	if err != nil {
+		return err
+	}
+	defer unix.Close(s.rootfd)
+
+	root = filepath.Clean("/" + root)
+	fd, realRoot, err := s.resolve(root, s.maxLinkDepth)
+	if err != nil {
+		return err
+	}
+	defer unix.Close(fd)
+
+	var st unix.Stat_t
+	err = unix.Fstat(fd, &st)
+	if err != nil {
+		return err
+	}
+
+	if st.Mode&unix.S_IFMT != unix.S_IFDIR {
+		return unix.ENOTDIR
+	}
+
+	s.walk(root, realRoot, fd, 1, 0)
+	return nil
+}
+
+// resolve returns a file descriptor of what a path of the container points to
+// once all its symlinks are followed, up to the given number of symlinks,
+// along with its resolved path.
+func (s *fileSearch) resolve(path string, maxLinks int) (int, string, error) {
+	rootfd, err := unix.Openat(s.rootfd, ".", unix.O_PATH|unix.O_DIRECTORY|unix.O_CLOEXEC, 0)
+	if err != nil {
+		return -1, "", err
+	}
+
+	// File descriptors of the resolved path components
+	fds := []int{rootfd}
+	names := []string{}
+	defer func() {
+		for _, fd := range fds {
+			unix.Close(fd)
+		}
+	}()
+
+	remaining := strings.Split(path, "/")
+	links := 0
+
+	for len(remaining) > 0 {
+		name := remaining[0]
+		remaining = remaining[1:]
+
+		if name == "" || name == "." {
+			continue
+		}
+
+		if name == ".." {
+			if len(names) > 0 {
+				unix.Close(fds[len(fds)-1])
+				fds = fds[:len(fds)-1]
+				names = names[:len(names)-1]
+			}
+
+			continue
+		}
+
+		fd, err := unix.Openat(fds[len(fds)-1], name, unix.O_PATH|unix.O_NOFOLLOW|unix.O_CLOEXEC, 0)
+		if err != nil {
+			return -1, "", err
+		}
+
+		var st unix.Stat_t
+		err = unix.Fstat(fd, &st)
+		if err != nil {
+			unix.Close(fd)
+			return -1, "", err
+		}
+
+		if st.Mode&unix.S_IFMT != unix.S_IFLNK {
+			fds = append(fds, fd)
+			names = append(names, name)
+			continue
+		}
+
+		links++
+		if links > maxLinks {
+			unix.Close(fd)
+			return -1, "", fmt.Errorf("Too many levels of symbolic links")
+		}
+
+		target, err := fileSearchReadlink(fd)
+		unix.Close(fd)
+		if err != nil {
+			return -1, "", err
+		}
+
+		if filepath.IsAbs(target) {
+			for _, fd := range fds[1:] {
+				unix.Close(fd)
+			}
+			fds = fds[:1]
+			names = names[:0]
+		}
+
+		remaining = append(strings.Split(target, "/"), remaining...)
+	}
+
+	// Hand the last file descriptor over to the caller
+	fd := fds[len(fds)-1]
+	fds = fds[:len(fds)-1]
+
+	return fd, "/" + strings.Join(names, "/"), nil
+}
+
+// fileSearchReadlink returns the target of the symlink opened as fd.
+func fileSearchReadlink(fd int) (string, error) {
+	buf := make([]byte, unix.PathMax)
+	n, err := unix.Readlinkat(fd, "", buf)
+	if err != nil {
+		return "", err
+	}
+
+	return string(buf[:n]), nil
+}
+
+// walk goes through the directory opened as dirfd, path being the path it's
+// found at and realPath the path of the directory once symlinks are resolved.
+func (s *fileSearch) walk(path string, realPath string, dirfd int, depth int, linkDepth int) {
+	if depth > s.maxDepth {
+		return
+	}
+
+	fd, err := unix.Openat(dirfd, ".", unix.O_RDONLY|unix.O_DIRECTORY|unix.O_CLOEXEC, 0)
+	if err != nil {
+		return
+	}
+
+	dir := os.NewFile(uintptr(fd), path)
+	names, err := dir.Readdirnames(-1)
+	dir.Close()
+	if err != nil {
+		// Unreadable or vanished directory
+		return
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		if len(s.results) >= s.maxResults {
+			return
+		}
+
+		var st unix.Stat_t
+		err := unix.Fstatat(dirfd, name, &st, unix.AT_SYMLINK_NOFOLLOW)
+		if err != nil {
+			continue
+		}
+
+		entryPath := filepath.Join(path, name)
+		entryRealPath := filepath.Join(realPath, name)
+		fileType := st.Mode & unix.S_IFMT
+
+		if s.match(entryPath) {
+			s.results = append(s.results, api.ContainerFileSearchResult{
+				Path:       entryPath,
+				Type:       fileSearchType(fileType),
+				Size:       st.Size,
+				ModifiedAt: time.Unix(st.Mtim.Unix()),
+			})
+		}
+
+		if fileType == unix.S_IFDIR {
+			// Fails if it was swapped for a symlink meanwhile
+			fd, err := unix.Openat(dirfd, name, unix.O_PATH|unix.O_DIRECTORY|unix.O_NOFOLLOW|unix.O_CLOEXEC, 0)
+			if err != nil {
+				continue
+			}
+
+			s.walk(entryPath, entryRealPath, fd, depth+1, linkDepth)
+			unix.Close(fd)
+			continue
+		}
+
+		if fileType != unix.S_IFLNK || linkDepth >= s.maxLinkDepth {
+			continue
+		}
+
+		// Follow the symlinks to directories
+		fd, target, err := s.resolve(entryRealPath, s.maxLinkDepth-linkDepth)
+		if err != nil {
+			continue
+		}
+
+		err = unix.Fstat(fd, &st)
+		if err == nil && st.Mode&unix.S_IFMT == unix.S_IFDIR {
+			s.walk(entryPath, target, fd, depth+1, linkDepth+1)
+		}
+		unix.Close(fd)
+	}
+}
+
+func fileSearchType(fileType uint32) string {
+	switch fileType {
+	case unix.S_IFDIR:
+		return "directory"
+	case unix.S_IFLNK:
+		return "symlink"
+	case unix.S_IFREG:
+		return "file"
+	}
+
+	return "other"
+}
+
+// fileSearchMatcher returns the function matching the paths of the container
+// against a glob pattern (matched against the file name unless it contains
+// a slash) or a regular expression (matched against the path).
+func fileSearchMatcher(pattern string, mode string) (func(path string) bool, error) {
+	switch mode {
+	case "", "glob":
+		_, err := filepath.Match(pattern, "")
+		if err != nil {
+			return nil, fmt.Errorf("Invalid glob pattern '%s'", pattern)
+		}
+
+		if strings.Contains(pattern, "/") {
+			return func(path string) bool {
+				match, _ := filepath.Match(pattern, path)
+				return match
+			}, nil
+		}
+
+		return func(path string) bool {
+			match, _ := filepath.Match(pattern, filepath.Base(path))
+			return match
+		}, nil
+	case "regex":
+		re, err := regexp.Compile(pattern)
+		if err != nil {
+			return nil, fmt.Errorf("Invalid regular expression '%s': %v", pattern, err)
+		}
+
+		return re.MatchString, nil
+	}
+
+	return nil, fmt.Errorf("Invalid search mode '%s'", mode)
+}
+
+// fileSearchLimit parses a positive integer query parameter.
+func fileSearchLimit(r *http.Request, name string, defaultValue int) (int, error) {
+	value := r.FormValue(name)
+	if value == "" {
+		return defaultValue, nil
+	}
+
+	limit, err := strconv.Atoi(value)
+	if err != nil || limit < 0 {
+		return -1, fmt.Errorf("Invalid %s '%s'", name, value)
+	}
+
+	return limit, nil
+}
+
+func containerFileSearchGet(d *Daemon, r *http.Request) Response {
+	project := projectParam(r)
+	name := mux.Vars(r)["name"]
+
+	response, err := ForwardedResponseIfContainerIsRemote(d, r, project, name)
+	if err != nil {
+		return SmartError(err)
+	}
+	if response != nil {
+		return response
+	}
+
+	c, err := containerLoadByProjectAndName(d.State(), project, name)
+	if err != nil {
+		return SmartError(err)
+	}
+
+	pattern := r.FormValue("pattern")
+	if pattern == "" {
+		return BadRequest(fmt.Errorf("Missing pattern argument"))
+	}
+
+	match, err := fileSearchMatcher(pattern, r.FormValue("mode"))
+	if err != nil {
+		return BadRequest(err)
+	}
+
+	search := &fileSearch{match: match, results: []api.ContainerFileSearchResult{}}
+
+	search.maxDepth, err = fileSearchLimit(r, "depth", 20)
+	if err != nil {
+		return BadRequest(err)
+	}
+
+	search.maxResults, err = fileSearchLimit(r, "limit", 10000)
+	if err != nil {
+		return BadRequest(err)
+	}
+
+	search.maxLinkDepth, err = fileSearchLimit(r, "symlink_depth", 5)
+	if err != nil {
+		return BadRequest(err)
+	}
+
+	ourStart, err := c.StorageStart()
+	if err != nil {
+		return InternalError(err)
+	}
+	if ourStart {
+		defer c.StorageStop()
+	}
+
+	search.rootfs = c.RootfsPath()
+
+	root := filepath.Clean("/" + r.FormValue("root"))
+	err = search.run(root)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return NotFound(fmt.Errorf("Directory '%s' not found", root))
+		}
+
+		if err == unix.ENOTDIR {
+			return BadRequest(fmt.Errorf("'%s' isn't a directory", root))
+		}
+
+		return BadRequest(err)
+	}
+
+	return SyncResponse(true, search.results)
+}
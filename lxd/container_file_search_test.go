@@ -0,0 +1,82 @@
+package main
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/sys/unix"
+)
+
+func TestFileSearch(t *testing.T) {
+	rootfs, err := ioutil.TempDir("", "lxd_file_search_")
+	require.NoError(t, err)
+	defer os.RemoveAll(rootfs)
+
+	require.NoError(t, os.MkdirAll(filepath.Join(rootfs, "var", "log", "nginx"), 0755))
+	require.NoError(t, os.MkdirAll(filepath.Join(rootfs, "srv"), 0755))
+	require.NoError(t, ioutil.WriteFile(filepath.Join(rootfs, "var", "log", "syslog.log"), []byte("abc"), 0644))
+	require.NoError(t, ioutil.WriteFile(filepath.Join(rootfs, "var", "log", "nginx", "access.log"), []byte("abcd"), 0644))
+	require.NoError(t, ioutil.WriteFile(filepath.Join(rootfs, "var", "log", "nginx", "access.txt"), []byte(""), 0644))
+
+	// Absolute symlinks are resolved within the rootfs, loops are cut
+	require.NoError(t, os.Symlink("/var/log/nginx", filepath.Join(rootfs, "srv", "logs")))
+	require.NoError(t, os.Symlink("/srv", filepath.Join(rootfs, "srv", "loop")))
+
+	match, err := fileSearchMatcher("*.log", "glob")
+	require.NoError(t, err)
+
+	search := &fileSearch{rootfs: rootfs, match: match, maxDepth: 20, maxResults: 100, maxLinkDepth: 5}
+	require.NoError(t, search.run("/var"))
+
+	paths := []string{}
+	for _, result := range search.results {
+		paths = append(paths, result.Path)
+	}
+	assert.Equal(t, []string{"/var/log/nginx/access.log", "/var/log/syslog.log"}, paths)
+	assert.Equal(t, int64(4), search.results[0].Size)
+	assert.Equal(t, "file", search.results[0].Type)
+
+	match, err = fileSearchMatcher("^/srv/logs/.*\\.log$", "regex")
+	require.NoError(t, err)
+
+	search = &fileSearch{rootfs: rootfs, match: match, maxDepth: 20, maxResults: 100, maxLinkDepth: 5}
+	require.NoError(t, search.run("/srv"))
+	require.Len(t, search.results, 1)
+	assert.Equal(t, "/srv/logs/access.log", search.results[0].Path)
+
+	// Results are capped
+	match, err = fileSearchMatcher("*", "")
+	require.NoError(t, err)
+
+	search = &fileSearch{rootfs: rootfs, match: match, maxDepth: 20, maxResults: 2, maxLinkDepth: 5}
+	require.NoError(t, search.run("/"))
+	assert.Len(t, search.results, 2)
+
+	// Relative symlinks going up the tree
+	require.NoError(t, os.Symlink("../var/log", filepath.Join(rootfs, "srv", "varlog")))
+
+	match, err = fileSearchMatcher("syslog.log", "glob")
+	require.NoError(t, err)
+
+	search = &fileSearch{rootfs: rootfs, match: match, maxDepth: 20, maxResults: 100, maxLinkDepth: 5}
+	require.NoError(t, search.run("/srv/varlog"))
+	require.Len(t, search.results, 1)
+	assert.Equal(t, "/srv/varlog/syslog.log", search.results[0].Path)
+
+	// Missing directories and files
+	err = search.run("/missing")
+	assert.True(t, os.IsNotExist(err))
+
+	err = search.run("/var/log/syslog.log")
+	assert.Equal(t, unix.ENOTDIR, err)
+
+	_, err = fileSearchMatcher("[", "glob")
+	assert.Error(t, err)
+
+	_, err = fileSearchMatcher("*", "fuzzy")
+	assert.Error(t, err)
+}
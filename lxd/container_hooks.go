@@ -0,0 +1,118 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"time"
+
+	"github.com/lxc/lxd/lxd/db"
+	"github.com/lxc/lxd/shared/api"
+	"github.com/lxc/lxd/shared/logger"
+
+	log "github.com/lxc/lxd/shared/log15"
+)
+
+// Lifecycle events of the containers and the config key of the URL they're
+// posted to
+var containerHookKeys = map[string]string{
+	"start":    "hooks.start_url",
+	"stop":     "hooks.stop_url",
+	"crash":    "hooks.stop_url",
+	"snapshot": "hooks.snapshot_url",
+}
+
+// How many times the delivery of an event to a container hook is attempted
+const containerHookAttempts = 3
+
+type containerHook struct {
+	url    string
+	secret string
+	event  api.ContainerHookEvent
+}
+
+var containerHooksQueue = make(chan containerHook, 1024)
+
+// containerHookEvent returns the hook of a container an event is posted to,
+// if any.
+func containerHookEvent(c container, event string, metadata map[string]interface{}) (containerHook, bool) {
+	config := c.ExpandedConfig()
+
+	url := config[containerHookKeys[event]]
+	if url == "" {
+		return containerHook{}, false
+	}
+
+	if metadata == nil {
+		metadata = map[string]interface{}{}
+	}
+
+	hook := containerHook{
+		url:    url,
+		secret: config["hooks.secret"],
+		event: api.ContainerHookEvent{
+			Container: c.Name(),
+			Project:   c.Project(),
+			Event:     event,
+			Timestamp: time.Now().UTC(),
+			Metadata:  metadata,
+		},
+	}
+
+	return hook, true
+}
+
+// containerHookSend queues a lifecycle event of a container for delivery to
+// its hook, events are dropped if the queue is full.
+func containerHookSend(c container, event string, metadata map[string]interface{}) {
+	hook, ok := containerHookEvent(c, event, metadata)
+	if !ok {
+		return
+	}
+
+	select {
+	case containerHooksQueue <- hook:
+	default:
+		logger.Warn("Dropped container hook event, the queue is full", log.Ctx{"container": c.Name(), "project": c.Project(), "event": event})
+	}
+}
+
+// containerHooksDispatch delivers the queued events to the container hooks.
+func containerHooksDispatch(d *Daemon) {
+	for hook := range containerHooksQueue {
+		go containerHookDeliver(d, hook)
+	}
+}
+
+// containerHookPost posts an event to a container hook, signed with the
+// secret of the container like the events posted to the webhooks.
+func containerHookPost(client *http.Client, hook containerHook) error {
+	body, err := json.Marshal(hook.event)
+	if err != nil {
+		return err
+	}
+
+	return webhookPost(client, db.Webhook{URL: hook.url, Secret: hook.secret}, body)
+}
+
+// containerHookDeliver posts an event to a container hook, retrying with an
+// exponential backoff on failure.
+func containerHookDeliver(d *Daemon, hook containerHook) {
+	client := &http.Client{
+		Timeout:   10 * time.Second,
+		Transport: &http.Transport{Proxy: d.proxy},
+	}
+
+	var err error
+	for attempt := 0; attempt < containerHookAttempts; attempt++ {
+		if attempt > 0 {
+			time.Sleep(time.Second * time.Duration(1<<uint(attempt-1)))
+		}
+
+		err = containerHookPost(client, hook)
+		if err == nil {
+			return
+		}
+	}
+
+	logger.Warn("Failed to deliver container hook event", log.Ctx{"container": hook.event.Container, "project": hook.event.Project, "event": hook.event.Event, "err": err})
+}
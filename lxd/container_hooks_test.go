@@ -0,0 +1,61 @@
+package main
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/lxc/lxd/shared/api"
+)
+
+func TestContainerHookPost(t *testing.T) {
+	var body []byte
+	var signature string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ = ioutil.ReadAll(r.Body)
+		signature = r.Header.Get("X-LXD-Signature")
+	}))
+	defer server.Close()
+
+	hook := containerHook{
+		url:    server.URL,
+		secret: "foo",
+		event: api.ContainerHookEvent{
+			Container: "c1",
+			Project:   "default",
+			Event:     "snapshot",
+			Timestamp: time.Date(2019, 1, 1, 0, 0, 0, 0, time.UTC),
+			Metadata:  map[string]interface{}{"snapshot_name": "snap0"},
+		},
+	}
+
+	err := containerHookPost(server.Client(), hook)
+	require.NoError(t, err)
+
+	event := api.ContainerHookEvent{}
+	require.NoError(t, json.Unmarshal(body, &event))
+	assert.Equal(t, hook.event, event)
+	assert.Equal(t, webhookSignature("foo", body), signature)
+
+	// No signature without a secret
+	hook.secret = ""
+	err = containerHookPost(server.Client(), hook)
+	require.NoError(t, err)
+	assert.Equal(t, "", signature)
+}
+
+func TestContainerHookPostFailure(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusInternalServerError)
+	}))
+	defer server.Close()
+
+	err := containerHookPost(server.Client(), containerHook{url: server.URL})
+	assert.Error(t, err)
+}
@@ -0,0 +1,331 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"syscall"
+
+	"github.com/lxc/lxd/lxd/util"
+	"github.com/lxc/lxd/shared"
+	"github.com/lxc/lxd/shared/logger"
+
+	log "github.com/lxc/lxd/shared/log15"
+)
+
+// isoBootConfigs lists, for each supported bootloader, the configuration
+// files looked for on the ISO image.
+var isoBootConfigs = []struct {
+	format string
+	globs  []string
+}{
+	{"syslinux", []string{"isolinux/*.cfg", "syslinux/*.cfg", "boot/isolinux/*.cfg", "boot/syslinux/*.cfg"}},
+	{"grub2", []string{"boot/grub/grub.cfg", "boot/grub/loopback.cfg", "boot/grub2/grub.cfg", "EFI/BOOT/grub.cfg"}},
+	{"systemd-boot", []string{"loader/entries/*.conf"}},
+}
+
+// isoRootImagesDefault lists the root filesystem images of the common live
+// ISO layouts (casper, live-boot and dracut).
+var isoRootImagesDefault = []string{
+	"casper/filesystem.squashfs",
+	"live/filesystem.squashfs",
+	"LiveOS/squashfs.img",
+}
+
+// isoParseBootConfig returns the kernel command line parameters found in the
+// configuration file of a bootloader.
+func isoParseBootConfig(format string, content string) []string {
+	params := []string{}
+
+	scanner := bufio.NewScanner(strings.NewReader(content))
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) < 2 {
+			continue
+		}
+
+		keyword := strings.ToLower(fields[0])
+		switch format {
+		case "syslinux":
+			if keyword == "append" {
+				params = append(params, fields[1:]...)
+			}
+		case "grub2":
+			// The first argument is the path to the kernel
+			if (keyword == "linux" || keyword == "linuxefi") && len(fields) > 2 {
+				params = append(params, fields[2:]...)
+			}
+		case "systemd-boot":
+			if keyword == "options" {
+				params = append(params, fields[1:]...)
+			}
+		}
+	}
+
+	return params
+}
+
+// isoBootParameters detects the bootloader of a mounted ISO image and returns
+// it along with the kernel command line parameters of its boot entries.
+func isoBootParameters(root string) (string, []string, error) {
+	for _, bootloader := range isoBootConfigs {
+		found := false
+		params := []string{}
+
+		for _, glob := range bootloader.globs {
+			paths, err := filepath.Glob(filepath.Join(root, glob))
+			if err != nil {
+				return "", nil, err
+			}
+			sort.Strings(paths)
+
+			for _, path := range paths {
+				content, err := ioutil.ReadFile(path)
+				if err != nil {
+					return "", nil, err
+				}
+
+				found = true
+				params = append(params, isoParseBootConfig(bootloader.format, string(content))...)
+			}
+		}
+
+		if found {
+			return bootloader.format, params, nil
+		}
+	}
+
+	return "", nil, fmt.Errorf("No syslinux, grub2 or systemd-boot configuration found")
+}
+
+// isoRootImages returns the candidate paths on the ISO image of the root
+// filesystem image, in order of preference, given the kernel command line
+// parameters of its bootloader.
+func isoRootImages(params []string) []string {
+	images := []string{}
+	add := func(image string) {
+		image = strings.TrimPrefix(filepath.Clean("/"+image), "/")
+		if !shared.StringInSlice(image, images) {
+			images = append(images, image)
+		}
+	}
+
+	dracut := false
+	liveDir := "LiveOS"
+	squashImg := "squashfs.img"
+	for _, param := range params {
+		fields := strings.SplitN(param, "=", 2)
+		value := ""
+		if len(fields) == 2 {
+			value = fields[1]
+		}
+
+		switch fields[0] {
+		case "live-media-path":
+			add(filepath.Join(value, "filesystem.squashfs"))
+		case "boot":
+			if value == "casper" || value == "live" {
+				add(filepath.Join(value, "filesystem.squashfs"))
+			}
+		case "rd.live.dir":
+			dracut = true
+			liveDir = value
+		case "rd.live.squashimg":
+			dracut = true
+			squashImg = value
+		case "root":
+			if strings.HasPrefix(value, "live:") {
+				dracut = true
+			}
+		}
+	}
+
+	if dracut {
+		add(filepath.Join(liveDir, squashImg))
+	}
+
+	for _, image := range isoRootImagesDefault {
+		add(image)
+	}
+
+	return images
+}
+
+// isoInitCommand returns the command to run as PID 1 from a root filesystem.
+func isoInitCommand(root string) (string, error) {
+	for _, init := range []string{"/init", "/sbin/init"} {
+		_, err := os.Lstat(filepath.Join(root, init))
+		if err == nil {
+			return init, nil
+		}
+	}
+
+	return "", fmt.Errorf("No /init or /sbin/init found in the root filesystem")
+}
+
+// isoMount mounts a filesystem image read-only through a loop device.
+func isoMount(source string, target string, fstype string) error {
+	err := os.MkdirAll(target, 0711)
+	if err != nil {
+		return err
+	}
+
+	_, err = shared.RunCommand("mount", "-t", fstype, "-o", "loop,ro", source, target)
+	if err != nil {
+		return fmt.Errorf("Failed to mount %s: %v", source, err)
+	}
+
+	return nil
+}
+
+// startIsoDevice mounts the live ISO image of the container, if any, and
+// sets the container up to boot from its root filesystem through a writable
+// overlay stored alongside the container.
+func (c *containerLXC) startIsoDevice() error {
+	for _, name := range c.expandedDevices.DeviceNames() {
+		m := c.expandedDevices[name]
+		if m["type"] != "iso" {
+			continue
+		}
+
+		err := c.insertIsoDevice(name, m)
+		if err != nil {
+			c.removeIsoDevices()
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (c *containerLXC) insertIsoDevice(name string, m map[string]string) error {
+	// Files from the image are owned by the host's root, so can't be
+	// shifted into an unprivileged container
+	if !c.IsPrivileged() {
+		return fmt.Errorf("ISO devices require a privileged container")
+	}
+
+	if !util.RuntimeLiblxcVersionAtLeast(2, 1, 0) {
+		return fmt.Errorf("ISO devices require liblxc 2.1 or higher")
+	}
+
+	if !shared.PathExists(m["source"]) {
+		return fmt.Errorf("ISO image %s doesn't exist", m["source"])
+	}
+
+	devPath := filepath.Join(c.DevicesPath(), fmt.Sprintf("iso.%s", strings.Replace(name, "/", "-", -1)))
+	isoPath := filepath.Join(devPath, "iso")
+	err := isoMount(m["source"], isoPath, "iso9660")
+	if err != nil {
+		return err
+	}
+
+	format, params, err := isoBootParameters(isoPath)
+	if err != nil {
+		return err
+	}
+
+	// Find the root filesystem, falling back to the ISO image itself
+	lowerPath := isoPath
+	for _, image := range isoRootImages(params) {
+		if !shared.PathExists(filepath.Join(isoPath, image)) {
+			continue
+		}
+
+		lowerPath = filepath.Join(devPath, "squashfs")
+		err = isoMount(filepath.Join(isoPath, image), lowerPath, "squashfs")
+		if err != nil {
+			return err
+		}
+
+		// dracut images wrap the actual root filesystem
+		rootImage := filepath.Join(lowerPath, "LiveOS", "rootfs.img")
+		if shared.PathExists(rootImage) {
+			lowerPath = filepath.Join(devPath, "rootfs")
+			err = isoMount(rootImage, lowerPath, "ext4")
+			if err != nil {
+				return err
+			}
+		}
+
+		break
+	}
+
+	init, err := isoInitCommand(lowerPath)
+	if err != nil {
+		return err
+	}
+
+	// liblxc creates the overlay work directory next to the upper one
+	upperPath := filepath.Join(c.Path(), "iso", "upper")
+	err = os.MkdirAll(upperPath, 0755)
+	if err != nil {
+		return err
+	}
+
+	err = lxcSetConfigItem(c.c, "lxc.rootfs.path", fmt.Sprintf("overlay:%s:%s", lowerPath, upperPath))
+	if err != nil {
+		return err
+	}
+
+	err = lxcSetConfigItem(c.c, "lxc.init.cmd", init)
+	if err != nil {
+		return err
+	}
+
+	logger.Debug("Booting container from ISO image", log.Ctx{"container": c.Name(), "source": m["source"], "bootloader": format, "rootfs": lowerPath, "init": init})
+	return nil
+}
+
+func (c *containerLXC) removeIsoDevices() error {
+	// Check that we indeed have devices to remove
+	if !shared.PathExists(c.DevicesPath()) {
+		return nil
+	}
+
+	// Load the directory listing
+	dents, err := ioutil.ReadDir(c.DevicesPath())
+	if err != nil {
+		return err
+	}
+
+	for _, f := range dents {
+		// Skip non-iso devices
+		if !strings.HasPrefix(f.Name(), "iso.") {
+			continue
+		}
+
+		// Unmount the filesystems, most nested first
+		devPath := filepath.Join(c.DevicesPath(), f.Name())
+		for _, mount := range []string{"rootfs", "squashfs", "iso"} {
+			mountPath := filepath.Join(devPath, mount)
+			if !shared.PathExists(mountPath) {
+				continue
+			}
+
+			if shared.IsMountPoint(mountPath) {
+				err := syscall.Unmount(mountPath, syscall.MNT_DETACH)
+				if err != nil {
+					logger.Error("Failed to unmount ISO device path", log.Ctx{"err": err, "path": mountPath})
+					continue
+				}
+			}
+
+			err := os.Remove(mountPath)
+			if err != nil {
+				logger.Error("Failed to remove ISO device path", log.Ctx{"err": err, "path": mountPath})
+			}
+		}
+
+		err := os.Remove(devPath)
+		if err != nil {
+			logger.Error("Failed to remove ISO device path", log.Ctx{"err": err, "path": devPath})
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,92 @@
+package main
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestIsoParseBootConfig(t *testing.T) {
+	syslinux := `DEFAULT live
+LABEL live
+  KERNEL /casper/vmlinuz
+  APPEND initrd=/casper/initrd boot=casper quiet splash ---
+`
+	assert.Equal(t, []string{"initrd=/casper/initrd", "boot=casper", "quiet", "splash", "---"}, isoParseBootConfig("syslinux", syslinux))
+
+	grub := `menuentry "Live" {
+	linux /live/vmlinuz boot=live components quiet
+	initrd /live/initrd.img
+}
+`
+	assert.Equal(t, []string{"boot=live", "components", "quiet"}, isoParseBootConfig("grub2", grub))
+
+	systemdBoot := `title Fedora Live
+linux /images/pxeboot/vmlinuz
+options root=live:CDLABEL=Fedora rd.live.image
+`
+	assert.Equal(t, []string{"root=live:CDLABEL=Fedora", "rd.live.image"}, isoParseBootConfig("systemd-boot", systemdBoot))
+	assert.Equal(t, []string{}, isoParseBootConfig("grub2", systemdBoot))
+}
+
+func TestIsoRootImages(t *testing.T) {
+	images := isoRootImages([]string{"boot=live", "live-media-path=/custom"})
+	assert.Equal(t, []string{
+		"live/filesystem.squashfs",
+		"custom/filesystem.squashfs",
+		"casper/filesystem.squashfs",
+		"LiveOS/squashfs.img",
+	}, images)
+
+	images = isoRootImages([]string{"root=live:CDLABEL=Fedora", "rd.live.dir=/images", "rd.live.squashimg=root.img"})
+	assert.Equal(t, "images/root.img", images[0])
+
+	images = isoRootImages(nil)
+	assert.Equal(t, isoRootImagesDefault, images)
+}
+
+func TestIsoBootParameters(t *testing.T) {
+	root, err := ioutil.TempDir("", "lxd_iso_")
+	require.NoError(t, err)
+	defer os.RemoveAll(root)
+
+	_, _, err = isoBootParameters(root)
+	assert.Error(t, err)
+
+	require.NoError(t, os.MkdirAll(filepath.Join(root, "boot", "grub"), 0755))
+	require.NoError(t, ioutil.WriteFile(filepath.Join(root, "boot", "grub", "grub.cfg"), []byte("linux /casper/vmlinuz boot=casper\n"), 0644))
+
+	format, params, err := isoBootParameters(root)
+	require.NoError(t, err)
+	assert.Equal(t, "grub2", format)
+	assert.Equal(t, []string{"boot=casper"}, params)
+
+	// syslinux takes precedence
+	require.NoError(t, os.MkdirAll(filepath.Join(root, "isolinux"), 0755))
+	require.NoError(t, ioutil.WriteFile(filepath.Join(root, "isolinux", "txt.cfg"), []byte("append boot=live\n"), 0644))
+
+	format, params, err = isoBootParameters(root)
+	require.NoError(t, err)
+	assert.Equal(t, "syslinux", format)
+	assert.Equal(t, []string{"boot=live"}, params)
+}
+
+func TestIsoInitCommand(t *testing.T) {
+	root, err := ioutil.TempDir("", "lxd_iso_")
+	require.NoError(t, err)
+	defer os.RemoveAll(root)
+
+	_, err = isoInitCommand(root)
+	assert.Error(t, err)
+
+	require.NoError(t, os.MkdirAll(filepath.Join(root, "sbin"), 0755))
+	require.NoError(t, os.Symlink("/lib/systemd/systemd", filepath.Join(root, "sbin", "init")))
+
+	init, err := isoInitCommand(root)
+	require.NoError(t, err)
+	assert.Equal(t, "/sbin/init", init)
+}
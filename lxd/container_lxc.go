@@ -3,6 +3,8 @@ package main
 import (
 	"archive/tar"
 	"bufio"
+	"context"
+	"encoding/hex"
 	"encoding/json"
 	"fmt"
 	"io"
@@ -694,29 +696,57 @@ func parseRawIdmap(value string) ([]idmap.IdmapEntry, error) {
 			continue
 		}
 
-		entries := strings.Split(line, " ")
-		if len(entries) != 3 {
-			return nil, fmt.Errorf("invalid raw.idmap line %s", line)
-		}
+		entries := strings.Fields(line)
 
-		outsideBase, outsideSize, err := getRange(entries[1])
-		if err != nil {
-			return nil, err
+		// Lines in the /proc/self/uid_map format ("<nsid> <hostid> <range>")
+		// apply to both uids and gids unless prefixed with a type.
+		_, err := strconv.ParseInt(entries[0], 10, 64)
+		if err == nil && len(entries) == 3 {
+			entries = append([]string{"both"}, entries...)
 		}
 
-		insideBase, insideSize, err := getRange(entries[2])
-		if err != nil {
-			return nil, err
-		}
+		var entry idmap.IdmapEntry
+		switch len(entries) {
+		case 3:
+			outsideBase, outsideSize, err := getRange(entries[1])
+			if err != nil {
+				return nil, err
+			}
 
-		if insideSize != outsideSize {
-			return nil, fmt.Errorf("idmap ranges of different sizes %s", line)
-		}
+			insideBase, insideSize, err := getRange(entries[2])
+			if err != nil {
+				return nil, err
+			}
+
+			if insideSize != outsideSize {
+				return nil, fmt.Errorf("idmap ranges of different sizes %s", line)
+			}
+
+			entry = idmap.IdmapEntry{
+				Hostid:   outsideBase,
+				Nsid:     insideBase,
+				Maprange: insideSize,
+			}
+		case 4:
+			var fields [3]int64
+			for i, field := range entries[1:] {
+				fields[i], err = strconv.ParseInt(field, 10, 64)
+				if err != nil {
+					return nil, fmt.Errorf("invalid raw.idmap line %s", line)
+				}
+			}
+
+			if fields[2] < 1 {
+				return nil, fmt.Errorf("invalid raw.idmap range size %s", line)
+			}
 
-		entry := idmap.IdmapEntry{
-			Hostid:   outsideBase,
-			Nsid:     insideBase,
-			Maprange: insideSize,
+			entry = idmap.IdmapEntry{
+				Nsid:     fields[0],
+				Hostid:   fields[1],
+				Maprange: fields[2],
+			}
+		default:
+			return nil, fmt.Errorf("invalid raw.idmap line %s", line)
 		}
 
 		switch entries[0] {
@@ -896,6 +926,105 @@ func findIdmap(state *state.State, cName string, isolatedStr string, configBase
 	return nil, 0, fmt.Errorf("Not enough uid/gid available for the container")
 }
 
+// rawIdmapValidate checks that the host ranges requested through raw.idmap
+// were delegated to LXD and aren't already in use by another container.
+func rawIdmapValidate(state *state.State, project string, cName string, rawIdmap string) error {
+	rawMaps, err := parseRawIdmap(rawIdmap)
+	if err != nil {
+		return err
+	}
+
+	if len(rawMaps) == 0 {
+		return nil
+	}
+
+	// Check that we have enough subordinate ids to satisfy the map
+	shadowSet, err := idmap.ShadowIdmapSet("/", "root")
+	if err != nil {
+		return err
+	}
+
+	for _, ent := range rawMaps {
+		if shadowSet == nil {
+			err := ent.Usable()
+			if err != nil {
+				return err
+			}
+
+			continue
+		}
+
+		if !shadowSet.Contains(ent) {
+			return fmt.Errorf("The raw.idmap entry '%s' isn't covered by the ranges in /etc/subuid and /etc/subgid", strings.Join(ent.ToLxcString(), ", "))
+		}
+	}
+
+	// Check for conflicts with the other containers of this node, only
+	// their expanded config being needed
+	var cts []db.Container
+	err = state.Cluster.Transaction(func(tx *db.ClusterTx) error {
+		var err error
+		cts, err = tx.ContainerNodeListExpanded()
+		return err
+	})
+	if err != nil {
+		return err
+	}
+
+	for _, container := range cts {
+		if container.Project == project && container.Name == cName {
+			continue
+		}
+
+		otherMaps, err := parseRawIdmap(container.Config["raw.idmap"])
+		if err != nil {
+			continue
+		}
+
+		// The same host ids may deliberately be mapped into several
+		// containers, e.g. the uid of a host user, so only the raw.idmap
+		// entries partially overlapping with those are conflicts
+		ranges := []idmap.IdmapEntry{}
+		for _, other := range otherMaps {
+			same := false
+			for _, ent := range rawMaps {
+				if ent.Isuid == other.Isuid && ent.Isgid == other.Isgid && ent.Hostid == other.Hostid && ent.Maprange == other.Maprange {
+					same = true
+					break
+				}
+			}
+
+			if !same {
+				ranges = append(ranges, other)
+			}
+		}
+
+		if shared.IsTrue(container.Config["security.idmap.isolated"]) && container.Config["volatile.idmap.base"] != "" {
+			cBase, err := strconv.ParseInt(container.Config["volatile.idmap.base"], 10, 64)
+			if err != nil {
+				return err
+			}
+
+			cSize, err := idmapSize(state, container.Config["security.idmap.isolated"], container.Config["security.idmap.size"])
+			if err != nil {
+				return err
+			}
+
+			ranges = append(ranges, idmap.IdmapEntry{Isuid: true, Isgid: true, Hostid: cBase, Maprange: cSize})
+		}
+
+		for _, ent := range rawMaps {
+			for _, other := range ranges {
+				if ent.HostidsIntersect(other) {
+					return fmt.Errorf("The raw.idmap entry '%s' conflicts with the id range used by container '%s'", strings.Join(ent.ToLxcString(), ", "), container.Name)
+				}
+			}
+		}
+	}
+
+	return nil
+}
+
 func (c *containerLXC) init() error {
 	// Compute the expanded config and device list
 	err := c.expandConfig(nil)
@@ -995,14 +1124,14 @@ func (c *containerLXC) initLXC(config bool) error {
 		return nil
 	}
 
-	if c.IsPrivileged() {
-		// Base config
-		toDrop := "sys_time sys_module sys_rawio"
-		if !c.state.OS.AppArmorStacking || c.state.OS.AppArmorStacked {
-			toDrop = toDrop + " mac_admin mac_override"
-		}
+	// Setup the capability bounding set
+	caps, err := containerCapabilitiesDropped(c.state.OS, c.expandedConfig, c.IsPrivileged())
+	if err != nil {
+		return err
+	}
 
-		err = lxcSetConfigItem(cc, "lxc.cap.drop", toDrop)
+	if len(caps) > 0 {
+		err = lxcSetConfigItem(cc, "lxc.cap.drop", strings.Join(caps, " "))
 		if err != nil {
 			return err
 		}
@@ -1056,8 +1185,14 @@ func (c *containerLXC) initLXC(config bool) error {
 		bindMounts = append(bindMounts, "/dev/mqueue")
 	}
 
+	// Paths of /sys hidden from the container
+	hiddenPaths, err := sysHiddenPaths(c.expandedConfig, "/sys")
+	if err != nil {
+		return err
+	}
+
 	for _, mnt := range bindMounts {
-		if !shared.PathExists(mnt) {
+		if !shared.PathExists(mnt) || sysHidden(hiddenPaths, mnt) {
 			continue
 		}
 
@@ -1201,6 +1336,14 @@ func (c *containerLXC) initLXC(config bool) error {
 		return err
 	}
 
+	// Hide the firmware and module parameters
+	for _, entry := range sysHideMountEntries(hiddenPaths) {
+		err = lxcSetConfigItem(cc, "lxc.mount.entry", entry)
+		if err != nil {
+			return err
+		}
+	}
+
 	// Setup devlxd
 	if c.expandedConfig["security.devlxd"] == "" || shared.IsTrue(c.expandedConfig["security.devlxd"]) {
 		err = lxcSetConfigItem(cc, "lxc.mount.entry", fmt.Sprintf("%s dev/lxd none bind,create=dir 0 0", shared.VarPath("devlxd")))
@@ -1241,12 +1384,39 @@ func (c *containerLXC) initLXC(config bool) error {
 		}
 	}
 
+	// Setup the time namespace, liblxc takes care of writing the offsets
+	// to /proc/self/timens_offsets before spawning init.
+	if shared.IsTrue(c.expandedConfig["security.time_namespace"]) && c.state.OS.TimeNamespace {
+		offset := c.expandedConfig["security.time_namespace.offset_seconds"]
+		if offset == "" {
+			offset = "0"
+		}
+
+		err = lxcSetConfigItem(cc, "lxc.time.offset.monotonic", fmt.Sprintf("%ss", offset))
+		if err != nil {
+			return err
+		}
+
+		err = lxcSetConfigItem(cc, "lxc.time.offset.boot", fmt.Sprintf("%ss", offset))
+		if err != nil {
+			return err
+		}
+	}
+
 	// Setup Seccomp if necessary
 	if ContainerNeedsSeccomp(c) {
 		err = lxcSetConfigItem(cc, "lxc.seccomp.profile", SeccompProfilePath(c))
 		if err != nil {
 			return err
 		}
+
+		// Forward the intercepted syscalls to LXD
+		if seccompNotifyNeeded(c.expandedConfig) && c.state.OS.SeccompListener {
+			err = lxcSetConfigItem(cc, "lxc.seccomp.notify.proxy", fmt.Sprintf("unix:%s", seccompNotifySocketPath(c)))
+			if err != nil {
+				return err
+			}
+		}
 	}
 
 	// Setup idmap
@@ -1275,6 +1445,28 @@ func (c *containerLXC) initLXC(config bool) error {
 		}
 	}
 
+	// Setup NVIDIA MPS
+	for _, k := range c.expandedDevices.DeviceNames() {
+		m := c.expandedDevices[k]
+		if !deviceWantsMPS(m) {
+			continue
+		}
+
+		// The pipe directory isn't put under /tmp as it'd be hidden by a
+		// tmpfs mounted there by the container
+		err = lxcSetConfigItem(cc, "lxc.mount.entry", fmt.Sprintf("%s dev/nvidia-mps none bind,create=dir,optional 0 0", deviceMPSPipePath()))
+		if err != nil {
+			return err
+		}
+
+		err = lxcSetConfigItem(cc, "lxc.environment", "CUDA_MPS_PIPE_DIRECTORY=/dev/nvidia-mps")
+		if err != nil {
+			return err
+		}
+
+		break
+	}
+
 	// Setup NVIDIA runtime
 	if shared.IsTrue(c.expandedConfig["nvidia.runtime"]) {
 		hookDir := os.Getenv("LXD_LXC_HOOK")
@@ -1339,6 +1531,11 @@ func (c *containerLXC) initLXC(config bool) error {
 		memorySwap := c.expandedConfig["limits.memory.swap"]
 		memorySwapPriority := c.expandedConfig["limits.memory.swap.priority"]
 
+		// Memory balloons start inflated to their maximum
+		if memory == "" {
+			memory = c.expandedConfig["limits.memory.max"]
+		}
+
 		// Configure the memory limits
 		if memory != "" {
 			var valueInt int64
@@ -1409,42 +1606,92 @@ func (c *containerLXC) initLXC(config bool) error {
 		}
 	}
 
+	// Memory protection, only available with cgroup2. The cgroup of the
+	// container doesn't exist yet, the guarantee is checked against the
+	// parent cgroup once started.
+	if c.expandedConfig["limits.memory.low"] != "" || c.expandedConfig["limits.memory.guarantee"] != "" {
+		if c.state.OS.CGroupMemoryUnified {
+			values, err := containerMemoryProtection(c.expandedConfig, -1)
+			if err != nil {
+				return err
+			}
+
+			for file, value := range values {
+				err = lxcSetConfigItem(cc, fmt.Sprintf("lxc.cgroup2.%s", file), value)
+				if err != nil {
+					return err
+				}
+			}
+		} else {
+			logger.Warn("Ignoring the memory protection of the container, the memory controller isn't on the unified cgroup hierarchy", log.Ctx{"container": c.Name()})
+		}
+	}
+
 	// CPU limits
 	cpuPriority := c.expandedConfig["limits.cpu.priority"]
 	cpuAllowance := c.expandedConfig["limits.cpu.allowance"]
+	cpuWeight := c.expandedConfig["priority.cpu"]
 
-	if (cpuPriority != "" || cpuAllowance != "") && c.state.OS.CGroupCPUController {
-		cpuShares, cpuCfsQuota, cpuCfsPeriod, err := deviceParseCPU(cpuAllowance, cpuPriority)
+	if (cpuPriority != "" || cpuAllowance != "" || cpuWeight != "") && c.state.OS.CGroupCPUController {
+		cpuShares, _, _, err := deviceParseCPU(cpuAllowance, cpuPriority)
 		if err != nil {
 			return err
 		}
 
-		if cpuShares != "1024" {
-			err = lxcSetConfigItem(cc, "lxc.cgroup.cpu.shares", cpuShares)
+		// The priority class takes precedence over the computed shares
+		if cpuWeight != "" {
+			cpuShares, err = deviceParseCPUWeight(cpuWeight)
 			if err != nil {
 				return err
 			}
 		}
 
-		if cpuCfsPeriod != "-1" {
-			err = lxcSetConfigItem(cc, "lxc.cgroup.cpu.cfs_period_us", cpuCfsPeriod)
+		if cpuShares != "1024" {
+			err = lxcSetConfigItem(cc, "lxc.cgroup.cpu.shares", cpuShares)
 			if err != nil {
 				return err
 			}
 		}
+	}
 
-		if cpuCfsQuota != "-1" {
-			err = lxcSetConfigItem(cc, "lxc.cgroup.cpu.cfs_quota_us", cpuCfsQuota)
-			if err != nil {
-				return err
-			}
+	// CPU bandwidth, through cpu.max on the unified hierarchy
+	cpuQuota, cpuPeriod, err := containerCPUBandwidth(c.expandedConfig)
+	if err != nil {
+		return err
+	}
+
+	if cpuQuota != -1 && c.state.OS.CGroupCPUUnified {
+		err = lxcSetConfigItem(cc, "lxc.cgroup2.cpu.max", containerCPUMax(cpuQuota, cpuPeriod))
+		if err != nil {
+			return err
+		}
+	} else if cpuQuota != -1 && c.state.OS.CGroupCPUController {
+		err = lxcSetConfigItem(cc, "lxc.cgroup.cpu.cfs_period_us", fmt.Sprintf("%d", cpuPeriod))
+		if err != nil {
+			return err
+		}
+
+		err = lxcSetConfigItem(cc, "lxc.cgroup.cpu.cfs_quota_us", fmt.Sprintf("%d", cpuQuota))
+		if err != nil {
+			return err
 		}
 	}
 
 	// Disk limits
 	if c.state.OS.CGroupBlkioController {
 		diskPriority := c.expandedConfig["limits.disk.priority"]
-		if diskPriority != "" {
+		ioWeight := c.expandedConfig["priority.io"]
+		if ioWeight != "" {
+			weight, err := deviceParseIOWeight(ioWeight)
+			if err != nil {
+				return err
+			}
+
+			err = lxcSetConfigItem(cc, "lxc.cgroup.blkio.weight", weight)
+			if err != nil {
+				return err
+			}
+		} else if diskPriority != "" {
 			priorityInt, err := strconv.Atoi(diskPriority)
 			if err != nil {
 				return err
@@ -1555,6 +1802,18 @@ func (c *containerLXC) initLXC(config bool) error {
 	}
 
 	// Setup process limits
+	ulimits, err := ulimitsLXCConfig(c.expandedConfig["limits.ulimits"])
+	if err != nil {
+		return err
+	}
+
+	for k, v := range ulimits {
+		err = lxcSetConfigItem(cc, k, v)
+		if err != nil {
+			return err
+		}
+	}
+
 	for k, v := range c.expandedConfig {
 		if strings.HasPrefix(k, "limits.kernel.") {
 			prlimitSuffix := strings.TrimPrefix(k, "limits.kernel.")
@@ -1965,6 +2224,54 @@ func (c *containerLXC) startCommon() (string, error) {
 		}
 	}
 
+	// Check that the intercepted syscalls can be forwarded to LXD
+	if c.expandedConfig["security.seccomp.path_rules"] != "" && !c.state.OS.SeccompListener {
+		return "", fmt.Errorf("Seccomp path rules aren't supported on this system (requires Linux 5.5 or higher and a recent liblxc)")
+	}
+
+	if shared.IsTrue(c.expandedConfig["security.proc_filter"]) {
+		if !c.state.OS.SeccompListener {
+			return "", fmt.Errorf("The /proc filter isn't supported on this system (requires Linux 5.5 or higher and a recent liblxc)")
+		}
+
+		if !seccompProcFilterSupported() {
+			return "", fmt.Errorf("The /proc filter requires the cgroup2 hierarchy to be mounted")
+		}
+	}
+
+	// Check that a time namespace can be setup
+	if shared.IsTrue(c.expandedConfig["security.time_namespace"]) && !c.state.OS.TimeNamespace {
+		return "", fmt.Errorf("Time namespaces aren't supported on this system (requires Linux 5.6 or higher and a recent liblxc)")
+	}
+
+	// Join the IPC namespace of the host or of another container
+	switch c.expandedConfig["security.ipc"] {
+	case "host":
+		err = lxcSetConfigItem(c.c, "lxc.namespace.share.ipc", "1")
+		if err != nil {
+			return "", err
+		}
+	case "shared":
+		sourceName := c.expandedConfig["security.ipc.shared_with"]
+		if sourceName == c.name {
+			return "", fmt.Errorf("A container can't share the IPC namespace of itself")
+		}
+
+		source, err := containerLoadByProjectAndName(c.state, c.project, sourceName)
+		if err != nil {
+			return "", fmt.Errorf("Failed to load container '%s' to share the IPC namespace of: %v", sourceName, err)
+		}
+
+		if !source.IsRunning() {
+			return "", fmt.Errorf("Container '%s' must be running to share its IPC namespace", sourceName)
+		}
+
+		err = lxcSetConfigItem(c.c, "lxc.namespace.share.ipc", fmt.Sprintf("%d", source.InitPID()))
+		if err != nil {
+			return "", err
+		}
+	}
+
 	// Load any required kernel modules
 	kernelModules := c.expandedConfig["linux.kernel_modules"]
 	if kernelModules != "" {
@@ -2005,6 +2312,14 @@ func (c *containerLXC) startCommon() (string, error) {
 		delete(c.expandedConfig, "volatile.apply_quota")
 	}
 
+	// Validate any custom id mapping
+	if c.expandedConfig["raw.idmap"] != "" && !c.IsPrivileged() {
+		err = rawIdmapValidate(c.state, c.Project(), c.Name(), c.expandedConfig["raw.idmap"])
+		if err != nil {
+			return "", err
+		}
+	}
+
 	/* Deal with idmap changes */
 	nextIdmap, err := c.NextIdmap()
 	if err != nil {
@@ -2029,32 +2344,17 @@ func (c *containerLXC) startCommon() (string, error) {
 			return "", errors.Wrap(err, "Storage start")
 		}
 
-		if diskIdmap != nil {
-			if c.Storage().GetStorageType() == storageTypeZfs {
-				err = diskIdmap.UnshiftRootfs(c.RootfsPath(), zfsIdmapSetSkipper)
-			} else {
-				err = diskIdmap.UnshiftRootfs(c.RootfsPath(), nil)
-			}
-			if err != nil {
-				if ourStart {
-					c.StorageStop()
-				}
-				return "", err
-			}
+		targetIdmap := nextIdmap
+		if c.state.OS.Shiftfs {
+			targetIdmap = nil
 		}
 
-		if nextIdmap != nil && !c.state.OS.Shiftfs {
-			if c.Storage().GetStorageType() == storageTypeZfs {
-				err = nextIdmap.ShiftRootfs(c.RootfsPath(), zfsIdmapSetSkipper)
-			} else {
-				err = nextIdmap.ShiftRootfs(c.RootfsPath(), nil)
-			}
-			if err != nil {
-				if ourStart {
-					c.StorageStop()
-				}
-				return "", err
+		err = c.RemapFilesystem(diskIdmap, targetIdmap)
+		if err != nil {
+			if ourStart {
+				c.StorageStop()
 			}
+			return "", err
 		}
 
 		jsonDiskIdmap := "[]"
@@ -2096,11 +2396,26 @@ func (c *containerLXC) startCommon() (string, error) {
 		return "", err
 	}
 
+	// Record the syscalls of containers in seccomp learning mode
+	if shared.IsTrue(c.expandedConfig["security.seccomp.log_only"]) {
+		seccompLog.register(c)
+	}
+
+	// Handle the syscalls intercepted to remap their paths or filter /proc
+	if seccompNotifyNeeded(c.expandedConfig) {
+		err = seccompNotify.register(c, c.state.OS.ExecPath)
+		if err != nil {
+			return "", err
+		}
+	}
+
 	// Cleanup any existing leftover devices
 	c.removeUnixDevices()
 	c.removeDiskDevices()
+	c.removeIsoDevices()
 	c.removeNetworkFilters()
 	c.removeProxyDevices()
+	c.stopCloudInitServer()
 
 	var usbs []usbDevice
 	var sriov []string
@@ -2109,6 +2424,15 @@ func (c *containerLXC) startCommon() (string, error) {
 	// Create the devices
 	for _, k := range c.expandedDevices.DeviceNames() {
 		m := c.expandedDevices[k]
+
+		// Load any kernel modules required by the device
+		if shared.StringInSlice(m["type"], []string{"nic", "usb", "gpu"}) {
+			err = deviceLoadKernelModules(k, m)
+			if err != nil {
+				return "", err
+			}
+		}
+
 		if shared.StringInSlice(m["type"], []string{"unix-char", "unix-block"}) {
 			// Unix device
 			paths, err := c.createUnixDevice(fmt.Sprintf("unix.%s", k), m, true)
@@ -2225,6 +2549,36 @@ func (c *containerLXC) startCommon() (string, error) {
 				logger.Error(msg)
 				return "", fmt.Errorf(msg)
 			}
+
+			if deviceWantsMPS(m) {
+				if !sawNvidia {
+					return "", fmt.Errorf("gpu.mps.enabled requires an NVIDIA GPU")
+				}
+
+				err := deviceMPSStart()
+				if err != nil {
+					return "", err
+				}
+
+				if m["gpu.mps.limit_active_threads"] != "" {
+					// Privileged containers would share the MPS
+					// server of the root user of the host
+					if nextIdmap == nil {
+						return "", fmt.Errorf("gpu.mps.limit_active_threads isn't supported in privileged containers")
+					}
+
+					uid, _ := nextIdmap.ShiftFromNs(0, 0)
+					err := deviceMPSSetLimit(uid, m["gpu.mps.limit_active_threads"])
+					if err != nil {
+						return "", err
+					}
+				}
+			}
+		} else if m["type"] == "pci" {
+			err := c.setupPCIDevice(k, m)
+			if err != nil {
+				return "", err
+			}
 		} else if m["type"] == "disk" {
 			if m["path"] != "/" {
 				diskDevices[k] = m
@@ -2386,6 +2740,26 @@ func (c *containerLXC) startCommon() (string, error) {
 		return "", err
 	}
 
+	// Set the OOM killer score adjustment, inherited by all the processes
+	oomDefault, err := containerOOMScoreAdjDefault(c.state)
+	if err != nil {
+		return "", err
+	}
+
+	oomScoreAdj, err := containerOOMScoreAdj(c.expandedConfig, oomDefault)
+	if err != nil {
+		return "", err
+	}
+
+	if util.RuntimeLiblxcVersionAtLeast(2, 1, 0) {
+		err = lxcSetConfigItem(c.c, "lxc.proc.oom_score_adj", fmt.Sprintf("%d", oomScoreAdj))
+		if err != nil {
+			return "", err
+		}
+	} else if oomScoreAdj != 0 {
+		return "", fmt.Errorf("Setting the OOM score adjustment requires liblxc 2.1 or higher")
+	}
+
 	// Create any missing directory
 	err = os.MkdirAll(c.LogPath(), 0700)
 	if err != nil {
@@ -2418,6 +2792,15 @@ func (c *containerLXC) startCommon() (string, error) {
 		return "", err
 	}
 
+	// Boot from the ISO image if any
+	err = c.startIsoDevice()
+	if err != nil {
+		if ourStart {
+			c.StorageStop()
+		}
+		return "", err
+	}
+
 	// Generate the LXC config
 	configPath := filepath.Join(c.LogPath(), "lxc.conf")
 	err = c.c.SaveConfigFile(configPath)
@@ -2508,6 +2891,13 @@ func (c *containerLXC) Start(stateful bool) error {
 		return errors.Wrap(err, "Storage start")
 	}
 
+	// Run the host pre-start hook
+	err = c.runHostHook("pre-start")
+	if err != nil {
+		c.startCleanup()
+		return err
+	}
+
 	ctxMap = log.Ctx{
 		"project":   c.project,
 		"name":      c.name,
@@ -2540,23 +2930,85 @@ func (c *containerLXC) Start(stateful bool) error {
 			return errors.Wrap(err, "Migrate")
 		}
 
-		os.RemoveAll(c.StatePath())
-		c.stateful = false
+		os.RemoveAll(c.StatePath())
+		c.stateful = false
+
+		err = c.state.Cluster.ContainerSetStateful(c.id, false)
+		if err != nil {
+			logger.Error("Failed starting container", ctxMap)
+			return errors.Wrap(err, "Start container")
+		}
+
+		// Start proxy devices
+		err = c.restartProxyDevices()
+		if err != nil {
+			// Attempt to stop the container
+			c.Stop(false)
+			return err
+		}
+
+		// Create the TUN devices
+		err = c.startTunDevices()
+		if err != nil {
+			// Attempt to stop the container
+			c.Stop(false)
+			return err
+		}
+
+		// Create the VLAN devices
+		err = c.startVlanDevices()
+		if err != nil {
+			// Attempt to stop the container
+			c.Stop(false)
+			return err
+		}
+
+		// Configure the nic queues
+		err = c.startNetworkQueues()
+		if err != nil {
+			// Attempt to stop the container
+			c.Stop(false)
+			return err
+		}
 
-		err = c.state.Cluster.ContainerSetStateful(c.id, false)
+		// Check the memory guarantee against the parent cgroup
+		err = c.startMemoryProtectionCheck()
 		if err != nil {
-			logger.Error("Failed starting container", ctxMap)
-			return errors.Wrap(err, "Start container")
+			// Attempt to stop the container
+			c.Stop(false)
+			return err
 		}
 
-		// Start proxy devices
-		err = c.restartProxyDevices()
+		// Start plugin provided devices
+		err = devicePluginsStart(c, c.expandedDevices)
 		if err != nil {
 			// Attempt to stop the container
 			c.Stop(false)
 			return err
 		}
 
+		// Announce the container over mDNS
+		mdnsAnnounce(c)
+
+		// Advertise the container on the BGP EVPN networks
+		evpnNotify(c)
+
+		// Start the cloud-init metadata server
+		err = c.startCloudInitServer()
+		if err != nil {
+			// Attempt to stop the container
+			c.Stop(false)
+			return errors.Wrap(err, "Start cloud-init metadata server")
+		}
+
+		// Run the host post-start hook
+		err = c.runHostHook("post-start")
+		if err != nil {
+			logger.Warn("Host hook failed", log.Ctx{"container": c.Name(), "hook": "post-start", "err": err})
+		}
+
+		containerExitWatch(c)
+
 		logger.Info("Started container", ctxMap)
 		return nil
 	} else if c.stateful {
@@ -2575,8 +3027,11 @@ func (c *containerLXC) Start(stateful bool) error {
 
 	name := projectPrefix(c.Project(), c.name)
 
+	c.thpCheck()
+
 	// Start the LXC container
-	out, err := shared.RunCommand(
+	out, err := shared.RunCommandWithEnv(
+		c.thpEnv(),
 		c.state.OS.ExecPath,
 		"forkstart",
 		name,
@@ -2632,9 +3087,72 @@ func (c *containerLXC) Start(stateful bool) error {
 		return err
 	}
 
+	// Create the TUN devices
+	err = c.startTunDevices()
+	if err != nil {
+		// Attempt to stop the container
+		c.Stop(false)
+		return err
+	}
+
+	// Create the VLAN devices
+	err = c.startVlanDevices()
+	if err != nil {
+		// Attempt to stop the container
+		c.Stop(false)
+		return err
+	}
+
+	// Configure the nic queues
+	err = c.startNetworkQueues()
+	if err != nil {
+		// Attempt to stop the container
+		c.Stop(false)
+		return err
+	}
+
+	// Check the memory guarantee against the parent cgroup
+	err = c.startMemoryProtectionCheck()
+	if err != nil {
+		// Attempt to stop the container
+		c.Stop(false)
+		return err
+	}
+
+	// Start plugin provided devices
+	err = devicePluginsStart(c, c.expandedDevices)
+	if err != nil {
+		// Attempt to stop the container
+		c.Stop(false)
+		return err
+	}
+
+	// Announce the container over mDNS
+	mdnsAnnounce(c)
+
+	// Advertise the container on the BGP EVPN networks
+	evpnNotify(c)
+
+	// Start the cloud-init metadata server
+	err = c.startCloudInitServer()
+	if err != nil {
+		// Attempt to stop the container
+		c.Stop(false)
+		return errors.Wrap(err, "Start cloud-init metadata server")
+	}
+
+	// Run the host post-start hook
+	err = c.runHostHook("post-start")
+	if err != nil {
+		logger.Warn("Host hook failed", log.Ctx{"container": c.Name(), "hook": "post-start", "err": err})
+	}
+
+	containerExitWatch(c)
+
 	logger.Info("Started container", ctxMap)
 	eventSendLifecycle(c.project, "container-started",
 		fmt.Sprintf("/1.0/containers/%s", c.name), nil)
+	containerHookSend(c, "start", nil)
 
 	return nil
 }
@@ -2735,6 +3253,15 @@ func (c *containerLXC) Stop(stateful bool) error {
 		return fmt.Errorf("The container is already stopped")
 	}
 
+	// Run the host pre-stop hook
+	err := c.runHostHook("pre-stop")
+	if err != nil {
+		return err
+	}
+
+	// Withdraw the mDNS records while the container can still send them
+	mdnsGoodbye(c)
+
 	// Setup a new operation
 	op, err := c.createOperation("stop", false, true)
 	if err != nil {
@@ -2860,6 +3387,15 @@ func (c *containerLXC) Shutdown(timeout time.Duration) error {
 		return fmt.Errorf("The container is already stopped")
 	}
 
+	// Run the host pre-stop hook
+	err := c.runHostHook("pre-stop")
+	if err != nil {
+		return err
+	}
+
+	// Withdraw the mDNS records while the container can still send them
+	mdnsGoodbye(c)
+
 	// Setup a new operation
 	op, err := c.createOperation("stop", true, true)
 	if err != nil {
@@ -2926,6 +3462,38 @@ func (c *containerLXC) OnStop(target string) error {
 		return fmt.Errorf("Unable to remove proxy devices: %v", err)
 	}
 
+	// Stop plugin provided devices
+	devicePluginsStop(c, c.expandedDevices)
+
+	// Withdraw any mDNS records left behind by an unexpected stop
+	mdnsGoodbye(c)
+
+	// Stop the cloud-init metadata server
+	err = c.stopCloudInitServer()
+	if err != nil {
+		logger.Error("Failed to stop cloud-init metadata server", log.Ctx{"container": c.Name(), "err": err})
+	}
+
+	// Run the host post-stop hook
+	err = c.runHostHook("post-stop")
+	if err != nil {
+		logger.Warn("Host hook failed", log.Ctx{"container": c.Name(), "hook": "post-stop", "err": err})
+	}
+
+	// Stop the NVIDIA MPS control daemon if this was its last user
+	for _, m := range c.expandedDevices {
+		if !deviceWantsMPS(m) {
+			continue
+		}
+
+		err = deviceMPSStop(c.state, c.Project(), c.Name())
+		if err != nil {
+			logger.Error("Failed to stop the NVIDIA MPS control daemon", log.Ctx{"container": c.Name(), "err": err})
+		}
+
+		break
+	}
+
 	// Stop the storage for this container
 	_, err = c.StorageStop()
 	if err != nil {
@@ -2986,14 +3554,48 @@ func (c *containerLXC) OnStop(target string) error {
 			logger.Error("Unable to remove disk devices", log.Ctx{"container": c.Name(), "err": err})
 		}
 
+		// Unmount the ISO image
+		err = c.removeIsoDevices()
+		if err != nil {
+			logger.Error("Unable to remove ISO devices", log.Ctx{"container": c.Name(), "err": err})
+		}
+
 		// Clean all network filters
 		err = c.removeNetworkFilters()
 		if err != nil {
 			logger.Error("Unable to remove network filters", log.Ctx{"container": c.Name(), "err": err})
 		}
 
+		// Return the infiniband virtual functions to the pool
+		for _, name := range c.expandedDevices.DeviceNames() {
+			c.releaseInfinibandVF(name, c.expandedDevices[name])
+		}
+
+		// Give the PCI devices back to their original drivers
+		for _, name := range c.expandedDevices.DeviceNames() {
+			c.releasePCIDevice(name, c.expandedDevices[name])
+		}
+
+		// Stop recording the syscalls of the container
+		seccompLog.unregister(c)
+
+		// Stop handling the syscalls of the container
+		seccompNotify.unregister(c)
+
+		// Detach the eBPF programs loaded into the container
+		ebpfPrograms.removeAll(c)
+
+		// Withdraw the container from the BGP EVPN networks
+		evpnNotify(c)
+
+		// Whether the init process failed, rather than the container
+		// being stopped, shut down or rebooted
+		failed := containerExitFailed(c) && op == nil
+
 		// Reboot the container
 		if target == "reboot" {
+			containerHookSend(c, "stop", map[string]interface{}{"action": target})
+
 			// Start the container again
 			err = c.Start(false)
 			return
@@ -3002,10 +3604,30 @@ func (c *containerLXC) OnStop(target string) error {
 		// Trigger a rebalance
 		deviceTaskSchedulerTrigger("container", c.name, "stopped")
 
-		// Destroy ephemeral containers
+		// Notify the hook
+		if failed {
+			containerHookSend(c, "crash", map[string]interface{}{"action": target})
+		} else {
+			containerHookSend(c, "stop", map[string]interface{}{"action": target})
+		}
+
+		// Destroy ephemeral containers, failures are retried on daemon startup
 		if c.ephemeral {
 			err = c.Delete()
+			if err != nil {
+				logger.Error("Failed to delete ephemeral container", log.Ctx{"container": c.Name(), "err": err})
+
+				err = c.VolatileSet(map[string]string{"volatile.ephemeral.delete_failed": "true"})
+				if err != nil {
+					logger.Error("Failed to flag ephemeral container for deletion", log.Ctx{"container": c.Name(), "err": err})
+				}
+			}
+
+			return
 		}
+
+		// Schedule a restart if the container stopped on its own
+		containerWatchdogStopped(c, op != nil, failed)
 	}(c, target, op)
 
 	return nil
@@ -3029,7 +3651,7 @@ func (c *containerLXC) Freeze() error {
 
 	// Check that we're running
 	if !c.IsRunning() {
-		return fmt.Errorf("The container isn't running")
+		return withErrorCode(api.ErrContainerNotRunning, fmt.Errorf("The container isn't running"))
 	}
 
 	// Check if the CGroup is available
@@ -3077,7 +3699,7 @@ func (c *containerLXC) Unfreeze() error {
 
 	// Check that we're running
 	if !c.IsRunning() {
-		return fmt.Errorf("The container isn't running")
+		return withErrorCode(api.ErrContainerNotRunning, fmt.Errorf("The container isn't running"))
 	}
 
 	// Check if the CGroup is available
@@ -3163,6 +3785,7 @@ func (c *containerLXC) Render() (interface{}, interface{}, error) {
 		ct.Ephemeral = c.ephemeral
 		ct.Profiles = c.profiles
 		ct.ExpiresAt = c.expiryDate
+		ct.Keep = shared.IsTrue(c.localConfig[snapshotKeepConfigKey])
 
 		return &ct, etag, nil
 	}
@@ -3195,6 +3818,10 @@ func (c *containerLXC) Render() (interface{}, interface{}, error) {
 	ct.LastUsedAt = c.lastUsedDate
 	ct.Profiles = c.profiles
 	ct.Stateful = c.stateful
+	ct.Security = api.ContainerSecurity{
+		HideFirmware:     shared.IsTrue(c.expandedConfig["security.hide_firmware"]),
+		HideModuleParams: shared.IsTrue(c.expandedConfig["security.hide_module_params"]),
+	}
 
 	return &ct, etag, nil
 }
@@ -3266,6 +3893,7 @@ func (c *containerLXC) RenderState() (*api.ContainerState, error) {
 	status := api.ContainerState{
 		Status:     statusCode.String(),
 		StatusCode: statusCode,
+		Ephemeral:  c.ephemeral,
 	}
 
 	if c.IsRunning() {
@@ -3276,6 +3904,9 @@ func (c *containerLXC) RenderState() (*api.ContainerState, error) {
 		status.Network = c.networkState()
 		status.Pid = int64(pid)
 		status.Processes = c.processesState()
+		status.OpenFiles = c.openFilesState()
+		status.Proxy = c.proxyState()
+		status.TimeNamespace = c.timeNamespaceState()
 	}
 
 	return &status, nil
@@ -3397,7 +4028,7 @@ func (c *containerLXC) Restore(sourceContainer container, stateful bool) error {
 	// Restore the configuration
 	args := db.ContainerArgs{
 		Architecture: sourceContainer.Architecture(),
-		Config:       sourceContainer.LocalConfig(),
+		Config:       snapshotConfigWithoutKeep(sourceContainer.LocalConfig()),
 		Description:  sourceContainer.Description(),
 		Devices:      sourceContainer.LocalDevices(),
 		Ephemeral:    sourceContainer.IsEphemeral(),
@@ -3478,12 +4109,46 @@ func (c *containerLXC) Restore(sourceContainer container, stateful bool) error {
 	return nil
 }
 
+// startCleanup undoes the setup of a start aborted before liblxc got to run
+// the container, as its stop hook then never runs.
+func (c *containerLXC) startCleanup() {
+	c.removeUnixDevices()
+	c.removeDiskDevices()
+	c.removeIsoDevices()
+	c.removeNetworkFilters()
+
+	for _, name := range c.expandedDevices.DeviceNames() {
+		m := c.expandedDevices[name]
+		c.releaseInfinibandVF(name, m)
+		c.releasePCIDevice(name, m)
+	}
+
+	for _, m := range c.expandedDevices {
+		if !deviceWantsMPS(m) {
+			continue
+		}
+
+		err := deviceMPSStop(c.state, c.Project(), c.Name())
+		if err != nil {
+			logger.Error("Failed to stop the NVIDIA MPS control daemon", log.Ctx{"container": c.Name(), "err": err})
+		}
+
+		break
+	}
+
+	_, err := c.StorageStop()
+	if err != nil {
+		logger.Error("Failed to stop the container storage", log.Ctx{"container": c.Name(), "err": err})
+	}
+}
+
 func (c *containerLXC) cleanup() {
 	// Unmount any leftovers
 	c.removeUnixDevices()
 	c.removeDiskDevices()
 	c.removeNetworkFilters()
 	c.removeProxyDevices()
+	c.stopCloudInitServer()
 
 	// Remove the security profiles
 	AADeleteProfile(c)
@@ -3568,6 +4233,9 @@ func (c *containerLXC) Delete() error {
 		// Clean things up
 		c.cleanup()
 
+		// Remove the host hooks
+		os.RemoveAll(c.hostHooksPath())
+
 		// Delete the container from disk
 		if c.storage != nil && !isImport {
 			_, poolName, _ := c.storage.GetContainerPoolInfo()
@@ -3610,6 +4278,14 @@ func (c *containerLXC) Delete() error {
 		return err
 	}
 
+	// Release the image the container was based on
+	if c.localConfig["volatile.base_image"] != "" {
+		err = c.state.Cluster.ImageRefCountUpdate(c.project, c.localConfig["volatile.base_image"], -1)
+		if err != nil {
+			logger.Warn("Failed to update image reference count", log.Ctx{"image": c.localConfig["volatile.base_image"], "err": err})
+		}
+	}
+
 	// Remove the database entry for the pool device
 	if c.storage != nil {
 		// Get the name of the storage pool the container is attached to. This
@@ -3667,10 +4343,26 @@ func (c *containerLXC) Rename(newName string) error {
 		return fmt.Errorf("Invalid container name")
 	}
 
+	// liblxc identifies a running container (command socket, monitor and
+	// cgroups) by its name, so this can't be changed under its feet.
 	if c.IsRunning() {
 		return fmt.Errorf("Renaming of running container not allowed")
 	}
 
+	// Undo the completed steps should any of the later ones fail, leaving
+	// the container available under its old name.
+	reverts := []func(){}
+	success := false
+	defer func() {
+		if success {
+			return
+		}
+
+		for i := len(reverts) - 1; i >= 0; i-- {
+			reverts[i]()
+		}
+	}()
+
 	// Clean things up
 	c.cleanup()
 
@@ -3680,6 +4372,25 @@ func (c *containerLXC) Rename(newName string) error {
 		if err != nil {
 			return err
 		}
+
+		reverts = append(reverts, func() {
+			c.maasRename(oldName)
+		})
+	}
+
+	// Rename the host hooks
+	if !c.IsSnapshot() && shared.PathExists(c.hostHooksPath()) {
+		oldHooksPath := c.hostHooksPath()
+		newHooksPath := shared.VarPath("hooks", projectPrefix(c.Project(), newName))
+		err := os.Rename(oldHooksPath, newHooksPath)
+		if err != nil {
+			logger.Error("Failed renaming container", ctxMap)
+			return err
+		}
+
+		reverts = append(reverts, func() {
+			os.Rename(newHooksPath, oldHooksPath)
+		})
 	}
 
 	// Rename the logging path
@@ -3690,6 +4401,10 @@ func (c *containerLXC) Rename(newName string) error {
 			logger.Error("Failed renaming container", ctxMap)
 			return err
 		}
+
+		reverts = append(reverts, func() {
+			os.Rename(shared.LogPath(newName), shared.LogPath(oldName))
+		})
 	}
 
 	// Rename the storage entry
@@ -3699,12 +4414,24 @@ func (c *containerLXC) Rename(newName string) error {
 			logger.Error("Failed renaming container", ctxMap)
 			return err
 		}
+
+		reverts = append(reverts, func() {
+			c.name = newName
+			c.storage.ContainerSnapshotRename(c, oldName)
+			c.name = oldName
+		})
 	} else {
 		err := c.storage.ContainerRename(c, newName)
 		if err != nil {
 			logger.Error("Failed renaming container", ctxMap)
 			return err
 		}
+
+		reverts = append(reverts, func() {
+			c.name = newName
+			c.storage.ContainerRename(c, oldName)
+			c.name = oldName
+		})
 	}
 
 	// Rename the backups
@@ -3716,11 +4443,17 @@ func (c *containerLXC) Rename(newName string) error {
 	for _, backup := range backups {
 		backupName := strings.Split(backup.name, "/")[1]
 		newName := fmt.Sprintf("%s/%s", newName, backupName)
+		oldBackupName := backup.name
 
 		err = backup.Rename(newName)
 		if err != nil {
 			return err
 		}
+
+		backup := backup
+		reverts = append(reverts, func() {
+			backup.Rename(oldBackupName)
+		})
 	}
 
 	// Rename the database entry
@@ -3732,6 +4465,12 @@ func (c *containerLXC) Rename(newName string) error {
 		return err
 	}
 
+	reverts = append(reverts, func() {
+		c.state.Cluster.Transaction(func(tx *db.ClusterTx) error {
+			return tx.ContainerRename(c.project, newName, oldName)
+		})
+	})
+
 	// Rename storage volume for the container.
 	poolID, _, _ := c.storage.GetContainerPoolInfo()
 	err = c.state.Cluster.StoragePoolVolumeRename(c.project, oldName, newName, storagePoolVolumeTypeContainer, poolID)
@@ -3740,6 +4479,10 @@ func (c *containerLXC) Rename(newName string) error {
 		return err
 	}
 
+	reverts = append(reverts, func() {
+		c.state.Cluster.StoragePoolVolumeRename(c.project, newName, oldName, storagePoolVolumeTypeContainer, poolID)
+	})
+
 	if !c.IsSnapshot() {
 		// Rename all the snapshots
 		results, err := c.state.Cluster.ContainerGetSnapshots(c.project, oldName)
@@ -3750,6 +4493,7 @@ func (c *containerLXC) Rename(newName string) error {
 
 		for _, sname := range results {
 			// Rename the snapshot
+			sname := sname
 			baseSnapName := filepath.Base(sname)
 			newSnapshotName := newName + shared.SnapshotDelimiter + baseSnapName
 			err := c.state.Cluster.Transaction(func(tx *db.ClusterTx) error {
@@ -3760,15 +4504,27 @@ func (c *containerLXC) Rename(newName string) error {
 				return err
 			}
 
+			reverts = append(reverts, func() {
+				c.state.Cluster.Transaction(func(tx *db.ClusterTx) error {
+					return tx.ContainerRename(c.project, newSnapshotName, sname)
+				})
+			})
+
 			// Rename storage volume for the snapshot.
 			err = c.state.Cluster.StoragePoolVolumeRename(c.project, sname, newSnapshotName, storagePoolVolumeTypeContainer, poolID)
 			if err != nil {
 				logger.Error("Failed renaming storage volume", ctxMap)
 				return err
 			}
+
+			reverts = append(reverts, func() {
+				c.state.Cluster.StoragePoolVolumeRename(c.project, newSnapshotName, sname, storagePoolVolumeTypeContainer, poolID)
+			})
 		}
 	}
 
+	success = true
+
 	// Set the new name in the struct
 	c.name = newName
 
@@ -3975,8 +4731,14 @@ func (c *containerLXC) Update(args db.ContainerArgs, userRequested bool) error {
 		args.Profiles = []string{}
 	}
 
+	// Hash the console password
+	err := consolePasswordHash(args.Config)
+	if err != nil {
+		return err
+	}
+
 	// Validate the new config
-	err := containerValidConfig(c.state.OS, args.Config, false, false)
+	err = containerValidConfig(c.state.OS, args.Config, false, false)
 	if err != nil {
 		return errors.Wrap(err, "Invalid config")
 	}
@@ -4263,7 +5025,20 @@ func (c *containerLXC) Update(args db.ContainerArgs, userRequested bool) error {
 	if newRootDiskDeviceSize != oldRootDiskDeviceSize {
 		storageTypeName := c.storage.GetStorageTypeName()
 		storageIsReady := c.storage.ContainerStorageReady(c)
-		if (storageTypeName == "lvm" || storageTypeName == "ceph") && isRunning || !storageIsReady {
+
+		// LVM thin volumes can be grown while the container is running
+		liveGrow := false
+		lvm, ok := c.storage.(*storageLvm)
+		if ok && isRunning && newRootDiskDeviceSize != "" {
+			size, err := shared.ParseByteSizeString(newRootDiskDeviceSize)
+			if err != nil {
+				return err
+			}
+
+			liveGrow = lvm.canGrowLive(size)
+		}
+
+		if (storageTypeName == "lvm" && !liveGrow || storageTypeName == "ceph") && isRunning || !storageIsReady {
 			c.localConfig["volatile.apply_quota"] = newRootDiskDeviceSize
 		} else {
 			size, err := shared.ParseByteSizeString(newRootDiskDeviceSize)
@@ -4306,6 +5081,13 @@ func (c *containerLXC) Update(args db.ContainerArgs, userRequested bool) error {
 				if err != nil {
 					return err
 				}
+			} else if key == "security.seccomp.path_rules" {
+				// Reload the rules, the syscalls only being
+				// intercepted if there were rules at startup
+				err = seccompNotify.update(c)
+				if err != nil {
+					return err
+				}
 			} else if key == "security.devlxd" {
 				if value == "" || shared.IsTrue(value) {
 					err = c.insertMount(shared.VarPath("devlxd"), "/dev/lxd", "none", syscall.MS_BIND)
@@ -4331,7 +5113,7 @@ func (c *containerLXC) Update(args db.ContainerArgs, userRequested bool) error {
 						return fmt.Errorf("Failed to load kernel module '%s': %s", module, err)
 					}
 				}
-			} else if key == "limits.disk.priority" {
+			} else if key == "limits.disk.priority" || key == "priority.io" {
 				if !c.state.OS.CGroupBlkioController {
 					continue
 				}
@@ -4346,12 +5128,42 @@ func (c *containerLXC) Update(args db.ContainerArgs, userRequested bool) error {
 				}
 
 				// Minimum valid value is 10
-				priority := priorityInt * 100
-				if priority == 0 {
-					priority = 10
+				priority := fmt.Sprintf("%d", priorityInt*100)
+				if priorityInt == 0 {
+					priority = "10"
+				}
+
+				// The priority class takes precedence over the disk priority
+				ioWeight := c.expandedConfig["priority.io"]
+				if ioWeight != "" {
+					priority, err = deviceParseIOWeight(ioWeight)
+					if err != nil {
+						return err
+					}
+				}
+
+				err = c.CGroupSet("blkio.weight", priority)
+				if err != nil {
+					return err
+				}
+			} else if key == "limits.memory.low" || key == "limits.memory.guarantee" {
+				if !c.state.OS.CGroupMemoryUnified {
+					logger.Warn("Ignoring the memory protection of the container, the memory controller isn't on the unified cgroup hierarchy", log.Ctx{"container": c.Name()})
+					continue
+				}
+
+				parentMin, err := memoryProtectionParentMin(c.InitPID())
+				if err != nil {
+					return err
+				}
+
+				values, err := containerMemoryProtection(c.expandedConfig, parentMin)
+				if err != nil {
+					return err
 				}
 
-				err = c.CGroupSet("blkio.weight", fmt.Sprintf("%d", priority))
+				file := memoryProtectionKeys[key]
+				err = c.CGroupSet(file, values[file])
 				if err != nil {
 					return err
 				}
@@ -4366,6 +5178,11 @@ func (c *containerLXC) Update(args db.ContainerArgs, userRequested bool) error {
 				memoryEnforce := c.expandedConfig["limits.memory.enforce"]
 				memorySwap := c.expandedConfig["limits.memory.swap"]
 
+				// Memory balloons restart from their maximum
+				if memory == "" {
+					memory = c.expandedConfig["limits.memory.max"]
+				}
+
 				// Parse memory
 				if memory == "" {
 					memory = "-1"
@@ -4515,32 +5332,67 @@ func (c *containerLXC) Update(args db.ContainerArgs, userRequested bool) error {
 				if err != nil {
 					return err
 				}
-			} else if key == "limits.cpu" {
+			} else if key == "limits.cpu" || key == "limits.cpu.nodes" {
 				// Trigger a scheduler re-run
 				deviceTaskSchedulerTrigger("container", c.name, "changed")
-			} else if key == "limits.cpu.priority" || key == "limits.cpu.allowance" {
+
+				// Unbind the memory from the NUMA nodes it was bound to
+				if key == "limits.cpu.nodes" && value == "" && c.state.OS.CGroupCPUsetController {
+					mems := deviceHostMems()
+					if mems != "" {
+						err = c.CGroupSet("cpuset.mems", mems)
+						if err != nil {
+							return err
+						}
+					}
+				}
+
+				// The CPU bandwidth follows the number of CPUs
+				if key == "limits.cpu" && c.expandedConfig["limits.cpu.period"] != "" && (c.state.OS.CGroupCPUController || c.state.OS.CGroupCPUUnified) {
+					err = c.setCPUBandwidth()
+					if err != nil {
+						return err
+					}
+				}
+			} else if key == "limits.cpu.period" {
 				// Skip if no cpu CGroup
-				if !c.state.OS.CGroupCPUController {
+				if !c.state.OS.CGroupCPUController && !c.state.OS.CGroupCPUUnified {
 					continue
 				}
 
-				// Apply new CPU limits
-				cpuShares, cpuCfsQuota, cpuCfsPeriod, err := deviceParseCPU(c.expandedConfig["limits.cpu.allowance"], c.expandedConfig["limits.cpu.priority"])
+				// Apply the new CPU bandwidth
+				err = c.setCPUBandwidth()
 				if err != nil {
 					return err
 				}
-
-				err = c.CGroupSet("cpu.shares", cpuShares)
-				if err != nil {
-					return err
+			} else if key == "limits.cpu.priority" || key == "limits.cpu.allowance" || key == "priority.cpu" {
+				// Skip if no cpu CGroup
+				if !c.state.OS.CGroupCPUController && !c.state.OS.CGroupCPUUnified {
+					continue
 				}
 
-				err = c.CGroupSet("cpu.cfs_period_us", cpuCfsPeriod)
-				if err != nil {
-					return err
+				// Apply new CPU limits
+				if c.state.OS.CGroupCPUController {
+					cpuShares, _, _, err := deviceParseCPU(c.expandedConfig["limits.cpu.allowance"], c.expandedConfig["limits.cpu.priority"])
+					if err != nil {
+						return err
+					}
+
+					cpuWeight := c.expandedConfig["priority.cpu"]
+					if cpuWeight != "" {
+						cpuShares, err = deviceParseCPUWeight(cpuWeight)
+						if err != nil {
+							return err
+						}
+					}
+
+					err = c.CGroupSet("cpu.shares", cpuShares)
+					if err != nil {
+						return err
+					}
 				}
 
-				err = c.CGroupSet("cpu.cfs_quota_us", cpuCfsQuota)
+				err = c.setCPUBandwidth()
 				if err != nil {
 					return err
 				}
@@ -4565,11 +5417,34 @@ func (c *containerLXC) Update(args db.ContainerArgs, userRequested bool) error {
 						return err
 					}
 				}
+			} else if key == "oom_score_adj" {
+				oomDefault, err := containerOOMScoreAdjDefault(c.state)
+				if err != nil {
+					return err
+				}
+
+				err = containerOOMScoreAdjApply(c, oomDefault)
+				if err != nil {
+					return err
+				}
 			}
 		}
 
 		var usbs []usbDevice
 
+		// PCI devices are only bound to vfio-pci on startup
+		for _, devices := range []map[string]types.Device{removeDevices, addDevices, updateDevices} {
+			for _, m := range devices {
+				if m["type"] == "pci" {
+					return fmt.Errorf("PCI devices can only be added, removed or changed while the container is stopped")
+				}
+
+				if m["type"] == "iso" {
+					return fmt.Errorf("ISO devices can only be added, removed or changed while the container is stopped")
+				}
+			}
+		}
+
 		// Live update the devices
 		for k, m := range removeDevices {
 			if shared.StringInSlice(m["type"], []string{"unix-char", "unix-block"}) {
@@ -4602,6 +5477,8 @@ func (c *containerLXC) Update(args db.ContainerArgs, userRequested bool) error {
 				if err != nil {
 					return err
 				}
+
+				c.releaseInfinibandVF(k, m)
 			} else if m["type"] == "usb" {
 				if usbs == nil {
 					usbs, err = deviceLoadUsb()
@@ -4693,11 +5570,33 @@ func (c *containerLXC) Update(args db.ContainerArgs, userRequested bool) error {
 				if err != nil {
 					return err
 				}
+			} else if m["type"] == "tun" {
+				err = c.removeTunDevice(k, m)
+				if err != nil {
+					return err
+				}
+			} else if m["type"] == "vlan" {
+				err = c.removeVlanDevice(k, m)
+				if err != nil {
+					return err
+				}
+			} else if devicePlugins[m["type"]] != nil {
+				err = devicePlugins[m["type"]].Stop(c, m)
+				if err != nil {
+					return err
+				}
 			}
 		}
 
 		diskDevices := map[string]types.Device{}
 		for k, m := range addDevices {
+			if shared.StringInSlice(m["type"], []string{"nic", "usb", "gpu"}) {
+				err = deviceLoadKernelModules(k, m)
+				if err != nil {
+					return err
+				}
+			}
+
 			if shared.StringInSlice(m["type"], []string{"unix-char", "unix-block"}) {
 				err = c.insertUnixDevice(fmt.Sprintf("unix.%s", k), m, true)
 				if err != nil {
@@ -4831,6 +5730,21 @@ func (c *containerLXC) Update(args db.ContainerArgs, userRequested bool) error {
 				if err != nil {
 					return err
 				}
+			} else if m["type"] == "tun" {
+				err = c.insertTunDevice(k, m)
+				if err != nil {
+					return err
+				}
+			} else if m["type"] == "vlan" {
+				err = c.insertVlanDevice(k, m)
+				if err != nil {
+					return err
+				}
+			} else if devicePlugins[m["type"]] != nil {
+				err = devicePlugins[m["type"]].Start(c, m)
+				if err != nil {
+					return err
+				}
 			}
 		}
 
@@ -4865,6 +5779,37 @@ func (c *containerLXC) Update(args db.ContainerArgs, userRequested bool) error {
 				if err != nil {
 					return err
 				}
+			} else if m["type"] == "tun" {
+				err = c.removeTunDevice(k, oldExpandedDevices[k])
+				if err != nil {
+					return err
+				}
+
+				err = c.insertTunDevice(k, m)
+				if err != nil {
+					return err
+				}
+			} else if m["type"] == "vlan" {
+				err = c.removeVlanDevice(k, oldExpandedDevices[k])
+				if err != nil {
+					return err
+				}
+
+				err = c.insertVlanDevice(k, m)
+				if err != nil {
+					return err
+				}
+			} else if devicePlugins[m["type"]] != nil {
+				// Plugins have no update hook, restart the device
+				err = devicePlugins[m["type"]].Stop(c, oldExpandedDevices[k])
+				if err != nil {
+					return err
+				}
+
+				err = devicePlugins[m["type"]].Start(c, m)
+				if err != nil {
+					return err
+				}
 			}
 		}
 
@@ -6050,6 +6995,170 @@ func (c *containerLXC) FileRemove(path string) error {
 	return nil
 }
 
+// fileXattr runs a forkfile xattr subcommand and returns its output.
+func (c *containerLXC) fileXattr(args ...string) (string, error) {
+	var errStr string
+	var ourStart bool
+	var err error
+
+	// Setup container storage if needed
+	if !c.IsRunning() {
+		ourStart, err = c.StorageStart()
+		if err != nil {
+			return "", err
+		}
+	}
+
+	out, err := shared.RunCommand(
+		c.state.OS.ExecPath,
+		append([]string{"forkfile", args[0], c.RootfsPath(), fmt.Sprintf("%d", c.InitPID())}, args[1:]...)...,
+	)
+
+	// Tear down container storage if needed
+	if !c.IsRunning() && ourStart {
+		_, err := c.StorageStop()
+		if err != nil {
+			return "", err
+		}
+	}
+
+	// Process forkfile response
+	for _, line := range strings.Split(strings.TrimRight(out, "\n"), "\n") {
+		if line == "" {
+			continue
+		}
+
+		// Extract errors
+		if strings.HasPrefix(line, "error: ") {
+			errStr = strings.TrimPrefix(line, "error: ")
+			continue
+		}
+
+		if strings.HasPrefix(line, "errno: ") {
+			errno := strings.TrimPrefix(line, "errno: ")
+			if errno == "2" {
+				return "", os.ErrNotExist
+			}
+
+			if errno == "95" {
+				return "", errACLNotSupported
+			}
+
+			return "", fmt.Errorf("%s", errStr)
+		}
+	}
+
+	if err != nil {
+		return "", err
+	}
+
+	return out, nil
+}
+
+func (c *containerLXC) fileXattrGet(path string, name string) ([]byte, error) {
+	out, err := c.fileXattr("getxattr", path, name)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, line := range strings.Split(out, "\n") {
+		if strings.HasPrefix(line, "value: ") {
+			return hex.DecodeString(strings.TrimPrefix(line, "value: "))
+		}
+	}
+
+	return nil, nil
+}
+
+// FileACLGet returns the POSIX ACL of a path in the getfacl text format, or an
+// empty string if the path has no extended ACL.
+func (c *containerLXC) FileACLGet(path string) (string, error) {
+	entries := map[string][]aclEntry{}
+	for _, name := range []string{aclXattrAccess, aclXattrDefault} {
+		value, err := c.fileXattrGet(path, name)
+		if err != nil {
+			return "", err
+		}
+
+		if value == nil {
+			continue
+		}
+
+		entries[name], err = aclDecode(value)
+		if err != nil {
+			return "", err
+		}
+	}
+
+	if len(entries) == 0 {
+		return "", nil
+	}
+
+	// Unmap uid and gid if needed
+	if !c.IsRunning() {
+		idmapset, err := c.DiskIdmap()
+		if err != nil {
+			return "", err
+		}
+
+		if idmapset != nil {
+			for name := range entries {
+				err := aclShift(entries[name], idmapset.ShiftFromNs)
+				if err != nil {
+					return "", err
+				}
+
+				entries[name] = aclComplete(entries[name])
+			}
+		}
+	}
+
+	return aclFormat(entries[aclXattrAccess], entries[aclXattrDefault]), nil
+}
+
+// FileACLSet applies a POSIX ACL in the getfacl text format to a path. The
+// access and default ACLs are only replaced if the ACL has entries for them.
+func (c *containerLXC) FileACLSet(path string, acl string) error {
+	access, def, err := aclParse(acl)
+	if err != nil {
+		return err
+	}
+
+	entries := map[string][]aclEntry{aclXattrAccess: access, aclXattrDefault: def}
+
+	// Map uid and gid if needed
+	if !c.IsRunning() {
+		idmapset, err := c.DiskIdmap()
+		if err != nil {
+			return err
+		}
+
+		if idmapset != nil {
+			for name := range entries {
+				err := aclShift(entries[name], idmapset.ShiftIntoNs)
+				if err != nil {
+					return err
+				}
+
+				entries[name] = aclComplete(entries[name])
+			}
+		}
+	}
+
+	for _, name := range []string{aclXattrAccess, aclXattrDefault} {
+		if len(entries[name]) == 0 {
+			continue
+		}
+
+		_, err := c.fileXattr("setxattr", path, name, hex.EncodeToString(aclEncode(entries[name])))
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
 func (c *containerLXC) Console(terminal *os.File) *exec.Cmd {
 	args := []string{
 		c.state.OS.ExecPath,
@@ -6079,11 +7188,12 @@ func (c *containerLXC) ConsoleLog(opts lxc.ConsoleLogOptions) (string, error) {
 }
 
 func (c *containerLXC) Exec(command []string, env map[string]string, stdin *os.File, stdout *os.File, stderr *os.File, wait bool) (*exec.Cmd, int, int, error) {
-	// Prepare the environment
-	envSlice := []string{}
-
-	for k, v := range env {
-		envSlice = append(envSlice, fmt.Sprintf("%s=%s", k, v))
+	// Prepare the environment, passed to forkexec through its own
+	// environment as it may hold secrets which the command line would
+	// expose to every user of the host
+	envData, err := json.Marshal(env)
+	if err != nil {
+		return nil, -1, -1, err
 	}
 
 	// Setup logfile
@@ -6097,10 +7207,6 @@ func (c *containerLXC) Exec(command []string, env map[string]string, stdin *os.F
 	cname := projectPrefix(c.Project(), c.Name())
 	args := []string{c.state.OS.ExecPath, "forkexec", cname, c.state.OS.LxcPath, filepath.Join(c.LogPath(), "lxc.conf")}
 
-	args = append(args, "--")
-	args = append(args, "env")
-	args = append(args, envSlice...)
-
 	args = append(args, "--")
 	args = append(args, "cmd")
 	args = append(args, command...)
@@ -6126,8 +7232,32 @@ func (c *containerLXC) Exec(command []string, env map[string]string, stdin *os.F
 		useRexec = true
 	}
 
+	cmd.Env = append(os.Environ(), fmt.Sprintf("%s=%s", forkexecEnv, envData))
+
 	if useRexec {
-		cmd.Env = append(os.Environ(), "LXC_MEMFD_REXEC=1")
+		cmd.Env = append(cmd.Env, "LXC_MEMFD_REXEC=1")
+	}
+
+	thpEnv := c.thpEnv()
+	if thpEnv != nil {
+		if cmd.Env == nil {
+			cmd.Env = os.Environ()
+		}
+
+		cmd.Env = append(cmd.Env, thpEnv...)
+	}
+
+	ulimitsEnv, err := c.ulimitsEnv()
+	if err != nil {
+		return nil, -1, -1, err
+	}
+
+	if ulimitsEnv != nil {
+		if cmd.Env == nil {
+			cmd.Env = os.Environ()
+		}
+
+		cmd.Env = append(cmd.Env, ulimitsEnv...)
 	}
 
 	// Setup communication PIPE
@@ -6246,6 +7376,37 @@ func (c *containerLXC) memoryState() api.ContainerStateMemory {
 		memory.Usage = valueInt
 	}
 
+	// Memory limit in bytes, as adjusted by the memory balloon
+	min, max, _, err := containerBalloonLimits(c.expandedConfig)
+	if err == nil && max >= 0 {
+		memory.LimitMin = min
+		memory.LimitMax = max
+
+		value, err := c.CGroupGet("memory.limit_in_bytes")
+		valueInt, err1 := strconv.ParseInt(value, 10, 64)
+		if err == nil && err1 == nil {
+			memory.Limit = valueInt
+		}
+	}
+
+	// Detailed memory usage
+	value, err = c.CGroupGet("memory.stat")
+	if err == nil {
+		stats := cgroupMemoryStats(value)
+
+		// The unified hierarchy doesn't have memory.usage_in_bytes
+		if memory.Usage == 0 {
+			value, err := c.CGroupGet("memory.current")
+			valueInt, err1 := strconv.ParseInt(value, 10, 64)
+			if err == nil && err1 == nil {
+				memory.Usage = valueInt
+			}
+		}
+
+		stats.Usage = memory.Usage
+		memory.Stats = &stats
+	}
+
 	// Memory peak in bytes
 	value, err = c.CGroupGet("memory.max_usage_in_bytes")
 	valueInt, err1 = strconv.ParseInt(value, 10, 64)
@@ -6276,6 +7437,27 @@ func (c *containerLXC) memoryState() api.ContainerStateMemory {
 	return memory
 }
 
+func (c *containerLXC) proxyState() map[string]api.ContainerStateProxy {
+	result := map[string]api.ContainerStateProxy{}
+
+	for _, name := range c.expandedDevices.DeviceNames() {
+		m := c.expandedDevices[name]
+		if m["type"] != "proxy" || shared.IsTrue(m["nat"]) {
+			continue
+		}
+
+		pidPath := filepath.Join(c.DevicesPath(), fmt.Sprintf("proxy.%s", name))
+		connections, err := proxyConnectionsGet(pidPath)
+		if err != nil {
+			continue
+		}
+
+		result[name] = api.ContainerStateProxy{Connections: connections}
+	}
+
+	return result
+}
+
 func (c *containerLXC) networkState() map[string]api.ContainerStateNetwork {
 	result := map[string]api.ContainerStateNetwork{}
 
@@ -6314,16 +7496,58 @@ func (c *containerLXC) networkState() map[string]api.ContainerStateNetwork {
 		// setns() + netns_getifaddrs() style retrieval.
 		c.state.OS.NetnsGetifaddrs = false
 
-		nw := map[string]api.ContainerStateNetwork{}
-		err = json.Unmarshal([]byte(out), &nw)
+		nw := map[string]api.ContainerStateNetwork{}
+		err = json.Unmarshal([]byte(out), &nw)
+		if err != nil {
+			logger.Error("Failure to read forkgetnet json", log.Ctx{"container": c.name, "err": err})
+			return result
+		}
+		result = nw
+	}
+
+	return result
+}
+
+func (c *containerLXC) timeNamespaceState() *api.ContainerStateTimeNamespace {
+	pid := c.InitPID()
+	if pid < 1 || !c.state.OS.TimeNamespace {
+		return nil
+	}
+
+	// Compare the container's time namespace with our own
+	hostNs, err := os.Readlink("/proc/self/ns/time")
+	if err != nil {
+		return nil
+	}
+
+	containerNs, err := os.Readlink(fmt.Sprintf("/proc/%d/ns/time", pid))
+	if err != nil || containerNs == hostNs {
+		return nil
+	}
+
+	timens := api.ContainerStateTimeNamespace{}
+
+	// Lines are of the form "<clock> <seconds> <nanoseconds>"
+	content, err := ioutil.ReadFile(fmt.Sprintf("/proc/%d/timens_offsets", pid))
+	if err != nil {
+		return &timens
+	}
+
+	for _, line := range strings.Split(string(content), "\n") {
+		fields := strings.Fields(line)
+		if len(fields) != 3 || fields[0] != "monotonic" {
+			continue
+		}
+
+		offset, err := strconv.ParseInt(fields[1], 10, 64)
 		if err != nil {
-			logger.Error("Failure to read forkgetnet json", log.Ctx{"container": c.name, "err": err})
-			return result
+			continue
 		}
-		result = nw
+
+		timens.OffsetSeconds = offset
 	}
 
-	return result
+	return &timens
 }
 
 func (c *containerLXC) processesState() int64 {
@@ -6347,27 +7571,18 @@ func (c *containerLXC) processesState() int64 {
 		return valueInt
 	}
 
-	pids := []int64{int64(pid)}
-
-	// Go through the pid list, adding new pids at the end so we go through them all
-	for i := 0; i < len(pids); i++ {
-		fname := fmt.Sprintf("/proc/%d/task/%d/children", pids[i], pids[i])
-		fcont, err := ioutil.ReadFile(fname)
-		if err != nil {
-			// the process terminated during execution of this loop
-			continue
-		}
+	return int64(len(containerProcesses(pid)))
+}
 
-		content := strings.Split(string(fcont), " ")
-		for j := 0; j < len(content); j++ {
-			pid, err := strconv.ParseInt(content[j], 10, 64)
-			if err == nil {
-				pids = append(pids, pid)
-			}
-		}
+func (c *containerLXC) openFilesState() int64 {
+	// Return 0 if not running
+	pid := c.InitPID()
+	if pid == -1 {
+		return 0
 	}
 
-	return int64(len(pids))
+	count, _ := containerOpenFiles("/proc", containerProcesses(pid))
+	return count
 }
 
 func (c *containerLXC) tarStoreFile(linkmap map[uint64]string, offset int, tw *tar.Writer, path string, fi os.FileInfo) error {
@@ -7115,6 +8330,34 @@ func (c *containerLXC) addInfinibandDevices(deviceName string, ifDev *IBF, injec
 	return c.addInfinibandDevicesPerFun(deviceName, ifDev, inject)
 }
 
+// releaseInfinibandVF resets the infiniband virtual function used by a device
+// and returns it to the pool.
+func (c *containerLXC) releaseInfinibandVF(deviceName string, device types.Device) {
+	if device["type"] != "infiniband" || device["nictype"] != "sriov" {
+		return
+	}
+
+	vfName := c.localConfig[fmt.Sprintf("volatile.%s.vf_name", deviceName)]
+	if vfName != "" && device["pkey"] != "" {
+		infinibandRemovePkey(vfName, device["pkey"])
+	}
+
+	infinibandPool.release(c, deviceName)
+
+	for _, key := range []string{"vf", "vf_name"} {
+		configKey := fmt.Sprintf("volatile.%s.%s", deviceName, key)
+		if c.localConfig[configKey] == "" {
+			continue
+		}
+
+		delete(c.localConfig, configKey)
+		err := c.state.Cluster.ContainerConfigRemove(c.id, configKey)
+		if err != nil {
+			logger.Warn("Failed to clear infiniband virtual function", log.Ctx{"container": c.Name(), "device": deviceName, "err": err})
+		}
+	}
+}
+
 func (c *containerLXC) removeInfinibandDevices(deviceName string, device types.Device) error {
 	// load all devices
 	dents, err := ioutil.ReadDir(c.DevicesPath())
@@ -7426,8 +8669,8 @@ func (c *containerLXC) removeProxyDevices() error {
 	}
 
 	for _, f := range devFiles {
-		// Skip non-proxy devices
-		if !strings.HasPrefix(f.Name(), "proxy.") {
+		// Skip non-proxy devices and the connection counters
+		if !strings.HasPrefix(f.Name(), "proxy.") || strings.HasSuffix(f.Name(), ".connections") {
 			continue
 		}
 
@@ -7471,6 +8714,286 @@ func (c *containerLXC) restartProxyDevices() error {
 	return nil
 }
 
+func (c *containerLXC) insertTunDevice(devName string, m types.Device) error {
+	if !c.IsRunning() {
+		return fmt.Errorf("Can't add TUN device to stopped container")
+	}
+
+	mode := m["mode"]
+	if mode == "" {
+		mode = "tun"
+	}
+
+	// The owner of the interface is given as a host uid/gid
+	uid := ""
+	gid := ""
+	if m["uid"] != "" || m["gid"] != "" {
+		containerUid, _ := strconv.ParseInt(m["uid"], 10, 64)
+		containerGid, _ := strconv.ParseInt(m["gid"], 10, 64)
+
+		hostUid, hostGid := containerUid, containerGid
+		idmapset, err := c.CurrentIdmap()
+		if err != nil {
+			return err
+		}
+
+		if idmapset != nil {
+			hostUid, hostGid = idmapset.ShiftIntoNs(containerUid, containerGid)
+		}
+
+		if m["uid"] != "" {
+			uid = fmt.Sprintf("%d", hostUid)
+		}
+
+		if m["gid"] != "" {
+			gid = fmt.Sprintf("%d", hostGid)
+		}
+	}
+
+	_, err := shared.RunCommand(
+		c.state.OS.ExecPath,
+		"forknet",
+		"tun-add",
+		fmt.Sprintf("%d", c.InitPID()),
+		m["name"],
+		mode,
+		uid,
+		gid,
+		m["socket"],
+	)
+	if err != nil {
+		return fmt.Errorf("Error occurred when adding TUN device '%s': %s", devName, err)
+	}
+
+	return nil
+}
+
+func (c *containerLXC) removeTunDevice(devName string, m types.Device) error {
+	if !c.IsRunning() {
+		return fmt.Errorf("Can't remove TUN device from stopped container")
+	}
+
+	mode := m["mode"]
+	if mode == "" {
+		mode = "tun"
+	}
+
+	_, err := shared.RunCommand(
+		c.state.OS.ExecPath,
+		"forknet",
+		"tun-remove",
+		fmt.Sprintf("%d", c.InitPID()),
+		m["name"],
+		mode,
+	)
+	if err != nil {
+		return fmt.Errorf("Error occurred when removing TUN device '%s': %s", devName, err)
+	}
+
+	return nil
+}
+
+// startMemoryProtectionCheck checks the memory guarantee of a container which
+// just started against the memory.min of the parent of its cgroup.
+func (c *containerLXC) startMemoryProtectionCheck() error {
+	if c.expandedConfig["limits.memory.guarantee"] == "" || !c.state.OS.CGroupMemoryUnified {
+		return nil
+	}
+
+	parentMin, err := memoryProtectionParentMin(c.InitPID())
+	if err != nil {
+		return err
+	}
+
+	_, err = containerMemoryProtection(c.expandedConfig, parentMin)
+	return err
+}
+
+func (c *containerLXC) startNetworkQueues() error {
+	for _, name := range c.expandedDevices.DeviceNames() {
+		m := c.expandedDevices[name]
+		if m["type"] != "nic" || m["queues"] == "" {
+			continue
+		}
+
+		m, err := c.fillNetworkDevice(name, m)
+		if err != nil {
+			return err
+		}
+
+		// The veth pairs created by LXC only have a single queue, which
+		// can't be changed afterwards, so don't fail the start over it
+		m["host_name"] = c.getHostInterface(m["name"])
+		err = c.setNetworkQueues(m)
+		if err != nil {
+			logger.Warn("Failed to configure the nic queues", log.Ctx{"container": c.Name(), "device": name, "err": err})
+		}
+	}
+
+	return nil
+}
+
+// setNetworkQueues configures the queues of both ends of a veth based nic.
+func (c *containerLXC) setNetworkQueues(m types.Device) error {
+	if m["queues"] == "" || !shared.StringInSlice(m["nictype"], []string{"bridged", "p2p"}) {
+		return nil
+	}
+
+	queues, err := nicQueuesCount(c.expandedConfig, m["queues"])
+	if err != nil {
+		return err
+	}
+
+	if m["host_name"] == "" {
+		return fmt.Errorf("Couldn't find the host side of interface %s", m["name"])
+	}
+
+	err = networkSetQueues(m["host_name"], queues)
+	if err != nil {
+		return err
+	}
+
+	_, err = shared.RunCommand(
+		c.state.OS.ExecPath,
+		"forknet",
+		"queues",
+		fmt.Sprintf("%d", c.InitPID()),
+		m["name"],
+		fmt.Sprintf("%d", queues),
+	)
+	if err != nil {
+		return fmt.Errorf("Failed to configure the queues of interface %s: %s", m["name"], err)
+	}
+
+	return nil
+}
+
+func (c *containerLXC) startTunDevices() error {
+	for _, name := range c.expandedDevices.DeviceNames() {
+		m := c.expandedDevices[name]
+		if m["type"] == "tun" {
+			err := c.insertTunDevice(name, m)
+			if err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
+
+// hostHooksPath returns the directory the host hooks of the container are
+// loaded from. It's owned by the daemon, out of reach of the images, imports
+// and copies which unpack into the container's directory.
+func (c *containerLXC) hostHooksPath() string {
+	name := projectPrefix(c.Project(), c.Name())
+	return shared.VarPath("hooks", name)
+}
+
+// runHostHook runs the given hook from the container's hooks directory on the host.
+func (c *containerLXC) runHostHook(hook string) error {
+	hookPath := filepath.Join(c.hostHooksPath(), hook)
+	if !shared.PathExists(hookPath) {
+		return nil
+	}
+
+	timeout := 30
+	if c.expandedConfig["boot.host_hooks.timeout"] != "" {
+		var err error
+		timeout, err = strconv.Atoi(c.expandedConfig["boot.host_hooks.timeout"])
+		if err != nil {
+			return err
+		}
+	}
+
+	config, err := json.Marshal(c.expandedConfig)
+	if err != nil {
+		return err
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Duration(timeout)*time.Second)
+	defer cancel()
+
+	cmd := exec.CommandContext(ctx, hookPath)
+	cmd.Dir = c.hostHooksPath()
+	cmd.Env = append(os.Environ(),
+		fmt.Sprintf("LXD_HOOK=%s", hook),
+		fmt.Sprintf("LXD_CONTAINER_NAME=%s", c.Name()),
+		fmt.Sprintf("LXD_CONTAINER_PROJECT=%s", c.Project()),
+		fmt.Sprintf("LXD_CONTAINER_PID=%d", c.InitPID()),
+		fmt.Sprintf("LXD_CONTAINER_CONFIG=%s", config))
+
+	output, err := cmd.CombinedOutput()
+	if ctx.Err() == context.DeadlineExceeded {
+		return fmt.Errorf("Host hook '%s' timed out after %ds", hook, timeout)
+	}
+
+	if err != nil {
+		return fmt.Errorf("Host hook '%s' failed: %s: %s", hook, strings.TrimSpace(string(output)), err)
+	}
+
+	return nil
+}
+
+// cloud-init metadata server handling
+func (c *containerLXC) startCloudInitServer() error {
+	if !cloudInitEnabled(c.expandedConfig) {
+		return nil
+	}
+
+	seedPath := filepath.Join(c.DevicesPath(), "cloud-init")
+	err := cloudInitWriteSeed(c, seedPath)
+	if err != nil {
+		return err
+	}
+
+	logPath := filepath.Join(c.LogPath(), "cloud-init.log")
+	logFile, err := os.OpenFile(logPath, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0644)
+	if err != nil {
+		return err
+	}
+	defer logFile.Close()
+
+	// Spawn the server in the container's network namespace
+	cmd := exec.Cmd{}
+	cmd.Path = c.state.OS.ExecPath
+	cmd.Args = []string{c.state.OS.ExecPath, "forknet", "metadata", fmt.Sprintf("%d", c.InitPID()), seedPath}
+	cmd.Stdout = logFile
+	cmd.Stderr = logFile
+
+	err = cmd.Start()
+	if err != nil {
+		return err
+	}
+
+	// Write the PID file
+	pidPath := filepath.Join(c.DevicesPath(), "cloud-init.pid")
+	err = ioutil.WriteFile(pidPath, []byte(fmt.Sprintf("%d\n", cmd.Process.Pid)), 0600)
+	if err != nil {
+		syscall.Kill(cmd.Process.Pid, syscall.SIGKILL)
+		return err
+	}
+
+	// Reap the process once it exits
+	go cmd.Wait()
+
+	return nil
+}
+
+func (c *containerLXC) stopCloudInitServer() error {
+	pidPath := filepath.Join(c.DevicesPath(), "cloud-init.pid")
+	if !shared.PathExists(pidPath) {
+		return nil
+	}
+
+	err := killCloudInitServer(pidPath)
+	if err != nil {
+		return err
+	}
+
+	return os.RemoveAll(filepath.Join(c.DevicesPath(), "cloud-init"))
+}
+
 // Network device handling
 func (c *containerLXC) createNetworkDevice(name string, m types.Device) (string, error) {
 	var dev, n1 string
@@ -7492,7 +9015,22 @@ func (c *containerLXC) createNetworkDevice(name string, m types.Device) (string,
 	if shared.StringInSlice(m["nictype"], []string{"bridged", "p2p"}) {
 		n2 := deviceNextVeth()
 
-		_, err := shared.RunCommand("ip", "link", "add", "dev", n1, "type", "veth", "peer", "name", n2)
+		// The number of queues of a veth is fixed when it's created
+		args := []string{"link", "add", "dev", n1}
+		peer := []string{"peer", "name", n2}
+		if m["queues"] != "" {
+			queues, err := nicQueuesCount(c.expandedConfig, m["queues"])
+			if err != nil {
+				return "", err
+			}
+
+			count := strconv.Itoa(queues)
+			args = append(args, "numtxqueues", count, "numrxqueues", count)
+			peer = append(peer, "numtxqueues", count, "numrxqueues", count)
+		}
+
+		args = append(args, "type", "veth")
+		_, err := shared.RunCommand("ip", append(args, peer...)...)
 		if err != nil {
 			return "", fmt.Errorf("Failed to create the veth interface: %s", err)
 		}
@@ -7602,6 +9140,65 @@ func (c *containerLXC) fillSriovNetworkDevice(name string, m types.Device, reser
 		return nil, fmt.Errorf("Parent device '%s' doesn't support SR-IOV", m["parent"])
 	}
 
+	// Infiniband virtual functions come from the pool
+	if m["type"] == "infiniband" {
+		vf, err := infinibandPool.allocate(c, name, m["parent"], m["guid"], reserved)
+		if err != nil {
+			return nil, err
+		}
+
+		nicName := vf.name
+		if m["pkey"] != "" {
+			nicName, err = infinibandSetupPkey(vf.name, m["pkey"])
+			if err != nil {
+				infinibandPool.release(c, name)
+				return nil, err
+			}
+		}
+
+		newDevice["host_name"] = nicName
+		c.localConfig[fmt.Sprintf("volatile.%s.host_name", name)] = nicName
+
+		// Record the allocation in the database
+		volatile := map[string]string{
+			fmt.Sprintf("volatile.%s.vf", name):      vf.pci,
+			fmt.Sprintf("volatile.%s.vf_name", name): vf.name,
+		}
+
+		for key := range volatile {
+			err = c.state.Cluster.ContainerConfigRemove(c.id, key)
+			if err != nil {
+				infinibandPool.release(c, name)
+				return nil, err
+			}
+		}
+
+		err = c.state.Cluster.Transaction(func(tx *db.ClusterTx) error {
+			return tx.ContainerConfigInsert(c.id, volatile)
+		})
+		if err != nil {
+			infinibandPool.release(c, name)
+			return nil, err
+		}
+
+		for key, value := range volatile {
+			c.localConfig[key] = value
+		}
+
+		return newDevice, nil
+	}
+
+	// Use the virtual function reserved when migrating the container
+	nicName := sriovReservationGet(c, name)
+	if nicName != "" {
+		newDevice["host_name"] = nicName
+		c.localConfig[fmt.Sprintf("volatile.%s.host_name", name)] = nicName
+		return newDevice, nil
+	}
+
+	// Skip those reserved for other containers
+	reserved = append(reserved, sriovReservedNames(c)...)
+
 	// get number of currently enabled VFs
 	sriovNumVfsBuf, err := ioutil.ReadFile(sriovNumVFs)
 	if err != nil {
@@ -7631,7 +9228,6 @@ func (c *containerLXC) fillSriovNetworkDevice(name string, m types.Device, reser
 	}
 
 	// Check if any VFs are already enabled
-	nicName := ""
 	for i := 0; i < sriovNum; i++ {
 		if !shared.PathExists(fmt.Sprintf("/sys/class/net/%s/device/virtfn%d/net", m["parent"], i)) {
 			continue
@@ -7990,6 +9586,13 @@ func (c *containerLXC) insertNetworkDevice(name string, m types.Device) (types.D
 		return nil, fmt.Errorf("Failed to attach interface: %s: %s", devName, err)
 	}
 
+	// Configure the queues on both ends
+	m["host_name"] = devName
+	err = c.setNetworkQueues(m)
+	if err != nil {
+		return nil, err
+	}
+
 	return m, nil
 }
 
@@ -8760,6 +10363,35 @@ func (c *containerLXC) DiskIdmap() (*idmap.IdmapSet, error) {
 	return idmapsetFromString(jsonIdmap)
 }
 
+// RemapFilesystem re-chowns the container's rootfs from the old idmap to the
+// new one. The rootfs must be mounted. On failure, the files which were
+// already remapped are restored to their previous ownership.
+func (c *containerLXC) RemapFilesystem(oldIdmap *idmap.IdmapSet, newIdmap *idmap.IdmapSet) error {
+	if oldIdmap == nil && newIdmap == nil {
+		return nil
+	}
+
+	var skipper func(dir string, absPath string, fi os.FileInfo) bool
+	if c.Storage().GetStorageType() == storageTypeZfs {
+		skipper = zfsIdmapSetSkipper
+	}
+
+	progress := func(count int64) {
+		if count%1000 != 0 {
+			return
+		}
+
+		c.updateProgress(fmt.Sprintf("Remapping container filesystem: %d files", count))
+	}
+
+	err := oldIdmap.RemapRootfs(c.RootfsPath(), newIdmap, skipper, progress)
+	if err != nil {
+		return errors.Wrap(err, "Remap container filesystem")
+	}
+
+	return nil
+}
+
 func (c *containerLXC) NextIdmap() (*idmap.IdmapSet, error) {
 	jsonIdmap, ok := c.LocalConfig()["volatile.idmap.next"]
 	if !ok {
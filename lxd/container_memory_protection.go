@@ -0,0 +1,101 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"io/ioutil"
+	"math"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/lxc/lxd/shared"
+)
+
+// memoryProtectionKeys maps the container configuration keys to the cgroup2
+// memory protection files they set.
+var memoryProtectionKeys = map[string]string{
+	"limits.memory.low":       "memory.low",
+	"limits.memory.guarantee": "memory.min",
+}
+
+// memoryProtectionCGroupPath returns the path of a process in the unified
+// cgroup hierarchy, given the content of /proc/<pid>/cgroup.
+func memoryProtectionCGroupPath(content string) (string, error) {
+	scan := bufio.NewScanner(strings.NewReader(content))
+	for scan.Scan() {
+		fields := strings.SplitN(scan.Text(), ":", 3)
+		if len(fields) == 3 && fields[0] == "0" && fields[1] == "" {
+			return fields[2], nil
+		}
+	}
+
+	return "", fmt.Errorf("Not part of the unified cgroup hierarchy")
+}
+
+// memoryProtectionParseValue parses the content of a memory.min or
+// memory.low file.
+func memoryProtectionParseValue(content string) (int64, error) {
+	content = strings.TrimSpace(content)
+	if content == "max" {
+		return math.MaxInt64, nil
+	}
+
+	return strconv.ParseInt(content, 10, 64)
+}
+
+// memoryProtectionParentMin returns the memory.min of the parent of the
+// cgroup of a running container, or -1 if it's the root cgroup which doesn't
+// have one.
+func memoryProtectionParentMin(pid int) (int64, error) {
+	path, err := ebpfCgroupPath(pid)
+	if err != nil {
+		return -1, err
+	}
+
+	content, err := ioutil.ReadFile(filepath.Join(filepath.Dir(path), "memory.min"))
+	if err != nil {
+		if os.IsNotExist(err) {
+			return -1, nil
+		}
+
+		return -1, err
+	}
+
+	return memoryProtectionParseValue(string(content))
+}
+
+// containerMemoryProtection returns the values of the cgroup2 memory
+// protection files of a container, 0 for the keys which aren't set. The
+// guarantee can't exceed parentMin, the memory.min of the parent cgroup,
+// which would make it ineffective. A negative parentMin skips that check.
+func containerMemoryProtection(config map[string]string, parentMin int64) (map[string]string, error) {
+	memoryTotal, err := shared.DeviceTotalMemory()
+	if err != nil {
+		return nil, err
+	}
+
+	values := map[string]string{}
+	for key, file := range memoryProtectionKeys {
+		if config[key] == "" {
+			values[file] = "0"
+			continue
+		}
+
+		value, err := balloonParseLimit(config[key], memoryTotal)
+		if err != nil {
+			return nil, err
+		}
+
+		if key == "limits.memory.guarantee" {
+			if parentMin >= 0 && value > parentMin {
+				return nil, fmt.Errorf("limits.memory.guarantee (%d bytes) can't be higher than the memory.min of the parent cgroup (%d bytes)", value, parentMin)
+			}
+		}
+
+		values[file] = strconv.FormatInt(value, 10)
+	}
+
+	return values, nil
+}
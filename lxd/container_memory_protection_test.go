@@ -0,0 +1,49 @@
+package main
+
+import (
+	"math"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMemoryProtectionCGroupPath(t *testing.T) {
+	path, err := memoryProtectionCGroupPath("0::/system.slice/snap.lxd.daemon.service\n")
+	require.NoError(t, err)
+	assert.Equal(t, "/system.slice/snap.lxd.daemon.service", path)
+
+	path, err = memoryProtectionCGroupPath("12:memory:/lxd\n1:name=systemd:/lxd\n0::/lxd\n")
+	require.NoError(t, err)
+	assert.Equal(t, "/lxd", path)
+
+	_, err = memoryProtectionCGroupPath("12:memory:/lxd\n1:name=systemd:/lxd\n")
+	assert.Error(t, err)
+}
+
+func TestMemoryProtectionParseValue(t *testing.T) {
+	value, err := memoryProtectionParseValue("1073741824\n")
+	require.NoError(t, err)
+	assert.Equal(t, int64(1073741824), value)
+
+	value, err = memoryProtectionParseValue("max\n")
+	require.NoError(t, err)
+	assert.Equal(t, int64(math.MaxInt64), value)
+
+	_, err = memoryProtectionParseValue("")
+	assert.Error(t, err)
+}
+
+func TestContainerMemoryProtection(t *testing.T) {
+	config := map[string]string{"limits.memory.guarantee": "1GB"}
+
+	values, err := containerMemoryProtection(config, -1)
+	require.NoError(t, err)
+	assert.Equal(t, map[string]string{"memory.low": "0", "memory.min": "1073741824"}, values)
+
+	_, err = containerMemoryProtection(config, 512*1024*1024)
+	assert.Error(t, err)
+
+	_, err = containerMemoryProtection(config, math.MaxInt64)
+	assert.NoError(t, err)
+}
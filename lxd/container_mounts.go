@@ -0,0 +1,373 @@
+package main
+
+import (
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/gorilla/mux"
+
+	"github.com/lxc/lxd/lxd/types"
+	"github.com/lxc/lxd/shared"
+	"github.com/lxc/lxd/shared/api"
+)
+
+var containerMountsCmd = Command{
+	name: "containers/{name}/mounts",
+	get:  containerMountsGet,
+}
+
+// Paths LXC and LXD mount into every container, along with the mounts below
+// them
+var mountsAuditDefaultPaths = []string{
+	"/dev/.lxd-mounts",
+	"/dev/console",
+	"/dev/full",
+	"/dev/fuse",
+	"/dev/lxd",
+	"/dev/mqueue",
+	"/dev/net/tun",
+	"/dev/null",
+	"/dev/nvidia-mps",
+	"/dev/random",
+	"/dev/tty",
+	"/dev/urandom",
+	"/dev/zero",
+	"/proc",
+	"/sys",
+}
+
+// Filesystems which aren't backed by the storage of the host
+var mountsAuditPseudoFilesystems = []string{
+	"autofs",
+	"binfmt_misc",
+	"bpf",
+	"cgroup",
+	"cgroup2",
+	"configfs",
+	"debugfs",
+	"devpts",
+	"devtmpfs",
+	"efivarfs",
+	"fuse.lxcfs",
+	"fusectl",
+	"hugetlbfs",
+	"mqueue",
+	"nsfs",
+	"overlay",
+	"proc",
+	"pstore",
+	"ramfs",
+	"securityfs",
+	"sysfs",
+	"tmpfs",
+	"tracefs",
+}
+
+// mountinfoUnescape decodes the octal escapes of the spaces, tabs, newlines
+// and backslashes of the mountinfo fields.
+func mountinfoUnescape(value string) string {
+	if !strings.Contains(value, "\\") {
+		return value
+	}
+
+	result := []byte{}
+	for i := 0; i < len(value); i++ {
+		if value[i] == '\\' && i+3 < len(value) {
+			code, err := strconv.ParseUint(value[i+1:i+4], 8, 8)
+			if err == nil {
+				result = append(result, byte(code))
+				i += 3
+				continue
+			}
+		}
+
+		result = append(result, value[i])
+	}
+
+	return string(result)
+}
+
+// mountinfoParse parses the content of a /proc/<pid>/mountinfo file, the
+// mount and superblock options being merged the way /proc/<pid>/mounts does.
+func mountinfoParse(content string) ([]api.ContainerMount, error) {
+	mounts := []api.ContainerMount{}
+	for _, line := range strings.Split(strings.TrimSpace(content), "\n") {
+		if line == "" {
+			continue
+		}
+
+		// Optional fields end with a lone "-"
+		fields := strings.Fields(line)
+		sep := -1
+		for i := 6; i < len(fields); i++ {
+			if fields[i] == "-" {
+				sep = i
+				break
+			}
+		}
+
+		if sep < 0 || len(fields) < sep+4 {
+			return nil, fmt.Errorf("Invalid mountinfo line: %s", line)
+		}
+
+		options := fields[5]
+		if fields[sep+3] != "" && fields[sep+3] != fields[5] {
+			options = fmt.Sprintf("%s,%s", fields[5], fields[sep+3])
+		}
+
+		mounts = append(mounts, api.ContainerMount{
+			Device:     mountinfoUnescape(fields[sep+2]),
+			Path:       mountinfoUnescape(fields[4]),
+			FSType:     fields[sep+1],
+			Options:    options,
+			MajorMinor: fields[2],
+			Root:       mountinfoUnescape(fields[3]),
+		})
+	}
+
+	return mounts, nil
+}
+
+// mountsAuditPathIn returns whether a path is one of the given paths or below
+// one of them.
+func mountsAuditPathIn(path string, paths []string) bool {
+	for _, p := range paths {
+		if path == p || strings.HasPrefix(path, strings.TrimSuffix(p, "/")+"/") {
+			return true
+		}
+	}
+
+	return false
+}
+
+// mountsAuditTmpfsSize returns the size of a tmpfs from its options, half of
+// the host memory being the default.
+func mountsAuditTmpfsSize(options string, hostMemory int64) (int64, error) {
+	for _, option := range strings.Split(options, ",") {
+		if !strings.HasPrefix(option, "size=") {
+			continue
+		}
+
+		value := strings.TrimPrefix(option, "size=")
+		if strings.HasSuffix(value, "%") {
+			percent, err := strconv.ParseInt(strings.TrimSuffix(value, "%"), 10, 64)
+			if err != nil {
+				return -1, err
+			}
+
+			return hostMemory * percent / 100, nil
+		}
+
+		// The kernel shows the size in kilobytes with a "k" suffix
+		multiplier := int64(1)
+		switch value[len(value)-1] {
+		case 'k', 'K':
+			multiplier = 1024
+		case 'm', 'M':
+			multiplier = 1024 * 1024
+		case 'g', 'G':
+			multiplier = 1024 * 1024 * 1024
+		}
+
+		if multiplier > 1 {
+			value = value[:len(value)-1]
+		}
+
+		size, err := strconv.ParseInt(value, 10, 64)
+		if err != nil {
+			return -1, err
+		}
+
+		return size * multiplier, nil
+	}
+
+	return hostMemory / 2, nil
+}
+
+// mountsAuditMemoryLimit returns the memory limit of a container in bytes, -1
+// if unlimited.
+func mountsAuditMemoryLimit(config map[string]string, hostMemory int64) (int64, error) {
+	memory := config["limits.memory"]
+	if memory == "" {
+		return -1, nil
+	}
+
+	if strings.HasSuffix(memory, "%") {
+		percent, err := strconv.ParseInt(strings.TrimSuffix(memory, "%"), 10, 64)
+		if err != nil {
+			return -1, err
+		}
+
+		return hostMemory * percent / 100, nil
+	}
+
+	return shared.ParseByteSizeString(memory)
+}
+
+// containerMountsAudit flags the mounts of a container which are unusual:
+// host filesystems bind-mounted outside of its disk devices, host device
+// nodes which aren't among its devices and tmpfs larger than its memory
+// limit.
+func containerMountsAudit(mounts []api.ContainerMount, devices types.Devices, config map[string]string, hostMounts []api.ContainerMount, hostMemory int64) []api.ContainerMountAnomaly {
+	anomalies := []api.ContainerMountAnomaly{}
+
+	// Mounts of the disk devices and device nodes of the container
+	diskPaths := []string{}
+	nodePaths := []string{}
+	for _, m := range devices {
+		switch m["type"] {
+		case "disk":
+			if m["path"] != "" && m["path"] != "/" {
+				diskPaths = append(diskPaths, m["path"])
+			}
+		case "unix-char", "unix-block":
+			if m["path"] != "" {
+				nodePaths = append(nodePaths, m["path"])
+			} else {
+				nodePaths = append(nodePaths, m["source"])
+			}
+		case "gpu", "usb", "infiniband":
+			// Their device nodes depend on the hardware
+			nodePaths = append(nodePaths, "/dev")
+		}
+	}
+
+	var rootMount api.ContainerMount
+	for _, mount := range mounts {
+		if mount.Path == "/" {
+			rootMount = mount
+		}
+	}
+
+	memoryLimit, err := mountsAuditMemoryLimit(config, hostMemory)
+	if err != nil {
+		memoryLimit = -1
+	}
+
+	for _, mount := range mounts {
+		if mount.Path == "/" {
+			continue
+		}
+
+		switch mount.FSType {
+		case "devtmpfs":
+			if !mountsAuditPathIn(mount.Path, mountsAuditDefaultPaths) && !mountsAuditPathIn(mount.Path, nodePaths) {
+				anomalies = append(anomalies, api.ContainerMountAnomaly{
+					Path:   mount.Path,
+					Reason: fmt.Sprintf("Host device node %s is mounted without a matching device", mount.Root),
+				})
+			}
+		case "tmpfs":
+			if memoryLimit < 0 {
+				continue
+			}
+
+			size, err := mountsAuditTmpfsSize(mount.Options, hostMemory)
+			if err != nil || size <= memoryLimit {
+				continue
+			}
+
+			anomalies = append(anomalies, api.ContainerMountAnomaly{
+				Path:   mount.Path,
+				Reason: fmt.Sprintf("tmpfs size of %s exceeds the memory limit of %s", shared.GetByteSizeString(size, 0), shared.GetByteSizeString(memoryLimit, 0)),
+			})
+		case "ramfs":
+			anomalies = append(anomalies, api.ContainerMountAnomaly{
+				Path:   mount.Path,
+				Reason: "ramfs has no size limit",
+			})
+		}
+
+		if shared.StringInSlice(mount.FSType, mountsAuditPseudoFilesystems) {
+			continue
+		}
+
+		// Parts of the container's own root filesystem
+		if mount.MajorMinor == rootMount.MajorMinor && mountsAuditPathIn(mount.Root, []string{rootMount.Root}) {
+			continue
+		}
+
+		if mountsAuditPathIn(mount.Path, mountsAuditDefaultPaths) || mountsAuditPathIn(mount.Path, diskPaths) {
+			continue
+		}
+
+		for _, hostMount := range hostMounts {
+			if hostMount.MajorMinor != mount.MajorMinor {
+				continue
+			}
+
+			relPath, err := filepath.Rel(hostMount.Root, mount.Root)
+			if err != nil || strings.HasPrefix(relPath, "..") {
+				continue
+			}
+
+			anomalies = append(anomalies, api.ContainerMountAnomaly{
+				Path:   mount.Path,
+				Reason: fmt.Sprintf("Host path %s is mounted outside of the disk devices", filepath.Join(hostMount.Path, relPath)),
+			})
+			break
+		}
+	}
+
+	return anomalies
+}
+
+func containerMountsGet(d *Daemon, r *http.Request) Response {
+	project := projectParam(r)
+	name := mux.Vars(r)["name"]
+
+	// Handle requests targeted to a container on a different node
+	response, err := ForwardedResponseIfContainerIsRemote(d, r, project, name)
+	if err != nil {
+		return SmartError(err)
+	}
+	if response != nil {
+		return response
+	}
+
+	c, err := containerLoadByProjectAndName(d.State(), project, name)
+	if err != nil {
+		return SmartError(err)
+	}
+
+	if !c.IsRunning() {
+		return BadRequest(withErrorCode(api.ErrContainerNotRunning, fmt.Errorf("Container is not running")))
+	}
+
+	// The mount points are relative to the root of the container's init
+	content, err := ioutil.ReadFile(fmt.Sprintf("/proc/%d/mountinfo", c.InitPID()))
+	if err != nil {
+		return InternalError(err)
+	}
+
+	mounts, err := mountinfoParse(string(content))
+	if err != nil {
+		return InternalError(err)
+	}
+
+	content, err = ioutil.ReadFile("/proc/self/mountinfo")
+	if err != nil {
+		return InternalError(err)
+	}
+
+	hostMounts, err := mountinfoParse(string(content))
+	if err != nil {
+		return InternalError(err)
+	}
+
+	hostMemory, err := shared.DeviceTotalMemory()
+	if err != nil {
+		return InternalError(err)
+	}
+
+	result := api.ContainerMounts{
+		Mounts:    mounts,
+		Anomalies: containerMountsAudit(mounts, c.ExpandedDevices(), c.ExpandedConfig(), hostMounts, hostMemory),
+	}
+
+	return SyncResponse(true, result)
+}
@@ -0,0 +1,90 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/lxc/lxd/lxd/types"
+	"github.com/lxc/lxd/shared/api"
+)
+
+func TestMountinfoParse(t *testing.T) {
+	content := `1203 1156 8:1 /var/lib/lxd/storage-pools/default/containers/c1/rootfs / rw,relatime shared:612 master:1 - ext4 /dev/sda1 rw
+1204 1203 0:52 / /dev rw,relatime - tmpfs none rw,size=492k,mode=755,uid=1000000,gid=1000000
+1205 1203 8:1 /srv/my\040data /mnt/data rw,relatime - ext4 /dev/sda1 rw
+`
+
+	mounts, err := mountinfoParse(content)
+	require.NoError(t, err)
+	require.Len(t, mounts, 3)
+
+	assert.Equal(t, api.ContainerMount{
+		Device:     "/dev/sda1",
+		Path:       "/",
+		FSType:     "ext4",
+		Options:    "rw,relatime,rw",
+		MajorMinor: "8:1",
+		Root:       "/var/lib/lxd/storage-pools/default/containers/c1/rootfs",
+	}, mounts[0])
+	assert.Equal(t, "rw,relatime,rw,size=492k,mode=755,uid=1000000,gid=1000000", mounts[1].Options)
+	assert.Equal(t, "/srv/my data", mounts[2].Root)
+
+	_, err = mountinfoParse("1203 1156 8:1 / / rw\n")
+	assert.Error(t, err)
+}
+
+func TestContainerMountsAudit(t *testing.T) {
+	hostMounts := []api.ContainerMount{
+		{Path: "/", MajorMinor: "8:1", Root: "/", FSType: "ext4"},
+		{Path: "/home", MajorMinor: "8:2", Root: "/", FSType: "xfs"},
+	}
+
+	mounts := []api.ContainerMount{
+		{Path: "/", MajorMinor: "8:1", Root: "/var/lib/lxd/storage-pools/default/containers/c1/rootfs", FSType: "ext4"},
+		{Path: "/var/cache", MajorMinor: "8:1", Root: "/var/lib/lxd/storage-pools/default/containers/c1/rootfs/srv/cache", FSType: "ext4"},
+		{Path: "/dev/null", MajorMinor: "0:6", Root: "/null", FSType: "devtmpfs"},
+		{Path: "/dev/kvm", MajorMinor: "0:6", Root: "/kvm", FSType: "devtmpfs"},
+		{Path: "/dev/ttyUSB0", MajorMinor: "0:6", Root: "/ttyUSB0", FSType: "devtmpfs"},
+		{Path: "/mnt/shared", MajorMinor: "8:2", Root: "/shared", FSType: "xfs"},
+		{Path: "/mnt/etc", MajorMinor: "8:1", Root: "/etc", FSType: "ext4"},
+		{Path: "/run", MajorMinor: "0:60", Root: "/", FSType: "tmpfs", Options: "rw,nosuid,size=102400k"},
+		{Path: "/dev/shm", MajorMinor: "0:61", Root: "/", FSType: "tmpfs", Options: "rw,nosuid"},
+		{Path: "/scratch", MajorMinor: "0:62", Root: "/", FSType: "ramfs", Options: "rw"},
+	}
+
+	devices := types.Devices{
+		"shared": {"type": "disk", "source": "/home/shared", "path": "/mnt/shared"},
+		"serial": {"type": "unix-char", "source": "/dev/ttyUSB0"},
+	}
+
+	config := map[string]string{"limits.memory": "512MB"}
+	hostMemory := int64(8 * 1024 * 1024 * 1024)
+
+	anomalies := containerMountsAudit(mounts, devices, config, hostMounts, hostMemory)
+	assert.Equal(t, []api.ContainerMountAnomaly{
+		{Path: "/dev/kvm", Reason: "Host device node /kvm is mounted without a matching device"},
+		{Path: "/mnt/etc", Reason: "Host path /etc is mounted outside of the disk devices"},
+		{Path: "/dev/shm", Reason: "tmpfs size of 4GB exceeds the memory limit of 512MB"},
+		{Path: "/scratch", Reason: "ramfs has no size limit"},
+	}, anomalies)
+
+	// Without a memory limit, tmpfs aren't flagged
+	anomalies = containerMountsAudit(mounts, devices, map[string]string{}, hostMounts, hostMemory)
+	assert.Len(t, anomalies, 3)
+}
+
+func TestMountsAuditTmpfsSize(t *testing.T) {
+	size, err := mountsAuditTmpfsSize("rw,size=1024k,mode=755", 4096)
+	require.NoError(t, err)
+	assert.Equal(t, int64(1024*1024), size)
+
+	size, err = mountsAuditTmpfsSize("rw,size=25%", 4096)
+	require.NoError(t, err)
+	assert.Equal(t, int64(1024), size)
+
+	size, err = mountsAuditTmpfsSize("rw", 4096)
+	require.NoError(t, err)
+	assert.Equal(t, int64(2048), size)
+}
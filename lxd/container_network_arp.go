@@ -0,0 +1,203 @@
+package main
+
+import (
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/gorilla/mux"
+	"github.com/vishvananda/netlink"
+
+	"github.com/lxc/lxd/shared"
+	"github.com/lxc/lxd/shared/api"
+	"github.com/lxc/lxd/shared/version"
+)
+
+var containerNetworkARPCmd = Command{
+	name: "containers/{name}/network/arp",
+	get:  containerNetworkARPGet,
+	post: containerNetworkARPPost,
+}
+
+var containerNetworkARPEntryCmd = Command{
+	name:   "containers/{name}/network/arp/{ip}",
+	delete: containerNetworkARPEntryDelete,
+}
+
+// arpStates maps the neighbour states to their names.
+var arpStates = map[int]string{
+	netlink.NUD_NONE:       "none",
+	netlink.NUD_INCOMPLETE: "incomplete",
+	netlink.NUD_REACHABLE:  "reachable",
+	netlink.NUD_STALE:      "stale",
+	netlink.NUD_DELAY:      "delay",
+	netlink.NUD_PROBE:      "probe",
+	netlink.NUD_FAILED:     "failed",
+	netlink.NUD_NOARP:      "noarp",
+	netlink.NUD_PERMANENT:  "permanent",
+}
+
+// arpEntryFromNetlink converts a neighbour returned by netlink, given the
+// names of the interfaces of the container indexed by their index.
+func arpEntryFromNetlink(neigh netlink.Neigh, links map[int]string) api.ContainerNetworkARPEntry {
+	entry := api.ContainerNetworkARPEntry{
+		IP:        neigh.IP.String(),
+		Interface: links[neigh.LinkIndex],
+		State:     arpStates[neigh.State],
+	}
+
+	if len(neigh.HardwareAddr) > 0 {
+		entry.MAC = neigh.HardwareAddr.String()
+	}
+
+	if entry.State == "" {
+		entry.State = fmt.Sprintf("0x%02x", neigh.State)
+	}
+
+	return entry
+}
+
+// arpEntryToNetlink validates a new entry and converts it to a permanent
+// netlink neighbour, given the index of its interface.
+func arpEntryToNetlink(req api.ContainerNetworkARPEntriesPost, linkIndex int) (*netlink.Neigh, error) {
+	ip := net.ParseIP(req.IP)
+	if ip == nil {
+		return nil, fmt.Errorf("Invalid IP address '%s'", req.IP)
+	}
+
+	mac, err := net.ParseMAC(req.MAC)
+	if err != nil {
+		return nil, fmt.Errorf("Invalid MAC address '%s'", req.MAC)
+	}
+
+	if linkIndex == 0 {
+		return nil, fmt.Errorf("An interface is required")
+	}
+
+	neigh := &netlink.Neigh{
+		LinkIndex:    linkIndex,
+		Family:       netlink.FAMILY_V6,
+		State:        netlink.NUD_PERMANENT,
+		IP:           ip,
+		HardwareAddr: mac,
+	}
+
+	if ip.To4() != nil {
+		neigh.Family = netlink.FAMILY_V4
+	}
+
+	return neigh, nil
+}
+
+func containerNetworkARPGet(d *Daemon, r *http.Request) Response {
+	h, resp := containerNetlinkHandle(d, r)
+	if resp != nil {
+		return resp
+	}
+	defer h.Delete()
+
+	links, err := networkLinkNames(h)
+	if err != nil {
+		return InternalError(err)
+	}
+
+	neighs, err := h.NeighList(0, netlink.FAMILY_ALL)
+	if err != nil {
+		return InternalError(err)
+	}
+
+	entries := []api.ContainerNetworkARPEntry{}
+	for _, neigh := range neighs {
+		entries = append(entries, arpEntryFromNetlink(neigh, links))
+	}
+
+	return SyncResponse(true, entries)
+}
+
+func containerNetworkARPPost(d *Daemon, r *http.Request) Response {
+	h, resp := containerNetlinkHandle(d, r)
+	if resp != nil {
+		return resp
+	}
+	defer h.Delete()
+
+	req := api.ContainerNetworkARPEntriesPost{}
+	err := shared.ReadToJSON(r.Body, &req)
+	if err != nil {
+		return BadRequest(err)
+	}
+
+	link, err := h.LinkByName(req.Interface)
+	if err != nil {
+		return BadRequest(fmt.Errorf("Unknown interface '%s'", req.Interface))
+	}
+
+	neigh, err := arpEntryToNetlink(req, link.Attrs().Index)
+	if err != nil {
+		return BadRequest(err)
+	}
+
+	// Replace the dynamic entry of the address if any
+	err = h.NeighSet(neigh)
+	if err != nil {
+		return BadRequest(fmt.Errorf("Failed to add the entry: %v", err))
+	}
+
+	entryURL := fmt.Sprintf("/%s/containers/%s/network/arp/%s?interface=%s", version.APIVersion, mux.Vars(r)["name"], url.PathEscape(neigh.IP.String()), url.QueryEscape(req.Interface))
+	return SyncResponseLocation(true, nil, entryURL)
+}
+
+func containerNetworkARPEntryDelete(d *Daemon, r *http.Request) Response {
+	ip := net.ParseIP(mux.Vars(r)["ip"])
+	if ip == nil {
+		return BadRequest(fmt.Errorf("Invalid IP address '%s'", mux.Vars(r)["ip"]))
+	}
+
+	h, resp := containerNetlinkHandle(d, r)
+	if resp != nil {
+		return resp
+	}
+	defer h.Delete()
+
+	links, err := networkLinkNames(h)
+	if err != nil {
+		return InternalError(err)
+	}
+
+	neighs, err := h.NeighList(0, netlink.FAMILY_ALL)
+	if err != nil {
+		return InternalError(err)
+	}
+
+	iface := r.FormValue("interface")
+	matches := []netlink.Neigh{}
+	for _, neigh := range neighs {
+		if !neigh.IP.Equal(ip) || (iface != "" && links[neigh.LinkIndex] != iface) {
+			continue
+		}
+
+		matches = append(matches, neigh)
+	}
+
+	if len(matches) == 0 {
+		return NotFound(fmt.Errorf("No entry for '%s' in the container", ip))
+	}
+
+	if len(matches) > 1 {
+		names := []string{}
+		for _, neigh := range matches {
+			names = append(names, links[neigh.LinkIndex])
+		}
+
+		return BadRequest(fmt.Errorf("Entries for '%s' exist on several interfaces (%s), pass the interface argument", ip, strings.Join(names, ", ")))
+	}
+
+	err = h.NeighDel(&matches[0])
+	if err != nil {
+		return InternalError(err)
+	}
+
+	return EmptySyncResponse
+}
@@ -0,0 +1,50 @@
+package main
+
+import (
+	"net"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/vishvananda/netlink"
+
+	"github.com/lxc/lxd/shared/api"
+)
+
+func TestArpEntryFromNetlink(t *testing.T) {
+	mac, err := net.ParseMAC("aa:bb:cc:dd:ee:ff")
+	require.NoError(t, err)
+
+	links := map[int]string{2: "eth0"}
+
+	entry := arpEntryFromNetlink(netlink.Neigh{LinkIndex: 2, State: netlink.NUD_PERMANENT, IP: net.ParseIP("10.0.0.1"), HardwareAddr: mac}, links)
+	assert.Equal(t, api.ContainerNetworkARPEntry{IP: "10.0.0.1", MAC: "aa:bb:cc:dd:ee:ff", Interface: "eth0", State: "permanent"}, entry)
+
+	entry = arpEntryFromNetlink(netlink.Neigh{LinkIndex: 2, State: netlink.NUD_INCOMPLETE, IP: net.ParseIP("fd00::1")}, links)
+	assert.Equal(t, api.ContainerNetworkARPEntry{IP: "fd00::1", Interface: "eth0", State: "incomplete"}, entry)
+
+	entry = arpEntryFromNetlink(netlink.Neigh{LinkIndex: 2, State: netlink.NUD_REACHABLE | netlink.NUD_NOARP, IP: net.ParseIP("10.0.0.2")}, links)
+	assert.Equal(t, "0x42", entry.State)
+}
+
+func TestArpEntryToNetlink(t *testing.T) {
+	neigh, err := arpEntryToNetlink(api.ContainerNetworkARPEntriesPost{IP: "10.0.0.1", MAC: "aa:bb:cc:dd:ee:ff", Interface: "eth0"}, 2)
+	require.NoError(t, err)
+	assert.Equal(t, netlink.FAMILY_V4, neigh.Family)
+	assert.Equal(t, netlink.NUD_PERMANENT, neigh.State)
+	assert.Equal(t, 2, neigh.LinkIndex)
+	assert.Equal(t, "aa:bb:cc:dd:ee:ff", neigh.HardwareAddr.String())
+
+	neigh, err = arpEntryToNetlink(api.ContainerNetworkARPEntriesPost{IP: "fd00::1", MAC: "aa:bb:cc:dd:ee:ff", Interface: "eth0"}, 2)
+	require.NoError(t, err)
+	assert.Equal(t, netlink.FAMILY_V6, neigh.Family)
+
+	_, err = arpEntryToNetlink(api.ContainerNetworkARPEntriesPost{IP: "10.0.0", MAC: "aa:bb:cc:dd:ee:ff"}, 2)
+	assert.Error(t, err)
+
+	_, err = arpEntryToNetlink(api.ContainerNetworkARPEntriesPost{IP: "10.0.0.1", MAC: "aa:bb:cc"}, 2)
+	assert.Error(t, err)
+
+	_, err = arpEntryToNetlink(api.ContainerNetworkARPEntriesPost{IP: "10.0.0.1", MAC: "aa:bb:cc:dd:ee:ff"}, 0)
+	assert.Error(t, err)
+}
@@ -0,0 +1,340 @@
+package main
+
+import (
+	"fmt"
+	"hash/fnv"
+	"net"
+	"net/http"
+	"strconv"
+	"syscall"
+
+	"github.com/gorilla/mux"
+	"github.com/vishvananda/netlink"
+	"github.com/vishvananda/netns"
+
+	"github.com/lxc/lxd/shared"
+	"github.com/lxc/lxd/shared/api"
+	"github.com/lxc/lxd/shared/version"
+)
+
+var containerNetworkRoutesCmd = Command{
+	name: "containers/{name}/network/routes",
+	get:  containerNetworkRoutesGet,
+	post: containerNetworkRoutesPost,
+}
+
+var containerNetworkRouteCmd = Command{
+	name:   "containers/{name}/network/routes/{id}",
+	get:    containerNetworkRouteGet,
+	delete: containerNetworkRouteDelete,
+}
+
+// networkRouteProtocols maps the common route protocols to their names.
+var networkRouteProtocols = map[int]string{
+	syscall.RTPROT_REDIRECT: "redirect",
+	syscall.RTPROT_KERNEL:   "kernel",
+	syscall.RTPROT_BOOT:     "boot",
+	syscall.RTPROT_STATIC:   "static",
+	syscall.RTPROT_RA:       "ra",
+	syscall.RTPROT_DHCP:     "dhcp",
+}
+
+// networkRouteScopes maps the route scopes to their names.
+var networkRouteScopes = map[netlink.Scope]string{
+	netlink.SCOPE_UNIVERSE: "universe",
+	netlink.SCOPE_SITE:     "site",
+	netlink.SCOPE_LINK:     "link",
+	netlink.SCOPE_HOST:     "host",
+	netlink.SCOPE_NOWHERE:  "nowhere",
+}
+
+// networkRouteID returns the ID of a route, derived from the fields
+// identifying it in the routing table since routes have no ID of their own.
+func networkRouteID(route api.ContainerNetworkRoute) string {
+	h := fnv.New64a()
+	fmt.Fprintf(h, "%s|%s|%s|%d", route.Destination, route.Gateway, route.Interface, route.Metric)
+	return fmt.Sprintf("%016x", h.Sum64())
+}
+
+// networkRouteFromNetlink converts a route returned by netlink, given the
+// names of the interfaces of the container indexed by their index.
+func networkRouteFromNetlink(route netlink.Route, links map[int]string) api.ContainerNetworkRoute {
+	result := api.ContainerNetworkRoute{
+		Destination: "default",
+		Interface:   links[route.LinkIndex],
+		Metric:      route.Priority,
+		Protocol:    networkRouteProtocols[int(route.Protocol)],
+		Scope:       networkRouteScopes[route.Scope],
+	}
+
+	if route.Dst != nil {
+		result.Destination = route.Dst.String()
+	}
+
+	if route.Gw != nil {
+		result.Gateway = route.Gw.String()
+	}
+
+	if result.Protocol == "" {
+		result.Protocol = strconv.Itoa(int(route.Protocol))
+	}
+
+	result.ID = networkRouteID(result)
+	return result
+}
+
+// networkRouteToNetlink validates a new route and converts it to a netlink
+// route, given the index of its interface (0 if none).
+func networkRouteToNetlink(req api.ContainerNetworkRoutesPost, linkIndex int) (*netlink.Route, error) {
+	route := &netlink.Route{
+		LinkIndex: linkIndex,
+		Priority:  req.Metric,
+		Protocol:  syscall.RTPROT_STATIC,
+		Scope:     netlink.SCOPE_UNIVERSE,
+	}
+
+	if req.Metric < 0 {
+		return nil, fmt.Errorf("Invalid metric: %d", req.Metric)
+	}
+
+	if req.Destination != "default" {
+		_, dst, err := net.ParseCIDR(req.Destination)
+		if err != nil {
+			return nil, fmt.Errorf("Invalid destination '%s': %v", req.Destination, err)
+		}
+
+		route.Dst = dst
+	}
+
+	if req.Gateway != "" {
+		route.Gw = net.ParseIP(req.Gateway)
+		if route.Gw == nil {
+			return nil, fmt.Errorf("Invalid gateway '%s'", req.Gateway)
+		}
+
+		if route.Dst != nil && (route.Dst.IP.To4() == nil) != (route.Gw.To4() == nil) {
+			return nil, fmt.Errorf("The destination and gateway must be of the same address family")
+		}
+	} else {
+		if linkIndex == 0 {
+			return nil, fmt.Errorf("A gateway or an interface is required")
+		}
+
+		if route.Dst == nil {
+			return nil, fmt.Errorf("A default route requires a gateway")
+		}
+
+		// Directly reachable destination
+		route.Scope = netlink.SCOPE_LINK
+	}
+
+	return route, nil
+}
+
+// networkNetlinkHandle returns a netlink handle operating in the network
+// namespace of a container.
+func networkNetlinkHandle(c container) (*netlink.Handle, error) {
+	ns, err := netns.GetFromPid(c.InitPID())
+	if err != nil {
+		return nil, err
+	}
+	defer ns.Close()
+
+	return netlink.NewHandleAt(ns)
+}
+
+// networkLinkNames returns the names of the interfaces of a container,
+// indexed by their index.
+func networkLinkNames(h *netlink.Handle) (map[int]string, error) {
+	links, err := h.LinkList()
+	if err != nil {
+		return nil, err
+	}
+
+	names := map[int]string{}
+	for _, link := range links {
+		names[link.Attrs().Index] = link.Attrs().Name
+	}
+
+	return names, nil
+}
+
+// networkRoutesList returns the routes of the main routing table of a
+// container, along with their netlink counterpart.
+func networkRoutesList(h *netlink.Handle) ([]api.ContainerNetworkRoute, []netlink.Route, error) {
+	names, err := networkLinkNames(h)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	routes, err := h.RouteList(nil, netlink.FAMILY_ALL)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	result := []api.ContainerNetworkRoute{}
+	for _, route := range routes {
+		result = append(result, networkRouteFromNetlink(route, names))
+	}
+
+	return result, routes, nil
+}
+
+// containerNetlinkHandle returns a netlink handle for the network namespace
+// of the container targeted by the request.
+func containerNetlinkHandle(d *Daemon, r *http.Request) (*netlink.Handle, Response) {
+	project := projectParam(r)
+	name := mux.Vars(r)["name"]
+
+	// Handle requests targeted to a container on a different node
+	response, err := ForwardedResponseIfContainerIsRemote(d, r, project, name)
+	if err != nil {
+		return nil, SmartError(err)
+	}
+	if response != nil {
+		return nil, response
+	}
+
+	c, err := containerLoadByProjectAndName(d.State(), project, name)
+	if err != nil {
+		return nil, SmartError(err)
+	}
+
+	if !c.IsRunning() {
+		return nil, BadRequest(withErrorCode(api.ErrContainerNotRunning, fmt.Errorf("Container is not running")))
+	}
+
+	h, err := networkNetlinkHandle(c)
+	if err != nil {
+		return nil, InternalError(err)
+	}
+
+	return h, nil
+}
+
+func containerNetworkRoutesGet(d *Daemon, r *http.Request) Response {
+	h, resp := containerNetlinkHandle(d, r)
+	if resp != nil {
+		return resp
+	}
+	defer h.Delete()
+
+	routes, _, err := networkRoutesList(h)
+	if err != nil {
+		return InternalError(err)
+	}
+
+	return SyncResponse(true, routes)
+}
+
+func containerNetworkRoutesPost(d *Daemon, r *http.Request) Response {
+	// The request body is only read once forwarding was ruled out
+	h, resp := containerNetlinkHandle(d, r)
+	if resp != nil {
+		return resp
+	}
+	defer h.Delete()
+
+	req := api.ContainerNetworkRoutesPost{}
+	err := shared.ReadToJSON(r.Body, &req)
+	if err != nil {
+		return BadRequest(err)
+	}
+
+	linkIndex := 0
+	if req.Interface != "" {
+		link, err := h.LinkByName(req.Interface)
+		if err != nil {
+			return BadRequest(fmt.Errorf("Unknown interface '%s'", req.Interface))
+		}
+
+		linkIndex = link.Attrs().Index
+	}
+
+	route, err := networkRouteToNetlink(req, linkIndex)
+	if err != nil {
+		return BadRequest(err)
+	}
+
+	err = h.RouteAdd(route)
+	if err != nil {
+		if err == syscall.EEXIST {
+			return Conflict(fmt.Errorf("The route already exists"))
+		}
+
+		return BadRequest(fmt.Errorf("Failed to add the route: %v", err))
+	}
+
+	// Look the route up as the kernel stored it to get its ID
+	routes, netlinkRoutes, err := networkRoutesList(h)
+	if err != nil {
+		return InternalError(err)
+	}
+
+	for i, existing := range netlinkRoutes {
+		// The kernel picks a metric for IPv6 routes added without one
+		if route.Priority != 0 && existing.Priority != route.Priority {
+			continue
+		}
+
+		if existing.Dst.String() == route.Dst.String() && existing.Gw.Equal(route.Gw) {
+			url := fmt.Sprintf("/%s/containers/%s/network/routes/%s", version.APIVersion, mux.Vars(r)["name"], routes[i].ID)
+			return SyncResponseLocation(true, nil, url)
+		}
+	}
+
+	return EmptySyncResponse
+}
+
+// containerNetworkRouteFind returns the route with the ID of the request.
+func containerNetworkRouteFind(h *netlink.Handle, r *http.Request) (*api.ContainerNetworkRoute, *netlink.Route, Response) {
+	id := mux.Vars(r)["id"]
+
+	routes, netlinkRoutes, err := networkRoutesList(h)
+	if err != nil {
+		return nil, nil, InternalError(err)
+	}
+
+	for i, route := range routes {
+		if route.ID == id {
+			return &route, &netlinkRoutes[i], nil
+		}
+	}
+
+	return nil, nil, NotFound(fmt.Errorf("No route '%s' in the container", id))
+}
+
+func containerNetworkRouteGet(d *Daemon, r *http.Request) Response {
+	h, resp := containerNetlinkHandle(d, r)
+	if resp != nil {
+		return resp
+	}
+	defer h.Delete()
+
+	route, _, resp := containerNetworkRouteFind(h, r)
+	if resp != nil {
+		return resp
+	}
+
+	return SyncResponse(true, route)
+}
+
+func containerNetworkRouteDelete(d *Daemon, r *http.Request) Response {
+	h, resp := containerNetlinkHandle(d, r)
+	if resp != nil {
+		return resp
+	}
+	defer h.Delete()
+
+	_, route, resp := containerNetworkRouteFind(h, r)
+	if resp != nil {
+		return resp
+	}
+
+	err := h.RouteDel(route)
+	if err != nil {
+		return InternalError(err)
+	}
+
+	return EmptySyncResponse
+}
@@ -0,0 +1,79 @@
+package main
+
+import (
+	"net"
+	"syscall"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/vishvananda/netlink"
+
+	"github.com/lxc/lxd/shared/api"
+)
+
+func TestNetworkRouteFromNetlink(t *testing.T) {
+	_, dst, err := net.ParseCIDR("10.0.0.0/24")
+	require.NoError(t, err)
+
+	links := map[int]string{2: "eth0"}
+
+	route := networkRouteFromNetlink(netlink.Route{
+		LinkIndex: 2,
+		Dst:       dst,
+		Gw:        net.ParseIP("192.168.1.1"),
+		Priority:  100,
+		Protocol:  syscall.RTPROT_STATIC,
+	}, links)
+	assert.Equal(t, "10.0.0.0/24", route.Destination)
+	assert.Equal(t, "192.168.1.1", route.Gateway)
+	assert.Equal(t, "eth0", route.Interface)
+	assert.Equal(t, 100, route.Metric)
+	assert.Equal(t, "static", route.Protocol)
+	assert.Equal(t, "universe", route.Scope)
+
+	defaultRoute := networkRouteFromNetlink(netlink.Route{LinkIndex: 2, Gw: net.ParseIP("192.168.1.1"), Protocol: 42}, links)
+	assert.Equal(t, "default", defaultRoute.Destination)
+	assert.Equal(t, "42", defaultRoute.Protocol)
+
+	// IDs are stable and differ between routes
+	assert.Equal(t, route.ID, networkRouteID(route))
+	assert.NotEqual(t, route.ID, defaultRoute.ID)
+	assert.Len(t, route.ID, 16)
+}
+
+func TestNetworkRouteToNetlink(t *testing.T) {
+	route, err := networkRouteToNetlink(api.ContainerNetworkRoutesPost{Destination: "10.0.0.0/24", Gateway: "192.168.1.1", Metric: 100}, 2)
+	require.NoError(t, err)
+	assert.Equal(t, "10.0.0.0/24", route.Dst.String())
+	assert.Equal(t, "192.168.1.1", route.Gw.String())
+	assert.Equal(t, 2, route.LinkIndex)
+	assert.Equal(t, 100, route.Priority)
+	assert.Equal(t, netlink.SCOPE_UNIVERSE, route.Scope)
+
+	route, err = networkRouteToNetlink(api.ContainerNetworkRoutesPost{Destination: "fd00::/64"}, 2)
+	require.NoError(t, err)
+	assert.Nil(t, route.Gw)
+	assert.Equal(t, netlink.SCOPE_LINK, route.Scope)
+
+	route, err = networkRouteToNetlink(api.ContainerNetworkRoutesPost{Destination: "default", Gateway: "192.168.1.1"}, 0)
+	require.NoError(t, err)
+	assert.Nil(t, route.Dst)
+
+	for _, req := range []api.ContainerNetworkRoutesPost{
+		{Destination: "10.0.0.0", Gateway: "192.168.1.1"},
+		{Destination: "10.0.0.0/24", Gateway: "fd00::1"},
+		{Destination: "10.0.0.0/24", Gateway: "bogus"},
+		{Destination: "10.0.0.0/24"},
+		{Destination: "default", Interface: "eth0"},
+		{Destination: "10.0.0.0/24", Gateway: "192.168.1.1", Metric: -1},
+	} {
+		linkIndex := 0
+		if req.Interface != "" {
+			linkIndex = 2
+		}
+
+		_, err := networkRouteToNetlink(req, linkIndex)
+		assert.Error(t, err, req)
+	}
+}
@@ -0,0 +1,150 @@
+package main
+
+import (
+	"fmt"
+	"runtime"
+	"strconv"
+	"syscall"
+	"unsafe"
+)
+
+// Ethtool ioctl and commands from linux/sockios.h and linux/ethtool.h
+const (
+	siocEthtool    = 0x8946
+	siocSIfTxQLen  = 0x8943
+	ethtoolGetChan = 0x0000003c
+	ethtoolSetChan = 0x0000003d
+)
+
+// Transmit queue length given to a nic for each of its queues, matching the
+// kernel default for a single queue.
+const nicTxQueueLenPerQueue = 1000
+
+// ethtoolChannels mirrors struct ethtool_channels.
+type ethtoolChannels struct {
+	cmd           uint32
+	maxRx         uint32
+	maxTx         uint32
+	maxOther      uint32
+	maxCombined   uint32
+	rxCount       uint32
+	txCount       uint32
+	otherCount    uint32
+	combinedCount uint32
+}
+
+// nicQueuesCount returns the number of queues to configure for a nic, given
+// the value of its queues key and the container configuration. A value of 0
+// means one queue per CPU available to the container.
+func nicQueuesCount(config map[string]string, value string) (int, error) {
+	if value == "" {
+		return 1, nil
+	}
+
+	queues, err := strconv.ParseUint(value, 10, 32)
+	if err != nil {
+		return -1, fmt.Errorf("Invalid number of queues: %s", value)
+	}
+
+	if queues > 0 {
+		return int(queues), nil
+	}
+
+	cpus := runtime.NumCPU()
+	if config["limits.cpu"] == "" {
+		return cpus, nil
+	}
+
+	count, err := strconv.Atoi(config["limits.cpu"])
+	if err == nil {
+		if count > cpus {
+			return cpus, nil
+		}
+
+		return count, nil
+	}
+
+	set, err := parseCpuset(config["limits.cpu"])
+	if err != nil {
+		return -1, err
+	}
+
+	return len(set), nil
+}
+
+func nicIoctl(fd int, request uintptr, arg unsafe.Pointer) error {
+	_, _, errno := syscall.Syscall(syscall.SYS_IOCTL, uintptr(fd), request, uintptr(arg))
+	if errno != 0 {
+		return errno
+	}
+
+	return nil
+}
+
+// networkSetQueues configures the number of receive and transmit queues of
+// an interface in the current network namespace, along with its transmit
+// queue length.
+//
+// veth devices don't support TUNSETQUEUE, their queues are instead set
+// through the ethtool channels (same as "ethtool -L"), which requires Linux
+// 5.14 or higher.
+func networkSetQueues(name string, queues int) error {
+	if len(name) >= syscall.IFNAMSIZ {
+		return fmt.Errorf("Interface name too long: %s", name)
+	}
+
+	fd, err := syscall.Socket(syscall.AF_INET, syscall.SOCK_DGRAM, 0)
+	if err != nil {
+		return err
+	}
+	defer syscall.Close(fd)
+
+	channels := ethtoolChannels{cmd: ethtoolGetChan}
+	var req struct {
+		name [syscall.IFNAMSIZ]byte
+		data uintptr
+		_    [16]byte
+	}
+
+	copy(req.name[:], name)
+	req.data = uintptr(unsafe.Pointer(&channels))
+
+	err = nicIoctl(fd, siocEthtool, unsafe.Pointer(&req))
+	if err != nil {
+		return fmt.Errorf("Failed to get the channels of %s: %v", name, err)
+	}
+
+	maxQueues := channels.maxRx
+	if channels.maxTx < maxQueues {
+		maxQueues = channels.maxTx
+	}
+
+	if uint32(queues) > maxQueues {
+		return fmt.Errorf("Interface %s supports at most %d queues", name, maxQueues)
+	}
+
+	channels.cmd = ethtoolSetChan
+	channels.rxCount = uint32(queues)
+	channels.txCount = uint32(queues)
+
+	err = nicIoctl(fd, siocEthtool, unsafe.Pointer(&req))
+	if err != nil {
+		return fmt.Errorf("Failed to set the channels of %s: %v", name, err)
+	}
+
+	var qlen struct {
+		name [syscall.IFNAMSIZ]byte
+		qlen int32
+		_    [20]byte
+	}
+
+	copy(qlen.name[:], name)
+	qlen.qlen = int32(queues * nicTxQueueLenPerQueue)
+
+	err = nicIoctl(fd, siocSIfTxQLen, unsafe.Pointer(&qlen))
+	if err != nil {
+		return fmt.Errorf("Failed to set the transmit queue length of %s: %v", name, err)
+	}
+
+	return nil
+}
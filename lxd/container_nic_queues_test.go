@@ -0,0 +1,39 @@
+package main
+
+import (
+	"runtime"
+	"testing"
+	"unsafe"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNicQueuesCount(t *testing.T) {
+	queues, err := nicQueuesCount(map[string]string{}, "")
+	require.NoError(t, err)
+	assert.Equal(t, 1, queues)
+
+	queues, err = nicQueuesCount(map[string]string{}, "4")
+	require.NoError(t, err)
+	assert.Equal(t, 4, queues)
+
+	queues, err = nicQueuesCount(map[string]string{}, "0")
+	require.NoError(t, err)
+	assert.Equal(t, runtime.NumCPU(), queues)
+
+	queues, err = nicQueuesCount(map[string]string{"limits.cpu": "0-1,4"}, "0")
+	require.NoError(t, err)
+	assert.Equal(t, 3, queues)
+
+	queues, err = nicQueuesCount(map[string]string{"limits.cpu": "1"}, "0")
+	require.NoError(t, err)
+	assert.Equal(t, 1, queues)
+
+	_, err = nicQueuesCount(map[string]string{}, "-1")
+	assert.Error(t, err)
+}
+
+func TestEthtoolChannelsSize(t *testing.T) {
+	assert.Equal(t, uintptr(36), unsafe.Sizeof(ethtoolChannels{}))
+}
@@ -0,0 +1,242 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+
+	"github.com/lxc/lxd/lxd/cluster"
+	"github.com/lxc/lxd/lxd/state"
+	"github.com/lxc/lxd/lxd/task"
+	"github.com/lxc/lxd/shared/logger"
+
+	log "github.com/lxc/lxd/shared/log15"
+)
+
+// How often the OOM killer scores of the containers are checked
+const oomScoreCheckInterval = 30 * time.Second
+
+// Minimum change of the OOM killer score of a container reported as an event,
+// the score being in thousandths of the memory available to the container
+const oomScoreEventThreshold = 10
+
+// containerOOMScoreAdj returns the OOM killer score adjustment of a container,
+// falling back to the server's default.
+func containerOOMScoreAdj(config map[string]string, defaultValue int64) (int64, error) {
+	if config["oom_score_adj"] == "" {
+		return defaultValue, nil
+	}
+
+	return strconv.ParseInt(config["oom_score_adj"], 10, 64)
+}
+
+// oomParseStatParent returns the parent PID found in the content of a
+// /proc/<pid>/stat file. The command name may contain spaces and parentheses,
+// so the fields are looked for after the last closing parenthesis.
+func oomParseStatParent(content string) (int, error) {
+	i := strings.LastIndex(content, ")")
+	if i < 0 {
+		return -1, fmt.Errorf("Invalid stat content")
+	}
+
+	fields := strings.Fields(content[i+1:])
+	if len(fields) < 2 {
+		return -1, fmt.Errorf("Invalid stat content")
+	}
+
+	return strconv.Atoi(fields[1])
+}
+
+// oomDescendants returns a process and all its descendants, given the parent
+// of each process.
+func oomDescendants(pid int, parents map[int]int) []int {
+	children := map[int][]int{}
+	for child, parent := range parents {
+		children[parent] = append(children[parent], child)
+	}
+
+	pids := []int{pid}
+	for i := 0; i < len(pids); i++ {
+		pids = append(pids, children[pids[i]]...)
+	}
+
+	return pids
+}
+
+// oomProcessParents returns the parent of each process of the host.
+func oomProcessParents() (map[int]int, error) {
+	dents, err := ioutil.ReadDir("/proc")
+	if err != nil {
+		return nil, err
+	}
+
+	parents := map[int]int{}
+	for _, dent := range dents {
+		pid, err := strconv.Atoi(dent.Name())
+		if err != nil {
+			continue
+		}
+
+		// Processes may exit while going through the list
+		content, err := ioutil.ReadFile(fmt.Sprintf("/proc/%d/stat", pid))
+		if err != nil {
+			continue
+		}
+
+		parent, err := oomParseStatParent(string(content))
+		if err != nil {
+			continue
+		}
+
+		parents[pid] = parent
+	}
+
+	return parents, nil
+}
+
+// oomSetScoreAdj sets the OOM killer score adjustment of a process and all
+// its descendants.
+func oomSetScoreAdj(pid int, value int64) error {
+	parents, err := oomProcessParents()
+	if err != nil {
+		return err
+	}
+
+	for _, p := range oomDescendants(pid, parents) {
+		err := ioutil.WriteFile(fmt.Sprintf("/proc/%d/oom_score_adj", p), []byte(fmt.Sprintf("%d", value)), 0644)
+		if err != nil && !os.IsNotExist(err) {
+			return errors.Wrapf(err, "Failed to set the OOM score adjustment of process %d", p)
+		}
+	}
+
+	return nil
+}
+
+// oomReadScore returns the current OOM killer score of a process.
+func oomReadScore(pid int) (int64, error) {
+	content, err := ioutil.ReadFile(fmt.Sprintf("/proc/%d/oom_score", pid))
+	if err != nil {
+		return -1, err
+	}
+
+	return strconv.ParseInt(strings.TrimSpace(string(content)), 10, 64)
+}
+
+// containerOOMScoreAdjApply sets the OOM killer score adjustment of the
+// processes of a running container.
+func containerOOMScoreAdjApply(c container, defaultValue int64) error {
+	value, err := containerOOMScoreAdj(c.ExpandedConfig(), defaultValue)
+	if err != nil {
+		return err
+	}
+
+	pid := c.InitPID()
+	if pid <= 0 {
+		return nil
+	}
+
+	return oomSetScoreAdj(pid, value)
+}
+
+// containerOOMScoreAdjDefaultUpdate applies a new server default OOM killer
+// score adjustment to the running containers which don't set their own.
+func containerOOMScoreAdjDefaultUpdate(d *Daemon, defaultValue int64) {
+	containers, err := containerLoadNodeAll(d.State())
+	if err != nil {
+		logger.Error("Failed to load containers for OOM score adjustment", log.Ctx{"err": err})
+		return
+	}
+
+	for _, c := range containers {
+		if !c.IsRunning() || c.ExpandedConfig()["oom_score_adj"] != "" {
+			continue
+		}
+
+		err := containerOOMScoreAdjApply(c, defaultValue)
+		if err != nil {
+			logger.Error("Failed to set OOM score adjustment", log.Ctx{"container": c.Name(), "project": c.Project(), "err": err})
+		}
+	}
+}
+
+// containerOOMScoreCheck emits an event for each running container whose
+// init process had its OOM killer score moved by the kernel, e.g. as its
+// memory usage grows, given the scores seen at the previous check.
+func containerOOMScoreCheck(d *Daemon, scores map[string]int64) {
+	containers, err := containerLoadNodeAll(d.State())
+	if err != nil {
+		logger.Error("Failed to load containers for OOM score check", log.Ctx{"err": err})
+		return
+	}
+
+	seen := map[string]bool{}
+	for _, c := range containers {
+		if !c.IsRunning() {
+			continue
+		}
+
+		score, err := oomReadScore(c.InitPID())
+		if err != nil {
+			continue
+		}
+
+		key := projectPrefix(c.Project(), c.Name())
+		seen[key] = true
+
+		previous, ok := scores[key]
+		scores[key] = score
+		if !ok {
+			continue
+		}
+
+		diff := score - previous
+		if diff < 0 {
+			diff = -diff
+		}
+
+		if diff < oomScoreEventThreshold {
+			// Keep the reference score so that slow drifts get
+			// reported too
+			scores[key] = previous
+			continue
+		}
+
+		eventSendLifecycle(c.Project(), "container-oom-score-updated",
+			fmt.Sprintf("/1.0/containers/%s", c.Name()), map[string]interface{}{
+				"oom_score":          score,
+				"previous_oom_score": previous,
+			})
+	}
+
+	for key := range scores {
+		if !seen[key] {
+			delete(scores, key)
+		}
+	}
+}
+
+func containerOOMScoreTask(d *Daemon) (task.Func, task.Schedule) {
+	scores := map[string]int64{}
+	f := func(ctx context.Context) {
+		containerOOMScoreCheck(d, scores)
+	}
+
+	return f, task.Every(oomScoreCheckInterval)
+}
+
+// containerOOMScoreAdjDefault returns the server's default OOM killer score
+// adjustment of the containers.
+func containerOOMScoreAdjDefault(s *state.State) (int64, error) {
+	value, err := cluster.ConfigGetInt64(s.Cluster, "containers.default_oom_score_adj")
+	if err != nil {
+		return -1, errors.Wrap(err, "Failed to load the default OOM score adjustment")
+	}
+
+	return value, nil
+}
@@ -0,0 +1,53 @@
+package main
+
+import (
+	"sort"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestContainerOOMScoreAdj(t *testing.T) {
+	value, err := containerOOMScoreAdj(map[string]string{}, 100)
+	require.NoError(t, err)
+	assert.Equal(t, int64(100), value)
+
+	value, err = containerOOMScoreAdj(map[string]string{"oom_score_adj": "-500"}, 100)
+	require.NoError(t, err)
+	assert.Equal(t, int64(-500), value)
+
+	_, err = containerOOMScoreAdj(map[string]string{"oom_score_adj": "high"}, 0)
+	assert.Error(t, err)
+}
+
+func TestOOMParseStatParent(t *testing.T) {
+	parent, err := oomParseStatParent("1234 (bash) S 1200 1234 1234 34816 1234 4194304")
+	require.NoError(t, err)
+	assert.Equal(t, 1200, parent)
+
+	// Command names may contain spaces and parentheses
+	parent, err = oomParseStatParent("42 (my (odd) cmd) R 7 42 42 0 -1 4194560")
+	require.NoError(t, err)
+	assert.Equal(t, 7, parent)
+
+	_, err = oomParseStatParent("42 my-cmd")
+	assert.Error(t, err)
+}
+
+func TestOOMDescendants(t *testing.T) {
+	parents := map[int]int{
+		1:   0,
+		100: 1,
+		101: 100,
+		102: 100,
+		103: 102,
+		200: 1,
+	}
+
+	pids := oomDescendants(100, parents)
+	sort.Ints(pids)
+	assert.Equal(t, []int{100, 101, 102, 103}, pids)
+
+	assert.Equal(t, []int{200}, oomDescendants(200, parents))
+}
@@ -0,0 +1,144 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/lxc/lxd/lxd/task"
+	"github.com/lxc/lxd/shared/logger"
+
+	log "github.com/lxc/lxd/shared/log15"
+)
+
+// How often the open files of the containers are counted
+const openFilesCheckInterval = time.Minute
+
+// Default warning threshold, in percentage of limits.kernel.nofile
+const openFilesWarningDefault = "80%"
+
+// containerOpenFilesThreshold returns the number of files a process of a
+// container may open before it's reported, along with the soft limit of
+// limits.kernel.nofile. A threshold of 0 means the container isn't monitored.
+func containerOpenFilesThreshold(config map[string]string) (int64, int64, error) {
+	limit := int64(0)
+	soft := strings.SplitN(config["limits.kernel.nofile"], ":", 2)[0]
+	if soft != "" && soft != "unlimited" {
+		var err error
+		limit, err = strconv.ParseInt(soft, 10, 64)
+		if err != nil {
+			return 0, 0, err
+		}
+	}
+
+	warning := config["limits.files_warning"]
+	if warning == "" {
+		warning = openFilesWarningDefault
+	}
+
+	if strings.HasSuffix(warning, "%") {
+		percent, err := strconv.ParseInt(strings.TrimSuffix(warning, "%"), 10, 64)
+		if err != nil {
+			return 0, 0, err
+		}
+
+		return limit * percent / 100, limit, nil
+	}
+
+	threshold, err := strconv.ParseInt(warning, 10, 64)
+	if err != nil {
+		return 0, 0, err
+	}
+
+	return threshold, limit, nil
+}
+
+// containerOpenFiles returns the number of file descriptors opened by the
+// given processes, along with the largest number opened by one of them.
+func containerOpenFiles(procPath string, pids []int64) (int64, int64) {
+	count := int64(0)
+	max := int64(0)
+	for _, pid := range pids {
+		dir, err := os.Open(fmt.Sprintf("%s/%d/fd", procPath, pid))
+		if err != nil {
+			// The process terminated in the meantime
+			continue
+		}
+
+		names, err := dir.Readdirnames(-1)
+		dir.Close()
+		if err != nil {
+			continue
+		}
+
+		count += int64(len(names))
+		if int64(len(names)) > max {
+			max = int64(len(names))
+		}
+	}
+
+	return count, max
+}
+
+// containerOpenFilesCheck emits an event for each container with a process
+// whose open files went over the warning threshold since the previous check,
+// the limit of limits.kernel.nofile applying to each process.
+func containerOpenFilesCheck(d *Daemon, alerted map[string]bool) {
+	containers, err := containerLoadNodeAll(d.State())
+	if err != nil {
+		logger.Error("Failed to load containers for open files check", log.Ctx{"err": err})
+		return
+	}
+
+	seen := map[string]bool{}
+	for _, c := range containers {
+		if !c.IsRunning() {
+			continue
+		}
+
+		threshold, limit, err := containerOpenFilesThreshold(c.ExpandedConfig())
+		if err != nil || threshold <= 0 {
+			continue
+		}
+
+		key := projectPrefix(c.Project(), c.Name())
+		seen[key] = true
+
+		_, count := containerOpenFiles("/proc", containerProcesses(c.InitPID()))
+		if count < threshold {
+			delete(alerted, key)
+			continue
+		}
+
+		if alerted[key] {
+			continue
+		}
+		alerted[key] = true
+
+		logger.Warn("Container is approaching its open files limit", log.Ctx{"container": c.Name(), "project": c.Project(), "open_files": count, "max": limit})
+		eventSendLifecycle(c.Project(), "fd.limit_approaching",
+			fmt.Sprintf("/1.0/containers/%s", c.Name()), map[string]interface{}{
+				"name":    c.Name(),
+				"current": count,
+				"max":     limit,
+			})
+	}
+
+	for key := range alerted {
+		if !seen[key] {
+			delete(alerted, key)
+		}
+	}
+}
+
+func containerOpenFilesTask(d *Daemon) (task.Func, task.Schedule) {
+	alerted := map[string]bool{}
+	f := func(ctx context.Context) {
+		containerOpenFilesCheck(d, alerted)
+	}
+
+	return f, task.Every(openFilesCheckInterval)
+}
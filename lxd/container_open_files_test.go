@@ -0,0 +1,63 @@
+package main
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestContainerOpenFilesThreshold(t *testing.T) {
+	cases := []struct {
+		config    map[string]string
+		threshold int64
+		limit     int64
+	}{
+		{map[string]string{}, 0, 0},
+		{map[string]string{"limits.kernel.nofile": "1000"}, 800, 1000},
+		{map[string]string{"limits.kernel.nofile": "1000:4096"}, 800, 1000},
+		{map[string]string{"limits.kernel.nofile": "unlimited"}, 0, 0},
+		{map[string]string{"limits.kernel.nofile": "1000", "limits.files_warning": "50%"}, 500, 1000},
+		{map[string]string{"limits.kernel.nofile": "1000", "limits.files_warning": "900"}, 900, 1000},
+		{map[string]string{"limits.files_warning": "900"}, 900, 0},
+		{map[string]string{"limits.files_warning": "90%"}, 0, 0},
+	}
+
+	for _, c := range cases {
+		threshold, limit, err := containerOpenFilesThreshold(c.config)
+		require.NoError(t, err)
+		assert.Equal(t, c.threshold, threshold, "%v", c.config)
+		assert.Equal(t, c.limit, limit, "%v", c.config)
+	}
+
+	_, _, err := containerOpenFilesThreshold(map[string]string{"limits.kernel.nofile": "foo"})
+	assert.Error(t, err)
+}
+
+func TestContainerOpenFiles(t *testing.T) {
+	dir, err := ioutil.TempDir("", "lxd-proc-")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	for pid, fds := range map[int]int{1: 3, 42: 5} {
+		fdDir := filepath.Join(dir, fmt.Sprintf("%d", pid), "fd")
+		require.NoError(t, os.MkdirAll(fdDir, 0755))
+
+		for fd := 0; fd < fds; fd++ {
+			require.NoError(t, os.Symlink("/dev/null", filepath.Join(fdDir, fmt.Sprintf("%d", fd))))
+		}
+	}
+
+	count, max := containerOpenFiles(dir, []int64{1, 42})
+	assert.Equal(t, int64(8), count)
+	assert.Equal(t, int64(5), max)
+
+	// Processes which went away are ignored
+	count, max = containerOpenFiles(dir, []int64{1, 4242})
+	assert.Equal(t, int64(3), count)
+	assert.Equal(t, int64(3), max)
+}
@@ -6,6 +6,8 @@ import (
 	"fmt"
 	"io/ioutil"
 	"net/http"
+	"regexp"
+	"sync"
 
 	"github.com/gorilla/mux"
 
@@ -112,6 +114,18 @@ func containerPatch(d *Daemon, r *http.Request) Response {
 		}
 	}
 
+	// Keys which only apply on start can't be changed on a running container
+	if c.IsRunning() {
+		local := c.LocalConfig()
+		for k, v := range req.Config {
+			if local[k] == v || containerConfigKeyLive(k) {
+				continue
+			}
+
+			return BadRequest(fmt.Errorf("Key '%s' can't be changed while the container is running, it requires a restart", k))
+		}
+	}
+
 	// Update container configuration
 	args := db.ContainerArgs{
 		Architecture: architecture,
@@ -130,3 +144,65 @@ func containerPatch(d *Daemon, r *http.Request) Response {
 
 	return EmptySyncResponse
 }
+
+// containerConfigLive lists whether the container config keys of the config
+// schema can be changed on a running container.
+var containerConfigLive struct {
+	once     sync.Once
+	keys     map[string]bool
+	patterns map[*regexp.Regexp]bool
+}
+
+type configSchemaKey struct {
+	Live bool `json:"x-lxd-live-update"`
+}
+
+// containerConfigKeyLive returns whether a change of the given container
+// config key applies to a running container. Unknown keys are considered
+// live.
+func containerConfigKeyLive(key string) bool {
+	containerConfigLive.once.Do(func() {
+		schema := struct {
+			Properties struct {
+				Config struct {
+					Properties        map[string]configSchemaKey `json:"properties"`
+					PatternProperties map[string]configSchemaKey `json:"patternProperties"`
+				} `json:"config"`
+			} `json:"properties"`
+		}{}
+
+		containerConfigLive.keys = map[string]bool{}
+		containerConfigLive.patterns = map[*regexp.Regexp]bool{}
+
+		err := json.Unmarshal([]byte(configSchema), &schema)
+		if err != nil {
+			return
+		}
+
+		for k, v := range schema.Properties.Config.Properties {
+			containerConfigLive.keys[k] = v.Live
+		}
+
+		for pattern, v := range schema.Properties.Config.PatternProperties {
+			re, err := regexp.Compile(pattern)
+			if err != nil {
+				continue
+			}
+
+			containerConfigLive.patterns[re] = v.Live
+		}
+	})
+
+	live, ok := containerConfigLive.keys[key]
+	if ok {
+		return live
+	}
+
+	for re, live := range containerConfigLive.patterns {
+		if re.MatchString(key) {
+			return live
+		}
+	}
+
+	return true
+}
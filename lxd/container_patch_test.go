@@ -0,0 +1,25 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestContainerConfigKeyLive(t *testing.T) {
+	assert.True(t, containerConfigKeyLive("limits.cpu"))
+	assert.True(t, containerConfigKeyLive("limits.cpu.period"))
+	assert.True(t, containerConfigKeyLive("user.foo"))
+	assert.True(t, containerConfigKeyLive("security.devlxd"))
+
+	// Keys not affecting the running container
+	assert.True(t, containerConfigKeyLive("boot.autostart"))
+	assert.True(t, containerConfigKeyLive("snapshots.expiry"))
+	assert.True(t, containerConfigKeyLive("volatile.apply_template"))
+	assert.False(t, containerConfigKeyLive("security.privileged"))
+	assert.False(t, containerConfigKeyLive("limits.kernel.nofile"))
+	assert.False(t, containerConfigKeyLive("raw.lxc"))
+
+	// Unknown keys
+	assert.True(t, containerConfigKeyLive("foo.bar"))
+}
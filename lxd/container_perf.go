@@ -0,0 +1,368 @@
+package main
+
+import (
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"time"
+	"unsafe"
+
+	"github.com/gorilla/mux"
+	"golang.org/x/sys/unix"
+
+	"github.com/lxc/lxd/shared"
+	"github.com/lxc/lxd/shared/api"
+)
+
+var containerPerfPageFaultsCmd = Command{
+	name: "containers/{name}/perf/pagefaults",
+	get:  containerPerfPageFaultsGet,
+}
+
+// Capability allowing to open perf events, CAP_SYS_ADMIN being used by
+// kernels older than 5.8
+const capPerfmon = 38
+
+// Number of processes in the page faults report
+const perfPageFaultsTop = 10
+
+// Default and maximum sampling duration of the page faults
+const (
+	perfPageFaultsDuration    = 5 * time.Second
+	perfPageFaultsMaxDuration = 60 * time.Second
+)
+
+// Size in pages of the ring buffers of the samples, drained every interval
+const (
+	perfRingPages    = 16
+	perfPollInterval = 100 * time.Millisecond
+)
+
+// perfCheckCapabilities makes sure that LXD is allowed to open perf events
+// on the processes of the containers.
+func perfCheckCapabilities() error {
+	content, err := ioutil.ReadFile("/proc/self/status")
+	if err != nil {
+		return err
+	}
+
+	_, sets, err := capabilitiesParseStatus(string(content))
+	if err != nil {
+		return err
+	}
+
+	if sets["CapEff"]&(1<<capPerfmon) == 0 && sets["CapEff"]&(1<<capSysAdmin) == 0 {
+		return fmt.Errorf("LXD lacks the CAP_PERFMON capability required to open perf events")
+	}
+
+	return nil
+}
+
+// perfContainerCgroup returns the path of the perf_event cgroup of the
+// container, in the cgroup2 hierarchy if mounted.
+func perfContainerCgroup(c container) (string, error) {
+	cgroup, err := ebpfCgroupPath(c.InitPID())
+	if err == nil {
+		return cgroup, nil
+	}
+
+	content, err := ioutil.ReadFile(fmt.Sprintf("/proc/%d/cgroup", c.InitPID()))
+	if err != nil {
+		return "", err
+	}
+
+	for _, line := range strings.Split(string(content), "\n") {
+		fields := strings.SplitN(line, ":", 3)
+		if len(fields) != 3 || !shared.StringInSlice("perf_event", strings.Split(fields[1], ",")) {
+			continue
+		}
+
+		return filepath.Join("/sys/fs/cgroup/perf_event", fields[2]), nil
+	}
+
+	return "", fmt.Errorf("The container has no perf_event cgroup")
+}
+
+// perfOpenPageFaults opens an event sampling every major page fault of the
+// processes of a cgroup on a CPU, recording the faulting process.
+func perfOpenPageFaults(cgroupFd int, cpu int) (int, error) {
+	attr := unix.PerfEventAttr{
+		Type:        unix.PERF_TYPE_SOFTWARE,
+		Config:      unix.PERF_COUNT_SW_PAGE_FAULTS_MAJ,
+		Sample:      1,
+		Sample_type: unix.PERF_SAMPLE_TID,
+	}
+	attr.Size = uint32(unsafe.Sizeof(attr))
+
+	return unix.PerfEventOpen(&attr, cgroupFd, cpu, -1, unix.PERF_FLAG_FD_CLOEXEC|unix.PERF_FLAG_PID_CGROUP)
+}
+
+// perfReadCounter reads the value of a counting perf event.
+func perfReadCounter(fd int) (uint64, error) {
+	buf := make([]byte, 8)
+	n, err := unix.Read(fd, buf)
+	if err != nil {
+		return 0, err
+	}
+
+	if n != len(buf) {
+		return 0, fmt.Errorf("Short read of perf counter")
+	}
+
+	return *(*uint64)(unsafe.Pointer(&buf[0])), nil
+}
+
+// perfRing is the ring buffer the samples of a perf event are written to.
+type perfRing struct {
+	fd   int
+	mem  []byte
+	meta *unix.PerfEventMmapPage
+	data []byte
+}
+
+func newPerfRing(fd int) (*perfRing, error) {
+	pageSize := os.Getpagesize()
+	mem, err := unix.Mmap(fd, 0, (1+perfRingPages)*pageSize, unix.PROT_READ|unix.PROT_WRITE, unix.MAP_SHARED)
+	if err != nil {
+		return nil, err
+	}
+
+	return &perfRing{
+		fd:   fd,
+		mem:  mem,
+		meta: (*unix.PerfEventMmapPage)(unsafe.Pointer(&mem[0])),
+		data: mem[pageSize:],
+	}, nil
+}
+
+// read returns the records written since the last read, freeing their space.
+func (r *perfRing) read() []byte {
+	head := atomic.LoadUint64(&r.meta.Data_head)
+	tail := r.meta.Data_tail
+
+	size := uint64(len(r.data))
+	start := tail % size
+	n := head - tail
+
+	records := make([]byte, 0, n)
+	if start+n <= size {
+		records = append(records, r.data[start:start+n]...)
+	} else {
+		records = append(records, r.data[start:]...)
+		records = append(records, r.data[:n-(size-start)]...)
+	}
+
+	atomic.StoreUint64(&r.meta.Data_tail, head)
+	return records
+}
+
+func (r *perfRing) close() {
+	unix.Munmap(r.mem)
+	unix.Close(r.fd)
+}
+
+// perfRecordHeader is the header of the records of a ring buffer.
+type perfRecordHeader struct {
+	Type uint32
+	Misc uint16
+	Size uint16
+}
+
+// perfParseSamples counts the samples of each process among the records of a
+// ring buffer, whose samples only hold the PID and TID.
+func perfParseSamples(records []byte, faults map[int64]uint64) {
+	for len(records) >= 8 {
+		header := (*perfRecordHeader)(unsafe.Pointer(&records[0]))
+		size := int(header.Size)
+		if size < 8 || size > len(records) {
+			break
+		}
+
+		if header.Type == unix.PERF_RECORD_SAMPLE && size >= 16 {
+			pid := *(*uint32)(unsafe.Pointer(&records[8]))
+			faults[int64(pid)]++
+		}
+
+		records = records[size:]
+	}
+}
+
+// perfSamplePageFaults samples the major page faults of the processes of a
+// cgroup on every CPU for the given duration, returning their total and the
+// faults of each process along with its command name. Processes started
+// during the sample are counted too.
+func perfSamplePageFaults(cgroup string, duration time.Duration) (uint64, map[int64]uint64, map[int64]string, error) {
+	cgroupFd, err := unix.Open(cgroup, unix.O_RDONLY|unix.O_DIRECTORY|unix.O_CLOEXEC, 0)
+	if err != nil {
+		return 0, nil, nil, err
+	}
+	defer unix.Close(cgroupFd)
+
+	online, err := ioutil.ReadFile("/sys/devices/system/cpu/online")
+	if err != nil {
+		return 0, nil, nil, err
+	}
+
+	cpus, err := parseCpuset(strings.TrimSpace(string(online)))
+	if err != nil {
+		return 0, nil, nil, err
+	}
+
+	rings := []*perfRing{}
+	defer func() {
+		for _, ring := range rings {
+			ring.close()
+		}
+	}()
+
+	for _, cpu := range cpus {
+		fd, err := perfOpenPageFaults(cgroupFd, cpu)
+		if err != nil {
+			return 0, nil, nil, fmt.Errorf("Failed to open perf event on CPU %d: %v", cpu, err)
+		}
+
+		ring, err := newPerfRing(fd)
+		if err != nil {
+			unix.Close(fd)
+			return 0, nil, nil, err
+		}
+
+		rings = append(rings, ring)
+	}
+
+	faults := map[int64]uint64{}
+	commands := map[int64]string{}
+	drain := func() {
+		for _, ring := range rings {
+			perfParseSamples(ring.read(), faults)
+		}
+
+		// Look the command up while the process is likely still there
+		for pid := range faults {
+			_, ok := commands[pid]
+			if ok {
+				continue
+			}
+
+			comm, err := ioutil.ReadFile(fmt.Sprintf("/proc/%d/comm", pid))
+			if err != nil {
+				commands[pid] = ""
+				continue
+			}
+
+			commands[pid] = strings.TrimSpace(string(comm))
+		}
+	}
+
+	deadline := time.Now().Add(duration)
+	for time.Now().Before(deadline) {
+		sleep := time.Until(deadline)
+		if sleep > perfPollInterval {
+			sleep = perfPollInterval
+		}
+
+		time.Sleep(sleep)
+		drain()
+	}
+
+	// The counters also include the samples which were lost
+	total := uint64(0)
+	for _, ring := range rings {
+		count, err := perfReadCounter(ring.fd)
+		if err != nil {
+			return 0, nil, nil, err
+		}
+
+		total += count
+	}
+
+	return total, faults, commands, nil
+}
+
+// perfPageFaultsReport returns the report of the most faulting processes.
+func perfPageFaultsReport(total uint64, faults map[int64]uint64, commands map[int64]string, duration time.Duration) api.ContainerPerfPageFaults {
+	report := api.ContainerPerfPageFaults{
+		Duration:  duration.Seconds(),
+		Total:     total,
+		Processes: []api.ContainerPerfPageFaultsProcess{},
+	}
+
+	for pid, count := range faults {
+		report.Processes = append(report.Processes, api.ContainerPerfPageFaultsProcess{
+			PID:     pid,
+			Command: commands[pid],
+			Faults:  count,
+			Rate:    float64(count) / duration.Seconds(),
+		})
+	}
+
+	sort.Slice(report.Processes, func(i, j int) bool {
+		if report.Processes[i].Faults != report.Processes[j].Faults {
+			return report.Processes[i].Faults > report.Processes[j].Faults
+		}
+
+		return report.Processes[i].PID < report.Processes[j].PID
+	})
+
+	if len(report.Processes) > perfPageFaultsTop {
+		report.Processes = report.Processes[:perfPageFaultsTop]
+	}
+
+	return report
+}
+
+func containerPerfPageFaultsGet(d *Daemon, r *http.Request) Response {
+	project := projectParam(r)
+	name := mux.Vars(r)["name"]
+
+	// Handle requests targeted to a container on a different node
+	response, err := ForwardedResponseIfContainerIsRemote(d, r, project, name)
+	if err != nil {
+		return SmartError(err)
+	}
+	if response != nil {
+		return response
+	}
+
+	duration := perfPageFaultsDuration
+	if r.FormValue("duration") != "" {
+		seconds, err := strconv.Atoi(r.FormValue("duration"))
+		if err != nil || seconds <= 0 || time.Duration(seconds)*time.Second > perfPageFaultsMaxDuration {
+			return BadRequest(fmt.Errorf("Invalid duration: %s", r.FormValue("duration")))
+		}
+
+		duration = time.Duration(seconds) * time.Second
+	}
+
+	c, err := containerLoadByProjectAndName(d.State(), project, name)
+	if err != nil {
+		return SmartError(err)
+	}
+
+	if !c.IsRunning() {
+		return BadRequest(withErrorCode(api.ErrContainerNotRunning, fmt.Errorf("Container is not running")))
+	}
+
+	err = perfCheckCapabilities()
+	if err != nil {
+		return Forbidden(err)
+	}
+
+	cgroup, err := perfContainerCgroup(c)
+	if err != nil {
+		return InternalError(err)
+	}
+
+	total, faults, commands, err := perfSamplePageFaults(cgroup, duration)
+	if err != nil {
+		return InternalError(err)
+	}
+
+	return SyncResponse(true, perfPageFaultsReport(total, faults, commands, duration))
+}
@@ -0,0 +1,65 @@
+package main
+
+import (
+	"fmt"
+	"testing"
+	"time"
+	"unsafe"
+
+	"github.com/stretchr/testify/assert"
+	"golang.org/x/sys/unix"
+)
+
+func TestPerfPageFaultsReport(t *testing.T) {
+	faults := map[int64]uint64{}
+	commands := map[int64]string{}
+	for pid := int64(1); pid <= 12; pid++ {
+		faults[pid] = uint64(pid * 10)
+		commands[pid] = fmt.Sprintf("cmd%d", pid)
+	}
+	faults[13] = 120
+	commands[13] = "cmd13"
+
+	report := perfPageFaultsReport(900, faults, commands, 2*time.Second)
+	assert.Equal(t, float64(2), report.Duration)
+	assert.Equal(t, uint64(900), report.Total)
+	assert.Len(t, report.Processes, 10)
+
+	// Ties are ordered by PID
+	assert.Equal(t, int64(12), report.Processes[0].PID)
+	assert.Equal(t, int64(13), report.Processes[1].PID)
+	assert.Equal(t, "cmd13", report.Processes[1].Command)
+	assert.Equal(t, uint64(120), report.Processes[1].Faults)
+	assert.Equal(t, float64(60), report.Processes[1].Rate)
+	assert.Equal(t, int64(4), report.Processes[9].PID)
+
+	report = perfPageFaultsReport(0, map[int64]uint64{}, map[int64]string{}, time.Second)
+	assert.Equal(t, uint64(0), report.Total)
+	assert.Len(t, report.Processes, 0)
+}
+
+// perfTestRecord returns a record of a ring buffer holding the given values.
+func perfTestRecord(recordType uint32, values ...uint32) []byte {
+	record := make([]byte, 8+4*len(values))
+	*(*perfRecordHeader)(unsafe.Pointer(&record[0])) = perfRecordHeader{Type: recordType, Size: uint16(len(record))}
+	for i, value := range values {
+		*(*uint32)(unsafe.Pointer(&record[8+4*i])) = value
+	}
+
+	return record
+}
+
+func TestPerfParseSamples(t *testing.T) {
+	records := []byte{}
+	records = append(records, perfTestRecord(unix.PERF_RECORD_SAMPLE, 42, 43)...)
+	records = append(records, perfTestRecord(unix.PERF_RECORD_LOST, 1, 0, 5, 0)...)
+	records = append(records, perfTestRecord(unix.PERF_RECORD_SAMPLE, 42, 42)...)
+	records = append(records, perfTestRecord(unix.PERF_RECORD_SAMPLE, 7, 7)...)
+
+	// Truncated
+	records = append(records, perfTestRecord(unix.PERF_RECORD_SAMPLE, 7, 7)[:12]...)
+
+	faults := map[int64]uint64{}
+	perfParseSamples(records, faults)
+	assert.Equal(t, map[int64]uint64{42: 2, 7: 1}, faults)
+}
@@ -0,0 +1,172 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/gorilla/mux"
+
+	"github.com/lxc/lxd/shared/api"
+)
+
+var containerPidMapCmd = Command{
+	name: "containers/{name}/pid-map",
+	get:  containerPidMapGet,
+}
+
+var containerPidMapEntryCmd = Command{
+	name: "containers/{name}/pid-map/{pid}",
+	get:  containerPidMapEntryGet,
+}
+
+// pidMapParseNSpid returns the pids of a process in each of the pid
+// namespaces it belongs to, from the outermost to the innermost one, given
+// the content of /proc/<pid>/status.
+func pidMapParseNSpid(status string) ([]int64, error) {
+	scan := bufio.NewScanner(strings.NewReader(status))
+	for scan.Scan() {
+		fields := strings.SplitN(scan.Text(), ":", 2)
+		if len(fields) != 2 || fields[0] != "NSpid" {
+			continue
+		}
+
+		pids := []int64{}
+		for _, field := range strings.Fields(fields[1]) {
+			pid, err := strconv.ParseInt(field, 10, 64)
+			if err != nil {
+				return nil, fmt.Errorf("Invalid NSpid value '%s'", field)
+			}
+
+			pids = append(pids, pid)
+		}
+
+		return pids, nil
+	}
+
+	return nil, fmt.Errorf("No NSpid field (requires Linux 4.1 or later)")
+}
+
+// pidMapCommand returns the command line of a process, or its name for
+// kernel threads and zombies.
+func pidMapCommand(pid int64) string {
+	content, err := ioutil.ReadFile(fmt.Sprintf("/proc/%d/cmdline", pid))
+	if err == nil && len(content) > 0 {
+		return strings.TrimSpace(string(bytes.Replace(content, []byte{0}, []byte{' '}, -1)))
+	}
+
+	content, err = ioutil.ReadFile(fmt.Sprintf("/proc/%d/comm", pid))
+	if err != nil {
+		return ""
+	}
+
+	return strings.TrimSpace(string(content))
+}
+
+// containerPidMap returns the pids of the processes of the container, as
+// seen from within the container and from the host.
+func containerPidMap(c container) ([]api.ContainerPidMapEntry, error) {
+	entries := []api.ContainerPidMapEntry{}
+
+	// The pid namespace of the container is the innermost one of its init
+	// process, nested namespaces of its processes are skipped over.
+	level := -1
+	for _, pid := range containerProcesses(c.InitPID()) {
+		content, err := ioutil.ReadFile(fmt.Sprintf("/proc/%d/status", pid))
+		if err != nil {
+			// The process exited in the meantime
+			continue
+		}
+
+		nspids, err := pidMapParseNSpid(string(content))
+		if err != nil {
+			return nil, err
+		}
+
+		if level < 0 {
+			level = len(nspids) - 1
+		}
+
+		if level >= len(nspids) {
+			continue
+		}
+
+		entries = append(entries, api.ContainerPidMapEntry{
+			ContainerPID: nspids[level],
+			HostPID:      pid,
+			Command:      pidMapCommand(pid),
+		})
+	}
+
+	return entries, nil
+}
+
+// containerPidMapLoad loads the container targeted by the request, making
+// sure it's running.
+func containerPidMapLoad(d *Daemon, r *http.Request) (container, Response) {
+	project := projectParam(r)
+	name := mux.Vars(r)["name"]
+
+	// Handle requests targeted to a container on a different node
+	response, err := ForwardedResponseIfContainerIsRemote(d, r, project, name)
+	if err != nil {
+		return nil, SmartError(err)
+	}
+	if response != nil {
+		return nil, response
+	}
+
+	c, err := containerLoadByProjectAndName(d.State(), project, name)
+	if err != nil {
+		return nil, SmartError(err)
+	}
+
+	if !c.IsRunning() {
+		return nil, BadRequest(withErrorCode(api.ErrContainerNotRunning, fmt.Errorf("Container is not running")))
+	}
+
+	return c, nil
+}
+
+func containerPidMapGet(d *Daemon, r *http.Request) Response {
+	c, resp := containerPidMapLoad(d, r)
+	if resp != nil {
+		return resp
+	}
+
+	entries, err := containerPidMap(c)
+	if err != nil {
+		return InternalError(err)
+	}
+
+	return SyncResponse(true, entries)
+}
+
+func containerPidMapEntryGet(d *Daemon, r *http.Request) Response {
+	pid, err := strconv.ParseInt(mux.Vars(r)["pid"], 10, 64)
+	if err != nil || pid <= 0 {
+		return BadRequest(fmt.Errorf("Invalid pid '%s'", mux.Vars(r)["pid"]))
+	}
+
+	c, resp := containerPidMapLoad(d, r)
+	if resp != nil {
+		return resp
+	}
+
+	entries, err := containerPidMap(c)
+	if err != nil {
+		return InternalError(err)
+	}
+
+	for _, entry := range entries {
+		if entry.ContainerPID == pid {
+			return SyncResponse(true, entry)
+		}
+	}
+
+	return NotFound(fmt.Errorf("No process with pid %d in the container", pid))
+}
@@ -0,0 +1,20 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPidMapParseNSpid(t *testing.T) {
+	pids, err := pidMapParseNSpid("Name:\tbash\nTgid:\t4321\nPid:\t4321\nNSpid:\t4321\t57\t1\n")
+	require.NoError(t, err)
+	assert.Equal(t, []int64{4321, 57, 1}, pids)
+
+	_, err = pidMapParseNSpid("Name:\tbash\nPid:\t4321\n")
+	assert.Error(t, err)
+
+	_, err = pidMapParseNSpid("NSpid:\t4321\tfoo\n")
+	assert.Error(t, err)
+}
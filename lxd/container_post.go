@@ -164,7 +164,7 @@ func containerPost(d *Daemon, r *http.Request) Response {
 		if targetNode != "" {
 			// Check whether the container is running.
 			if c != nil && c.IsRunning() {
-				return BadRequest(fmt.Errorf("Container is running"))
+				return BadRequest(withErrorCode(api.ErrContainerRunning, fmt.Errorf("Container is running")))
 			}
 
 			// Check if we are migrating a ceph-based container.
@@ -6,6 +6,7 @@ import (
 	"net/http"
 
 	"github.com/gorilla/mux"
+	"github.com/pkg/errors"
 
 	"github.com/lxc/lxd/lxd/db"
 	"github.com/lxc/lxd/lxd/state"
@@ -93,12 +94,20 @@ func containerPut(d *Daemon, r *http.Request) Response {
 	resources := map[string][]string{}
 	resources["containers"] = []string{name}
 
-	op, err := operationCreate(d.cluster, project, operationClassTask, opType, resources, nil, do, nil, nil)
-	if err != nil {
-		return InternalError(err)
+	create := func() Response {
+		op, err := operationCreate(d.cluster, project, operationClassTask, opType, resources, nil, do, nil, nil)
+		if err != nil {
+			return InternalError(err)
+		}
+
+		return OperationResponse(op)
+	}
+
+	if configRaw.Restore != "" {
+		return storageOpsLimit(d, create)
 	}
 
-	return OperationResponse(op)
+	return create()
 }
 
 func containerSnapRestore(s *state.State, project, name, snap string, stateful bool) error {
@@ -114,7 +123,7 @@ func containerSnapRestore(s *state.State, project, name, snap string, stateful b
 
 	source, err := containerLoadByProjectAndName(s, project, snap)
 	if err != nil {
-		switch err {
+		switch errors.Cause(err) {
 		case db.ErrNoSuchObject:
 			return fmt.Errorf("Snapshot %s does not exist", snap)
 		default:
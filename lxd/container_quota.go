@@ -0,0 +1,62 @@
+package main
+
+import (
+	"fmt"
+
+	"github.com/lxc/lxd/lxd/cluster"
+	"github.com/lxc/lxd/shared"
+	"github.com/lxc/lxd/shared/api"
+)
+
+// containerQuotaCheck returns a 507 error if the root disk of the container
+// is filled above storage.quota_threshold, so that writes to the container
+// don't fail with ENOSPC half way through. It returns nil if the container
+// has room left or its usage can't be determined.
+func containerQuotaCheck(d *Daemon, c container) Response {
+	threshold, err := cluster.ConfigGetInt64(d.cluster, "storage.quota_threshold")
+	if err != nil {
+		return SmartError(err)
+	}
+
+	if threshold <= 0 {
+		return nil
+	}
+
+	_, rootDiskDevice, err := shared.GetRootDiskDevice(c.ExpandedDevices())
+	if err != nil || rootDiskDevice["size"] == "" {
+		return nil
+	}
+
+	quota, err := shared.ParseByteSizeString(rootDiskDevice["size"])
+	if err != nil || quota <= 0 {
+		return nil
+	}
+
+	storage := c.Storage()
+	if storage == nil {
+		return nil
+	}
+
+	// Not all storage drivers can report the usage
+	usage, err := storage.ContainerGetUsage(c)
+	if err != nil {
+		return nil
+	}
+
+	if usage*100 < quota*threshold {
+		return nil
+	}
+
+	headroom := quota - usage
+	if headroom < 0 {
+		headroom = 0
+	}
+
+	info := api.ContainerDiskQuota{
+		Usage:    usage,
+		Quota:    quota,
+		Headroom: headroom,
+	}
+
+	return InsufficientStorage(fmt.Errorf("Container root disk is %d%% full (%s used out of %s)", usage*100/quota, shared.GetByteSizeString(usage, 2), shared.GetByteSizeString(quota, 2)), info)
+}
@@ -0,0 +1,500 @@
+package main
+
+import (
+	"archive/tar"
+	"bytes"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/cespare/xxhash"
+	"github.com/gorilla/mux"
+	"github.com/pkg/errors"
+	"golang.org/x/sys/unix"
+
+	"github.com/lxc/lxd/lxd/db"
+	"github.com/lxc/lxd/shared"
+	"github.com/lxc/lxd/shared/api"
+	"github.com/lxc/lxd/shared/idmap"
+)
+
+var containerRootfsSyncCmd = Command{
+	name: "containers/{name}/rootfs/sync",
+	post: containerRootfsSyncPost,
+}
+
+// rootfsSyncEntryError is returned for tarball entries which can't be synced.
+type rootfsSyncEntryError struct {
+	path string
+	msg  string
+}
+
+func (e rootfsSyncEntryError) Error() string {
+	return fmt.Sprintf("%s: %s", e.path, e.msg)
+}
+
+// rootfsSync applies a tarball to a directory tree of a container rootfs,
+// only replacing the files whose content or mode changed.
+//
+// The container may change its rootfs while it's synced, so all the paths
+// are resolved one component at a time relative to a pinned file descriptor
+// of the rootfs, never following symlinks.
+type rootfsSync struct {
+	rootfs   string
+	base     string
+	idmapset *idmap.IdmapSet
+	result   api.ContainerRootfsSync
+
+	rootfd int
+}
+
+// resolve returns a file descriptor of the parent directory of a path of the
+// container along with its last component, making sure that none of its
+// parents is a symlink, which could point outside of the rootfs. Missing
+// parents are created.
+func (s *rootfsSync) resolve(path string) (int, string, error) {
+	path = filepath.Clean("/" + filepath.Join(s.base, path))
+
+	dirfd, err := unix.Openat(s.rootfd, ".", unix.O_PATH|unix.O_DIRECTORY|unix.O_CLOEXEC, 0)
+	if err != nil {
+		return -1, "", err
+	}
+
+	if path == "/" {
+		return dirfd, ".", nil
+	}
+
+	parents := strings.Split(strings.TrimPrefix(filepath.Dir(path), "/"), "/")
+	for _, name := range parents {
+		if name == "" {
+			continue
+		}
+
+		fd, err := unix.Openat(dirfd, name, unix.O_PATH|unix.O_DIRECTORY|unix.O_NOFOLLOW|unix.O_CLOEXEC, 0)
+		if err == unix.ENOENT {
+			err = s.mkdir(dirfd, name, 0755, 0, 0)
+			if err != nil {
+				unix.Close(dirfd)
+				return -1, "", err
+			}
+
+			s.result.Added++
+			fd, err = unix.Openat(dirfd, name, unix.O_PATH|unix.O_DIRECTORY|unix.O_NOFOLLOW|unix.O_CLOEXEC, 0)
+		}
+		unix.Close(dirfd)
+
+		if err == unix.ENOTDIR || err == unix.ELOOP {
+			return -1, "", rootfsSyncEntryError{path, "parent isn't a directory"}
+		} else if err != nil {
+			return -1, "", err
+		}
+
+		dirfd = fd
+	}
+
+	return dirfd, filepath.Base(path), nil
+}
+
+// shift returns the host ids matching the ids of the container, failing if
+// they aren't mapped.
+func (s *rootfsSync) shift(name string, uid int, gid int) (int, int, error) {
+	if s.idmapset == nil {
+		return uid, gid, nil
+	}
+
+	hostUid, hostGid := s.idmapset.ShiftIntoNs(int64(uid), int64(gid))
+	if hostUid == -1 || hostGid == -1 {
+		return -1, -1, rootfsSyncEntryError{name, fmt.Sprintf("uid %d or gid %d isn't mapped in the container", uid, gid)}
+	}
+
+	return int(hostUid), int(hostGid), nil
+}
+
+// open opens an existing file or directory, without following a symlink
+// swapped in since it was checked nor blocking on a fifo, failing if it's
+// not of the given type anymore.
+func (s *rootfsSync) open(name string, dirfd int, base string, fileType uint32) (*os.File, error) {
+	fd, err := unix.Openat(dirfd, base, unix.O_RDONLY|unix.O_NOFOLLOW|unix.O_NONBLOCK|unix.O_CLOEXEC, 0)
+	if err != nil {
+		return nil, err
+	}
+
+	var st unix.Stat_t
+	err = unix.Fstat(fd, &st)
+	if err != nil {
+		unix.Close(fd)
+		return nil, err
+	}
+
+	if st.Mode&unix.S_IFMT != fileType {
+		unix.Close(fd)
+		return nil, rootfsSyncEntryError{name, "the file was replaced during the sync"}
+	}
+
+	return os.NewFile(uintptr(fd), name), nil
+}
+
+// setOwnerMode sets the owner then the mode of a file, as changing the owner
+// clears the setuid and setgid bits.
+func (s *rootfsSync) setOwnerMode(f *os.File, mode uint32, uid int, gid int) error {
+	err := unix.Fchown(int(f.Fd()), uid, gid)
+	if err != nil {
+		return err
+	}
+
+	return unix.Fchmod(int(f.Fd()), mode)
+}
+
+func (s *rootfsSync) mkdir(dirfd int, base string, mode uint32, uid int, gid int) error {
+	uid, gid, err := s.shift(base, uid, gid)
+	if err != nil {
+		return err
+	}
+
+	err = unix.Mkdirat(dirfd, base, 0700)
+	if err != nil {
+		return err
+	}
+
+	dir, err := s.open(base, dirfd, base, unix.S_IFDIR)
+	if err != nil {
+		return err
+	}
+	defer dir.Close()
+
+	return s.setOwnerMode(dir, mode, uid, gid)
+}
+
+// tempName returns a random name for the new version of a file.
+func (s *rootfsSync) tempName() (string, error) {
+	suffix, err := shared.RandomCryptoString()
+	if err != nil {
+		return "", err
+	}
+
+	return fmt.Sprintf(".lxd_sync_%s", suffix[:16]), nil
+}
+
+// Apply syncs all the entries of a tarball.
+func (s *rootfsSync) Apply(tr *tar.Reader) error {
+	var err error
+	s.rootfd, err = unix.Open(s.rootfs, unix.O_PATH|unix.O_DIRECTORY|unix.O_CLOEXEC, 0)
+	if err != nil {
+		return err
+	}
+	defer unix.Close(s.rootfd)
+
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+
+		err = s.apply(hdr, tr)
+		if err != nil {
+			_, ok := err.(rootfsSyncEntryError)
+			if !ok {
+				err = errors.Wrapf(err, "Failed to sync '%s'", hdr.Name)
+			}
+
+			return err
+		}
+	}
+}
+
+func (s *rootfsSync) apply(hdr *tar.Header, content io.Reader) error {
+	dirfd, base, err := s.resolve(hdr.Name)
+	if err != nil {
+		return err
+	}
+	defer unix.Close(dirfd)
+
+	mode := uint32(hdr.Mode) & 07777
+	switch hdr.Typeflag {
+	case tar.TypeDir:
+		return s.syncDir(hdr.Name, dirfd, base, mode, hdr.Uid, hdr.Gid)
+	case tar.TypeReg, tar.TypeRegA:
+		return s.syncFile(hdr.Name, dirfd, base, content, mode, hdr.Uid, hdr.Gid)
+	case tar.TypeSymlink:
+		return s.syncSymlink(hdr.Name, dirfd, base, hdr.Linkname, hdr.Uid, hdr.Gid)
+	}
+
+	return rootfsSyncEntryError{hdr.Name, fmt.Sprintf("unsupported file type '%c'", hdr.Typeflag)}
+}
+
+func (s *rootfsSync) syncDir(name string, dirfd int, base string, mode uint32, uid int, gid int) error {
+	var st unix.Stat_t
+	err := unix.Fstatat(dirfd, base, &st, unix.AT_SYMLINK_NOFOLLOW)
+	if err == unix.ENOENT {
+		s.result.Added++
+		return s.mkdir(dirfd, base, mode, uid, gid)
+	} else if err != nil {
+		return err
+	}
+
+	if st.Mode&unix.S_IFMT != unix.S_IFDIR {
+		return rootfsSyncEntryError{name, "a file of another type already exists"}
+	}
+
+	if st.Mode&07777 == mode {
+		s.result.Unchanged++
+		return nil
+	}
+
+	dir, err := s.open(name, dirfd, base, unix.S_IFDIR)
+	if err != nil {
+		return err
+	}
+	defer dir.Close()
+
+	s.result.Modified++
+	return unix.Fchmod(int(dir.Fd()), mode)
+}
+
+// syncFile writes the new content of a file next to it, only renaming it over
+// the existing file if its content differs.
+func (s *rootfsSync) syncFile(name string, dirfd int, base string, content io.Reader, mode uint32, uid int, gid int) error {
+	uid, gid, err := s.shift(name, uid, gid)
+	if err != nil {
+		return err
+	}
+
+	var st unix.Stat_t
+	err = unix.Fstatat(dirfd, base, &st, unix.AT_SYMLINK_NOFOLLOW)
+	exists := err == nil
+	if err != nil && err != unix.ENOENT {
+		return err
+	}
+
+	if exists && st.Mode&unix.S_IFMT == unix.S_IFDIR {
+		return rootfsSyncEntryError{name, "a directory already exists"}
+	}
+
+	tempName, err := s.tempName()
+	if err != nil {
+		return err
+	}
+
+	fd, err := unix.Openat(dirfd, tempName, unix.O_WRONLY|unix.O_CREAT|unix.O_EXCL|unix.O_NOFOLLOW|unix.O_CLOEXEC, 0600)
+	if err != nil {
+		return err
+	}
+
+	renamed := false
+	temp := os.NewFile(uintptr(fd), tempName)
+	defer func() {
+		temp.Close()
+		if !renamed {
+			unix.Unlinkat(dirfd, tempName, 0)
+		}
+	}()
+
+	hash := xxhash.New()
+	_, err = io.Copy(io.MultiWriter(temp, hash), content)
+	if err != nil {
+		return err
+	}
+
+	if exists && st.Mode&unix.S_IFMT == unix.S_IFREG {
+		old, err := s.open(name, dirfd, base, unix.S_IFREG)
+		if err != nil {
+			return err
+		}
+		defer old.Close()
+
+		oldHash, err := rootfsSyncHash(old)
+		if err != nil {
+			return err
+		}
+
+		if bytes.Equal(oldHash, hash.Sum(nil)) {
+			if st.Mode&07777 == mode {
+				s.result.Unchanged++
+				return nil
+			}
+
+			s.result.Modified++
+			return unix.Fchmod(int(old.Fd()), mode)
+		}
+	}
+
+	err = s.setOwnerMode(temp, mode, uid, gid)
+	if err != nil {
+		return err
+	}
+
+	err = unix.Renameat(dirfd, tempName, dirfd, base)
+	if err != nil {
+		return err
+	}
+	renamed = true
+
+	if exists {
+		s.result.Modified++
+	} else {
+		s.result.Added++
+	}
+
+	return nil
+}
+
+func (s *rootfsSync) syncSymlink(name string, dirfd int, base string, target string, uid int, gid int) error {
+	uid, gid, err := s.shift(name, uid, gid)
+	if err != nil {
+		return err
+	}
+
+	var st unix.Stat_t
+	err = unix.Fstatat(dirfd, base, &st, unix.AT_SYMLINK_NOFOLLOW)
+	exists := err == nil
+	if err != nil && err != unix.ENOENT {
+		return err
+	}
+
+	if exists && st.Mode&unix.S_IFMT == unix.S_IFDIR {
+		return rootfsSyncEntryError{name, "a directory already exists"}
+	}
+
+	if exists && st.Mode&unix.S_IFMT == unix.S_IFLNK {
+		buf := make([]byte, unix.PathMax)
+		n, err := unix.Readlinkat(dirfd, base, buf)
+		if err != nil {
+			return err
+		}
+
+		if string(buf[:n]) == target {
+			s.result.Unchanged++
+			return nil
+		}
+	}
+
+	tempName, err := s.tempName()
+	if err != nil {
+		return err
+	}
+
+	err = unix.Symlinkat(target, dirfd, tempName)
+	if err != nil {
+		return err
+	}
+
+	err = unix.Fchownat(dirfd, tempName, uid, gid, unix.AT_SYMLINK_NOFOLLOW)
+	if err == nil {
+		err = unix.Renameat(dirfd, tempName, dirfd, base)
+	}
+	if err != nil {
+		unix.Unlinkat(dirfd, tempName, 0)
+		return err
+	}
+
+	if exists {
+		s.result.Modified++
+	} else {
+		s.result.Added++
+	}
+
+	return nil
+}
+
+// rootfsSyncHash returns the xxhash of the content of a file.
+func rootfsSyncHash(f *os.File) ([]byte, error) {
+	hash := xxhash.New()
+	_, err := io.Copy(hash, f)
+	if err != nil {
+		return nil, err
+	}
+
+	return hash.Sum(nil), nil
+}
+
+func containerRootfsSyncPost(d *Daemon, r *http.Request) Response {
+	project := projectParam(r)
+	name := mux.Vars(r)["name"]
+
+	response, err := ForwardedResponseIfContainerIsRemote(d, r, project, name)
+	if err != nil {
+		return SmartError(err)
+	}
+	if response != nil {
+		return response
+	}
+
+	c, err := containerLoadByProjectAndName(d.State(), project, name)
+	if err != nil {
+		return SmartError(err)
+	}
+
+	base := r.FormValue("path")
+	if base == "" {
+		base = "/"
+	}
+
+	snapshotName := r.FormValue("snapshot")
+	if strings.Contains(snapshotName, "/") {
+		return BadRequest(fmt.Errorf("Snapshot names may not contain slashes"))
+	}
+
+	resp := containerQuotaCheck(d, c)
+	if resp != nil {
+		return resp
+	}
+
+	ourStart, err := c.StorageStart()
+	if err != nil {
+		return InternalError(err)
+	}
+	if ourStart {
+		defer c.StorageStop()
+	}
+
+	// Take the snapshot to roll back to if the sync doesn't go as planned
+	if snapshotName != "" {
+		expiry, err := shared.GetSnapshotExpiry(time.Now(), c.LocalConfig()["snapshots.expiry"])
+		if err != nil {
+			return BadRequest(err)
+		}
+
+		args := db.ContainerArgs{
+			Project:      c.Project(),
+			Architecture: c.Architecture(),
+			Config:       c.LocalConfig(),
+			Ctype:        db.CTypeSnapshot,
+			Devices:      c.LocalDevices(),
+			Ephemeral:    c.IsEphemeral(),
+			Name:         name + shared.SnapshotDelimiter + snapshotName,
+			Profiles:     c.Profiles(),
+			ExpiryDate:   expiry,
+		}
+
+		_, err = containerCreateAsSnapshot(d.State(), args, c)
+		if err != nil {
+			return SmartError(err)
+		}
+	}
+
+	idmapset, err := c.DiskIdmap()
+	if err != nil {
+		return InternalError(err)
+	}
+
+	sync := &rootfsSync{rootfs: c.RootfsPath(), base: base, idmapset: idmapset}
+	err = sync.Apply(tar.NewReader(r.Body))
+	if err != nil {
+		_, ok := err.(rootfsSyncEntryError)
+		if ok || err == tar.ErrHeader {
+			return BadRequest(err)
+		}
+
+		return InternalError(err)
+	}
+
+	sync.result.Snapshot = snapshotName
+	return SyncResponse(true, sync.result)
+}
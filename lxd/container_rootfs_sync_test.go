@@ -0,0 +1,116 @@
+package main
+
+import (
+	"archive/tar"
+	"bytes"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/lxc/lxd/shared"
+	"github.com/lxc/lxd/shared/idmap"
+)
+
+func rootfsSyncTarball(t *testing.T, files map[string]string) *tar.Reader {
+	buf := &bytes.Buffer{}
+	tw := tar.NewWriter(buf)
+
+	for _, name := range []string{"etc/", "etc/hostname", "etc/hosts", "etc/motd", "etc/localtime"} {
+		content, ok := files[name]
+		if !ok {
+			continue
+		}
+
+		hdr := &tar.Header{Name: name, Mode: 0644, Uid: os.Getuid(), Gid: os.Getgid(), Size: int64(len(content)), Typeflag: tar.TypeReg}
+		if name == "etc/" {
+			hdr.Mode = 0755
+			hdr.Size = 0
+			hdr.Typeflag = tar.TypeDir
+		} else if name == "etc/localtime" {
+			hdr.Size = 0
+			hdr.Typeflag = tar.TypeSymlink
+			hdr.Linkname = content
+		}
+
+		require.NoError(t, tw.WriteHeader(hdr))
+		if hdr.Typeflag == tar.TypeReg {
+			_, err := tw.Write([]byte(content))
+			require.NoError(t, err)
+		}
+	}
+	require.NoError(t, tw.Close())
+
+	return tar.NewReader(buf)
+}
+
+func TestRootfsSyncApply(t *testing.T) {
+	rootfs, err := ioutil.TempDir("", "lxd_rootfs_sync_")
+	require.NoError(t, err)
+	defer os.RemoveAll(rootfs)
+
+	require.NoError(t, os.Mkdir(filepath.Join(rootfs, "etc"), 0755))
+	require.NoError(t, ioutil.WriteFile(filepath.Join(rootfs, "etc", "hostname"), []byte("c1\n"), 0644))
+	require.NoError(t, ioutil.WriteFile(filepath.Join(rootfs, "etc", "hosts"), []byte("127.0.0.1 localhost\n"), 0644))
+
+	sync := &rootfsSync{rootfs: rootfs, base: "/"}
+	err = sync.Apply(rootfsSyncTarball(t, map[string]string{
+		"etc/":          "",
+		"etc/hostname":  "c1\n",
+		"etc/hosts":     "127.0.0.1 localhost c1\n",
+		"etc/motd":      "Hello\n",
+		"etc/localtime": "/usr/share/zoneinfo/UTC",
+	}))
+	require.NoError(t, err)
+
+	assert.Equal(t, int64(2), sync.result.Added)
+	assert.Equal(t, int64(1), sync.result.Modified)
+	assert.Equal(t, int64(2), sync.result.Unchanged)
+
+	content, err := ioutil.ReadFile(filepath.Join(rootfs, "etc", "hosts"))
+	require.NoError(t, err)
+	assert.Equal(t, "127.0.0.1 localhost c1\n", string(content))
+
+	target, err := os.Readlink(filepath.Join(rootfs, "etc", "localtime"))
+	require.NoError(t, err)
+	assert.Equal(t, "/usr/share/zoneinfo/UTC", target)
+
+	// No temporary file is left behind
+	entries, err := ioutil.ReadDir(filepath.Join(rootfs, "etc"))
+	require.NoError(t, err)
+	assert.Len(t, entries, 4)
+}
+
+func TestRootfsSyncSymlinkParent(t *testing.T) {
+	rootfs, err := ioutil.TempDir("", "lxd_rootfs_sync_")
+	require.NoError(t, err)
+	defer os.RemoveAll(rootfs)
+
+	require.NoError(t, os.Symlink("/", filepath.Join(rootfs, "etc")))
+
+	sync := &rootfsSync{rootfs: rootfs, base: "/"}
+	err = sync.Apply(rootfsSyncTarball(t, map[string]string{"etc/motd": "Hello\n"}))
+	assert.IsType(t, rootfsSyncEntryError{}, err)
+}
+
+func TestRootfsSyncUnmappedIds(t *testing.T) {
+	rootfs, err := ioutil.TempDir("", "lxd_rootfs_sync_")
+	require.NoError(t, err)
+	defer os.RemoveAll(rootfs)
+
+	require.NoError(t, os.Mkdir(filepath.Join(rootfs, "etc"), 0755))
+
+	buf := &bytes.Buffer{}
+	tw := tar.NewWriter(buf)
+	require.NoError(t, tw.WriteHeader(&tar.Header{Name: "etc/motd", Mode: 0644, Uid: 70000, Gid: 0, Size: 0, Typeflag: tar.TypeReg}))
+	require.NoError(t, tw.Close())
+
+	idmapset := &idmap.IdmapSet{Idmap: []idmap.IdmapEntry{{Isuid: true, Isgid: true, Hostid: 100000, Nsid: 0, Maprange: 65536}}}
+	sync := &rootfsSync{rootfs: rootfs, base: "/", idmapset: idmapset}
+	err = sync.Apply(tar.NewReader(buf))
+	assert.IsType(t, rootfsSyncEntryError{}, err)
+	assert.False(t, shared.PathExists(filepath.Join(rootfs, "etc", "motd")))
+}
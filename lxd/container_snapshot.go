@@ -137,11 +137,20 @@ func containerSnapshotsPost(d *Daemon, r *http.Request) Response {
 		}
 	}
 
+	config := c.LocalConfig()
+	if req.Keep {
+		config = map[string]string{}
+		for k, v := range c.LocalConfig() {
+			config[k] = v
+		}
+		config[snapshotKeepConfigKey] = "true"
+	}
+
 	snapshot := func(op *operation) error {
 		args := db.ContainerArgs{
 			Project:      c.Project(),
 			Architecture: c.Architecture(),
-			Config:       c.LocalConfig(),
+			Config:       config,
 			Ctype:        db.CTypeSnapshot,
 			Devices:      c.LocalDevices(),
 			Ephemeral:    c.IsEphemeral(),
@@ -156,6 +165,19 @@ func containerSnapshotsPost(d *Daemon, r *http.Request) Response {
 			return err
 		}
 
+		// Index the snapshot in the build cache
+		if req.Cache {
+			step := req.CacheStep
+			if step == "" {
+				step = req.Name
+			}
+
+			err = buildCacheStore(d, r, op, c, fullName, step)
+			if err != nil {
+				return err
+			}
+		}
+
 		return nil
 	}
 
@@ -227,9 +249,10 @@ func snapshotPut(d *Daemon, r *http.Request, sc container, name string) Response
 
 	var do func(op *operation) error
 
-	_, err = rj.GetString("expires_at")
-	if err != nil {
-		// Skip updating the snapshot since the requested key wasn't provided
+	_, expiryErr := rj.GetString("expires_at")
+	_, keepErr := rj.GetBool("keep")
+	if expiryErr != nil && keepErr != nil {
+		// Skip updating the snapshot since none of the requested keys were provided
 		do = func(op *operation) error {
 			return nil
 		}
@@ -246,17 +269,35 @@ func snapshotPut(d *Daemon, r *http.Request, sc container, name string) Response
 			return BadRequest(err)
 		}
 
+		expiry := sc.ExpiryDate()
+		if expiryErr == nil {
+			expiry = configRaw.ExpiresAt
+		}
+
+		config := map[string]string{}
+		for k, v := range sc.LocalConfig() {
+			config[k] = v
+		}
+
+		if keepErr == nil {
+			if configRaw.Keep {
+				config[snapshotKeepConfigKey] = "true"
+			} else {
+				delete(config, snapshotKeepConfigKey)
+			}
+		}
+
 		// Update container configuration
 		do = func(op *operation) error {
 			args := db.ContainerArgs{
 				Architecture: sc.Architecture(),
-				Config:       sc.LocalConfig(),
+				Config:       config,
 				Description:  sc.Description(),
 				Devices:      sc.LocalDevices(),
 				Ephemeral:    sc.IsEphemeral(),
 				Profiles:     sc.Profiles(),
 				Project:      sc.Project(),
-				ExpiryDate:   configRaw.ExpiresAt,
+				ExpiryDate:   expiry,
 			}
 
 			err = sc.Update(args, false)
@@ -0,0 +1,62 @@
+package main
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/lxc/lxd/shared/api"
+)
+
+// Default of the hooks.snapshot_timeout key, in seconds
+const containerSnapshotHookTimeout = 30
+
+// Output of a failed snapshot hook included in the error
+const containerSnapshotHookMaxOutput = 1024
+
+// containerSnapshotHook runs the hooks.pre_snapshot_command or
+// hooks.post_snapshot_command of a running container, given the name of the
+// snapshot. Stopped containers have nothing to flush, their hooks aren't run.
+func containerSnapshotHook(c container, key string, snapshot string) error {
+	if !c.IsRunning() {
+		return nil
+	}
+
+	command := c.ExpandedConfig()[key]
+	if command == "" {
+		return nil
+	}
+
+	devNull, err := os.OpenFile(os.DevNull, os.O_RDWR, 0)
+	if err != nil {
+		return err
+	}
+	defer devNull.Close()
+
+	output, err := ioutil.TempFile("", "lxd_snapshot_hook_")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(output.Name())
+	defer output.Close()
+
+	post := api.ContainerExecPost{
+		Command: []string{"/bin/sh", "-c", command},
+		Timeout: int(containerConfigSeconds(c.ExpandedConfig(), "hooks.snapshot_timeout", containerSnapshotHookTimeout) / time.Second),
+	}
+
+	env := execEnvironment(c, map[string]string{"LXD_SNAPSHOT_NAME": snapshot})
+	code, err := execRun(c, post, env, devNull, output)
+	if err != nil {
+		return fmt.Errorf("Failed to run %s: %v", key, err)
+	}
+
+	if code != 0 {
+		stderr, _ := cronReadOutput(output, containerSnapshotHookMaxOutput)
+		return fmt.Errorf("%s failed with exit code %d: %s", key, code, strings.TrimSpace(string(stderr)))
+	}
+
+	return nil
+}
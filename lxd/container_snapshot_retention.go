@@ -0,0 +1,166 @@
+package main
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+
+	"github.com/lxc/lxd/shared"
+	"github.com/lxc/lxd/shared/logger"
+
+	log "github.com/lxc/lxd/shared/log15"
+)
+
+// Config key of the snapshots exempt from the snapshot retention policy
+const snapshotKeepConfigKey = "volatile.snapshot.keep"
+
+// snapshotRetentionEntry holds what the snapshot retention policy looks at
+// in a snapshot.
+type snapshotRetentionEntry struct {
+	CreationDate time.Time
+	ExpiryDate   time.Time
+	Keep         bool
+}
+
+// snapshotRetentionSelect returns the snapshots to delete, given as indexes
+// in the list of snapshots of a container (oldest first) along with the
+// reason for their deletion, to enforce its snapshots.max_count policy and
+// the expiry dates of the snapshots.
+//
+// The expiry date of a snapshot is set from snapshots.expiry when it's
+// created and may be changed or cleared afterwards, so only that date is
+// looked at. Kept snapshots are never deleted and don't count towards
+// snapshots.max_count.
+func snapshotRetentionSelect(entries []snapshotRetentionEntry, maxCount int, now time.Time) map[int]string {
+	result := map[int]string{}
+	remaining := []int{}
+
+	for i, entry := range entries {
+		if entry.Keep {
+			continue
+		}
+
+		if !entry.ExpiryDate.IsZero() && !now.Before(entry.ExpiryDate) {
+			result[i] = "expired"
+			continue
+		}
+
+		remaining = append(remaining, i)
+	}
+
+	if maxCount > 0 && len(remaining) > maxCount {
+		for _, i := range remaining[:len(remaining)-maxCount] {
+			result[i] = "max_count"
+		}
+	}
+
+	return result
+}
+
+// snapshotPrune is a snapshot to delete to enforce the retention policy.
+type snapshotPrune struct {
+	snapshot container
+	reason   string
+}
+
+// containerSnapshotsToPrune returns the snapshots of a container to delete
+// to enforce its retention policy.
+func containerSnapshotsToPrune(c container, now time.Time) ([]snapshotPrune, error) {
+	config := c.LocalConfig()
+
+	maxCount := 0
+	if config["snapshots.max_count"] != "" {
+		var err error
+		maxCount, err = strconv.Atoi(config["snapshots.max_count"])
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	snapshots, err := c.Snapshots()
+	if err != nil {
+		return nil, err
+	}
+
+	sort.SliceStable(snapshots, func(i, j int) bool {
+		return snapshots[i].CreationDate().Before(snapshots[j].CreationDate())
+	})
+
+	entries := []snapshotRetentionEntry{}
+	for _, snapshot := range snapshots {
+		entries = append(entries, snapshotRetentionEntry{
+			CreationDate: snapshot.CreationDate(),
+			ExpiryDate:   snapshot.ExpiryDate(),
+			Keep:         shared.IsTrue(snapshot.LocalConfig()[snapshotKeepConfigKey]),
+		})
+	}
+
+	selected := snapshotRetentionSelect(entries, maxCount, now)
+
+	prunes := []snapshotPrune{}
+	for i, snapshot := range snapshots {
+		reason, ok := selected[i]
+		if ok {
+			prunes = append(prunes, snapshotPrune{snapshot: snapshot, reason: reason})
+		}
+	}
+
+	return prunes, nil
+}
+
+// containerSnapshotsPrune deletes the given snapshots, reporting each
+// deletion as a lifecycle event.
+func containerSnapshotsPrune(prunes []snapshotPrune) error {
+	for _, prune := range prunes {
+		snapshot := prune.snapshot
+
+		err := snapshot.Delete()
+		if err != nil {
+			return errors.Wrapf(err, "Failed to delete snapshot '%s' in project '%s'", snapshot.Name(), snapshot.Project())
+		}
+
+		logger.Info("Pruned container snapshot", log.Ctx{"snapshot": snapshot.Name(), "project": snapshot.Project(), "reason": prune.reason})
+
+		fields := strings.SplitN(snapshot.Name(), shared.SnapshotDelimiter, 2)
+		eventSendLifecycle(snapshot.Project(), "container-snapshot-pruned",
+			fmt.Sprintf("/1.0/containers/%s/snapshots/%s", fields[0], fields[1]), map[string]interface{}{
+				"snapshot_name": snapshot.Name(),
+				"reason":        prune.reason,
+			})
+	}
+
+	return nil
+}
+
+// containerSnapshotsRetention enforces the retention policy of a container
+// right away.
+func containerSnapshotsRetention(c container) error {
+	prunes, err := containerSnapshotsToPrune(c, time.Now())
+	if err != nil {
+		return err
+	}
+
+	return containerSnapshotsPrune(prunes)
+}
+
+// snapshotConfigWithoutKeep returns the configuration of a snapshot to use
+// for a container, without the snapshot-only keys.
+func snapshotConfigWithoutKeep(config map[string]string) map[string]string {
+	_, ok := config[snapshotKeepConfigKey]
+	if !ok {
+		return config
+	}
+
+	result := map[string]string{}
+	for k, v := range config {
+		if k != snapshotKeepConfigKey {
+			result[k] = v
+		}
+	}
+
+	return result
+}
@@ -0,0 +1,44 @@
+package main
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSnapshotRetentionSelect(t *testing.T) {
+	now := time.Date(2019, 5, 20, 12, 0, 0, 0, time.UTC)
+	day := 24 * time.Hour
+
+	entries := []snapshotRetentionEntry{
+		{CreationDate: now.Add(-10 * day)},
+		{CreationDate: now.Add(-9 * day), Keep: true},
+		{CreationDate: now.Add(-5 * day), ExpiryDate: now.Add(day)},
+		{CreationDate: now.Add(-3 * day)},
+		{CreationDate: now.Add(-2 * day)},
+		{CreationDate: now.Add(-day), ExpiryDate: now.Add(-time.Hour)},
+	}
+
+	// Only the snapshots with an expiry date in the past expire, however
+	// old the ones without an expiry date are
+	selected := snapshotRetentionSelect(entries, 0, now)
+	assert.Equal(t, map[int]string{5: "expired"}, selected)
+
+	// The oldest snapshots above snapshots.max_count go, kept ones
+	// being ignored
+	selected = snapshotRetentionSelect(entries, 2, now)
+	assert.Equal(t, map[int]string{0: "max_count", 2: "max_count", 5: "expired"}, selected)
+
+	selected = snapshotRetentionSelect(entries, 1, now)
+	assert.Equal(t, map[int]string{0: "max_count", 2: "max_count", 3: "max_count", 5: "expired"}, selected)
+}
+
+func TestSnapshotConfigWithoutKeep(t *testing.T) {
+	config := map[string]string{"limits.cpu": "2"}
+	assert.Equal(t, config, snapshotConfigWithoutKeep(config))
+
+	config[snapshotKeepConfigKey] = "true"
+	assert.Equal(t, map[string]string{"limits.cpu": "2"}, snapshotConfigWithoutKeep(config))
+	assert.Equal(t, "true", config[snapshotKeepConfigKey])
+}
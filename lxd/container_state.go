@@ -75,6 +75,12 @@ func containerStatePut(d *Daemon, r *http.Request) Response {
 	case shared.Start:
 		opType = db.OperationContainerStart
 		do = func(op *operation) error {
+			// Wait for the containers this one depends on
+			err = containerWaitDependencies(d.State(), c)
+			if err != nil {
+				return err
+			}
+
 			c.SetOperation(op)
 			if err = c.Start(raw.Stateful); err != nil {
 				return err
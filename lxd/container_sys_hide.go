@@ -0,0 +1,67 @@
+package main
+
+import (
+	"fmt"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/lxc/lxd/shared"
+)
+
+// Mount options of the empty tmpfs hiding a directory of /sys
+const sysHideMountOptions = "ro,nosuid,nodev,noexec,mode=0555,create=dir"
+
+// sysHiddenPaths returns the paths of /sys (relative to the given sysfs
+// mount point) hidden from a container by security.hide_firmware and
+// security.hide_module_params. The module parameters are those of the
+// modules loaded on the host.
+func sysHiddenPaths(config map[string]string, sysRoot string) ([]string, error) {
+	paths := []string{}
+
+	if shared.IsTrue(config["security.hide_firmware"]) {
+		paths = append(paths, "firmware")
+	}
+
+	if shared.IsTrue(config["security.hide_module_params"]) {
+		params, err := filepath.Glob(filepath.Join(sysRoot, "module", "*", "parameters"))
+		if err != nil {
+			return nil, err
+		}
+
+		sort.Strings(params)
+		for _, path := range params {
+			rel, err := filepath.Rel(sysRoot, path)
+			if err != nil {
+				return nil, err
+			}
+
+			paths = append(paths, rel)
+		}
+	}
+
+	return paths, nil
+}
+
+// sysHideMountEntries returns the LXC mount entries hiding the given paths of
+// /sys under an empty read-only tmpfs.
+func sysHideMountEntries(paths []string) []string {
+	entries := []string{}
+	for _, path := range paths {
+		entries = append(entries, fmt.Sprintf("tmpfs %s tmpfs %s 0 0", filepath.Join("sys", path), sysHideMountOptions))
+	}
+
+	return entries
+}
+
+// sysHidden returns whether a host path of /sys is hidden by the given paths.
+func sysHidden(paths []string, hostPath string) bool {
+	for _, path := range paths {
+		hidden := filepath.Join("/sys", path)
+		if hostPath == hidden || strings.HasPrefix(hostPath, hidden+"/") {
+			return true
+		}
+	}
+
+	return false
+}
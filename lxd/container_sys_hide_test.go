@@ -0,0 +1,50 @@
+package main
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSysHiddenPaths(t *testing.T) {
+	dir, err := ioutil.TempDir("", "lxd-sys-")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	for _, path := range []string{"module/kvm/parameters", "module/zfs/parameters", "module/loop", "firmware/efi"} {
+		require.NoError(t, os.MkdirAll(filepath.Join(dir, path), 0755))
+	}
+
+	paths, err := sysHiddenPaths(map[string]string{}, dir)
+	require.NoError(t, err)
+	assert.Equal(t, []string{}, paths)
+
+	paths, err = sysHiddenPaths(map[string]string{"security.hide_firmware": "true"}, dir)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"firmware"}, paths)
+
+	paths, err = sysHiddenPaths(map[string]string{"security.hide_firmware": "true", "security.hide_module_params": "true"}, dir)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"firmware", "module/kvm/parameters", "module/zfs/parameters"}, paths)
+}
+
+func TestSysHideMountEntries(t *testing.T) {
+	assert.Equal(t, []string{
+		"tmpfs sys/firmware tmpfs ro,nosuid,nodev,noexec,mode=0555,create=dir 0 0",
+		"tmpfs sys/module/kvm/parameters tmpfs ro,nosuid,nodev,noexec,mode=0555,create=dir 0 0",
+	}, sysHideMountEntries([]string{"firmware", "module/kvm/parameters"}))
+}
+
+func TestSysHidden(t *testing.T) {
+	paths := []string{"firmware"}
+
+	assert.True(t, sysHidden(paths, "/sys/firmware"))
+	assert.True(t, sysHidden(paths, "/sys/firmware/efi/efivars"))
+	assert.False(t, sysHidden(paths, "/sys/firmwarex"))
+	assert.False(t, sysHidden(paths, "/sys/kernel/debug"))
+	assert.False(t, sysHidden([]string{}, "/sys/firmware/efi/efivars"))
+}
@@ -0,0 +1,101 @@
+package main
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"strings"
+	"syscall"
+
+	"github.com/lxc/lxd/shared/logger"
+
+	log "github.com/lxc/lxd/shared/log15"
+)
+
+// Environment variable telling forkstart and forkexec to disable transparent
+// huge pages for the processes of the container
+const thpDisableEnv = "LXD_THP_DISABLE"
+
+// From linux/prctl.h
+const prSetTHPDisable = 41
+
+// thpParseMode returns the active mode out of the content of
+// /sys/kernel/mm/transparent_hugepage/enabled (e.g. "always [madvise] never").
+func thpParseMode(content string) string {
+	for _, field := range strings.Fields(content) {
+		if strings.HasPrefix(field, "[") && strings.HasSuffix(field, "]") {
+			return strings.Trim(field, "[]")
+		}
+	}
+
+	return ""
+}
+
+// thpHostMode returns the transparent huge pages mode of the host.
+func thpHostMode() (string, error) {
+	content, err := ioutil.ReadFile("/sys/kernel/mm/transparent_hugepage/enabled")
+	if err != nil {
+		return "", err
+	}
+
+	mode := thpParseMode(string(content))
+	if mode == "" {
+		return "", fmt.Errorf("Unknown transparent huge pages mode: %s", strings.TrimSpace(string(content)))
+	}
+
+	return mode, nil
+}
+
+// thpDisable disables transparent huge pages for the current process and the
+// ones it will spawn, the setting being kept across fork and exec.
+func thpDisable() error {
+	_, _, errno := syscall.RawSyscall6(syscall.SYS_PRCTL, prSetTHPDisable, 1, 0, 0, 0, 0)
+	if errno != 0 {
+		return fmt.Errorf("Failed to disable transparent huge pages: %v", errno)
+	}
+
+	return nil
+}
+
+// thpDisableIfRequested disables transparent huge pages if asked to by the
+// daemon through the environment.
+func thpDisableIfRequested() error {
+	if os.Getenv(thpDisableEnv) != "1" {
+		return nil
+	}
+
+	// Don't leak it to the container
+	os.Unsetenv(thpDisableEnv)
+
+	return thpDisable()
+}
+
+// thpEnv returns the environment variables to pass to the processes starting
+// or attaching to the container.
+func (c *containerLXC) thpEnv() []string {
+	if c.expandedConfig["linux.thp_mode"] != "never" {
+		return nil
+	}
+
+	return []string{fmt.Sprintf("%s=1", thpDisableEnv)}
+}
+
+// thpCheck warns when the transparent huge pages mode of the container can't
+// be honoured. Only "never" can be enforced per container, the containers not
+// having a sysfs of their own the other modes are host-global.
+func (c *containerLXC) thpCheck() {
+	mode := c.expandedConfig["linux.thp_mode"]
+	if mode == "" || mode == "never" {
+		return
+	}
+
+	hostMode, err := thpHostMode()
+	if err != nil {
+		logger.Warn("Failed to get the transparent huge pages mode of the host", log.Ctx{"container": c.name, "err": err})
+		return
+	}
+
+	if hostMode != mode {
+		logger.Warn("The transparent huge pages mode is host-global, the container uses the one of the host", log.Ctx{"container": c.name, "mode": mode, "host": hostMode})
+	}
+}
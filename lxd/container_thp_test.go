@@ -0,0 +1,14 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestThpParseMode(t *testing.T) {
+	assert.Equal(t, "madvise", thpParseMode("always [madvise] never\n"))
+	assert.Equal(t, "always", thpParseMode("[always] madvise never\n"))
+	assert.Equal(t, "never", thpParseMode("always madvise [never]\n"))
+	assert.Equal(t, "", thpParseMode(""))
+}
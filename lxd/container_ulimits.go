@@ -0,0 +1,111 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"os"
+	"strconv"
+	"syscall"
+
+	"github.com/lxc/lxd/shared"
+)
+
+// Environment variable passing the limits.ulimits of the container to
+// forkexec, as JSON
+const ulimitsEnv = "LXD_ULIMITS"
+
+// ulimitsLXCConfig returns the lxc.prlimit keys and values of limits.ulimits.
+func ulimitsLXCConfig(value string) (map[string]string, error) {
+	ulimits, err := shared.ParseUlimits(value)
+	if err != nil {
+		return nil, err
+	}
+
+	config := map[string]string{}
+	for _, ulimit := range ulimits {
+		config[fmt.Sprintf("lxc.prlimit.%s", ulimit.Type)] = fmt.Sprintf("%s:%s", ulimit.Soft, ulimit.Hard)
+	}
+
+	return config, nil
+}
+
+// ulimitsValidate checks limits.ulimits doesn't conflict with limits.kernel.*
+func ulimitsValidate(config map[string]string) error {
+	ulimits, err := shared.ParseUlimits(config["limits.ulimits"])
+	if err != nil {
+		return err
+	}
+
+	for _, ulimit := range ulimits {
+		key := fmt.Sprintf("limits.kernel.%s", ulimit.Type)
+		if config[key] != "" {
+			return fmt.Errorf("limits.ulimits can't set %s along with %s", ulimit.Type, key)
+		}
+	}
+
+	return nil
+}
+
+func ulimitRlimitValue(value string) uint64 {
+	if value == "unlimited" {
+		return math.MaxUint64
+	}
+
+	limit, _ := strconv.ParseUint(value, 10, 64)
+	return limit
+}
+
+// ulimitsSetIfRequested sets the resource limits of the current process, to be
+// inherited by the ones it spawns, if asked to by the daemon through the
+// environment.
+func ulimitsSetIfRequested() error {
+	value := os.Getenv(ulimitsEnv)
+	if value == "" {
+		return nil
+	}
+
+	// Don't leak it to the container
+	os.Unsetenv(ulimitsEnv)
+
+	ulimits := []shared.ContainerUlimit{}
+	err := json.Unmarshal([]byte(value), &ulimits)
+	if err != nil {
+		return err
+	}
+
+	for _, ulimit := range ulimits {
+		limit := syscall.Rlimit{
+			Cur: ulimitRlimitValue(ulimit.Soft),
+			Max: ulimitRlimitValue(ulimit.Hard),
+		}
+
+		err := syscall.Setrlimit(ulimit.Resource, &limit)
+		if err != nil {
+			return fmt.Errorf("Failed to set the %s limit: %v", ulimit.Type, err)
+		}
+	}
+
+	return nil
+}
+
+// ulimitsEnv returns the environment variables to pass to the processes
+// attaching to the container. The init process only gets the limits.ulimits
+// it was started with, while the attached ones get the current ones.
+func (c *containerLXC) ulimitsEnv() ([]string, error) {
+	if c.expandedConfig["limits.ulimits"] == "" {
+		return nil, nil
+	}
+
+	ulimits, err := shared.ParseUlimits(c.expandedConfig["limits.ulimits"])
+	if err != nil {
+		return nil, err
+	}
+
+	data, err := json.Marshal(ulimits)
+	if err != nil {
+		return nil, err
+	}
+
+	return []string{fmt.Sprintf("%s=%s", ulimitsEnv, data)}, nil
+}
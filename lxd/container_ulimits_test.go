@@ -0,0 +1,47 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestUlimitsLXCConfig(t *testing.T) {
+	config, err := ulimitsLXCConfig("")
+	require.NoError(t, err)
+	assert.Equal(t, map[string]string{}, config)
+
+	config, err = ulimitsLXCConfig("[{type: nofile, soft: 1024, hard: 2048}, {type: RLIMIT_CORE, soft: unlimited}, {type: nproc, soft: 100}]")
+	require.NoError(t, err)
+	assert.Equal(t, map[string]string{
+		"lxc.prlimit.nofile": "1024:2048",
+		"lxc.prlimit.core":   "unlimited:unlimited",
+		"lxc.prlimit.nproc":  "100:100",
+	}, config)
+
+	for _, value := range []string{
+		"nofile=1024",
+		"[{type: foo, soft: 1}]",
+		"[{type: nofile}]",
+		"[{type: nofile, soft: -1}]",
+		"[{type: nofile, soft: 2048, hard: 1024}]",
+		"[{type: nofile, soft: unlimited, hard: 1024}]",
+		"[{type: nofile, soft: 1}, {type: nofile, soft: 2}]",
+	} {
+		_, err := ulimitsLXCConfig(value)
+		assert.Error(t, err, value)
+	}
+}
+
+func TestUlimitsValidate(t *testing.T) {
+	config := map[string]string{
+		"limits.ulimits":        "[{type: nofile, soft: 1024}]",
+		"limits.kernel.nproc":   "100",
+		"limits.kernel.memlock": "unlimited",
+	}
+	assert.NoError(t, ulimitsValidate(config))
+
+	config["limits.kernel.nofile"] = "2048"
+	assert.Error(t, ulimitsValidate(config))
+}
@@ -0,0 +1,164 @@
+package main
+
+import (
+	"fmt"
+	"net"
+	"strconv"
+	"syscall"
+
+	"github.com/vishvananda/netlink"
+
+	"github.com/lxc/lxd/lxd/types"
+)
+
+// vlanDeviceName returns the name of the interface of a vlan device,
+// defaulting to <parent>.<vid>.
+func vlanDeviceName(m types.Device) string {
+	if m["name"] != "" {
+		return m["name"]
+	}
+
+	return fmt.Sprintf("%s.%s", m["parent"], m["vid"])
+}
+
+// vlanDeviceAddress parses the address of a vlan device given in CIDR
+// notation, checking that it's of the expected family.
+func vlanDeviceAddress(key string, value string) (*netlink.Addr, error) {
+	ip, subnet, err := net.ParseCIDR(value)
+	if err != nil {
+		return nil, fmt.Errorf("Invalid %s '%s' (must be in CIDR notation): %v", key, value, err)
+	}
+
+	if (key == "ipv4.address") != (ip.To4() != nil) {
+		return nil, fmt.Errorf("Invalid %s '%s' (wrong address family)", key, value)
+	}
+
+	subnet.IP = ip
+	return &netlink.Addr{IPNet: subnet}, nil
+}
+
+// vlanDeviceValidate checks the configuration of a vlan device.
+func vlanDeviceValidate(m types.Device) error {
+	if m["parent"] == "" {
+		return fmt.Errorf("VLAN device entry is missing the required \"parent\" property")
+	}
+
+	vid, err := strconv.Atoi(m["vid"])
+	if err != nil || vid < 1 || vid > 4094 {
+		return fmt.Errorf("Invalid VLAN ID '%s' (must be between 1 and 4094)", m["vid"])
+	}
+
+	if len(vlanDeviceName(m)) > 15 {
+		return fmt.Errorf("The VLAN interface name '%s' is longer than 15 characters", vlanDeviceName(m))
+	}
+
+	for _, key := range []string{"ipv4.address", "ipv6.address"} {
+		if m[key] == "" {
+			continue
+		}
+
+		_, err := vlanDeviceAddress(key, m[key])
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (c *containerLXC) insertVlanDevice(devName string, m types.Device) error {
+	if !c.IsRunning() {
+		return fmt.Errorf("Can't add VLAN device to stopped container")
+	}
+
+	h, err := networkNetlinkHandle(c)
+	if err != nil {
+		return err
+	}
+	defer h.Delete()
+
+	parent, err := h.LinkByName(m["parent"])
+	if err != nil {
+		return fmt.Errorf("Parent interface '%s' of VLAN device '%s' not found in the container: %v", m["parent"], devName, err)
+	}
+
+	vid, _ := strconv.Atoi(m["vid"])
+	link := &netlink.Vlan{
+		LinkAttrs: netlink.LinkAttrs{
+			Name:        vlanDeviceName(m),
+			ParentIndex: parent.Attrs().Index,
+		},
+		VlanId: vid,
+	}
+
+	err = h.LinkAdd(link)
+	if err != nil {
+		if err == syscall.EEXIST {
+			return fmt.Errorf("Interface '%s' of VLAN device '%s' already exists in the container", link.Name, devName)
+		}
+
+		return fmt.Errorf("Failed to create interface '%s' of VLAN device '%s': %v", link.Name, devName, err)
+	}
+
+	for _, key := range []string{"ipv4.address", "ipv6.address"} {
+		if m[key] == "" {
+			continue
+		}
+
+		addr, err := vlanDeviceAddress(key, m[key])
+		if err == nil {
+			err = h.AddrAdd(link, addr)
+		}
+		if err != nil {
+			h.LinkDel(link)
+			return fmt.Errorf("Failed to set the %s of VLAN device '%s': %v", key, devName, err)
+		}
+	}
+
+	err = h.LinkSetUp(link)
+	if err != nil {
+		h.LinkDel(link)
+		return fmt.Errorf("Failed to bring up interface '%s' of VLAN device '%s': %v", link.Name, devName, err)
+	}
+
+	return nil
+}
+
+func (c *containerLXC) removeVlanDevice(devName string, m types.Device) error {
+	if !c.IsRunning() {
+		return fmt.Errorf("Can't remove VLAN device from stopped container")
+	}
+
+	h, err := networkNetlinkHandle(c)
+	if err != nil {
+		return err
+	}
+	defer h.Delete()
+
+	link, err := h.LinkByName(vlanDeviceName(m))
+	if err != nil {
+		// Already gone along with its parent
+		return nil
+	}
+
+	err = h.LinkDel(link)
+	if err != nil {
+		return fmt.Errorf("Failed to remove interface '%s' of VLAN device '%s': %v", link.Attrs().Name, devName, err)
+	}
+
+	return nil
+}
+
+func (c *containerLXC) startVlanDevices() error {
+	for _, name := range c.expandedDevices.DeviceNames() {
+		m := c.expandedDevices[name]
+		if m["type"] == "vlan" {
+			err := c.insertVlanDevice(name, m)
+			if err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
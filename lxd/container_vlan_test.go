@@ -0,0 +1,59 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/lxc/lxd/lxd/types"
+)
+
+func TestVlanDeviceName(t *testing.T) {
+	assert.Equal(t, "eth0.100", vlanDeviceName(types.Device{"parent": "eth0", "vid": "100"}))
+	assert.Equal(t, "tenant1", vlanDeviceName(types.Device{"parent": "eth0", "vid": "100", "name": "tenant1"}))
+}
+
+func TestVlanDeviceAddress(t *testing.T) {
+	addr, err := vlanDeviceAddress("ipv4.address", "192.0.2.10/24")
+	require.NoError(t, err)
+	assert.Equal(t, "192.0.2.10/24", addr.IPNet.String())
+
+	addr, err = vlanDeviceAddress("ipv6.address", "2001:db8::10/64")
+	require.NoError(t, err)
+	assert.Equal(t, "2001:db8::10/64", addr.IPNet.String())
+
+	_, err = vlanDeviceAddress("ipv4.address", "192.0.2.10")
+	assert.Error(t, err)
+
+	_, err = vlanDeviceAddress("ipv4.address", "2001:db8::10/64")
+	assert.Error(t, err)
+
+	_, err = vlanDeviceAddress("ipv6.address", "192.0.2.10/24")
+	assert.Error(t, err)
+}
+
+func TestVlanDeviceValidate(t *testing.T) {
+	cases := []struct {
+		device types.Device
+		valid  bool
+	}{
+		{types.Device{"parent": "eth0", "vid": "100"}, true},
+		{types.Device{"parent": "eth0", "vid": "4094", "ipv4.address": "192.0.2.10/24", "ipv6.address": "2001:db8::10/64"}, true},
+		{types.Device{"vid": "100"}, false},
+		{types.Device{"parent": "eth0"}, false},
+		{types.Device{"parent": "eth0", "vid": "0"}, false},
+		{types.Device{"parent": "eth0", "vid": "4095"}, false},
+		{types.Device{"parent": "eth0", "vid": "100", "name": "averyveryverylongname"}, false},
+		{types.Device{"parent": "eth0", "vid": "100", "ipv4.address": "invalid"}, false},
+	}
+
+	for _, c := range cases {
+		err := vlanDeviceValidate(c.device)
+		if c.valid {
+			assert.NoError(t, err, c.device)
+		} else {
+			assert.Error(t, err, c.device)
+		}
+	}
+}
@@ -0,0 +1,160 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/lxc/lxd/lxd/task"
+	"github.com/lxc/lxd/shared/logger"
+
+	log "github.com/lxc/lxd/shared/log15"
+)
+
+// containerRestart tracks the automatic restarts of a container.
+type containerRestart struct {
+	project string
+	name    string
+
+	retries int
+	next    time.Time
+	last    time.Time
+	pending bool
+}
+
+// Longest delay before restarting a container, however many retries it took
+const containerRestartMaxDelay = time.Hour
+
+var containerRestartsLock sync.Mutex
+var containerRestarts = map[string]*containerRestart{}
+
+// containerRestartPolicy returns the restart policy, maximum number of
+// retries and base interval between retries of a container.
+func containerRestartPolicy(config map[string]string) (string, int, time.Duration) {
+	policy := config["restart.policy"]
+	if policy == "" {
+		policy = "never"
+	}
+
+	maxRetries := 5
+	if config["restart.max_retries"] != "" {
+		value, err := strconv.Atoi(config["restart.max_retries"])
+		if err == nil {
+			maxRetries = value
+		}
+	}
+
+	interval := 5 * time.Second
+	if config["restart.interval"] != "" {
+		value, err := time.ParseDuration(config["restart.interval"])
+		if err == nil && value > 0 {
+			interval = value
+		}
+	}
+
+	return policy, maxRetries, interval
+}
+
+// containerRestartDelay returns the delay before a restart of a container,
+// doubling the base interval after every retry.
+func containerRestartDelay(interval time.Duration, retries int) time.Duration {
+	if interval <= 0 {
+		return 0
+	}
+
+	// Past 62 doublings any interval exceeds the maximum delay
+	if retries > 62 || interval > containerRestartMaxDelay>>uint(retries) {
+		return containerRestartMaxDelay
+	}
+
+	return interval << uint(retries)
+}
+
+// containerWatchdogStopped is called whenever a container stops. Containers
+// which weren't stopped through LXD are scheduled for a restart according to
+// their restart policy, those with the on-failure policy only if they failed.
+func containerWatchdogStopped(c container, requested bool, failed bool) {
+	key := fmt.Sprintf("%s/%s", c.Project(), c.Name())
+
+	policy, maxRetries, interval := containerRestartPolicy(c.ExpandedConfig())
+	maxDelay := containerRestartDelay(interval, maxRetries)
+
+	containerRestartsLock.Lock()
+	defer containerRestartsLock.Unlock()
+
+	if requested || policy == "never" || (policy == "on-failure" && !failed) || c.IsEphemeral() {
+		delete(containerRestarts, key)
+		return
+	}
+
+	restart, ok := containerRestarts[key]
+	if !ok {
+		restart = &containerRestart{project: c.Project(), name: c.Name()}
+		containerRestarts[key] = restart
+	}
+
+	// Start counting again if the container has been running for longer
+	// than the longest backoff since its last restart
+	if !restart.last.IsZero() && time.Since(restart.last) > maxDelay {
+		restart.retries = 0
+	}
+
+	if restart.retries >= maxRetries {
+		logger.Warn("Container reached its maximum number of restarts", log.Ctx{"container": c.Name(), "project": c.Project(), "retries": restart.retries})
+		eventSendLifecycle(c.Project(), "container-restart-failed",
+			fmt.Sprintf("/1.0/containers/%s", c.Name()), map[string]interface{}{"retries": restart.retries})
+		delete(containerRestarts, key)
+		return
+	}
+
+	restart.next = time.Now().Add(containerRestartDelay(interval, restart.retries))
+	restart.pending = true
+}
+
+// containerWatchdogTask restarts the containers whose restart is due.
+func containerWatchdogTask(d *Daemon) (task.Func, task.Schedule) {
+	f := func(ctx context.Context) {
+		due := []*containerRestart{}
+
+		containerRestartsLock.Lock()
+		for _, restart := range containerRestarts {
+			if restart.pending && time.Now().After(restart.next) {
+				restart.pending = false
+				restart.retries++
+				restart.last = time.Now()
+				due = append(due, restart)
+			}
+		}
+		containerRestartsLock.Unlock()
+
+		for _, restart := range due {
+			c, err := containerLoadByProjectAndName(d.State(), restart.project, restart.name)
+			if err != nil {
+				// The container is gone
+				containerRestartsLock.Lock()
+				delete(containerRestarts, fmt.Sprintf("%s/%s", restart.project, restart.name))
+				containerRestartsLock.Unlock()
+				continue
+			}
+
+			if c.IsRunning() {
+				continue
+			}
+
+			logger.Info("Restarting container", log.Ctx{"container": c.Name(), "project": c.Project(), "retry": restart.retries})
+			err = c.Start(false)
+			if err != nil {
+				logger.Error("Failed to restart container", log.Ctx{"container": c.Name(), "project": c.Project(), "err": err})
+
+				// Count this as another failure
+				containerWatchdogStopped(c, false, true)
+			}
+		}
+	}
+
+	schedule := task.Every(time.Second)
+
+	return f, schedule
+}
@@ -0,0 +1,19 @@
+package main
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestContainerRestartDelay(t *testing.T) {
+	assert.Equal(t, 5*time.Second, containerRestartDelay(5*time.Second, 0))
+	assert.Equal(t, 40*time.Second, containerRestartDelay(5*time.Second, 3))
+
+	// Capped rather than overflowing
+	assert.Equal(t, containerRestartMaxDelay, containerRestartDelay(5*time.Second, 100))
+	assert.Equal(t, containerRestartMaxDelay, containerRestartDelay(2*time.Hour, 0))
+	assert.Equal(t, containerRestartMaxDelay, containerRestartDelay(time.Nanosecond, 1<<31))
+	assert.Equal(t, time.Duration(0), containerRestartDelay(0, 3))
+}
@@ -135,11 +135,29 @@ func containersRestart(s *state.State) error {
 	containers := []container{}
 
 	for _, c := range result {
+		// Ephemeral containers are never restarted, the deletion of
+		// those which failed to be deleted once stopped being retried
+		if c.IsEphemeral() {
+			if !c.IsRunning() && shared.IsTrue(c.LocalConfig()["volatile.ephemeral.delete_failed"]) {
+				err := c.Delete()
+				if err != nil {
+					logger.Errorf("Failed to delete ephemeral container '%s': %v", c.Name(), err)
+				}
+			}
+
+			continue
+		}
+
 		containers = append(containers, c)
 	}
 
 	sort.Sort(containerAutostartList(containers))
 
+	// Start dependencies first
+	containers = containerDependencyOrder(containers)
+
+	// Containers with dependencies wait for them in the background, not
+	// holding up the start of the others nor the daemon becoming ready
 	// Restart the containers
 	for _, c := range containers {
 		config := c.ExpandedConfig()
@@ -148,11 +166,31 @@ func containersRestart(s *state.State) error {
 		autoStart := config["boot.autostart"]
 		autoStartDelay := config["boot.autostart.delay"]
 
-		if shared.IsTrue(autoStart) || (autoStart == "" && lastState == "RUNNING") {
+		// Containers with an "always" restart policy are always started
+		restartAlways := config["restart.policy"] == "always"
+
+		if shared.IsTrue(autoStart) || (autoStart == "" && lastState == "RUNNING") || restartAlways {
 			if c.IsRunning() {
 				continue
 			}
 
+			if len(containerDependencies(config)) > 0 {
+				go func(c container) {
+					err := containerWaitDependencies(s, c)
+					if err != nil {
+						logger.Errorf("Failed to start container '%s': %v", c.Name(), err)
+						return
+					}
+
+					err = c.Start(false)
+					if err != nil {
+						logger.Errorf("Failed to start container '%s': %v", c.Name(), err)
+					}
+				}(c)
+
+				continue
+			}
+
 			err = c.Start(false)
 			if err != nil {
 				logger.Errorf("Failed to start container '%s': %v", c.Name(), err)
@@ -3,8 +3,10 @@ package main
 import (
 	"fmt"
 	"net/http"
+	"net/url"
 	"sort"
 	"strconv"
+	"strings"
 	"sync"
 	"time"
 
@@ -19,10 +21,15 @@ import (
 )
 
 func containersGet(d *Daemon, r *http.Request) Response {
+	filter, err := containersGetQuery(r)
+	if err != nil {
+		return BadRequest(err)
+	}
+
 	for i := 0; i < 100; i++ {
-		result, err := doContainersGet(d, r)
+		result, total, err := doContainersGet(d, r, filter)
 		if err == nil {
-			return SyncResponse(true, result)
+			return SyncResponseHeaders(true, result, containersGetHeaders(r, filter, total))
 		}
 		if !query.IsRetriableError(err) {
 			logger.Debugf("DBERR: containersGet: error %q", err)
@@ -38,7 +45,96 @@ func containersGet(d *Daemon, r *http.Request) Response {
 	return InternalError(fmt.Errorf("DB is locked"))
 }
 
-func doContainersGet(d *Daemon, r *http.Request) (interface{}, error) {
+// containersGetQuery parses the filtering and pagination parameters of a
+// containers list request.
+func containersGetQuery(r *http.Request) (db.ContainersQuery, error) {
+	filter := db.ContainersQuery{Config: map[string]string{}}
+	values := r.URL.Query()
+
+	for _, label := range values["label"] {
+		fields := strings.SplitN(label, "=", 2)
+		if len(fields) != 2 || fields[0] == "" {
+			return filter, fmt.Errorf("Invalid label filter: %s", label)
+		}
+
+		value, ok := filter.Config[fields[0]]
+		if ok && value != fields[1] {
+			return filter, fmt.Errorf("Conflicting label filters for %s", fields[0])
+		}
+
+		filter.Config[fields[0]] = fields[1]
+	}
+
+	status := values.Get("status")
+	if status != "" {
+		filter.Status = strings.ToUpper(status)
+		if !shared.StringInSlice(filter.Status, []string{"RUNNING", "STOPPED"}) {
+			return filter, fmt.Errorf("Invalid status filter: %s", status)
+		}
+	}
+
+	filter.Image = values.Get("image")
+	filter.Node = values.Get("node")
+
+	for _, key := range []string{"limit", "offset"} {
+		if values.Get(key) == "" {
+			continue
+		}
+
+		value, err := strconv.Atoi(values.Get(key))
+		if err != nil || value < 0 {
+			return filter, fmt.Errorf("Invalid %s: %s", key, values.Get(key))
+		}
+
+		if key == "limit" {
+			filter.Limit = value
+		} else {
+			filter.Offset = value
+		}
+	}
+
+	return filter, nil
+}
+
+// containersGetHeaders returns the total count and pagination links of a
+// containers list response.
+func containersGetHeaders(r *http.Request, filter db.ContainersQuery, total int) map[string]string {
+	headers := map[string]string{"X-Total-Count": fmt.Sprintf("%d", total)}
+	if filter.Limit == 0 {
+		return headers
+	}
+
+	link := func(offset int, rel string) string {
+		values := r.URL.Query()
+		values.Set("offset", fmt.Sprintf("%d", offset))
+		values.Set("limit", fmt.Sprintf("%d", filter.Limit))
+
+		u := url.URL{Path: r.URL.Path, RawQuery: values.Encode()}
+		return fmt.Sprintf("<%s>; rel=\"%s\"", u.String(), rel)
+	}
+
+	links := []string{}
+	if filter.Offset+filter.Limit < total {
+		links = append(links, link(filter.Offset+filter.Limit, "next"))
+	}
+
+	if filter.Offset > 0 {
+		prev := filter.Offset - filter.Limit
+		if prev < 0 {
+			prev = 0
+		}
+
+		links = append(links, link(prev, "prev"))
+	}
+
+	if len(links) > 0 {
+		headers["Link"] = strings.Join(links, ", ")
+	}
+
+	return headers
+}
+
+func doContainersGet(d *Daemon, r *http.Request, filter db.ContainersQuery) (interface{}, int, error) {
 	resultString := []string{}
 	resultList := []*api.Container{}
 	resultFullList := []*api.ContainerFull{}
@@ -58,10 +154,11 @@ func doContainersGet(d *Daemon, r *http.Request) (interface{}, error) {
 	// Get the list and location of all containers
 	var result map[string][]string // Containers by node address
 	var nodes map[string]string    // Node names by container
+	var total int
 	err = d.cluster.Transaction(func(tx *db.ClusterTx) error {
 		var err error
 
-		result, err = tx.ContainersListByNodeAddress(project)
+		result, total, err = tx.ContainersQueryByNodeAddress(project, filter)
 		if err != nil {
 			return err
 		}
@@ -74,7 +171,7 @@ func doContainersGet(d *Daemon, r *http.Request) (interface{}, error) {
 		return nil
 	})
 	if err != nil {
-		return []string{}, err
+		return []string{}, -1, err
 	}
 
 	// Get the local containers
@@ -82,7 +179,7 @@ func doContainersGet(d *Daemon, r *http.Request) (interface{}, error) {
 	if recursion > 0 {
 		cts, err := containerLoadNodeProjectAll(d.State(), project)
 		if err != nil {
-			return nil, err
+			return nil, -1, err
 		}
 
 		for _, ct := range cts {
@@ -161,6 +258,11 @@ func doContainersGet(d *Daemon, r *http.Request) (interface{}, error) {
 					}
 
 					for _, c := range cs {
+						// Only keep the containers matching the filter
+						if !shared.StringInSlice(c.Name, containers) {
+							continue
+						}
+
 						resultListAppend(c.Name, c, nil)
 					}
 
@@ -177,6 +279,10 @@ func doContainersGet(d *Daemon, r *http.Request) (interface{}, error) {
 				}
 
 				for _, c := range cs {
+					if !shared.StringInSlice(c.Name, containers) {
+						continue
+					}
+
 					resultFullListAppend(c.Name, c, nil)
 				}
 			}(address, containers)
@@ -240,7 +346,7 @@ func doContainersGet(d *Daemon, r *http.Request) (interface{}, error) {
 	wg.Wait()
 
 	if recursion == 0 {
-		return resultString, nil
+		return resultString, total, nil
 	}
 
 	if recursion == 1 {
@@ -249,7 +355,7 @@ func doContainersGet(d *Daemon, r *http.Request) (interface{}, error) {
 			return resultList[i].Name < resultList[j].Name
 		})
 
-		return resultList, nil
+		return resultList, total, nil
 	}
 
 	// Sort the result list by name.
@@ -257,7 +363,7 @@ func doContainersGet(d *Daemon, r *http.Request) (interface{}, error) {
 		return resultFullList[i].Name < resultFullList[j].Name
 	})
 
-	return resultFullList, nil
+	return resultFullList, total, nil
 }
 
 // Fetch information about the containers on the given remote node, using the
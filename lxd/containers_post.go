@@ -11,6 +11,7 @@ import (
 	"net/http"
 	"net/url"
 	"os"
+	"strconv"
 	"strings"
 
 	"github.com/dustinkirkland/golang-petname"
@@ -93,6 +94,18 @@ func createFromImage(d *Daemon, project string, req *api.ContainersPost) Respons
 		return BadRequest(fmt.Errorf("Must specify one of alias, fingerprint or properties for init from image"))
 	}
 
+	if req.Source.Server == "" {
+		imageID, info, err := d.cluster.ImageGet(project, hash, false, false)
+		if err != nil {
+			return SmartError(err)
+		}
+
+		err = imageSignatureCheck(d, imageID, info.Fingerprint)
+		if err != nil {
+			return imageSignatureResponse(err)
+		}
+	}
+
 	run := func(op *operation) error {
 		args := db.ContainerArgs{
 			Project:     project,
@@ -117,6 +130,16 @@ func createFromImage(d *Daemon, project string, req *api.ContainersPost) Respons
 			if err != nil {
 				return err
 			}
+
+			imageID, _, err := d.cluster.ImageGet(project, info.Fingerprint, false, false)
+			if err != nil {
+				return err
+			}
+
+			err = imageSignatureCheck(d, imageID, info.Fingerprint)
+			if err != nil {
+				return err
+			}
 		} else {
 			_, info, err = d.cluster.ImageGet(project, hash, false, false)
 			if err != nil {
@@ -124,6 +147,28 @@ func createFromImage(d *Daemon, project string, req *api.ContainersPost) Respons
 			}
 		}
 
+		// Start from the last cached layer of the build steps
+		buildHash := info.Properties["build.hash"]
+		if len(req.Source.BuildSteps) > 0 {
+			var count int
+			info, buildHash, count, err = buildCacheLookup(d, project, info, req.Source.BuildSteps)
+			if err != nil {
+				return err
+			}
+
+			if args.Config == nil {
+				args.Config = map[string]string{}
+			}
+			args.Config["volatile.build.cached_steps"] = strconv.Itoa(count)
+		}
+
+		if buildHash != "" {
+			if args.Config == nil {
+				args.Config = map[string]string{}
+			}
+			args.Config["volatile.build.hash"] = buildHash
+		}
+
 		args.Architecture, err = osarch.ArchitectureId(info.Architecture)
 		if err != nil {
 			return err
@@ -321,7 +366,7 @@ func createFromMigration(d *Daemon, project string, req *api.ContainersPost) Res
 		if err != nil {
 			req.Source.Refresh = false
 		} else if c.IsRunning() {
-			return BadRequest(fmt.Errorf("Cannot refresh a running container"))
+			return BadRequest(withErrorCode(api.ErrContainerRunning, fmt.Errorf("Cannot refresh a running container")))
 		}
 	}
 
@@ -485,6 +530,15 @@ func createFromCopy(d *Daemon, project string, req *api.ContainersPost) Response
 		return BadRequest(fmt.Errorf("must specify a source container"))
 	}
 
+	if !shared.StringInSlice(req.Source.CopyMode, []string{"", "copy", "reflink", "snapshot"}) {
+		return BadRequest(fmt.Errorf("Invalid copy mode \"%s\"", req.Source.CopyMode))
+	}
+
+	// Refreshes always sync the data rather than sharing it with the source
+	if req.Source.Refresh && req.Source.CopyMode != "" && req.Source.CopyMode != "copy" {
+		return BadRequest(fmt.Errorf("The \"%s\" copy mode can't be used to refresh a container", req.Source.CopyMode))
+	}
+
 	sourceProject := req.Source.Project
 	if sourceProject == "" {
 		sourceProject = project
@@ -564,7 +618,7 @@ func createFromCopy(d *Daemon, project string, req *api.ContainersPost) Response
 	}
 
 	run := func(op *operation) error {
-		_, err := containerCreateAsCopy(d.State(), args, source, req.Source.ContainerOnly, req.Source.Refresh)
+		_, err := containerCreateAsCopy(d.State(), args, source, req.Source.ContainerOnly, req.Source.Refresh, req.Source.CopyMode)
 		if err != nil {
 			return err
 		}
@@ -673,7 +727,9 @@ func containersPost(d *Daemon, r *http.Request) Response {
 
 	// If we're getting binary content, process separately
 	if r.Header.Get("Content-Type") == "application/octet-stream" {
-		return createFromBackup(d, project, r.Body, r.Header.Get("X-LXD-pool"))
+		return storageOpsLimit(d, func() Response {
+			return createFromBackup(d, project, r.Body, r.Header.Get("X-LXD-pool"))
+		})
 	}
 
 	// Parse the request
@@ -784,13 +840,19 @@ func containersPost(d *Daemon, r *http.Request) Response {
 
 	switch req.Source.Type {
 	case "image":
-		return createFromImage(d, project, &req)
+		return storageOpsLimit(d, func() Response {
+			return createFromImage(d, project, &req)
+		})
 	case "none":
 		return createFromNone(d, project, &req)
 	case "migration":
-		return createFromMigration(d, project, &req)
+		return storageOpsLimit(d, func() Response {
+			return createFromMigration(d, project, &req)
+		})
 	case "copy":
-		return createFromCopy(d, project, &req)
+		return storageOpsLimit(d, func() Response {
+			return createFromCopy(d, project, &req)
+		})
 	default:
 		return BadRequest(fmt.Errorf("unknown source type %s", req.Source.Type))
 	}
@@ -21,6 +21,7 @@ import (
 	"github.com/gorilla/mux"
 	"github.com/pkg/errors"
 	"golang.org/x/net/context"
+	"gopkg.in/lxc/go-lxc.v2"
 	"gopkg.in/macaroon-bakery.v2/bakery"
 	"gopkg.in/macaroon-bakery.v2/bakery/checkers"
 	"gopkg.in/macaroon-bakery.v2/bakery/identchecker"
@@ -29,6 +30,7 @@ import (
 	"github.com/lxc/lxd/lxd/cluster"
 	"github.com/lxc/lxd/lxd/db"
 	"github.com/lxc/lxd/lxd/endpoints"
+	"github.com/lxc/lxd/lxd/keystore"
 	"github.com/lxc/lxd/lxd/maas"
 	"github.com/lxc/lxd/lxd/node"
 	"github.com/lxc/lxd/lxd/state"
@@ -36,6 +38,7 @@ import (
 	"github.com/lxc/lxd/lxd/task"
 	"github.com/lxc/lxd/lxd/util"
 	"github.com/lxc/lxd/shared"
+	"github.com/lxc/lxd/shared/api"
 	"github.com/lxc/lxd/shared/idmap"
 	"github.com/lxc/lxd/shared/logger"
 	"github.com/lxc/lxd/shared/version"
@@ -60,8 +63,11 @@ type Daemon struct {
 	clusterTasks task.Group
 
 	// Indexes of tasks that need to be reset when their execution interval changes
-	taskPruneImages *task.Task
-	taskAutoUpdate  *task.Task
+	taskPruneImages    *task.Task
+	taskAutoUpdate     *task.Task
+	taskImagesGC       *task.Task
+	taskPruneSnapshots *task.Task
+	taskContainerDrift *task.Task
 
 	config    *DaemonConfig
 	endpoints *endpoints.Endpoints
@@ -70,6 +76,14 @@ type Daemon struct {
 	proxy func(req *http.Request) (*url.URL, error)
 
 	externalAuth *externalAuth
+
+	// LDAP server used to authenticate clients
+	ldapAuth     *ldapAuth
+	ldapAuthLock sync.Mutex
+
+	// Policy engine endpoint used to authorize API requests
+	opaEndpoint     string
+	opaEndpointLock sync.Mutex
 }
 
 type externalAuth struct {
@@ -233,6 +247,18 @@ func (d *Daemon) Authenticate(r *http.Request) (bool, string, error) {
 		return true, "", nil
 	}
 
+	// Validate the client certificate against LDAP, falling back to the
+	// trust store if the server can't be queried
+	ldapAuth := d.ldapAuthGet()
+	if ldapAuth != nil && len(r.TLS.PeerCertificates) > 0 {
+		trusted, username, err := ldapAuth.Authenticate(r.TLS.PeerCertificates[0])
+		if err == nil {
+			return trusted, username, nil
+		}
+
+		logger.Warn("Failed LDAP authentication, using the trust store", log.Ctx{"err": err})
+	}
+
 	// Validate normal TLS access
 	for i := range r.TLS.PeerCertificates {
 		trusted, username := util.CheckTrustState(*r.TLS.PeerCertificates[i], d.clientCerts)
@@ -254,7 +280,7 @@ func writeMacaroonsRequiredResponse(b *identchecker.Bakery, r *http.Request, w h
 	m, err := b.Oven.NewMacaroon(
 		ctx, httpbakery.RequestVersion(r), caveats, derr.Ops...)
 	if err != nil {
-		resp := errorResponse{http.StatusInternalServerError, err.Error()}
+		resp := errorResponse{http.StatusInternalServerError, err.Error(), api.ErrInternal, nil}
 		resp.Render(w)
 		return
 	}
@@ -344,6 +370,24 @@ func (d *Daemon) createCmd(restAPI *mux.Router, version string, c Command) {
 			return
 		}
 
+		// Convert YAML requests to JSON
+		if r.Body != nil && isYAMLRequest(r) {
+			err := yamlRequestBody(r)
+			if err != nil {
+				BadRequest(err).Render(w)
+				return
+			}
+		}
+
+		// Fine-grained authorization
+		if trusted {
+			resp := opaCheck(d, username, r)
+			if resp != nil {
+				resp.Render(w)
+				return
+			}
+		}
+
 		// Dump full request JSON when in debug mode
 		if debug && r.Method != "GET" && isJSONRequest(r) {
 			newBody := &bytes.Buffer{}
@@ -358,8 +402,19 @@ func (d *Daemon) createCmd(restAPI *mux.Router, version string, c Command) {
 			shared.DebugJson(captured)
 		}
 
+		// Replay the response to a retried request
+		idempotency, resp := idempotencyStart(d, r, idempotencyIdentity(d, r, trusted, username))
+		if resp != nil {
+			resp.Render(w)
+			return
+		}
+
+		if idempotency != nil {
+			defer idempotency.Finish()
+			w = idempotency.Writer(w)
+		}
+
 		// Actually process the request
-		var resp Response
 		resp = NotImplemented(nil)
 
 		switch r.Method {
@@ -387,6 +442,11 @@ func (d *Daemon) createCmd(restAPI *mux.Router, version string, c Command) {
 			resp = NotFound(fmt.Errorf("Method '%s' not found", r.Method))
 		}
 
+		// Render JSON responses as YAML if requested
+		if wantsYAML(r) {
+			resp = YAMLResponse(resp)
+		}
+
 		// Handle errors
 		if err := resp.Render(w); err != nil {
 			err := InternalError(err).Render(w)
@@ -515,6 +575,28 @@ func (d *Daemon) init() error {
 		logger.Infof(" - shiftfs support: no")
 	}
 
+	if shared.PathExists("/proc/self/ns/time") && lxc.HasApiExtension("time_namespace") {
+		d.os.TimeNamespace = true
+		logger.Infof(" - time namespace: yes")
+	} else {
+		logger.Infof(" - time namespace: no")
+	}
+
+	// Seccomp user notifications, with the kernel performing the
+	// syscalls which don't need remapping (Linux 5.5)
+	if lxc.HasApiExtension("seccomp_notify") && seccompNotifyContinueSupported() {
+		d.os.SeccompListener = true
+		logger.Infof(" - seccomp listener: yes")
+	} else {
+		logger.Infof(" - seccomp listener: no")
+	}
+
+	/* Load the device plugins */
+	err = devicePluginsLoad()
+	if err != nil {
+		return err
+	}
+
 	/* Initialize the database */
 	dump, err := initializeDbObject(d)
 	if err != nil {
@@ -522,7 +604,23 @@ func (d *Daemon) init() error {
 	}
 
 	/* Setup server certificate */
-	certInfo, err := util.LoadCert(d.os.VarDir)
+	var nodeConfig *node.Config
+	err = d.db.Transaction(func(tx *db.NodeTx) error {
+		var err error
+		nodeConfig, err = node.ConfigLoad(tx)
+		return err
+	})
+	if err != nil {
+		return errors.Wrap(err, "Failed to load node configuration")
+	}
+
+	certInfo, err := serverCertLoadOrDefault(d.os.VarDir, nodeConfig)
+	if err != nil {
+		return err
+	}
+
+	keyStore, _ := nodeConfig.KeyStore()
+	err = keystore.RemoveKey(d.os.VarDir, keyStore)
 	if err != nil {
 		return err
 	}
@@ -726,6 +824,7 @@ func (d *Daemon) init() error {
 	maasAPIURL := ""
 	maasAPIKey := ""
 	maasMachine := ""
+	coredumpCapture := false
 
 	err = d.db.Transaction(func(tx *db.NodeTx) error {
 		config, err := node.ConfigLoad(tx)
@@ -755,23 +854,49 @@ func (d *Daemon) init() error {
 		candidExpiry = config.CandidExpiry()
 		candidDomains = config.CandidDomains()
 		maasAPIURL, maasAPIKey = config.MAASController()
+		eventsSetHistorySize(int(config.EventsBuffer()))
+		debugPprofSetRates(config.DebugPprof())
+		coredumpCapture = config.CoredumpCapture()
+		d.opaEndpointSet(config.OPAEndpoint())
+		d.setupLDAPAuthentication(config)
 		return nil
 	})
 	if err != nil {
 		return err
 	}
 
+	if !d.os.MockMode {
+		err = coredumpSetCapture(d.os.ExecPath, coredumpCapture)
+		if err != nil {
+			logger.Warnf("Failed to set the core pattern: %v", err)
+		}
+	}
+
 	err = d.setupExternalAuthentication(candidEndpoint, candidEndpointKey, candidExpiry, candidDomains)
 	if err != nil {
 		return err
 	}
 
+	// Start delivering events to webhooks
+	go webhooksDispatch(d)
+
+	// Start delivering lifecycle events to the container hooks
+	go containerHooksDispatch(d)
+
 	if !d.os.MockMode {
 		// Start the scheduler
 		go deviceEventListener(d.State())
 
+		// Collect the exit statuses of the container init processes
+		err := containerExitListen()
+		if err != nil {
+			logger.Warn("Failed to listen to the process exit events", log.Ctx{"err": err})
+		} else {
+			containerExitWatchRunning(d.State())
+		}
+
 		// Setup inotify watches
-		_, err := deviceInotifyInit(d.State())
+		_, err = deviceInotifyInit(d.State())
 		if err != nil {
 			return err
 		}
@@ -853,12 +978,18 @@ func (d *Daemon) Ready() error {
 		// Log expiry (daily)
 		d.tasks.Add(expireLogsTask(d.State()))
 
+		// Remove expired idempotency keys (every 5 minutes)
+		d.tasks.Add(pruneExpiredIdempotencyKeysTask(d))
+
 		// Remove expired images (daily)
 		d.taskPruneImages = d.tasks.Add(pruneExpiredImagesTask(d))
 
 		// Auto-update images (every 6 hours, configurable)
 		d.taskAutoUpdate = d.tasks.Add(autoUpdateImagesTask(d))
 
+		// Remove unused images (daily, configurable)
+		d.taskImagesGC = d.tasks.Add(imagesGCTask(d))
+
 		// Auto-update instance types (daily)
 		d.tasks.Add(instanceRefreshTypesTask(d))
 
@@ -868,8 +999,32 @@ func (d *Daemon) Ready() error {
 		// Take snapshot of containers (minutely check of configurable cron expression)
 		d.tasks.Add(autoCreateContainerSnapshotsTask(d))
 
-		// Remove expired container snapshots (minutely)
-		d.tasks.Add(pruneExpiredContainerSnapshotsTask(d))
+		// Enforce the container snapshots retention policy (hourly by default)
+		d.taskPruneSnapshots = d.tasks.Add(pruneExpiredContainerSnapshotsTask(d))
+
+		// Run the scheduled commands of containers (minutely check of their cron expressions)
+		d.tasks.Add(containerCronTask(d))
+
+		// Restart crashed containers (every second)
+		d.tasks.Add(containerWatchdogTask(d))
+
+		// Adjust the memory balloons (configurable interval)
+		d.tasks.Add(containerBalloonTask(d))
+
+		// Report the OOM killer score changes of the containers
+		d.tasks.Add(containerOOMScoreTask(d))
+
+		// Account the network traffic of the containers (every minute)
+		d.tasks.Add(containerBandwidthTask(d))
+
+		// Detect the config drift of the containers (configurable interval)
+		d.taskContainerDrift = d.tasks.Add(containerDriftTask(d))
+
+		// Warn about the containers approaching their open files limit (every minute)
+		d.tasks.Add(containerOpenFilesTask(d))
+
+		// Check the metadata usage of LVM thin pools (every 5 minutes)
+		d.tasks.Add(lvmThinpoolMetadataTask(d))
 	}
 
 	// Start all background tasks
@@ -878,6 +1033,14 @@ func (d *Daemon) Ready() error {
 	// Get daemon state struct
 	s := d.State()
 
+	// Build the pool of infiniband virtual functions
+	if !d.os.MockMode {
+		err = infinibandPool.load(s)
+		if err != nil {
+			logger.Warn("Failed to load the infiniband virtual functions", log.Ctx{"err": err})
+		}
+	}
+
 	// Restore containers
 	containersRestart(s)
 
@@ -271,6 +271,10 @@ func (d *Daemon) ImageDownload(op *operation, server string, protocol string, ce
 		logger.Debug("Image already exists in the db", log.Ctx{"image": fp})
 		info = imgInfo
 
+		if forContainer {
+			imageCacheHit(d, info.Fingerprint)
+		}
+
 		// If not requested in a particular pool, we're done.
 		if storagePool == "" {
 			return info, nil
@@ -326,6 +330,10 @@ func (d *Daemon) ImageDownload(op *operation, server string, protocol string, ce
 			logger.Error("Other image download didn't succeed", log.Ctx{"image": fp})
 		} else {
 			// Other download succeeded, we're done
+			if forContainer {
+				imageCacheHit(d, imgInfo.Fingerprint)
+			}
+
 			return imgInfo, nil
 		}
 	} else {
@@ -355,6 +363,10 @@ func (d *Daemon) ImageDownload(op *operation, server string, protocol string, ce
 	}
 	logger.Info("Downloading image", ctxMap)
 
+	if forContainer {
+		imageCacheMiss()
+	}
+
 	// Cleanup any leftover from a past attempt
 	destDir := shared.VarPath("images")
 	destName := filepath.Join(destDir, fp)
@@ -45,9 +45,19 @@ CREATE TABLE containers_backups (
     expiry_date DATETIME,
     container_only INTEGER NOT NULL default 0,
     optimized_storage INTEGER NOT NULL default 0,
+    xattrs INTEGER NOT NULL DEFAULT 0,
     FOREIGN KEY (container_id) REFERENCES containers (id) ON DELETE CASCADE,
     UNIQUE (container_id, name)
 );
+CREATE TABLE containers_bandwidth (
+    id INTEGER PRIMARY KEY AUTOINCREMENT NOT NULL,
+    container_id INTEGER NOT NULL,
+    date TEXT NOT NULL,
+    rx_bytes INTEGER NOT NULL DEFAULT 0,
+    tx_bytes INTEGER NOT NULL DEFAULT 0,
+    UNIQUE (container_id, date),
+    FOREIGN KEY (container_id) REFERENCES containers (id) ON DELETE CASCADE
+);
 CREATE TABLE containers_config (
     id INTEGER PRIMARY KEY AUTOINCREMENT NOT NULL,
     container_id INTEGER NOT NULL,
@@ -70,6 +80,27 @@ CREATE VIEW containers_config_ref (project,
        JOIN containers ON containers.id=containers_config.container_id
        JOIN projects ON projects.id=containers.project_id
        JOIN nodes ON nodes.id=containers.node_id;
+CREATE TABLE containers_cron_jobs (
+    id INTEGER PRIMARY KEY AUTOINCREMENT NOT NULL,
+    container_id INTEGER NOT NULL,
+    command TEXT NOT NULL,
+    schedule TEXT NOT NULL,
+    timeout INTEGER NOT NULL DEFAULT 0,
+    capture_output INTEGER NOT NULL DEFAULT 0,
+    creation_date DATETIME NOT NULL,
+    FOREIGN KEY (container_id) REFERENCES containers (id) ON DELETE CASCADE
+);
+CREATE TABLE containers_cron_runs (
+    id INTEGER PRIMARY KEY AUTOINCREMENT NOT NULL,
+    job_id INTEGER NOT NULL,
+    start_date DATETIME NOT NULL,
+    end_date DATETIME NOT NULL,
+    exit_code INTEGER NOT NULL,
+    stdout BLOB NOT NULL,
+    stderr BLOB NOT NULL,
+    error TEXT NOT NULL DEFAULT '',
+    FOREIGN KEY (job_id) REFERENCES containers_cron_jobs (id) ON DELETE CASCADE
+);
 CREATE TABLE containers_devices (
     id INTEGER primary key AUTOINCREMENT NOT NULL,
     container_id INTEGER NOT NULL,
@@ -139,6 +170,19 @@ CREATE INDEX containers_project_id_and_node_id_and_name_idx ON containers (proje
 CREATE INDEX containers_project_id_and_node_id_idx ON containers (project_id,
     node_id);
 CREATE INDEX containers_project_id_idx ON containers (project_id);
+CREATE TABLE idempotency_keys (
+    id INTEGER PRIMARY KEY AUTOINCREMENT NOT NULL,
+    key TEXT NOT NULL,
+    username TEXT NOT NULL DEFAULT '',
+    method TEXT NOT NULL,
+    uri TEXT NOT NULL,
+    status_code INTEGER NOT NULL,
+    content_type TEXT NOT NULL DEFAULT '',
+    location TEXT NOT NULL DEFAULT '',
+    body BLOB NOT NULL,
+    expiry_date DATETIME NOT NULL,
+    UNIQUE (key, username)
+);
 CREATE TABLE "images" (
     id INTEGER PRIMARY KEY AUTOINCREMENT NOT NULL,
     fingerprint TEXT NOT NULL,
@@ -153,6 +197,7 @@ CREATE TABLE "images" (
     last_use_date DATETIME,
     auto_update INTEGER NOT NULL DEFAULT 0,
     project_id INTEGER NOT NULL,
+    refcount INTEGER NOT NULL DEFAULT 0,
     UNIQUE (project_id, fingerprint),
     FOREIGN KEY (project_id) REFERENCES projects (id) ON DELETE CASCADE
 );
@@ -184,6 +229,26 @@ CREATE TABLE images_properties (
     value TEXT,
     FOREIGN KEY (image_id) REFERENCES images (id) ON DELETE CASCADE
 );
+CREATE TABLE images_replicas (
+    id INTEGER PRIMARY KEY AUTOINCREMENT NOT NULL,
+    image_id INTEGER NOT NULL,
+    node_id INTEGER NOT NULL,
+    status INTEGER NOT NULL DEFAULT 0,
+    progress INTEGER NOT NULL DEFAULT 0,
+    error TEXT NOT NULL DEFAULT '',
+    updated_at DATETIME NOT NULL,
+    UNIQUE (image_id, node_id),
+    FOREIGN KEY (image_id) REFERENCES images (id) ON DELETE CASCADE,
+    FOREIGN KEY (node_id) REFERENCES nodes (id) ON DELETE CASCADE
+);
+CREATE TABLE images_signatures (
+    id INTEGER PRIMARY KEY AUTOINCREMENT NOT NULL,
+    image_id INTEGER NOT NULL,
+    bundle TEXT NOT NULL DEFAULT '',
+    exempt INTEGER NOT NULL DEFAULT 0,
+    UNIQUE (image_id),
+    FOREIGN KEY (image_id) REFERENCES images (id) ON DELETE CASCADE
+);
 CREATE TABLE images_source (
     id INTEGER PRIMARY KEY AUTOINCREMENT NOT NULL,
     image_id INTEGER NOT NULL,
@@ -210,6 +275,32 @@ CREATE TABLE networks_config (
     FOREIGN KEY (network_id) REFERENCES networks (id) ON DELETE CASCADE,
     FOREIGN KEY (node_id) REFERENCES nodes (id) ON DELETE CASCADE
 );
+CREATE TABLE networks_forwards (
+    id INTEGER PRIMARY KEY AUTOINCREMENT NOT NULL,
+    network_id INTEGER NOT NULL,
+    node_id INTEGER NOT NULL,
+    listen_address TEXT NOT NULL,
+    target_address TEXT NOT NULL,
+    protocol TEXT NOT NULL,
+    description TEXT NOT NULL DEFAULT '',
+    UNIQUE (network_id, node_id, listen_address, protocol),
+    FOREIGN KEY (network_id) REFERENCES networks (id) ON DELETE CASCADE,
+    FOREIGN KEY (node_id) REFERENCES nodes (id) ON DELETE CASCADE
+);
+CREATE TABLE networks_load_balancers (
+    id INTEGER PRIMARY KEY AUTOINCREMENT NOT NULL,
+    network_id INTEGER NOT NULL,
+    node_id INTEGER NOT NULL,
+    listen_address TEXT NOT NULL,
+    protocol TEXT NOT NULL,
+    algorithm TEXT NOT NULL,
+    backends TEXT NOT NULL,
+    health_check_interval INTEGER NOT NULL DEFAULT 0,
+    description TEXT NOT NULL DEFAULT '',
+    UNIQUE (network_id, node_id, listen_address, protocol),
+    FOREIGN KEY (network_id) REFERENCES networks (id) ON DELETE CASCADE,
+    FOREIGN KEY (node_id) REFERENCES nodes (id) ON DELETE CASCADE
+);
 CREATE TABLE networks_nodes (
     id INTEGER PRIMARY KEY AUTOINCREMENT NOT NULL,
     network_id INTEGER NOT NULL,
@@ -404,6 +495,21 @@ CREATE TABLE storage_volumes_config (
     UNIQUE (storage_volume_id, key),
     FOREIGN KEY (storage_volume_id) REFERENCES storage_volumes (id) ON DELETE CASCADE
 );
+CREATE TABLE storage_volumes_encryption_keys (
+    id INTEGER PRIMARY KEY AUTOINCREMENT NOT NULL,
+    storage_volume_id INTEGER NOT NULL,
+    key TEXT NOT NULL,
+    next_key TEXT NOT NULL DEFAULT '',
+    UNIQUE (storage_volume_id),
+    FOREIGN KEY (storage_volume_id) REFERENCES storage_volumes (id) ON DELETE CASCADE
+);
+CREATE TABLE webhooks (
+    id INTEGER PRIMARY KEY AUTOINCREMENT NOT NULL,
+    url TEXT NOT NULL,
+    secret TEXT NOT NULL DEFAULT '',
+    events TEXT NOT NULL DEFAULT '',
+    retry_max INTEGER NOT NULL DEFAULT 3
+);
 
-INSERT INTO schema (version, updated_at) VALUES (14, strftime("%s"))
+INSERT INTO schema (version, updated_at) VALUES (26, strftime("%s"))
 `
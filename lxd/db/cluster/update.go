@@ -47,6 +47,216 @@ var updates = map[int]schema.Update{
 	12: updateFromV11,
 	13: updateFromV12,
 	14: updateFromV13,
+	15: updateFromV14,
+	16: updateFromV15,
+	17: updateFromV16,
+	18: updateFromV17,
+	19: updateFromV18,
+	20: updateFromV19,
+	21: updateFromV20,
+	22: updateFromV21,
+	23: updateFromV22,
+	24: updateFromV23,
+	25: updateFromV24,
+	26: updateFromV25,
+}
+
+func updateFromV25(tx *sql.Tx) error {
+	_, err := tx.Exec("ALTER TABLE storage_volumes_encryption_keys ADD COLUMN next_key TEXT NOT NULL DEFAULT '';")
+	return err
+}
+
+func updateFromV24(tx *sql.Tx) error {
+	stmt := `
+CREATE TABLE images_signatures (
+    id INTEGER PRIMARY KEY AUTOINCREMENT NOT NULL,
+    image_id INTEGER NOT NULL,
+    bundle TEXT NOT NULL DEFAULT '',
+    exempt INTEGER NOT NULL DEFAULT 0,
+    UNIQUE (image_id),
+    FOREIGN KEY (image_id) REFERENCES images (id) ON DELETE CASCADE
+);
+`
+	_, err := tx.Exec(stmt)
+	return err
+}
+
+func updateFromV23(tx *sql.Tx) error {
+	stmt := `
+CREATE TABLE containers_bandwidth (
+    id INTEGER PRIMARY KEY AUTOINCREMENT NOT NULL,
+    container_id INTEGER NOT NULL,
+    date TEXT NOT NULL,
+    rx_bytes INTEGER NOT NULL DEFAULT 0,
+    tx_bytes INTEGER NOT NULL DEFAULT 0,
+    UNIQUE (container_id, date),
+    FOREIGN KEY (container_id) REFERENCES containers (id) ON DELETE CASCADE
+);
+`
+	_, err := tx.Exec(stmt)
+	return err
+}
+
+func updateFromV22(tx *sql.Tx) error {
+	stmt := `
+CREATE TABLE images_replicas (
+    id INTEGER PRIMARY KEY AUTOINCREMENT NOT NULL,
+    image_id INTEGER NOT NULL,
+    node_id INTEGER NOT NULL,
+    status INTEGER NOT NULL DEFAULT 0,
+    progress INTEGER NOT NULL DEFAULT 0,
+    error TEXT NOT NULL DEFAULT '',
+    updated_at DATETIME NOT NULL,
+    UNIQUE (image_id, node_id),
+    FOREIGN KEY (image_id) REFERENCES images (id) ON DELETE CASCADE,
+    FOREIGN KEY (node_id) REFERENCES nodes (id) ON DELETE CASCADE
+);
+`
+	_, err := tx.Exec(stmt)
+	return err
+}
+
+func updateFromV21(tx *sql.Tx) error {
+	stmt := `
+CREATE TABLE containers_cron_jobs (
+    id INTEGER PRIMARY KEY AUTOINCREMENT NOT NULL,
+    container_id INTEGER NOT NULL,
+    command TEXT NOT NULL,
+    schedule TEXT NOT NULL,
+    timeout INTEGER NOT NULL DEFAULT 0,
+    capture_output INTEGER NOT NULL DEFAULT 0,
+    creation_date DATETIME NOT NULL,
+    FOREIGN KEY (container_id) REFERENCES containers (id) ON DELETE CASCADE
+);
+CREATE TABLE containers_cron_runs (
+    id INTEGER PRIMARY KEY AUTOINCREMENT NOT NULL,
+    job_id INTEGER NOT NULL,
+    start_date DATETIME NOT NULL,
+    end_date DATETIME NOT NULL,
+    exit_code INTEGER NOT NULL,
+    stdout BLOB NOT NULL,
+    stderr BLOB NOT NULL,
+    error TEXT NOT NULL DEFAULT '',
+    FOREIGN KEY (job_id) REFERENCES containers_cron_jobs (id) ON DELETE CASCADE
+);
+`
+	_, err := tx.Exec(stmt)
+	return err
+}
+
+func updateFromV20(tx *sql.Tx) error {
+	stmt := `
+CREATE TABLE idempotency_keys (
+    id INTEGER PRIMARY KEY AUTOINCREMENT NOT NULL,
+    key TEXT NOT NULL,
+    username TEXT NOT NULL DEFAULT '',
+    method TEXT NOT NULL,
+    uri TEXT NOT NULL,
+    status_code INTEGER NOT NULL,
+    content_type TEXT NOT NULL DEFAULT '',
+    location TEXT NOT NULL DEFAULT '',
+    body BLOB NOT NULL,
+    expiry_date DATETIME NOT NULL,
+    UNIQUE (key, username)
+);
+`
+	_, err := tx.Exec(stmt)
+	return err
+}
+
+func updateFromV19(tx *sql.Tx) error {
+	stmt := `
+CREATE TABLE networks_load_balancers (
+    id INTEGER PRIMARY KEY AUTOINCREMENT NOT NULL,
+    network_id INTEGER NOT NULL,
+    node_id INTEGER NOT NULL,
+    listen_address TEXT NOT NULL,
+    protocol TEXT NOT NULL,
+    algorithm TEXT NOT NULL,
+    backends TEXT NOT NULL,
+    health_check_interval INTEGER NOT NULL DEFAULT 0,
+    description TEXT NOT NULL DEFAULT '',
+    UNIQUE (network_id, node_id, listen_address, protocol),
+    FOREIGN KEY (network_id) REFERENCES networks (id) ON DELETE CASCADE,
+    FOREIGN KEY (node_id) REFERENCES nodes (id) ON DELETE CASCADE
+);
+`
+	_, err := tx.Exec(stmt)
+	return err
+}
+
+func updateFromV18(tx *sql.Tx) error {
+	stmt := `
+CREATE TABLE networks_forwards (
+    id INTEGER PRIMARY KEY AUTOINCREMENT NOT NULL,
+    network_id INTEGER NOT NULL,
+    node_id INTEGER NOT NULL,
+    listen_address TEXT NOT NULL,
+    target_address TEXT NOT NULL,
+    protocol TEXT NOT NULL,
+    description TEXT NOT NULL DEFAULT '',
+    UNIQUE (network_id, node_id, listen_address, protocol),
+    FOREIGN KEY (network_id) REFERENCES networks (id) ON DELETE CASCADE,
+    FOREIGN KEY (node_id) REFERENCES nodes (id) ON DELETE CASCADE
+);
+`
+	_, err := tx.Exec(stmt)
+	return err
+}
+
+func updateFromV17(tx *sql.Tx) error {
+	stmt := `
+CREATE TABLE storage_volumes_encryption_keys (
+    id INTEGER PRIMARY KEY AUTOINCREMENT NOT NULL,
+    storage_volume_id INTEGER NOT NULL,
+    key TEXT NOT NULL,
+    UNIQUE (storage_volume_id),
+    FOREIGN KEY (storage_volume_id) REFERENCES storage_volumes (id) ON DELETE CASCADE
+);
+`
+	_, err := tx.Exec(stmt)
+	return err
+}
+
+func updateFromV16(tx *sql.Tx) error {
+	stmts := `
+ALTER TABLE images ADD COLUMN refcount INTEGER NOT NULL DEFAULT 0;
+UPDATE images SET refcount = (
+    SELECT COUNT(*)
+      FROM containers_config
+      JOIN containers ON containers.id = containers_config.container_id
+     WHERE containers_config.key = 'volatile.base_image'
+       AND containers_config.value = images.fingerprint
+       AND images.project_id = CASE
+           WHEN (SELECT projects_config.value
+                   FROM projects_config
+                  WHERE projects_config.project_id = containers.project_id
+                    AND projects_config.key = 'features.images') = 'true'
+           THEN containers.project_id
+           ELSE (SELECT id FROM projects WHERE name = 'default')
+       END);
+`
+	_, err := tx.Exec(stmts)
+	return err
+}
+
+func updateFromV15(tx *sql.Tx) error {
+	_, err := tx.Exec("ALTER TABLE containers_backups ADD COLUMN xattrs INTEGER NOT NULL DEFAULT 0;")
+	return err
+}
+
+func updateFromV14(tx *sql.Tx) error {
+	stmt := `
+CREATE TABLE webhooks (
+    id INTEGER PRIMARY KEY AUTOINCREMENT NOT NULL,
+    url TEXT NOT NULL,
+    secret TEXT NOT NULL DEFAULT '',
+    events TEXT NOT NULL DEFAULT '',
+    retry_max INTEGER NOT NULL DEFAULT 3
+);
+`
+	_, err := tx.Exec(stmt)
+	return err
 }
 
 func updateFromV13(tx *sql.Tx) error {
@@ -461,3 +461,58 @@ INSERT INTO containers VALUES (4, 1, 'xenial', 1, 1, 0, ?, 0, ?, 'Xenial Xerus',
 `, time.Now(), time.Now())
 	assert.EqualError(t, err, "UNIQUE constraint failed: containers.project_id, containers.name")
 }
+
+func TestUpdateFromV16(t *testing.T) {
+	schema := cluster.Schema()
+	db, err := schema.ExerciseUpdate(17, func(db *sql.DB) {
+		// Insert a node.
+		_, err := db.Exec(
+			"INSERT INTO nodes (id, name, address, schema, api_extensions) VALUES (1, 'n1', '1.2.3.4:666', 1, 32)")
+		require.NoError(t, err)
+
+		// Insert a project with its own images and one without.
+		_, err = db.Exec(`
+INSERT INTO projects (id, name) VALUES (2, 'staging');
+INSERT INTO projects (id, name) VALUES (3, 'testing');
+INSERT INTO projects_config (project_id, key, value) VALUES (2, 'features.images', 'true');
+`)
+		require.NoError(t, err)
+
+		// Insert the same image in the default and staging projects.
+		_, err = db.Exec(`
+INSERT INTO images (id, fingerprint, filename, size, architecture, upload_date, project_id)
+     VALUES (1, 'abcd', 'img.tgz', 123, 0, ?, 1);
+INSERT INTO images (id, fingerprint, filename, size, architecture, upload_date, project_id)
+     VALUES (2, 'abcd', 'img.tgz', 123, 0, ?, 2);
+`, time.Now(), time.Now())
+		require.NoError(t, err)
+
+		// Insert containers using the image in every project.
+		for i, project := range []int{1, 2, 2, 3} {
+			_, err = db.Exec(`
+INSERT INTO containers (id, node_id, name, architecture, type, project_id)
+     VALUES (?, 1, ?, 1, 0, ?)
+`, i+1, fmt.Sprintf("c%d", i+1), project)
+			require.NoError(t, err)
+
+			_, err = db.Exec(`
+INSERT INTO containers_config (container_id, key, value)
+     VALUES (?, 'volatile.base_image', 'abcd')
+`, i+1)
+			require.NoError(t, err)
+		}
+	})
+	require.NoError(t, err)
+
+	tx, err := db.Begin()
+	require.NoError(t, err)
+
+	defer tx.Rollback()
+
+	// The default project image is used by the containers of the default
+	// project and of the project without images, the staging one only by
+	// the containers of the staging project.
+	refcounts, err := query.SelectIntegers(tx, "SELECT refcount FROM images ORDER BY id")
+	require.NoError(t, err)
+	assert.Equal(t, []int{2, 2}, refcounts)
+}
@@ -153,6 +153,7 @@ type ContainerBackupArgs struct {
 	ExpiryDate       time.Time
 	ContainerOnly    bool
 	OptimizedStorage bool
+	Xattrs           bool
 }
 
 // ContainerType encodes the type of container (either regular or snapshot).
@@ -219,6 +220,17 @@ SELECT nodes.id, nodes.address
 	return address, nil
 }
 
+// ContainersQuery holds the criteria used to filter and paginate the list
+// of containers.
+type ContainersQuery struct {
+	Config map[string]string // Config keys and values the containers must have
+	Status string            // Last recorded power state (RUNNING or STOPPED)
+	Image  string            // Fingerprint (or prefix) of the source image
+	Node   string            // Name of the node running the containers
+	Limit  int               // Maximum number of containers to return (all if 0)
+	Offset int               // Number of matching containers to skip
+}
+
 // ContainersListByNodeAddress returns the names of all containers grouped by
 // cluster node address.
 //
@@ -227,23 +239,77 @@ SELECT nodes.id, nodes.address
 //
 // Containers whose node is down are addeded to the special address "0.0.0.0".
 func (c *ClusterTx) ContainersListByNodeAddress(project string) (map[string][]string, error) {
+	result, _, err := c.ContainersQueryByNodeAddress(project, ContainersQuery{})
+	return result, err
+}
+
+// ContainersQueryByNodeAddress returns the names of the containers matching
+// the given query grouped by cluster node address (see
+// ContainersListByNodeAddress), along with the total number of matching
+// containers regardless of pagination.
+func (c *ClusterTx) ContainersQueryByNodeAddress(project string, filter ContainersQuery) (map[string][]string, int, error) {
 	offlineThreshold, err := c.NodeOfflineThreshold()
 	if err != nil {
-		return nil, err
+		return nil, -1, err
 	}
 
-	stmt := `
-SELECT containers.name, nodes.id, nodes.address, nodes.heartbeat
+	where := []string{"containers.type=?", "projects.name = ?"}
+	args := []interface{}{CTypeRegular, project}
+
+	configClause := "EXISTS (SELECT 1 FROM containers_config WHERE containers_config.container_id = containers.id AND containers_config.key = ? AND containers_config.value %s ?)"
+	for key, value := range filter.Config {
+		where = append(where, fmt.Sprintf(configClause, "="))
+		args = append(args, key, value)
+	}
+
+	switch filter.Status {
+	case "":
+	case "STOPPED":
+		// Containers which were never started have no recorded state
+		where = append(where, "NOT "+fmt.Sprintf(configClause, "="))
+		args = append(args, "volatile.last_state.power", "RUNNING")
+	default:
+		where = append(where, fmt.Sprintf(configClause, "="))
+		args = append(args, "volatile.last_state.power", filter.Status)
+	}
+
+	if filter.Image != "" {
+		where = append(where, fmt.Sprintf(configClause, "LIKE"))
+		args = append(args, "volatile.base_image", filter.Image+"%")
+	}
+
+	if filter.Node != "" {
+		where = append(where, "nodes.name = ?")
+		args = append(args, filter.Node)
+	}
+
+	from := `
   FROM containers
   JOIN nodes ON nodes.id = containers.node_id
   JOIN projects ON projects.id = containers.project_id
-  WHERE containers.type=?
-    AND projects.name = ?
-  ORDER BY containers.id
-`
-	rows, err := c.tx.Query(stmt, CTypeRegular, project)
+  WHERE ` + strings.Join(where, "\n    AND ")
+
+	// Get the total count
+	total := 0
+	err = c.tx.QueryRow("SELECT COUNT(*)"+from, args...).Scan(&total)
 	if err != nil {
-		return nil, err
+		return nil, -1, err
+	}
+
+	stmt := "SELECT containers.name, nodes.id, nodes.address, nodes.heartbeat" + from + "\n  ORDER BY containers.id"
+	if filter.Limit > 0 || filter.Offset > 0 {
+		limit := filter.Limit
+		if limit <= 0 {
+			limit = -1
+		}
+
+		stmt += "\n  LIMIT ? OFFSET ?"
+		args = append(args, limit, filter.Offset)
+	}
+
+	rows, err := c.tx.Query(stmt, args...)
+	if err != nil {
+		return nil, -1, err
 	}
 	defer rows.Close()
 
@@ -256,7 +322,7 @@ SELECT containers.name, nodes.id, nodes.address, nodes.heartbeat
 		var nodeHeartbeat time.Time
 		err := rows.Scan(&name, &nodeID, &nodeAddress, &nodeHeartbeat)
 		if err != nil {
-			return nil, err
+			return nil, -1, err
 		}
 		if nodeID == c.nodeID {
 			nodeAddress = ""
@@ -268,9 +334,9 @@ SELECT containers.name, nodes.id, nodes.address, nodes.heartbeat
 
 	err = rows.Err()
 	if err != nil {
-		return nil, err
+		return nil, -1, err
 	}
-	return result, nil
+	return result, total, nil
 }
 
 // ContainerListExpanded loads all containers across all projects and expands
@@ -281,6 +347,23 @@ func (c *ClusterTx) ContainerListExpanded() ([]Container, error) {
 		return nil, errors.Wrap(err, "Load containers")
 	}
 
+	return c.containersExpand(containers)
+}
+
+// ContainerNodeListExpanded loads all containers of the local node across all
+// projects and expands their config and devices using their profiles.
+func (c *ClusterTx) ContainerNodeListExpanded() ([]Container, error) {
+	containers, err := c.ContainerNodeList()
+	if err != nil {
+		return nil, errors.Wrap(err, "Load containers")
+	}
+
+	return c.containersExpand(containers)
+}
+
+// containersExpand expands the config and devices of the given containers
+// using the profiles they are associated to.
+func (c *ClusterTx) containersExpand(containers []Container) ([]Container, error) {
 	profiles, err := c.ProfileList(ProfileFilter{})
 	if err != nil {
 		return nil, errors.Wrap(err, "Load profiles")
@@ -977,10 +1060,12 @@ func (c *Cluster) ContainerGetBackup(project, name string) (ContainerBackupArgs,
 
 	containerOnlyInt := -1
 	optimizedStorageInt := -1
+	xattrsInt := -1
 	q := `
 SELECT containers_backups.id, containers_backups.container_id,
        containers_backups.creation_date, containers_backups.expiry_date,
-       containers_backups.container_only, containers_backups.optimized_storage
+       containers_backups.container_only, containers_backups.optimized_storage,
+       containers_backups.xattrs
     FROM containers_backups
     JOIN containers ON containers.id=containers_backups.container_id
     JOIN projects ON projects.id=containers.project_id
@@ -988,7 +1073,7 @@ SELECT containers_backups.id, containers_backups.container_id,
 `
 	arg1 := []interface{}{project, name}
 	arg2 := []interface{}{&args.ID, &args.ContainerID, &args.CreationDate,
-		&args.ExpiryDate, &containerOnlyInt, &optimizedStorageInt}
+		&args.ExpiryDate, &containerOnlyInt, &optimizedStorageInt, &xattrsInt}
 	err := dbQueryRowScan(c.db, q, arg1, arg2)
 	if err != nil {
 		if err == sql.ErrNoRows {
@@ -1006,6 +1091,10 @@ SELECT containers_backups.id, containers_backups.container_id,
 		args.OptimizedStorage = true
 	}
 
+	if xattrsInt == 1 {
+		args.Xattrs = true
+	}
+
 	return args, nil
 }
 
@@ -1050,7 +1139,12 @@ func (c *Cluster) ContainerBackupCreate(args ContainerBackupArgs) error {
 			optimizedStorageInt = 1
 		}
 
-		str := fmt.Sprintf("INSERT INTO containers_backups (container_id, name, creation_date, expiry_date, container_only, optimized_storage) VALUES (?, ?, ?, ?, ?, ?)")
+		xattrsInt := 0
+		if args.Xattrs {
+			xattrsInt = 1
+		}
+
+		str := fmt.Sprintf("INSERT INTO containers_backups (container_id, name, creation_date, expiry_date, container_only, optimized_storage, xattrs) VALUES (?, ?, ?, ?, ?, ?, ?)")
 		stmt, err := tx.tx.Prepare(str)
 		if err != nil {
 			return err
@@ -1058,7 +1152,7 @@ func (c *Cluster) ContainerBackupCreate(args ContainerBackupArgs) error {
 		defer stmt.Close()
 		result, err := stmt.Exec(args.ContainerID, args.Name,
 			args.CreationDate.Unix(), args.ExpiryDate.Unix(), containerOnlyInt,
-			optimizedStorageInt)
+			optimizedStorageInt, xattrsInt)
 		if err != nil {
 			return err
 		}
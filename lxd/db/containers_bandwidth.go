@@ -0,0 +1,69 @@
+package db
+
+// ContainerBandwidth holds the network traffic of a container over a day.
+type ContainerBandwidth struct {
+	Date    string
+	RxBytes int64
+	TxBytes int64
+}
+
+// ContainerBandwidthAdd adds traffic to the counters of the container with the
+// given ID for the given day (YYYY-MM-DD).
+func (c *Cluster) ContainerBandwidthAdd(containerID int, date string, rxBytes int64, txBytes int64) error {
+	return c.Transaction(func(tx *ClusterTx) error {
+		_, err := tx.tx.Exec(`
+INSERT OR IGNORE INTO containers_bandwidth (container_id, date, rx_bytes, tx_bytes) VALUES (?, ?, 0, 0)`,
+			containerID, date)
+		if err != nil {
+			return err
+		}
+
+		_, err = tx.tx.Exec(`
+UPDATE containers_bandwidth SET rx_bytes=rx_bytes+?, tx_bytes=tx_bytes+?
+ WHERE container_id=? AND date=?`,
+			rxBytes, txBytes, containerID, date)
+		return err
+	})
+}
+
+// ContainerBandwidthGet returns the daily traffic of the container with the
+// given ID since the given day (YYYY-MM-DD), oldest first.
+func (c *Cluster) ContainerBandwidthGet(containerID int, since string) ([]ContainerBandwidth, error) {
+	result := []ContainerBandwidth{}
+
+	err := c.Transaction(func(tx *ClusterTx) error {
+		rows, err := tx.tx.Query(`
+SELECT date, rx_bytes, tx_bytes FROM containers_bandwidth
+ WHERE container_id=? AND date>=? ORDER BY date`, containerID, since)
+		if err != nil {
+			return err
+		}
+		defer rows.Close()
+
+		for rows.Next() {
+			day := ContainerBandwidth{}
+			err := rows.Scan(&day.Date, &day.RxBytes, &day.TxBytes)
+			if err != nil {
+				return err
+			}
+
+			result = append(result, day)
+		}
+
+		return rows.Err()
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return result, nil
+}
+
+// ContainerBandwidthPrune deletes the traffic of all containers recorded
+// before the given day (YYYY-MM-DD).
+func (c *Cluster) ContainerBandwidthPrune(before string) error {
+	return c.Transaction(func(tx *ClusterTx) error {
+		_, err := tx.tx.Exec("DELETE FROM containers_bandwidth WHERE date<?", before)
+		return err
+	})
+}
@@ -0,0 +1,44 @@
+package db_test
+
+import (
+	"testing"
+
+	"github.com/lxc/lxd/lxd/db"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// Accumulate the traffic of a container and prune the oldest days.
+func TestContainerBandwidth(t *testing.T) {
+	cluster, cleanup := db.NewTestCluster(t)
+	defer cleanup()
+
+	var containerID int64
+	err := cluster.Transaction(func(tx *db.ClusterTx) error {
+		addContainer(t, tx, 1, "c1")
+		containerID = getContainerID(t, tx, "c1")
+		return nil
+	})
+	require.NoError(t, err)
+
+	require.NoError(t, cluster.ContainerBandwidthAdd(int(containerID), "2019-05-01", 100, 10))
+	require.NoError(t, cluster.ContainerBandwidthAdd(int(containerID), "2019-05-01", 50, 5))
+	require.NoError(t, cluster.ContainerBandwidthAdd(int(containerID), "2019-05-02", 1, 2))
+
+	days, err := cluster.ContainerBandwidthGet(int(containerID), "2019-01-01")
+	require.NoError(t, err)
+	assert.Equal(t, []db.ContainerBandwidth{
+		{Date: "2019-05-01", RxBytes: 150, TxBytes: 15},
+		{Date: "2019-05-02", RxBytes: 1, TxBytes: 2},
+	}, days)
+
+	days, err = cluster.ContainerBandwidthGet(int(containerID), "2019-05-02")
+	require.NoError(t, err)
+	assert.Len(t, days, 1)
+
+	require.NoError(t, cluster.ContainerBandwidthPrune("2019-05-02"))
+
+	days, err = cluster.ContainerBandwidthGet(int(containerID), "2019-01-01")
+	require.NoError(t, err)
+	assert.Equal(t, []db.ContainerBandwidth{{Date: "2019-05-02", RxBytes: 1, TxBytes: 2}}, days)
+}
@@ -0,0 +1,206 @@
+package db
+
+import (
+	"database/sql"
+	"encoding/json"
+	"time"
+)
+
+// ContainerCronJob holds a command run on a schedule inside a container.
+type ContainerCronJob struct {
+	ID            int64
+	ContainerID   int
+	Command       []string
+	Schedule      string
+	Timeout       int
+	CaptureOutput bool
+	CreationDate  time.Time
+}
+
+// ContainerCronJobRun holds the result of a run of a scheduled command.
+type ContainerCronJobRun struct {
+	JobID     int64
+	StartDate time.Time
+	EndDate   time.Time
+	ExitCode  int
+	Stdout    []byte
+	Stderr    []byte
+	Error     string
+}
+
+func containerCronJobScan(rows *sql.Rows) (*ContainerCronJob, error) {
+	job := ContainerCronJob{}
+	var command string
+
+	err := rows.Scan(&job.ID, &job.ContainerID, &command, &job.Schedule, &job.Timeout, &job.CaptureOutput, &job.CreationDate)
+	if err != nil {
+		return nil, err
+	}
+
+	err = json.Unmarshal([]byte(command), &job.Command)
+	if err != nil {
+		return nil, err
+	}
+
+	return &job, nil
+}
+
+func (c *Cluster) containerCronJobsQuery(where string, args ...interface{}) ([]ContainerCronJob, error) {
+	jobs := []ContainerCronJob{}
+
+	err := c.Transaction(func(tx *ClusterTx) error {
+		rows, err := tx.tx.Query(`
+SELECT id, container_id, command, schedule, timeout, capture_output, creation_date
+  FROM containers_cron_jobs`+where+` ORDER BY id`, args...)
+		if err != nil {
+			return err
+		}
+		defer rows.Close()
+
+		for rows.Next() {
+			job, err := containerCronJobScan(rows)
+			if err != nil {
+				return err
+			}
+
+			jobs = append(jobs, *job)
+		}
+
+		return rows.Err()
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return jobs, nil
+}
+
+// ContainerCronJobs returns the scheduled commands of the container with the
+// given ID, or of all the containers if the ID is -1.
+func (c *Cluster) ContainerCronJobs(containerID int) ([]ContainerCronJob, error) {
+	if containerID == -1 {
+		return c.containerCronJobsQuery("")
+	}
+
+	return c.containerCronJobsQuery(" WHERE container_id=?", containerID)
+}
+
+// ContainerCronJobGet returns the scheduled command with the given ID.
+func (c *Cluster) ContainerCronJobGet(id int64) (*ContainerCronJob, error) {
+	jobs, err := c.containerCronJobsQuery(" WHERE id=?", id)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(jobs) == 0 {
+		return nil, ErrNoSuchObject
+	}
+
+	return &jobs[0], nil
+}
+
+// ContainerCronJobCreate adds a new scheduled command and returns its ID.
+func (c *Cluster) ContainerCronJobCreate(job ContainerCronJob) (int64, error) {
+	command, err := json.Marshal(job.Command)
+	if err != nil {
+		return -1, err
+	}
+
+	var id int64
+	err = c.Transaction(func(tx *ClusterTx) error {
+		result, err := tx.tx.Exec(`
+INSERT INTO containers_cron_jobs (container_id, command, schedule, timeout, capture_output, creation_date)
+     VALUES (?, ?, ?, ?, ?, ?)`,
+			job.ContainerID, string(command), job.Schedule, job.Timeout, job.CaptureOutput, job.CreationDate)
+		if err != nil {
+			return err
+		}
+
+		id, err = result.LastInsertId()
+		return err
+	})
+	if err != nil {
+		return -1, err
+	}
+
+	return id, nil
+}
+
+// ContainerCronJobDelete deletes the scheduled command with the given ID,
+// along with its runs.
+func (c *Cluster) ContainerCronJobDelete(id int64) error {
+	err := c.Transaction(func(tx *ClusterTx) error {
+		result, err := tx.tx.Exec("DELETE FROM containers_cron_jobs WHERE id=?", id)
+		if err != nil {
+			return err
+		}
+
+		n, err := result.RowsAffected()
+		if err != nil {
+			return err
+		}
+
+		if n == 0 {
+			return ErrNoSuchObject
+		}
+
+		return nil
+	})
+	return err
+}
+
+// ContainerCronJobRunCreate records a run of a scheduled command, only
+// keeping the given number of most recent runs of the command.
+func (c *Cluster) ContainerCronJobRunCreate(run ContainerCronJobRun, keep int) error {
+	err := c.Transaction(func(tx *ClusterTx) error {
+		_, err := tx.tx.Exec(`
+INSERT INTO containers_cron_runs (job_id, start_date, end_date, exit_code, stdout, stderr, error)
+     VALUES (?, ?, ?, ?, ?, ?, ?)`,
+			run.JobID, run.StartDate, run.EndDate, run.ExitCode, run.Stdout, run.Stderr, run.Error)
+		if err != nil {
+			return err
+		}
+
+		_, err = tx.tx.Exec(`
+DELETE FROM containers_cron_runs
+ WHERE job_id=? AND id NOT IN (
+   SELECT id FROM containers_cron_runs WHERE job_id=? ORDER BY id DESC LIMIT ?)`,
+			run.JobID, run.JobID, keep)
+		return err
+	})
+	return err
+}
+
+// ContainerCronJobRuns returns the most recent runs of the scheduled command
+// with the given ID, newest first.
+func (c *Cluster) ContainerCronJobRuns(jobID int64, limit int) ([]ContainerCronJobRun, error) {
+	runs := []ContainerCronJobRun{}
+
+	err := c.Transaction(func(tx *ClusterTx) error {
+		rows, err := tx.tx.Query(`
+SELECT job_id, start_date, end_date, exit_code, stdout, stderr, error
+  FROM containers_cron_runs
+ WHERE job_id=? ORDER BY id DESC LIMIT ?`, jobID, limit)
+		if err != nil {
+			return err
+		}
+		defer rows.Close()
+
+		for rows.Next() {
+			run := ContainerCronJobRun{}
+			err := rows.Scan(&run.JobID, &run.StartDate, &run.EndDate, &run.ExitCode, &run.Stdout, &run.Stderr, &run.Error)
+			if err != nil {
+				return err
+			}
+
+			runs = append(runs, run)
+		}
+
+		return rows.Err()
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return runs, nil
+}
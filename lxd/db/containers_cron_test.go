@@ -0,0 +1,72 @@
+package db_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/lxc/lxd/lxd/db"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// Create a scheduled command, record its runs and delete it.
+func TestContainerCronJob(t *testing.T) {
+	cluster, cleanup := db.NewTestCluster(t)
+	defer cleanup()
+
+	var containerID int64
+	err := cluster.Transaction(func(tx *db.ClusterTx) error {
+		addContainer(t, tx, 1, "c1")
+		containerID = getContainerID(t, tx, "c1")
+		return nil
+	})
+	require.NoError(t, err)
+
+	id, err := cluster.ContainerCronJobCreate(db.ContainerCronJob{
+		ContainerID:   int(containerID),
+		Command:       []string{"apt", "update"},
+		Schedule:      "0 * * * *",
+		Timeout:       60,
+		CaptureOutput: true,
+		CreationDate:  time.Now(),
+	})
+	require.NoError(t, err)
+
+	job, err := cluster.ContainerCronJobGet(id)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"apt", "update"}, job.Command)
+	assert.Equal(t, "0 * * * *", job.Schedule)
+	assert.True(t, job.CaptureOutput)
+
+	jobs, err := cluster.ContainerCronJobs(-1)
+	require.NoError(t, err)
+	assert.Len(t, jobs, 1)
+
+	for i := 0; i < 3; i++ {
+		err := cluster.ContainerCronJobRunCreate(db.ContainerCronJobRun{
+			JobID:     id,
+			StartDate: time.Now(),
+			EndDate:   time.Now(),
+			ExitCode:  i,
+			Stdout:    []byte("out"),
+			Stderr:    []byte{},
+		}, 2)
+		require.NoError(t, err)
+	}
+
+	runs, err := cluster.ContainerCronJobRuns(id, 10)
+	require.NoError(t, err)
+	require.Len(t, runs, 2)
+	assert.Equal(t, 2, runs[0].ExitCode)
+	assert.Equal(t, 1, runs[1].ExitCode)
+	assert.Equal(t, []byte("out"), runs[0].Stdout)
+
+	err = cluster.ContainerCronJobDelete(id)
+	require.NoError(t, err)
+
+	_, err = cluster.ContainerCronJobGet(id)
+	assert.Equal(t, db.ErrNoSuchObject, err)
+
+	err = cluster.ContainerCronJobDelete(id)
+	assert.Equal(t, db.ErrNoSuchObject, err)
+}
@@ -196,6 +196,43 @@ func TestContainerListExpanded(t *testing.T) {
 	})
 }
 
+func TestContainerNodeListExpanded(t *testing.T) {
+	tx, cleanup := db.NewTestClusterTx(t)
+	defer cleanup()
+
+	_, err := tx.ProjectCreate(api.ProjectsPost{Name: "p1"})
+	require.NoError(t, err)
+
+	_, err = tx.ProfileCreate(db.Profile{Project: "p1", Name: "default", Config: map[string]string{"a": "1"}})
+	require.NoError(t, err)
+
+	for _, project := range []string{"default", "p1"} {
+		_, err = tx.ContainerCreate(db.Container{
+			Project:      project,
+			Name:         "c1",
+			Node:         "none",
+			Type:         int(db.CTypeRegular),
+			Architecture: 1,
+			Config:       map[string]string{"c": "3"},
+			Profiles:     []string{"default"},
+		})
+		require.NoError(t, err)
+	}
+
+	containers, err := tx.ContainerNodeListExpanded()
+	require.NoError(t, err)
+
+	require.Len(t, containers, 2)
+
+	configs := map[string]map[string]string{}
+	for _, container := range containers {
+		configs[container.Project] = container.Config
+	}
+
+	assert.Equal(t, map[string]string{"c": "3"}, configs["default"])
+	assert.Equal(t, map[string]string{"a": "1", "c": "3"}, configs["p1"])
+}
+
 func TestContainerCreate(t *testing.T) {
 	tx, cleanup := db.NewTestClusterTx(t)
 	defer cleanup()
@@ -260,6 +297,54 @@ func TestContainersListByNodeAddress(t *testing.T) {
 		}, result)
 }
 
+// Containers can be filtered and paginated.
+func TestContainersQueryByNodeAddress(t *testing.T) {
+	tx, cleanup := db.NewTestClusterTx(t)
+	defer cleanup()
+
+	nodeID1 := int64(1) // This is the default local node
+
+	nodeID2, err := tx.NodeAdd("node2", "1.2.3.4:666")
+	require.NoError(t, err)
+
+	addContainer(t, tx, nodeID1, "c1")
+	addContainer(t, tx, nodeID2, "c2")
+	addContainer(t, tx, nodeID1, "c3")
+	addContainer(t, tx, nodeID1, "c4")
+
+	addContainerConfig(t, tx, "c1", "user.app", "web")
+	addContainerConfig(t, tx, "c1", "volatile.last_state.power", "RUNNING")
+	addContainerConfig(t, tx, "c2", "user.app", "web")
+	addContainerConfig(t, tx, "c3", "user.app", "db")
+	addContainerConfig(t, tx, "c3", "volatile.last_state.power", "STOPPED")
+	addContainerConfig(t, tx, "c4", "volatile.base_image", "abcdef")
+
+	result, total, err := tx.ContainersQueryByNodeAddress("default", db.ContainersQuery{
+		Config: map[string]string{"user.app": "web"},
+	})
+	require.NoError(t, err)
+	assert.Equal(t, 2, total)
+	assert.Equal(t, map[string][]string{"": {"c1"}, "1.2.3.4:666": {"c2"}}, result)
+
+	result, total, err = tx.ContainersQueryByNodeAddress("default", db.ContainersQuery{Status: "STOPPED"})
+	require.NoError(t, err)
+	assert.Equal(t, 3, total)
+	assert.Equal(t, map[string][]string{"": {"c3", "c4"}, "1.2.3.4:666": {"c2"}}, result)
+
+	result, _, err = tx.ContainersQueryByNodeAddress("default", db.ContainersQuery{Image: "abc"})
+	require.NoError(t, err)
+	assert.Equal(t, map[string][]string{"": {"c4"}}, result)
+
+	result, _, err = tx.ContainersQueryByNodeAddress("default", db.ContainersQuery{Node: "node2"})
+	require.NoError(t, err)
+	assert.Equal(t, map[string][]string{"1.2.3.4:666": {"c2"}}, result)
+
+	result, total, err = tx.ContainersQueryByNodeAddress("default", db.ContainersQuery{Limit: 2, Offset: 1})
+	require.NoError(t, err)
+	assert.Equal(t, 4, total)
+	assert.Equal(t, map[string][]string{"": {"c3"}, "1.2.3.4:666": {"c2"}}, result)
+}
+
 // Containers are associated with their node name.
 func TestContainersByNodeName(t *testing.T) {
 	tx, cleanup := db.NewTestClusterTx(t)
@@ -7,6 +7,15 @@ import (
 	"github.com/lxc/lxd/lxd/types"
 )
 
+// Device types provided by plugins, stored with the plugin type code. This is
+// only written to during daemon startup.
+var devicePluginTypes = map[string]bool{}
+
+// DevicePluginTypeRegister registers a device type provided by a plugin.
+func DevicePluginTypeRegister(t string) {
+	devicePluginTypes[t] = true
+}
+
 func dbDeviceTypeToString(t int) (string, error) {
 	switch t {
 	case 0:
@@ -27,6 +36,16 @@ func dbDeviceTypeToString(t int) (string, error) {
 		return "infiniband", nil
 	case 8:
 		return "proxy", nil
+	case 9:
+		return "plugin", nil
+	case 10:
+		return "tun", nil
+	case 11:
+		return "pci", nil
+	case 12:
+		return "iso", nil
+	case 13:
+		return "vlan", nil
 	default:
 		return "", fmt.Errorf("Invalid device type %d", t)
 	}
@@ -52,7 +71,20 @@ func dbDeviceTypeToInt(t string) (int, error) {
 		return 7, nil
 	case "proxy":
 		return 8, nil
+	case "tun":
+		return 10, nil
+	case "pci":
+		return 11, nil
+	case "iso":
+		return 12, nil
+	case "vlan":
+		return 13, nil
 	default:
+		// Plugin provided device, the actual type is kept in the config
+		if devicePluginTypes[t] {
+			return 9, nil
+		}
+
 		return -1, fmt.Errorf("Invalid device type %s", t)
 	}
 }
@@ -94,8 +126,9 @@ func DevicesAdd(tx *sql.Tx, w string, cID int64, devices types.Devices) error {
 		id := int(id64)
 
 		for ck, cv := range v {
-			// The type is stored as int in the parent entry
-			if ck == "type" || cv == "" {
+			// The type is stored as int in the parent entry (except
+			// for plugin provided devices)
+			if (ck == "type" && t != 9) || cv == "" {
 				continue
 			}
 
@@ -188,7 +221,9 @@ func (c *Cluster) Devices(project, qName string, isprofile bool) (types.Devices,
 		if err != nil {
 			return nil, err
 		}
-		newdev["type"] = stype
+		if newdev["type"] == "" {
+			newdev["type"] = stype
+		}
 		devices[name] = newdev
 	}
 
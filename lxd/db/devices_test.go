@@ -0,0 +1,26 @@
+package db_test
+
+import (
+	"testing"
+
+	"github.com/lxc/lxd/lxd/db"
+	"github.com/stretchr/testify/assert"
+)
+
+// Only the device types registered by plugins are stored as plugin devices.
+func TestDeviceTypeToInt(t *testing.T) {
+	code, err := db.DeviceTypeToInt("disk")
+	assert.NoError(t, err)
+	assert.Equal(t, 2, code)
+
+	_, err = db.DeviceTypeToInt("")
+	assert.EqualError(t, err, "Invalid device type ")
+
+	_, err = db.DeviceTypeToInt("foo")
+	assert.EqualError(t, err, "Invalid device type foo")
+
+	db.DevicePluginTypeRegister("foo")
+	code, err = db.DeviceTypeToInt("foo")
+	assert.NoError(t, err)
+	assert.Equal(t, 9, code)
+}
@@ -0,0 +1,81 @@
+package db
+
+import (
+	"time"
+)
+
+// IdempotencyKey holds the response recorded for a request carrying an
+// idempotency key.
+type IdempotencyKey struct {
+	Key         string
+	Username    string
+	Method      string
+	URI         string
+	StatusCode  int
+	ContentType string
+	Location    string
+	Body        []byte
+	ExpiryDate  time.Time
+}
+
+// IdempotencyKeyGet returns the response recorded for the given idempotency
+// key of the given user, unless it expired.
+func (c *Cluster) IdempotencyKeyGet(key string, username string) (*IdempotencyKey, error) {
+	entry := IdempotencyKey{}
+
+	err := c.Transaction(func(tx *ClusterTx) error {
+		rows, err := tx.tx.Query(`
+SELECT key, username, method, uri, status_code, content_type, location, body, expiry_date
+  FROM idempotency_keys
+ WHERE key=? AND username=? AND expiry_date>?`, key, username, time.Now())
+		if err != nil {
+			return err
+		}
+		defer rows.Close()
+
+		if !rows.Next() {
+			err := rows.Err()
+			if err != nil {
+				return err
+			}
+
+			return ErrNoSuchObject
+		}
+
+		return rows.Scan(&entry.Key, &entry.Username, &entry.Method, &entry.URI, &entry.StatusCode,
+			&entry.ContentType, &entry.Location, &entry.Body, &entry.ExpiryDate)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return &entry, nil
+}
+
+// IdempotencyKeyCreate records the response of a request carrying an
+// idempotency key, replacing the expired entry of the same key if any.
+func (c *Cluster) IdempotencyKeyCreate(entry IdempotencyKey) error {
+	err := c.Transaction(func(tx *ClusterTx) error {
+		_, err := tx.tx.Exec("DELETE FROM idempotency_keys WHERE key=? AND username=? AND expiry_date<=?", entry.Key, entry.Username, time.Now())
+		if err != nil {
+			return err
+		}
+
+		_, err = tx.tx.Exec(`
+INSERT INTO idempotency_keys (key, username, method, uri, status_code, content_type, location, body, expiry_date)
+     VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?)`,
+			entry.Key, entry.Username, entry.Method, entry.URI, entry.StatusCode,
+			entry.ContentType, entry.Location, entry.Body, entry.ExpiryDate)
+		return err
+	})
+	return err
+}
+
+// IdempotencyKeysPrune removes the expired idempotency keys.
+func (c *Cluster) IdempotencyKeysPrune() error {
+	err := c.Transaction(func(tx *ClusterTx) error {
+		_, err := tx.tx.Exec("DELETE FROM idempotency_keys WHERE expiry_date<=?", time.Now())
+		return err
+	})
+	return err
+}
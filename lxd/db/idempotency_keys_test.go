@@ -0,0 +1,72 @@
+package db_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/lxc/lxd/lxd/db"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// Record, get and prune the response to a request with an idempotency key.
+func TestIdempotencyKey(t *testing.T) {
+	cluster, cleanup := db.NewTestCluster(t)
+	defer cleanup()
+
+	entry := db.IdempotencyKey{
+		Key:        "abcd",
+		Username:   "user",
+		Method:     "POST",
+		URI:        "/1.0/containers",
+		StatusCode: 202,
+		Body:       []byte("{}"),
+		ExpiryDate: time.Now().Add(time.Minute),
+	}
+
+	err := cluster.IdempotencyKeyCreate(entry)
+	require.NoError(t, err)
+
+	got, err := cluster.IdempotencyKeyGet("abcd", "user")
+	require.NoError(t, err)
+	assert.Equal(t, "/1.0/containers", got.URI)
+	assert.Equal(t, 202, got.StatusCode)
+	assert.Equal(t, []byte("{}"), got.Body)
+
+	_, err = cluster.IdempotencyKeyGet("abcd", "other")
+	assert.Equal(t, db.ErrNoSuchObject, err)
+
+	err = cluster.IdempotencyKeysPrune()
+	require.NoError(t, err)
+
+	_, err = cluster.IdempotencyKeyGet("abcd", "user")
+	require.NoError(t, err)
+}
+
+// An expired idempotency key is ignored and replaced.
+func TestIdempotencyKeyExpired(t *testing.T) {
+	cluster, cleanup := db.NewTestCluster(t)
+	defer cleanup()
+
+	entry := db.IdempotencyKey{
+		Key:        "abcd",
+		Method:     "DELETE",
+		URI:        "/1.0/containers/c1",
+		StatusCode: 202,
+		Body:       []byte("{}"),
+		ExpiryDate: time.Now().Add(-time.Minute),
+	}
+
+	err := cluster.IdempotencyKeyCreate(entry)
+	require.NoError(t, err)
+
+	_, err = cluster.IdempotencyKeyGet("abcd", "")
+	assert.Equal(t, db.ErrNoSuchObject, err)
+
+	entry.ExpiryDate = time.Now().Add(time.Minute)
+	err = cluster.IdempotencyKeyCreate(entry)
+	require.NoError(t, err)
+
+	_, err = cluster.IdempotencyKeyGet("abcd", "")
+	require.NoError(t, err)
+}
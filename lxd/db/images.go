@@ -3,6 +3,7 @@ package db
 import (
 	"database/sql"
 	"fmt"
+	"sort"
 	"time"
 
 	"github.com/lxc/lxd/lxd/db/query"
@@ -103,6 +104,76 @@ func (c *Cluster) ImagesGetExpired(expiry int64) ([]string, error) {
 	return results, nil
 }
 
+// ImagesGetUnreferenced returns the images which aren't referenced by any
+// container or snapshot nor aliased, and weren't used for the given number of
+// days. The keys of the returned map are the fingerprints and the values the
+// projects of the images.
+func (c *Cluster) ImagesGetUnreferenced(minAge int64) (map[string][]string, error) {
+	q := `
+SELECT images.fingerprint, projects.name, images.last_use_date, images.upload_date
+  FROM images
+  JOIN projects ON projects.id = images.project_id
+ WHERE images.refcount = 0
+   AND images.id NOT IN (SELECT image_id FROM images_aliases)
+`
+
+	var fpStr string
+	var projectStr string
+	var useStr string
+	var uploadStr string
+
+	inargs := []interface{}{}
+	outfmt := []interface{}{fpStr, projectStr, useStr, uploadStr}
+	dbResults, err := queryScan(c.db, q, inargs, outfmt)
+	if err != nil {
+		return nil, err
+	}
+
+	results := map[string][]string{}
+	for _, r := range dbResults {
+		timestamp := r[3]
+		if r[2] != "" {
+			timestamp = r[2]
+		}
+
+		var lastUse time.Time
+		err = lastUse.UnmarshalText([]byte(timestamp.(string)))
+		if err != nil {
+			return nil, err
+		}
+
+		if lastUse.Add(time.Duration(minAge*24) * time.Hour).After(time.Now()) {
+			continue
+		}
+
+		fingerprint := r[0].(string)
+		results[fingerprint] = append(results[fingerprint], r[1].(string))
+	}
+
+	return results, nil
+}
+
+// ImageRefCountUpdate adds the given delta to the number of containers and
+// snapshots referencing the image with the given fingerprint.
+func (c *Cluster) ImageRefCountUpdate(project string, fingerprint string, delta int) error {
+	return c.Transaction(func(tx *ClusterTx) error {
+		enabled, err := tx.ProjectHasImages(project)
+		if err != nil {
+			return errors.Wrap(err, "Check if project has images")
+		}
+		if !enabled {
+			project = "default"
+		}
+
+		stmt := `
+UPDATE images SET refcount = MAX(refcount + ?, 0)
+ WHERE fingerprint = ? AND project_id = (SELECT id FROM projects WHERE name = ?)
+`
+		_, err = tx.tx.Exec(stmt, delta, fingerprint, project)
+		return err
+	})
+}
+
 // ImageSourceInsert inserts a new image source.
 func (c *Cluster) ImageSourceInsert(id int, server string, protocol string, certificate string, alias string) error {
 	stmt := `INSERT INTO images_source (image_id, server, protocol, certificate, alias) values (?, ?, ?, ?, ?)`
@@ -976,3 +1047,66 @@ func (c *Cluster) getNodesByImageFingerprint(stmt, fingerprint string) ([]string
 	})
 	return addresses, err
 }
+
+// ImageBuildCacheEntry is an image holding a cached container build layer.
+type ImageBuildCacheEntry struct {
+	Project     string
+	Fingerprint string
+	Hash        string
+	Size        int64
+	LastUseDate time.Time
+}
+
+// ImagesBuildCache returns the images holding cached container build layers,
+// least recently used first.
+func (c *Cluster) ImagesBuildCache() ([]ImageBuildCacheEntry, error) {
+	q := `
+SELECT projects.name, images.fingerprint, images_properties.value, images.size, images.last_use_date, images.upload_date
+  FROM images
+  JOIN projects ON projects.id = images.project_id
+  JOIN images_properties ON images_properties.image_id = images.id
+ WHERE images_properties.key = 'build.hash'
+`
+
+	var project string
+	var fingerprint string
+	var hash string
+	var size int64
+	var useStr string
+	var uploadStr string
+
+	inargs := []interface{}{}
+	outfmt := []interface{}{project, fingerprint, hash, size, useStr, uploadStr}
+	dbResults, err := queryScan(c.db, q, inargs, outfmt)
+	if err != nil {
+		return nil, err
+	}
+
+	entries := []ImageBuildCacheEntry{}
+	for _, r := range dbResults {
+		timestamp := r[5]
+		if r[4] != "" {
+			timestamp = r[4]
+		}
+
+		var lastUse time.Time
+		err = lastUse.UnmarshalText([]byte(timestamp.(string)))
+		if err != nil {
+			return nil, err
+		}
+
+		entries = append(entries, ImageBuildCacheEntry{
+			Project:     r[0].(string),
+			Fingerprint: r[1].(string),
+			Hash:        r[2].(string),
+			Size:        r[3].(int64),
+			LastUseDate: lastUse,
+		})
+	}
+
+	sort.Slice(entries, func(i, j int) bool {
+		return entries[i].LastUseDate.Before(entries[j].LastUseDate)
+	})
+
+	return entries, nil
+}
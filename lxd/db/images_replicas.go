@@ -0,0 +1,131 @@
+package db
+
+import (
+	"time"
+
+	"github.com/lxc/lxd/lxd/db/query"
+)
+
+// Replication states of an image on a cluster node.
+const (
+	ImageReplicaPending = iota
+	ImageReplicaRunning
+	ImageReplicaComplete
+	ImageReplicaFailed
+)
+
+// ImageReplica holds the replication state of an image on a cluster node.
+type ImageReplica struct {
+	Node       string
+	Address    string
+	Status     int
+	Progress   int
+	Error      string
+	UpdateDate time.Time
+}
+
+// ImageReplicas returns the replication state of the image with the given
+// ID on each node either having it or it's being replicated to. Nodes
+// having the image without it being replicated to them (e.g. the node it
+// was created on) are reported as complete.
+func (c *Cluster) ImageReplicas(imageID int) ([]ImageReplica, error) {
+	replicas := []ImageReplica{}
+
+	err := c.Transaction(func(tx *ClusterTx) error {
+		rows, err := tx.tx.Query(`
+SELECT nodes.name, nodes.address, images_replicas.status, images_replicas.progress, images_replicas.error, images_replicas.updated_at
+  FROM images_replicas JOIN nodes ON nodes.id = images_replicas.node_id
+ WHERE images_replicas.image_id = ?
+ ORDER BY nodes.name`, imageID)
+		if err != nil {
+			return err
+		}
+		defer rows.Close()
+
+		for rows.Next() {
+			replica := ImageReplica{}
+			err := rows.Scan(&replica.Node, &replica.Address, &replica.Status, &replica.Progress, &replica.Error, &replica.UpdateDate)
+			if err != nil {
+				return err
+			}
+
+			replicas = append(replicas, replica)
+		}
+
+		err = rows.Err()
+		if err != nil {
+			return err
+		}
+
+		names, err := query.SelectStrings(tx.tx, `
+SELECT nodes.name FROM images_nodes JOIN nodes ON nodes.id = images_nodes.node_id
+ WHERE images_nodes.image_id = ?
+ ORDER BY nodes.name`, imageID)
+		if err != nil {
+			return err
+		}
+
+		for _, name := range names {
+			found := false
+			for i := range replicas {
+				if replicas[i].Node == name {
+					replicas[i].Status = ImageReplicaComplete
+					replicas[i].Progress = 100
+					found = true
+				}
+			}
+
+			if !found {
+				replicas = append(replicas, ImageReplica{
+					Node:     name,
+					Status:   ImageReplicaComplete,
+					Progress: 100,
+				})
+			}
+		}
+
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return replicas, nil
+}
+
+// ImageReplicaUpdate records the replication state of the image with the
+// given ID on the node with the given address.
+func (c *Cluster) ImageReplicaUpdate(imageID int, address string, status int, progress int, message string) error {
+	return c.Transaction(func(tx *ClusterTx) error {
+		_, err := tx.tx.Exec(`
+INSERT OR REPLACE INTO images_replicas (image_id, node_id, status, progress, error, updated_at)
+  SELECT ?, id, ?, ?, ?, ? FROM nodes WHERE address = ?`,
+			imageID, status, progress, message, time.Now().UTC(), address)
+		return err
+	})
+}
+
+// ImageGetNodesReplicating returns the addresses of the nodes the image with
+// the given ID is being replicated to, ignoring replications which haven't
+// made any progress since the given date.
+func (c *Cluster) ImageGetNodesReplicating(imageID int, since time.Time) ([]string, error) {
+	replicas, err := c.ImageReplicas(imageID)
+	if err != nil {
+		return nil, err
+	}
+
+	addresses := []string{}
+	for _, replica := range replicas {
+		if replica.Status != ImageReplicaPending && replica.Status != ImageReplicaRunning {
+			continue
+		}
+
+		if replica.UpdateDate.Before(since) {
+			continue
+		}
+
+		addresses = append(addresses, replica.Address)
+	}
+
+	return addresses, nil
+}
@@ -0,0 +1,66 @@
+package db_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/lxc/lxd/lxd/db"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// Track the replication of an image to another node.
+func TestImageReplicas(t *testing.T) {
+	cluster, cleanup := db.NewTestCluster(t)
+	defer cleanup()
+
+	err := cluster.ImageInsert(
+		"default", "abc", "x.gz", 16, false, false, "amd64", time.Now(), time.Now(), map[string]string{})
+	require.NoError(t, err)
+
+	err = cluster.Transaction(func(tx *db.ClusterTx) error {
+		_, err := tx.NodeAdd("node2", "1.2.3.4:666")
+		return err
+	})
+	require.NoError(t, err)
+
+	id, _, err := cluster.ImageGetFromAnyProject("abc")
+	require.NoError(t, err)
+
+	// The node the image was created on has it
+	replicas, err := cluster.ImageReplicas(id)
+	require.NoError(t, err)
+	require.Len(t, replicas, 1)
+	assert.Equal(t, "none", replicas[0].Node)
+	assert.Equal(t, db.ImageReplicaComplete, replicas[0].Status)
+
+	err = cluster.ImageReplicaUpdate(id, "1.2.3.4:666", db.ImageReplicaRunning, 42, "")
+	require.NoError(t, err)
+
+	replicas, err = cluster.ImageReplicas(id)
+	require.NoError(t, err)
+	require.Len(t, replicas, 2)
+	assert.Equal(t, "node2", replicas[0].Node)
+	assert.Equal(t, db.ImageReplicaRunning, replicas[0].Status)
+	assert.Equal(t, 42, replicas[0].Progress)
+
+	addresses, err := cluster.ImageGetNodesReplicating(id, time.Now().Add(-time.Minute))
+	require.NoError(t, err)
+	assert.Equal(t, []string{"1.2.3.4:666"}, addresses)
+
+	addresses, err = cluster.ImageGetNodesReplicating(id, time.Now().Add(time.Minute))
+	require.NoError(t, err)
+	assert.Len(t, addresses, 0)
+
+	err = cluster.ImageReplicaUpdate(id, "1.2.3.4:666", db.ImageReplicaFailed, 42, "Network unreachable")
+	require.NoError(t, err)
+
+	replicas, err = cluster.ImageReplicas(id)
+	require.NoError(t, err)
+	assert.Equal(t, db.ImageReplicaFailed, replicas[0].Status)
+	assert.Equal(t, "Network unreachable", replicas[0].Error)
+
+	addresses, err = cluster.ImageGetNodesReplicating(id, time.Now().Add(-time.Minute))
+	require.NoError(t, err)
+	assert.Len(t, addresses, 0)
+}
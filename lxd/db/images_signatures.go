@@ -0,0 +1,44 @@
+package db
+
+import (
+	"database/sql"
+)
+
+// ImageSignature holds the sigstore bundle of an image, and whether the image
+// is exempted from signature verification.
+type ImageSignature struct {
+	Bundle string
+	Exempt bool
+}
+
+// ImageSignatureGet returns the signature of the image with the given ID. An
+// image with no signature gets an empty one.
+func (c *Cluster) ImageSignatureGet(imageID int) (*ImageSignature, error) {
+	signature := &ImageSignature{}
+
+	err := c.Transaction(func(tx *ClusterTx) error {
+		err := tx.tx.QueryRow(
+			"SELECT bundle, exempt FROM images_signatures WHERE image_id=?", imageID).Scan(
+			&signature.Bundle, &signature.Exempt)
+		if err == sql.ErrNoRows {
+			return nil
+		}
+
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return signature, nil
+}
+
+// ImageSignatureUpdate sets the signature of the image with the given ID.
+func (c *Cluster) ImageSignatureUpdate(imageID int, signature ImageSignature) error {
+	return c.Transaction(func(tx *ClusterTx) error {
+		_, err := tx.tx.Exec(`
+INSERT OR REPLACE INTO images_signatures (image_id, bundle, exempt) VALUES (?, ?, ?)`,
+			imageID, signature.Bundle, signature.Exempt)
+		return err
+	})
+}
@@ -0,0 +1,43 @@
+package db_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/lxc/lxd/lxd/db"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// Set the signature of an image and drop it along with the image.
+func TestImageSignature(t *testing.T) {
+	cluster, cleanup := db.NewTestCluster(t)
+	defer cleanup()
+
+	err := cluster.ImageInsert(
+		"default", "abc", "x.gz", 16, false, false, "amd64", time.Now(), time.Now(), map[string]string{})
+	require.NoError(t, err)
+
+	id, _, err := cluster.ImageGetFromAnyProject("abc")
+	require.NoError(t, err)
+
+	signature, err := cluster.ImageSignatureGet(id)
+	require.NoError(t, err)
+	assert.Equal(t, &db.ImageSignature{}, signature)
+
+	err = cluster.ImageSignatureUpdate(id, db.ImageSignature{Bundle: "{}"})
+	require.NoError(t, err)
+
+	err = cluster.ImageSignatureUpdate(id, db.ImageSignature{Bundle: "{}", Exempt: true})
+	require.NoError(t, err)
+
+	signature, err = cluster.ImageSignatureGet(id)
+	require.NoError(t, err)
+	assert.Equal(t, &db.ImageSignature{Bundle: "{}", Exempt: true}, signature)
+
+	require.NoError(t, cluster.ImageDelete(id))
+
+	signature, err = cluster.ImageSignatureGet(id)
+	require.NoError(t, err)
+	assert.Equal(t, &db.ImageSignature{}, signature)
+}
@@ -0,0 +1,140 @@
+package db
+
+import (
+	"database/sql"
+)
+
+// NetworkForward holds a port forward of a network on this node.
+type NetworkForward struct {
+	ID            int64
+	ListenAddress string
+	TargetAddress string
+	Protocol      string
+	Description   string
+}
+
+func networkForwardScan(rows *sql.Rows) (*NetworkForward, error) {
+	forward := NetworkForward{}
+
+	err := rows.Scan(&forward.ID, &forward.ListenAddress, &forward.TargetAddress, &forward.Protocol, &forward.Description)
+	if err != nil {
+		return nil, err
+	}
+
+	return &forward, nil
+}
+
+// NetworkForwards returns the port forwards of the network with the given ID
+// on this node.
+func (c *Cluster) NetworkForwards(networkID int64) ([]NetworkForward, error) {
+	forwards := []NetworkForward{}
+
+	err := c.Transaction(func(tx *ClusterTx) error {
+		rows, err := tx.tx.Query(`
+SELECT id, listen_address, target_address, protocol, description
+  FROM networks_forwards
+ WHERE network_id=? AND node_id=?
+ ORDER BY id`, networkID, c.nodeID)
+		if err != nil {
+			return err
+		}
+		defer rows.Close()
+
+		for rows.Next() {
+			forward, err := networkForwardScan(rows)
+			if err != nil {
+				return err
+			}
+
+			forwards = append(forwards, *forward)
+		}
+
+		return rows.Err()
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return forwards, nil
+}
+
+// NetworkForwardGet returns the port forward with the given ID of the network
+// with the given ID on this node.
+func (c *Cluster) NetworkForwardGet(networkID int64, id int64) (*NetworkForward, error) {
+	var forward *NetworkForward
+
+	err := c.Transaction(func(tx *ClusterTx) error {
+		rows, err := tx.tx.Query(`
+SELECT id, listen_address, target_address, protocol, description
+  FROM networks_forwards
+ WHERE network_id=? AND node_id=? AND id=?`, networkID, c.nodeID, id)
+		if err != nil {
+			return err
+		}
+		defer rows.Close()
+
+		if !rows.Next() {
+			err := rows.Err()
+			if err != nil {
+				return err
+			}
+
+			return ErrNoSuchObject
+		}
+
+		forward, err = networkForwardScan(rows)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return forward, nil
+}
+
+// NetworkForwardCreate adds a new port forward to the network with the given
+// ID on this node and returns its ID.
+func (c *Cluster) NetworkForwardCreate(networkID int64, forward NetworkForward) (int64, error) {
+	var id int64
+
+	err := c.Transaction(func(tx *ClusterTx) error {
+		result, err := tx.tx.Exec(`
+INSERT INTO networks_forwards (network_id, node_id, listen_address, target_address, protocol, description)
+     VALUES (?, ?, ?, ?, ?, ?)`,
+			networkID, c.nodeID, forward.ListenAddress, forward.TargetAddress, forward.Protocol, forward.Description)
+		if err != nil {
+			return err
+		}
+
+		id, err = result.LastInsertId()
+		return err
+	})
+	if err != nil {
+		return -1, err
+	}
+
+	return id, nil
+}
+
+// NetworkForwardDelete deletes the port forward with the given ID of the
+// network with the given ID on this node.
+func (c *Cluster) NetworkForwardDelete(networkID int64, id int64) error {
+	err := c.Transaction(func(tx *ClusterTx) error {
+		result, err := tx.tx.Exec("DELETE FROM networks_forwards WHERE network_id=? AND node_id=? AND id=?", networkID, c.nodeID, id)
+		if err != nil {
+			return err
+		}
+
+		n, err := result.RowsAffected()
+		if err != nil {
+			return err
+		}
+
+		if n != 1 {
+			return ErrNoSuchObject
+		}
+
+		return nil
+	})
+	return err
+}
@@ -0,0 +1,156 @@
+package db
+
+import (
+	"database/sql"
+	"encoding/json"
+
+	"github.com/lxc/lxd/shared/api"
+)
+
+// NetworkLoadBalancer holds a load balancer of a network on this node.
+type NetworkLoadBalancer struct {
+	ID                  int64
+	ListenAddress       string
+	Protocol            string
+	Algorithm           string
+	Backends            []api.NetworkLoadBalancerBackend
+	HealthCheckInterval int
+	Description         string
+}
+
+func networkLoadBalancerScan(rows *sql.Rows) (*NetworkLoadBalancer, error) {
+	lb := NetworkLoadBalancer{}
+	var backends string
+
+	err := rows.Scan(&lb.ID, &lb.ListenAddress, &lb.Protocol, &lb.Algorithm, &backends, &lb.HealthCheckInterval, &lb.Description)
+	if err != nil {
+		return nil, err
+	}
+
+	err = json.Unmarshal([]byte(backends), &lb.Backends)
+	if err != nil {
+		return nil, err
+	}
+
+	return &lb, nil
+}
+
+// NetworkLoadBalancers returns the load balancers of the network with the
+// given ID on this node.
+func (c *Cluster) NetworkLoadBalancers(networkID int64) ([]NetworkLoadBalancer, error) {
+	lbs := []NetworkLoadBalancer{}
+
+	err := c.Transaction(func(tx *ClusterTx) error {
+		rows, err := tx.tx.Query(`
+SELECT id, listen_address, protocol, algorithm, backends, health_check_interval, description
+  FROM networks_load_balancers
+ WHERE network_id=? AND node_id=?
+ ORDER BY id`, networkID, c.nodeID)
+		if err != nil {
+			return err
+		}
+		defer rows.Close()
+
+		for rows.Next() {
+			lb, err := networkLoadBalancerScan(rows)
+			if err != nil {
+				return err
+			}
+
+			lbs = append(lbs, *lb)
+		}
+
+		return rows.Err()
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return lbs, nil
+}
+
+// NetworkLoadBalancerGet returns the load balancer with the given ID of the
+// network with the given ID on this node.
+func (c *Cluster) NetworkLoadBalancerGet(networkID int64, id int64) (*NetworkLoadBalancer, error) {
+	var lb *NetworkLoadBalancer
+
+	err := c.Transaction(func(tx *ClusterTx) error {
+		rows, err := tx.tx.Query(`
+SELECT id, listen_address, protocol, algorithm, backends, health_check_interval, description
+  FROM networks_load_balancers
+ WHERE network_id=? AND node_id=? AND id=?`, networkID, c.nodeID, id)
+		if err != nil {
+			return err
+		}
+		defer rows.Close()
+
+		if !rows.Next() {
+			err := rows.Err()
+			if err != nil {
+				return err
+			}
+
+			return ErrNoSuchObject
+		}
+
+		lb, err = networkLoadBalancerScan(rows)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return lb, nil
+}
+
+// NetworkLoadBalancerCreate adds a new load balancer to the network with the
+// given ID on this node and returns its ID.
+func (c *Cluster) NetworkLoadBalancerCreate(networkID int64, lb NetworkLoadBalancer) (int64, error) {
+	backends, err := json.Marshal(lb.Backends)
+	if err != nil {
+		return -1, err
+	}
+
+	var id int64
+
+	err = c.Transaction(func(tx *ClusterTx) error {
+		result, err := tx.tx.Exec(`
+INSERT INTO networks_load_balancers (network_id, node_id, listen_address, protocol, algorithm, backends, health_check_interval, description)
+     VALUES (?, ?, ?, ?, ?, ?, ?, ?)`,
+			networkID, c.nodeID, lb.ListenAddress, lb.Protocol, lb.Algorithm, string(backends), lb.HealthCheckInterval, lb.Description)
+		if err != nil {
+			return err
+		}
+
+		id, err = result.LastInsertId()
+		return err
+	})
+	if err != nil {
+		return -1, err
+	}
+
+	return id, nil
+}
+
+// NetworkLoadBalancerDelete deletes the load balancer with the given ID of
+// the network with the given ID on this node.
+func (c *Cluster) NetworkLoadBalancerDelete(networkID int64, id int64) error {
+	err := c.Transaction(func(tx *ClusterTx) error {
+		result, err := tx.tx.Exec("DELETE FROM networks_load_balancers WHERE network_id=? AND node_id=? AND id=?", networkID, c.nodeID, id)
+		if err != nil {
+			return err
+		}
+
+		n, err := result.RowsAffected()
+		if err != nil {
+			return err
+		}
+
+		if n != 1 {
+			return ErrNoSuchObject
+		}
+
+		return nil
+	})
+	return err
+}
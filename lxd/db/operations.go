@@ -62,6 +62,9 @@ const (
 	OperationInstanceTypesUpdate
 	OperationBackupsExpire
 	OperationSnapshotsExpire
+	OperationImagesGC
+	OperationStoragePoolCompress
+	OperationStoragePoolDedupScrub
 )
 
 // Description return a human-readable description of the operation type.
@@ -157,6 +160,12 @@ func (t OperationType) Description() string {
 		return "Cleaning up expired backups"
 	case OperationSnapshotsExpire:
 		return "Cleaning up expired snapshots"
+	case OperationImagesGC:
+		return "Removing unused images"
+	case OperationStoragePoolCompress:
+		return "Compressing storage pool"
+	case OperationStoragePoolDedupScrub:
+		return "Scrubbing storage pool"
 	default:
 		return "Executing operation"
 
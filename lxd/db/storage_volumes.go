@@ -288,6 +288,62 @@ func StorageVolumeConfigClear(tx *sql.Tx, volumeID int64) error {
 	return nil
 }
 
+// StorageVolumeEncryptionKeyGet returns the encryption key of a storage volume.
+func (c *Cluster) StorageVolumeEncryptionKeyGet(volumeID int64) (string, error) {
+	key := ""
+	query := "SELECT key FROM storage_volumes_encryption_keys WHERE storage_volume_id=?"
+	inargs := []interface{}{volumeID}
+	outargs := []interface{}{&key}
+
+	err := dbQueryRowScan(c.db, query, inargs, outargs)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return "", ErrNoSuchObject
+		}
+		return "", err
+	}
+
+	return key, nil
+}
+
+// StorageVolumeEncryptionKeySet sets the encryption key of a storage volume,
+// replacing the existing one.
+func (c *Cluster) StorageVolumeEncryptionKeySet(volumeID int64, key string) error {
+	return exec(c.db, "INSERT OR REPLACE INTO storage_volumes_encryption_keys (storage_volume_id, key) VALUES (?, ?)", volumeID, key)
+}
+
+// StorageVolumeEncryptionNextKeyGet returns the key the encryption key of a
+// storage volume is being replaced with, if any.
+func (c *Cluster) StorageVolumeEncryptionNextKeyGet(volumeID int64) (string, error) {
+	key := ""
+	query := "SELECT next_key FROM storage_volumes_encryption_keys WHERE storage_volume_id=?"
+	inargs := []interface{}{volumeID}
+	outargs := []interface{}{&key}
+
+	err := dbQueryRowScan(c.db, query, inargs, outargs)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return "", ErrNoSuchObject
+		}
+		return "", err
+	}
+
+	return key, nil
+}
+
+// StorageVolumeEncryptionNextKeySet records the key the encryption key of a
+// storage volume is being replaced with, both being kept until the
+// replacement is committed.
+func (c *Cluster) StorageVolumeEncryptionNextKeySet(volumeID int64, key string) error {
+	return exec(c.db, "UPDATE storage_volumes_encryption_keys SET next_key=? WHERE storage_volume_id=?", key, volumeID)
+}
+
+// StorageVolumeEncryptionKeyCommit replaces the encryption key of a storage
+// volume with the next one.
+func (c *Cluster) StorageVolumeEncryptionKeyCommit(volumeID int64) error {
+	return exec(c.db, "UPDATE storage_volumes_encryption_keys SET key=next_key, next_key='' WHERE storage_volume_id=? AND next_key != ''", volumeID)
+}
+
 // Get the IDs of all volumes with the given name and type associated with the
 // given pool, regardless of their node_id column.
 func storageVolumeIDsGet(tx *sql.Tx, project, volumeName string, volumeType int, poolID int64) ([]int64, error) {
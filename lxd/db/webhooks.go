@@ -0,0 +1,133 @@
+package db
+
+import (
+	"database/sql"
+	"strings"
+)
+
+// Webhook holds the configuration of a webhook.
+type Webhook struct {
+	ID       int64
+	URL      string
+	Secret   string
+	Events   []string
+	RetryMax int
+}
+
+func webhookScan(rows *sql.Rows) (*Webhook, error) {
+	webhook := Webhook{}
+	var events string
+
+	err := rows.Scan(&webhook.ID, &webhook.URL, &webhook.Secret, &events, &webhook.RetryMax)
+	if err != nil {
+		return nil, err
+	}
+
+	if events != "" {
+		webhook.Events = strings.Split(events, ",")
+	}
+
+	return &webhook, nil
+}
+
+// Webhooks returns all the webhooks.
+func (c *Cluster) Webhooks() ([]Webhook, error) {
+	webhooks := []Webhook{}
+
+	err := c.Transaction(func(tx *ClusterTx) error {
+		rows, err := tx.tx.Query("SELECT id, url, secret, events, retry_max FROM webhooks ORDER BY id")
+		if err != nil {
+			return err
+		}
+		defer rows.Close()
+
+		for rows.Next() {
+			webhook, err := webhookScan(rows)
+			if err != nil {
+				return err
+			}
+
+			webhooks = append(webhooks, *webhook)
+		}
+
+		return rows.Err()
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return webhooks, nil
+}
+
+// WebhookGet returns the webhook with the given ID.
+func (c *Cluster) WebhookGet(id int64) (*Webhook, error) {
+	var webhook *Webhook
+
+	err := c.Transaction(func(tx *ClusterTx) error {
+		rows, err := tx.tx.Query("SELECT id, url, secret, events, retry_max FROM webhooks WHERE id=?", id)
+		if err != nil {
+			return err
+		}
+		defer rows.Close()
+
+		if !rows.Next() {
+			err := rows.Err()
+			if err != nil {
+				return err
+			}
+
+			return ErrNoSuchObject
+		}
+
+		webhook, err = webhookScan(rows)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return webhook, nil
+}
+
+// WebhookCreate adds a new webhook and returns its ID.
+func (c *Cluster) WebhookCreate(webhook Webhook) (int64, error) {
+	var id int64
+
+	err := c.Transaction(func(tx *ClusterTx) error {
+		result, err := tx.tx.Exec("INSERT INTO webhooks (url, secret, events, retry_max) VALUES (?, ?, ?, ?)",
+			webhook.URL, webhook.Secret, strings.Join(webhook.Events, ","), webhook.RetryMax)
+		if err != nil {
+			return err
+		}
+
+		id, err = result.LastInsertId()
+		return err
+	})
+	if err != nil {
+		return -1, err
+	}
+
+	return id, nil
+}
+
+// WebhookDelete deletes the webhook with the given ID.
+func (c *Cluster) WebhookDelete(id int64) error {
+	err := c.Transaction(func(tx *ClusterTx) error {
+		result, err := tx.tx.Exec("DELETE FROM webhooks WHERE id=?", id)
+		if err != nil {
+			return err
+		}
+
+		n, err := result.RowsAffected()
+		if err != nil {
+			return err
+		}
+
+		if n != 1 {
+			return ErrNoSuchObject
+		}
+
+		return nil
+	})
+	return err
+}
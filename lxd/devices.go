@@ -18,6 +18,7 @@ import (
 	"sort"
 	"strconv"
 	"strings"
+	"sync"
 	"syscall"
 	"unsafe"
 
@@ -206,6 +207,139 @@ func deviceWantsAllGPUs(m map[string]string) bool {
 	return m["vendorid"] == "" && m["productid"] == "" && m["id"] == "" && m["pci"] == ""
 }
 
+// NVIDIA MPS (Multi-Process Service) handling
+var deviceMPSLock sync.Mutex
+
+func deviceMPSPipePath() string {
+	return shared.VarPath("nvidia-mps", "pipe")
+}
+
+func deviceWantsMPS(m map[string]string) bool {
+	return m["type"] == "gpu" && shared.IsTrue(m["gpu.mps.enabled"])
+}
+
+func deviceMPSRunning() bool {
+	return shared.PathExists(filepath.Join(deviceMPSPipePath(), "control"))
+}
+
+func deviceMPSCommand() *exec.Cmd {
+	cmd := exec.Command("nvidia-cuda-mps-control")
+	cmd.Env = append(os.Environ(),
+		fmt.Sprintf("CUDA_MPS_PIPE_DIRECTORY=%s", deviceMPSPipePath()),
+		fmt.Sprintf("CUDA_MPS_LOG_DIRECTORY=%s", shared.VarPath("nvidia-mps", "log")))
+
+	return cmd
+}
+
+// deviceMPSStart spawns the MPS control daemon unless it's already running.
+func deviceMPSStart() error {
+	deviceMPSLock.Lock()
+	defer deviceMPSLock.Unlock()
+
+	if deviceMPSRunning() {
+		return nil
+	}
+
+	_, err := exec.LookPath("nvidia-cuda-mps-control")
+	if err != nil {
+		return fmt.Errorf("Couldn't find nvidia-cuda-mps-control, required for gpu.mps.enabled")
+	}
+
+	for _, dir := range []string{deviceMPSPipePath(), shared.VarPath("nvidia-mps", "log")} {
+		err := os.MkdirAll(dir, 0755)
+		if err != nil {
+			return err
+		}
+	}
+
+	cmd := deviceMPSCommand()
+	cmd.Args = append(cmd.Args, "-d")
+	output, err := cmd.CombinedOutput()
+	if err != nil {
+		return fmt.Errorf("Failed to start the NVIDIA MPS control daemon: %s: %s", strings.TrimSpace(string(output)), err)
+	}
+
+	logger.Info("Started the NVIDIA MPS control daemon")
+	return nil
+}
+
+// deviceMPSSetLimit limits the GPU threads of the MPS server of the given host
+// uid, starting that server if needed. MPS runs a server per user, so the
+// limit applies to all the CUDA clients running as that uid, however they
+// were started.
+func deviceMPSSetLimit(uid int64, percentage string) error {
+	deviceMPSLock.Lock()
+	defer deviceMPSLock.Unlock()
+
+	cmd := deviceMPSCommand()
+	cmd.Stdin = strings.NewReader(fmt.Sprintf("start_server -uid %d\nget_server_list\n", uid))
+	output, err := cmd.CombinedOutput()
+	if err != nil {
+		return fmt.Errorf("Failed to start the NVIDIA MPS server: %s: %s", strings.TrimSpace(string(output)), err)
+	}
+
+	for _, field := range strings.Fields(string(output)) {
+		pid, err := strconv.Atoi(field)
+		if err != nil {
+			continue
+		}
+
+		fi, err := os.Stat(fmt.Sprintf("/proc/%d", pid))
+		if err != nil || int64(fi.Sys().(*syscall.Stat_t).Uid) != uid {
+			continue
+		}
+
+		cmd := deviceMPSCommand()
+		cmd.Stdin = strings.NewReader(fmt.Sprintf("set_active_thread_percentage %d %s\n", pid, percentage))
+		output, err := cmd.CombinedOutput()
+		if err != nil {
+			return fmt.Errorf("Failed to limit the NVIDIA MPS server: %s: %s", strings.TrimSpace(string(output)), err)
+		}
+
+		return nil
+	}
+
+	return fmt.Errorf("Couldn't find the NVIDIA MPS server of uid %d", uid)
+}
+
+// deviceMPSStop shuts down the MPS control daemon once no running container
+// other than the one given is using it anymore.
+func deviceMPSStop(s *state.State, project string, name string) error {
+	deviceMPSLock.Lock()
+	defer deviceMPSLock.Unlock()
+
+	if !deviceMPSRunning() {
+		return nil
+	}
+
+	containers, err := containerLoadNodeAll(s)
+	if err != nil {
+		return err
+	}
+
+	for _, c := range containers {
+		if (c.Project() == project && c.Name() == name) || !c.IsRunning() {
+			continue
+		}
+
+		for _, m := range c.ExpandedDevices() {
+			if deviceWantsMPS(m) {
+				return nil
+			}
+		}
+	}
+
+	cmd := deviceMPSCommand()
+	cmd.Stdin = strings.NewReader("quit\n")
+	output, err := cmd.CombinedOutput()
+	if err != nil {
+		return fmt.Errorf("Failed to stop the NVIDIA MPS control daemon: %s: %s", strings.TrimSpace(string(output)), err)
+	}
+
+	logger.Info("Stopped the NVIDIA MPS control daemon")
+	return nil
+}
+
 func deviceLoadGpu(all bool) ([]gpuDevice, []nvidiaGpuDevice, error) {
 	const DRM_PATH = "/sys/class/drm/"
 	var gpus []gpuDevice
@@ -844,8 +978,16 @@ func deviceTaskBalance(s *state.State) {
 		return
 	}
 
+	// NUMA nodes of the host, for the containers bound to some of them
+	numaNodes, err := deviceNumaNodes(deviceNumaPath)
+	if err != nil {
+		logger.Warn("Error reading the host's NUMA nodes", log.Ctx{"err": err})
+		numaNodes = map[int][]int{}
+	}
+
 	fixedContainers := map[int][]container{}
 	balancedContainers := map[container]int{}
+	numaContainers := map[container][]int{}
 	for _, c := range containers {
 		conf := c.ExpandedConfig()
 		cpulimit, ok := conf["limits.cpu"]
@@ -857,6 +999,26 @@ func deviceTaskBalance(s *state.State) {
 			continue
 		}
 
+		if conf["limits.cpu.nodes"] != "" {
+			nodes, err := deviceNumaParseNodes(conf["limits.cpu.nodes"], numaNodes)
+			if err != nil {
+				logger.Error("balance: Invalid NUMA nodes", log.Ctx{"name": c.Name(), "err": err})
+			} else {
+				numaContainers[c] = nodes
+
+				// Default to all the CPUs of the nodes
+				nodeCpus := deviceNumaCPUs(numaNodes, nodes, cpus)
+				if conf["limits.cpu"] == "" && len(nodeCpus) > 0 {
+					ids := []string{}
+					for _, id := range nodeCpus {
+						ids = append(ids, fmt.Sprintf("%d", id))
+					}
+
+					cpulimit = strings.Join(ids, ",")
+				}
+			}
+		}
+
 		count, err := strconv.Atoi(cpulimit)
 		if err == nil {
 			// Load-balance
@@ -920,7 +1082,28 @@ func deviceTaskBalance(s *state.State) {
 		sortedUsage = append(sortedUsage, value)
 	}
 
+	numaMems := map[container][]int{}
 	for ctn, count := range balancedContainers {
+		nodes, ok := numaContainers[ctn]
+		if ok {
+			// Pick the CPUs among those of the NUMA nodes
+			cpuUsage := map[int]int{}
+			for id, cpu := range usage {
+				cpuUsage[id] = *cpu.count
+			}
+
+			picked, used := deviceNumaPick(numaNodes, nodes, cpuUsage, count)
+			if len(picked) > 0 {
+				for _, id := range picked {
+					pinning[ctn] = append(pinning[ctn], usage[id].strId)
+					*usage[id].count += 1
+				}
+
+				numaMems[ctn] = used
+				continue
+			}
+		}
+
 		sort.Sort(sortedUsage)
 		for _, cpu := range sortedUsage {
 			if count == 0 {
@@ -951,7 +1134,38 @@ func deviceTaskBalance(s *state.State) {
 		if err != nil {
 			logger.Error("balance: Unable to set cpuset", log.Ctx{"name": ctn.Name(), "err": err, "value": strings.Join(set, ",")})
 		}
+
+		// Bind the memory to the NUMA nodes of the container, the memory
+		// of the others being left alone
+		nodes, ok := numaMems[ctn]
+		if !ok {
+			nodes, ok = numaContainers[ctn]
+		}
+		if !ok {
+			continue
+		}
+
+		mems := deviceNumaList(nodes)
+		current, err := ctn.CGroupGet("cpuset.mems")
+		if err != nil || current == mems {
+			continue
+		}
+
+		err = ctn.CGroupSet("cpuset.mems", mems)
+		if err != nil {
+			logger.Error("balance: Unable to set cpuset.mems", log.Ctx{"name": ctn.Name(), "err": err, "value": mems})
+		}
+	}
+}
+
+// deviceHostMems returns the memory nodes available to the containers.
+func deviceHostMems() string {
+	mems, err := cGroupGet("cpuset", "/", "cpuset.effective_mems")
+	if err != nil {
+		mems, _ = cGroupGet("cpuset", "/", "cpuset.mems")
 	}
+
+	return mems
 }
 
 func deviceNetworkPriority(s *state.State, netif string) {
@@ -1180,6 +1394,28 @@ func deviceNextVeth() string {
 	return "veth" + hex.EncodeToString(randBytes)
 }
 
+// Matches the valid names of kernel modules, which mustn't be taken for a
+// modprobe option.
+var deviceKernelModuleRegexp = regexp.MustCompile(`^[A-Za-z0-9_]+[A-Za-z0-9_-]*$`)
+
+func deviceLoadKernelModules(name string, m map[string]string) error {
+	for _, module := range strings.Fields(m["kernel_modules"]) {
+		err := util.LoadModule(module)
+		if err == nil {
+			continue
+		}
+
+		if shared.IsTrue(m["kernel_modules_optional"]) {
+			logger.Warn("Failed to load optional kernel module", log.Ctx{"device": name, "module": module, "err": err})
+			continue
+		}
+
+		return fmt.Errorf("Failed to load kernel module '%s' required by device '%s': %s", module, name, err)
+	}
+
+	return nil
+}
+
 func deviceRemoveInterface(nic string) error {
 	_, err := shared.RunCommand("ip", "link", "del", "dev", nic)
 	return err
@@ -1314,6 +1550,40 @@ func deviceParseCPU(cpuAllowance string, cpuPriority string) (string, string, st
 	return fmt.Sprintf("%d", cpuShares), cpuCfsQuota, cpuCfsPeriod, nil
 }
 
+// deviceParseCPUWeight converts a priority.cpu class or weight, expressed on
+// the cgroup2 cpu.weight scale (100 being the default), into cpu.shares.
+func deviceParseCPUWeight(value string) (string, error) {
+	weight, err := shared.ParsePriorityWeight(value)
+	if err != nil {
+		return "", err
+	}
+
+	// Minimum valid value is 2
+	cpuShares := weight * 1024 / 100
+	if cpuShares < 2 {
+		cpuShares = 2
+	}
+
+	return fmt.Sprintf("%d", cpuShares), nil
+}
+
+// deviceParseIOWeight converts a priority.io class or weight, expressed on the
+// cgroup2 io.weight scale, into blkio.weight which only goes from 10 to 1000.
+func deviceParseIOWeight(value string) (string, error) {
+	weight, err := shared.ParsePriorityWeight(value)
+	if err != nil {
+		return "", err
+	}
+
+	if weight < 10 {
+		weight = 10
+	} else if weight > 1000 {
+		weight = 1000
+	}
+
+	return fmt.Sprintf("%d", weight), nil
+}
+
 func deviceGetParentBlocks(path string) ([]string, error) {
 	var devices []string
 	var device []string
@@ -0,0 +1,183 @@
+package main
+
+import (
+	"fmt"
+	"io/ioutil"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/lxc/lxd/shared"
+)
+
+// Where the kernel exposes the NUMA nodes
+const deviceNumaPath = "/sys/devices/system/node"
+
+// deviceNumaNodes returns the CPUs of each NUMA node found in the given
+// sysfs directory.
+func deviceNumaNodes(path string) (map[int][]int, error) {
+	entries, err := filepath.Glob(filepath.Join(path, "node[0-9]*"))
+	if err != nil {
+		return nil, err
+	}
+
+	nodes := map[int][]int{}
+	for _, entry := range entries {
+		id, err := strconv.Atoi(strings.TrimPrefix(filepath.Base(entry), "node"))
+		if err != nil {
+			continue
+		}
+
+		content, err := ioutil.ReadFile(filepath.Join(entry, "cpulist"))
+		if err != nil {
+			return nil, err
+		}
+
+		cpus := []int{}
+		cpulist := strings.TrimSpace(string(content))
+		if cpulist != "" {
+			cpus, err = parseCpuset(cpulist)
+			if err != nil {
+				return nil, err
+			}
+		}
+
+		nodes[id] = cpus
+	}
+
+	return nodes, nil
+}
+
+// deviceNumaParseNodes parses the value of limits.cpu.nodes, checking that
+// the nodes exist.
+func deviceNumaParseNodes(value string, nodes map[int][]int) ([]int, error) {
+	wanted, err := parseCpuset(value)
+	if err != nil {
+		return nil, fmt.Errorf("Invalid NUMA node list: %s", value)
+	}
+
+	result := []int{}
+	for _, id := range wanted {
+		_, ok := nodes[id]
+		if !ok {
+			return nil, fmt.Errorf("NUMA node %d doesn't exist", id)
+		}
+
+		if !shared.IntInSlice(id, result) {
+			result = append(result, id)
+		}
+	}
+
+	sort.Ints(result)
+	return result, nil
+}
+
+// deviceNumaValidate checks that the NUMA nodes of limits.cpu.nodes exist on
+// this system.
+func deviceNumaValidate(value string) error {
+	nodes, err := deviceNumaNodes(deviceNumaPath)
+	if err != nil {
+		return err
+	}
+
+	if len(nodes) == 0 {
+		return fmt.Errorf("No NUMA node found on this system")
+	}
+
+	_, err = deviceNumaParseNodes(value, nodes)
+	return err
+}
+
+// deviceNumaPick picks count CPUs of the wanted NUMA nodes, going through the
+// nodes with the most idle CPUs first and through the least used CPUs of each
+// node first, so that the container spans as few nodes as possible. usage
+// holds the number of containers using each CPU (only the available CPUs
+// being listed). The picked CPUs are returned along with their nodes.
+func deviceNumaPick(nodes map[int][]int, wanted []int, usage map[int]int, count int) ([]int, []int) {
+	type numaNode struct {
+		id   int
+		cpus []int
+		idle int
+	}
+
+	candidates := []numaNode{}
+	for _, id := range wanted {
+		node := numaNode{id: id}
+		for _, cpu := range nodes[id] {
+			used, ok := usage[cpu]
+			if !ok {
+				continue
+			}
+
+			node.cpus = append(node.cpus, cpu)
+			if used == 0 {
+				node.idle++
+			}
+		}
+
+		sort.SliceStable(node.cpus, func(i, j int) bool {
+			return usage[node.cpus[i]] < usage[node.cpus[j]]
+		})
+
+		candidates = append(candidates, node)
+	}
+
+	sort.SliceStable(candidates, func(i, j int) bool {
+		return candidates[i].idle > candidates[j].idle
+	})
+
+	cpus := []int{}
+	used := []int{}
+	for _, node := range candidates {
+		if len(cpus) >= count {
+			break
+		}
+
+		for _, cpu := range node.cpus {
+			if len(cpus) >= count {
+				break
+			}
+
+			cpus = append(cpus, cpu)
+		}
+
+		if len(node.cpus) > 0 {
+			used = append(used, node.id)
+		}
+	}
+
+	// Memory only nodes
+	for _, id := range wanted {
+		if len(nodes[id]) == 0 {
+			used = append(used, id)
+		}
+	}
+
+	sort.Ints(used)
+	return cpus, used
+}
+
+// deviceNumaCPUs returns all the available CPUs of the given NUMA nodes.
+func deviceNumaCPUs(nodes map[int][]int, wanted []int, available []int) []int {
+	cpus := []int{}
+	for _, id := range wanted {
+		for _, cpu := range nodes[id] {
+			if shared.IntInSlice(cpu, available) {
+				cpus = append(cpus, cpu)
+			}
+		}
+	}
+
+	return cpus
+}
+
+// deviceNumaList formats a list of NUMA nodes as accepted by cpuset.mems.
+func deviceNumaList(nodes []int) string {
+	ids := []string{}
+	for _, id := range nodes {
+		ids = append(ids, strconv.Itoa(id))
+	}
+
+	return strings.Join(ids, ",")
+}
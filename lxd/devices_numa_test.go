@@ -0,0 +1,80 @@
+package main
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDeviceNumaNodes(t *testing.T) {
+	dir, err := ioutil.TempDir("", "lxd-numa-")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	for node, cpulist := range map[string]string{"node0": "0-3\n", "node1": "4,5,6-7\n", "node2": "\n"} {
+		require.NoError(t, os.Mkdir(filepath.Join(dir, node), 0755))
+		require.NoError(t, ioutil.WriteFile(filepath.Join(dir, node, "cpulist"), []byte(cpulist), 0644))
+	}
+
+	// Not a node
+	require.NoError(t, os.Mkdir(filepath.Join(dir, "power"), 0755))
+
+	nodes, err := deviceNumaNodes(dir)
+	require.NoError(t, err)
+	assert.Equal(t, map[int][]int{0: {0, 1, 2, 3}, 1: {4, 5, 6, 7}, 2: {}}, nodes)
+}
+
+func TestDeviceNumaParseNodes(t *testing.T) {
+	nodes := map[int][]int{0: {0, 1}, 1: {2, 3}, 2: {4, 5}}
+
+	wanted, err := deviceNumaParseNodes("2,0", nodes)
+	require.NoError(t, err)
+	assert.Equal(t, []int{0, 2}, wanted)
+
+	wanted, err = deviceNumaParseNodes("0-1,1", nodes)
+	require.NoError(t, err)
+	assert.Equal(t, []int{0, 1}, wanted)
+
+	_, err = deviceNumaParseNodes("0,3", nodes)
+	assert.EqualError(t, err, "NUMA node 3 doesn't exist")
+
+	_, err = deviceNumaParseNodes("a", nodes)
+	assert.Error(t, err)
+}
+
+func TestDeviceNumaPick(t *testing.T) {
+	nodes := map[int][]int{0: {0, 1, 2, 3}, 1: {4, 5, 6, 7}, 2: {}}
+
+	// Node 1 has the most idle CPUs
+	usage := map[int]int{0: 1, 1: 0, 2: 2, 3: 1, 4: 0, 5: 1, 6: 0, 7: 0}
+	cpus, used := deviceNumaPick(nodes, []int{0, 1}, usage, 2)
+	assert.Equal(t, []int{4, 6}, cpus)
+	assert.Equal(t, []int{1}, used)
+
+	// Spanning over both nodes
+	cpus, used = deviceNumaPick(nodes, []int{0, 1}, usage, 6)
+	assert.Equal(t, []int{4, 6, 7, 5, 1, 0}, cpus)
+	assert.Equal(t, []int{0, 1}, used)
+
+	// Unavailable CPUs are skipped and memory only nodes kept
+	delete(usage, 4)
+	cpus, used = deviceNumaPick(nodes, []int{1, 2}, usage, 8)
+	assert.Equal(t, []int{6, 7, 5}, cpus)
+	assert.Equal(t, []int{1, 2}, used)
+}
+
+func TestDeviceNumaCPUs(t *testing.T) {
+	nodes := map[int][]int{0: {0, 1, 2, 3}, 1: {4, 5, 6, 7}}
+
+	assert.Equal(t, []int{0, 1, 2, 3}, deviceNumaCPUs(nodes, []int{0}, []int{0, 1, 2, 3, 4, 5, 6, 7}))
+	assert.Equal(t, []int{1, 2, 5}, deviceNumaCPUs(nodes, []int{0, 1}, []int{1, 2, 5}))
+}
+
+func TestDeviceNumaList(t *testing.T) {
+	assert.Equal(t, "0,2,3", deviceNumaList([]int{0, 2, 3}))
+	assert.Equal(t, "", deviceNumaList([]int{}))
+}
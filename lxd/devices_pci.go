@@ -0,0 +1,430 @@
+package main
+
+import (
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/lxc/lxd/lxd/db"
+	"github.com/lxc/lxd/lxd/types"
+	"github.com/lxc/lxd/lxd/util"
+	"github.com/lxc/lxd/shared"
+	"github.com/lxc/lxd/shared/api"
+	"github.com/lxc/lxd/shared/logger"
+
+	log "github.com/lxc/lxd/shared/log15"
+)
+
+var pciResourcesCmd = Command{
+	name: "resources/pci",
+	get:  pciResourcesGet,
+}
+
+const (
+	sysBusPCI       = "/sys/bus/pci"
+	sysIOMMUGroups  = "/sys/kernel/iommu_groups"
+	pciVFIODriver   = "vfio-pci"
+	pciBridgeClass  = "0x0604"
+	pciVFIOTimeout  = 5 * time.Second
+	pciVFIOInterval = 100 * time.Millisecond
+)
+
+var pciAddressRegexp = regexp.MustCompile(`^[0-9a-f]{4}:[0-9a-f]{2}:[0-9a-f]{2}\.[0-7]$`)
+
+// pciDevice is a PCI device of the host, as found in /sys/bus/pci/devices.
+type pciDevice struct {
+	address    string
+	driver     string
+	class      string
+	vendorID   string
+	productID  string
+	iommuGroup string
+}
+
+// pciLoadDevice reads the details of a PCI device from sysfs.
+func pciLoadDevice(address string) (pciDevice, error) {
+	devicePath := filepath.Join(sysBusPCI, "devices", address)
+	if !shared.PathExists(devicePath) {
+		return pciDevice{}, fmt.Errorf("PCI device %s not found", address)
+	}
+
+	dev := pciDevice{address: address}
+
+	read := func(name string) string {
+		content, err := ioutil.ReadFile(filepath.Join(devicePath, name))
+		if err != nil {
+			return ""
+		}
+
+		return strings.TrimPrefix(strings.TrimSpace(string(content)), "0x")
+	}
+
+	dev.vendorID = read("vendor")
+	dev.productID = read("device")
+	dev.class = "0x" + read("class")
+
+	link, err := os.Readlink(filepath.Join(devicePath, "driver"))
+	if err == nil {
+		dev.driver = filepath.Base(link)
+	}
+
+	link, err = os.Readlink(filepath.Join(devicePath, "iommu_group"))
+	if err == nil {
+		dev.iommuGroup = filepath.Base(link)
+	}
+
+	return dev, nil
+}
+
+// pciIsBridge returns whether a PCI class is the one of PCI bridges, which
+// are part of IOMMU groups but can't be bound to vfio-pci.
+func pciIsBridge(class string) bool {
+	return strings.HasPrefix(class, pciBridgeClass)
+}
+
+// pciIOMMUGroupDevices returns the addresses of the devices of an IOMMU
+// group.
+func pciIOMMUGroupDevices(group string) ([]string, error) {
+	ents, err := ioutil.ReadDir(filepath.Join(sysIOMMUGroups, group, "devices"))
+	if err != nil {
+		return nil, err
+	}
+
+	addresses := []string{}
+	for _, ent := range ents {
+		addresses = append(addresses, ent.Name())
+	}
+
+	sort.Strings(addresses)
+	return addresses, nil
+}
+
+// pciBindDriver unbinds a PCI device from its current driver and binds it to
+// the given one, the device going back to the default driver of its vendor
+// and product if empty.
+func pciBindDriver(dev pciDevice, driver string) error {
+	devicePath := filepath.Join(sysBusPCI, "devices", dev.address)
+
+	// Make sure the kernel picks the right driver when probing
+	override := driver
+	if override == "" {
+		override = "\n"
+	}
+
+	err := ioutil.WriteFile(filepath.Join(devicePath, "driver_override"), []byte(override), 0200)
+	if err != nil {
+		return fmt.Errorf("Failed to set the driver override of PCI device %s: %v", dev.address, err)
+	}
+
+	if dev.driver != "" {
+		err = ioutil.WriteFile(filepath.Join(devicePath, "driver", "unbind"), []byte(dev.address), 0200)
+		if err != nil {
+			return fmt.Errorf("Failed to unbind PCI device %s from %s: %v", dev.address, dev.driver, err)
+		}
+	}
+
+	err = ioutil.WriteFile(filepath.Join(sysBusPCI, "drivers_probe"), []byte(dev.address), 0200)
+	if err != nil {
+		return fmt.Errorf("Failed to probe the driver of PCI device %s: %v", dev.address, err)
+	}
+
+	return nil
+}
+
+// pciParseDrivers parses the original drivers of the devices of an IOMMU
+// group as recorded in volatile.<device>.drivers
+// (<address>=<driver>[,<address>=<driver>...]).
+func pciParseDrivers(value string) map[string]string {
+	drivers := map[string]string{}
+	for _, entry := range strings.Split(value, ",") {
+		fields := strings.SplitN(entry, "=", 2)
+		if len(fields) != 2 || fields[0] == "" {
+			continue
+		}
+
+		drivers[fields[0]] = fields[1]
+	}
+
+	return drivers
+}
+
+func pciFormatDrivers(drivers map[string]string) string {
+	entries := []string{}
+	for address, driver := range drivers {
+		entries = append(entries, fmt.Sprintf("%s=%s", address, driver))
+	}
+
+	sort.Strings(entries)
+	return strings.Join(entries, ",")
+}
+
+// pciGroupOwner returns the running container using an IOMMU group, if any.
+func pciGroupOwner(c container, group string) (string, error) {
+	containers, err := containerLoadNodeAll(c.DaemonState())
+	if err != nil {
+		return "", err
+	}
+
+	for _, other := range containers {
+		if other.Project() == c.Project() && other.Name() == c.Name() {
+			continue
+		}
+
+		if !other.IsRunning() {
+			continue
+		}
+
+		for name, m := range other.ExpandedDevices() {
+			if m["type"] != "pci" {
+				continue
+			}
+
+			if other.LocalConfig()[fmt.Sprintf("volatile.%s.iommu_group", name)] == group {
+				return projectPrefix(other.Project(), other.Name()), nil
+			}
+		}
+	}
+
+	return "", nil
+}
+
+// setupPCIDevice binds all the devices of the IOMMU group of a PCI device to
+// vfio-pci, recording their original drivers, and makes the VFIO devices
+// available in the container.
+func (c *containerLXC) setupPCIDevice(name string, m types.Device) error {
+	dev, err := pciLoadDevice(m["address"])
+	if err != nil {
+		return err
+	}
+
+	if dev.iommuGroup == "" {
+		return fmt.Errorf("PCI device %s isn't part of an IOMMU group (is the IOMMU enabled?)", dev.address)
+	}
+
+	owner, err := pciGroupOwner(c, dev.iommuGroup)
+	if err != nil {
+		return err
+	}
+
+	if owner != "" {
+		return fmt.Errorf("IOMMU group %s of PCI device %s is already used by container %s", dev.iommuGroup, dev.address, owner)
+	}
+
+	err = util.LoadModule(pciVFIODriver)
+	if err != nil {
+		return fmt.Errorf("Failed to load the %s kernel module: %v", pciVFIODriver, err)
+	}
+
+	addresses, err := pciIOMMUGroupDevices(dev.iommuGroup)
+	if err != nil {
+		return err
+	}
+
+	// Drivers recorded by a start which didn't go through a stop are kept,
+	// the devices already being bound to vfio-pci
+	driversKey := fmt.Sprintf("volatile.%s.drivers", name)
+	drivers := pciParseDrivers(c.localConfig[driversKey])
+
+	for _, address := range addresses {
+		member, err := pciLoadDevice(address)
+		if err != nil {
+			return err
+		}
+
+		if pciIsBridge(member.class) || member.driver == pciVFIODriver {
+			continue
+		}
+
+		err = pciBindDriver(member, pciVFIODriver)
+		if err != nil {
+			// Give back the devices bound so far
+			pciRestoreDrivers(drivers)
+			return err
+		}
+
+		drivers[address] = member.driver
+	}
+
+	volatile := map[string]string{
+		driversKey: pciFormatDrivers(drivers),
+		fmt.Sprintf("volatile.%s.iommu_group", name): dev.iommuGroup,
+	}
+
+	for key := range volatile {
+		err = c.state.Cluster.ContainerConfigRemove(c.id, key)
+		if err != nil {
+			pciRestoreDrivers(drivers)
+			return err
+		}
+	}
+
+	err = c.state.Cluster.Transaction(func(tx *db.ClusterTx) error {
+		return tx.ContainerConfigInsert(c.id, volatile)
+	})
+	if err != nil {
+		pciRestoreDrivers(drivers)
+		return err
+	}
+
+	for key, value := range volatile {
+		c.localConfig[key] = value
+	}
+
+	// The group device shows up once all its devices are bound
+	groupPath := filepath.Join("/dev/vfio", dev.iommuGroup)
+	for start := time.Now(); !shared.PathExists(groupPath); time.Sleep(pciVFIOInterval) {
+		if time.Since(start) > pciVFIOTimeout {
+			return fmt.Errorf("VFIO device %s didn't show up", groupPath)
+		}
+	}
+
+	for _, path := range []string{"/dev/vfio/vfio", groupPath} {
+		_, major, minor, err := deviceGetAttributes(path)
+		if err != nil {
+			return err
+		}
+
+		err = c.setupUnixDevice(fmt.Sprintf("unix.%s", name), m, major, minor, path, true, false)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// pciRestoreDrivers binds PCI devices back to their original drivers.
+func pciRestoreDrivers(drivers map[string]string) error {
+	var failed error
+	for address, driver := range drivers {
+		dev, err := pciLoadDevice(address)
+		if err != nil {
+			failed = err
+			continue
+		}
+
+		if dev.driver == driver {
+			continue
+		}
+
+		err = pciBindDriver(dev, driver)
+		if err != nil {
+			failed = err
+		}
+	}
+
+	return failed
+}
+
+// releasePCIDevice binds the devices of the IOMMU group used by a PCI device
+// back to their original drivers.
+func (c *containerLXC) releasePCIDevice(name string, m types.Device) {
+	if m["type"] != "pci" {
+		return
+	}
+
+	driversKey := fmt.Sprintf("volatile.%s.drivers", name)
+	err := pciRestoreDrivers(pciParseDrivers(c.localConfig[driversKey]))
+	if err != nil {
+		logger.Error("Failed to restore the drivers of PCI devices", log.Ctx{"container": c.Name(), "device": name, "err": err})
+	}
+
+	for _, key := range []string{driversKey, fmt.Sprintf("volatile.%s.iommu_group", name)} {
+		if c.localConfig[key] == "" {
+			continue
+		}
+
+		delete(c.localConfig, key)
+		err := c.state.Cluster.ContainerConfigRemove(c.id, key)
+		if err != nil {
+			logger.Warn("Failed to clear PCI device state", log.Ctx{"container": c.Name(), "device": name, "err": err})
+		}
+	}
+}
+
+// /1.0/resources/pci
+// Get the IOMMU groups of the PCI devices
+func pciResourcesGet(d *Daemon, r *http.Request) Response {
+	// If a target was specified, forward the request to the relevant node.
+	response := ForwardedResponseIfTargetIsRemote(d, r)
+	if response != nil {
+		return response
+	}
+
+	res := api.ResourcesPCI{IOMMUGroups: []api.ResourcesPCIIOMMUGroup{}}
+
+	groups, err := ioutil.ReadDir(sysIOMMUGroups)
+	if err != nil && !os.IsNotExist(err) {
+		return SmartError(err)
+	}
+
+	for _, group := range groups {
+		id, err := strconv.ParseUint(group.Name(), 10, 64)
+		if err != nil {
+			continue
+		}
+
+		addresses, err := pciIOMMUGroupDevices(group.Name())
+		if err != nil {
+			return SmartError(err)
+		}
+
+		iommuGroup := api.ResourcesPCIIOMMUGroup{ID: id, Devices: []api.ResourcesPCIDevice{}}
+		for _, address := range addresses {
+			dev, err := pciLoadDevice(address)
+			if err != nil {
+				continue
+			}
+
+			iommuGroup.Devices = append(iommuGroup.Devices, api.ResourcesPCIDevice{
+				PCIAddress: dev.address,
+				Driver:     dev.driver,
+				Class:      dev.class,
+				VendorID:   dev.vendorID,
+				ProductID:  dev.productID,
+			})
+		}
+
+		res.IOMMUGroups = append(res.IOMMUGroups, iommuGroup)
+	}
+
+	sort.Slice(res.IOMMUGroups, func(i, j int) bool { return res.IOMMUGroups[i].ID < res.IOMMUGroups[j].ID })
+	res.Total = uint64(len(res.IOMMUGroups))
+
+	return SyncResponse(true, res)
+}
+
+// pciValidDevice checks the configuration of a pci device.
+func pciValidDevice(m types.Device, expanded bool) error {
+	if m["address"] == "" {
+		return fmt.Errorf("Missing address for pci device")
+	}
+
+	if !pciAddressRegexp.MatchString(m["address"]) {
+		return fmt.Errorf("Invalid PCI address '%s' (expecting <domain>:<bus>:<slot>.<function>)", m["address"])
+	}
+
+	if expanded && !shared.PathExists(filepath.Join(sysBusPCI, "devices", m["address"])) {
+		return fmt.Errorf("Invalid PCI address (no device found): %s", m["address"])
+	}
+
+	if m["vfio"] != "" {
+		err := shared.IsBool(m["vfio"])
+		if err != nil {
+			return fmt.Errorf("Invalid value for vfio: %v", err)
+		}
+
+		if !shared.IsTrue(m["vfio"]) {
+			return fmt.Errorf("pci devices only support VFIO passthrough (vfio=true)")
+		}
+	}
+
+	return nil
+}
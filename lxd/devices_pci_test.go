@@ -0,0 +1,35 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/lxc/lxd/lxd/types"
+)
+
+func TestPCIDrivers(t *testing.T) {
+	drivers := pciParseDrivers("0000:03:00.1=snd_hda_intel,0000:03:00.0=nvidia,0000:04:00.0=")
+	assert.Equal(t, map[string]string{
+		"0000:03:00.0": "nvidia",
+		"0000:03:00.1": "snd_hda_intel",
+		"0000:04:00.0": "",
+	}, drivers)
+
+	assert.Equal(t, "0000:03:00.0=nvidia,0000:03:00.1=snd_hda_intel,0000:04:00.0=", pciFormatDrivers(drivers))
+	assert.Equal(t, map[string]string{}, pciParseDrivers(""))
+}
+
+func TestPCIIsBridge(t *testing.T) {
+	assert.True(t, pciIsBridge("0x060400"))
+	assert.False(t, pciIsBridge("0x030000"))
+}
+
+func TestPCIValidDevice(t *testing.T) {
+	assert.NoError(t, pciValidDevice(types.Device{"type": "pci", "address": "0000:03:00.0"}, false))
+	assert.NoError(t, pciValidDevice(types.Device{"type": "pci", "address": "0000:03:00.0", "vfio": "true"}, false))
+	assert.Error(t, pciValidDevice(types.Device{"type": "pci"}, false))
+	assert.Error(t, pciValidDevice(types.Device{"type": "pci", "address": "03:00.0"}, false))
+	assert.Error(t, pciValidDevice(types.Device{"type": "pci", "address": "0000:03:00.0", "vfio": "false"}, false))
+	assert.Error(t, pciValidDevice(types.Device{"type": "pci", "address": "0000:03:00.0", "vfio": "maybe"}, false))
+}
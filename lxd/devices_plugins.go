@@ -0,0 +1,100 @@
+package main
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/lxc/lxd/lxd/db"
+	"github.com/lxc/lxd/lxd/plugin"
+	"github.com/lxc/lxd/lxd/types"
+	"github.com/lxc/lxd/shared"
+	"github.com/lxc/lxd/shared/logger"
+	"github.com/lxc/lxd/shared/version"
+
+	log "github.com/lxc/lxd/shared/log15"
+)
+
+// Device drivers provided by plugins, keyed by device type. This is only
+// written to during daemon startup.
+var devicePlugins = map[string]plugin.Plugin{}
+
+func devicePluginsPath() string {
+	path := os.Getenv("LXD_PLUGINS_DIR")
+	if path != "" {
+		return path
+	}
+
+	return shared.VarPath("plugins")
+}
+
+// devicePluginsLoad loads the device plugins and registers their device types.
+func devicePluginsLoad() error {
+	path := devicePluginsPath()
+	if !shared.PathExists(path) {
+		return nil
+	}
+
+	plugins, err := plugin.Load(path, version.APIVersion)
+	if err != nil {
+		return err
+	}
+
+	for deviceType, p := range plugins {
+		if shared.StringInSlice(deviceType, deviceBuiltinTypes) {
+			return fmt.Errorf("Plugin device type '%s' conflicts with a built-in device type", deviceType)
+		}
+
+		logger.Info("Loaded device plugin", log.Ctx{"type": deviceType})
+		devicePlugins[deviceType] = p
+		db.DevicePluginTypeRegister(deviceType)
+	}
+
+	return nil
+}
+
+// devicePluginsStart starts all the plugin provided devices of a container.
+func devicePluginsStart(c container, devices types.Devices) error {
+	started := []string{}
+	for _, name := range devices.DeviceNames() {
+		m := devices[name]
+		p, ok := devicePlugins[m["type"]]
+		if !ok {
+			continue
+		}
+
+		err := p.Start(c, m)
+		if err != nil {
+			for i := len(started) - 1; i >= 0; i-- {
+				prev := started[i]
+				devicePluginStop(c, prev, devices[prev])
+			}
+
+			return fmt.Errorf("Failed to start device '%s': %v", name, err)
+		}
+
+		started = append(started, name)
+	}
+
+	return nil
+}
+
+// devicePluginsStop stops all the plugin provided devices of a container.
+func devicePluginsStop(c container, devices types.Devices) {
+	names := devices.DeviceNames()
+	for i := len(names) - 1; i >= 0; i-- {
+		name := names[i]
+		m := devices[name]
+		if devicePlugins[m["type"]] == nil {
+			continue
+		}
+
+		devicePluginStop(c, name, m)
+	}
+}
+
+func devicePluginStop(c container, name string, m map[string]string) {
+	err := devicePlugins[m["type"]].Stop(c, m)
+	if err != nil {
+		logger.Warn("Failed to stop plugin device", log.Ctx{"container": c.Name(), "device": name, "err": err})
+	}
+}
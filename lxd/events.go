@@ -4,6 +4,7 @@ import (
 	"encoding/json"
 	"fmt"
 	"net/http"
+	"strconv"
 	"strings"
 	"sync"
 	"time"
@@ -23,6 +24,11 @@ var eventsCmd = Command{
 	get:  eventsGet,
 }
 
+var eventsSSECmd = Command{
+	name: "events/sse",
+	get:  eventsSSEGet,
+}
+
 type eventsHandler struct {
 }
 
@@ -62,9 +68,31 @@ func eventSendLifecycle(project, action, source string,
 var eventsLock sync.Mutex
 var eventListeners map[string]*eventListener = make(map[string]*eventListener)
 
+// Recently sent events, used to replay events to reconnecting Server-Sent
+// Events clients.
+type eventHistoryEntry struct {
+	project string
+	event   api.Event
+}
+
+var eventsHistory []eventHistoryEntry
+var eventsHistorySize = 1000
+
+// eventsSetHistorySize changes the number of events kept for replay.
+func eventsSetHistorySize(size int) {
+	eventsLock.Lock()
+	defer eventsLock.Unlock()
+
+	eventsHistorySize = size
+	if len(eventsHistory) > size {
+		eventsHistory = eventsHistory[len(eventsHistory)-size:]
+	}
+}
+
 type eventListener struct {
 	project      string
 	connection   *websocket.Conn
+	stream       http.ResponseWriter
 	messageTypes []string
 	active       chan bool
 	id           string
@@ -145,6 +173,161 @@ func eventsGet(d *Daemon, r *http.Request) Response {
 	return &eventsServe{req: r, d: d}
 }
 
+type eventsSSEServe struct {
+	req *http.Request
+	d   *Daemon
+}
+
+func (r *eventsSSEServe) Render(w http.ResponseWriter) error {
+	return eventsStream(r.d, r.req, w)
+}
+
+func (r *eventsSSEServe) String() string {
+	return "event stream handler"
+}
+
+func eventsSSEGet(d *Daemon, r *http.Request) Response {
+	return &eventsSSEServe{req: r, d: d}
+}
+
+// eventWriteSSE writes an event in the text/event-stream format.
+func eventWriteSSE(w http.ResponseWriter, event api.Event) error {
+	body, err := json.Marshal(event)
+	if err != nil {
+		return err
+	}
+
+	_, err = fmt.Fprintf(w, "id: %d\nevent: %s\ndata: %s\n\n", event.Timestamp.UnixNano(), event.Type, body)
+	if err != nil {
+		return err
+	}
+
+	w.(http.Flusher).Flush()
+	return nil
+}
+
+// eventsStream streams events to the client as Server-Sent Events, replaying
+// the events sent since the one in the Last-Event-ID header.
+func eventsStream(d *Daemon, r *http.Request, w http.ResponseWriter) error {
+	project := projectParam(r)
+	typeStr := r.FormValue("type")
+	if typeStr == "" {
+		typeStr = "logging,operation,lifecycle"
+	}
+
+	_, ok := w.(http.Flusher)
+	if !ok {
+		return fmt.Errorf("Streaming isn't supported by the connection")
+	}
+
+	lastID := int64(-1)
+	if r.Header.Get("Last-Event-ID") != "" {
+		var err error
+		lastID, err = strconv.ParseInt(r.Header.Get("Last-Event-ID"), 10, 64)
+		if err != nil {
+			http.Error(w, "Invalid Last-Event-ID", http.StatusBadRequest)
+			return nil
+		}
+	}
+
+	var serverName string
+	err := d.cluster.Transaction(func(tx *db.ClusterTx) error {
+		var err error
+		serverName, err = tx.NodeName()
+		return err
+	})
+	if err != nil {
+		return err
+	}
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.WriteHeader(http.StatusOK)
+	w.(http.Flusher).Flush()
+
+	listener := eventListener{
+		project:      project,
+		active:       make(chan bool, 1),
+		stream:       w,
+		id:           uuid.NewRandom().String(),
+		messageTypes: strings.Split(typeStr, ","),
+		location:     serverName,
+		noForward:    isClusterNotification(r),
+	}
+
+	// Hold the listener until the missed events have been replayed so
+	// that new events are only sent after them.
+	listener.lock.Lock()
+
+	eventsLock.Lock()
+	history := []api.Event{}
+	if lastID >= 0 {
+		for _, entry := range eventsHistory {
+			if entry.event.Timestamp.UnixNano() <= lastID || !listener.wants(entry.project, entry.event) {
+				continue
+			}
+
+			history = append(history, entry.event)
+		}
+	}
+	eventListeners[listener.id] = &listener
+	eventsLock.Unlock()
+
+	logger.Debugf("New event stream listener: %s", listener.id)
+
+	for _, event := range history {
+		err := eventWriteSSE(w, listener.localize(event))
+		if err != nil {
+			listener.done = true
+			break
+		}
+	}
+	listener.lock.Unlock()
+
+	// Wait for the client to go away or for a write to fail
+	select {
+	case <-listener.active:
+	case <-r.Context().Done():
+	}
+
+	listener.lock.Lock()
+	listener.done = true
+	listener.lock.Unlock()
+
+	eventsLock.Lock()
+	delete(eventListeners, listener.id)
+	eventsLock.Unlock()
+
+	logger.Debugf("Disconnected event stream listener: %s", listener.id)
+
+	return nil
+}
+
+// wants returns whether the listener is interested in the event.
+func (l *eventListener) wants(project string, event api.Event) bool {
+	if project != "" && l.project != "*" && project != l.project {
+		return false
+	}
+
+	return shared.StringInSlice(event.Type, l.messageTypes)
+}
+
+// localize sets the event's location to the listener's node if unset.
+func (l *eventListener) localize(event api.Event) api.Event {
+	if event.Location != "" {
+		return event
+	}
+
+	eventCopy := api.Event{}
+	err := shared.DeepCopy(&event, &eventCopy)
+	if err != nil {
+		return event
+	}
+	eventCopy.Location = l.location
+
+	return eventCopy
+}
+
 func eventSend(project, eventType string, eventMessage interface{}) error {
 	encodedMessage, err := json.Marshal(eventMessage)
 	if err != nil {
@@ -156,22 +339,30 @@ func eventSend(project, eventType string, eventMessage interface{}) error {
 		Metadata:  encodedMessage,
 	}
 
+	// Push local events to the webhooks
+	webhooksSend(project, event)
+
 	return eventBroadcast(project, event, false)
 }
 
 func eventBroadcast(project string, event api.Event, isForward bool) error {
 	eventsLock.Lock()
-	listeners := eventListeners
-	for _, listener := range listeners {
-		if project != "" && listener.project != "*" && project != listener.project {
-			continue
+
+	// Keep the event around for replay
+	if eventsHistorySize > 0 {
+		eventsHistory = append(eventsHistory, eventHistoryEntry{project: project, event: event})
+		if len(eventsHistory) > eventsHistorySize {
+			eventsHistory = eventsHistory[len(eventsHistory)-eventsHistorySize:]
 		}
+	}
 
+	listeners := eventListeners
+	for _, listener := range listeners {
 		if isForward && listener.noForward {
 			continue
 		}
 
-		if !shared.StringInSlice(event.Type, listener.messageTypes) {
+		if !listener.wants(project, event) {
 			continue
 		}
 
@@ -191,23 +382,21 @@ func eventBroadcast(project string, event api.Event, isForward bool) error {
 			}
 
 			// Set the Location to the expected serverName
-			if event.Location == "" {
-				eventCopy := api.Event{}
-				err := shared.DeepCopy(&event, &eventCopy)
+			event = listener.localize(event)
+
+			var err error
+			if listener.stream != nil {
+				err = eventWriteSSE(listener.stream, event)
+			} else {
+				var body []byte
+				body, err = json.Marshal(event)
 				if err != nil {
 					return
 				}
-				eventCopy.Location = listener.location
-
-				event = eventCopy
-			}
 
-			body, err := json.Marshal(event)
-			if err != nil {
-				return
+				err = listener.connection.WriteMessage(websocket.TextMessage, body)
 			}
 
-			err = listener.connection.WriteMessage(websocket.TextMessage, body)
 			if err != nil {
 				// Remove the listener from the list
 				eventsLock.Lock()
@@ -215,7 +404,9 @@ func eventBroadcast(project string, event api.Event, isForward bool) error {
 				eventsLock.Unlock()
 
 				// Disconnect the listener
-				listener.connection.Close()
+				if listener.connection != nil {
+					listener.connection.Close()
+				}
 				listener.active <- false
 				listener.done = true
 				logger.Debugf("Disconnected event listener: %s", listener.id)
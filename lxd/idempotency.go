@@ -0,0 +1,270 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"fmt"
+	"net"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/lxc/lxd/lxd/cluster"
+	"github.com/lxc/lxd/lxd/db"
+	"github.com/lxc/lxd/lxd/task"
+	"github.com/lxc/lxd/shared"
+	"github.com/lxc/lxd/shared/api"
+	"github.com/lxc/lxd/shared/logger"
+
+	log "github.com/lxc/lxd/shared/log15"
+)
+
+// Header identifying the retries of a request
+const idempotencyKeyHeader = "Idempotency-Key"
+
+// Header set on the responses replayed for a retried request
+const idempotencyReplayedHeader = "Idempotent-Replayed"
+
+// Responses larger than this (e.g. file transfers) aren't recorded
+const idempotencyMaxBody = 1024 * 1024
+
+// Keys of the requests being processed on this node, as identity and key
+var idempotencyInFlightLock sync.Mutex
+var idempotencyInFlight = map[string]bool{}
+
+// idempotencyKeyValidate checks that an idempotency key is made of 1 to 255
+// printable ASCII characters.
+func idempotencyKeyValidate(key string) error {
+	if len(key) == 0 || len(key) > 255 {
+		return fmt.Errorf("Idempotency keys must be between 1 and 255 characters long")
+	}
+
+	for _, r := range key {
+		if r < 0x21 || r > 0x7e {
+			return fmt.Errorf("Idempotency keys must only contain printable ASCII characters")
+		}
+	}
+
+	return nil
+}
+
+// idempotencyIdentity returns the identity the idempotency keys of a request
+// are scoped by, or an empty string for the requests they don't apply to:
+// untrusted requests, internal cluster traffic and clients without identity.
+func idempotencyIdentity(d *Daemon, r *http.Request, trusted bool, username string) string {
+	if !trusted {
+		return ""
+	}
+
+	// Clients of the local unix socket are all equally privileged
+	if r.RemoteAddr == "@" {
+		return "@"
+	}
+
+	if r.TLS == nil || isClusterMemberRequest(d, r) {
+		return ""
+	}
+
+	if username != "" {
+		return username
+	}
+
+	if len(r.TLS.PeerCertificates) > 0 {
+		return shared.CertFingerprint(r.TLS.PeerCertificates[0])
+	}
+
+	return ""
+}
+
+// idempotencyRequest tracks a request carrying an idempotency key, recording
+// its response as it's sent to the client.
+type idempotencyRequest struct {
+	d        *Daemon
+	entry    db.IdempotencyKey
+	ttl      time.Duration
+	recorder *idempotencyRecorder
+}
+
+// idempotencyStart looks up the response recorded for a retried request. It
+// returns the response to send instead of processing the request, or the
+// request to record the response of once processed. Keys are scoped by the
+// identity of the client, as returned by idempotencyIdentity.
+func idempotencyStart(d *Daemon, r *http.Request, identity string) (*idempotencyRequest, Response) {
+	key := r.Header.Get(idempotencyKeyHeader)
+	if key == "" || identity == "" || !shared.StringInSlice(r.Method, []string{"POST", "PUT", "DELETE"}) {
+		return nil, nil
+	}
+
+	err := idempotencyKeyValidate(key)
+	if err != nil {
+		return nil, BadRequest(err)
+	}
+
+	ttl, err := cluster.ConfigGetInt64(d.cluster, "core.idempotency_ttl")
+	if err != nil {
+		return nil, SmartError(err)
+	}
+
+	if ttl == 0 {
+		return nil, nil
+	}
+
+	inFlight := fmt.Sprintf("%s/%s", identity, key)
+	idempotencyInFlightLock.Lock()
+	if idempotencyInFlight[inFlight] {
+		idempotencyInFlightLock.Unlock()
+		return nil, &errorResponse{http.StatusConflict, "A request with the same idempotency key is still being processed", api.ErrIdempotencyKeyInUse, nil}
+	}
+	idempotencyInFlight[inFlight] = true
+	idempotencyInFlightLock.Unlock()
+
+	req := &idempotencyRequest{
+		d: d,
+		entry: db.IdempotencyKey{
+			Key:      key,
+			Username: identity,
+			Method:   r.Method,
+			URI:      r.URL.RequestURI(),
+		},
+		ttl: time.Duration(ttl) * time.Second,
+	}
+
+	entry, err := d.cluster.IdempotencyKeyGet(key, identity)
+	if err == db.ErrNoSuchObject {
+		return req, nil
+	}
+
+	req.done()
+
+	if err != nil {
+		return nil, SmartError(err)
+	}
+
+	if entry.Method != req.entry.Method || entry.URI != req.entry.URI {
+		return nil, &errorResponse{http.StatusUnprocessableEntity, fmt.Sprintf("The idempotency key was used for %s %s", entry.Method, entry.URI), api.ErrIdempotencyKeyMismatch, nil}
+	}
+
+	logger.Debug("Replaying response to retried request", log.Ctx{"method": r.Method, "url": entry.URI, "key": key})
+	return nil, &idempotencyReplayResponse{entry: entry}
+}
+
+// Writer returns the writer to render the response of the request to.
+func (req *idempotencyRequest) Writer(w http.ResponseWriter) http.ResponseWriter {
+	req.recorder = &idempotencyRecorder{w: w, code: http.StatusOK}
+	return req.recorder
+}
+
+// Finish records the response sent for the request. Server errors aren't
+// recorded, retrying the request processing it again.
+func (req *idempotencyRequest) Finish() {
+	defer req.done()
+
+	if req.recorder == nil || req.recorder.overflow || req.recorder.code >= http.StatusInternalServerError {
+		return
+	}
+
+	entry := req.entry
+	entry.StatusCode = req.recorder.code
+	entry.ContentType = req.recorder.Header().Get("Content-Type")
+	entry.Location = req.recorder.Header().Get("Location")
+	entry.Body = req.recorder.body.Bytes()
+	entry.ExpiryDate = time.Now().Add(req.ttl)
+
+	err := req.d.cluster.IdempotencyKeyCreate(entry)
+	if err != nil {
+		logger.Warn("Failed to record the response to a request with an idempotency key", log.Ctx{"key": entry.Key, "err": err})
+	}
+}
+
+func (req *idempotencyRequest) done() {
+	idempotencyInFlightLock.Lock()
+	delete(idempotencyInFlight, fmt.Sprintf("%s/%s", req.entry.Username, req.entry.Key))
+	idempotencyInFlightLock.Unlock()
+}
+
+// idempotencyRecorder passes a response through to the client while
+// recording it.
+type idempotencyRecorder struct {
+	w        http.ResponseWriter
+	code     int
+	body     bytes.Buffer
+	overflow bool
+}
+
+func (w *idempotencyRecorder) Header() http.Header {
+	return w.w.Header()
+}
+
+func (w *idempotencyRecorder) WriteHeader(code int) {
+	w.code = code
+	w.w.WriteHeader(code)
+}
+
+func (w *idempotencyRecorder) Write(data []byte) (int, error) {
+	if !w.overflow {
+		if w.body.Len()+len(data) > idempotencyMaxBody {
+			w.overflow = true
+			w.body.Reset()
+		} else {
+			w.body.Write(data)
+		}
+	}
+
+	return w.w.Write(data)
+}
+
+// Hijack hands the connection over, the response of upgraded connections
+// not being recorded.
+func (w *idempotencyRecorder) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	hijacker, ok := w.w.(http.Hijacker)
+	if !ok {
+		return nil, nil, fmt.Errorf("The connection can't be hijacked")
+	}
+
+	w.overflow = true
+	return hijacker.Hijack()
+}
+
+func (w *idempotencyRecorder) Flush() {
+	flusher, ok := w.w.(http.Flusher)
+	if ok {
+		flusher.Flush()
+	}
+}
+
+// idempotencyReplayResponse sends back the response recorded for a retried
+// request.
+type idempotencyReplayResponse struct {
+	entry *db.IdempotencyKey
+}
+
+func (r *idempotencyReplayResponse) Render(w http.ResponseWriter) error {
+	if r.entry.ContentType != "" {
+		w.Header().Set("Content-Type", r.entry.ContentType)
+	}
+
+	if r.entry.Location != "" {
+		w.Header().Set("Location", r.entry.Location)
+	}
+
+	w.Header().Set(idempotencyReplayedHeader, "true")
+	w.WriteHeader(r.entry.StatusCode)
+	_, err := w.Write(r.entry.Body)
+	return err
+}
+
+func (r *idempotencyReplayResponse) String() string {
+	return fmt.Sprintf("replayed %d", r.entry.StatusCode)
+}
+
+func pruneExpiredIdempotencyKeysTask(d *Daemon) (task.Func, task.Schedule) {
+	f := func(ctx context.Context) {
+		err := d.cluster.IdempotencyKeysPrune()
+		if err != nil {
+			logger.Error("Failed to prune expired idempotency keys", log.Ctx{"err": err})
+		}
+	}
+
+	return f, task.Every(5 * time.Minute)
+}
@@ -787,10 +787,13 @@ func imagesPost(d *Daemon, r *http.Request) Response {
 			}
 		}
 
-		// Sync the images between each node in the cluster on demand
-		err = imageSyncBetweenNodes(d, info.Fingerprint)
-		if err != nil {
-			return errors.Wrapf(err, "Image sync between nodes")
+		// Sync the images between each node in the cluster on demand,
+		// unless this is already a node receiving a replica
+		if !isClusterNotification(r) {
+			err = imageSyncBetweenNodes(d, project, info.Fingerprint)
+			if err != nil {
+				return errors.Wrapf(err, "Image sync between nodes")
+			}
 		}
 
 		return nil
@@ -1238,55 +1241,80 @@ func pruneExpiredImages(ctx context.Context, d *Daemon) error {
 		default:
 		}
 
-		// Get the IDs of all storage pools on which a storage volume
-		// for the requested image currently exists.
-		poolIDs, err := d.cluster.ImageGetPools(fp)
+		err := imagePrune(d, "default", fp)
 		if err != nil {
-			continue
+			return err
 		}
+	}
+
+	return nil
+}
+
+// imagePrune removes an image from the storage pools, the disk and the
+// database.
+func imagePrune(d *Daemon, project string, fp string) error {
+	imgID, _, err := d.cluster.ImageGet(project, fp, false, false)
+	if err != nil {
+		return errors.Wrapf(err, "Error retrieving image info %s", fp)
+	}
 
-		// Translate the IDs to poolNames.
-		poolNames, err := d.cluster.ImageGetPoolNamesFromIDs(poolIDs)
+	// If another project still uses the image, only remove the
+	// database entry and keep the files around.
+	referenced, err := d.cluster.ImageIsReferencedByOtherProjects(project, fp)
+	if err != nil {
+		return errors.Wrapf(err, "Error checking references of image %s", fp)
+	}
+	if referenced {
+		err = d.cluster.ImageDelete(imgID)
 		if err != nil {
-			continue
+			return errors.Wrapf(err, "Error deleting image %s from database", fp)
 		}
+		return nil
+	}
 
-		for _, pool := range poolNames {
-			err := doDeleteImageFromPool(d.State(), fp, pool)
-			if err != nil {
-				return errors.Wrapf(err, "Error deleting image %s from storage pool %s", fp, pool)
-			}
-		}
+	// Get the IDs of all storage pools on which a storage volume
+	// for the requested image currently exists.
+	poolIDs, err := d.cluster.ImageGetPools(fp)
+	if err != nil {
+		return nil
+	}
 
-		// Remove main image file.
-		fname := filepath.Join(d.os.VarDir, "images", fp)
-		if shared.PathExists(fname) {
-			err = os.Remove(fname)
-			if err != nil && !os.IsNotExist(err) {
-				return errors.Wrapf(err, "Error deleting image file %s", fname)
-			}
-		}
+	// Translate the IDs to poolNames.
+	poolNames, err := d.cluster.ImageGetPoolNamesFromIDs(poolIDs)
+	if err != nil {
+		return nil
+	}
 
-		// Remove the rootfs file for the image.
-		fname = filepath.Join(d.os.VarDir, "images", fp) + ".rootfs"
-		if shared.PathExists(fname) {
-			err = os.Remove(fname)
-			if err != nil && !os.IsNotExist(err) {
-				return errors.Wrapf(err, "Error deleting image file %s", fname)
-			}
+	for _, pool := range poolNames {
+		err := doDeleteImageFromPool(d.State(), fp, pool)
+		if err != nil {
+			return errors.Wrapf(err, "Error deleting image %s from storage pool %s", fp, pool)
 		}
+	}
 
-		imgID, _, err := d.cluster.ImageGet("default", fp, false, false)
-		if err != nil {
-			return errors.Wrapf(err, "Error retrieving image info %s", fp)
+	// Remove main image file.
+	fname := filepath.Join(d.os.VarDir, "images", fp)
+	if shared.PathExists(fname) {
+		err = os.Remove(fname)
+		if err != nil && !os.IsNotExist(err) {
+			return errors.Wrapf(err, "Error deleting image file %s", fname)
 		}
+	}
 
-		// Remove the database entry for the image.
-		if err = d.cluster.ImageDelete(imgID); err != nil {
-			return errors.Wrapf(err, "Error deleting image %s from database", fp)
+	// Remove the rootfs file for the image.
+	fname = filepath.Join(d.os.VarDir, "images", fp) + ".rootfs"
+	if shared.PathExists(fname) {
+		err = os.Remove(fname)
+		if err != nil && !os.IsNotExist(err) {
+			return errors.Wrapf(err, "Error deleting image file %s", fname)
 		}
 	}
 
+	// Remove the database entry for the image.
+	if err = d.cluster.ImageDelete(imgID); err != nil {
+		return errors.Wrapf(err, "Error deleting image %s from database", fp)
+	}
+
 	return nil
 }
 
@@ -1445,7 +1473,7 @@ func imageDeleteFromDisk(fingerprint string) {
 func doImageGet(db *db.Cluster, project, fingerprint string, public bool) (*api.Image, Response) {
 	_, imgInfo, err := db.ImageGet(project, fingerprint, public, false)
 	if err != nil {
-		return nil, SmartError(err)
+		return nil, SmartError(withNotFoundCode(api.ErrImageNotFound, err))
 	}
 
 	return imgInfo, nil
@@ -2048,10 +2076,10 @@ func autoSyncImages(ctx context.Context, d *Daemon) error {
 		return errors.Wrap(err, "Failed to query image fingerprints of the node")
 	}
 
-	for fingerprint := range imageProjectInfo {
+	for fingerprint, projects := range imageProjectInfo {
 		ch := make(chan error)
 		go func() {
-			err := imageSyncBetweenNodes(d, fingerprint)
+			err := imageSyncBetweenNodes(d, projects[0], fingerprint)
 			if err != nil {
 				logger.Error("Failed to synchronize images", log.Ctx{"err": err, "fingerprint": fingerprint})
 			}
@@ -2068,8 +2096,9 @@ func autoSyncImages(ctx context.Context, d *Daemon) error {
 	return nil
 }
 
-func imageSyncBetweenNodes(d *Daemon, fingerprint string) error {
+func imageSyncBetweenNodes(d *Daemon, project string, fingerprint string) error {
 	var desiredSyncNodeCount int64
+	var replicationFactor int64
 
 	err := d.cluster.Transaction(func(tx *db.ClusterTx) error {
 		config, err := cluster.ConfigLoad(tx)
@@ -2077,6 +2106,7 @@ func imageSyncBetweenNodes(d *Daemon, fingerprint string) error {
 			return errors.Wrap(err, "Failed to load cluster configuration")
 		}
 		desiredSyncNodeCount = config.ImagesMinimalReplica()
+		replicationFactor = config.ImagesReplicationFactor()
 
 		// -1 means that we want to replicate the image on all nodes
 		if desiredSyncNodeCount == -1 {
@@ -2094,13 +2124,28 @@ func imageSyncBetweenNodes(d *Daemon, fingerprint string) error {
 		return err
 	}
 
+	if replicationFactor > desiredSyncNodeCount {
+		desiredSyncNodeCount = replicationFactor
+	}
+
+	imageID, _, err := d.cluster.ImageGet(project, fingerprint, false, true)
+	if err != nil {
+		return errors.Wrap(err, "Failed to get the image for the image synchronization")
+	}
+
 	// Check how many nodes already have this image
 	syncNodeAddresses, err := d.cluster.ImageGetNodesWithImage(fingerprint)
 	if err != nil {
 		return errors.Wrap(err, "Failed to get nodes for the image synchronization")
 	}
 
-	nodeCount := desiredSyncNodeCount - int64(len(syncNodeAddresses))
+	// Leave alone the nodes the image is still being replicated to
+	replicatingAddresses, err := d.cluster.ImageGetNodesReplicating(imageID, time.Now().Add(-imageReplicaStaleAfter))
+	if err != nil {
+		return errors.Wrap(err, "Failed to get nodes for the image synchronization")
+	}
+
+	nodeCount := int(desiredSyncNodeCount) - len(syncNodeAddresses) - len(replicatingAddresses)
 	if nodeCount <= 0 {
 		return nil
 	}
@@ -2113,60 +2158,72 @@ func imageSyncBetweenNodes(d *Daemon, fingerprint string) error {
 		return nil
 	}
 
-	// We spread the image for the nodes inside of cluster and we need to double
-	// check if the image already exists via DB since when one certain node is
-	// going to create an image it will invoke the same routine.
-	// Also as the daily image synchronization task can be only launched by leader node,
-	// hence the leader node will have the image synced first with higher priority.
-	// In case the operation fails, the daily image synchronization task will check
-	// whether an image was synced successfully across the cluster and perform the
-	// same job if not.
+	// As the daily image synchronization task can be only launched by
+	// the leader node, the leader node will have the image synced first
+	// with higher priority. In case the replication to a node fails, the
+	// daily image synchronization task will retry it.
 	leader, err := d.gateway.LeaderAddress()
 	if err != nil {
 		return errors.Wrap(err, "Failed to fetch the leader node address")
 	}
-	var targetNodeAddress string
-	if shared.StringInSlice(leader, addresses) {
-		targetNodeAddress = leader
-	} else {
-		targetNodeAddress = addresses[0]
+
+	targetNodeAddresses := []string{}
+	for _, address := range addresses {
+		if shared.StringInSlice(address, replicatingAddresses) {
+			continue
+		}
+
+		if address == leader {
+			targetNodeAddresses = append([]string{address}, targetNodeAddresses...)
+		} else {
+			targetNodeAddresses = append(targetNodeAddresses, address)
+		}
 	}
-	client, err := cluster.Connect(targetNodeAddress, d.endpoints.NetworkCert(), true)
-	if err != nil {
-		return errors.Wrap(err, "Failed to connect node for image synchronization")
+
+	if len(targetNodeAddresses) > nodeCount {
+		targetNodeAddresses = targetNodeAddresses[:nodeCount]
 	}
 
-	createArgs := &lxd.ImageCreateArgs{}
-	imageMetaPath := shared.VarPath("images", fingerprint)
-	imageRootfsPath := shared.VarPath("images", fingerprint+".rootfs")
+	if len(targetNodeAddresses) == 0 {
+		return nil
+	}
 
-	metaFile, err := os.Open(imageMetaPath)
-	if err != nil {
-		return err
+	// Replicate the image to all the target nodes at once
+	results := make(chan error, len(targetNodeAddresses))
+	for _, address := range targetNodeAddresses {
+		err := d.cluster.ImageReplicaUpdate(imageID, address, db.ImageReplicaPending, 0, "")
+		if err != nil {
+			return errors.Wrap(err, "Failed to record the image replication")
+		}
+	}
+
+	for _, address := range targetNodeAddresses {
+		go func(address string) {
+			results <- imageReplicate(d, project, imageID, fingerprint, address)
+		}(address)
 	}
-	defer metaFile.Close()
 
-	createArgs.MetaFile = metaFile
-	createArgs.MetaName = filepath.Base(imageMetaPath)
+	// Only wait for as many nodes as needed to reach the replication
+	// factor, the other replications completing in the background
+	missing := int(replicationFactor) - len(syncNodeAddresses)
+	if missing > len(targetNodeAddresses) {
+		missing = len(targetNodeAddresses)
+	}
 
-	if shared.PathExists(imageRootfsPath) {
-		rootfsFile, err := os.Open(imageRootfsPath)
+	var lastErr error
+	for i := 0; i < len(targetNodeAddresses) && missing > 0; i++ {
+		err := <-results
 		if err != nil {
-			return err
+			lastErr = err
+			continue
 		}
-		defer rootfsFile.Close()
 
-		createArgs.RootfsFile = rootfsFile
-		createArgs.RootfsName = filepath.Base(imageRootfsPath)
+		missing--
 	}
 
-	image := api.ImagesPost{}
-	image.Filename = createArgs.MetaName
-
-	op, err := client.CreateImage(image, createArgs)
-	if err != nil {
-		return err
+	if missing > 0 {
+		return errors.Wrapf(lastErr, "Failed to replicate the image to %d more nodes", missing)
 	}
 
-	return op.Wait()
+	return nil
 }
@@ -0,0 +1,465 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+	"gopkg.in/yaml.v2"
+
+	"github.com/lxc/lxd/lxd/db"
+	"github.com/lxc/lxd/lxd/node"
+	"github.com/lxc/lxd/lxd/types"
+	"github.com/lxc/lxd/shared"
+	"github.com/lxc/lxd/shared/api"
+	"github.com/lxc/lxd/shared/logger"
+	"github.com/lxc/lxd/shared/osarch"
+
+	log "github.com/lxc/lxd/shared/log15"
+)
+
+var imagesBuildCmd = Command{
+	name: "images/build",
+	post: imagesBuildPost,
+}
+
+// Trailers of the build response, holding its result
+const (
+	imagesBuildFingerprintHeader = "X-LXD-Image-Fingerprint"
+	imagesBuildErrorHeader       = "X-LXD-Build-Error"
+)
+
+// Runs buildkitd for the duration of a buildctl build
+const imagesBuildCommand = "buildctl-daemonless.sh"
+
+// Paths of the build context, the BuildKit cache and the output of a build in
+// the build container
+const (
+	imagesBuildContextPath = "/build/context"
+	imagesBuildCachePath   = "/build/cache"
+	imagesBuildOutputPath  = "/build/output"
+)
+
+// imagesBuildArgs returns the arguments of buildctl building the Dockerfile
+// of a context into an OCI image tarball, updating the given cache and
+// starting from it if importCache is set.
+func imagesBuildArgs(contextDir string, dockerfile string, target string, buildArgs []string, cacheDir string, importCache bool, dest string) ([]string, error) {
+	if dockerfile == "" {
+		dockerfile = "Dockerfile"
+	}
+
+	dockerfile = filepath.Clean(dockerfile)
+	if filepath.IsAbs(dockerfile) || dockerfile == ".." || strings.HasPrefix(dockerfile, "../") {
+		return nil, fmt.Errorf("The Dockerfile must be in the build context")
+	}
+
+	args := []string{
+		"build",
+		"--progress=plain",
+		"--frontend=dockerfile.v0",
+		"--local", fmt.Sprintf("context=%s", contextDir),
+		"--local", fmt.Sprintf("dockerfile=%s", filepath.Join(contextDir, filepath.Dir(dockerfile))),
+		"--opt", fmt.Sprintf("filename=%s", filepath.Base(dockerfile)),
+	}
+
+	if target != "" {
+		args = append(args, "--opt", fmt.Sprintf("target=%s", target))
+	}
+
+	for _, arg := range buildArgs {
+		fields := strings.SplitN(arg, "=", 2)
+		if len(fields) != 2 || fields[0] == "" {
+			return nil, fmt.Errorf("Invalid build argument '%s', must be KEY=VALUE", arg)
+		}
+
+		args = append(args, "--opt", fmt.Sprintf("build-arg:%s", arg))
+	}
+
+	args = append(args, "--output", fmt.Sprintf("type=oci,dest=%s", dest))
+
+	if cacheDir != "" {
+		if importCache {
+			args = append(args, "--import-cache", fmt.Sprintf("type=local,src=%s", cacheDir))
+		}
+
+		args = append(args, "--export-cache", fmt.Sprintf("type=local,dest=%s,mode=max", cacheDir))
+	}
+
+	return args, nil
+}
+
+// imagesBuildPack converts an OCI image tarball into a unified image tarball.
+func imagesBuildPack(execPath string, dir string, ociTarball string, dockerfile string) (*os.File, error) {
+	ociDir := filepath.Join(dir, "oci")
+	err := os.Mkdir(ociDir, 0700)
+	if err != nil {
+		return nil, err
+	}
+
+	msg, err := shared.RunCommand("tar", "-xf", ociTarball, "-C", ociDir)
+	if err != nil {
+		return nil, fmt.Errorf("Failed to extract the OCI image: %s", msg)
+	}
+
+	config, layers, err := ociReadImage(ociDir)
+	if err != nil {
+		return nil, err
+	}
+
+	id, err := osarch.ArchitectureId(config.Architecture)
+	if err != nil {
+		return nil, err
+	}
+
+	architecture, err := osarch.ArchitectureName(id)
+	if err != nil {
+		return nil, err
+	}
+
+	rootfs := filepath.Join(dir, "rootfs")
+	err = os.Mkdir(rootfs, 0755)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, layer := range layers {
+		err := ociApplyLayer(execPath, rootfs, layer)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	imageMeta := api.ImageMetadata{
+		Architecture: architecture,
+		CreationDate: time.Now().UTC().Unix(),
+		Properties: map[string]string{
+			"description": fmt.Sprintf("Built from %s", dockerfile),
+			"os":          config.OS,
+		},
+	}
+
+	data, err := yaml.Marshal(&imageMeta)
+	if err != nil {
+		return nil, err
+	}
+
+	err = ioutil.WriteFile(filepath.Join(dir, "metadata.yaml"), data, 0644)
+	if err != nil {
+		return nil, err
+	}
+
+	image := filepath.Join(dir, "image.tar.gz")
+	msg, err = shared.RunCommand("tar", "-czf", image, "--numeric-owner", "--xattrs",
+		"--transform=s,^\\.,rootfs,S", "-C", dir, "metadata.yaml", "-C", rootfs, ".")
+	if err != nil {
+		return nil, fmt.Errorf("Failed to pack the root filesystem: %s", msg)
+	}
+
+	return os.Open(image)
+}
+
+// imagesBuildContainerCreate creates the privileged container running a
+// build, with the build context, the BuildKit cache and the output directory
+// of the build mounted into it.
+func imagesBuildContainerCreate(d *Daemon, project string, image *api.Image, builddir string, cacheDir string) (container, error) {
+	suffix, err := shared.RandomCryptoString()
+	if err != nil {
+		return nil, err
+	}
+
+	architecture, err := osarch.ArchitectureId(image.Architecture)
+	if err != nil {
+		return nil, err
+	}
+
+	args := db.ContainerArgs{
+		Project:      project,
+		Architecture: architecture,
+		Config: map[string]string{
+			"security.nesting":    "true",
+			"security.privileged": "true",
+		},
+		Ctype:       db.CTypeRegular,
+		Description: "Dockerfile build",
+		Devices: types.Devices{
+			"build-context": map[string]string{
+				"type":     "disk",
+				"source":   filepath.Join(builddir, "context"),
+				"path":     imagesBuildContextPath,
+				"readonly": "true",
+			},
+			"build-cache": map[string]string{
+				"type":   "disk",
+				"source": cacheDir,
+				"path":   imagesBuildCachePath,
+			},
+			"build-output": map[string]string{
+				"type":   "disk",
+				"source": filepath.Join(builddir, "output"),
+				"path":   imagesBuildOutputPath,
+			},
+		},
+		Name:     fmt.Sprintf("lxd-build-%s", suffix[:12]),
+		Profiles: []string{"default"},
+	}
+
+	return containerCreateFromImage(d, args, image.Fingerprint, nil)
+}
+
+// imagesBuildContainerDelete stops and deletes a build container.
+func imagesBuildContainerDelete(c container) {
+	if c.IsRunning() {
+		err := c.Stop(false)
+		if err != nil {
+			logger.Warn("Failed to stop build container", log.Ctx{"container": c.Name(), "err": err})
+		}
+	}
+
+	err := c.Delete()
+	if err != nil {
+		logger.Warn("Failed to delete build container", log.Ctx{"container": c.Name(), "err": err})
+	}
+}
+
+// imagesBuildResponse runs a build, streaming its logs
+type imagesBuildResponse struct {
+	d          *Daemon
+	req        *http.Request
+	project    string
+	builddir   string
+	cacheDir   string
+	image      *api.Image
+	dockerfile string
+	args       []string
+}
+
+// flushWriter flushes every write to the client
+type flushWriter struct {
+	w       io.Writer
+	flusher http.Flusher
+}
+
+func (w *flushWriter) Write(p []byte) (int, error) {
+	n, err := w.w.Write(p)
+	if w.flusher != nil {
+		w.flusher.Flush()
+	}
+
+	return n, err
+}
+
+// run runs BuildKit in a build container, stopping it if the client goes
+// away.
+func (r *imagesBuildResponse) run(output io.Writer) error {
+	fmt.Fprintf(output, "Starting build container from image %s\n", r.image.Fingerprint)
+	c, err := imagesBuildContainerCreate(r.d, r.project, r.image, r.builddir, r.cacheDir)
+	if err != nil {
+		return errors.Wrap(err, "Failed to create the build container")
+	}
+	defer imagesBuildContainerDelete(c)
+
+	err = c.Start(false)
+	if err != nil {
+		return errors.Wrap(err, "Failed to start the build container")
+	}
+
+	outputR, outputW, err := os.Pipe()
+	if err != nil {
+		return err
+	}
+	defer outputR.Close()
+
+	fmt.Fprintf(output, "Building image\n")
+	command := append([]string{imagesBuildCommand}, r.args...)
+	cmd, _, _, err := c.Exec(command, execEnvironment(c, nil), nil, outputW, outputW, false)
+	outputW.Close()
+	if err != nil {
+		return errors.Wrap(err, "Failed to run BuildKit")
+	}
+
+	done := make(chan struct{})
+	go func() {
+		select {
+		case <-r.req.Context().Done():
+			c.Stop(false)
+		case <-done:
+		}
+	}()
+
+	io.Copy(output, outputR)
+	err = cmd.Wait()
+	close(done)
+	if err != nil {
+		return fmt.Errorf("Failed to build the image: exit status %d", execExitCode(err))
+	}
+
+	return nil
+}
+
+func (r *imagesBuildResponse) build(output io.Writer) (*api.Image, error) {
+	err := r.run(output)
+	if err != nil {
+		return nil, err
+	}
+
+	fmt.Fprintf(output, "Converting OCI image\n")
+	image, err := imagesBuildPack(r.d.os.ExecPath, r.builddir, filepath.Join(r.builddir, "output", "oci.tar"), r.dockerfile)
+	if err != nil {
+		return nil, err
+	}
+	defer image.Close()
+
+	fmt.Fprintf(output, "Importing image\n")
+	info, err := getImgPostInfo(r.d, r.req, r.builddir, r.project, image)
+	if err != nil {
+		return nil, err
+	}
+
+	err = imageSyncBetweenNodes(r.d, r.project, info.Fingerprint)
+	if err != nil {
+		return nil, err
+	}
+
+	return info, nil
+}
+
+func (r *imagesBuildResponse) Render(w http.ResponseWriter) error {
+	defer os.RemoveAll(r.builddir)
+
+	w.Header().Set("Content-Type", "text/plain")
+	w.Header().Set("Trailer", fmt.Sprintf("%s, %s", imagesBuildFingerprintHeader, imagesBuildErrorHeader))
+	w.WriteHeader(http.StatusOK)
+
+	flusher, _ := w.(http.Flusher)
+	output := &flushWriter{w: w, flusher: flusher}
+
+	info, err := r.build(output)
+	if err != nil {
+		logger.Warn("Failed to build image", log.Ctx{"project": r.project, "err": err})
+		fmt.Fprintf(output, "Error: %v\n", err)
+		w.Header().Set(imagesBuildErrorHeader, err.Error())
+		return nil
+	}
+
+	fmt.Fprintf(output, "Image built: %s\n", info.Fingerprint)
+	w.Header().Set(imagesBuildFingerprintHeader, info.Fingerprint)
+	return nil
+}
+
+func (r *imagesBuildResponse) String() string {
+	return "image build"
+}
+
+// /1.0/images/build
+// Build an image from a Dockerfile
+func imagesBuildPost(d *Daemon, r *http.Request) Response {
+	project := projectParam(r)
+
+	var buildImage string
+	var cacheDir string
+	err := d.db.Transaction(func(tx *db.NodeTx) error {
+		config, err := node.ConfigLoad(tx)
+		if err != nil {
+			return err
+		}
+
+		buildImage = config.BuildImage()
+		cacheDir = config.BuildKitCache()
+		return nil
+	})
+	if err != nil {
+		return SmartError(err)
+	}
+
+	if buildImage == "" {
+		return BadRequest(fmt.Errorf("No build image set in images.build_image"))
+	}
+
+	if cacheDir == "" {
+		cacheDir = shared.VarPath("buildkit")
+	}
+
+	err = os.MkdirAll(cacheDir, 0700)
+	if err != nil {
+		return InternalError(err)
+	}
+
+	// The build image is either an alias or a fingerprint
+	fingerprint := buildImage
+	_, alias, err := d.cluster.ImageAliasGet(project, buildImage, true)
+	if err == nil {
+		fingerprint = alias.Target
+	} else if err != db.ErrNoSuchObject {
+		return SmartError(err)
+	}
+
+	_, image, err := d.cluster.ImageGet(project, fingerprint, false, false)
+	if err != nil {
+		return SmartError(errors.Wrapf(err, "Failed to load build image '%s'", buildImage))
+	}
+
+	builddir, err := ioutil.TempDir(shared.VarPath("images"), "lxd_build_")
+	if err != nil {
+		return InternalError(err)
+	}
+
+	// Extract the build context
+	contextTarball := filepath.Join(builddir, "context.tar")
+	f, err := os.Create(contextTarball)
+	if err != nil {
+		os.RemoveAll(builddir)
+		return InternalError(err)
+	}
+
+	_, err = io.Copy(f, r.Body)
+	f.Close()
+	if err != nil {
+		os.RemoveAll(builddir)
+		return InternalError(err)
+	}
+
+	contextDir := filepath.Join(builddir, "context")
+	err = os.Mkdir(contextDir, 0700)
+	if err != nil {
+		os.RemoveAll(builddir)
+		return InternalError(err)
+	}
+
+	err = os.Mkdir(filepath.Join(builddir, "output"), 0700)
+	if err != nil {
+		os.RemoveAll(builddir)
+		return InternalError(err)
+	}
+
+	msg, err := shared.RunCommand("tar", "-xf", contextTarball, "-C", contextDir, "--no-same-owner")
+	os.Remove(contextTarball)
+	if err != nil {
+		os.RemoveAll(builddir)
+		return BadRequest(fmt.Errorf("Invalid build context: %s", msg))
+	}
+
+	dockerfile := queryParam(r, "dockerfile")
+	if dockerfile == "" {
+		dockerfile = "Dockerfile"
+	}
+
+	importCache := shared.PathExists(filepath.Join(cacheDir, "index.json"))
+	args, err := imagesBuildArgs(imagesBuildContextPath, dockerfile, queryParam(r, "target"), r.URL.Query()["arg"], imagesBuildCachePath, importCache, filepath.Join(imagesBuildOutputPath, "oci.tar"))
+	if err != nil {
+		os.RemoveAll(builddir)
+		return BadRequest(err)
+	}
+
+	if !shared.PathExists(filepath.Join(contextDir, filepath.Clean(dockerfile))) {
+		os.RemoveAll(builddir)
+		return BadRequest(fmt.Errorf("No %s in the build context", dockerfile))
+	}
+
+	return &imagesBuildResponse{d: d, req: r, project: project, builddir: builddir, cacheDir: cacheDir, image: image, dockerfile: dockerfile, args: args}
+}
@@ -0,0 +1,45 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestImagesBuildArgs(t *testing.T) {
+	args, err := imagesBuildArgs("/build/context", "docker/Dockerfile.prod", "runtime", []string{"VERSION=1.2", "EMPTY="}, "", false, "/build/oci.tar")
+	require.NoError(t, err)
+	assert.Equal(t, []string{
+		"build",
+		"--progress=plain",
+		"--frontend=dockerfile.v0",
+		"--local", "context=/build/context",
+		"--local", "dockerfile=/build/context/docker",
+		"--opt", "filename=Dockerfile.prod",
+		"--opt", "target=runtime",
+		"--opt", "build-arg:VERSION=1.2",
+		"--opt", "build-arg:EMPTY=",
+		"--output", "type=oci,dest=/build/oci.tar",
+	}, args)
+
+	args, err = imagesBuildArgs("/build/context", "", "", nil, "/cache", false, "/build/oci.tar")
+	require.NoError(t, err)
+	assert.Contains(t, args, "dockerfile=/build/context")
+	assert.Contains(t, args, "filename=Dockerfile")
+	assert.Contains(t, args, "type=local,dest=/cache,mode=max")
+	assert.NotContains(t, args, "--import-cache")
+
+	args, err = imagesBuildArgs("/build/context", "", "", nil, "/cache", true, "/build/oci.tar")
+	require.NoError(t, err)
+	assert.Contains(t, args, "type=local,src=/cache")
+
+	_, err = imagesBuildArgs("/build/context", "../Dockerfile", "", nil, "", false, "/build/oci.tar")
+	assert.Error(t, err)
+
+	_, err = imagesBuildArgs("/build/context", "/etc/Dockerfile", "", nil, "", false, "/build/oci.tar")
+	assert.Error(t, err)
+
+	_, err = imagesBuildArgs("/build/context", "", "", []string{"=foo"}, "", false, "/build/oci.tar")
+	assert.Error(t, err)
+}
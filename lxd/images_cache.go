@@ -0,0 +1,64 @@
+package main
+
+import (
+	"sync"
+
+	"github.com/lxc/lxd/shared/api"
+	"github.com/lxc/lxd/shared/logger"
+
+	log "github.com/lxc/lxd/shared/log15"
+)
+
+// Counters of the remote images containers were created from, since the
+// daemon started
+var imageCacheCounters struct {
+	sync.Mutex
+
+	// Images already available on this node
+	hits int64
+
+	// Images transferred from another node of the cluster
+	clusterHits int64
+
+	// Images downloaded from the remote server
+	misses int64
+}
+
+// imageCacheHit counts a remote image found in the cluster, locally if it's
+// already available on this node.
+func imageCacheHit(d *Daemon, fingerprint string) {
+	address, err := d.cluster.ImageLocate(fingerprint)
+	if err != nil {
+		// The image will be downloaded again
+		address = ""
+	}
+
+	imageCacheCounters.Lock()
+	if address != "" {
+		imageCacheCounters.clusterHits++
+	} else {
+		imageCacheCounters.hits++
+	}
+	imageCacheCounters.Unlock()
+
+	logger.Debug("Using cached copy of remote image", log.Ctx{"image": fingerprint, "node": address})
+}
+
+// imageCacheMiss counts a remote image downloaded from its server.
+func imageCacheMiss() {
+	imageCacheCounters.Lock()
+	imageCacheCounters.misses++
+	imageCacheCounters.Unlock()
+}
+
+// imageCacheStats returns the counters of the remote images cache.
+func imageCacheStats() api.ImageCacheStats {
+	imageCacheCounters.Lock()
+	defer imageCacheCounters.Unlock()
+
+	return api.ImageCacheStats{
+		Hits:        imageCacheCounters.hits,
+		ClusterHits: imageCacheCounters.clusterHits,
+		Misses:      imageCacheCounters.misses,
+	}
+}
@@ -0,0 +1,151 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+
+	"github.com/lxc/lxd/lxd/cluster"
+	"github.com/lxc/lxd/lxd/db"
+	"github.com/lxc/lxd/lxd/task"
+	"github.com/lxc/lxd/shared/logger"
+
+	log "github.com/lxc/lxd/shared/log15"
+)
+
+var imagesGCCmd = Command{
+	name: "images/gc",
+	post: imagesGCPost,
+}
+
+// Prevents the background and the manually triggered collections from
+// running concurrently
+var imagesGCLock sync.Mutex
+
+// imagesGC removes the images which aren't referenced by any container or
+// snapshot, nor aliased, and weren't used for images.gc_min_age days.
+func imagesGC(ctx context.Context, d *Daemon) error {
+	imagesGCLock.Lock()
+	defer imagesGCLock.Unlock()
+
+	minAge, err := cluster.ConfigGetInt64(d.cluster, "images.gc_min_age")
+	if err != nil {
+		return errors.Wrap(err, "Unable to fetch cluster configuration")
+	}
+
+	images, err := d.cluster.ImagesGetUnreferenced(minAge)
+	if err != nil {
+		return errors.Wrap(err, "Unable to retrieve the list of unused images")
+	}
+
+	for fp, projects := range images {
+		for _, project := range projects {
+			// It is safe to abort here since the remaining images
+			// will be collected at the next run.
+			select {
+			case <-ctx.Done():
+				return nil
+			default:
+			}
+
+			// Keep the image files when another project still has
+			// the image.
+			referenced, err := d.cluster.ImageIsReferencedByOtherProjects(project, fp)
+			if err != nil {
+				return err
+			}
+
+			if referenced {
+				imgID, _, err := d.cluster.ImageGet(project, fp, false, true)
+				if err == db.ErrNoSuchObject {
+					continue
+				}
+				if err != nil {
+					return errors.Wrapf(err, "Error retrieving image info %s", fp)
+				}
+
+				err = d.cluster.ImageDelete(imgID)
+				if err != nil {
+					return errors.Wrapf(err, "Error deleting image %s from database", fp)
+				}
+			} else {
+				err = imagePrune(d, project, fp)
+				if err != nil {
+					return err
+				}
+			}
+
+			logger.Info("Removed unused image", log.Ctx{"fingerprint": fp, "project": project})
+		}
+	}
+
+	return nil
+}
+
+func imagesGCTask(d *Daemon) (task.Func, task.Schedule) {
+	f := func(ctx context.Context) {
+		opRun := func(op *operation) error {
+			return imagesGC(ctx, d)
+		}
+
+		op, err := operationCreate(d.cluster, "", operationClassTask, db.OperationImagesGC, nil, nil, opRun, nil, nil)
+		if err != nil {
+			logger.Error("Failed to start unused images removal operation", log.Ctx{"err": err})
+			return
+		}
+
+		logger.Infof("Removing unused images")
+		chanRun, err := op.Run()
+		if err == nil {
+			err = <-chanRun
+		}
+		if err != nil {
+			logger.Error("Failed to remove unused images", log.Ctx{"err": err})
+		}
+		logger.Infof("Done removing unused images")
+	}
+
+	first := true
+	schedule := func() (time.Duration, error) {
+		var interval time.Duration
+		err := d.cluster.Transaction(func(tx *db.ClusterTx) error {
+			config, err := cluster.ConfigLoad(tx)
+			if err != nil {
+				return errors.Wrap(err, "failed to load cluster configuration")
+			}
+			interval = config.ImagesGCInterval()
+			return nil
+		})
+		if err != nil {
+			return 0, err
+		}
+
+		// Don't remove images right after startup
+		if first {
+			first = false
+			return interval, task.ErrSkip
+		}
+
+		return interval, nil
+	}
+
+	return f, schedule
+}
+
+// /1.0/images/gc
+// Remove the unused images right away
+func imagesGCPost(d *Daemon, r *http.Request) Response {
+	run := func(op *operation) error {
+		return imagesGC(context.Background(), d)
+	}
+
+	op, err := operationCreate(d.cluster, "", operationClassTask, db.OperationImagesGC, nil, nil, run, nil, nil)
+	if err != nil {
+		return InternalError(err)
+	}
+
+	return OperationResponse(op)
+}
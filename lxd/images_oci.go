@@ -0,0 +1,345 @@
+package main
+
+import (
+	"archive/tar"
+	"bufio"
+	"compress/gzip"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+
+	"github.com/pkg/errors"
+	"golang.org/x/sys/unix"
+
+	"github.com/lxc/lxd/shared"
+)
+
+// Media types of the OCI image indexes and manifests
+const (
+	ociMediaTypeIndex    = "application/vnd.oci.image.index.v1+json"
+	ociMediaTypeManifest = "application/vnd.oci.image.manifest.v1+json"
+)
+
+// Whiteout files of the OCI image layers
+const (
+	ociWhiteoutPrefix = ".wh."
+	ociWhiteoutOpaque = ".wh..wh..opq"
+)
+
+type ociDescriptor struct {
+	MediaType string `json:"mediaType"`
+	Digest    string `json:"digest"`
+}
+
+type ociIndex struct {
+	Manifests []ociDescriptor `json:"manifests"`
+}
+
+type ociManifest struct {
+	Config ociDescriptor   `json:"config"`
+	Layers []ociDescriptor `json:"layers"`
+}
+
+type ociConfig struct {
+	Architecture string `json:"architecture"`
+	OS           string `json:"os"`
+}
+
+// ociBlobPath returns the path of a blob of an OCI image layout.
+func ociBlobPath(dir string, digest string) (string, error) {
+	fields := strings.SplitN(digest, ":", 2)
+	if len(fields) != 2 || fields[0] != "sha256" || len(fields[1]) != 64 {
+		return "", fmt.Errorf("Invalid OCI digest '%s'", digest)
+	}
+
+	for _, c := range fields[1] {
+		if !strings.ContainsRune("0123456789abcdef", c) {
+			return "", fmt.Errorf("Invalid OCI digest '%s'", digest)
+		}
+	}
+
+	return filepath.Join(dir, "blobs", fields[0], fields[1]), nil
+}
+
+// ociReadBlob unmarshals a JSON blob of an OCI image layout.
+func ociReadBlob(dir string, digest string, v interface{}) error {
+	blob, err := ociBlobPath(dir, digest)
+	if err != nil {
+		return err
+	}
+
+	content, err := ioutil.ReadFile(blob)
+	if err != nil {
+		return err
+	}
+
+	return json.Unmarshal(content, v)
+}
+
+// ociReadImage returns the config and the paths of the layers, lowest first,
+// of the image of an OCI image layout.
+func ociReadImage(dir string) (*ociConfig, []string, error) {
+	content, err := ioutil.ReadFile(filepath.Join(dir, "index.json"))
+	if err != nil {
+		return nil, nil, fmt.Errorf("Invalid OCI image, no index.json: %v", err)
+	}
+
+	index := ociIndex{}
+	err = json.Unmarshal(content, &index)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	// Follow nested indexes down to the first manifest
+	for len(index.Manifests) > 0 && index.Manifests[0].MediaType == ociMediaTypeIndex {
+		digest := index.Manifests[0].Digest
+		index = ociIndex{}
+		err = ociReadBlob(dir, digest, &index)
+		if err != nil {
+			return nil, nil, err
+		}
+	}
+
+	if len(index.Manifests) != 1 || index.Manifests[0].MediaType != ociMediaTypeManifest {
+		return nil, nil, fmt.Errorf("The OCI image must hold exactly one image manifest")
+	}
+
+	manifest := ociManifest{}
+	err = ociReadBlob(dir, index.Manifests[0].Digest, &manifest)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	config := ociConfig{}
+	err = ociReadBlob(dir, manifest.Config.Digest, &config)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	layers := []string{}
+	for _, layer := range manifest.Layers {
+		blob, err := ociBlobPath(dir, layer.Digest)
+		if err != nil {
+			return nil, nil, err
+		}
+
+		layers = append(layers, blob)
+	}
+
+	return &config, layers, nil
+}
+
+// ociWhiteouts returns the paths a layer removes from the layers below it and
+// the directories whose content from those layers it hides, given the names
+// of its entries.
+func ociWhiteouts(names []string) ([]string, []string) {
+	removed := []string{}
+	opaque := []string{}
+
+	for _, name := range names {
+		name = path.Clean("/" + name)
+		base := path.Base(name)
+		dir := strings.TrimPrefix(path.Dir(name), "/")
+
+		if base == ociWhiteoutOpaque {
+			opaque = append(opaque, dir)
+		} else if strings.HasPrefix(base, ociWhiteoutPrefix) {
+			removed = append(removed, path.Join(dir, strings.TrimPrefix(base, ociWhiteoutPrefix)))
+		}
+	}
+
+	return removed, opaque
+}
+
+// ociLayerReader returns a tar reader of a layer, uncompressing it if needed.
+func ociLayerReader(layer *os.File) (*tar.Reader, error) {
+	_, err := layer.Seek(0, io.SeekStart)
+	if err != nil {
+		return nil, err
+	}
+
+	r := bufio.NewReader(layer)
+	magic, err := r.Peek(2)
+	if err == nil && magic[0] == 0x1f && magic[1] == 0x8b {
+		gz, err := gzip.NewReader(r)
+		if err != nil {
+			return nil, err
+		}
+
+		return tar.NewReader(gz), nil
+	}
+
+	return tar.NewReader(r), nil
+}
+
+// ociUnpackEntry extracts an entry of a layer under root, replacing whatever
+// the layers below it left at its path unless both are directories.
+func ociUnpackEntry(root string, hdr *tar.Header, content io.Reader) error {
+	name := path.Clean("/" + hdr.Name)
+	target := filepath.Join(root, name)
+
+	if name != "/" {
+		err := os.MkdirAll(filepath.Dir(target), 0755)
+		if err != nil {
+			return err
+		}
+
+		fi, err := os.Lstat(target)
+		if err == nil && !(fi.IsDir() && hdr.Typeflag == tar.TypeDir) {
+			err = os.RemoveAll(target)
+		}
+		if err != nil && !os.IsNotExist(err) {
+			return err
+		}
+	}
+
+	mode := hdr.FileInfo().Mode() & (os.ModePerm | os.ModeSetuid | os.ModeSetgid | os.ModeSticky)
+
+	var err error
+	switch hdr.Typeflag {
+	case tar.TypeDir:
+		err = os.Mkdir(target, 0700)
+		if os.IsExist(err) {
+			err = nil
+		}
+	case tar.TypeReg, tar.TypeRegA:
+		var f *os.File
+		f, err = os.OpenFile(target, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0600)
+		if err != nil {
+			return err
+		}
+
+		_, err = io.Copy(f, content)
+		f.Close()
+	case tar.TypeSymlink:
+		err = os.Symlink(hdr.Linkname, target)
+	case tar.TypeLink:
+		err = os.Link(filepath.Join(root, path.Clean("/"+hdr.Linkname)), target)
+	case tar.TypeChar, tar.TypeBlock, tar.TypeFifo:
+		fileType := uint32(unix.S_IFIFO)
+		if hdr.Typeflag == tar.TypeChar {
+			fileType = unix.S_IFCHR
+		} else if hdr.Typeflag == tar.TypeBlock {
+			fileType = unix.S_IFBLK
+		}
+
+		err = unix.Mknod(target, fileType|0600, int(unix.Mkdev(uint32(hdr.Devmajor), uint32(hdr.Devminor))))
+	default:
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	err = os.Lchown(target, hdr.Uid, hdr.Gid)
+	if err != nil {
+		return err
+	}
+
+	for key, value := range hdr.Xattrs {
+		err := unix.Lsetxattr(target, key, []byte(value), 0)
+		if err != nil {
+			return err
+		}
+	}
+
+	if hdr.Typeflag == tar.TypeSymlink || hdr.Typeflag == tar.TypeLink {
+		return nil
+	}
+
+	// Set the mode after the owner as changing it clears the setuid bits
+	err = os.Chmod(target, mode)
+	if err != nil {
+		return err
+	}
+
+	return os.Chtimes(target, hdr.ModTime, hdr.ModTime)
+}
+
+// ociUnpackLayer applies a layer of an OCI image on top of the root filesystem
+// at root. It's only safe to call with root being "/" from within a chroot of
+// the root filesystem, as the symlinks of the layers below are followed.
+func ociUnpackLayer(root string, layer *os.File) error {
+	tr, err := ociLayerReader(layer)
+	if err != nil {
+		return err
+	}
+
+	names := []string{}
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return err
+		}
+
+		names = append(names, hdr.Name)
+	}
+
+	// Hide what the layers below have before extracting anything, as the
+	// whiteouts may come after the entries of the layer in the same directory
+	removed, opaque := ociWhiteouts(names)
+	for _, dir := range opaque {
+		dirPath := filepath.Join(root, dir)
+		entries, err := ioutil.ReadDir(dirPath)
+		if err != nil && !os.IsNotExist(err) {
+			return err
+		}
+
+		for _, entry := range entries {
+			err := os.RemoveAll(filepath.Join(dirPath, entry.Name()))
+			if err != nil {
+				return err
+			}
+		}
+	}
+
+	for _, name := range removed {
+		err := os.RemoveAll(filepath.Join(root, name))
+		if err != nil {
+			return err
+		}
+	}
+
+	tr, err = ociLayerReader(layer)
+	if err != nil {
+		return err
+	}
+
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+
+		if strings.HasPrefix(path.Base(hdr.Name), ociWhiteoutPrefix) {
+			continue
+		}
+
+		err = ociUnpackEntry(root, hdr, tr)
+		if err != nil {
+			return errors.Wrapf(err, "Failed to extract '%s'", hdr.Name)
+		}
+	}
+}
+
+// ociApplyLayer applies a layer of an OCI image on top of a root filesystem,
+// through the forkunpack helper of LXD.
+func ociApplyLayer(execPath string, rootfs string, layer string) error {
+	output, err := shared.RunCommand(execPath, "forkunpack", rootfs, layer)
+	if err != nil {
+		return fmt.Errorf("Failed to apply the layer: %s", strings.TrimSpace(output))
+	}
+
+	return nil
+}
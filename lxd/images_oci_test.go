@@ -0,0 +1,132 @@
+package main
+
+import (
+	"archive/tar"
+	"compress/gzip"
+	"crypto/sha256"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/lxc/lxd/shared"
+)
+
+func TestOciWhiteouts(t *testing.T) {
+	removed, opaque := ociWhiteouts([]string{
+		"./",
+		"./etc/",
+		"./etc/.wh.motd",
+		"./var/cache/.wh..wh..opq",
+		"./var/cache/apt/",
+		"usr/bin/.wh.vi",
+	})
+
+	assert.Equal(t, []string{"etc/motd", "usr/bin/vi"}, removed)
+	assert.Equal(t, []string{"var/cache"}, opaque)
+}
+
+func TestOciBlobPath(t *testing.T) {
+	digest := fmt.Sprintf("sha256:%x", sha256.Sum256([]byte("foo")))
+	blob, err := ociBlobPath("/oci", digest)
+	require.NoError(t, err)
+	assert.Equal(t, filepath.Join("/oci/blobs/sha256", digest[7:]), blob)
+
+	_, err = ociBlobPath("/oci", "sha256:../../etc/passwd")
+	assert.Error(t, err)
+
+	_, err = ociBlobPath("/oci", "md5:d3b07384d113edec49eaa6238ad5ff00")
+	assert.Error(t, err)
+}
+
+func TestOciReadImage(t *testing.T) {
+	dir, err := ioutil.TempDir("", "lxd-oci-")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	require.NoError(t, os.MkdirAll(filepath.Join(dir, "blobs", "sha256"), 0755))
+	blob := func(content string) string {
+		digest := fmt.Sprintf("%x", sha256.Sum256([]byte(content)))
+		require.NoError(t, ioutil.WriteFile(filepath.Join(dir, "blobs", "sha256", digest), []byte(content), 0644))
+		return "sha256:" + digest
+	}
+
+	config := blob(`{"architecture": "amd64", "os": "linux"}`)
+	layer1 := blob("layer1")
+	layer2 := blob("layer2")
+	manifest := blob(fmt.Sprintf(`{"config": {"digest": "%s"}, "layers": [{"digest": "%s"}, {"digest": "%s"}]}`, config, layer1, layer2))
+	index := fmt.Sprintf(`{"manifests": [{"mediaType": "%s", "digest": "%s"}]}`, ociMediaTypeManifest, manifest)
+	require.NoError(t, ioutil.WriteFile(filepath.Join(dir, "index.json"), []byte(index), 0644))
+
+	imageConfig, layers, err := ociReadImage(dir)
+	require.NoError(t, err)
+	assert.Equal(t, "amd64", imageConfig.Architecture)
+	assert.Equal(t, "linux", imageConfig.OS)
+	assert.Equal(t, []string{
+		filepath.Join(dir, "blobs", "sha256", layer1[7:]),
+		filepath.Join(dir, "blobs", "sha256", layer2[7:]),
+	}, layers)
+}
+
+func TestOciUnpackLayer(t *testing.T) {
+	dir, err := ioutil.TempDir("", "lxd-rootfs-")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	require.NoError(t, os.MkdirAll(filepath.Join(dir, "var", "cache", "apt"), 0755))
+	require.NoError(t, ioutil.WriteFile(filepath.Join(dir, "etc"), []byte("not a directory"), 0644))
+	require.NoError(t, ioutil.WriteFile(filepath.Join(dir, "motd"), []byte("hello"), 0644))
+
+	layer, err := ioutil.TempFile("", "lxd-layer-")
+	require.NoError(t, err)
+	defer os.Remove(layer.Name())
+	defer layer.Close()
+
+	gz := gzip.NewWriter(layer)
+	tw := tar.NewWriter(gz)
+	entry := func(hdr *tar.Header, content string) {
+		hdr.Uid = os.Getuid()
+		hdr.Gid = os.Getgid()
+		hdr.Size = int64(len(content))
+		require.NoError(t, tw.WriteHeader(hdr))
+		_, err := tw.Write([]byte(content))
+		require.NoError(t, err)
+	}
+
+	entry(&tar.Header{Name: "./etc/", Typeflag: tar.TypeDir, Mode: 0755}, "")
+	entry(&tar.Header{Name: "./etc/hostname", Typeflag: tar.TypeReg, Mode: 0640}, "build")
+	entry(&tar.Header{Name: "./etc/hosts", Typeflag: tar.TypeLink, Linkname: "etc/hostname"}, "")
+	entry(&tar.Header{Name: "./bin/sh", Typeflag: tar.TypeSymlink, Linkname: "/bin/busybox"}, "")
+	entry(&tar.Header{Name: "./var/cache/index", Typeflag: tar.TypeReg, Mode: 0644}, "new")
+	entry(&tar.Header{Name: "./var/cache/.wh..wh..opq", Typeflag: tar.TypeReg}, "")
+	entry(&tar.Header{Name: "./.wh.motd", Typeflag: tar.TypeReg}, "")
+	require.NoError(t, tw.Close())
+	require.NoError(t, gz.Close())
+
+	require.NoError(t, ociUnpackLayer(dir, layer))
+
+	content, err := ioutil.ReadFile(filepath.Join(dir, "etc", "hosts"))
+	require.NoError(t, err)
+	assert.Equal(t, "build", string(content))
+
+	fi, err := os.Stat(filepath.Join(dir, "etc", "hostname"))
+	require.NoError(t, err)
+	assert.Equal(t, os.FileMode(0640), fi.Mode())
+
+	target, err := os.Readlink(filepath.Join(dir, "bin", "sh"))
+	require.NoError(t, err)
+	assert.Equal(t, "/bin/busybox", target)
+
+	// The opaque whiteout only hides the content of the layers below
+	entries, err := ioutil.ReadDir(filepath.Join(dir, "var", "cache"))
+	require.NoError(t, err)
+	require.Len(t, entries, 1)
+	assert.Equal(t, "index", entries[0].Name())
+
+	assert.False(t, shared.PathExists(filepath.Join(dir, "motd")))
+	assert.False(t, shared.PathExists(filepath.Join(dir, ".wh.motd")))
+}
@@ -0,0 +1,150 @@
+package main
+
+import (
+	"net/http"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/gorilla/mux"
+	"github.com/pkg/errors"
+
+	lxd "github.com/lxc/lxd/client"
+	"github.com/lxc/lxd/lxd/cluster"
+	"github.com/lxc/lxd/lxd/db"
+	"github.com/lxc/lxd/shared"
+	"github.com/lxc/lxd/shared/api"
+	"github.com/lxc/lxd/shared/ioprogress"
+	"github.com/lxc/lxd/shared/logger"
+
+	log "github.com/lxc/lxd/shared/log15"
+)
+
+var imageReplicasCmd = Command{
+	name: "images/{fingerprint}/replicas",
+	get:  imageReplicasGet,
+}
+
+// Time after which a replication which hasn't made any progress is
+// considered dead, so that the image can be replicated again.
+const imageReplicaStaleAfter = 10 * time.Minute
+
+// imageReplicaStatuses maps the replication states to their names.
+var imageReplicaStatuses = map[int]string{
+	db.ImageReplicaPending:  "pending",
+	db.ImageReplicaRunning:  "running",
+	db.ImageReplicaComplete: "complete",
+	db.ImageReplicaFailed:   "failed",
+}
+
+func imageReplicaToAPI(replica db.ImageReplica) api.ImageReplica {
+	return api.ImageReplica{
+		Node:      replica.Node,
+		Status:    imageReplicaStatuses[replica.Status],
+		Progress:  replica.Progress,
+		Error:     replica.Error,
+		UpdatedAt: replica.UpdateDate,
+	}
+}
+
+// imageReplicate pushes an image to another node, recording the progress of
+// the replication in the database.
+func imageReplicate(d *Daemon, project string, imageID int, fingerprint string, address string) error {
+	err := imagePushToNode(d, project, imageID, fingerprint, address)
+	if err != nil {
+		logger.Error("Failed to replicate image", log.Ctx{"fingerprint": fingerprint, "node": address, "err": err})
+
+		dbErr := d.cluster.ImageReplicaUpdate(imageID, address, db.ImageReplicaFailed, 0, err.Error())
+		if dbErr != nil {
+			logger.Error("Failed to record the image replication", log.Ctx{"fingerprint": fingerprint, "node": address, "err": dbErr})
+		}
+
+		return err
+	}
+
+	return d.cluster.ImageReplicaUpdate(imageID, address, db.ImageReplicaComplete, 100, "")
+}
+
+func imagePushToNode(d *Daemon, project string, imageID int, fingerprint string, address string) error {
+	err := d.cluster.ImageReplicaUpdate(imageID, address, db.ImageReplicaRunning, 0, "")
+	if err != nil {
+		return err
+	}
+
+	client, err := cluster.Connect(address, d.endpoints.NetworkCert(), true)
+	if err != nil {
+		return errors.Wrap(err, "Failed to connect node for image synchronization")
+	}
+	client = client.UseProject(project)
+
+	createArgs := &lxd.ImageCreateArgs{}
+	imageMetaPath := shared.VarPath("images", fingerprint)
+	imageRootfsPath := shared.VarPath("images", fingerprint+".rootfs")
+
+	metaFile, err := os.Open(imageMetaPath)
+	if err != nil {
+		return err
+	}
+	defer metaFile.Close()
+
+	createArgs.MetaFile = metaFile
+	createArgs.MetaName = filepath.Base(imageMetaPath)
+
+	if shared.PathExists(imageRootfsPath) {
+		rootfsFile, err := os.Open(imageRootfsPath)
+		if err != nil {
+			return err
+		}
+		defer rootfsFile.Close()
+
+		createArgs.RootfsFile = rootfsFile
+		createArgs.RootfsName = filepath.Base(imageRootfsPath)
+	}
+
+	// Record the upload progress, without hitting the database for every
+	// chunk sent
+	lastProgress := 0
+	createArgs.ProgressHandler = func(progress ioprogress.ProgressData) {
+		if progress.Percentage == lastProgress {
+			return
+		}
+		lastProgress = progress.Percentage
+
+		err := d.cluster.ImageReplicaUpdate(imageID, address, db.ImageReplicaRunning, progress.Percentage, "")
+		if err != nil {
+			logger.Warn("Failed to record the image replication progress", log.Ctx{"fingerprint": fingerprint, "node": address, "err": err})
+		}
+	}
+
+	image := api.ImagesPost{}
+	image.Filename = createArgs.MetaName
+
+	op, err := client.CreateImage(image, createArgs)
+	if err != nil {
+		return err
+	}
+
+	return op.Wait()
+}
+
+func imageReplicasGet(d *Daemon, r *http.Request) Response {
+	project := projectParam(r)
+	fingerprint := mux.Vars(r)["fingerprint"]
+
+	imageID, _, err := d.cluster.ImageGet(project, fingerprint, false, false)
+	if err != nil {
+		return SmartError(err)
+	}
+
+	replicas, err := d.cluster.ImageReplicas(imageID)
+	if err != nil {
+		return SmartError(err)
+	}
+
+	result := []api.ImageReplica{}
+	for _, replica := range replicas {
+		result = append(result, imageReplicaToAPI(replica))
+	}
+
+	return SyncResponse(true, result)
+}
@@ -0,0 +1,268 @@
+package main
+
+import (
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+
+	"github.com/gorilla/mux"
+	"github.com/sigstore/sigstore-go/pkg/bundle"
+	"github.com/sigstore/sigstore-go/pkg/root"
+	"github.com/sigstore/sigstore-go/pkg/verify"
+
+	"github.com/lxc/lxd/lxd/cluster"
+	"github.com/lxc/lxd/lxd/db"
+	"github.com/lxc/lxd/shared/api"
+)
+
+var imageSignatureCmd = Command{
+	name: "images/{fingerprint}/signature",
+	get:  imageSignatureGet,
+	put:  imageSignaturePut,
+}
+
+// imageSignaturePolicy is the content of the file images.signature_policy
+// points to.
+type imageSignaturePolicy struct {
+	// Path of the sigstore trusted root (trusted_root.json) holding the
+	// certificate authorities, transparency logs and timestamp authorities
+	TrustedRoot string `json:"trusted_root"`
+
+	// Signers allowed to sign images, any of them matching being enough
+	Identities []imageSignatureIdentity `json:"identities"`
+
+	// Don't require the signing certificates to be in a certificate
+	// transparency log, for the sigstore instances not running one
+	InsecureIgnoreSCT bool `json:"insecure_ignore_sct"`
+}
+
+// imageSignatureIdentity matches the certificate of a keyless signer, either
+// exactly or through regular expressions.
+type imageSignatureIdentity struct {
+	Issuer        string `json:"issuer"`
+	IssuerRegexp  string `json:"issuer_regexp"`
+	Subject       string `json:"subject"`
+	SubjectRegexp string `json:"subject_regexp"`
+}
+
+// imageSignatureError is a failed check of the signature policy.
+type imageSignatureError struct {
+	check string
+	err   error
+}
+
+func (e imageSignatureError) Error() string {
+	return fmt.Sprintf("Image signature check %q failed: %v", e.check, e.err)
+}
+
+// imageSignaturePolicyLoad parses a signature policy file.
+func imageSignaturePolicyLoad(path string) (*imageSignaturePolicy, error) {
+	content, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	policy := imageSignaturePolicy{}
+	err = json.Unmarshal(content, &policy)
+	if err != nil {
+		return nil, fmt.Errorf("Invalid signature policy: %v", err)
+	}
+
+	if policy.TrustedRoot == "" {
+		return nil, fmt.Errorf("The signature policy has no trusted root")
+	}
+
+	if len(policy.Identities) == 0 {
+		return nil, fmt.Errorf("The signature policy has no identities")
+	}
+
+	return &policy, nil
+}
+
+// imageSignatureVerify verifies that a sigstore bundle signs the image with
+// the given fingerprint and passes the policy.
+func imageSignatureVerify(policy *imageSignaturePolicy, bundleJSON string, fingerprint string) error {
+	if bundleJSON == "" {
+		return imageSignatureError{"signed", fmt.Errorf("The image has no signature")}
+	}
+
+	b := &bundle.Bundle{}
+	err := b.UnmarshalJSON([]byte(bundleJSON))
+	if err != nil {
+		return imageSignatureError{"bundle", err}
+	}
+
+	trustedRoot, err := root.NewTrustedRootFromPath(policy.TrustedRoot)
+	if err != nil {
+		return imageSignatureError{"trusted root", err}
+	}
+
+	return imageSignatureVerifyEntity(trustedRoot, policy, b, fingerprint)
+}
+
+// imageSignatureVerifyEntity verifies that a signed entity signs the image
+// with the given fingerprint and passes the policy, its trusted root being
+// replaced by the given trusted material.
+func imageSignatureVerifyEntity(trustedMaterial root.TrustedMaterial, policy *imageSignaturePolicy, entity verify.SignedEntity, fingerprint string) error {
+	digest, err := hex.DecodeString(fingerprint)
+	if err != nil {
+		return err
+	}
+
+	options := []verify.VerifierOption{
+		verify.WithTransparencyLog(1),
+		verify.WithObserverTimestamps(1),
+	}
+
+	if !policy.InsecureIgnoreSCT {
+		options = append(options, verify.WithSignedCertificateTimestamps(1))
+	}
+
+	verifier, err := verify.NewVerifier(trustedMaterial, options...)
+	if err != nil {
+		return imageSignatureError{"trusted root", err}
+	}
+
+	// Check the signature itself first, to tell it apart from its signer
+	artifact := verify.WithArtifactDigest("sha256", digest)
+	_, err = verifier.Verify(entity, verify.NewPolicy(artifact, verify.WithoutIdentitiesUnsafe()))
+	if err != nil {
+		return imageSignatureError{"signature", err}
+	}
+
+	identities := []verify.PolicyOption{}
+	for _, identity := range policy.Identities {
+		certID, err := verify.NewShortCertificateIdentity(identity.Issuer, identity.IssuerRegexp, identity.Subject, identity.SubjectRegexp)
+		if err != nil {
+			return imageSignatureError{"identities", err}
+		}
+
+		identities = append(identities, verify.WithCertificateIdentity(certID))
+	}
+
+	_, err = verifier.Verify(entity, verify.NewPolicy(artifact, identities...))
+	if err != nil {
+		return imageSignatureError{"identities", err}
+	}
+
+	return nil
+}
+
+// imageSignatureCheck verifies the signature of an image containers are to
+// be created from, when images.require_signature is set.
+func imageSignatureCheck(d *Daemon, imageID int, fingerprint string) error {
+	required, err := cluster.ConfigGetBool(d.cluster, "images.require_signature")
+	if err != nil {
+		return err
+	}
+
+	if !required {
+		return nil
+	}
+
+	signature, err := d.cluster.ImageSignatureGet(imageID)
+	if err != nil {
+		return err
+	}
+
+	if signature.Exempt {
+		return nil
+	}
+
+	return imageSignatureCheckPolicy(d, signature.Bundle, fingerprint)
+}
+
+func imageSignatureCheckPolicy(d *Daemon, bundleJSON string, fingerprint string) error {
+	path, err := cluster.ConfigGetString(d.cluster, "images.signature_policy")
+	if err != nil {
+		return err
+	}
+
+	if path == "" {
+		return imageSignatureError{"policy", fmt.Errorf("No images.signature_policy is set")}
+	}
+
+	policy, err := imageSignaturePolicyLoad(path)
+	if err != nil {
+		return imageSignatureError{"policy", err}
+	}
+
+	return imageSignatureVerify(policy, bundleJSON, fingerprint)
+}
+
+// imageSignatureResponse returns the response rejecting a container creation
+// on a failed signature check.
+func imageSignatureResponse(err error) Response {
+	_, ok := err.(imageSignatureError)
+	if ok {
+		return Forbidden(err)
+	}
+
+	return SmartError(err)
+}
+
+// /1.0/images/{fingerprint}/signature
+// Get the signature of an image and whether it passes the signature policy
+func imageSignatureGet(d *Daemon, r *http.Request) Response {
+	project := projectParam(r)
+	fingerprint := mux.Vars(r)["fingerprint"]
+
+	imageID, info, err := d.cluster.ImageGet(project, fingerprint, false, false)
+	if err != nil {
+		return SmartError(err)
+	}
+
+	signature, err := d.cluster.ImageSignatureGet(imageID)
+	if err != nil {
+		return SmartError(err)
+	}
+
+	result := api.ImageSignature{}
+	result.Bundle = signature.Bundle
+	result.Exempt = signature.Exempt
+
+	err = imageSignatureCheckPolicy(d, signature.Bundle, info.Fingerprint)
+	if err != nil {
+		result.Error = err.Error()
+	} else {
+		result.Verified = true
+	}
+
+	return SyncResponse(true, result)
+}
+
+// /1.0/images/{fingerprint}/signature
+// Set the signature of an image, or exempt it from verification. Every
+// trusted client is an administrator of the server, so any of them can
+// exempt images.
+func imageSignaturePut(d *Daemon, r *http.Request) Response {
+	project := projectParam(r)
+	fingerprint := mux.Vars(r)["fingerprint"]
+
+	imageID, _, err := d.cluster.ImageGet(project, fingerprint, false, false)
+	if err != nil {
+		return SmartError(err)
+	}
+
+	req := api.ImageSignaturePut{}
+	err = json.NewDecoder(r.Body).Decode(&req)
+	if err != nil {
+		return BadRequest(err)
+	}
+
+	if req.Bundle != "" {
+		err := (&bundle.Bundle{}).UnmarshalJSON([]byte(req.Bundle))
+		if err != nil {
+			return BadRequest(fmt.Errorf("Invalid sigstore bundle: %v", err))
+		}
+	}
+
+	err = d.cluster.ImageSignatureUpdate(imageID, db.ImageSignature{Bundle: req.Bundle, Exempt: req.Exempt})
+	if err != nil {
+		return SmartError(err)
+	}
+
+	return EmptySyncResponse
+}
@@ -0,0 +1,96 @@
+package main
+
+import (
+	"crypto/sha256"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/sigstore/sigstore-go/pkg/testing/ca"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestImageSignaturePolicyLoad(t *testing.T) {
+	dir, err := ioutil.TempDir("", "lxd-signature-")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	path := filepath.Join(dir, "policy.json")
+	require.NoError(t, ioutil.WriteFile(path, []byte(`{
+  "trusted_root": "/etc/lxd/trusted_root.json",
+  "identities": [{"issuer": "https://accounts.google.com", "subject_regexp": "@example\\.com$"}]
+}`), 0600))
+
+	policy, err := imageSignaturePolicyLoad(path)
+	require.NoError(t, err)
+	assert.Equal(t, "/etc/lxd/trusted_root.json", policy.TrustedRoot)
+	assert.Equal(t, []imageSignatureIdentity{
+		{Issuer: "https://accounts.google.com", SubjectRegexp: "@example\\.com$"},
+	}, policy.Identities)
+
+	require.NoError(t, ioutil.WriteFile(path, []byte(`{"trusted_root": "/etc/lxd/trusted_root.json"}`), 0600))
+	_, err = imageSignaturePolicyLoad(path)
+	assert.Error(t, err)
+}
+
+func TestImageSignatureVerifyEntity(t *testing.T) {
+	sigstore, err := ca.NewVirtualSigstore()
+	require.NoError(t, err)
+
+	image := []byte("image")
+	fingerprint := fmt.Sprintf("%x", sha256.Sum256(image))
+
+	entity, err := sigstore.Sign("builder@example.com", "https://issuer.example.com", image)
+	require.NoError(t, err)
+
+	policy := &imageSignaturePolicy{
+		Identities: []imageSignatureIdentity{
+			{Issuer: "https://other.example.com", Subject: "builder@example.com"},
+			{Issuer: "https://issuer.example.com", SubjectRegexp: "@example\\.com$"},
+		},
+		InsecureIgnoreSCT: true,
+	}
+
+	err = imageSignatureVerifyEntity(sigstore, policy, entity, fingerprint)
+	assert.NoError(t, err)
+
+	// Signed by someone else
+	other := &imageSignaturePolicy{Identities: policy.Identities[:1], InsecureIgnoreSCT: true}
+	err = imageSignatureVerifyEntity(sigstore, other, entity, fingerprint)
+	require.Error(t, err)
+	assert.Equal(t, "identities", err.(imageSignatureError).check)
+
+	// Signing another image
+	err = imageSignatureVerifyEntity(sigstore, policy, entity, fmt.Sprintf("%x", sha256.Sum256([]byte("other"))))
+	require.Error(t, err)
+	assert.Equal(t, "signature", err.(imageSignatureError).check)
+
+	// Signed through another sigstore instance
+	untrusted, err := ca.NewVirtualSigstore()
+	require.NoError(t, err)
+
+	err = imageSignatureVerifyEntity(untrusted, policy, entity, fingerprint)
+	require.Error(t, err)
+	assert.Equal(t, "signature", err.(imageSignatureError).check)
+
+	// Without a certificate transparency log entry
+	policy.InsecureIgnoreSCT = false
+	err = imageSignatureVerifyEntity(sigstore, policy, entity, fingerprint)
+	require.Error(t, err)
+	assert.Equal(t, "signature", err.(imageSignatureError).check)
+}
+
+func TestImageSignatureVerify(t *testing.T) {
+	policy := &imageSignaturePolicy{TrustedRoot: "/nonexistent"}
+
+	err := imageSignatureVerify(policy, "", "abcd")
+	require.Error(t, err)
+	assert.Equal(t, "signed", err.(imageSignatureError).check)
+
+	err = imageSignatureVerify(policy, "{", "abcd")
+	require.Error(t, err)
+	assert.Equal(t, "bundle", err.(imageSignatureError).check)
+}
@@ -0,0 +1,503 @@
+package main
+
+import (
+	"bufio"
+	"debug/elf"
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"gopkg.in/yaml.v2"
+
+	"github.com/lxc/lxd/lxd/db"
+	"github.com/lxc/lxd/shared"
+	"github.com/lxc/lxd/shared/api"
+	"github.com/lxc/lxd/shared/logger"
+	"github.com/lxc/lxd/shared/osarch"
+
+	log "github.com/lxc/lxd/shared/log15"
+)
+
+var imagesImportVagrantCmd = Command{
+	name: "images/import-vagrant",
+	post: imagesImportVagrantPost,
+}
+
+// vagrantBoxMetadata is the metadata.json file of a Vagrant box.
+type vagrantBoxMetadata struct {
+	Provider    string `json:"provider"`
+	Format      string `json:"format"`
+	VirtualSize int64  `json:"virtual_size"`
+}
+
+// Matches the string settings of the machine in a Vagrantfile, such as
+// config.vm.hostname = "web"
+var vagrantSettingRegexp = regexp.MustCompile(`^\s*config\.vm\.([a-z_.]+)\s*=\s*["']([^"']*)["']`)
+
+// vagrantParseBoxMetadata parses the metadata.json file of a box.
+func vagrantParseBoxMetadata(content []byte) (*vagrantBoxMetadata, error) {
+	metadata := vagrantBoxMetadata{}
+	err := json.Unmarshal(content, &metadata)
+	if err != nil {
+		return nil, fmt.Errorf("Invalid box metadata: %v", err)
+	}
+
+	if !shared.StringInSlice(metadata.Provider, []string{"libvirt", "virtualbox"}) {
+		return nil, fmt.Errorf("Unsupported box provider '%s'", metadata.Provider)
+	}
+
+	return &metadata, nil
+}
+
+// vagrantParseVagrantfile returns the string settings of the machine of a
+// base box Vagrantfile as image properties.
+func vagrantParseVagrantfile(content string) (map[string]string, error) {
+	properties := map[string]string{}
+
+	scanner := bufio.NewScanner(strings.NewReader(content))
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if strings.HasPrefix(line, "#") {
+			continue
+		}
+
+		if strings.Contains(line, "config.vm.define") {
+			return nil, fmt.Errorf("Multi-machine boxes aren't supported")
+		}
+
+		match := vagrantSettingRegexp.FindStringSubmatch(line)
+		if match == nil {
+			continue
+		}
+
+		properties[fmt.Sprintf("vagrant.%s", match[1])] = match[2]
+	}
+
+	return properties, scanner.Err()
+}
+
+// vagrantBoxFile checks that a file of the extracted box is a regular file,
+// so that a box shipping symlinks or device nodes can't get host files read.
+func vagrantBoxFile(path string) error {
+	fi, err := os.Lstat(path)
+	if err != nil {
+		return err
+	}
+
+	if !fi.Mode().IsRegular() {
+		return fmt.Errorf("Invalid box, %s isn't a regular file", filepath.Base(path))
+	}
+
+	return nil
+}
+
+// vagrantReadBoxFile reads a regular file of the extracted box.
+func vagrantReadBoxFile(path string) ([]byte, error) {
+	err := vagrantBoxFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	return ioutil.ReadFile(path)
+}
+
+// vagrantFindDisk returns the path and format of the disk image of a box
+// extracted in the given directory.
+func vagrantFindDisk(dir string, metadata *vagrantBoxMetadata) (string, string, error) {
+	if metadata.Provider == "libvirt" {
+		format := metadata.Format
+		if format == "" {
+			format = "qcow2"
+		}
+
+		path := filepath.Join(dir, "box.img")
+		if !shared.PathExists(path) {
+			return "", "", fmt.Errorf("No box.img disk image in the box")
+		}
+
+		err := vagrantBoxFile(path)
+		if err != nil {
+			return "", "", err
+		}
+
+		return path, format, nil
+	}
+
+	// VirtualBox boxes come with an OVF descriptor and VMDK disks, the
+	// first one being the boot disk.
+	disks, err := filepath.Glob(filepath.Join(dir, "*.vmdk"))
+	if err != nil {
+		return "", "", err
+	}
+
+	if len(disks) == 0 {
+		return "", "", fmt.Errorf("No VMDK disk image in the box")
+	}
+
+	err = vagrantBoxFile(disks[0])
+	if err != nil {
+		return "", "", err
+	}
+
+	return disks[0], "vmdk", nil
+}
+
+// vagrantParseOSRelease returns the image properties matching an os-release
+// file.
+func vagrantParseOSRelease(content string) map[string]string {
+	fields := map[string]string{}
+	for _, line := range strings.Split(content, "\n") {
+		parts := strings.SplitN(strings.TrimSpace(line), "=", 2)
+		if len(parts) != 2 {
+			continue
+		}
+
+		fields[parts[0]] = strings.Trim(parts[1], `"'`)
+	}
+
+	properties := map[string]string{}
+	if fields["ID"] != "" {
+		properties["os"] = fields["ID"]
+	}
+
+	if fields["VERSION_ID"] != "" {
+		properties["release"] = fields["VERSION_ID"]
+	}
+
+	if fields["PRETTY_NAME"] != "" {
+		properties["description"] = fields["PRETTY_NAME"]
+	}
+
+	return properties
+}
+
+// vagrantArchitecture returns the name of the architecture of an ELF binary.
+func vagrantArchitecture(f *elf.File) (string, error) {
+	switch f.Machine {
+	case elf.EM_X86_64:
+		return "x86_64", nil
+	case elf.EM_386:
+		return "i686", nil
+	case elf.EM_AARCH64:
+		return "aarch64", nil
+	case elf.EM_ARM:
+		return "armv7l", nil
+	case elf.EM_PPC64:
+		if f.ByteOrder == binary.LittleEndian {
+			return "ppc64le", nil
+		}
+
+		return "ppc64", nil
+	case elf.EM_S390:
+		return "s390x", nil
+	}
+
+	return "", fmt.Errorf("Unsupported architecture %v", f.Machine)
+}
+
+// vagrantDetectArchitecture returns the architecture of a root filesystem,
+// based on one of its binaries.
+func vagrantDetectArchitecture(rootfs string) (string, error) {
+	for _, path := range []string{"usr/bin/env", "bin/busybox"} {
+		fi, err := os.Lstat(filepath.Join(rootfs, path))
+		if err != nil || !fi.Mode().IsRegular() {
+			continue
+		}
+
+		f, err := elf.Open(filepath.Join(rootfs, path))
+		if err != nil {
+			return "", err
+		}
+		defer f.Close()
+
+		return vagrantArchitecture(f)
+	}
+
+	return "", fmt.Errorf("Couldn't detect the architecture of the root filesystem")
+}
+
+// vagrantNbdConnect attaches a disk image read-only to a free nbd device.
+func vagrantNbdConnect(path string, format string) (string, error) {
+	if !shared.PathExists("/sys/module/nbd") {
+		_, err := shared.RunCommand("modprobe", "nbd", "max_part=16")
+		if err != nil {
+			return "", fmt.Errorf("Failed to load the nbd module: %v", err)
+		}
+	}
+
+	devices, err := filepath.Glob("/sys/block/nbd*")
+	if err != nil {
+		return "", err
+	}
+
+	for _, device := range devices {
+		// Devices in use have a pid file
+		if shared.PathExists(filepath.Join(device, "pid")) {
+			continue
+		}
+
+		dev := filepath.Join("/dev", filepath.Base(device))
+		_, err := shared.RunCommand("qemu-nbd", "--read-only", fmt.Sprintf("--format=%s", format), fmt.Sprintf("--connect=%s", dev), path)
+		if err != nil {
+			continue
+		}
+
+		// Wait for the device and its partitions to show up
+		for i := 0; i < 50; i++ {
+			if shared.PathExists(filepath.Join(device, "pid")) {
+				break
+			}
+
+			time.Sleep(100 * time.Millisecond)
+		}
+
+		return dev, nil
+	}
+
+	return "", fmt.Errorf("No free nbd device")
+}
+
+func vagrantNbdDisconnect(dev string) {
+	_, err := shared.RunCommand("qemu-nbd", "--disconnect", dev)
+	if err != nil {
+		logger.Warn("Failed to disconnect nbd device", log.Ctx{"device": dev, "err": err})
+	}
+}
+
+// vagrantMountRootfs mounts read-only the partition of an nbd device holding
+// the root filesystem, returning a function unmounting it.
+func vagrantMountRootfs(dev string, target string) (func(), error) {
+	partitions, err := filepath.Glob(fmt.Sprintf("%sp*", dev))
+	if err != nil {
+		return nil, err
+	}
+
+	// Boxes without a partition table
+	partitions = append(partitions, dev)
+
+	for _, partition := range partitions {
+		_, err := shared.RunCommand("mount", "-o", "ro", partition, target)
+		if err != nil {
+			continue
+		}
+
+		if shared.PathExists(filepath.Join(target, "etc", "os-release")) || shared.PathExists(filepath.Join(target, "sbin", "init")) {
+			return func() {
+				_, err := shared.RunCommand("umount", target)
+				if err != nil {
+					logger.Warn("Failed to unmount box root filesystem", log.Ctx{"path": target, "err": err})
+				}
+			}, nil
+		}
+
+		shared.RunCommand("umount", target)
+	}
+
+	return nil, fmt.Errorf("No root filesystem found in the disk image")
+}
+
+// vagrantBuildImage converts an extracted box into a unified image tarball.
+func vagrantBuildImage(dir string, progress func(stage string)) (*os.File, error) {
+	content, err := vagrantReadBoxFile(filepath.Join(dir, "metadata.json"))
+	if err != nil {
+		return nil, fmt.Errorf("Invalid box, no metadata.json: %v", err)
+	}
+
+	metadata, err := vagrantParseBoxMetadata(content)
+	if err != nil {
+		return nil, err
+	}
+
+	properties := map[string]string{
+		"description": fmt.Sprintf("Vagrant box (%s)", metadata.Provider),
+	}
+
+	content, err = vagrantReadBoxFile(filepath.Join(dir, "Vagrantfile"))
+	if err != nil && !os.IsNotExist(err) {
+		return nil, err
+	}
+
+	if err == nil {
+		settings, err := vagrantParseVagrantfile(string(content))
+		if err != nil {
+			return nil, err
+		}
+
+		for k, v := range settings {
+			properties[k] = v
+		}
+	}
+
+	disk, format, err := vagrantFindDisk(dir, metadata)
+	if err != nil {
+		return nil, err
+	}
+
+	progress("Attaching disk image")
+	dev, err := vagrantNbdConnect(disk, format)
+	if err != nil {
+		return nil, err
+	}
+	defer vagrantNbdDisconnect(dev)
+
+	rootfs := filepath.Join(dir, "rootfs")
+	err = os.Mkdir(rootfs, 0755)
+	if err != nil {
+		return nil, err
+	}
+
+	unmount, err := vagrantMountRootfs(dev, rootfs)
+	if err != nil {
+		return nil, err
+	}
+	defer unmount()
+
+	architecture, err := vagrantDetectArchitecture(rootfs)
+	if err != nil {
+		return nil, err
+	}
+
+	_, err = osarch.ArchitectureId(architecture)
+	if err != nil {
+		return nil, err
+	}
+
+	content, err = ioutil.ReadFile(filepath.Join(rootfs, "etc", "os-release"))
+	if err == nil {
+		for k, v := range vagrantParseOSRelease(string(content)) {
+			properties[k] = v
+		}
+	}
+
+	imageMeta := api.ImageMetadata{
+		Architecture: architecture,
+		CreationDate: time.Now().UTC().Unix(),
+		Properties:   properties,
+	}
+
+	data, err := yaml.Marshal(&imageMeta)
+	if err != nil {
+		return nil, err
+	}
+
+	err = ioutil.WriteFile(filepath.Join(dir, "metadata.yaml"), data, 0644)
+	if err != nil {
+		return nil, err
+	}
+
+	// The disks of the machine aren't there to be mounted in a container
+	progress("Packing root filesystem")
+	image := filepath.Join(dir, "image.tar.gz")
+	msg, err := shared.RunCommand("tar", "-czf", image, "--numeric-owner", "--xattrs",
+		"--exclude=./etc/fstab", "--transform=s,^\\.,rootfs,S",
+		"-C", dir, "metadata.yaml", "-C", rootfs, ".")
+	if err != nil {
+		return nil, fmt.Errorf("Failed to pack the root filesystem: %s", msg)
+	}
+
+	return os.Open(image)
+}
+
+// /1.0/images/import-vagrant
+// Import a Vagrant box as an image
+func imagesImportVagrantPost(d *Daemon, r *http.Request) Response {
+	project := projectParam(r)
+
+	builddir, err := ioutil.TempDir(shared.VarPath("images"), "lxd_build_")
+	if err != nil {
+		return InternalError(err)
+	}
+
+	cleanup := func() {
+		err := os.RemoveAll(builddir)
+		if err != nil {
+			logger.Debugf("Error deleting temporary directory \"%s\": %s", builddir, err)
+		}
+	}
+
+	box, err := os.Create(filepath.Join(builddir, "box"))
+	if err != nil {
+		cleanup()
+		return InternalError(err)
+	}
+
+	_, err = io.Copy(box, r.Body)
+	box.Close()
+	if err != nil {
+		cleanup()
+		return InternalError(err)
+	}
+
+	run := func(op *operation) error {
+		defer cleanup()
+
+		stages := []string{"Extracting box", "Attaching disk image", "Packing root filesystem", "Importing image"}
+		progress := func(stage string) {
+			for i, name := range stages {
+				if name == stage {
+					metadata := map[string]interface{}{}
+					shared.SetProgressMetadata(metadata, "vagrant_import", stage, int64(i*100/len(stages)), 0, 0)
+					op.UpdateMetadata(metadata)
+				}
+			}
+		}
+
+		progress("Extracting box")
+		boxDir := filepath.Join(builddir, "extracted")
+		err := os.Mkdir(boxDir, 0700)
+		if err != nil {
+			return err
+		}
+
+		extractArgs, extension, _, err := shared.DetectCompression(box.Name())
+		if err != nil || !strings.HasPrefix(extension, ".tar") {
+			return fmt.Errorf("Invalid box, not a tarball")
+		}
+
+		args := append([]string{"-C", boxDir}, extractArgs...)
+		args = append(args, box.Name())
+		msg, err := shared.RunCommand("tar", args...)
+		if err != nil {
+			return fmt.Errorf("Failed to extract the box: %s", msg)
+		}
+
+		os.Remove(box.Name())
+
+		image, err := vagrantBuildImage(boxDir, progress)
+		if err != nil {
+			return err
+		}
+		defer image.Close()
+
+		progress("Importing image")
+		info, err := getImgPostInfo(d, r, builddir, project, image)
+		if info != nil {
+			op.UpdateMetadata(map[string]interface{}{
+				"fingerprint": info.Fingerprint,
+				"size":        strconv.FormatInt(info.Size, 10),
+			})
+		}
+		if err != nil {
+			return err
+		}
+
+		return imageSyncBetweenNodes(d, project, info.Fingerprint)
+	}
+
+	op, err := operationCreate(d.cluster, project, operationClassTask, db.OperationImageDownload, nil, nil, run, nil, nil)
+	if err != nil {
+		cleanup()
+		return InternalError(err)
+	}
+
+	return OperationResponse(op)
+}
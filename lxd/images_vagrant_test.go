@@ -0,0 +1,79 @@
+package main
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestVagrantParseBoxMetadata(t *testing.T) {
+	metadata, err := vagrantParseBoxMetadata([]byte(`{"provider": "libvirt", "format": "qcow2", "virtual_size": 40}`))
+	require.NoError(t, err)
+	assert.Equal(t, "libvirt", metadata.Provider)
+	assert.Equal(t, "qcow2", metadata.Format)
+
+	_, err = vagrantParseBoxMetadata([]byte(`{"provider": "docker"}`))
+	assert.Error(t, err)
+
+	_, err = vagrantParseBoxMetadata([]byte(`{`))
+	assert.Error(t, err)
+}
+
+func TestVagrantParseVagrantfile(t *testing.T) {
+	properties, err := vagrantParseVagrantfile(`Vagrant.configure("2") do |config|
+  config.vm.hostname = "web"
+  # config.vm.box = "ignored"
+  config.vm.guest = :linux
+  config.vm.box_version = '1.2'
+end
+`)
+	require.NoError(t, err)
+	assert.Equal(t, map[string]string{"vagrant.hostname": "web", "vagrant.box_version": "1.2"}, properties)
+
+	_, err = vagrantParseVagrantfile(`Vagrant.configure("2") do |config|
+  config.vm.define "web" do |web|
+  end
+end
+`)
+	assert.Error(t, err)
+}
+
+func TestVagrantFindDisk(t *testing.T) {
+	dir, err := ioutil.TempDir("", "lxd_vagrant_")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	_, _, err = vagrantFindDisk(dir, &vagrantBoxMetadata{Provider: "libvirt"})
+	assert.Error(t, err)
+
+	require.NoError(t, ioutil.WriteFile(filepath.Join(dir, "box.img"), []byte{}, 0644))
+	path, format, err := vagrantFindDisk(dir, &vagrantBoxMetadata{Provider: "libvirt"})
+	require.NoError(t, err)
+	assert.Equal(t, filepath.Join(dir, "box.img"), path)
+	assert.Equal(t, "qcow2", format)
+
+	require.NoError(t, ioutil.WriteFile(filepath.Join(dir, "box-disk001.vmdk"), []byte{}, 0644))
+	path, format, err = vagrantFindDisk(dir, &vagrantBoxMetadata{Provider: "virtualbox"})
+	require.NoError(t, err)
+	assert.Equal(t, filepath.Join(dir, "box-disk001.vmdk"), path)
+	assert.Equal(t, "vmdk", format)
+
+	// Symlinks to host files are rejected
+	require.NoError(t, os.Remove(filepath.Join(dir, "box.img")))
+	require.NoError(t, os.Symlink("/etc/passwd", filepath.Join(dir, "box.img")))
+	_, _, err = vagrantFindDisk(dir, &vagrantBoxMetadata{Provider: "libvirt"})
+	assert.Error(t, err)
+}
+
+func TestVagrantParseOSRelease(t *testing.T) {
+	properties := vagrantParseOSRelease(`NAME="Ubuntu"
+VERSION_ID="18.04"
+ID=ubuntu
+PRETTY_NAME="Ubuntu 18.04.3 LTS"
+`)
+	assert.Equal(t, map[string]string{"os": "ubuntu", "release": "18.04", "description": "Ubuntu 18.04.3 LTS"}, properties)
+}
@@ -0,0 +1,318 @@
+package main
+
+import (
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/pkg/errors"
+
+	"github.com/lxc/lxd/lxd/state"
+	"github.com/lxc/lxd/shared"
+	"github.com/lxc/lxd/shared/api"
+	"github.com/lxc/lxd/shared/logger"
+
+	log "github.com/lxc/lxd/shared/log15"
+)
+
+var infinibandResourcesCmd = Command{
+	name: "resources/infiniband",
+	get:  infinibandResourcesGet,
+}
+
+// ibVF is a virtual function of an SR-IOV enabled infiniband device.
+type ibVF struct {
+	parent string
+	index  int
+	pci    string
+
+	// Name of the network interface of the function on the host
+	name string
+
+	// Container device the function is allocated to ("<container>/<device>"
+	// with the project prefix), empty if free
+	owner string
+}
+
+// ibVFPool tracks the allocation of the infiniband virtual functions to
+// containers, so that concurrent container starts don't pick the same one.
+type ibVFPool struct {
+	mu  sync.Mutex
+	vfs map[string]*ibVF
+}
+
+var infinibandPool = &ibVFPool{vfs: map[string]*ibVF{}}
+
+// refresh adds the virtual functions of all the SR-IOV enabled infiniband
+// devices which aren't known yet. Must be called with the lock held.
+func (p *ibVFPool) refresh() error {
+	ibDevs, err := ioutil.ReadDir(SCIB)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil
+		}
+
+		return err
+	}
+
+	for _, ibDev := range ibDevs {
+		devicePath := filepath.Join(SCIB, ibDev.Name(), "device")
+		if !shared.PathExists(filepath.Join(devicePath, "sriov_numvfs")) {
+			continue
+		}
+
+		// The network interfaces of the physical function
+		parents, err := ioutil.ReadDir(filepath.Join(devicePath, "net"))
+		if err != nil || len(parents) == 0 {
+			continue
+		}
+
+		content, err := ioutil.ReadFile(filepath.Join(devicePath, "sriov_numvfs"))
+		if err != nil {
+			return err
+		}
+
+		numVFs, err := strconv.Atoi(strings.TrimSpace(string(content)))
+		if err != nil {
+			return err
+		}
+
+		for i := 0; i < numVFs; i++ {
+			vfPath := filepath.Join(devicePath, fmt.Sprintf("virtfn%d", i))
+
+			link, err := os.Readlink(vfPath)
+			if err != nil {
+				continue
+			}
+
+			pci := filepath.Base(link)
+			vf, ok := p.vfs[pci]
+			if !ok {
+				vf = &ibVF{parent: parents[0].Name(), index: i, pci: pci}
+				p.vfs[pci] = vf
+			}
+
+			// The interface is missing while it's in a container
+			ents, err := ioutil.ReadDir(filepath.Join(vfPath, "net"))
+			if err == nil && len(ents) > 0 {
+				vf.name = ents[0].Name()
+			}
+		}
+	}
+
+	return nil
+}
+
+// load builds the pool and records the functions already used by the
+// running containers.
+func (p *ibVFPool) load(s *state.State) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	err := p.refresh()
+	if err != nil {
+		return err
+	}
+
+	if len(p.vfs) == 0 {
+		return nil
+	}
+
+	containers, err := containerLoadNodeAll(s)
+	if err != nil {
+		return err
+	}
+
+	for _, c := range containers {
+		if !c.IsRunning() {
+			continue
+		}
+
+		for name, m := range c.ExpandedDevices() {
+			if m["type"] != "infiniband" || m["nictype"] != "sriov" {
+				continue
+			}
+
+			vf, ok := p.vfs[c.LocalConfig()[fmt.Sprintf("volatile.%s.vf", name)]]
+			if ok {
+				vf.owner = ibVFOwner(c, name)
+			}
+		}
+	}
+
+	return nil
+}
+
+func ibVFOwner(c container, device string) string {
+	return fmt.Sprintf("%s/%s", projectPrefix(c.Project(), c.Name()), device)
+}
+
+// allocate reserves a free virtual function of the given parent for a
+// container device, configuring its GUID if requested.
+func (p *ibVFPool) allocate(c container, device string, parent string, guid string, reserved []string) (*ibVF, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	err := p.refresh()
+	if err != nil {
+		return nil, err
+	}
+
+	owner := ibVFOwner(c, device)
+	free := []*ibVF{}
+	for _, vf := range p.vfs {
+		if vf.parent != parent {
+			continue
+		}
+
+		// Already allocated to this device (restart of a failed start)
+		if vf.owner == owner {
+			return vf, nil
+		}
+
+		if vf.owner != "" || vf.name == "" || shared.StringInSlice(vf.name, reserved) {
+			continue
+		}
+
+		// The interface must still be on the host
+		if !shared.PathExists(filepath.Join(SCNET, vf.name)) {
+			continue
+		}
+
+		free = append(free, vf)
+	}
+
+	if len(free) == 0 {
+		return nil, fmt.Errorf("All virtual functions of infiniband device \"%s\" are already in use", parent)
+	}
+
+	sort.Slice(free, func(i, j int) bool { return free[i].index < free[j].index })
+	vf := free[0]
+
+	if guid != "" {
+		for _, guidType := range []string{"node_guid", "port_guid"} {
+			_, err := shared.RunCommand("ip", "link", "set", "dev", parent, "vf", fmt.Sprintf("%d", vf.index), guidType, guid)
+			if err != nil {
+				return nil, fmt.Errorf("Failed to set the %s of virtual function %d of \"%s\": %v", guidType, vf.index, parent, err)
+			}
+		}
+	}
+
+	vf.owner = owner
+	return vf, nil
+}
+
+// release returns the virtual function allocated to a container device to
+// the pool.
+func (p *ibVFPool) release(c container, device string) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	owner := ibVFOwner(c, device)
+	for _, vf := range p.vfs {
+		if vf.owner == owner {
+			vf.owner = ""
+		}
+	}
+}
+
+// status returns the state of all the virtual functions in the pool.
+func (p *ibVFPool) status() ([]api.ResourcesInfinibandVF, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	err := p.refresh()
+	if err != nil {
+		return nil, err
+	}
+
+	vfs := []api.ResourcesInfinibandVF{}
+	for _, vf := range p.vfs {
+		vfs = append(vfs, api.ResourcesInfinibandVF{
+			Parent:     vf.parent,
+			Index:      vf.index,
+			PCIAddress: vf.pci,
+			Name:       vf.name,
+			Allocated:  vf.owner != "",
+			Owner:      vf.owner,
+		})
+	}
+
+	sort.Slice(vfs, func(i, j int) bool {
+		if vfs[i].Parent != vfs[j].Parent {
+			return vfs[i].Parent < vfs[j].Parent
+		}
+
+		return vfs[i].Index < vfs[j].Index
+	})
+
+	return vfs, nil
+}
+
+// infinibandSetupPkey creates the IPoIB child interface of the given
+// partition key on a virtual function and returns its name.
+func infinibandSetupPkey(name string, pkey string) (string, error) {
+	value, err := strconv.ParseUint(pkey, 0, 16)
+	if err != nil {
+		return "", fmt.Errorf("Invalid partition key: %s", pkey)
+	}
+
+	// Full membership partition keys have the high bit set
+	value |= 0x8000
+	child := fmt.Sprintf("%s.%04x", name, value)
+
+	if !shared.PathExists(filepath.Join(SCNET, child)) {
+		err = ioutil.WriteFile(filepath.Join(SCNET, name, "create_child"), []byte(fmt.Sprintf("0x%04x", value)), 0200)
+		if err != nil {
+			return "", errors.Wrapf(err, "Failed to create the partition key interface of %s", name)
+		}
+	}
+
+	return child, nil
+}
+
+// infinibandRemovePkey removes the IPoIB child interface created by
+// infinibandSetupPkey.
+func infinibandRemovePkey(name string, pkey string) {
+	value, err := strconv.ParseUint(pkey, 0, 16)
+	if err != nil {
+		return
+	}
+
+	value |= 0x8000
+	err = ioutil.WriteFile(filepath.Join(SCNET, name, "delete_child"), []byte(fmt.Sprintf("0x%04x", value)), 0200)
+	if err != nil && !os.IsNotExist(err) {
+		logger.Warn("Failed to remove infiniband partition key interface", log.Ctx{"device": name, "pkey": pkey, "err": err})
+	}
+}
+
+// /1.0/resources/infiniband
+// Get the infiniband virtual functions and their allocations
+func infinibandResourcesGet(d *Daemon, r *http.Request) Response {
+	// If a target was specified, forward the request to the relevant node.
+	response := ForwardedResponseIfTargetIsRemote(d, r)
+	if response != nil {
+		return response
+	}
+
+	vfs, err := infinibandPool.status()
+	if err != nil {
+		return SmartError(err)
+	}
+
+	res := api.ResourcesInfiniband{VFs: vfs}
+	for _, vf := range vfs {
+		res.Total++
+		if vf.Allocated {
+			res.Allocated++
+		}
+	}
+
+	return SyncResponse(true, res)
+}
@@ -0,0 +1,189 @@
+package keystore
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/asn1"
+	"fmt"
+	"io"
+	"math/big"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/pkg/errors"
+
+	"github.com/lxc/lxd/lxd/util"
+	"github.com/lxc/lxd/shared"
+)
+
+// Supported key stores
+const (
+	File   = "file"
+	PKCS11 = "pkcs11"
+	TPM2   = "tpm2"
+)
+
+// LoadCert reads the LXD server certificate from the given var dir, using the
+// given key store for its private key.
+//
+// With the pkcs11 and tpm2 stores, a private key still found on disk is
+// imported into the token (as sensitive and non-extractable), to be removed
+// from disk through RemoveKey once the use of the key store is committed.
+func LoadCert(dir string, store string, uri string) (*shared.CertInfo, error) {
+	if store == "" || store == File {
+		// Don't generate a new certificate if the key was moved to a
+		// key store
+		if shared.PathExists(filepath.Join(dir, "server.crt")) && !shared.PathExists(filepath.Join(dir, "server.key")) {
+			return nil, fmt.Errorf("The private key of the server certificate isn't on disk")
+		}
+
+		return util.LoadCert(dir)
+	}
+
+	// Cluster members share their private key
+	if shared.PathExists(filepath.Join(dir, "cluster.crt")) {
+		return nil, fmt.Errorf("The %s key store isn't supported on clustered nodes", store)
+	}
+
+	certFilename := filepath.Join(dir, "server.crt")
+	keyFilename := filepath.Join(dir, "server.key")
+
+	// Generate the server certificate if this is the first start
+	if !shared.PathExists(certFilename) {
+		err := shared.FindOrGenCert(certFilename, keyFilename, false)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	cert, err := shared.ReadCert(certFilename)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to load TLS certificate")
+	}
+
+	signer, err := pkcs11Open(store, uri, cert, keyFilename)
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to load TLS private key from the %s key store", store)
+	}
+
+	var ca *x509.Certificate
+	caFilename := filepath.Join(dir, "server.ca")
+	if shared.PathExists(caFilename) {
+		ca, err = shared.ReadCert(caFilename)
+		if err != nil {
+			signer.Close()
+			return nil, err
+		}
+	}
+
+	keypair := tls.Certificate{
+		Certificate: [][]byte{cert.Raw},
+		PrivateKey:  signer,
+		Leaf:        cert,
+	}
+
+	return shared.NewCertInfo(keypair, ca), nil
+}
+
+// RemoveKey removes the private key of the server certificate from the given
+// var dir once it's held by the given key store. It's left alone until then so
+// that switching back to the file store remains possible.
+func RemoveKey(dir string, store string) error {
+	if store == "" || store == File {
+		return nil
+	}
+
+	keyFilename := filepath.Join(dir, "server.key")
+	if !shared.PathExists(keyFilename) {
+		return nil
+	}
+
+	return os.Remove(keyFilename)
+}
+
+// LoadCertFiles reads the LXD server certificate and its private key from the
+// given files rather than from the var dir, such as the ones renewed by an
+// ACME client. The certificate must be currently valid.
+func LoadCertFiles(dir string, certFilename string, keyFilename string) (*shared.CertInfo, error) {
+	// Cluster members share their certificate
+	if shared.PathExists(filepath.Join(dir, "cluster.crt")) {
+		return nil, fmt.Errorf("Custom server certificates aren't supported on clustered nodes")
+	}
+
+	keypair, err := tls.LoadX509KeyPair(certFilename, keyFilename)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to load TLS certificate")
+	}
+
+	cert, err := x509.ParseCertificate(keypair.Certificate[0])
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to parse TLS certificate")
+	}
+
+	now := time.Now()
+	if now.Before(cert.NotBefore) {
+		return nil, fmt.Errorf("The certificate isn't valid before %s", cert.NotBefore)
+	}
+
+	if now.After(cert.NotAfter) {
+		return nil, fmt.Errorf("The certificate expired on %s", cert.NotAfter)
+	}
+
+	keypair.Leaf = cert
+
+	var ca *x509.Certificate
+	caFilename := filepath.Join(dir, "server.ca")
+	if shared.PathExists(caFilename) {
+		ca, err = shared.ReadCert(caFilename)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return shared.NewCertInfo(keypair, ca), nil
+}
+
+// Close releases the key store session held by the given certificate, if any.
+func Close(cert *shared.CertInfo) error {
+	closer, ok := cert.KeyPair().PrivateKey.(io.Closer)
+	if !ok {
+		return nil
+	}
+
+	return closer.Close()
+}
+
+// checkSigner makes sure that the key matches the public key of the
+// certificate, by verifying a test signature.
+func checkSigner(signer crypto.Signer, cert *x509.Certificate) error {
+	digest := sha256.Sum256([]byte("lxd key store check"))
+
+	signature, err := signer.Sign(rand.Reader, digest[:], crypto.SHA256)
+	if err != nil {
+		return err
+	}
+
+	switch public := cert.PublicKey.(type) {
+	case *ecdsa.PublicKey:
+		var sig struct{ R, S *big.Int }
+		_, err = asn1.Unmarshal(signature, &sig)
+		if err != nil || !ecdsa.Verify(public, digest[:], sig.R, sig.S) {
+			return fmt.Errorf("The private key doesn't match the certificate")
+		}
+	case *rsa.PublicKey:
+		err = rsa.VerifyPKCS1v15(public, crypto.SHA256, digest[:], signature)
+		if err != nil {
+			return fmt.Errorf("The private key doesn't match the certificate")
+		}
+	default:
+		return fmt.Errorf("Unsupported public key type %T", cert.PublicKey)
+	}
+
+	return nil
+}
@@ -0,0 +1,68 @@
+package keystore_test
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/lxc/lxd/lxd/keystore"
+	"github.com/lxc/lxd/shared"
+)
+
+// Load the server certificate from files outside of the var dir.
+func TestLoadCertFiles(t *testing.T) {
+	dir, err := ioutil.TempDir("", "lxd-keystore-")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	cert, key, err := shared.GenerateMemCert(false)
+	require.NoError(t, err)
+
+	certFilename := filepath.Join(dir, "fullchain.pem")
+	keyFilename := filepath.Join(dir, "privkey.pem")
+	require.NoError(t, ioutil.WriteFile(certFilename, cert, 0644))
+	require.NoError(t, ioutil.WriteFile(keyFilename, key, 0600))
+
+	info, err := keystore.LoadCertFiles(dir, certFilename, keyFilename)
+	require.NoError(t, err)
+	assert.Equal(t, cert, info.PublicKey())
+	assert.NotNil(t, info.KeyPair().Leaf)
+
+	// Key of another certificate
+	_, otherKey, err := shared.GenerateMemCert(false)
+	require.NoError(t, err)
+	require.NoError(t, ioutil.WriteFile(keyFilename, otherKey, 0600))
+
+	_, err = keystore.LoadCertFiles(dir, certFilename, keyFilename)
+	assert.Error(t, err)
+
+	// Clustered nodes share their certificate
+	require.NoError(t, ioutil.WriteFile(keyFilename, key, 0600))
+	require.NoError(t, ioutil.WriteFile(filepath.Join(dir, "cluster.crt"), cert, 0644))
+
+	_, err = keystore.LoadCertFiles(dir, certFilename, keyFilename)
+	assert.EqualError(t, err, "Custom server certificates aren't supported on clustered nodes")
+}
+
+// The private key is only removed from disk once held by another key store.
+func TestRemoveKey(t *testing.T) {
+	dir, err := ioutil.TempDir("", "lxd-keystore-")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	keyFilename := filepath.Join(dir, "server.key")
+	require.NoError(t, ioutil.WriteFile(keyFilename, []byte("key"), 0600))
+
+	require.NoError(t, keystore.RemoveKey(dir, keystore.File))
+	assert.True(t, shared.PathExists(keyFilename))
+
+	require.NoError(t, keystore.RemoveKey(dir, keystore.PKCS11))
+	assert.False(t, shared.PathExists(keyFilename))
+
+	// Already removed
+	require.NoError(t, keystore.RemoveKey(dir, keystore.PKCS11))
+}
@@ -0,0 +1,362 @@
+package keystore
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/asn1"
+	"encoding/pem"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"math/big"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/miekg/pkcs11"
+
+	"github.com/lxc/lxd/shared"
+)
+
+// Module used by the tpm2 key store when the URI doesn't name one
+const tpm2DefaultModule = "libtpm2_pkcs11.so.1"
+
+// Label of the private key in the token when the URI doesn't set one
+const pkcs11DefaultLabel = "lxd"
+
+// DER encoded OIDs of the curves supported for EC keys
+var pkcs11CurveOIDs = map[string]asn1.ObjectIdentifier{
+	"P-256": {1, 2, 840, 10045, 3, 1, 7},
+	"P-384": {1, 3, 132, 0, 34},
+	"P-521": {1, 3, 132, 0, 35},
+}
+
+// Hash mechanisms, MGF1 functions and PKCS#1 DigestInfo prefixes of the
+// digests signed by TLS
+var pkcs11Hashes = map[crypto.Hash]struct {
+	mechanism uint
+	mgf       uint
+	prefix    []byte
+}{
+	crypto.SHA1:   {pkcs11.CKM_SHA_1, pkcs11.CKG_MGF1_SHA1, []byte{0x30, 0x21, 0x30, 0x09, 0x06, 0x05, 0x2b, 0x0e, 0x03, 0x02, 0x1a, 0x05, 0x00, 0x04, 0x14}},
+	crypto.SHA256: {pkcs11.CKM_SHA256, pkcs11.CKG_MGF1_SHA256, []byte{0x30, 0x31, 0x30, 0x0d, 0x06, 0x09, 0x60, 0x86, 0x48, 0x01, 0x65, 0x03, 0x04, 0x02, 0x01, 0x05, 0x00, 0x04, 0x20}},
+	crypto.SHA384: {pkcs11.CKM_SHA384, pkcs11.CKG_MGF1_SHA384, []byte{0x30, 0x41, 0x30, 0x0d, 0x06, 0x09, 0x60, 0x86, 0x48, 0x01, 0x65, 0x03, 0x04, 0x02, 0x02, 0x05, 0x00, 0x04, 0x30}},
+	crypto.SHA512: {pkcs11.CKM_SHA512, pkcs11.CKG_MGF1_SHA512, []byte{0x30, 0x51, 0x30, 0x0d, 0x06, 0x09, 0x60, 0x86, 0x48, 0x01, 0x65, 0x03, 0x04, 0x02, 0x03, 0x05, 0x00, 0x04, 0x40}},
+}
+
+// pkcs11URI is a parsed core.key_store_uri, of the form
+// "<module>?slot=<id>&label=<label>&pin-source=<file>".
+type pkcs11URI struct {
+	module    string
+	slot      int64
+	label     string
+	pinSource string
+}
+
+// pkcs11ParseURI parses the URI of a pkcs11 or tpm2 key store.
+func pkcs11ParseURI(store string, uri string) (*pkcs11URI, error) {
+	u, err := url.Parse(uri)
+	if err != nil {
+		return nil, fmt.Errorf("Invalid key store URI '%s': %v", uri, err)
+	}
+
+	result := &pkcs11URI{
+		module: u.Path,
+		slot:   -1,
+		label:  pkcs11DefaultLabel,
+	}
+
+	if result.module == "" {
+		if store != TPM2 {
+			return nil, fmt.Errorf("The key store URI must start with the path of the PKCS#11 module")
+		}
+
+		result.module = tpm2DefaultModule
+	}
+
+	for key, values := range u.Query() {
+		value := values[len(values)-1]
+		switch key {
+		case "slot":
+			result.slot, err = strconv.ParseInt(value, 10, 64)
+			if err != nil || result.slot < 0 {
+				return nil, fmt.Errorf("Invalid key store slot '%s'", value)
+			}
+		case "label":
+			result.label = value
+		case "pin-source":
+			result.pinSource = value
+		default:
+			return nil, fmt.Errorf("Invalid key store URI attribute '%s'", key)
+		}
+	}
+
+	return result, nil
+}
+
+// pkcs11Signer is a crypto.Signer backed by a private key held in a PKCS#11
+// token.
+type pkcs11Signer struct {
+	mu      sync.Mutex
+	ctx     *pkcs11.Ctx
+	session pkcs11.SessionHandle
+	key     pkcs11.ObjectHandle
+	public  crypto.PublicKey
+}
+
+// pkcs11Open logs into the token and looks up the private key of the
+// certificate, importing it from the key file if the token doesn't hold it
+// yet.
+func pkcs11Open(store string, uri string, cert *x509.Certificate, keyFilename string) (*pkcs11Signer, error) {
+	config, err := pkcs11ParseURI(store, uri)
+	if err != nil {
+		return nil, err
+	}
+
+	ctx := pkcs11.New(config.module)
+	if ctx == nil {
+		return nil, fmt.Errorf("Failed to load PKCS#11 module '%s'", config.module)
+	}
+
+	err = ctx.Initialize()
+	if err != nil {
+		ctx.Destroy()
+		return nil, err
+	}
+
+	s := &pkcs11Signer{ctx: ctx, public: cert.PublicKey}
+	err = s.open(config, cert, keyFilename)
+	if err != nil {
+		s.Close()
+		return nil, err
+	}
+
+	return s, nil
+}
+
+func (s *pkcs11Signer) open(config *pkcs11URI, cert *x509.Certificate, keyFilename string) error {
+	slot := uint(config.slot)
+	if config.slot < 0 {
+		slots, err := s.ctx.GetSlotList(true)
+		if err != nil {
+			return err
+		}
+
+		if len(slots) == 0 {
+			return fmt.Errorf("No token found")
+		}
+
+		slot = slots[0]
+	}
+
+	var err error
+	s.session, err = s.ctx.OpenSession(slot, pkcs11.CKF_SERIAL_SESSION|pkcs11.CKF_RW_SESSION)
+	if err != nil {
+		return err
+	}
+
+	pin := ""
+	if config.pinSource != "" {
+		content, err := ioutil.ReadFile(config.pinSource)
+		if err != nil {
+			return err
+		}
+
+		pin = strings.TrimSpace(string(content))
+	}
+
+	err = s.ctx.Login(s.session, pkcs11.CKU_USER, pin)
+	if err != nil && err != pkcs11.Error(pkcs11.CKR_USER_ALREADY_LOGGED_IN) {
+		return err
+	}
+
+	err = s.ctx.FindObjectsInit(s.session, []*pkcs11.Attribute{
+		pkcs11.NewAttribute(pkcs11.CKA_CLASS, pkcs11.CKO_PRIVATE_KEY),
+		pkcs11.NewAttribute(pkcs11.CKA_LABEL, config.label),
+	})
+	if err != nil {
+		return err
+	}
+
+	objects, _, err := s.ctx.FindObjects(s.session, 1)
+	s.ctx.FindObjectsFinal(s.session)
+	if err != nil {
+		return err
+	}
+
+	if len(objects) > 0 {
+		s.key = objects[0]
+		return checkSigner(s, cert)
+	}
+
+	if !shared.PathExists(keyFilename) {
+		return fmt.Errorf("No private key labelled '%s' found in the token", config.label)
+	}
+
+	// Move the private key into the token
+	err = s.importKey(keyFilename, config.label)
+	if err != nil {
+		return err
+	}
+
+	return checkSigner(s, cert)
+}
+
+// importKey stores the PEM encoded private key as a sensitive non-extractable
+// object of the token.
+func (s *pkcs11Signer) importKey(keyFilename string, label string) error {
+	content, err := ioutil.ReadFile(keyFilename)
+	if err != nil {
+		return err
+	}
+
+	block, _ := pem.Decode(content)
+	if block == nil {
+		return fmt.Errorf("Invalid private key file '%s'", keyFilename)
+	}
+
+	var key interface{}
+	switch block.Type {
+	case "EC PRIVATE KEY":
+		key, err = x509.ParseECPrivateKey(block.Bytes)
+	case "RSA PRIVATE KEY":
+		key, err = x509.ParsePKCS1PrivateKey(block.Bytes)
+	default:
+		key, err = x509.ParsePKCS8PrivateKey(block.Bytes)
+	}
+	if err != nil {
+		return err
+	}
+
+	template := []*pkcs11.Attribute{
+		pkcs11.NewAttribute(pkcs11.CKA_CLASS, pkcs11.CKO_PRIVATE_KEY),
+		pkcs11.NewAttribute(pkcs11.CKA_TOKEN, true),
+		pkcs11.NewAttribute(pkcs11.CKA_PRIVATE, true),
+		pkcs11.NewAttribute(pkcs11.CKA_SENSITIVE, true),
+		pkcs11.NewAttribute(pkcs11.CKA_EXTRACTABLE, false),
+		pkcs11.NewAttribute(pkcs11.CKA_SIGN, true),
+		pkcs11.NewAttribute(pkcs11.CKA_LABEL, label),
+	}
+
+	switch key := key.(type) {
+	case *ecdsa.PrivateKey:
+		oid, ok := pkcs11CurveOIDs[key.Curve.Params().Name]
+		if !ok {
+			return fmt.Errorf("Unsupported curve %s", key.Curve.Params().Name)
+		}
+
+		params, err := asn1.Marshal(oid)
+		if err != nil {
+			return err
+		}
+
+		template = append(template,
+			pkcs11.NewAttribute(pkcs11.CKA_KEY_TYPE, pkcs11.CKK_EC),
+			pkcs11.NewAttribute(pkcs11.CKA_EC_PARAMS, params),
+			pkcs11.NewAttribute(pkcs11.CKA_VALUE, key.D.Bytes()))
+	case *rsa.PrivateKey:
+		if len(key.Primes) != 2 {
+			return fmt.Errorf("Unsupported multi-prime RSA key")
+		}
+
+		key.Precompute()
+		template = append(template,
+			pkcs11.NewAttribute(pkcs11.CKA_KEY_TYPE, pkcs11.CKK_RSA),
+			pkcs11.NewAttribute(pkcs11.CKA_MODULUS, key.N.Bytes()),
+			pkcs11.NewAttribute(pkcs11.CKA_PUBLIC_EXPONENT, big.NewInt(int64(key.E)).Bytes()),
+			pkcs11.NewAttribute(pkcs11.CKA_PRIVATE_EXPONENT, key.D.Bytes()),
+			pkcs11.NewAttribute(pkcs11.CKA_PRIME_1, key.Primes[0].Bytes()),
+			pkcs11.NewAttribute(pkcs11.CKA_PRIME_2, key.Primes[1].Bytes()),
+			pkcs11.NewAttribute(pkcs11.CKA_EXPONENT_1, key.Precomputed.Dp.Bytes()),
+			pkcs11.NewAttribute(pkcs11.CKA_EXPONENT_2, key.Precomputed.Dq.Bytes()),
+			pkcs11.NewAttribute(pkcs11.CKA_COEFFICIENT, key.Precomputed.Qinv.Bytes()))
+	default:
+		return fmt.Errorf("Unsupported private key type %T", key)
+	}
+
+	s.key, err = s.ctx.CreateObject(s.session, template)
+	return err
+}
+
+// Public returns the public key of the certificate.
+func (s *pkcs11Signer) Public() crypto.PublicKey {
+	return s.public
+}
+
+// Sign signs the digest with the private key held by the token.
+func (s *pkcs11Signer) Sign(rand io.Reader, digest []byte, opts crypto.SignerOpts) ([]byte, error) {
+	var mechanism *pkcs11.Mechanism
+	data := digest
+
+	switch s.public.(type) {
+	case *ecdsa.PublicKey:
+		mechanism = pkcs11.NewMechanism(pkcs11.CKM_ECDSA, nil)
+	case *rsa.PublicKey:
+		hash, ok := pkcs11Hashes[opts.HashFunc()]
+		if !ok {
+			return nil, fmt.Errorf("Unsupported hash function %v", opts.HashFunc())
+		}
+
+		pss, ok := opts.(*rsa.PSSOptions)
+		if ok {
+			saltLength := pss.SaltLength
+			if saltLength == rsa.PSSSaltLengthAuto || saltLength == rsa.PSSSaltLengthEqualsHash {
+				saltLength = opts.HashFunc().Size()
+			}
+
+			params := pkcs11.NewPSSParams(hash.mechanism, hash.mgf, uint(saltLength))
+			mechanism = pkcs11.NewMechanism(pkcs11.CKM_RSA_PKCS_PSS, params)
+		} else {
+			mechanism = pkcs11.NewMechanism(pkcs11.CKM_RSA_PKCS, nil)
+			data = append(append([]byte{}, hash.prefix...), digest...)
+		}
+	default:
+		return nil, fmt.Errorf("Unsupported public key type %T", s.public)
+	}
+
+	// Sessions can't be used concurrently
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	err := s.ctx.SignInit(s.session, []*pkcs11.Mechanism{mechanism}, s.key)
+	if err != nil {
+		return nil, err
+	}
+
+	signature, err := s.ctx.Sign(s.session, data)
+	if err != nil {
+		return nil, err
+	}
+
+	_, ok := s.public.(*ecdsa.PublicKey)
+	if !ok {
+		return signature, nil
+	}
+
+	// The token returns the raw r and s values, TLS wants them DER encoded
+	half := len(signature) / 2
+	return asn1.Marshal(struct{ R, S *big.Int }{
+		new(big.Int).SetBytes(signature[:half]),
+		new(big.Int).SetBytes(signature[half:]),
+	})
+}
+
+// Close logs out of the token and unloads the module.
+func (s *pkcs11Signer) Close() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.session != 0 {
+		s.ctx.Logout(s.session)
+		s.ctx.CloseSession(s.session)
+		s.session = 0
+	}
+
+	s.ctx.Finalize()
+	s.ctx.Destroy()
+
+	return nil
+}
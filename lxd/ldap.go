@@ -0,0 +1,247 @@
+package main
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/hex"
+	"fmt"
+	"net"
+	"net/url"
+	"sync"
+	"time"
+
+	"gopkg.in/ldap.v3"
+
+	"github.com/lxc/lxd/lxd/cluster"
+	"github.com/lxc/lxd/shared/logger"
+
+	log "github.com/lxc/lxd/shared/log15"
+)
+
+// How long the result of a successful lookup is reused for
+const ldapCacheExpiry = time.Minute
+
+// How long a failure to query the server is reused for, so that an
+// unreachable server doesn't hold up every request
+const ldapFailureExpiry = 30 * time.Second
+
+// Timeout of the connection to the server and of each query
+const ldapTimeout = 5 * time.Second
+
+// ldapAuth holds the settings of the LDAP server used to authenticate
+// clients.
+type ldapAuth struct {
+	uri          string
+	bindDN       string
+	bindPassword string
+	baseDN       string
+	userFilter   string
+	groupFilter  string
+
+	cacheLock     sync.Mutex
+	cache         map[string]ldapCacheEntry
+	failure       error
+	failureExpiry time.Time
+}
+
+type ldapCacheEntry struct {
+	trusted  bool
+	username string
+	expiry   time.Time
+}
+
+// setupLDAPAuthentication configures LDAP authentication from the cluster
+// configuration, disabling it if no server is set.
+func (d *Daemon) setupLDAPAuthentication(config *cluster.Config) {
+	d.ldapAuthLock.Lock()
+	defer d.ldapAuthLock.Unlock()
+
+	uri := config.LDAPURI()
+	if uri == "" {
+		d.ldapAuth = nil
+		return
+	}
+
+	bindDN, bindPassword := config.LDAPBind()
+	d.ldapAuth = &ldapAuth{
+		uri:          uri,
+		bindDN:       bindDN,
+		bindPassword: bindPassword,
+		baseDN:       config.LDAPBaseDN(),
+		userFilter:   config.LDAPUserFilter(),
+		groupFilter:  config.LDAPGroupFilter(),
+		cache:        map[string]ldapCacheEntry{},
+	}
+}
+
+// ldapAuthGet returns the LDAP server used to authenticate clients, nil if
+// LDAP authentication is disabled.
+func (d *Daemon) ldapAuthGet() *ldapAuth {
+	d.ldapAuthLock.Lock()
+	defer d.ldapAuthLock.Unlock()
+
+	return d.ldapAuth
+}
+
+// Authenticate looks up the common name of the client certificate as an LDAP
+// user. The user entry must hold the certificate in its userCertificate
+// attribute and, if a group filter is set, the user must be a member of a
+// matching group. An error is returned if the server can't be queried.
+func (a *ldapAuth) Authenticate(cert *x509.Certificate) (bool, string, error) {
+	username := cert.Subject.CommonName
+	if username == "" {
+		return false, "", nil
+	}
+
+	digest := sha256.Sum256(cert.Raw)
+	fingerprint := hex.EncodeToString(digest[:])
+
+	a.cacheLock.Lock()
+	entry, ok := a.cache[fingerprint]
+	failure := a.failure
+	failureExpiry := a.failureExpiry
+	a.cacheLock.Unlock()
+	if ok && time.Now().Before(entry.expiry) {
+		return entry.trusted, entry.username, nil
+	}
+
+	if failure != nil && time.Now().Before(failureExpiry) {
+		return false, "", failure
+	}
+
+	trusted, err := a.lookup(username, cert)
+	if err != nil {
+		a.cacheLock.Lock()
+		a.failure = err
+		a.failureExpiry = time.Now().Add(ldapFailureExpiry)
+		a.cacheLock.Unlock()
+
+		return false, "", err
+	}
+
+	a.cacheLock.Lock()
+	a.cache[fingerprint] = ldapCacheEntry{trusted: trusted, username: username, expiry: time.Now().Add(ldapCacheExpiry)}
+	a.cacheLock.Unlock()
+
+	if !trusted {
+		return false, "", nil
+	}
+
+	return true, username, nil
+}
+
+// dial connects to the server, upgrading plain ldap:// connections with
+// StartTLS when a bind password is to be sent.
+func (a *ldapAuth) dial() (*ldap.Conn, error) {
+	u, err := url.Parse(a.uri)
+	if err != nil {
+		return nil, err
+	}
+
+	host := u.Hostname()
+	port := u.Port()
+
+	var netConn net.Conn
+	dialer := &net.Dialer{Timeout: ldapTimeout}
+	switch u.Scheme {
+	case "ldap":
+		if port == "" {
+			port = "389"
+		}
+
+		netConn, err = dialer.Dial("tcp", net.JoinHostPort(host, port))
+	case "ldaps":
+		if port == "" {
+			port = "636"
+		}
+
+		netConn, err = tls.DialWithDialer(dialer, "tcp", net.JoinHostPort(host, port), &tls.Config{ServerName: host})
+	default:
+		return nil, fmt.Errorf("Unsupported LDAP URI scheme: %s", u.Scheme)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	conn := ldap.NewConn(netConn, u.Scheme == "ldaps")
+	conn.Start()
+	conn.SetTimeout(ldapTimeout)
+
+	if u.Scheme == "ldap" && a.bindPassword != "" {
+		err := conn.StartTLS(&tls.Config{ServerName: host})
+		if err != nil {
+			conn.Close()
+			return nil, fmt.Errorf("Refusing to send the bind password over plain LDAP, StartTLS failed: %v", err)
+		}
+	}
+
+	return conn, nil
+}
+
+func (a *ldapAuth) lookup(username string, cert *x509.Certificate) (bool, error) {
+	conn, err := a.dial()
+	if err != nil {
+		return false, err
+	}
+	defer conn.Close()
+
+	if a.bindDN != "" {
+		err := conn.Bind(a.bindDN, a.bindPassword)
+		if err != nil {
+			return false, err
+		}
+	}
+
+	// Find the user
+	req := ldap.NewSearchRequest(a.baseDN, ldap.ScopeWholeSubtree, ldap.NeverDerefAliases, 2, 10, false,
+		fmt.Sprintf(a.userFilter, ldap.EscapeFilter(username)), []string{"userCertificate;binary", "userCertificate"}, nil)
+
+	result, err := conn.Search(req)
+	if err != nil {
+		return false, err
+	}
+
+	if len(result.Entries) != 1 {
+		logger.Debug("No single LDAP user matching the client certificate", log.Ctx{"user": username, "entries": len(result.Entries)})
+		return false, nil
+	}
+
+	user := result.Entries[0]
+
+	// Check that the certificate is the one registered for the user
+	found := false
+	certs := append(user.GetRawAttributeValues("userCertificate;binary"), user.GetRawAttributeValues("userCertificate")...)
+	for _, raw := range certs {
+		if bytes.Equal(raw, cert.Raw) {
+			found = true
+			break
+		}
+	}
+
+	if !found {
+		logger.Warn("Client certificate doesn't match the LDAP user", log.Ctx{"user": username, "dn": user.DN})
+		return false, nil
+	}
+
+	if a.groupFilter == "" {
+		return true, nil
+	}
+
+	// Check the group membership
+	req = ldap.NewSearchRequest(a.baseDN, ldap.ScopeWholeSubtree, ldap.NeverDerefAliases, 1, 10, false,
+		fmt.Sprintf(a.groupFilter, ldap.EscapeFilter(user.DN)), []string{"dn"}, nil)
+
+	result, err = conn.Search(req)
+	if err != nil && !ldap.IsErrorWithCode(err, ldap.LDAPResultSizeLimitExceeded) {
+		return false, err
+	}
+
+	if result == nil || len(result.Entries) == 0 {
+		logger.Debug("LDAP user isn't in a trusted group", log.Ctx{"user": username, "dn": user.DN})
+		return false, nil
+	}
+
+	return true, nil
+}
@@ -92,6 +92,10 @@ func main() {
 	forkconsoleCmd := cmdForkconsole{global: &globalCmd}
 	app.AddCommand(forkconsoleCmd.Command())
 
+	// forkcoredump sub-command
+	forkcoredumpCmd := cmdForkcoredump{global: &globalCmd}
+	app.AddCommand(forkcoredumpCmd.Command())
+
 	// forkdns sub-command
 	forkDNSCmd := cmdForkDNS{global: &globalCmd}
 	app.AddCommand(forkDNSCmd.Command())
@@ -124,10 +128,18 @@ func main() {
 	forkstartCmd := cmdForkstart{global: &globalCmd}
 	app.AddCommand(forkstartCmd.Command())
 
+	// forksyscall sub-command
+	forksyscallCmd := cmdForksyscall{global: &globalCmd}
+	app.AddCommand(forksyscallCmd.Command())
+
 	// forkuevent sub-command
 	forkueventCmd := cmdForkuevent{global: &globalCmd}
 	app.AddCommand(forkueventCmd.Command())
 
+	// forkunpack sub-command
+	forkunpackCmd := cmdForkunpack{global: &globalCmd}
+	app.AddCommand(forkunpackCmd.Command())
+
 	// forkzfs sub-command
 	forkzfsCmd := cmdForkZFS{global: &globalCmd}
 	app.AddCommand(forkzfsCmd.Command())
@@ -0,0 +1,84 @@
+package main
+
+import (
+	"fmt"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/spf13/cobra"
+
+	"github.com/lxc/lxd/client"
+)
+
+type cmdForkcoredump struct {
+	global *cmdGlobal
+}
+
+func (c *cmdForkcoredump) Command() *cobra.Command {
+	cmd := &cobra.Command{}
+	cmd.Use = "forkcoredump <path> <pid> <signal> <timestamp> <command>"
+	cmd.Short = "Send a core dump to LXD"
+	cmd.Long = `Description:
+  Send a core dump to LXD
+
+  This internal command is run by the kernel through the core pattern set
+  when core.coredump_capture is enabled, the core dump being read from
+  standard input and sent to LXD which stores it if the process belongs to
+  a container. Other core dumps are handed to the core pattern set before
+  the capture was enabled.
+
+`
+	cmd.RunE = c.Run
+	cmd.Hidden = true
+
+	return cmd
+}
+
+func (c *cmdForkcoredump) Run(cmd *cobra.Command, args []string) error {
+	// Sanity checks
+	if len(args) < 5 {
+		cmd.Help()
+
+		if len(args) == 0 {
+			return nil
+		}
+
+		return fmt.Errorf("Missing required arguments")
+	}
+
+	// The kernel splits the pattern on spaces after expanding it, so a
+	// command name containing spaces spans several arguments
+	command := strings.Join(args[4:], " ")
+
+	// Connect to LXD
+	socket := os.Getenv("LXD_SOCKET")
+	if socket == "" {
+		socket = filepath.Join(args[0], "unix.socket")
+	}
+	d, err := lxd.ConnectLXDUnix(socket, nil)
+	if err == nil {
+		_, _, err = d.RawQuery("GET", fmt.Sprintf("/internal/coredumps?pid=%s", url.QueryEscape(args[1])), nil, "")
+	}
+
+	// Dumps of processes outside of containers, or while LXD can't be
+	// reached, go where they would have without the capture
+	if err != nil {
+		values, err := coredumpProcessValues(args[1], args[2], args[3], command)
+		if err != nil {
+			return err
+		}
+
+		return coredumpPassOn(args[0], values, os.Stdin)
+	}
+
+	values := url.Values{}
+	values.Set("pid", args[1])
+	values.Set("signal", args[2])
+	values.Set("timestamp", args[3])
+	values.Set("command", command)
+
+	_, _, err = d.RawQuery("POST", fmt.Sprintf("/internal/coredumps?%s", values.Encode()), os.Stdin, "")
+	return err
+}
@@ -11,6 +11,10 @@ import (
 	"gopkg.in/lxc/go-lxc.v2"
 )
 
+// Environment variable passing the environment of the task to forkexec, as
+// JSON, so that it doesn't show in the command line of the process
+const forkexecEnv = "LXD_EXEC_ENV"
+
 type cmdForkexec struct {
 	global *cmdGlobal
 }
@@ -25,6 +29,9 @@ func (c *cmdForkexec) Command() *cobra.Command {
 
   This internal command is used to spawn a task inside the container and
   allow LXD to interact with it.
+
+  The environment of the task may also be passed as a JSON object in the
+  LXD_EXEC_ENV environment variable.
 `
 	cmd.RunE = c.Run
 	cmd.Hidden = true
@@ -68,6 +75,17 @@ func (c *cmdForkexec) Run(cmd *cobra.Command, args []string) error {
 		return fmt.Errorf("Error opening startup config file: %q", err)
 	}
 
+	// Inherited by all the processes of the container
+	err = thpDisableIfRequested()
+	if err != nil {
+		return err
+	}
+
+	err = ulimitsSetIfRequested()
+	if err != nil {
+		return err
+	}
+
 	// Setup attach arguments
 	opts := lxc.DefaultAttachOptions
 	opts.ClearEnv = true
@@ -105,6 +123,25 @@ func (c *cmdForkexec) Run(cmd *cobra.Command, args []string) error {
 		}
 	}
 
+	// Environment kept out of the command line
+	value := os.Getenv(forkexecEnv)
+	if value != "" {
+		os.Unsetenv(forkexecEnv)
+
+		extraEnv := map[string]string{}
+		err := json.Unmarshal([]byte(value), &extraEnv)
+		if err != nil {
+			return err
+		}
+
+		for k, v := range extraEnv {
+			if k == "HOME" {
+				opts.Cwd = v
+			}
+			env = append(env, fmt.Sprintf("%s=%s", k, v))
+		}
+	}
+
 	opts.Env = env
 
 	// Exec the command
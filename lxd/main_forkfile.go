@@ -16,6 +16,7 @@ import (
 #include <stdlib.h>
 #include <string.h>
 #include <sys/stat.h>
+#include <sys/xattr.h>
 #include <unistd.h>
 #include <limits.h>
 
@@ -401,6 +402,92 @@ void forkremovefile(char *rootfs, pid_t pid) {
 	_exit(0);
 }
 
+void forkxattr(bool set, char *rootfs, pid_t pid) {
+	__do_free char *buf = NULL;
+	char *path = NULL;
+	char *name = NULL;
+	char *value = NULL;
+	ssize_t len;
+	ssize_t i;
+
+	path = advance_arg(true);
+	name = advance_arg(true);
+	if (set)
+		value = advance_arg(true);
+
+	if (pid > 0) {
+		attach_userns(pid);
+
+		if (dosetns(pid, "mnt") < 0) {
+			error("error: setns");
+			_exit(1);
+		}
+	} else {
+		if (chroot(rootfs) < 0) {
+			error("error: chroot");
+			_exit(1);
+		}
+
+		if (chdir("/") < 0) {
+			error("error: chdir");
+			_exit(1);
+		}
+	}
+
+	if (set) {
+		// The value is hex encoded
+		len = strlen(value) / 2;
+		buf = malloc(len);
+		if (!buf) {
+			error("error: malloc");
+			_exit(1);
+		}
+
+		for (i = 0; i < len; i++) {
+			if (sscanf(value + 2 * i, "%2hhx", (unsigned char *)&buf[i]) != 1) {
+				fprintf(stderr, "Invalid xattr value\n");
+				_exit(1);
+			}
+		}
+
+		if (lsetxattr(path, name, buf, len, 0) < 0) {
+			error("error: setxattr");
+			_exit(1);
+		}
+
+		_exit(0);
+	}
+
+	len = lgetxattr(path, name, NULL, 0);
+	if (len < 0) {
+		// The attribute isn't set
+		if (errno == ENODATA)
+			_exit(0);
+
+		error("error: getxattr");
+		_exit(1);
+	}
+
+	buf = malloc(len + 1);
+	if (!buf) {
+		error("error: malloc");
+		_exit(1);
+	}
+
+	len = lgetxattr(path, name, buf, len);
+	if (len < 0) {
+		error("error: getxattr");
+		_exit(1);
+	}
+
+	printf("value: ");
+	for (i = 0; i < len; i++)
+		printf("%02x", (unsigned char)buf[i]);
+	printf("\n");
+
+	_exit(0);
+}
+
 void forkfile() {
 	char *command = NULL;
 	char *rootfs = NULL;
@@ -436,6 +523,10 @@ void forkfile() {
 		forkcheckfile(rootfs, pid);
 	} else if (strcmp(command, "remove") == 0) {
 		forkremovefile(rootfs, pid);
+	} else if (strcmp(command, "getxattr") == 0) {
+		forkxattr(false, rootfs, pid);
+	} else if (strcmp(command, "setxattr") == 0) {
+		forkxattr(true, rootfs, pid);
 	}
 }
 */
@@ -488,6 +579,20 @@ func (c *cmdForkfile) Command() *cobra.Command {
 	cmdRemove.RunE = c.Run
 	cmd.AddCommand(cmdRemove)
 
+	// getxattr
+	cmdGetxattr := &cobra.Command{}
+	cmdGetxattr.Use = "getxattr <rootfs> <PID> <path> <name>"
+	cmdGetxattr.Args = cobra.ExactArgs(4)
+	cmdGetxattr.RunE = c.Run
+	cmd.AddCommand(cmdGetxattr)
+
+	// setxattr
+	cmdSetxattr := &cobra.Command{}
+	cmdSetxattr.Use = "setxattr <rootfs> <PID> <path> <name> <value>"
+	cmdSetxattr.Args = cobra.ExactArgs(5)
+	cmdSetxattr.RunE = c.Run
+	cmd.AddCommand(cmdSetxattr)
+
 	return cmd
 }
 
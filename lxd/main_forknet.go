@@ -1,8 +1,15 @@
 package main
 
 import (
+	"encoding/hex"
 	"encoding/json"
 	"fmt"
+	"net"
+	"net/http"
+	"os"
+	"strconv"
+	"syscall"
+	"unsafe"
 
 	"github.com/spf13/cobra"
 
@@ -59,6 +66,16 @@ void forknet() {
 	// Call the subcommands
 	if (strcmp(command, "info") == 0) {
 		forkdonetinfo(pid);
+	} else if (strcmp(command, "metadata") == 0) {
+		forkdonetinfo(pid);
+	} else if (strcmp(command, "mdns") == 0) {
+		forkdonetinfo(pid);
+	} else if (strcmp(command, "tun-add") == 0) {
+		forkdonetinfo(pid);
+	} else if (strcmp(command, "tun-remove") == 0) {
+		forkdonetinfo(pid);
+	} else if (strcmp(command, "queues") == 0) {
+		forkdonetinfo(pid);
 	}
 }
 */
@@ -89,6 +106,41 @@ func (c *cmdForknet) Command() *cobra.Command {
 	cmdInfo.RunE = c.RunInfo
 	cmd.AddCommand(cmdInfo)
 
+	// metadata
+	cmdMetadata := &cobra.Command{}
+	cmdMetadata.Use = "metadata <PID> <path>"
+	cmdMetadata.Args = cobra.ExactArgs(2)
+	cmdMetadata.RunE = c.RunMetadata
+	cmd.AddCommand(cmdMetadata)
+
+	// mdns
+	cmdMdns := &cobra.Command{}
+	cmdMdns.Use = "mdns <PID> <interface> <packet>"
+	cmdMdns.Args = cobra.ExactArgs(3)
+	cmdMdns.RunE = c.RunMdns
+	cmd.AddCommand(cmdMdns)
+
+	// tun-add
+	cmdTunAdd := &cobra.Command{}
+	cmdTunAdd.Use = "tun-add <PID> <name> <mode> <uid> <gid> <socket>"
+	cmdTunAdd.Args = cobra.ExactArgs(6)
+	cmdTunAdd.RunE = c.RunTunAdd
+	cmd.AddCommand(cmdTunAdd)
+
+	// tun-remove
+	cmdTunRemove := &cobra.Command{}
+	cmdTunRemove.Use = "tun-remove <PID> <name> <mode>"
+	cmdTunRemove.Args = cobra.ExactArgs(3)
+	cmdTunRemove.RunE = c.RunTunRemove
+	cmd.AddCommand(cmdTunRemove)
+
+	// queues
+	cmdQueues := &cobra.Command{}
+	cmdQueues.Use = "queues <PID> <interface> <queues>"
+	cmdQueues.Args = cobra.ExactArgs(3)
+	cmdQueues.RunE = c.RunQueues
+	cmd.AddCommand(cmdQueues)
+
 	return cmd
 }
 
@@ -107,3 +159,174 @@ func (c *cmdForknet) RunInfo(cmd *cobra.Command, args []string) error {
 
 	return nil
 }
+
+func (c *cmdForknet) RunMetadata(cmd *cobra.Command, args []string) error {
+	// Setup the link-local address on a dummy interface
+	_, err := shared.RunCommand("ip", "link", "add", "dev", cloudInitMetadataDevice, "type", "dummy")
+	if err != nil {
+		return err
+	}
+
+	_, err = shared.RunCommand("ip", "link", "set", "dev", cloudInitMetadataDevice, "up")
+	if err != nil {
+		return err
+	}
+
+	_, err = shared.RunCommand("ip", "-4", "addr", "add", fmt.Sprintf("%s/32", cloudInitMetadataAddress), "dev", cloudInitMetadataDevice)
+	if err != nil {
+		return err
+	}
+
+	// Serve the NoCloud seed files
+	return http.ListenAndServe(fmt.Sprintf("%s:80", cloudInitMetadataAddress), http.FileServer(http.Dir(args[1])))
+}
+
+func (c *cmdForknet) RunMdns(cmd *cobra.Command, args []string) error {
+	packet, err := hex.DecodeString(args[2])
+	if err != nil {
+		return err
+	}
+
+	return mdnsSend(args[1], packet)
+}
+
+// TUN/TAP ioctls and flags from linux/if_tun.h
+const (
+	tunSetIff     = 0x400454ca
+	tunSetPersist = 0x400454cb
+	tunSetOwner   = 0x400454cc
+	tunSetGroup   = 0x400454ce
+
+	tunFlagTun  = 0x0001
+	tunFlagTap  = 0x0002
+	tunFlagNoPi = 0x1000
+)
+
+func tunIoctl(fd uintptr, request uintptr, arg uintptr) error {
+	_, _, errno := syscall.Syscall(syscall.SYS_IOCTL, fd, request, arg)
+	if errno != 0 {
+		return errno
+	}
+
+	return nil
+}
+
+// tunOpen creates (or attaches to) the TUN/TAP interface with the given name
+// in the current network namespace.
+func tunOpen(name string, mode string) (*os.File, error) {
+	if len(name) >= syscall.IFNAMSIZ {
+		return nil, fmt.Errorf("Interface name too long: %s", name)
+	}
+
+	f, err := os.OpenFile("/dev/net/tun", os.O_RDWR, 0)
+	if err != nil {
+		return nil, err
+	}
+
+	var req struct {
+		name  [syscall.IFNAMSIZ]byte
+		flags uint16
+		_     [22]byte
+	}
+
+	copy(req.name[:], name)
+	req.flags = tunFlagTun | tunFlagNoPi
+	if mode == "tap" {
+		req.flags = tunFlagTap | tunFlagNoPi
+	}
+
+	err = tunIoctl(f.Fd(), tunSetIff, uintptr(unsafe.Pointer(&req)))
+	if err != nil {
+		f.Close()
+		return nil, fmt.Errorf("Failed to create %s interface %s: %v", mode, name, err)
+	}
+
+	return f, nil
+}
+
+func (c *cmdForknet) RunTunAdd(cmd *cobra.Command, args []string) error {
+	name := args[1]
+	mode := args[2]
+
+	f, err := tunOpen(name, mode)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	// Keep the interface around once we exit
+	err = tunIoctl(f.Fd(), tunSetPersist, 1)
+	if err != nil {
+		return fmt.Errorf("Failed to make interface %s persistent: %v", name, err)
+	}
+
+	if args[3] != "" {
+		uid, err := strconv.ParseUint(args[3], 10, 32)
+		if err != nil {
+			return err
+		}
+
+		err = tunIoctl(f.Fd(), tunSetOwner, uintptr(uid))
+		if err != nil {
+			return fmt.Errorf("Failed to set the owner of interface %s: %v", name, err)
+		}
+	}
+
+	if args[4] != "" {
+		gid, err := strconv.ParseUint(args[4], 10, 32)
+		if err != nil {
+			return err
+		}
+
+		err = tunIoctl(f.Fd(), tunSetGroup, uintptr(gid))
+		if err != nil {
+			return fmt.Errorf("Failed to set the group of interface %s: %v", name, err)
+		}
+	}
+
+	if args[5] == "" {
+		return nil
+	}
+
+	// Hand the file descriptor over to the daemon listening on the socket
+	conn, err := net.DialUnix("unix", nil, &net.UnixAddr{Name: args[5], Net: "unix"})
+	if err != nil {
+		return fmt.Errorf("Failed to connect to %s: %v", args[5], err)
+	}
+	defer conn.Close()
+
+	_, _, err = conn.WriteMsgUnix([]byte(name), syscall.UnixRights(int(f.Fd())), nil)
+	if err != nil {
+		return fmt.Errorf("Failed to send the file descriptor of interface %s: %v", name, err)
+	}
+
+	return nil
+}
+
+func (c *cmdForknet) RunTunRemove(cmd *cobra.Command, args []string) error {
+	name := args[1]
+	mode := args[2]
+
+	f, err := tunOpen(name, mode)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	// The interface goes away along with the last file descriptor
+	err = tunIoctl(f.Fd(), tunSetPersist, 0)
+	if err != nil {
+		return fmt.Errorf("Failed to remove interface %s: %v", name, err)
+	}
+
+	return nil
+}
+
+func (c *cmdForknet) RunQueues(cmd *cobra.Command, args []string) error {
+	queues, err := strconv.Atoi(args[2])
+	if err != nil {
+		return err
+	}
+
+	return networkSetQueues(args[1], queues)
+}
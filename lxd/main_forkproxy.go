@@ -22,6 +22,7 @@ import (
 #define _GNU_SOURCE
 #include <errno.h>
 #include <fcntl.h>
+#include <limits.h>
 #include <stdbool.h>
 #include <stdint.h>
 #include <stdio.h>
@@ -43,6 +44,7 @@ int whoami = -ESRCH;
 #define FORKPROXY_CHILD 1
 #define FORKPROXY_PARENT 0
 #define FORKPROXY_UDS_SOCK_FD_NUM 200
+#define FORKPROXY_CONNECTIONS_FD_NUM 201
 
 static int switch_uid_gid(uint32_t uid, uint32_t gid)
 {
@@ -90,10 +92,11 @@ again:
 void forkproxy()
 {
 	unsigned int needs_mntns = 0;
-	int connect_pid, listen_pid, log_fd;
+	int connect_pid, listen_pid, log_fd, connections_fd;
 	ssize_t ret;
 	pid_t pid;
 	char *connect_addr, *cur, *listen_addr, *log_path, *pid_path;
+	char connections_path[PATH_MAX];
 	int sk_fds[2] = {-EBADF, -EBADF};
 	FILE *pid_file;
 
@@ -132,6 +135,32 @@ void forkproxy()
 		_exit(EXIT_FAILURE);
 	}
 
+	// The number of active connections is written next to the pid file.
+	// It's opened here as the proxy may not see the host filesystem once
+	// attached to the container's mount namespace.
+	ret = snprintf(connections_path, sizeof(connections_path), "%s.connections", pid_path);
+	if (ret < 0 || ret >= sizeof(connections_path)) {
+		fprintf(stderr, "Failed to create connections file path for proxy daemon\n");
+		_exit(EXIT_FAILURE);
+	}
+
+	connections_fd = open(connections_path, O_RDWR | O_CREAT | O_TRUNC | O_CLOEXEC, 0600);
+	if (connections_fd < 0) {
+		fprintf(stderr,
+			"%s - Failed to create connections file for proxy daemon\n",
+			strerror(errno));
+		_exit(EXIT_FAILURE);
+	}
+
+	ret = dup3(connections_fd, FORKPROXY_CONNECTIONS_FD_NUM, O_CLOEXEC);
+	if (ret < 0) {
+		fprintf(stderr,
+			"%s - Failed to duplicate fd %d to fd 201\n",
+			strerror(errno), connections_fd);
+		_exit(EXIT_FAILURE);
+	}
+	close(connections_fd);
+
 	if (strncmp(listen_addr, "udp:", sizeof("udp:") - 1) == 0 &&
 	    strncmp(connect_addr, "udp:", sizeof("udp:") - 1) != 0) {
 		    fprintf(stderr, "Error: Proxying from udp to non-udp protocol is not supported\n");
@@ -163,6 +192,7 @@ void forkproxy()
 		whoami = FORKPROXY_CHILD;
 
 		fclose(pid_file);
+		close(FORKPROXY_CONNECTIONS_FD_NUM);
 		ret = close(sk_fds[0]);
 		if (ret < 0)
 			fprintf(stderr, "%s - Failed to close fd %d\n",
@@ -293,6 +323,7 @@ void forkproxy()
 import "C"
 
 const forkproxyUDSSockFDNum int = C.FORKPROXY_UDS_SOCK_FD_NUM
+const forkproxyConnectionsFDNum int = C.FORKPROXY_CONNECTIONS_FD_NUM
 
 type cmdForkproxy struct {
 	global *cmdGlobal
@@ -315,6 +346,26 @@ type udpSession struct {
 	timerLock sync.Mutex
 }
 
+// Number of active connections (or UDP sessions), as reported to LXD
+var proxyConnections int64
+var proxyConnectionsLock sync.Mutex
+var proxyConnectionsFile *os.File
+
+// proxyConnectionsAdd updates the number of active connections and writes it
+// to the connections file.
+func proxyConnectionsAdd(delta int64) {
+	proxyConnectionsLock.Lock()
+	defer proxyConnectionsLock.Unlock()
+
+	proxyConnections += delta
+	if proxyConnectionsFile == nil {
+		return
+	}
+
+	proxyConnectionsFile.Truncate(0)
+	proxyConnectionsFile.WriteAt([]byte(fmt.Sprintf("%d\n", proxyConnections)), 0)
+}
+
 func (c *cmdForkproxy) Command() *cobra.Command {
 	// Main subcommand
 	cmd := &cobra.Command{}
@@ -423,12 +474,18 @@ func listenerInstance(epFd C.int, lAddr *proxyAddress, cAddr *proxyAddress, conn
 		}
 	}
 
+	proxyConnectionsAdd(1)
 	if cAddr.connType == "unix" && lAddr.connType == "unix" {
 		// Handle OOB if both src and dst are using unix sockets
-		go unixRelay(srcConn, dstConn)
+		go func() {
+			unixRelay(srcConn, dstConn)
+			proxyConnectionsAdd(-1)
+		}()
 	} else {
-
-		go genericRelay(srcConn, dstConn, false)
+		go func() {
+			genericRelay(srcConn, dstConn, false)
+			proxyConnectionsAdd(-1)
+		}()
 	}
 
 	return nil
@@ -463,6 +520,11 @@ func (c *cmdForkproxy) Run(cmd *cobra.Command, args []string) error {
 		return fmt.Errorf("Failed to call forkproxy constructor")
 	}
 
+	if C.whoami == C.FORKPROXY_PARENT {
+		proxyConnectionsFile = os.NewFile(uintptr(forkproxyConnectionsFDNum), "connections")
+		proxyConnectionsAdd(0)
+	}
+
 	listenAddr := args[1]
 	lAddr, err := parseAddr(listenAddr)
 	if err != nil {
@@ -738,6 +800,7 @@ func proxyCopy(dst net.Conn, src net.Conn) error {
 					udpSessions[addr.String()] = us
 					udpSessionsLock.Unlock()
 
+					proxyConnectionsAdd(1)
 					go proxyCopy(src, dc)
 					us.timer = time.AfterFunc(30*time.Minute, func() {
 						us.target.Close()
@@ -745,6 +808,8 @@ func proxyCopy(dst net.Conn, src net.Conn) error {
 						udpSessionsLock.Lock()
 						delete(udpSessions, addr.String())
 						udpSessionsLock.Unlock()
+
+						proxyConnectionsAdd(-1)
 					})
 				}
 
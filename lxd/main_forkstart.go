@@ -63,6 +63,12 @@ func (c *cmdForkstart) Run(cmd *cobra.Command, args []string) error {
 		return fmt.Errorf("Error opening startup config file: %q", err)
 	}
 
+	// Inherited by all the processes of the container
+	err = thpDisableIfRequested()
+	if err != nil {
+		return err
+	}
+
 	/* due to https://github.com/golang/go/issues/13155 and the
 	 * CollectOutput call we make for the forkstart process, we need to
 	 * close our stdin/stdout/stderr here. Collecting some of the logs is
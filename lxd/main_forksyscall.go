@@ -0,0 +1,146 @@
+package main
+
+import (
+	"fmt"
+
+	"github.com/spf13/cobra"
+)
+
+/*
+#define _GNU_SOURCE
+#include <errno.h>
+#include <fcntl.h>
+#include <stdbool.h>
+#include <stdio.h>
+#include <stdlib.h>
+#include <string.h>
+#include <sys/fsuid.h>
+#include <sys/stat.h>
+#include <sys/types.h>
+#include <unistd.h>
+#include <limits.h>
+
+#include "include/memory_utils.h"
+
+extern char* advance_arg(bool required);
+extern void error(char *msg);
+extern int dosetns(int pid, char *nstype);
+
+// forksyscall performs a syscall intercepted by the seccomp filter of a
+// container on behalf of the calling process. The exit code is the errno of
+// the syscall, 0 if it succeeded.
+void forksyscall() {
+	__do_close_prot_errno int root_fd = -EBADF;
+	char *command = NULL;
+	char *cur = NULL;
+	char root_path[PATH_MAX];
+	pid_t pid = 0;
+	uid_t uid = 0;
+	gid_t gid = 0;
+	int ret;
+
+	// Get the subcommand
+	command = advance_arg(false);
+	if (command == NULL || (strcmp(command, "--help") == 0 || strcmp(command, "--version") == 0 || strcmp(command, "-h") == 0)) {
+		return;
+	}
+
+	// Get the pid, uid and gid of the calling process
+	cur = advance_arg(false);
+	if (cur == NULL || (strcmp(cur, "--help") == 0 || strcmp(cur, "--version") == 0 || strcmp(cur, "-h") == 0)) {
+		return;
+	}
+	pid = atoi(cur);
+	uid = atoi(advance_arg(true));
+	gid = atoi(advance_arg(true));
+
+	// Check that we're root
+	if (geteuid() != 0) {
+		fprintf(stderr, "Error: forksyscall requires root privileges\n");
+		_exit(EPERM);
+	}
+
+	// Keep a handle on the root of the process, which may be chrooted
+	snprintf(root_path, sizeof(root_path), "/proc/%d/root", pid);
+	root_fd = open(root_path, O_PATH | O_DIRECTORY | O_CLOEXEC);
+	if (root_fd < 0) {
+		error("error: open root");
+		_exit(EPERM);
+	}
+
+	if (dosetns(pid, "mnt") < 0) {
+		_exit(EPERM);
+	}
+
+	if (fchdir(root_fd) < 0 || chroot(".") < 0) {
+		error("error: chroot");
+		_exit(EPERM);
+	}
+
+	// Permissions are checked against the credentials of the process
+	setfsgid(gid);
+	setfsuid(uid);
+	umask(0);
+
+	if (strcmp(command, "mkdir") == 0) {
+		mode_t mode = strtoul(advance_arg(true), NULL, 8);
+		char *path = advance_arg(true);
+
+		ret = mkdir(path, mode);
+	} else if (strcmp(command, "symlink") == 0) {
+		char *target = advance_arg(true);
+		char *path = advance_arg(true);
+
+		ret = symlink(target, path);
+	} else {
+		fprintf(stderr, "Error: Unsupported syscall %s\n", command);
+		_exit(ENOSYS);
+	}
+
+	if (ret < 0)
+		_exit(errno);
+
+	_exit(0);
+}
+*/
+// #cgo CFLAGS: -std=gnu11 -Wvla
+import "C"
+
+type cmdForksyscall struct {
+	global *cmdGlobal
+}
+
+func (c *cmdForksyscall) Command() *cobra.Command {
+	// Main subcommand
+	cmd := &cobra.Command{}
+	cmd.Use = "forksyscall"
+	cmd.Short = "Perform syscalls intercepted in containers"
+	cmd.Long = `Description:
+  Perform syscalls intercepted in containers
+
+  This set of internal commands are used to perform the syscalls
+  intercepted by the seccomp filter of a container on behalf of the
+  calling process, attaching to its mount namespace and root directory.
+`
+	cmd.Hidden = true
+
+	// mkdir
+	cmdMkdir := &cobra.Command{}
+	cmdMkdir.Use = "mkdir <PID> <uid> <gid> <mode> <path>"
+	cmdMkdir.Args = cobra.ExactArgs(5)
+	cmdMkdir.RunE = c.Run
+	cmd.AddCommand(cmdMkdir)
+
+	// symlink
+	cmdSymlink := &cobra.Command{}
+	cmdSymlink.Use = "symlink <PID> <uid> <gid> <target> <path>"
+	cmdSymlink.Args = cobra.ExactArgs(5)
+	cmdSymlink.RunE = c.Run
+	cmd.AddCommand(cmdSymlink)
+
+	return cmd
+}
+
+func (c *cmdForksyscall) Run(cmd *cobra.Command, args []string) error {
+	return fmt.Errorf("This command should have been intercepted in cgo")
+}
@@ -0,0 +1,68 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"syscall"
+
+	"github.com/spf13/cobra"
+)
+
+type cmdForkunpack struct {
+	global *cmdGlobal
+}
+
+func (c *cmdForkunpack) Command() *cobra.Command {
+	cmd := &cobra.Command{}
+	cmd.Use = "forkunpack <rootfs> <layer>"
+	cmd.Short = "Apply an OCI image layer to a root filesystem"
+	cmd.Long = `Description:
+  Apply an OCI image layer to a root filesystem
+
+  This internal command is used to apply the layers of the images built from
+  a Dockerfile. The layer is extracted from within a chroot of the root
+  filesystem so that the symlinks of the earlier layers can't make its
+  entries land outside of it.
+`
+	cmd.RunE = c.Run
+	cmd.Hidden = true
+
+	return cmd
+}
+
+func (c *cmdForkunpack) Run(cmd *cobra.Command, args []string) error {
+	// Sanity checks
+	if len(args) != 2 {
+		cmd.Help()
+
+		if len(args) == 0 {
+			return nil
+		}
+
+		return fmt.Errorf("Missing required arguments")
+	}
+
+	// Only root should run this
+	if os.Geteuid() != 0 {
+		return fmt.Errorf("This must be run as root")
+	}
+
+	// Open the layer before it goes out of reach
+	layer, err := os.Open(args[1])
+	if err != nil {
+		return err
+	}
+	defer layer.Close()
+
+	err = syscall.Chroot(args[0])
+	if err != nil {
+		return err
+	}
+
+	err = os.Chdir("/")
+	if err != nil {
+		return err
+	}
+
+	return ociUnpackLayer("/", layer)
+}
@@ -41,6 +41,7 @@ extern void forkfile();
 extern void forkmount();
 extern void forknet();
 extern void forkproxy();
+extern void forksyscall();
 extern void forkuevent();
 
 // Command line parsing and tracking
@@ -271,6 +272,8 @@ __attribute__((constructor)) void init(void) {
 		forknet();
 	else if (strcmp(cmdline_cur, "forkproxy") == 0)
 		forkproxy();
+	else if (strcmp(cmdline_cur, "forksyscall") == 0)
+		forksyscall();
 	else if (strcmp(cmdline_cur, "forkuevent") == 0)
 		forkuevent();
 	else if (strncmp(cmdline_cur, "-", 1) == 0 || strcmp(cmdline_cur, "daemon") == 0)
@@ -0,0 +1,263 @@
+package main
+
+import (
+	"encoding/hex"
+	"fmt"
+	"net"
+	"sync"
+	"time"
+
+	"golang.org/x/net/dns/dnsmessage"
+
+	"github.com/lxc/lxd/lxd/state"
+	"github.com/lxc/lxd/lxd/types"
+	"github.com/lxc/lxd/shared"
+	"github.com/lxc/lxd/shared/logger"
+
+	log "github.com/lxc/lxd/shared/log15"
+)
+
+const mdnsPort = 5353
+const mdnsTTL = 120
+
+var mdnsGroupV4 = net.ParseIP("224.0.0.251")
+var mdnsGroupV6 = net.ParseIP("ff02::fb")
+
+// How long to wait for a container's interface to get an address
+const mdnsAddressTimeout = time.Minute
+
+// mdnsAnnouncement is an mDNS record set announced for a container NIC.
+type mdnsAnnouncement struct {
+	hostname  string
+	parent    string
+	bridged   bool
+	addresses []net.IP
+}
+
+var mdnsAnnouncementsLock sync.Mutex
+var mdnsAnnouncements = map[string]map[string]*mdnsAnnouncement{}
+
+// mdnsEnabled returns whether mDNS announcements apply to a device, that is
+// an enabled physical NIC or one bridged to a fan network.
+func mdnsEnabled(s *state.State, m types.Device) bool {
+	if m["type"] != "nic" || !shared.IsTrue(m["mdns.announce"]) {
+		return false
+	}
+
+	if m["nictype"] == "physical" {
+		return true
+	}
+
+	if m["nictype"] != "bridged" {
+		return false
+	}
+
+	n, err := networkLoadByName(s, m["parent"])
+	if err != nil {
+		return false
+	}
+
+	return n.Config()["bridge.mode"] == "fan"
+}
+
+// mdnsMessage builds an unsolicited mDNS response for the given addresses.
+// A TTL of zero makes it a goodbye packet.
+func mdnsMessage(hostname string, addresses []net.IP, ttl uint32) ([]byte, error) {
+	name, err := dnsmessage.NewName(hostname)
+	if err != nil {
+		return nil, err
+	}
+
+	msg := dnsmessage.Message{
+		Header: dnsmessage.Header{Response: true, Authoritative: true},
+	}
+
+	// Set the cache-flush bit so that stale records get replaced
+	class := dnsmessage.ClassINET | 1<<15
+
+	for _, address := range addresses {
+		if address.To4() != nil {
+			record := dnsmessage.AResource{}
+			copy(record.A[:], address.To4())
+
+			msg.Answers = append(msg.Answers, dnsmessage.Resource{
+				Header: dnsmessage.ResourceHeader{Name: name, Type: dnsmessage.TypeA, Class: class, TTL: ttl},
+				Body:   &record,
+			})
+		} else {
+			record := dnsmessage.AAAAResource{}
+			copy(record.AAAA[:], address.To16())
+
+			msg.Answers = append(msg.Answers, dnsmessage.Resource{
+				Header: dnsmessage.ResourceHeader{Name: name, Type: dnsmessage.TypeAAAA, Class: class, TTL: ttl},
+				Body:   &record,
+			})
+		}
+	}
+
+	return msg.Pack()
+}
+
+// mdnsSend sends an mDNS packet on the given interface of the current
+// network namespace.
+func mdnsSend(iface string, packet []byte) error {
+	ifi, err := net.InterfaceByName(iface)
+	if err != nil {
+		return err
+	}
+
+	addrs, err := ifi.Addrs()
+	if err != nil {
+		return err
+	}
+
+	sent := false
+	for _, addr := range addrs {
+		ip, _, err := net.ParseCIDR(addr.String())
+		if err != nil || ip.To4() == nil {
+			continue
+		}
+
+		// Binding to the interface's address selects it for multicast
+		conn, err := net.DialUDP("udp4", &net.UDPAddr{IP: ip}, &net.UDPAddr{IP: mdnsGroupV4, Port: mdnsPort})
+		if err != nil {
+			return err
+		}
+
+		_, err = conn.Write(packet)
+		conn.Close()
+		if err != nil {
+			return err
+		}
+
+		sent = true
+		break
+	}
+
+	conn, err := net.DialUDP("udp6", nil, &net.UDPAddr{IP: mdnsGroupV6, Port: mdnsPort, Zone: iface})
+	if err == nil {
+		_, err = conn.Write(packet)
+		conn.Close()
+		if err == nil {
+			sent = true
+		}
+	}
+
+	if !sent {
+		return fmt.Errorf("No usable address on %s", iface)
+	}
+
+	return nil
+}
+
+// mdnsSendContainer sends an mDNS packet from inside a running container.
+func mdnsSendContainer(c *containerLXC, iface string, packet []byte) error {
+	_, err := shared.RunCommand(c.state.OS.ExecPath, "forknet", "mdns", fmt.Sprintf("%d", c.InitPID()), iface, hex.EncodeToString(packet))
+	return err
+}
+
+// mdnsAnnounce waits for the container's mDNS enabled NICs to get an address
+// and announces "<container>.local" on them.
+func mdnsAnnounce(c *containerLXC) {
+	key := fmt.Sprintf("%s/%s", c.Project(), c.Name())
+	hostname := fmt.Sprintf("%s.local.", c.Name())
+
+	for _, name := range c.expandedDevices.DeviceNames() {
+		m := c.expandedDevices[name]
+		if !mdnsEnabled(c.state, m) {
+			continue
+		}
+
+		// Get the name of the NIC inside the container
+		m, err := c.fillNetworkDevice(name, m)
+		if err != nil {
+			logger.Warn("Failed to get the mDNS interface", log.Ctx{"container": c.Name(), "device": name, "err": err})
+			continue
+		}
+
+		go func(name string, m types.Device) {
+			deadline := time.Now().Add(mdnsAddressTimeout)
+			for time.Now().Before(deadline) {
+				if !c.IsRunning() {
+					return
+				}
+
+				addresses := []net.IP{}
+				for _, addr := range c.networkState()[m["name"]].Addresses {
+					if addr.Scope != "global" {
+						continue
+					}
+
+					ip := net.ParseIP(addr.Address)
+					if ip != nil {
+						addresses = append(addresses, ip)
+					}
+				}
+
+				if len(addresses) == 0 {
+					time.Sleep(time.Second)
+					continue
+				}
+
+				packet, err := mdnsMessage(hostname, addresses, mdnsTTL)
+				if err == nil {
+					err = mdnsSendContainer(c, m["name"], packet)
+				}
+
+				if err != nil {
+					logger.Warn("Failed to send mDNS announcement", log.Ctx{"container": c.Name(), "device": name, "err": err})
+					return
+				}
+
+				mdnsAnnouncementsLock.Lock()
+				if mdnsAnnouncements[key] == nil {
+					mdnsAnnouncements[key] = map[string]*mdnsAnnouncement{}
+				}
+
+				mdnsAnnouncements[key][m["name"]] = &mdnsAnnouncement{
+					hostname:  hostname,
+					parent:    m["parent"],
+					bridged:   m["nictype"] == "bridged",
+					addresses: addresses,
+				}
+				mdnsAnnouncementsLock.Unlock()
+
+				return
+			}
+
+			logger.Warn("No address to announce over mDNS", log.Ctx{"container": c.Name(), "device": name})
+		}(name, m)
+	}
+}
+
+// mdnsGoodbye withdraws the container's mDNS records. While the container is
+// running the goodbye packets are sent from its network namespace, otherwise
+// only bridged NICs can still be reached through the host side bridge.
+func mdnsGoodbye(c *containerLXC) {
+	key := fmt.Sprintf("%s/%s", c.Project(), c.Name())
+
+	mdnsAnnouncementsLock.Lock()
+	announcements := mdnsAnnouncements[key]
+	delete(mdnsAnnouncements, key)
+	mdnsAnnouncementsLock.Unlock()
+
+	running := c.IsRunning()
+	for iface, announcement := range announcements {
+		packet, err := mdnsMessage(announcement.hostname, announcement.addresses, 0)
+		if err != nil {
+			continue
+		}
+
+		if running {
+			err = mdnsSendContainer(c, iface, packet)
+		} else if announcement.bridged {
+			err = mdnsSend(announcement.parent, packet)
+		} else {
+			continue
+		}
+
+		if err != nil {
+			logger.Warn("Failed to send mDNS goodbye", log.Ctx{"container": c.Name(), "interface": iface, "err": err})
+		}
+	}
+}
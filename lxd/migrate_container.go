@@ -386,6 +386,7 @@ func (s *migrationSourceWs) Do(migrateOp *operation) error {
 		SnapshotNames: snapshotNames,
 		Snapshots:     snapshots,
 		Predump:       proto.Bool(use_pre_dumps),
+		Sriov:         proto.Bool(s.container.IsRunning() && len(migrationSriovDevices(s.container)) > 0),
 		RsyncFeatures: &migration.RsyncFeatures{
 			Xattrs:        &hasFeature,
 			Delete:        &hasFeature,
@@ -412,6 +413,16 @@ func (s *migrationSourceWs) Do(migrateOp *operation) error {
 		return err
 	}
 
+	// Allocate the virtual functions on the target before stopping the
+	// container, if it supports it
+	if header.GetSriov() {
+		err = s.migrationSriovSource()
+		if err != nil {
+			s.sendControl(err)
+			return err
+		}
+	}
+
 	// Handle rsync options
 	rsyncFeatures := header.GetRsyncFeaturesSlice()
 	if !shared.StringInSlice("bidirectional", rsyncFeatures) {
@@ -906,12 +917,25 @@ func (c *migrationSink) Do(migrateOp *operation) error {
 		resp.Predump = proto.Bool(false)
 	}
 
+	resp.Sriov = proto.Bool(header.GetSriov())
+
 	err = sender(&resp)
 	if err != nil {
 		controller(err)
 		return err
 	}
 
+	if resp.GetSriov() {
+		err = migrationSriovSink(c.src.container, receiver, sender)
+		if err != nil {
+			controller(err)
+			return err
+		}
+
+		// The restored container has taken over the virtual functions
+		defer sriovReservationsRelease(c.src.container)
+	}
+
 	restore := make(chan error)
 	go func(c *migrationSink) {
 		imagesDir := ""
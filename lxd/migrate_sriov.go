@@ -0,0 +1,346 @@
+package main
+
+import (
+	"fmt"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/golang/protobuf/proto"
+
+	"github.com/lxc/lxd/lxd/db"
+	"github.com/lxc/lxd/lxd/migration"
+	"github.com/lxc/lxd/shared"
+	"github.com/lxc/lxd/shared/logger"
+
+	log "github.com/lxc/lxd/shared/log15"
+)
+
+// sriovReservation is a virtual function reserved on this node for a SR-IOV
+// network device of a container being migrated to it.
+type sriovReservation struct {
+	// Container name with the project prefix
+	container string
+	device    string
+	hostName  string
+}
+
+var sriovReservationsLock sync.Mutex
+var sriovReservations = []sriovReservation{}
+
+// Serializes the allocations, which pick the virtual functions not in use
+var sriovAllocateLock sync.Mutex
+
+// sriovReservationGet returns the virtual function reserved for a device of a
+// container, if any.
+func sriovReservationGet(c container, device string) string {
+	sriovReservationsLock.Lock()
+	defer sriovReservationsLock.Unlock()
+
+	owner := projectPrefix(c.Project(), c.Name())
+	for _, r := range sriovReservations {
+		if r.container == owner && r.device == device {
+			return r.hostName
+		}
+	}
+
+	return ""
+}
+
+// sriovReservedNames returns the virtual functions reserved for containers
+// other than the given one.
+func sriovReservedNames(c container) []string {
+	sriovReservationsLock.Lock()
+	defer sriovReservationsLock.Unlock()
+
+	owner := projectPrefix(c.Project(), c.Name())
+	names := []string{}
+	for _, r := range sriovReservations {
+		if r.container != owner {
+			names = append(names, r.hostName)
+		}
+	}
+
+	return names
+}
+
+// sriovReservationsRelease releases the virtual functions reserved for a
+// container.
+func sriovReservationsRelease(c container) {
+	sriovReservationsLock.Lock()
+	defer sriovReservationsLock.Unlock()
+
+	owner := projectPrefix(c.Project(), c.Name())
+	kept := []sriovReservation{}
+	for _, r := range sriovReservations {
+		if r.container != owner {
+			kept = append(kept, r)
+		}
+	}
+
+	sriovReservations = kept
+}
+
+// sriovVFIndex returns the index of a virtual function of a SR-IOV device
+// given the name of its network interface.
+func sriovVFIndex(parent string, hostName string) (int, error) {
+	prefix := fmt.Sprintf("/sys/class/net/%s/device/virtfn", parent)
+	vfs, err := filepath.Glob(prefix + "*")
+	if err != nil {
+		return -1, err
+	}
+
+	for _, vf := range vfs {
+		if !shared.PathExists(filepath.Join(vf, "net", hostName)) {
+			continue
+		}
+
+		return strconv.Atoi(strings.TrimPrefix(vf, prefix))
+	}
+
+	return -1, fmt.Errorf("Virtual function '%s' not found on device '%s'", hostName, parent)
+}
+
+// sriovSetMAC configures the MAC address of a virtual function through its
+// parent so that the container keeps its address on the new function.
+func sriovSetMAC(parent string, hostName string, hwaddr string) error {
+	index, err := sriovVFIndex(parent, hostName)
+	if err != nil {
+		return err
+	}
+
+	_, err = shared.RunCommand("ip", "link", "set", "dev", parent, "vf", strconv.Itoa(index), "mac", hwaddr)
+	if err != nil {
+		return fmt.Errorf("Failed to set the MAC address of virtual function '%s': %v", hostName, err)
+	}
+
+	return nil
+}
+
+// migrationSriovDevices returns the SR-IOV network devices of a container for
+// which a virtual function needs to be allocated on the migration sink.
+func migrationSriovDevices(c container) []*migration.SriovDevice {
+	devices := []*migration.SriovDevice{}
+
+	for _, name := range c.ExpandedDevices().DeviceNames() {
+		m := c.ExpandedDevices()[name]
+		if m["type"] != "nic" || m["nictype"] != "sriov" {
+			continue
+		}
+
+		hwaddr := m["hwaddr"]
+		if hwaddr == "" {
+			hwaddr = c.LocalConfig()[fmt.Sprintf("volatile.%s.hwaddr", name)]
+		}
+
+		devices = append(devices, &migration.SriovDevice{
+			Name:   proto.String(name),
+			Hwaddr: proto.String(hwaddr),
+		})
+	}
+
+	return devices
+}
+
+// migrationSriovAllocate reserves a virtual function on this node for each of
+// the SR-IOV network devices of a container being migrated to it, configuring
+// the MAC address the device had on the source.
+func migrationSriovAllocate(c container, devices []*migration.SriovDevice) ([]*migration.SriovDevice, error) {
+	ct, ok := c.(*containerLXC)
+	if !ok {
+		return nil, fmt.Errorf("Virtual functions can only be allocated to LXC containers")
+	}
+
+	sriovAllocateLock.Lock()
+	defer sriovAllocateLock.Unlock()
+
+	// Physical devices won't be available as virtual functions
+	reserved := []string{}
+	for _, name := range c.ExpandedDevices().DeviceNames() {
+		m := c.ExpandedDevices()[name]
+		if m["type"] == "nic" && m["nictype"] == "physical" {
+			reserved = append(reserved, m["parent"])
+		}
+	}
+
+	sriovReservationsRelease(c)
+
+	revert := true
+	defer func() {
+		if revert {
+			sriovReservationsRelease(c)
+		}
+	}()
+
+	allocated := []*migration.SriovDevice{}
+	for _, device := range devices {
+		m, ok := c.ExpandedDevices()[device.GetName()]
+		if !ok || m["type"] != "nic" || m["nictype"] != "sriov" {
+			return nil, fmt.Errorf("Device '%s' isn't a SR-IOV network device on the target", device.GetName())
+		}
+
+		m, err := ct.fillSriovNetworkDevice(device.GetName(), m, reserved)
+		if err != nil {
+			return nil, err
+		}
+		reserved = append(reserved, m["host_name"])
+
+		// Prefer the address configured on the target
+		hwaddr := m["hwaddr"]
+		if hwaddr == "" {
+			hwaddr = c.LocalConfig()[fmt.Sprintf("volatile.%s.hwaddr", device.GetName())]
+		}
+
+		if hwaddr == "" {
+			hwaddr = device.GetHwaddr()
+		}
+
+		if hwaddr != "" {
+			err = sriovSetMAC(m["parent"], m["host_name"], hwaddr)
+			if err != nil {
+				return nil, err
+			}
+		}
+
+		sriovReservationsLock.Lock()
+		sriovReservations = append(sriovReservations, sriovReservation{
+			container: projectPrefix(c.Project(), c.Name()),
+			device:    device.GetName(),
+			hostName:  m["host_name"],
+		})
+		sriovReservationsLock.Unlock()
+
+		allocated = append(allocated, &migration.SriovDevice{
+			Name:     proto.String(device.GetName()),
+			Hwaddr:   proto.String(hwaddr),
+			HostName: proto.String(m["host_name"]),
+		})
+	}
+
+	revert = false
+	return allocated, nil
+}
+
+// migrationSriovCommit records the MAC addresses of the devices which got a
+// virtual function allocated, in case the container didn't have one yet.
+func migrationSriovCommit(c container, devices []*migration.SriovDevice) error {
+	volatile := map[string]string{}
+	for _, device := range devices {
+		key := fmt.Sprintf("volatile.%s.hwaddr", device.GetName())
+		if device.GetHwaddr() == "" || c.LocalConfig()[key] != "" || c.ExpandedDevices()[device.GetName()]["hwaddr"] != "" {
+			continue
+		}
+
+		volatile[key] = device.GetHwaddr()
+	}
+
+	if len(volatile) == 0 {
+		return nil
+	}
+
+	state := c.DaemonState()
+	err := state.Cluster.Transaction(func(tx *db.ClusterTx) error {
+		return tx.ContainerConfigInsert(c.Id(), volatile)
+	})
+	if err != nil {
+		return err
+	}
+
+	for key, value := range volatile {
+		c.LocalConfig()[key] = value
+	}
+
+	return nil
+}
+
+// migrationSriovSource runs the SR-IOV pre-migration phase on the source: the
+// sink allocates a virtual function for each device, which is committed once
+// they all got one or rolled back otherwise.
+func (s *migrationSourceWs) migrationSriovSource() error {
+	devices := migrationSriovDevices(s.container)
+
+	err := s.send(&migration.MigrationSriovAllocate{Devices: devices})
+	if err != nil {
+		return err
+	}
+
+	result := migration.MigrationSriovAllocation{}
+	err = s.recv(&result)
+	if err != nil {
+		return err
+	}
+
+	if !result.GetSuccess() {
+		return fmt.Errorf("Failed to allocate the virtual functions on the target: %s", result.GetMessage())
+	}
+
+	err = nil
+	if len(result.GetDevices()) != len(devices) {
+		err = fmt.Errorf("The target allocated %d virtual functions out of %d", len(result.GetDevices()), len(devices))
+	}
+
+	sendErr := s.send(&migration.MigrationSriovCommit{Commit: proto.Bool(err == nil)})
+	if err != nil {
+		return err
+	}
+
+	if sendErr != nil {
+		return sendErr
+	}
+
+	for _, device := range result.GetDevices() {
+		logger.Debug("Allocated virtual function on the target", log.Ctx{"container": s.container.Name(), "device": device.GetName(), "vf": device.GetHostName()})
+	}
+
+	return nil
+}
+
+// migrationSriovSink runs the SR-IOV pre-migration phase on the sink. The
+// reservations are kept until the migration completes.
+func migrationSriovSink(c container, receiver func(proto.Message) error, sender func(proto.Message) error) error {
+	req := migration.MigrationSriovAllocate{}
+	err := receiver(&req)
+	if err != nil {
+		return err
+	}
+
+	devices, err := migrationSriovAllocate(c, req.GetDevices())
+	result := migration.MigrationSriovAllocation{
+		Success: proto.Bool(err == nil),
+		Devices: devices,
+	}
+	if err != nil {
+		result.Message = proto.String(err.Error())
+	}
+
+	sendErr := sender(&result)
+	if err != nil {
+		return err
+	}
+
+	if sendErr != nil {
+		sriovReservationsRelease(c)
+		return sendErr
+	}
+
+	commit := migration.MigrationSriovCommit{}
+	err = receiver(&commit)
+	if err != nil {
+		sriovReservationsRelease(c)
+		return err
+	}
+
+	if !commit.GetCommit() {
+		sriovReservationsRelease(c)
+		return fmt.Errorf("The source rolled back the allocation of the virtual functions")
+	}
+
+	err = migrationSriovCommit(c, devices)
+	if err != nil {
+		sriovReservationsRelease(c)
+		return err
+	}
+
+	return nil
+}
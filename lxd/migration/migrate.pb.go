@@ -17,6 +17,10 @@ It has these top-level messages:
 	MigrationHeader
 	MigrationControl
 	MigrationSync
+	SriovDevice
+	MigrationSriovAllocate
+	MigrationSriovAllocation
+	MigrationSriovCommit
 	DumpStatsEntry
 	RestoreStatsEntry
 	StatsEntry
@@ -363,6 +367,7 @@ type MigrationHeader struct {
 	RsyncFeatures    *RsyncFeatures   `protobuf:"bytes,8,opt,name=rsyncFeatures" json:"rsyncFeatures,omitempty"`
 	Refresh          *bool            `protobuf:"varint,9,opt,name=refresh" json:"refresh,omitempty"`
 	ZfsFeatures      *ZfsFeatures     `protobuf:"bytes,10,opt,name=zfsFeatures" json:"zfsFeatures,omitempty"`
+	Sriov            *bool            `protobuf:"varint,11,opt,name=sriov" json:"sriov,omitempty"`
 	XXX_unrecognized []byte           `json:"-"`
 }
 
@@ -434,6 +439,13 @@ func (m *MigrationHeader) GetZfsFeatures() *ZfsFeatures {
 	return nil
 }
 
+func (m *MigrationHeader) GetSriov() bool {
+	if m != nil && m.Sriov != nil {
+		return *m.Sriov
+	}
+	return false
+}
+
 type MigrationControl struct {
 	Success *bool `protobuf:"varint,1,req,name=success" json:"success,omitempty"`
 	// optional failure message if sending a failure
@@ -477,6 +489,107 @@ func (m *MigrationSync) GetFinalPreDump() bool {
 	return false
 }
 
+type SriovDevice struct {
+	Name   *string `protobuf:"bytes,1,req,name=name" json:"name,omitempty"`
+	Hwaddr *string `protobuf:"bytes,2,opt,name=hwaddr" json:"hwaddr,omitempty"`
+	// virtual function allocated by the sink
+	HostName         *string `protobuf:"bytes,3,opt,name=hostName" json:"hostName,omitempty"`
+	XXX_unrecognized []byte  `json:"-"`
+}
+
+func (m *SriovDevice) Reset()                    { *m = SriovDevice{} }
+func (m *SriovDevice) String() string            { return proto.CompactTextString(m) }
+func (*SriovDevice) ProtoMessage()               {}
+func (*SriovDevice) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{9} }
+
+func (m *SriovDevice) GetName() string {
+	if m != nil && m.Name != nil {
+		return *m.Name
+	}
+	return ""
+}
+
+func (m *SriovDevice) GetHwaddr() string {
+	if m != nil && m.Hwaddr != nil {
+		return *m.Hwaddr
+	}
+	return ""
+}
+
+func (m *SriovDevice) GetHostName() string {
+	if m != nil && m.HostName != nil {
+		return *m.HostName
+	}
+	return ""
+}
+
+type MigrationSriovAllocate struct {
+	Devices          []*SriovDevice `protobuf:"bytes,1,rep,name=devices" json:"devices,omitempty"`
+	XXX_unrecognized []byte         `json:"-"`
+}
+
+func (m *MigrationSriovAllocate) Reset()                    { *m = MigrationSriovAllocate{} }
+func (m *MigrationSriovAllocate) String() string            { return proto.CompactTextString(m) }
+func (*MigrationSriovAllocate) ProtoMessage()               {}
+func (*MigrationSriovAllocate) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{10} }
+
+func (m *MigrationSriovAllocate) GetDevices() []*SriovDevice {
+	if m != nil {
+		return m.Devices
+	}
+	return nil
+}
+
+type MigrationSriovAllocation struct {
+	Success          *bool          `protobuf:"varint,1,req,name=success" json:"success,omitempty"`
+	Message          *string        `protobuf:"bytes,2,opt,name=message" json:"message,omitempty"`
+	Devices          []*SriovDevice `protobuf:"bytes,3,rep,name=devices" json:"devices,omitempty"`
+	XXX_unrecognized []byte         `json:"-"`
+}
+
+func (m *MigrationSriovAllocation) Reset()                    { *m = MigrationSriovAllocation{} }
+func (m *MigrationSriovAllocation) String() string            { return proto.CompactTextString(m) }
+func (*MigrationSriovAllocation) ProtoMessage()               {}
+func (*MigrationSriovAllocation) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{11} }
+
+func (m *MigrationSriovAllocation) GetSuccess() bool {
+	if m != nil && m.Success != nil {
+		return *m.Success
+	}
+	return false
+}
+
+func (m *MigrationSriovAllocation) GetMessage() string {
+	if m != nil && m.Message != nil {
+		return *m.Message
+	}
+	return ""
+}
+
+func (m *MigrationSriovAllocation) GetDevices() []*SriovDevice {
+	if m != nil {
+		return m.Devices
+	}
+	return nil
+}
+
+type MigrationSriovCommit struct {
+	Commit           *bool  `protobuf:"varint,1,req,name=commit" json:"commit,omitempty"`
+	XXX_unrecognized []byte `json:"-"`
+}
+
+func (m *MigrationSriovCommit) Reset()                    { *m = MigrationSriovCommit{} }
+func (m *MigrationSriovCommit) String() string            { return proto.CompactTextString(m) }
+func (*MigrationSriovCommit) ProtoMessage()               {}
+func (*MigrationSriovCommit) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{12} }
+
+func (m *MigrationSriovCommit) GetCommit() bool {
+	if m != nil && m.Commit != nil {
+		return *m.Commit
+	}
+	return false
+}
+
 // This one contains statistics about dump/restore process
 type DumpStatsEntry struct {
 	FreezingTime       *uint32 `protobuf:"varint,1,req,name=freezing_time,json=freezingTime" json:"freezing_time,omitempty"`
@@ -496,7 +609,7 @@ type DumpStatsEntry struct {
 func (m *DumpStatsEntry) Reset()                    { *m = DumpStatsEntry{} }
 func (m *DumpStatsEntry) String() string            { return proto.CompactTextString(m) }
 func (*DumpStatsEntry) ProtoMessage()               {}
-func (*DumpStatsEntry) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{9} }
+func (*DumpStatsEntry) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{13} }
 
 func (m *DumpStatsEntry) GetFreezingTime() uint32 {
 	if m != nil && m.FreezingTime != nil {
@@ -587,7 +700,7 @@ type RestoreStatsEntry struct {
 func (m *RestoreStatsEntry) Reset()                    { *m = RestoreStatsEntry{} }
 func (m *RestoreStatsEntry) String() string            { return proto.CompactTextString(m) }
 func (*RestoreStatsEntry) ProtoMessage()               {}
-func (*RestoreStatsEntry) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{10} }
+func (*RestoreStatsEntry) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{14} }
 
 func (m *RestoreStatsEntry) GetPagesCompared() uint64 {
 	if m != nil && m.PagesCompared != nil {
@@ -633,7 +746,7 @@ type StatsEntry struct {
 func (m *StatsEntry) Reset()                    { *m = StatsEntry{} }
 func (m *StatsEntry) String() string            { return proto.CompactTextString(m) }
 func (*StatsEntry) ProtoMessage()               {}
-func (*StatsEntry) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{11} }
+func (*StatsEntry) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{15} }
 
 func (m *StatsEntry) GetDump() *DumpStatsEntry {
 	if m != nil {
@@ -659,6 +772,10 @@ func init() {
 	proto.RegisterType((*MigrationHeader)(nil), "migration.MigrationHeader")
 	proto.RegisterType((*MigrationControl)(nil), "migration.MigrationControl")
 	proto.RegisterType((*MigrationSync)(nil), "migration.MigrationSync")
+	proto.RegisterType((*SriovDevice)(nil), "migration.sriovDevice")
+	proto.RegisterType((*MigrationSriovAllocate)(nil), "migration.MigrationSriovAllocate")
+	proto.RegisterType((*MigrationSriovAllocation)(nil), "migration.MigrationSriovAllocation")
+	proto.RegisterType((*MigrationSriovCommit)(nil), "migration.MigrationSriovCommit")
 	proto.RegisterType((*DumpStatsEntry)(nil), "migration.dump_stats_entry")
 	proto.RegisterType((*RestoreStatsEntry)(nil), "migration.restore_stats_entry")
 	proto.RegisterType((*StatsEntry)(nil), "migration.stats_entry")
@@ -669,71 +786,77 @@ func init() {
 func init() { proto.RegisterFile("lxd/migration/migrate.proto", fileDescriptor0) }
 
 var fileDescriptor0 = []byte{
-	// 1047 bytes of a gzipped FileDescriptorProto
-	0x1f, 0x8b, 0x08, 0x00, 0x00, 0x00, 0x00, 0x00, 0x02, 0xff, 0x84, 0x55, 0xdb, 0x6e, 0xdb, 0x46,
-	0x10, 0xad, 0x6e, 0xb6, 0x38, 0x92, 0x1c, 0x65, 0x63, 0x04, 0x44, 0xd2, 0x8b, 0xca, 0xa4, 0xa8,
-	0xe2, 0x07, 0x3b, 0x55, 0x50, 0x20, 0x7d, 0x29, 0x50, 0xcb, 0x75, 0x13, 0x20, 0x71, 0x8d, 0x95,
-	0x8d, 0xa2, 0x7d, 0x21, 0x36, 0xe4, 0x50, 0x5e, 0x98, 0x37, 0xec, 0x52, 0xb6, 0xe5, 0x97, 0xa2,
-	0x9f, 0xd1, 0x0f, 0xe8, 0xf7, 0xf4, 0xa9, 0xff, 0x53, 0xec, 0x2c, 0x49, 0x53, 0x4e, 0x81, 0xbe,
-	0xed, 0x9c, 0x39, 0x3c, 0xb3, 0x3b, 0x37, 0xc2, 0xd3, 0xf8, 0x26, 0x3c, 0x48, 0xe4, 0x52, 0x89,
-	0x42, 0x66, 0x69, 0x79, 0xc2, 0xfd, 0x5c, 0x65, 0x45, 0xc6, 0x9c, 0xda, 0xe1, 0xfd, 0x0e, 0xce,
-	0xdb, 0xa3, 0xf7, 0x22, 0x3f, 0x5b, 0xe7, 0xc8, 0x76, 0xa1, 0x27, 0xf5, 0x4a, 0x86, 0x6e, 0x6b,
-	0xd2, 0x9e, 0xf6, 0xb9, 0x35, 0x2c, 0xba, 0x94, 0xa1, 0xdb, 0xae, 0xd0, 0xa5, 0x0c, 0xd9, 0x63,
-	0xd8, 0xba, 0xc8, 0x74, 0x21, 0x43, 0xb7, 0x33, 0x69, 0x4f, 0x7b, 0xbc, 0xb4, 0x18, 0x83, 0x6e,
-	0xaa, 0x65, 0xe8, 0x76, 0x09, 0xa5, 0x33, 0x7b, 0x02, 0xfd, 0x44, 0xe4, 0x4a, 0xa4, 0x4b, 0x74,
-	0x7b, 0x84, 0xd7, 0xb6, 0xf7, 0x12, 0xb6, 0xe6, 0x59, 0x1a, 0xc9, 0x25, 0x1b, 0x43, 0xe7, 0x12,
-	0xd7, 0x14, 0xdb, 0xe1, 0xe6, 0x68, 0x22, 0x5f, 0x89, 0x78, 0x85, 0x14, 0xd9, 0xe1, 0xd6, 0xf0,
-	0x7e, 0x82, 0xad, 0x23, 0xbc, 0x92, 0x01, 0x52, 0x2c, 0x91, 0x60, 0xf9, 0x09, 0x9d, 0xd9, 0x0b,
-	0xd8, 0x0a, 0x48, 0xcf, 0x6d, 0x4f, 0x3a, 0xd3, 0xc1, 0xec, 0xe1, 0x7e, 0xfd, 0xd8, 0x7d, 0x1b,
-	0x88, 0x97, 0x04, 0xef, 0xef, 0x36, 0xf4, 0x17, 0xa9, 0xc8, 0xf5, 0x45, 0x56, 0xfc, 0xa7, 0xd6,
-	0x2b, 0x18, 0xc4, 0x59, 0x20, 0xe2, 0xf9, 0xff, 0x08, 0x36, 0x59, 0xe6, 0xb1, 0xb9, 0xca, 0x22,
-	0x19, 0xa3, 0x76, 0x3b, 0x93, 0xce, 0xd4, 0xe1, 0xb5, 0xcd, 0x3e, 0x05, 0x07, 0xf3, 0x0b, 0x4c,
-	0x50, 0x89, 0x98, 0x32, 0xd4, 0xe7, 0x77, 0x00, 0xfb, 0x16, 0x86, 0x24, 0x64, 0x5f, 0xa7, 0xdd,
-	0xde, 0x47, 0xf1, 0xac, 0x87, 0x6f, 0xd0, 0x98, 0x07, 0x43, 0xa1, 0x82, 0x0b, 0x59, 0x60, 0x50,
-	0xac, 0x14, 0xba, 0x5b, 0x94, 0xe1, 0x0d, 0xcc, 0x5c, 0x4a, 0x17, 0xa2, 0xc0, 0x68, 0x15, 0xbb,
-	0xdb, 0x14, 0xb7, 0xb6, 0xd9, 0x33, 0x18, 0x05, 0x0a, 0x29, 0x80, 0x1f, 0x8a, 0x02, 0xdd, 0xfe,
-	0xa4, 0x35, 0xed, 0xf0, 0x61, 0x05, 0x1e, 0x89, 0x02, 0xd9, 0x73, 0xd8, 0x89, 0x85, 0x2e, 0xfc,
-	0x95, 0xc6, 0xd0, 0xb2, 0x1c, 0xcb, 0x32, 0xe8, 0xb9, 0xc6, 0xd0, 0xb0, 0xbc, 0x3f, 0x5a, 0x30,
-	0x52, 0x7a, 0x9d, 0x06, 0xc7, 0x28, 0x4c, 0x5c, 0x6d, 0xda, 0xe4, 0x46, 0x14, 0x85, 0xd2, 0x6e,
-	0x6b, 0xd2, 0x9a, 0xf6, 0x79, 0x69, 0x19, 0x3c, 0xc4, 0x18, 0x0b, 0x53, 0x5b, 0xc2, 0xad, 0x65,
-	0x2e, 0x1a, 0x64, 0x49, 0xae, 0x50, 0x9b, 0xec, 0x19, 0x4f, 0x6d, 0xb3, 0xe7, 0x30, 0xfa, 0x20,
-	0x43, 0xa9, 0x30, 0x30, 0xd7, 0xa2, 0x0c, 0x1a, 0xc2, 0x26, 0xe8, 0xbd, 0x80, 0xc1, 0x6d, 0xa4,
-	0xeb, 0x0b, 0x34, 0x05, 0x5b, 0x9b, 0x82, 0xde, 0x9f, 0x1d, 0x78, 0xf0, 0xbe, 0x4a, 0xee, 0x1b,
-	0x14, 0x21, 0x2a, 0xb6, 0x07, 0xed, 0x48, 0x53, 0x17, 0xec, 0xcc, 0x9e, 0x34, 0x52, 0x5f, 0xf3,
-	0x8e, 0x17, 0x66, 0x56, 0x78, 0x3b, 0xd2, 0xec, 0x6b, 0xe8, 0x06, 0x4a, 0xae, 0xe8, 0x09, 0x3b,
-	0xb3, 0x47, 0xcd, 0xc6, 0xe0, 0x6f, 0xcf, 0x89, 0x46, 0x04, 0xb6, 0x07, 0x3d, 0x19, 0x26, 0x22,
-	0xa7, 0x86, 0x18, 0xcc, 0x76, 0x1b, 0xcc, 0x7a, 0xfa, 0xb8, 0xa5, 0x98, 0x57, 0xea, 0xb2, 0x29,
-	0x4f, 0x44, 0x82, 0xda, 0xed, 0x52, 0x13, 0x6d, 0x82, 0xec, 0x1b, 0x70, 0x2a, 0xa0, 0x6a, 0x94,
-	0x66, 0xfc, 0xaa, 0xad, 0xf9, 0x1d, 0x8b, 0xb9, 0xb0, 0x9d, 0x2b, 0x0c, 0x57, 0x49, 0xee, 0x6e,
-	0x53, 0x22, 0x2a, 0x93, 0x7d, 0x7f, 0xaf, 0x6a, 0xd4, 0x01, 0x83, 0x99, 0xdb, 0x10, 0xdc, 0xf0,
-	0xf3, 0x7b, 0x45, 0x76, 0x61, 0x5b, 0x61, 0xa4, 0x50, 0x5f, 0x50, 0x57, 0xf4, 0x79, 0x65, 0xb2,
-	0xd7, 0x1b, 0xc5, 0x70, 0x81, 0x74, 0x1f, 0x37, 0x74, 0x1b, 0x5e, 0xde, 0xa4, 0x7a, 0xc7, 0x30,
-	0xae, 0x53, 0x3e, 0xcf, 0xd2, 0x42, 0x65, 0xb1, 0x89, 0xa3, 0x57, 0x41, 0x60, 0x4b, 0x69, 0x9a,
-	0xb8, 0x32, 0x8d, 0x27, 0x41, 0xad, 0xc5, 0xd2, 0xf6, 0x93, 0xc3, 0x2b, 0xd3, 0x7b, 0x05, 0xa3,
-	0x5a, 0x67, 0xb1, 0x4e, 0x03, 0x33, 0x2e, 0x91, 0x4c, 0x45, 0x7c, 0xaa, 0xf0, 0xc8, 0xe4, 0xc2,
-	0x2a, 0x6d, 0x60, 0xde, 0x5f, 0x1d, 0x18, 0x9b, 0xcc, 0xf8, 0x66, 0x48, 0xb4, 0x8f, 0x69, 0xa1,
-	0xd6, 0x66, 0x4e, 0x22, 0x85, 0x78, 0x2b, 0xd3, 0xa5, 0x5f, 0xc8, 0x72, 0x55, 0x8c, 0xf8, 0xb0,
-	0x02, 0xcf, 0x64, 0x82, 0xec, 0x0b, 0x18, 0x44, 0x2a, 0xbb, 0xc5, 0xd4, 0x52, 0xda, 0x44, 0x01,
-	0x0b, 0x11, 0xe1, 0x4b, 0x18, 0x26, 0x98, 0x90, 0x38, 0x31, 0x3a, 0xc4, 0x18, 0x94, 0x18, 0x51,
-	0x9e, 0xc1, 0x28, 0xc1, 0xe4, 0x5a, 0xc9, 0x02, 0x2d, 0xa7, 0x6b, 0x03, 0x55, 0x60, 0x45, 0xca,
-	0xc5, 0x12, 0xb5, 0xaf, 0x03, 0x91, 0xa6, 0x18, 0xd2, 0x62, 0xed, 0xf2, 0x21, 0x81, 0x0b, 0x8b,
-	0xb1, 0x97, 0xb0, 0x5b, 0x92, 0x2e, 0x65, 0x9e, 0x63, 0xe8, 0xe7, 0x42, 0x61, 0x5a, 0xd0, 0x8a,
-	0xe8, 0x72, 0x66, 0xb9, 0xd6, 0x75, 0x4a, 0x9e, 0x3b, 0x59, 0x13, 0xa9, 0xc0, 0x94, 0xb6, 0x45,
-	0x25, 0xfb, 0x8b, 0xc5, 0x0c, 0x49, 0xaa, 0x44, 0xe4, 0xbe, 0x42, 0x9d, 0xc5, 0x57, 0x76, 0x63,
-	0x8c, 0xf8, 0x90, 0x40, 0x6e, 0x31, 0xf6, 0x19, 0x80, 0x55, 0x8a, 0xc5, 0xed, 0xda, 0x75, 0x48,
-	0xc6, 0x21, 0xe4, 0x9d, 0xb8, 0x5d, 0x57, 0x6e, 0x3f, 0x97, 0x79, 0xd9, 0x18, 0xa5, 0xfb, 0xd4,
-	0x00, 0x66, 0xdf, 0xd4, 0x6e, 0xff, 0xc3, 0x2a, 0xd2, 0xee, 0x80, 0x28, 0xc3, 0x8a, 0x72, 0xb8,
-	0x8a, 0xb4, 0xf7, 0x4f, 0x0b, 0x1e, 0x29, 0xd4, 0x45, 0xa6, 0x70, 0xa3, 0x54, 0x5f, 0xd9, 0xaf,
-	0xb5, 0x6f, 0x46, 0x5d, 0x28, 0xb4, 0x7f, 0xb4, 0x2e, 0xb7, 0x6f, 0x9b, 0x97, 0x20, 0xdb, 0x83,
-	0x87, 0x9b, 0xe9, 0x09, 0xb2, 0x6b, 0x2a, 0x59, 0x97, 0x3f, 0x68, 0xe6, 0x66, 0x9e, 0x5d, 0x9b,
-	0xba, 0x45, 0x99, 0xba, 0xac, 0x8b, 0x5f, 0xd6, 0xad, 0xc4, 0xaa, 0xd2, 0x56, 0x97, 0x69, 0x94,
-	0x6d, 0x50, 0x62, 0x44, 0xa9, 0x2f, 0x56, 0x82, 0xa6, 0x6c, 0xad, 0xfa, 0x62, 0xbc, 0x04, 0xbd,
-	0x1b, 0x18, 0x34, 0x9f, 0x73, 0x00, 0xdd, 0xd0, 0xb6, 0xaa, 0x19, 0x9f, 0xa7, 0x8d, 0xf1, 0xb9,
-	0xdf, 0xa4, 0x9c, 0x88, 0xec, 0xb5, 0x19, 0x48, 0xd2, 0xa2, 0x71, 0x18, 0xcc, 0x3e, 0x6f, 0x8e,
-	0xf2, 0xc7, 0x09, 0xe3, 0x15, 0x7d, 0xef, 0xbb, 0xc6, 0x46, 0xb4, 0x9b, 0x8e, 0x39, 0xd0, 0xe3,
-	0x8b, 0x5f, 0x4f, 0xe6, 0xe3, 0x4f, 0xcc, 0xf1, 0xf0, 0x8c, 0x1f, 0x2f, 0xc6, 0x2d, 0xb6, 0x0d,
-	0x9d, 0xdf, 0x8e, 0x17, 0xe3, 0xb6, 0x39, 0xf0, 0xc3, 0xa3, 0x71, 0x67, 0xef, 0x00, 0xfa, 0xd5,
-	0xda, 0x63, 0x3b, 0x00, 0xe6, 0xec, 0x37, 0x3e, 0x3c, 0x7d, 0xf3, 0xc3, 0xf9, 0xbb, 0x71, 0x8b,
-	0xf5, 0xa1, 0x7b, 0xf2, 0xf3, 0xc9, 0x8f, 0xe3, 0xf6, 0xbf, 0x01, 0x00, 0x00, 0xff, 0xff, 0xcf,
-	0xd6, 0xdc, 0x0b, 0xa4, 0x08, 0x00, 0x00,
+	// 1151 bytes of a gzipped FileDescriptorProto
+	0x1f, 0x8b, 0x08, 0x00, 0x00, 0x00, 0x00, 0x00, 0x02, 0xff, 0x9c, 0x56, 0xcd, 0x6e, 0xdb, 0x46,
+	0x10, 0xae, 0x44, 0xc9, 0x12, 0x47, 0x92, 0xa3, 0x6c, 0x8c, 0x80, 0x48, 0xfa, 0xa3, 0x32, 0x29,
+	0xea, 0xf8, 0xe0, 0xa4, 0x0e, 0x0a, 0xa4, 0x97, 0x02, 0x89, 0x5c, 0x37, 0x29, 0x12, 0xd7, 0x58,
+	0xc5, 0x28, 0xda, 0x8b, 0xb0, 0x21, 0x87, 0xf2, 0x22, 0xfc, 0xc3, 0x2e, 0x65, 0x47, 0xbe, 0xb4,
+	0x7d, 0x98, 0x9e, 0xfb, 0x28, 0x3d, 0xf5, 0x7d, 0x8a, 0x9d, 0x25, 0x69, 0xd2, 0x0d, 0xda, 0xa2,
+	0xb7, 0x9d, 0x6f, 0x3e, 0x7e, 0x33, 0x3b, 0x33, 0x3b, 0x12, 0xdc, 0x8d, 0xdf, 0x85, 0x0f, 0x13,
+	0xb9, 0x52, 0xa2, 0x90, 0x59, 0x5a, 0x9e, 0x70, 0x3f, 0x57, 0x59, 0x91, 0x31, 0xb7, 0x76, 0xf8,
+	0x3f, 0x83, 0xfb, 0xe2, 0xf0, 0x95, 0xc8, 0x5f, 0x6f, 0x72, 0x64, 0x3b, 0xd0, 0x97, 0x7a, 0x2d,
+	0x43, 0xaf, 0x33, 0xeb, 0xee, 0x0e, 0xb9, 0x35, 0x2c, 0xba, 0x92, 0xa1, 0xd7, 0xad, 0xd0, 0x95,
+	0x0c, 0xd9, 0x6d, 0xd8, 0x3a, 0xcb, 0x74, 0x21, 0x43, 0xcf, 0x99, 0x75, 0x77, 0xfb, 0xbc, 0xb4,
+	0x18, 0x83, 0x5e, 0xaa, 0x65, 0xe8, 0xf5, 0x08, 0xa5, 0x33, 0xbb, 0x03, 0xc3, 0x44, 0xe4, 0x4a,
+	0xa4, 0x2b, 0xf4, 0xfa, 0x84, 0xd7, 0xb6, 0xff, 0x08, 0xb6, 0xe6, 0x59, 0x1a, 0xc9, 0x15, 0x9b,
+	0x82, 0xf3, 0x16, 0x37, 0x14, 0xdb, 0xe5, 0xe6, 0x68, 0x22, 0x9f, 0x8b, 0x78, 0x8d, 0x14, 0xd9,
+	0xe5, 0xd6, 0xf0, 0xbf, 0x85, 0xad, 0x43, 0x3c, 0x97, 0x01, 0x52, 0x2c, 0x91, 0x60, 0xf9, 0x09,
+	0x9d, 0xd9, 0x03, 0xd8, 0x0a, 0x48, 0xcf, 0xeb, 0xce, 0x9c, 0xdd, 0xd1, 0xc1, 0xcd, 0xfd, 0xfa,
+	0xb2, 0xfb, 0x36, 0x10, 0x2f, 0x09, 0xfe, 0x1f, 0x5d, 0x18, 0x2e, 0x52, 0x91, 0xeb, 0xb3, 0xac,
+	0x78, 0xaf, 0xd6, 0x63, 0x18, 0xc5, 0x59, 0x20, 0xe2, 0xf9, 0xbf, 0x08, 0x36, 0x59, 0xe6, 0xb2,
+	0xb9, 0xca, 0x22, 0x19, 0xa3, 0xf6, 0x9c, 0x99, 0xb3, 0xeb, 0xf2, 0xda, 0x66, 0x1f, 0x82, 0x8b,
+	0xf9, 0x19, 0x26, 0xa8, 0x44, 0x4c, 0x15, 0x1a, 0xf2, 0x2b, 0x80, 0x7d, 0x09, 0x63, 0x12, 0xb2,
+	0xb7, 0xd3, 0x5e, 0xff, 0x6f, 0xf1, 0xac, 0x87, 0xb7, 0x68, 0xcc, 0x87, 0xb1, 0x50, 0xc1, 0x99,
+	0x2c, 0x30, 0x28, 0xd6, 0x0a, 0xbd, 0x2d, 0xaa, 0x70, 0x0b, 0x33, 0x49, 0xe9, 0x42, 0x14, 0x18,
+	0xad, 0x63, 0x6f, 0x40, 0x71, 0x6b, 0x9b, 0xdd, 0x83, 0x49, 0xa0, 0x90, 0x02, 0x2c, 0x43, 0x51,
+	0xa0, 0x37, 0x9c, 0x75, 0x76, 0x1d, 0x3e, 0xae, 0xc0, 0x43, 0x51, 0x20, 0xbb, 0x0f, 0xdb, 0xb1,
+	0xd0, 0xc5, 0x72, 0xad, 0x31, 0xb4, 0x2c, 0xd7, 0xb2, 0x0c, 0x7a, 0xaa, 0x31, 0x34, 0x2c, 0xff,
+	0xd7, 0x0e, 0x4c, 0x94, 0xde, 0xa4, 0xc1, 0x11, 0x0a, 0x13, 0x57, 0x9b, 0x31, 0x79, 0x27, 0x8a,
+	0x42, 0x69, 0xaf, 0x33, 0xeb, 0xec, 0x0e, 0x79, 0x69, 0x19, 0x3c, 0xc4, 0x18, 0x0b, 0xd3, 0x5b,
+	0xc2, 0xad, 0x65, 0x12, 0x0d, 0xb2, 0x24, 0x57, 0xa8, 0x4d, 0xf5, 0x8c, 0xa7, 0xb6, 0xd9, 0x7d,
+	0x98, 0xbc, 0x91, 0xa1, 0x54, 0x18, 0x98, 0xb4, 0xa8, 0x82, 0x86, 0xd0, 0x06, 0xfd, 0x07, 0x30,
+	0xba, 0x8c, 0x74, 0x9d, 0x40, 0x53, 0xb0, 0xd3, 0x16, 0xf4, 0x7f, 0x77, 0xe0, 0xc6, 0xab, 0xaa,
+	0xb8, 0xcf, 0x51, 0x84, 0xa8, 0xd8, 0x1e, 0x74, 0x23, 0x4d, 0x53, 0xb0, 0x7d, 0x70, 0xa7, 0x51,
+	0xfa, 0x9a, 0x77, 0xb4, 0x30, 0x6f, 0x85, 0x77, 0x23, 0xcd, 0x3e, 0x87, 0x5e, 0xa0, 0xe4, 0x9a,
+	0xae, 0xb0, 0x7d, 0x70, 0xab, 0x39, 0x18, 0xfc, 0xc5, 0x29, 0xd1, 0x88, 0xc0, 0xf6, 0xa0, 0x2f,
+	0xc3, 0x44, 0xe4, 0x34, 0x10, 0xa3, 0x83, 0x9d, 0x06, 0xb3, 0x7e, 0x7d, 0xdc, 0x52, 0xcc, 0x2d,
+	0x75, 0x39, 0x94, 0xc7, 0x22, 0x41, 0xed, 0xf5, 0x68, 0x88, 0xda, 0x20, 0xfb, 0x02, 0xdc, 0x0a,
+	0xa8, 0x06, 0xa5, 0x19, 0xbf, 0x1a, 0x6b, 0x7e, 0xc5, 0x62, 0x1e, 0x0c, 0x72, 0x85, 0xe1, 0x3a,
+	0xc9, 0xbd, 0x01, 0x15, 0xa2, 0x32, 0xd9, 0xd7, 0xd7, 0xba, 0x46, 0x13, 0x30, 0x3a, 0xf0, 0x1a,
+	0x82, 0x2d, 0x3f, 0xbf, 0xd6, 0x64, 0x0f, 0x06, 0x0a, 0x23, 0x85, 0xfa, 0x8c, 0xa6, 0x62, 0xc8,
+	0x2b, 0x93, 0x3d, 0x69, 0x35, 0xc3, 0x03, 0xd2, 0xbd, 0xdd, 0xd0, 0x6d, 0x78, 0x79, 0xab, 0x6f,
+	0x3b, 0xd0, 0xd7, 0x4a, 0x66, 0xe7, 0xde, 0x88, 0x14, 0xad, 0xe1, 0x1f, 0xc1, 0xb4, 0x6e, 0xc4,
+	0x3c, 0x4b, 0x0b, 0x95, 0xc5, 0x26, 0xba, 0x5e, 0x07, 0x81, 0x6d, 0xb0, 0x19, 0xed, 0xca, 0x34,
+	0x9e, 0x04, 0xb5, 0x16, 0x2b, 0x3b, 0x65, 0x2e, 0xaf, 0x4c, 0xff, 0x31, 0x4c, 0x6a, 0x9d, 0xc5,
+	0x26, 0x0d, 0xcc, 0x23, 0x8a, 0x64, 0x2a, 0xe2, 0x13, 0x85, 0x87, 0xa6, 0x42, 0x56, 0xa9, 0x85,
+	0xf9, 0xa7, 0x30, 0xa2, 0x2c, 0xfe, 0x61, 0xfb, 0x98, 0xad, 0x78, 0x21, 0xc2, 0x50, 0x95, 0x01,
+	0x4b, 0xcb, 0x4c, 0xa1, 0xd9, 0x8f, 0xa6, 0x77, 0x34, 0xd6, 0x2e, 0xaf, 0x6d, 0xff, 0x3b, 0xb8,
+	0x7d, 0x95, 0x8b, 0xd1, 0x7f, 0x1a, 0x9b, 0xf7, 0x5d, 0x20, 0x7b, 0x04, 0x83, 0xb0, 0xdc, 0x05,
+	0x9d, 0x99, 0x73, 0xad, 0x72, 0x8d, 0x54, 0x78, 0x45, 0xf3, 0x7f, 0xe9, 0x80, 0xf7, 0x5e, 0x31,
+	0x99, 0xa5, 0xff, 0xa7, 0x50, 0xcd, 0x14, 0x9c, 0xff, 0x96, 0xc2, 0x3e, 0xec, 0xb4, 0x33, 0x98,
+	0x67, 0x49, 0x22, 0x0b, 0x53, 0x9a, 0x80, 0x4e, 0x65, 0xf0, 0xd2, 0xf2, 0x7f, 0x73, 0x60, 0x6a,
+	0xa6, 0x70, 0x69, 0x16, 0x92, 0x5e, 0x62, 0x5a, 0xa8, 0x8d, 0xd9, 0x49, 0x91, 0x42, 0xbc, 0x94,
+	0xe9, 0x6a, 0x59, 0xc8, 0xb2, 0xc8, 0x13, 0x3e, 0xae, 0xc0, 0xd7, 0x32, 0x41, 0xf6, 0x09, 0x8c,
+	0x22, 0x95, 0x5d, 0x62, 0x6a, 0x29, 0x5d, 0xa2, 0x80, 0x85, 0x88, 0xf0, 0x29, 0x8c, 0x13, 0x4c,
+	0x48, 0x9c, 0x18, 0x0e, 0x31, 0x46, 0x25, 0x46, 0x94, 0x7b, 0x30, 0x49, 0x30, 0xb9, 0x50, 0xb2,
+	0x40, 0xcb, 0xe9, 0xd9, 0x40, 0x15, 0x58, 0x91, 0x72, 0xb1, 0x42, 0xbd, 0xd4, 0x81, 0x48, 0x53,
+	0x0c, 0xe9, 0x47, 0xac, 0xc7, 0xc7, 0x04, 0x2e, 0x2c, 0xc6, 0x1e, 0xc1, 0x4e, 0x49, 0x7a, 0x2b,
+	0xf3, 0x1c, 0xc3, 0x65, 0x2e, 0x14, 0xa6, 0x05, 0xad, 0xe3, 0x1e, 0x67, 0x96, 0x6b, 0x5d, 0x27,
+	0xe4, 0xb9, 0x92, 0x35, 0x91, 0x0a, 0x4c, 0xbd, 0x41, 0x43, 0xf6, 0x07, 0x8b, 0x19, 0x92, 0x54,
+	0x89, 0xc8, 0x97, 0x0a, 0x75, 0x16, 0x9f, 0xdb, 0xed, 0x3c, 0xe1, 0x63, 0x02, 0xb9, 0xc5, 0xd8,
+	0x47, 0x00, 0x56, 0x29, 0x16, 0x97, 0x1b, 0xcf, 0x25, 0x19, 0x97, 0x90, 0x97, 0xe2, 0x72, 0x53,
+	0xb9, 0x97, 0xb9, 0xcc, 0xcb, 0x47, 0x58, 0xba, 0x4f, 0x0c, 0x60, 0x76, 0x7b, 0xed, 0x5e, 0xbe,
+	0x59, 0x47, 0x9a, 0xde, 0x5c, 0x99, 0x88, 0xa1, 0x3c, 0x5b, 0x47, 0xda, 0xff, 0xb3, 0x03, 0xb7,
+	0x14, 0xea, 0x22, 0x53, 0xd8, 0x6a, 0xd5, 0x67, 0xf6, 0x6b, 0xbd, 0x34, 0x6b, 0x55, 0x28, 0xb4,
+	0xff, 0x1e, 0x7a, 0xdc, 0xde, 0x6d, 0x5e, 0x82, 0x6c, 0x0f, 0x6e, 0xb6, 0xcb, 0x13, 0x64, 0x17,
+	0xd4, 0xb2, 0x1e, 0xbf, 0xd1, 0xac, 0xcd, 0x3c, 0xbb, 0x30, 0x7d, 0x8b, 0x32, 0xf5, 0xb6, 0x6e,
+	0x7e, 0xd9, 0xb7, 0x12, 0xab, 0x5a, 0x5b, 0x25, 0xd3, 0x68, 0xdb, 0xa8, 0xc4, 0x88, 0x52, 0x27,
+	0x56, 0x82, 0xa6, 0x6d, 0x9d, 0x3a, 0x31, 0x5e, 0x82, 0xfe, 0x3b, 0x18, 0x35, 0xaf, 0xf3, 0x10,
+	0x7a, 0xa1, 0x5d, 0x00, 0x66, 0x55, 0xdd, 0x6d, 0x4c, 0xfb, 0xf5, 0x21, 0xe5, 0x44, 0x64, 0x4f,
+	0xcc, 0xf2, 0x23, 0x2d, 0x7a, 0x3b, 0xa3, 0x83, 0x8f, 0x1b, 0xdf, 0xbc, 0xa7, 0x60, 0xbc, 0xa2,
+	0xef, 0x7d, 0x05, 0x37, 0xae, 0xfd, 0xaa, 0x30, 0x17, 0xfa, 0x7c, 0xf1, 0xe3, 0xf1, 0x7c, 0xfa,
+	0x81, 0x39, 0x3e, 0x7b, 0xcd, 0x8f, 0x16, 0xd3, 0x0e, 0x1b, 0x80, 0xf3, 0xd3, 0xd1, 0x62, 0xda,
+	0x35, 0x07, 0xfe, 0xec, 0x70, 0xea, 0xec, 0x3d, 0x84, 0x61, 0xf5, 0x13, 0xc3, 0xb6, 0x01, 0xcc,
+	0x79, 0xd9, 0xf8, 0xf0, 0xe4, 0xf9, 0xd3, 0xd3, 0x97, 0xd3, 0x0e, 0x1b, 0x42, 0xef, 0xf8, 0xfb,
+	0xe3, 0x6f, 0xa6, 0xdd, 0xbf, 0x06, 0x00, 0xff, 0xc3, 0xe4, 0xfc, 0x10, 0x0a, 0x00, 0x00,
 }
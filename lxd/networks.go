@@ -353,7 +353,7 @@ func networkGet(d *Daemon, r *http.Request) Response {
 
 	n, err := doNetworkGet(d, name)
 	if err != nil {
-		return SmartError(err)
+		return SmartError(withNotFoundCode(api.ErrNetworkNotFound, err))
 	}
 
 	targetNode := queryParam(r, "target")
@@ -550,6 +550,12 @@ func networkPost(d *Daemon, r *http.Request) Response {
 		return BadRequest(err)
 	}
 
+	// The FAN and BGP EVPN modes limit the length of the name
+	err = networkValidateConfig(req.Name, n.config)
+	if err != nil {
+		return BadRequest(err)
+	}
+
 	// Check that the name isn't already in use
 	networks, err := networkGetInterfaces(d.cluster)
 	if err != nil {
@@ -1064,6 +1070,8 @@ func (n *network) Start() error {
 		} else {
 			mtu = "1450"
 		}
+	} else if n.config["bridge.mode"] == "bgp-evpn" {
+		mtu = "1450"
 	}
 
 	// Attempt to add a dummy device to the bridge to force the MTU
@@ -1719,6 +1727,20 @@ func (n *network) Start() error {
 		}
 	}
 
+	// Configure the BGP EVPN overlay
+	if n.config["bridge.mode"] == "bgp-evpn" {
+		err = n.evpnStart(mtu)
+		if err != nil {
+			return err
+		}
+	}
+
+	// Set up the port forwards
+	n.forwardsApply()
+
+	// Start balancing the traffic to the load balancer backends
+	n.loadBalancersStart()
+
 	// Kill any existing dnsmasq and forkdns daemon for this network
 	err = networkKillDnsmasq(n.name, false)
 	if err != nil {
@@ -1856,6 +1878,15 @@ func (n *network) Stop() error {
 		return err
 	}
 
+	// Stop the BGP speaker
+	err = n.evpnStop()
+	if err != nil {
+		return err
+	}
+
+	// Remove the load balancers
+	n.loadBalancersStop()
+
 	// Get a list of interfaces
 	ifaces, err := net.Interfaces()
 	if err != nil {
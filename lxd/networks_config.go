@@ -29,7 +29,45 @@ var networkConfigKeys = map[string]func(value string) error{
 	"bridge.hwaddr": shared.IsAny,
 	"bridge.mtu":    shared.IsInt64,
 	"bridge.mode": func(value string) error {
-		return shared.IsOneOf(value, []string{"standard", "fan"})
+		return shared.IsOneOf(value, []string{"standard", "fan", "bgp-evpn"})
+	},
+
+	"evpn.asn":       shared.IsUint32,
+	"evpn.interface": networkValidName,
+	"evpn.local":     networkValidAddressV4,
+	"evpn.peers":     evpnValidatePeers,
+	"evpn.port":      networkValidPort,
+	"evpn.route_target": func(value string) error {
+		if value == "" {
+			return nil
+		}
+
+		fields := strings.Split(value, ":")
+		if len(fields) != 2 {
+			return fmt.Errorf("Invalid route target '%s', must be <asn>:<number>", value)
+		}
+
+		for _, field := range fields {
+			_, err := strconv.ParseUint(field, 10, 32)
+			if err != nil {
+				return fmt.Errorf("Invalid route target '%s', must be <asn>:<number>", value)
+			}
+		}
+
+		return nil
+	},
+	"evpn.router_id": networkValidAddressV4,
+	"evpn.vni": func(value string) error {
+		if value == "" {
+			return nil
+		}
+
+		vni, err := strconv.ParseUint(value, 10, 32)
+		if err != nil || vni < 1 || vni > 16777215 {
+			return fmt.Errorf("Invalid VNI '%s', must be between 1 and 16777215", value)
+		}
+
+		return nil
 	},
 
 	"fan.overlay_subnet": networkValidNetworkV4,
@@ -110,6 +148,20 @@ func networkValidateConfig(name string, config map[string]string) error {
 		return fmt.Errorf("Network name too long to use with the FAN (must be 11 characters or less)")
 	}
 
+	if bridgeMode == "bgp-evpn" {
+		if len(name) > 10 {
+			return fmt.Errorf("Network name too long to use with BGP EVPN (must be 10 characters or less)")
+		}
+
+		if config["evpn.asn"] == "" || config["evpn.vni"] == "" {
+			return fmt.Errorf("evpn.asn and evpn.vni must be set when in 'bgp-evpn' mode")
+		}
+
+		if config["bridge.driver"] == "openvswitch" {
+			return fmt.Errorf("BGP EVPN isn't supported with Open vSwitch bridges")
+		}
+	}
+
 	for k, v := range config {
 		key := k
 
@@ -156,6 +208,10 @@ func networkValidateConfig(name string, config map[string]string) error {
 			return fmt.Errorf("FAN configuration may only be set when in 'fan' mode")
 		}
 
+		if bridgeMode != "bgp-evpn" && strings.HasPrefix(key, "evpn.") && v != "" {
+			return fmt.Errorf("EVPN configuration may only be set when in 'bgp-evpn' mode")
+		}
+
 		// MTU checks
 		if key == "bridge.mtu" && v != "" {
 			mtu, err := strconv.ParseInt(v, 10, 64)
@@ -173,6 +229,10 @@ func networkValidateConfig(name string, config map[string]string) error {
 				return fmt.Errorf("The minimum MTU for an IPv4 network is 68")
 			}
 
+			if config["bridge.mode"] == "bgp-evpn" && mtu > 1450 {
+				return fmt.Errorf("Maximum MTU for a BGP EVPN bridge is 1450")
+			}
+
 			if config["bridge.mode"] == "fan" {
 				if config["fan.type"] == "ipip" {
 					if mtu > 1480 {
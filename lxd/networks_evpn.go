@@ -0,0 +1,619 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/lxc/lxd/shared"
+	"github.com/lxc/lxd/shared/logger"
+
+	log "github.com/lxc/lxd/shared/log15"
+)
+
+// Port of the gobgpd API of a network, offset by the network ID
+const evpnAPIPortBase = 50100
+
+// Default UDP port of the VXLAN data plane
+const evpnDefaultPort = "4789"
+
+// How often the BGP routes are reconciled with the containers and the FDB
+const evpnSyncInterval = 10 * time.Second
+
+// evpnSyncer keeps the routes of a BGP EVPN network in sync.
+type evpnSyncer struct {
+	notify chan struct{}
+	stop   chan struct{}
+
+	// Routes advertised for the local containers, keyed by their arguments
+	advertised map[string][]string
+
+	// FDB entries of the remote MAC addresses and VTEPs
+	macs  map[string]string
+	vteps map[string]bool
+}
+
+var evpnSyncersLock sync.Mutex
+var evpnSyncers = map[string]*evpnSyncer{}
+
+// evpnValidatePeers validates a comma separated list of <address>:<asn> BGP
+// peers.
+func evpnValidatePeers(value string) error {
+	_, err := evpnParsePeers(value)
+	return err
+}
+
+// evpnParsePeers parses the evpn.peers network configuration key into a map
+// of peer addresses to AS numbers.
+func evpnParsePeers(value string) (map[string]uint32, error) {
+	peers := map[string]uint32{}
+	if value == "" {
+		return peers, nil
+	}
+
+	for _, entry := range strings.Split(value, ",") {
+		entry = strings.TrimSpace(entry)
+		idx := strings.LastIndex(entry, ":")
+		if idx < 0 {
+			return nil, fmt.Errorf("Invalid BGP peer '%s', must be <address>:<asn>", entry)
+		}
+
+		address := strings.Trim(entry[:idx], "[]")
+		if net.ParseIP(address) == nil {
+			return nil, fmt.Errorf("Invalid BGP peer address '%s'", address)
+		}
+
+		asn, err := strconv.ParseUint(entry[idx+1:], 10, 32)
+		if err != nil {
+			return nil, fmt.Errorf("Invalid BGP peer AS number '%s'", entry[idx+1:])
+		}
+
+		peers[address] = uint32(asn)
+	}
+
+	return peers, nil
+}
+
+// evpnLocalAddress returns the underlay address used as the VXLAN tunnel
+// endpoint, defaulting to the address of the default gateway interface.
+func (n *network) evpnLocalAddress() (string, string, error) {
+	devName := n.config["evpn.interface"]
+	if devName == "" {
+		var err error
+		_, devName, err = networkDefaultGatewaySubnetV4()
+		if err != nil {
+			return "", "", err
+		}
+	}
+
+	if n.config["evpn.local"] != "" {
+		return n.config["evpn.local"], devName, nil
+	}
+
+	iface, err := net.InterfaceByName(devName)
+	if err != nil {
+		return "", "", err
+	}
+
+	addrs, err := iface.Addrs()
+	if err != nil {
+		return "", "", err
+	}
+
+	for _, addr := range addrs {
+		ip, _, err := net.ParseCIDR(addr.String())
+		if err == nil && ip.To4() != nil && ip.IsGlobalUnicast() {
+			return ip.String(), devName, nil
+		}
+	}
+
+	return "", "", fmt.Errorf("No IPv4 address found on underlay interface '%s'", devName)
+}
+
+func (n *network) evpnAPIPort() string {
+	return fmt.Sprintf("%d", evpnAPIPortBase+n.id)
+}
+
+// evpnRouteDistinguisher and evpnRouteTarget return the RD and RT of the
+// routes of the network.
+func (n *network) evpnRouteDistinguisher(routerID string) string {
+	return fmt.Sprintf("%s:%s", routerID, n.config["evpn.vni"])
+}
+
+func (n *network) evpnRouteTarget() string {
+	if n.config["evpn.route_target"] != "" {
+		return n.config["evpn.route_target"]
+	}
+
+	return fmt.Sprintf("%s:%s", n.config["evpn.asn"], n.config["evpn.vni"])
+}
+
+// evpnConfig renders the gobgpd configuration of the network.
+func (n *network) evpnConfig(routerID string) (string, error) {
+	peers, err := evpnParsePeers(n.config["evpn.peers"])
+	if err != nil {
+		return "", err
+	}
+
+	var b bytes.Buffer
+	fmt.Fprintf(&b, "[global.config]\n  as = %s\n  router-id = %q\n  # Only initiate the sessions so that several networks can share the host\n  port = -1\n", n.config["evpn.asn"], routerID)
+
+	for address, asn := range peers {
+		fmt.Fprintf(&b, "\n[[neighbors]]\n  [neighbors.config]\n    neighbor-address = %q\n    peer-as = %d\n", address, asn)
+		fmt.Fprintf(&b, "  [[neighbors.afi-safis]]\n    [neighbors.afi-safis.config]\n      afi-safi-name = \"l2vpn-evpn\"\n")
+	}
+
+	return b.String(), nil
+}
+
+// evpnTunnelName returns the name of the VXLAN interface of the network.
+func (n *network) evpnTunnelName() (string, error) {
+	tunName := fmt.Sprintf("%s-evpn", n.name)
+	if len(tunName) > 15 {
+		return "", fmt.Errorf("Network name too long to use with BGP EVPN (must be 10 characters or less)")
+	}
+
+	return tunName, nil
+}
+
+// evpnStart creates the VXLAN interface of the network and starts its BGP
+// speaker.
+func (n *network) evpnStart(mtu string) error {
+	_, err := exec.LookPath("gobgpd")
+	if err != nil {
+		return fmt.Errorf("gobgpd is required for BGP EVPN bridges")
+	}
+
+	_, err = exec.LookPath("gobgp")
+	if err != nil {
+		return fmt.Errorf("gobgp is required for BGP EVPN bridges")
+	}
+
+	local, devName, err := n.evpnLocalAddress()
+	if err != nil {
+		return err
+	}
+
+	port := n.config["evpn.port"]
+	if port == "" {
+		port = evpnDefaultPort
+	}
+
+	tunName, err := n.evpnTunnelName()
+	if err != nil {
+		return err
+	}
+
+	// The remote MAC addresses are learned over BGP rather than from the
+	// traffic, so that unknown unicast isn't flooded
+	_, err = shared.RunCommand("ip", "link", "add", tunName, "type", "vxlan", "id", n.config["evpn.vni"], "dev", devName, "local", local, "dstport", port, "nolearning")
+	if err != nil {
+		return err
+	}
+
+	err = networkAttachInterface(n.name, tunName)
+	if err != nil {
+		return err
+	}
+
+	_, err = shared.RunCommand("ip", "link", "set", "dev", tunName, "mtu", mtu, "up")
+	if err != nil {
+		return err
+	}
+
+	// Suppress the ARP and ND broadcasts of the known neighbours
+	shared.RunCommand("bridge", "link", "set", "dev", tunName, "neigh_suppress", "on")
+
+	routerID := n.config["evpn.router_id"]
+	if routerID == "" {
+		routerID = local
+	}
+
+	config, err := n.evpnConfig(routerID)
+	if err != nil {
+		return err
+	}
+
+	configPath := shared.VarPath("networks", n.name, "gobgpd.conf")
+	err = ioutil.WriteFile(configPath, []byte(config), 0600)
+	if err != nil {
+		return err
+	}
+
+	err = networkKillBGP(n.name)
+	if err != nil {
+		return err
+	}
+
+	// Spawn the daemon
+	cmd := exec.Cmd{}
+	cmd.Path, _ = exec.LookPath("gobgpd")
+	cmd.Args = []string{"gobgpd", "-t", "toml", "-f", configPath, "--api-hosts", fmt.Sprintf("127.0.0.1:%s", n.evpnAPIPort()), "--disable-stdlog"}
+
+	err = cmd.Start()
+	if err != nil {
+		return fmt.Errorf("Failed to start gobgpd for network '%s': %v", n.name, err)
+	}
+
+	// Write the PID file
+	pidPath := shared.VarPath("networks", n.name, "gobgpd.pid")
+	err = ioutil.WriteFile(pidPath, []byte(fmt.Sprintf("%d\n", cmd.Process.Pid)), 0600)
+	if err != nil {
+		syscall.Kill(cmd.Process.Pid, syscall.SIGKILL)
+		return fmt.Errorf("Failed to start gobgpd for network '%s': %v", n.name, err)
+	}
+
+	// Don't leave a zombie behind once it exits
+	go cmd.Wait()
+
+	syncer := &evpnSyncer{
+		notify:     make(chan struct{}, 1),
+		stop:       make(chan struct{}),
+		advertised: map[string][]string{},
+		macs:       map[string]string{},
+		vteps:      map[string]bool{},
+	}
+
+	evpnSyncersLock.Lock()
+	old, ok := evpnSyncers[n.name]
+	if ok {
+		close(old.stop)
+	}
+	evpnSyncers[n.name] = syncer
+	evpnSyncersLock.Unlock()
+
+	go n.evpnRun(syncer, local, routerID)
+
+	return nil
+}
+
+// evpnStop stops the BGP speaker of the network. The VXLAN interface is
+// removed along with the other tunnels.
+func (n *network) evpnStop() error {
+	evpnSyncersLock.Lock()
+	syncer, ok := evpnSyncers[n.name]
+	if ok {
+		close(syncer.stop)
+		delete(evpnSyncers, n.name)
+	}
+	evpnSyncersLock.Unlock()
+
+	return networkKillBGP(n.name)
+}
+
+// evpnNotify triggers an immediate sync of the BGP EVPN networks the
+// container is bridged to.
+func evpnNotify(c container) {
+	evpnSyncersLock.Lock()
+	defer evpnSyncersLock.Unlock()
+
+	for _, m := range c.ExpandedDevices() {
+		if m["type"] != "nic" || m["nictype"] != "bridged" {
+			continue
+		}
+
+		syncer, ok := evpnSyncers[m["parent"]]
+		if !ok {
+			continue
+		}
+
+		select {
+		case syncer.notify <- struct{}{}:
+		default:
+		}
+	}
+}
+
+func (n *network) evpnRun(syncer *evpnSyncer, local string, routerID string) {
+	for {
+		err := n.evpnSync(syncer, local, routerID)
+		if err != nil {
+			logger.Debug("Failed to sync BGP EVPN routes", log.Ctx{"network": n.name, "err": err})
+		}
+
+		select {
+		case <-syncer.stop:
+			return
+		case <-syncer.notify:
+		case <-time.After(evpnSyncInterval):
+		}
+	}
+}
+
+// gobgp runs a gobgp command against the BGP speaker of the network.
+func (n *network) gobgp(args ...string) (string, error) {
+	return shared.RunCommand("gobgp", append([]string{"-u", "127.0.0.1", "-p", n.evpnAPIPort()}, args...)...)
+}
+
+// evpnRoutes returns the routes to advertise for the network: the VTEP
+// (type 3), the subnet of the bridge (type 5) and the MAC and IP addresses of
+// the local containers (type 2).
+func (n *network) evpnRoutes(local string, routerID string) (map[string][]string, error) {
+	vni := n.config["evpn.vni"]
+	rd := n.evpnRouteDistinguisher(routerID)
+	rt := n.evpnRouteTarget()
+
+	routes := map[string][]string{}
+	add := func(args ...string) {
+		routes[strings.Join(args, " ")] = args
+	}
+
+	add("multicast", local, "etag", "0", "rd", rd, "rt", rt, "encap", "vxlan")
+
+	if !shared.StringInSlice(n.config["ipv4.address"], []string{"", "none"}) {
+		ip, subnet, err := net.ParseCIDR(n.config["ipv4.address"])
+		if err != nil {
+			return nil, err
+		}
+
+		add("prefix", subnet.String(), "gw", ip.String(), "etag", "0", "label", vni, "rd", rd, "rt", rt, "encap", "vxlan")
+	}
+
+	leases := evpnLeases(n.name)
+
+	containers, err := containerLoadNodeAll(n.state)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, c := range containers {
+		if !c.IsRunning() {
+			continue
+		}
+
+		for name, m := range c.ExpandedDevices() {
+			if m["type"] != "nic" || m["nictype"] != "bridged" || m["parent"] != n.name {
+				continue
+			}
+
+			hwaddr := m["hwaddr"]
+			if hwaddr == "" {
+				hwaddr = c.LocalConfig()[fmt.Sprintf("volatile.%s.hwaddr", name)]
+			}
+
+			if hwaddr == "" {
+				continue
+			}
+
+			ip := m["ipv4.address"]
+			if ip == "" {
+				ip = leases[strings.ToLower(hwaddr)]
+			}
+
+			if ip == "" {
+				add("macadv", hwaddr, "etag", "0", "label", vni, "rd", rd, "rt", rt, "encap", "vxlan")
+			} else {
+				add("macadv", hwaddr, ip, "etag", "0", "label", vni, "rd", rd, "rt", rt, "encap", "vxlan")
+			}
+		}
+	}
+
+	return routes, nil
+}
+
+// evpnLeases returns the IPv4 addresses leased by dnsmasq, keyed by MAC
+// address.
+func evpnLeases(name string) map[string]string {
+	leases := map[string]string{}
+
+	f, err := os.Open(shared.VarPath("networks", name, "dnsmasq.leases"))
+	if err != nil {
+		return leases
+	}
+	defer f.Close()
+
+	scan := bufio.NewScanner(f)
+	for scan.Scan() {
+		fields := strings.Fields(scan.Text())
+		if len(fields) < 3 || net.ParseIP(fields[2]).To4() == nil {
+			continue
+		}
+
+		leases[strings.ToLower(fields[1])] = fields[2]
+	}
+
+	return leases
+}
+
+// evpnPath is a route of the gobgp RIB as rendered in JSON.
+type evpnPath struct {
+	Attrs []struct {
+		NextHop string `json:"nexthop"`
+	} `json:"attrs"`
+}
+
+var evpnNLRIField = regexp.MustCompile(`\[([a-z-]+):([^\]]*)\]`)
+
+// evpnParseRIB returns the MAC addresses and VTEPs advertised by the remote
+// endpoints, given the JSON RIB of gobgp.
+func evpnParseRIB(content []byte, local string) (map[string]string, map[string]bool, error) {
+	rib := map[string][]evpnPath{}
+	err := json.Unmarshal(content, &rib)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	macs := map[string]string{}
+	vteps := map[string]bool{}
+	for nlri, paths := range rib {
+		fields := map[string]string{}
+		for _, match := range evpnNLRIField.FindAllStringSubmatch(nlri, -1) {
+			fields[match[1]] = match[2]
+		}
+
+		for _, path := range paths {
+			nextHop := ""
+			for _, attr := range path.Attrs {
+				if attr.NextHop != "" {
+					nextHop = attr.NextHop
+				}
+			}
+
+			if nextHop == "" || nextHop == local || nextHop == "0.0.0.0" {
+				continue
+			}
+
+			switch fields["type"] {
+			case "macadv":
+				if fields["mac"] != "" {
+					macs[strings.ToLower(fields["mac"])] = nextHop
+				}
+			case "multicast":
+				vteps[nextHop] = true
+			}
+		}
+	}
+
+	return macs, vteps, nil
+}
+
+// evpnSync advertises the routes of the local containers and programs the
+// FDB of the VXLAN interface with the remote ones.
+func (n *network) evpnSync(syncer *evpnSyncer, local string, routerID string) error {
+	routes, err := n.evpnRoutes(local, routerID)
+	if err != nil {
+		return err
+	}
+
+	for key, args := range syncer.advertised {
+		_, ok := routes[key]
+		if ok {
+			continue
+		}
+
+		_, err := n.gobgp(append([]string{"global", "rib", "-a", "evpn", "del"}, args...)...)
+		if err != nil {
+			return err
+		}
+
+		delete(syncer.advertised, key)
+	}
+
+	for key, args := range routes {
+		_, ok := syncer.advertised[key]
+		if ok {
+			continue
+		}
+
+		_, err := n.gobgp(append([]string{"global", "rib", "-a", "evpn", "add"}, args...)...)
+		if err != nil {
+			return err
+		}
+
+		syncer.advertised[key] = args
+	}
+
+	out, err := n.gobgp("global", "rib", "-a", "evpn", "-j")
+	if err != nil {
+		return err
+	}
+
+	macs, vteps, err := evpnParseRIB([]byte(out), local)
+	if err != nil {
+		return err
+	}
+
+	tunName, err := n.evpnTunnelName()
+	if err != nil {
+		return err
+	}
+
+	for mac := range syncer.macs {
+		_, ok := macs[mac]
+		if !ok {
+			shared.RunCommand("bridge", "fdb", "del", mac, "dev", tunName, "self")
+			delete(syncer.macs, mac)
+		}
+	}
+
+	for mac, nextHop := range macs {
+		if syncer.macs[mac] == nextHop {
+			continue
+		}
+
+		_, err := shared.RunCommand("bridge", "fdb", "replace", mac, "dev", tunName, "dst", nextHop, "self", "permanent")
+		if err != nil {
+			return err
+		}
+
+		syncer.macs[mac] = nextHop
+	}
+
+	// Broadcast and unknown traffic is sent to all the remote VTEPs
+	for vtep := range syncer.vteps {
+		if !vteps[vtep] {
+			shared.RunCommand("bridge", "fdb", "del", "00:00:00:00:00:00", "dev", tunName, "dst", vtep, "self")
+			delete(syncer.vteps, vtep)
+		}
+	}
+
+	for vtep := range vteps {
+		if syncer.vteps[vtep] {
+			continue
+		}
+
+		_, err := shared.RunCommand("bridge", "fdb", "append", "00:00:00:00:00:00", "dev", tunName, "dst", vtep, "self", "permanent")
+		if err != nil {
+			return err
+		}
+
+		syncer.vteps[vtep] = true
+	}
+
+	return nil
+}
+
+func networkKillBGP(name string) error {
+	// Check if we have a running gobgpd at all
+	pidPath := shared.VarPath("networks", name, "gobgpd.pid")
+	if !shared.PathExists(pidPath) {
+		return nil
+	}
+
+	// Grab the PID
+	content, err := ioutil.ReadFile(pidPath)
+	if err != nil {
+		return err
+	}
+
+	pid, err := strconv.Atoi(strings.TrimSpace(string(content)))
+	if err != nil {
+		os.Remove(pidPath)
+		return nil
+	}
+
+	// Check if it's gobgpd
+	cmdArgs, err := ioutil.ReadFile(fmt.Sprintf("/proc/%d/cmdline", pid))
+	if err != nil {
+		os.Remove(pidPath)
+		return nil
+	}
+
+	cmdFields := strings.Split(string(bytes.TrimRight(cmdArgs, string("\x00"))), string(byte(0)))
+	if len(cmdFields) < 1 || filepath.Base(cmdFields[0]) != "gobgpd" {
+		os.Remove(pidPath)
+		return nil
+	}
+
+	// Actually kill the process
+	err = syscall.Kill(pid, syscall.SIGKILL)
+	if err != nil {
+		return err
+	}
+
+	// Cleanup
+	os.Remove(pidPath)
+	return nil
+}
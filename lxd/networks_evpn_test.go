@@ -0,0 +1,33 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestEVPNParsePeers(t *testing.T) {
+	peers, err := evpnParsePeers("10.0.0.1:65001, [fd00::1]:65002")
+	require.NoError(t, err)
+	assert.Equal(t, map[string]uint32{"10.0.0.1": 65001, "fd00::1": 65002}, peers)
+
+	_, err = evpnParsePeers("10.0.0.1")
+	assert.Error(t, err)
+
+	_, err = evpnParsePeers("switch:65001")
+	assert.Error(t, err)
+}
+
+func TestEVPNParseRIB(t *testing.T) {
+	rib := `{
+  "[type:macadv][rd:10.0.0.2:100][etag:0][mac:00:16:3E:AA:BB:CC][ip:10.1.0.5]": [{"attrs": [{"type": 14, "nexthop": "10.0.0.2"}]}],
+  "[type:macadv][rd:10.0.0.1:100][etag:0][mac:00:16:3e:11:22:33][ip:10.1.0.6]": [{"attrs": [{"type": 14, "nexthop": "10.0.0.1"}]}],
+  "[type:multicast][rd:10.0.0.2:100][etag:0][ip:10.0.0.2]": [{"attrs": [{"type": 14, "nexthop": "10.0.0.2"}]}]
+}`
+
+	macs, vteps, err := evpnParseRIB([]byte(rib), "10.0.0.1")
+	require.NoError(t, err)
+	assert.Equal(t, map[string]string{"00:16:3e:aa:bb:cc": "10.0.0.2"}, macs)
+	assert.Equal(t, map[string]bool{"10.0.0.2": true}, vteps)
+}
@@ -0,0 +1,409 @@
+package main
+
+import (
+	"fmt"
+	"net"
+	"net/http"
+	"strconv"
+
+	"github.com/gorilla/mux"
+
+	"github.com/lxc/lxd/lxd/db"
+	"github.com/lxc/lxd/lxd/util"
+	"github.com/lxc/lxd/shared"
+	"github.com/lxc/lxd/shared/api"
+	"github.com/lxc/lxd/shared/logger"
+	"github.com/lxc/lxd/shared/version"
+
+	log "github.com/lxc/lxd/shared/log15"
+)
+
+var networkForwardsCmd = Command{
+	name: "networks/{name}/forwards",
+	get:  networkForwardsGet,
+	post: networkForwardsPost,
+}
+
+var networkForwardCmd = Command{
+	name:   "networks/{name}/forwards/{id}",
+	get:    networkForwardGet,
+	delete: networkForwardDelete,
+}
+
+// networkForwardParseAddress parses the listen or target address of a port
+// forward, an empty host standing for all the IPv4 addresses.
+func networkForwardParseAddress(address string) (net.IP, int, error) {
+	host, portStr, err := net.SplitHostPort(address)
+	if err != nil {
+		return nil, -1, fmt.Errorf("Invalid address '%s': %v", address, err)
+	}
+
+	port, err := strconv.Atoi(portStr)
+	if err != nil || port < 1 || port > 65535 {
+		return nil, -1, fmt.Errorf("Invalid port in address '%s'", address)
+	}
+
+	if host == "" {
+		return net.IPv4zero, port, nil
+	}
+
+	ip := net.ParseIP(host)
+	if ip == nil {
+		return nil, -1, fmt.Errorf("Invalid IP in address '%s'", address)
+	}
+
+	return ip, port, nil
+}
+
+// networkForwardValidate checks the addresses and protocol of a port forward.
+func networkForwardValidate(forward api.NetworkForwardsPost) error {
+	if !shared.StringInSlice(forward.Protocol, []string{"tcp", "udp"}) {
+		return fmt.Errorf("Invalid protocol '%s', must be tcp or udp", forward.Protocol)
+	}
+
+	listenIP, _, err := networkForwardParseAddress(forward.ListenAddress)
+	if err != nil {
+		return err
+	}
+
+	targetIP, _, err := networkForwardParseAddress(forward.TargetAddress)
+	if err != nil {
+		return err
+	}
+
+	if targetIP.IsUnspecified() {
+		return fmt.Errorf("The target address must be the address of a container")
+	}
+
+	if (listenIP.To4() == nil) != (targetIP.To4() == nil) {
+		return fmt.Errorf("The listen and target addresses must be of the same family")
+	}
+
+	return nil
+}
+
+// networkForwardRules returns the iptables rules of a port forward, as
+// protocol, table, chain and rule.
+func networkForwardRules(forward db.NetworkForward) ([][]string, error) {
+	listenIP, listenPort, err := networkForwardParseAddress(forward.ListenAddress)
+	if err != nil {
+		return nil, err
+	}
+
+	targetIP, targetPort, err := networkForwardParseAddress(forward.TargetAddress)
+	if err != nil {
+		return nil, err
+	}
+
+	family := "ipv4"
+	destination := fmt.Sprintf("%s:%d", targetIP, targetPort)
+	if targetIP.To4() == nil {
+		family = "ipv6"
+		destination = fmt.Sprintf("[%s]:%d", targetIP, targetPort)
+	}
+
+	match := []string{"-p", forward.Protocol}
+	if listenIP.IsUnspecified() {
+		match = append(match, "-m", "addrtype", "--dst-type", "LOCAL")
+	} else {
+		match = append(match, "-d", listenIP.String())
+	}
+	match = append(match, "--dport", strconv.Itoa(listenPort))
+
+	dnat := append(match, "-j", "DNAT", "--to-destination", destination)
+
+	rules := [][]string{
+		append([]string{family, "nat", "PREROUTING"}, dnat...),
+		append([]string{family, "nat", "OUTPUT"}, dnat...),
+
+		// Hairpin for the container reaching its own forward
+		{family, "nat", "POSTROUTING", "-p", forward.Protocol, "-s", targetIP.String(), "-d", targetIP.String(),
+			"--dport", strconv.Itoa(targetPort), "-j", "MASQUERADE"},
+	}
+
+	return rules, nil
+}
+
+func networkForwardComment(networkName string, id int64) string {
+	return fmt.Sprintf("%s (forward %d)", networkName, id)
+}
+
+// forwardAdd sets up the firewall rules of a port forward.
+func (n *network) forwardAdd(forward db.NetworkForward) error {
+	rules, err := networkForwardRules(forward)
+	if err != nil {
+		return err
+	}
+
+	comment := networkForwardComment(n.name, forward.ID)
+	for _, rule := range rules {
+		err = networkIptablesPrepend(rule[0], comment, rule[1], rule[2], rule[3:]...)
+		if err != nil {
+			n.forwardRemove(forward)
+			return err
+		}
+	}
+
+	return nil
+}
+
+// forwardRemove removes the firewall rules of a port forward.
+func (n *network) forwardRemove(forward db.NetworkForward) error {
+	comment := networkForwardComment(n.name, forward.ID)
+	for _, family := range []string{"ipv4", "ipv6"} {
+		err := networkIptablesClear(family, comment, "nat")
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// forwardsApply sets up the firewall rules of all the port forwards of the
+// network on this node, the existing ones having been cleared along with the
+// other rules of the network.
+func (n *network) forwardsApply() {
+	forwards, err := n.state.Cluster.NetworkForwards(n.id)
+	if err != nil {
+		logger.Error("Failed to load network port forwards", log.Ctx{"network": n.name, "err": err})
+		return
+	}
+
+	for _, forward := range forwards {
+		err := n.forwardAdd(forward)
+		if err != nil {
+			logger.Error("Failed to set up network port forward", log.Ctx{"network": n.name, "listen": forward.ListenAddress, "err": err})
+		}
+	}
+}
+
+func networkForwardToAPI(forward db.NetworkForward, location string) api.NetworkForward {
+	return api.NetworkForward{
+		NetworkForwardsPost: api.NetworkForwardsPost{
+			ListenAddress: forward.ListenAddress,
+			TargetAddress: forward.TargetAddress,
+			Protocol:      forward.Protocol,
+			Description:   forward.Description,
+		},
+		ID:       forward.ID,
+		Location: location,
+	}
+}
+
+// networkBridgeID returns the ID of a managed bridge, or the error
+// response to send.
+func networkBridgeID(d *Daemon, name string) (int64, Response) {
+	id, n, err := d.cluster.NetworkGet(name)
+	if err != nil {
+		return -1, SmartError(err)
+	}
+
+	if !n.Managed || n.Type != "bridge" {
+		return -1, BadRequest(fmt.Errorf("Network '%s' isn't a managed bridge", name))
+	}
+
+	return id, nil
+}
+
+func networkForwardID(r *http.Request) (int64, error) {
+	id, err := strconv.ParseInt(mux.Vars(r)["id"], 10, 64)
+	if err != nil {
+		return -1, fmt.Errorf("Invalid port forward ID")
+	}
+
+	return id, nil
+}
+
+func networkForwardsGet(d *Daemon, r *http.Request) Response {
+	// Port forwards are specific to each node
+	response := ForwardedResponseIfTargetIsRemote(d, r)
+	if response != nil {
+		return response
+	}
+
+	name := mux.Vars(r)["name"]
+	recursion := util.IsRecursionRequest(r)
+
+	networkID, response := networkBridgeID(d, name)
+	if response != nil {
+		return response
+	}
+
+	forwards, err := d.cluster.NetworkForwards(networkID)
+	if err != nil {
+		return SmartError(err)
+	}
+
+	if recursion {
+		var location string
+		err = d.cluster.Transaction(func(tx *db.ClusterTx) error {
+			var err error
+			location, err = tx.NodeName()
+			return err
+		})
+		if err != nil {
+			return SmartError(err)
+		}
+
+		result := []api.NetworkForward{}
+		for _, forward := range forwards {
+			result = append(result, networkForwardToAPI(forward, location))
+		}
+
+		return SyncResponse(true, result)
+	}
+
+	result := []string{}
+	for _, forward := range forwards {
+		result = append(result, fmt.Sprintf("/%s/networks/%s/forwards/%d", version.APIVersion, name, forward.ID))
+	}
+
+	return SyncResponse(true, result)
+}
+
+func networkForwardsPost(d *Daemon, r *http.Request) Response {
+	// Port forwards are specific to each node
+	response := ForwardedResponseIfTargetIsRemote(d, r)
+	if response != nil {
+		return response
+	}
+
+	name := mux.Vars(r)["name"]
+
+	req := api.NetworkForwardsPost{}
+	err := shared.ReadToJSON(r.Body, &req)
+	if err != nil {
+		return BadRequest(err)
+	}
+
+	if req.Protocol == "" {
+		req.Protocol = "tcp"
+	}
+
+	err = networkForwardValidate(req)
+	if err != nil {
+		return BadRequest(err)
+	}
+
+	networkID, response := networkBridgeID(d, name)
+	if response != nil {
+		return response
+	}
+
+	forwards, err := d.cluster.NetworkForwards(networkID)
+	if err != nil {
+		return SmartError(err)
+	}
+
+	for _, forward := range forwards {
+		if forward.ListenAddress == req.ListenAddress && forward.Protocol == req.Protocol {
+			return Conflict(fmt.Errorf("A port forward already listens on %s/%s", req.ListenAddress, req.Protocol))
+		}
+	}
+
+	forward := db.NetworkForward{
+		ListenAddress: req.ListenAddress,
+		TargetAddress: req.TargetAddress,
+		Protocol:      req.Protocol,
+		Description:   req.Description,
+	}
+
+	forward.ID, err = d.cluster.NetworkForwardCreate(networkID, forward)
+	if err != nil {
+		return SmartError(err)
+	}
+
+	n, err := networkLoadByName(d.State(), name)
+	if err != nil {
+		return SmartError(err)
+	}
+
+	// Stopped networks get their forwards set up when started
+	if n.IsRunning() {
+		err = n.forwardAdd(forward)
+		if err != nil {
+			d.cluster.NetworkForwardDelete(networkID, forward.ID)
+			return SmartError(err)
+		}
+	}
+
+	return SyncResponseLocation(true, nil, fmt.Sprintf("/%s/networks/%s/forwards/%d", version.APIVersion, name, forward.ID))
+}
+
+func networkForwardGet(d *Daemon, r *http.Request) Response {
+	// Port forwards are specific to each node
+	response := ForwardedResponseIfTargetIsRemote(d, r)
+	if response != nil {
+		return response
+	}
+
+	id, err := networkForwardID(r)
+	if err != nil {
+		return BadRequest(err)
+	}
+
+	networkID, response := networkBridgeID(d, mux.Vars(r)["name"])
+	if response != nil {
+		return response
+	}
+
+	forward, err := d.cluster.NetworkForwardGet(networkID, id)
+	if err != nil {
+		return SmartError(err)
+	}
+
+	var location string
+	err = d.cluster.Transaction(func(tx *db.ClusterTx) error {
+		var err error
+		location, err = tx.NodeName()
+		return err
+	})
+	if err != nil {
+		return SmartError(err)
+	}
+
+	return SyncResponse(true, networkForwardToAPI(*forward, location))
+}
+
+func networkForwardDelete(d *Daemon, r *http.Request) Response {
+	// Port forwards are specific to each node
+	response := ForwardedResponseIfTargetIsRemote(d, r)
+	if response != nil {
+		return response
+	}
+
+	name := mux.Vars(r)["name"]
+
+	id, err := networkForwardID(r)
+	if err != nil {
+		return BadRequest(err)
+	}
+
+	networkID, response := networkBridgeID(d, name)
+	if response != nil {
+		return response
+	}
+
+	forward, err := d.cluster.NetworkForwardGet(networkID, id)
+	if err != nil {
+		return SmartError(err)
+	}
+
+	n, err := networkLoadByName(d.State(), name)
+	if err != nil {
+		return SmartError(err)
+	}
+
+	err = n.forwardRemove(*forward)
+	if err != nil {
+		return SmartError(err)
+	}
+
+	err = d.cluster.NetworkForwardDelete(networkID, id)
+	if err != nil {
+		return SmartError(err)
+	}
+
+	return EmptySyncResponse
+}
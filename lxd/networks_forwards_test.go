@@ -0,0 +1,39 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/lxc/lxd/lxd/db"
+	"github.com/lxc/lxd/shared/api"
+)
+
+func TestNetworkForwardValidate(t *testing.T) {
+	valid := api.NetworkForwardsPost{ListenAddress: "0.0.0.0:8080", TargetAddress: "10.0.0.5:80", Protocol: "tcp"}
+	assert.NoError(t, networkForwardValidate(valid))
+
+	cases := []api.NetworkForwardsPost{
+		{ListenAddress: "0.0.0.0:8080", TargetAddress: "10.0.0.5:80", Protocol: "sctp"},
+		{ListenAddress: "0.0.0.0", TargetAddress: "10.0.0.5:80", Protocol: "tcp"},
+		{ListenAddress: "0.0.0.0:70000", TargetAddress: "10.0.0.5:80", Protocol: "tcp"},
+		{ListenAddress: "0.0.0.0:8080", TargetAddress: "0.0.0.0:80", Protocol: "tcp"},
+		{ListenAddress: "[::]:8080", TargetAddress: "10.0.0.5:80", Protocol: "udp"},
+	}
+
+	for _, forward := range cases {
+		assert.Error(t, networkForwardValidate(forward), forward)
+	}
+}
+
+func TestNetworkForwardRules(t *testing.T) {
+	rules, err := networkForwardRules(db.NetworkForward{ListenAddress: "192.0.2.1:8080", TargetAddress: "10.0.0.5:80", Protocol: "tcp"})
+	require.NoError(t, err)
+	require.Len(t, rules, 3)
+	assert.Equal(t, []string{"ipv4", "nat", "PREROUTING", "-p", "tcp", "-d", "192.0.2.1", "--dport", "8080", "-j", "DNAT", "--to-destination", "10.0.0.5:80"}, rules[0])
+
+	rules, err = networkForwardRules(db.NetworkForward{ListenAddress: "[::]:53", TargetAddress: "[fd42::5]:5353", Protocol: "udp"})
+	require.NoError(t, err)
+	assert.Equal(t, []string{"ipv6", "nat", "OUTPUT", "-p", "udp", "-m", "addrtype", "--dst-type", "LOCAL", "--dport", "53", "-j", "DNAT", "--to-destination", "[fd42::5]:5353"}, rules[1])
+}
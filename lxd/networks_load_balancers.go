@@ -0,0 +1,726 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gorilla/mux"
+
+	"github.com/lxc/lxd/lxd/db"
+	"github.com/lxc/lxd/lxd/util"
+	"github.com/lxc/lxd/shared"
+	"github.com/lxc/lxd/shared/api"
+	"github.com/lxc/lxd/shared/logger"
+	"github.com/lxc/lxd/shared/version"
+
+	log "github.com/lxc/lxd/shared/log15"
+)
+
+var networkLoadBalancersCmd = Command{
+	name: "networks/{name}/load-balancers",
+	get:  networkLoadBalancersGet,
+	post: networkLoadBalancersPost,
+}
+
+var networkLoadBalancerCmd = Command{
+	name:   "networks/{name}/load-balancers/{id}",
+	get:    networkLoadBalancerGet,
+	delete: networkLoadBalancerDelete,
+}
+
+// Default health check interval of the backends, in seconds
+const networkLoadBalancerHealthCheckInterval = 10
+
+// Timeout of the TCP connect probes
+const networkLoadBalancerProbeTimeout = 2 * time.Second
+
+var networkLoadBalancerAlgorithms = []string{"round-robin", "least-conn", "ip-hash"}
+
+// networkLoadBalancerTarget is a backend of a load balancer along with its
+// address and state.
+type networkLoadBalancerTarget struct {
+	api.NetworkLoadBalancerBackend
+
+	Address string
+	Healthy bool
+
+	// Number of entries of the backend in the verdict map
+	Weight int
+}
+
+// networkLoadBalancerSyncer keeps the nftables rules of the load balancers of
+// a network in sync with the health of their backends.
+type networkLoadBalancerSyncer struct {
+	notify chan struct{}
+	stop   chan struct{}
+
+	mu     sync.Mutex
+	status map[int64][]api.NetworkLoadBalancerBackendStatus
+
+	// Last ruleset applied for each family
+	rulesets map[string]string
+}
+
+var networkLoadBalancerSyncersLock sync.Mutex
+var networkLoadBalancerSyncers = map[string]*networkLoadBalancerSyncer{}
+
+// networkLoadBalancerValidate checks the fields of a new load balancer.
+func networkLoadBalancerValidate(req api.NetworkLoadBalancersPost) error {
+	if !shared.StringInSlice(req.Protocol, []string{"tcp", "udp"}) {
+		return fmt.Errorf("Invalid protocol '%s', must be tcp or udp", req.Protocol)
+	}
+
+	if !shared.StringInSlice(req.Algorithm, networkLoadBalancerAlgorithms) {
+		return fmt.Errorf("Invalid algorithm '%s', must be one of %s", req.Algorithm, strings.Join(networkLoadBalancerAlgorithms, ", "))
+	}
+
+	_, _, err := networkForwardParseAddress(req.ListenAddress)
+	if err != nil {
+		return err
+	}
+
+	if len(req.Backends) == 0 {
+		return fmt.Errorf("At least one backend is required")
+	}
+
+	for _, backend := range req.Backends {
+		if backend.Container == "" {
+			return fmt.Errorf("The container of each backend is required")
+		}
+
+		if backend.Port < 1 || backend.Port > 65535 {
+			return fmt.Errorf("Invalid port %d for backend '%s'", backend.Port, backend.Container)
+		}
+	}
+
+	if req.HealthCheckInterval < 0 {
+		return fmt.Errorf("The health check interval can't be negative")
+	}
+
+	return nil
+}
+
+// networkLoadBalancerFamily returns the family of a load balancer, as an
+// nftables family.
+func networkLoadBalancerFamily(lb db.NetworkLoadBalancer) string {
+	ip, _, err := networkForwardParseAddress(lb.ListenAddress)
+	if err == nil && ip.To4() == nil {
+		return "ip6"
+	}
+
+	return "ip"
+}
+
+// networkLoadBalancerConntrack counts the established connections to each
+// backend, keyed by address and port, given the content of
+// /proc/net/nf_conntrack.
+func networkLoadBalancerConntrack(content string, protocol string) map[string]int {
+	counts := map[string]int{}
+
+	for _, line := range strings.Split(content, "\n") {
+		fields := strings.Fields(line)
+		if len(fields) < 3 || fields[2] != protocol {
+			continue
+		}
+
+		if protocol == "tcp" && !shared.StringInSlice("ESTABLISHED", fields) {
+			continue
+		}
+
+		// The reply tuple comes second, its source being the backend
+		srcs := []string{}
+		sports := []string{}
+		for _, field := range fields {
+			if strings.HasPrefix(field, "src=") {
+				srcs = append(srcs, strings.TrimPrefix(field, "src="))
+			} else if strings.HasPrefix(field, "sport=") {
+				sports = append(sports, strings.TrimPrefix(field, "sport="))
+			}
+		}
+
+		if len(srcs) < 2 || len(sports) < 2 {
+			continue
+		}
+
+		counts[net.JoinHostPort(srcs[1], sports[1])]++
+	}
+
+	return counts
+}
+
+// networkLoadBalancerWeights sets the weight of the healthy backends: the
+// same for all of them, except with least-conn where the backends with the
+// fewest established connections get up to 10 times more new ones.
+func networkLoadBalancerWeights(algorithm string, targets []networkLoadBalancerTarget, counts map[string]int) {
+	max := 0
+	for _, target := range targets {
+		count := counts[net.JoinHostPort(target.Address, strconv.Itoa(target.Port))]
+		if target.Healthy && count > max {
+			max = count
+		}
+	}
+
+	for i := range targets {
+		targets[i].Weight = 0
+		if !targets[i].Healthy {
+			continue
+		}
+
+		targets[i].Weight = 1
+		if algorithm == "least-conn" && max > 0 {
+			count := counts[net.JoinHostPort(targets[i].Address, strconv.Itoa(targets[i].Port))]
+			targets[i].Weight = 1 + (max-count)*9/max
+		}
+	}
+}
+
+// networkLoadBalancerRuleset returns the nftables ruleset of the load
+// balancers of a network for a family, replacing the existing table.
+func networkLoadBalancerRuleset(networkName string, family string, lbs []db.NetworkLoadBalancer, targets map[int64][]networkLoadBalancerTarget) string {
+	table := fmt.Sprintf("%s lxd_lb_%s", family, networkName)
+
+	// Create the table if missing so that it can always be deleted
+	var buf bytes.Buffer
+	fmt.Fprintf(&buf, "table %s\ndelete table %s\n", table, table)
+
+	jumps := []string{}
+	chains := []string{}
+	for _, lb := range lbs {
+		if networkLoadBalancerFamily(lb) != family {
+			continue
+		}
+
+		listenIP, listenPort, err := networkForwardParseAddress(lb.ListenAddress)
+		if err != nil {
+			continue
+		}
+
+		chain := fmt.Sprintf("lb_%d", lb.ID)
+		match := fmt.Sprintf("fib daddr type local %s dport %d", lb.Protocol, listenPort)
+		if !listenIP.IsUnspecified() {
+			match = fmt.Sprintf("%s daddr %s %s dport %d", family, listenIP, lb.Protocol, listenPort)
+		}
+		jumps = append(jumps, fmt.Sprintf("\t\t%s jump %s\n", match, chain))
+
+		entries := []string{}
+		for i, target := range targets[lb.ID] {
+			if target.Weight == 0 {
+				continue
+			}
+
+			destination := fmt.Sprintf("%s:%d", target.Address, target.Port)
+			if family == "ip6" {
+				destination = fmt.Sprintf("[%s]:%d", target.Address, target.Port)
+			}
+			chains = append(chains, fmt.Sprintf("\tchain %s_%d {\n\t\tdnat to %s\n\t}\n", chain, i, destination))
+
+			for j := 0; j < target.Weight; j++ {
+				entries = append(entries, fmt.Sprintf("%d : jump %s_%d", len(entries), chain, i))
+			}
+		}
+
+		// Without healthy backend, the traffic isn't forwarded
+		verdict := ""
+		if len(entries) > 0 {
+			selector := fmt.Sprintf("numgen inc mod %d", len(entries))
+			if lb.Algorithm == "ip-hash" {
+				selector = fmt.Sprintf("jhash %s saddr mod %d", family, len(entries))
+			}
+
+			verdict = fmt.Sprintf("\t\t%s vmap { %s }\n", selector, strings.Join(entries, ", "))
+		}
+		chains = append(chains, fmt.Sprintf("\tchain %s {\n%s\t}\n", chain, verdict))
+	}
+
+	if len(jumps) == 0 {
+		return buf.String()
+	}
+
+	// Chains are declared before being jumped to
+	fmt.Fprintf(&buf, "table %s {\n", table)
+	for _, chain := range chains {
+		buf.WriteString(chain)
+	}
+	for _, hook := range []string{"prerouting", "output"} {
+		fmt.Fprintf(&buf, "\tchain %s {\n\t\ttype nat hook %s priority -100; policy accept;\n", hook, hook)
+		for _, jump := range jumps {
+			buf.WriteString(jump)
+		}
+		buf.WriteString("\t}\n")
+	}
+	buf.WriteString("\tchain postrouting {\n\t\ttype nat hook postrouting priority 100; policy accept;\n\t}\n")
+	buf.WriteString("}\n")
+
+	return buf.String()
+}
+
+// networkLoadBalancerAddress returns the address of the container of a backend
+// on a network, either static or leased.
+func networkLoadBalancerAddress(n *network, family string, leases map[string]string, backend api.NetworkLoadBalancerBackend) string {
+	c, err := containerLoadByProjectAndName(n.state, backend.Project, backend.Container)
+	if err != nil || !c.IsRunning() {
+		return ""
+	}
+
+	for devName, m := range c.ExpandedDevices() {
+		if m["type"] != "nic" || m["nictype"] != "bridged" || m["parent"] != n.name {
+			continue
+		}
+
+		if family == "ip6" {
+			return m["ipv6.address"]
+		}
+
+		if m["ipv4.address"] != "" {
+			return m["ipv4.address"]
+		}
+
+		hwaddr := m["hwaddr"]
+		if hwaddr == "" {
+			hwaddr = c.LocalConfig()[fmt.Sprintf("volatile.%s.hwaddr", devName)]
+		}
+
+		return leases[strings.ToLower(hwaddr)]
+	}
+
+	return ""
+}
+
+// networkLoadBalancerProbe checks whether a backend accepts connections. UDP
+// backends are considered healthy as long as their container runs.
+func networkLoadBalancerProbe(protocol string, address string, port int) bool {
+	if protocol != "tcp" {
+		return true
+	}
+
+	conn, err := net.DialTimeout("tcp", net.JoinHostPort(address, strconv.Itoa(port)), networkLoadBalancerProbeTimeout)
+	if err != nil {
+		return false
+	}
+	conn.Close()
+
+	return true
+}
+
+// loadBalancersSync checks the health of the backends and updates the
+// nftables rules if needed. It returns how long to wait until the next check.
+func (n *network) loadBalancersSync(syncer *networkLoadBalancerSyncer) (time.Duration, error) {
+	interval := time.Duration(networkLoadBalancerHealthCheckInterval) * time.Second
+
+	lbs, err := n.state.Cluster.NetworkLoadBalancers(n.id)
+	if err != nil {
+		return interval, err
+	}
+
+	leases := evpnLeases(n.name)
+
+	conntrack := ""
+	content, err := ioutil.ReadFile("/proc/net/nf_conntrack")
+	if err == nil {
+		conntrack = string(content)
+	}
+
+	targets := map[int64][]networkLoadBalancerTarget{}
+	status := map[int64][]api.NetworkLoadBalancerBackendStatus{}
+	for _, lb := range lbs {
+		if lb.HealthCheckInterval > 0 && time.Duration(lb.HealthCheckInterval)*time.Second < interval {
+			interval = time.Duration(lb.HealthCheckInterval) * time.Second
+		}
+
+		family := networkLoadBalancerFamily(lb)
+		for _, backend := range lb.Backends {
+			target := networkLoadBalancerTarget{NetworkLoadBalancerBackend: backend}
+			target.Address = networkLoadBalancerAddress(n, family, leases, backend)
+			if target.Address != "" {
+				target.Healthy = networkLoadBalancerProbe(lb.Protocol, target.Address, backend.Port)
+			}
+
+			targets[lb.ID] = append(targets[lb.ID], target)
+			status[lb.ID] = append(status[lb.ID], api.NetworkLoadBalancerBackendStatus{
+				NetworkLoadBalancerBackend: backend,
+				Address:                    target.Address,
+				Healthy:                    target.Healthy,
+			})
+		}
+
+		networkLoadBalancerWeights(lb.Algorithm, targets[lb.ID], networkLoadBalancerConntrack(conntrack, lb.Protocol))
+	}
+
+	syncer.mu.Lock()
+	syncer.status = status
+	syncer.mu.Unlock()
+
+	for _, family := range []string{"ip", "ip6"} {
+		ruleset := networkLoadBalancerRuleset(n.name, family, lbs, targets)
+		if syncer.rulesets[family] == ruleset {
+			continue
+		}
+
+		err := shared.RunCommandWithFds(strings.NewReader(ruleset), nil, "nft", "-f", "-")
+		if err != nil {
+			return interval, err
+		}
+
+		syncer.rulesets[family] = ruleset
+	}
+
+	return interval, nil
+}
+
+func (n *network) loadBalancersRun(syncer *networkLoadBalancerSyncer) {
+	for {
+		interval, err := n.loadBalancersSync(syncer)
+		if err != nil {
+			logger.Error("Failed to sync network load balancers", log.Ctx{"network": n.name, "err": err})
+		}
+
+		select {
+		case <-syncer.stop:
+			return
+		case <-syncer.notify:
+		case <-time.After(interval):
+		}
+	}
+}
+
+// loadBalancersStart starts syncing the load balancers of the network, if it
+// has any.
+func (n *network) loadBalancersStart() {
+	lbs, err := n.state.Cluster.NetworkLoadBalancers(n.id)
+	if err != nil {
+		logger.Error("Failed to load network load balancers", log.Ctx{"network": n.name, "err": err})
+		return
+	}
+
+	if len(lbs) == 0 {
+		return
+	}
+
+	networkLoadBalancerSyncersLock.Lock()
+	defer networkLoadBalancerSyncersLock.Unlock()
+
+	_, ok := networkLoadBalancerSyncers[n.name]
+	if ok {
+		return
+	}
+
+	syncer := &networkLoadBalancerSyncer{
+		notify:   make(chan struct{}, 1),
+		stop:     make(chan struct{}),
+		status:   map[int64][]api.NetworkLoadBalancerBackendStatus{},
+		rulesets: map[string]string{},
+	}
+	networkLoadBalancerSyncers[n.name] = syncer
+
+	go n.loadBalancersRun(syncer)
+}
+
+// loadBalancersStop stops syncing the load balancers of the network and
+// removes their nftables rules.
+func (n *network) loadBalancersStop() {
+	networkLoadBalancerSyncersLock.Lock()
+	syncer, ok := networkLoadBalancerSyncers[n.name]
+	if ok {
+		close(syncer.stop)
+		delete(networkLoadBalancerSyncers, n.name)
+	}
+	networkLoadBalancerSyncersLock.Unlock()
+
+	if !ok {
+		return
+	}
+
+	for _, family := range []string{"ip", "ip6"} {
+		ruleset := networkLoadBalancerRuleset(n.name, family, nil, nil)
+		err := shared.RunCommandWithFds(strings.NewReader(ruleset), nil, "nft", "-f", "-")
+		if err != nil {
+			logger.Error("Failed to remove network load balancers", log.Ctx{"network": n.name, "err": err})
+		}
+	}
+}
+
+// networkLoadBalancersNotify triggers an immediate sync of the load
+// balancers of a network.
+func networkLoadBalancersNotify(name string) {
+	networkLoadBalancerSyncersLock.Lock()
+	defer networkLoadBalancerSyncersLock.Unlock()
+
+	syncer, ok := networkLoadBalancerSyncers[name]
+	if !ok {
+		return
+	}
+
+	select {
+	case syncer.notify <- struct{}{}:
+	default:
+	}
+}
+
+// networkLoadBalancersRefresh applies a change of the load balancers of a
+// network, starting or stopping their sync as the first is added or the last
+// removed.
+func networkLoadBalancersRefresh(d *Daemon, name string) error {
+	n, err := networkLoadByName(d.State(), name)
+	if err != nil {
+		return err
+	}
+
+	if !n.IsRunning() {
+		return nil
+	}
+
+	lbs, err := d.cluster.NetworkLoadBalancers(n.id)
+	if err != nil {
+		return err
+	}
+
+	if len(lbs) == 0 {
+		n.loadBalancersStop()
+		return nil
+	}
+
+	n.loadBalancersStart()
+	networkLoadBalancersNotify(name)
+
+	return nil
+}
+
+func networkLoadBalancerToAPI(lb db.NetworkLoadBalancer, location string, status []api.NetworkLoadBalancerBackendStatus) api.NetworkLoadBalancer {
+	if status == nil {
+		status = []api.NetworkLoadBalancerBackendStatus{}
+	}
+
+	return api.NetworkLoadBalancer{
+		NetworkLoadBalancersPost: api.NetworkLoadBalancersPost{
+			ListenAddress:       lb.ListenAddress,
+			Protocol:            lb.Protocol,
+			Algorithm:           lb.Algorithm,
+			Backends:            lb.Backends,
+			HealthCheckInterval: lb.HealthCheckInterval,
+			Description:         lb.Description,
+		},
+		ID:       lb.ID,
+		Location: location,
+		Status:   status,
+	}
+}
+
+// networkLoadBalancerStatus returns the last known health of the backends of
+// a load balancer.
+func networkLoadBalancerStatus(name string, id int64) []api.NetworkLoadBalancerBackendStatus {
+	networkLoadBalancerSyncersLock.Lock()
+	syncer, ok := networkLoadBalancerSyncers[name]
+	networkLoadBalancerSyncersLock.Unlock()
+
+	if !ok {
+		return nil
+	}
+
+	syncer.mu.Lock()
+	defer syncer.mu.Unlock()
+
+	return syncer.status[id]
+}
+
+func networkLoadBalancerID(r *http.Request) (int64, error) {
+	id, err := strconv.ParseInt(mux.Vars(r)["id"], 10, 64)
+	if err != nil {
+		return -1, fmt.Errorf("Invalid load balancer ID")
+	}
+
+	return id, nil
+}
+
+func networkLoadBalancersGet(d *Daemon, r *http.Request) Response {
+	// Load balancers are specific to each node
+	response := ForwardedResponseIfTargetIsRemote(d, r)
+	if response != nil {
+		return response
+	}
+
+	name := mux.Vars(r)["name"]
+	recursion := util.IsRecursionRequest(r)
+
+	networkID, response := networkBridgeID(d, name)
+	if response != nil {
+		return response
+	}
+
+	lbs, err := d.cluster.NetworkLoadBalancers(networkID)
+	if err != nil {
+		return SmartError(err)
+	}
+
+	if recursion {
+		var location string
+		err = d.cluster.Transaction(func(tx *db.ClusterTx) error {
+			var err error
+			location, err = tx.NodeName()
+			return err
+		})
+		if err != nil {
+			return SmartError(err)
+		}
+
+		result := []api.NetworkLoadBalancer{}
+		for _, lb := range lbs {
+			result = append(result, networkLoadBalancerToAPI(lb, location, networkLoadBalancerStatus(name, lb.ID)))
+		}
+
+		return SyncResponse(true, result)
+	}
+
+	result := []string{}
+	for _, lb := range lbs {
+		result = append(result, fmt.Sprintf("/%s/networks/%s/load-balancers/%d", version.APIVersion, name, lb.ID))
+	}
+
+	return SyncResponse(true, result)
+}
+
+func networkLoadBalancersPost(d *Daemon, r *http.Request) Response {
+	// Load balancers are specific to each node
+	response := ForwardedResponseIfTargetIsRemote(d, r)
+	if response != nil {
+		return response
+	}
+
+	name := mux.Vars(r)["name"]
+
+	req := api.NetworkLoadBalancersPost{}
+	err := shared.ReadToJSON(r.Body, &req)
+	if err != nil {
+		return BadRequest(err)
+	}
+
+	if req.Protocol == "" {
+		req.Protocol = "tcp"
+	}
+
+	if req.Algorithm == "" {
+		req.Algorithm = "round-robin"
+	}
+
+	// Backends default to the containers of the project of the request
+	for i := range req.Backends {
+		if req.Backends[i].Project == "" {
+			req.Backends[i].Project = projectParam(r)
+		}
+	}
+
+	err = networkLoadBalancerValidate(req)
+	if err != nil {
+		return BadRequest(err)
+	}
+
+	networkID, response := networkBridgeID(d, name)
+	if response != nil {
+		return response
+	}
+
+	lbs, err := d.cluster.NetworkLoadBalancers(networkID)
+	if err != nil {
+		return SmartError(err)
+	}
+
+	for _, lb := range lbs {
+		if lb.ListenAddress == req.ListenAddress && lb.Protocol == req.Protocol {
+			return Conflict(fmt.Errorf("A load balancer already listens on %s/%s", req.ListenAddress, req.Protocol))
+		}
+	}
+
+	id, err := d.cluster.NetworkLoadBalancerCreate(networkID, db.NetworkLoadBalancer{
+		ListenAddress:       req.ListenAddress,
+		Protocol:            req.Protocol,
+		Algorithm:           req.Algorithm,
+		Backends:            req.Backends,
+		HealthCheckInterval: req.HealthCheckInterval,
+		Description:         req.Description,
+	})
+	if err != nil {
+		return SmartError(err)
+	}
+
+	err = networkLoadBalancersRefresh(d, name)
+	if err != nil {
+		return SmartError(err)
+	}
+
+	return SyncResponseLocation(true, nil, fmt.Sprintf("/%s/networks/%s/load-balancers/%d", version.APIVersion, name, id))
+}
+
+func networkLoadBalancerGet(d *Daemon, r *http.Request) Response {
+	// Load balancers are specific to each node
+	response := ForwardedResponseIfTargetIsRemote(d, r)
+	if response != nil {
+		return response
+	}
+
+	name := mux.Vars(r)["name"]
+
+	id, err := networkLoadBalancerID(r)
+	if err != nil {
+		return BadRequest(err)
+	}
+
+	networkID, response := networkBridgeID(d, name)
+	if response != nil {
+		return response
+	}
+
+	lb, err := d.cluster.NetworkLoadBalancerGet(networkID, id)
+	if err != nil {
+		return SmartError(err)
+	}
+
+	var location string
+	err = d.cluster.Transaction(func(tx *db.ClusterTx) error {
+		var err error
+		location, err = tx.NodeName()
+		return err
+	})
+	if err != nil {
+		return SmartError(err)
+	}
+
+	return SyncResponse(true, networkLoadBalancerToAPI(*lb, location, networkLoadBalancerStatus(name, lb.ID)))
+}
+
+func networkLoadBalancerDelete(d *Daemon, r *http.Request) Response {
+	// Load balancers are specific to each node
+	response := ForwardedResponseIfTargetIsRemote(d, r)
+	if response != nil {
+		return response
+	}
+
+	name := mux.Vars(r)["name"]
+
+	id, err := networkLoadBalancerID(r)
+	if err != nil {
+		return BadRequest(err)
+	}
+
+	networkID, response := networkBridgeID(d, name)
+	if response != nil {
+		return response
+	}
+
+	err = d.cluster.NetworkLoadBalancerDelete(networkID, id)
+	if err != nil {
+		return SmartError(err)
+	}
+
+	err = networkLoadBalancersRefresh(d, name)
+	if err != nil {
+		return SmartError(err)
+	}
+
+	return EmptySyncResponse
+}
@@ -0,0 +1,84 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/lxc/lxd/lxd/db"
+	"github.com/lxc/lxd/shared/api"
+)
+
+func TestNetworkLoadBalancerConntrack(t *testing.T) {
+	content := `ipv4     2 tcp      6 431999 ESTABLISHED src=192.0.2.10 dst=192.0.2.1 sport=50000 dport=8080 src=10.0.0.5 dst=192.0.2.10 sport=80 dport=50000 [ASSURED] mark=0 zone=0 use=2
+ipv4     2 tcp      6 431999 ESTABLISHED src=192.0.2.11 dst=192.0.2.1 sport=50001 dport=8080 src=10.0.0.5 dst=192.0.2.11 sport=80 dport=50001 [ASSURED] mark=0 zone=0 use=2
+ipv4     2 tcp      6 119 TIME_WAIT src=192.0.2.12 dst=192.0.2.1 sport=50002 dport=8080 src=10.0.0.6 dst=192.0.2.12 sport=80 dport=50002 [ASSURED] mark=0 zone=0 use=2
+ipv4     2 udp      17 29 src=192.0.2.10 dst=192.0.2.1 sport=53000 dport=53 src=10.0.0.7 dst=192.0.2.10 sport=53 dport=53000 mark=0 zone=0 use=2
+`
+
+	assert.Equal(t, map[string]int{"10.0.0.5:80": 2}, networkLoadBalancerConntrack(content, "tcp"))
+	assert.Equal(t, map[string]int{"10.0.0.7:53": 1}, networkLoadBalancerConntrack(content, "udp"))
+}
+
+func TestNetworkLoadBalancerWeights(t *testing.T) {
+	targets := []networkLoadBalancerTarget{
+		{NetworkLoadBalancerBackend: api.NetworkLoadBalancerBackend{Container: "c1", Port: 80}, Address: "10.0.0.5", Healthy: true},
+		{NetworkLoadBalancerBackend: api.NetworkLoadBalancerBackend{Container: "c2", Port: 80}, Address: "10.0.0.6", Healthy: true},
+		{NetworkLoadBalancerBackend: api.NetworkLoadBalancerBackend{Container: "c3", Port: 80}, Address: "10.0.0.7", Healthy: false},
+	}
+	counts := map[string]int{"10.0.0.5:80": 10}
+
+	networkLoadBalancerWeights("round-robin", targets, counts)
+	assert.Equal(t, []int{1, 1, 0}, []int{targets[0].Weight, targets[1].Weight, targets[2].Weight})
+
+	networkLoadBalancerWeights("least-conn", targets, counts)
+	assert.Equal(t, []int{1, 10, 0}, []int{targets[0].Weight, targets[1].Weight, targets[2].Weight})
+}
+
+func TestNetworkLoadBalancerRuleset(t *testing.T) {
+	lbs := []db.NetworkLoadBalancer{
+		{ID: 1, ListenAddress: "0.0.0.0:8080", Protocol: "tcp", Algorithm: "round-robin"},
+		{ID: 2, ListenAddress: "[::]:53", Protocol: "udp", Algorithm: "ip-hash"},
+	}
+	targets := map[int64][]networkLoadBalancerTarget{
+		1: {
+			{NetworkLoadBalancerBackend: api.NetworkLoadBalancerBackend{Container: "c1", Port: 80}, Address: "10.0.0.5", Weight: 1},
+			{NetworkLoadBalancerBackend: api.NetworkLoadBalancerBackend{Container: "c2", Port: 80}, Address: "10.0.0.6", Weight: 0},
+			{NetworkLoadBalancerBackend: api.NetworkLoadBalancerBackend{Container: "c3", Port: 81}, Address: "10.0.0.7", Weight: 2},
+		},
+	}
+
+	expected := `table ip lxd_lb_lxdbr0
+delete table ip lxd_lb_lxdbr0
+table ip lxd_lb_lxdbr0 {
+	chain lb_1_0 {
+		dnat to 10.0.0.5:80
+	}
+	chain lb_1_2 {
+		dnat to 10.0.0.7:81
+	}
+	chain lb_1 {
+		numgen inc mod 3 vmap { 0 : jump lb_1_0, 1 : jump lb_1_2, 2 : jump lb_1_2 }
+	}
+	chain prerouting {
+		type nat hook prerouting priority -100; policy accept;
+		fib daddr type local tcp dport 8080 jump lb_1
+	}
+	chain output {
+		type nat hook output priority -100; policy accept;
+		fib daddr type local tcp dport 8080 jump lb_1
+	}
+	chain postrouting {
+		type nat hook postrouting priority 100; policy accept;
+	}
+}
+`
+	assert.Equal(t, expected, networkLoadBalancerRuleset("lxdbr0", "ip", lbs, targets))
+
+	// No healthy backend
+	ruleset := networkLoadBalancerRuleset("lxdbr0", "ip6", lbs, targets)
+	assert.Contains(t, ruleset, "\tchain lb_2 {\n\t}\n")
+	assert.Contains(t, ruleset, "fib daddr type local udp dport 53 jump lb_2")
+
+	assert.Equal(t, "table ip lxd_lb_lxdbr0\ndelete table ip lxd_lb_lxdbr0\n", networkLoadBalancerRuleset("lxdbr0", "ip", nil, nil))
+}
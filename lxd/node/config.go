@@ -2,9 +2,11 @@ package node
 
 import (
 	"fmt"
+	"path/filepath"
 
 	"github.com/lxc/lxd/lxd/config"
 	"github.com/lxc/lxd/lxd/db"
+	"github.com/lxc/lxd/shared"
 )
 
 // Config holds node-local configuration values for a certain LXD instance.
@@ -48,6 +50,30 @@ func (c *Config) DebugAddress() string {
 	return c.m.GetString("core.debug_address")
 }
 
+// KeyStore returns the key store holding the private key of the server
+// certificate, along with the URI of the key in that store.
+func (c *Config) KeyStore() (string, string) {
+	return c.m.GetString("core.key_store"), c.m.GetString("core.key_store_uri")
+}
+
+// HTTPSCert returns the paths of the certificate and private key served by
+// the network endpoint instead of the ones of the var dir, if set.
+func (c *Config) HTTPSCert() (string, string) {
+	return c.m.GetString("core.https_cert"), c.m.GetString("core.https_key")
+}
+
+// BuildImage returns the image, alias or fingerprint, of the privileged
+// containers running the Dockerfile builds, if set.
+func (c *Config) BuildImage() string {
+	return c.m.GetString("images.build_image")
+}
+
+// BuildKitCache returns the directory holding the BuildKit cache of the
+// Dockerfile builds, if set.
+func (c *Config) BuildKitCache() string {
+	return c.m.GetString("images.buildkit_cache")
+}
+
 // MAASMachine returns the MAAS machine this instance is associated with, if
 // any.
 func (c *Config) MAASMachine() string {
@@ -123,6 +149,40 @@ func DebugAddress(node *db.Node) (string, error) {
 	return config.DebugAddress(), nil
 }
 
+// KeyStore is a convenience for loading the node configuration and returning
+// the values of core.key_store and core.key_store_uri.
+func KeyStore(node *db.Node) (string, string, error) {
+	var config *Config
+	err := node.Transaction(func(tx *db.NodeTx) error {
+		var err error
+		config, err = ConfigLoad(tx)
+		return err
+	})
+	if err != nil {
+		return "", "", err
+	}
+
+	store, uri := config.KeyStore()
+	return store, uri, nil
+}
+
+// HTTPSCert is a convenience for loading the node configuration and returning
+// the values of core.https_cert and core.https_key.
+func HTTPSCert(node *db.Node) (string, string, error) {
+	var config *Config
+	err := node.Transaction(func(tx *db.NodeTx) error {
+		var err error
+		config, err = ConfigLoad(tx)
+		return err
+	})
+	if err != nil {
+		return "", "", err
+	}
+
+	cert, key := config.HTTPSCert()
+	return cert, key, nil
+}
+
 func (c *Config) update(values map[string]interface{}) (map[string]string, error) {
 	changed, err := c.m.Change(values)
 	if err != nil {
@@ -142,12 +202,41 @@ var ConfigSchema = config.Schema{
 	// Network address for this LXD server
 	"core.https_address": {},
 
+	// Certificate and private key of the network endpoint, such as the
+	// ones renewed by an ACME client
+	"core.https_cert": {Validator: httpsCertValidator},
+	"core.https_key":  {Validator: httpsCertValidator},
+
 	// Network address for cluster communication
 	"cluster.https_address": {},
 
 	// Network address for the debug server
 	"core.debug_address": {},
 
+	// Key store holding the private key of the server certificate
+	"core.key_store": {Default: "file", Validator: keyStoreValidator},
+
+	// Location of the private key in the key store
+	"core.key_store_uri": {},
+
+	// Image of the privileged containers running the Dockerfile builds
+	"images.build_image": {},
+
+	// Directory holding the BuildKit cache of the Dockerfile builds
+	"images.buildkit_cache": {},
+
 	// MAAS machine this LXD instance is associated with
 	"maas.machine": {},
 }
+
+func httpsCertValidator(value string) error {
+	if value != "" && !filepath.IsAbs(value) {
+		return fmt.Errorf("The path must be absolute")
+	}
+
+	return nil
+}
+
+func keyStoreValidator(value string) error {
+	return shared.IsOneOf(value, []string{"file", "pkcs11", "tpm2"})
+}
@@ -0,0 +1,248 @@
+package main
+
+import (
+	"bytes"
+	"crypto/x509"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"gopkg.in/macaroon-bakery.v2/httpbakery"
+
+	"github.com/lxc/lxd/lxd/util"
+	"github.com/lxc/lxd/shared"
+	"github.com/lxc/lxd/shared/logger"
+	"github.com/lxc/lxd/shared/version"
+
+	log "github.com/lxc/lxd/shared/log15"
+)
+
+// Largest request body forwarded to the policy engine
+const opaMaxBody = 1024 * 1024
+
+// Parsed network certificate, to tell internal cluster traffic apart
+var opaClusterCertLock sync.Mutex
+var opaClusterCertRaw []byte
+var opaClusterCert *x509.Certificate
+
+// opaInput is the document sent to the policy engine for each request.
+type opaInput struct {
+	User        string              `json:"user"`
+	Protocol    string              `json:"protocol"`
+	Method      string              `json:"method"`
+	Path        string              `json:"path"`
+	Query       map[string][]string `json:"query"`
+	Project     string              `json:"project"`
+	ContentType string              `json:"content_type"`
+	Body        interface{}         `json:"body"`
+}
+
+// opaResult is the decision returned by the policy engine. The policy may
+// either return a plain boolean or a document with those fields.
+type opaResult struct {
+	Allow   bool                   `json:"allow"`
+	Message string                 `json:"message"`
+	Context map[string]interface{} `json:"context"`
+}
+
+// opaEndpointSet sets the policy engine endpoint, disabling it if empty.
+func (d *Daemon) opaEndpointSet(endpoint string) {
+	d.opaEndpointLock.Lock()
+	defer d.opaEndpointLock.Unlock()
+
+	d.opaEndpoint = endpoint
+}
+
+// opaEndpointGet returns the policy engine endpoint.
+func (d *Daemon) opaEndpointGet() string {
+	d.opaEndpointLock.Lock()
+	defer d.opaEndpointLock.Unlock()
+
+	return d.opaEndpoint
+}
+
+// opaClusterCertificate returns the parsed network certificate, which is only
+// parsed again when it changes.
+func opaClusterCertificate(d *Daemon) (*x509.Certificate, error) {
+	raw := d.endpoints.NetworkCert().KeyPair().Certificate[0]
+
+	opaClusterCertLock.Lock()
+	defer opaClusterCertLock.Unlock()
+
+	if opaClusterCert == nil || !bytes.Equal(raw, opaClusterCertRaw) {
+		cert, err := x509.ParseCertificate(raw)
+		if err != nil {
+			return nil, err
+		}
+
+		opaClusterCert = cert
+		opaClusterCertRaw = raw
+	}
+
+	return opaClusterCert, nil
+}
+
+// opaExempt returns whether the request bypasses the policy engine, which is
+// the case for the local unix socket and internal cluster traffic.
+func opaExempt(d *Daemon, r *http.Request) bool {
+	return r.RemoteAddr == "@" || isClusterMemberRequest(d, r)
+}
+
+// isClusterMemberRequest returns whether a request comes from another member
+// of the cluster, authenticated with the cluster certificate.
+func isClusterMemberRequest(d *Daemon, r *http.Request) bool {
+	if r.TLS == nil {
+		return false
+	}
+
+	cert, err := opaClusterCertificate(d)
+	if err != nil {
+		return false
+	}
+
+	clusterCerts := map[string]x509.Certificate{"0": *cert}
+	for i := range r.TLS.PeerCertificates {
+		trusted, _ := util.CheckTrustState(*r.TLS.PeerCertificates[i], clusterCerts)
+		if trusted {
+			return true
+		}
+	}
+
+	return false
+}
+
+// opaUploadRequest returns whether the request uploads a file, whose content
+// isn't passed to the policy engine.
+func opaUploadRequest(r *http.Request) bool {
+	contentType := strings.ToLower(r.Header.Get("Content-Type"))
+	return contentType == "application/octet-stream" || strings.HasPrefix(contentType, "multipart/form-data")
+}
+
+// opaAuthorize asks the policy engine at the given endpoint whether the
+// authenticated user may perform the request.
+func opaAuthorize(d *Daemon, endpoint string, username string, r *http.Request) (*opaResult, error) {
+	input := opaInput{
+		User:     username,
+		Protocol: "tls",
+		Method:   r.Method,
+		Path:     r.URL.Path,
+		Query:    r.URL.Query(),
+		Project:  projectParam(r),
+	}
+
+	if d.externalAuth != nil && r.Header.Get(httpbakery.BakeryProtocolHeader) != "" {
+		input.Protocol = "candid"
+	}
+
+	// Include the body of all but file uploads, leaving it in place for the
+	// handler. Handlers decode JSON whatever the content type, so bodies
+	// which can't be passed on deny the request.
+	input.ContentType = r.Header.Get("Content-Type")
+	if r.Body != nil && !opaUploadRequest(r) {
+		buf, err := ioutil.ReadAll(io.LimitReader(r.Body, opaMaxBody+1))
+		if err != nil {
+			return nil, err
+		}
+		r.Body = shared.BytesReadCloser{Buf: bytes.NewBuffer(buf)}
+
+		if len(buf) > opaMaxBody {
+			return &opaResult{Message: "Request body too large to be authorized"}, nil
+		}
+
+		if len(buf) > 0 {
+			err := json.Unmarshal(buf, &input.Body)
+			if err != nil {
+				return &opaResult{Message: "Request body isn't valid JSON"}, nil
+			}
+		}
+	}
+
+	payload, err := json.Marshal(map[string]interface{}{"input": input})
+	if err != nil {
+		return nil, err
+	}
+
+	req, err := http.NewRequest("POST", endpoint, bytes.NewReader(payload))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", version.UserAgent)
+
+	client := &http.Client{
+		Timeout:   10 * time.Second,
+		Transport: &http.Transport{Proxy: d.proxy},
+	}
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("Unexpected HTTP status: %s", resp.Status)
+	}
+
+	decision := struct {
+		Result json.RawMessage `json:"result"`
+	}{}
+
+	err = json.NewDecoder(resp.Body).Decode(&decision)
+	if err != nil {
+		return nil, err
+	}
+
+	// An undefined decision means the policy doesn't cover the request
+	result := opaResult{}
+	if len(decision.Result) == 0 {
+		return &result, nil
+	}
+
+	err = json.Unmarshal(decision.Result, &result.Allow)
+	if err == nil {
+		return &result, nil
+	}
+
+	err = json.Unmarshal(decision.Result, &result)
+	if err != nil {
+		return nil, fmt.Errorf("Invalid policy decision: %v", err)
+	}
+
+	return &result, nil
+}
+
+// opaCheck returns a response to send instead of processing the request if
+// the policy engine denies it, or nil if the request may proceed.
+func opaCheck(d *Daemon, username string, r *http.Request) Response {
+	endpoint := d.opaEndpointGet()
+	if endpoint == "" || opaExempt(d, r) {
+		return nil
+	}
+
+	result, err := opaAuthorize(d, endpoint, username, r)
+	if err != nil {
+		logger.Error("Failed to query the policy engine", log.Ctx{"endpoint": endpoint, "err": err})
+		return Forbidden(fmt.Errorf("Failed to check the authorization policy"))
+	}
+
+	if !result.Allow {
+		logger.Warn("Request denied by policy", log.Ctx{"method": r.Method, "url": r.URL.RequestURI(), "user": username, "message": result.Message})
+		if result.Message == "" {
+			return Forbidden(nil)
+		}
+
+		return Forbidden(fmt.Errorf("%s", result.Message))
+	}
+
+	if len(result.Context) > 0 {
+		logger.Debug("Request allowed by policy", log.Ctx{"method": r.Method, "url": r.URL.RequestURI(), "user": username, "context": result.Context})
+	}
+
+	return nil
+}
@@ -0,0 +1,113 @@
+package plugin
+
+import (
+	"fmt"
+	"io/ioutil"
+	"path/filepath"
+	"plugin"
+	"strings"
+)
+
+// Container is the view of a container made available to device plugins.
+type Container interface {
+	Name() string
+	Project() string
+	InitPID() int
+	RootfsPath() string
+	ExpandedConfig() map[string]string
+}
+
+// Plugin is the interface implemented by custom device drivers.
+//
+// Plugins are Go plugins (.so files) exporting a "Plugin" symbol implementing
+// this interface and an "APIVersion" string matching the API version of the
+// daemon they're built for.
+type Plugin interface {
+	// DeviceType returns the value of the "type" device key handled by the plugin.
+	DeviceType() string
+
+	// Validate checks the device configuration.
+	Validate(config map[string]string) error
+
+	// Start sets up the device for a starting container.
+	Start(c Container, config map[string]string) error
+
+	// Stop tears down the device of a stopping container.
+	Stop(c Container, config map[string]string) error
+}
+
+// Load loads all the plugins found in the given directory, keyed by
+// the device type they handle.
+func Load(dir string, apiVersion string) (map[string]Plugin, error) {
+	devices := map[string]Plugin{}
+
+	files, err := ioutil.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, f := range files {
+		if f.IsDir() || !strings.HasSuffix(f.Name(), ".so") {
+			continue
+		}
+
+		device, err := loadPlugin(filepath.Join(dir, f.Name()), apiVersion)
+		if err != nil {
+			return nil, err
+		}
+
+		_, exists := devices[device.DeviceType()]
+		if exists {
+			return nil, fmt.Errorf("Device type '%s' is provided by more than one plugin", device.DeviceType())
+		}
+
+		devices[device.DeviceType()] = device
+	}
+
+	return devices, nil
+}
+
+func loadPlugin(path string, apiVersion string) (Plugin, error) {
+	p, err := plugin.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("Failed to load plugin '%s': %v", path, err)
+	}
+
+	// Check compatibility
+	symbol, err := p.Lookup("APIVersion")
+	if err != nil {
+		return nil, fmt.Errorf("Plugin '%s' doesn't declare its API version", path)
+	}
+
+	version, ok := symbol.(*string)
+	if !ok {
+		return nil, fmt.Errorf("Plugin '%s' has an invalid APIVersion symbol", path)
+	}
+
+	if *version != apiVersion {
+		return nil, fmt.Errorf("Plugin '%s' was built for API version %s, the daemon uses %s", path, *version, apiVersion)
+	}
+
+	// Get the device driver
+	symbol, err = p.Lookup("Plugin")
+	if err != nil {
+		return nil, fmt.Errorf("Plugin '%s' doesn't provide a device", path)
+	}
+
+	device, ok := symbol.(Plugin)
+	if !ok {
+		// Exported variables are looked up as pointers
+		devicePtr, ok := symbol.(*Plugin)
+		if !ok {
+			return nil, fmt.Errorf("Plugin '%s' has an invalid Plugin symbol", path)
+		}
+
+		device = *devicePtr
+	}
+
+	if device.DeviceType() == "" {
+		return nil, fmt.Errorf("Plugin '%s' provides an empty device type", path)
+	}
+
+	return device, nil
+}
@@ -160,7 +160,7 @@ func profileGet(d *Daemon, r *http.Request) Response {
 
 		profile, err := tx.ProfileGet(project, name)
 		if err != nil {
-			return errors.Wrap(err, "Fetch profile")
+			return withNotFoundCode(api.ErrProfileNotFound, errors.Wrap(err, "Fetch profile"))
 		}
 
 		resp = db.ProfileToAPI(profile)
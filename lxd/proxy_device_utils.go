@@ -124,5 +124,23 @@ func killProxyProc(pidPath string) error {
 
 	// Cleanup
 	os.Remove(pidPath)
+	os.Remove(proxyConnectionsPath(pidPath))
 	return nil
 }
+
+// proxyConnectionsPath returns the path of the file the proxy process writes
+// its number of active connections to.
+func proxyConnectionsPath(pidPath string) string {
+	return fmt.Sprintf("%s.connections", pidPath)
+}
+
+// proxyConnectionsGet returns the number of active connections of the proxy
+// process with the given pid file.
+func proxyConnectionsGet(pidPath string) (int64, error) {
+	contents, err := ioutil.ReadFile(proxyConnectionsPath(pidPath))
+	if err != nil {
+		return -1, err
+	}
+
+	return strconv.ParseInt(strings.TrimSpace(string(contents)), 10, 64)
+}
@@ -126,6 +126,11 @@ func (r *forwardedResponse) Render(w http.ResponseWriter) error {
 		return err
 	}
 	for key := range r.request.Header {
+		// The idempotency of the request is handled by this node
+		if http.CanonicalHeaderKey(key) == idempotencyKeyHeader {
+			continue
+		}
+
 		forwarded.Header.Set(key, r.request.Header.Get(key))
 	}
 
@@ -142,9 +147,21 @@ func (r *forwardedResponse) Render(w http.ResponseWriter) error {
 		w.Header().Set(key, response.Header.Get(key))
 	}
 
+	for key := range response.Trailer {
+		w.Header().Add("Trailer", key)
+	}
+
 	w.WriteHeader(response.StatusCode)
 	_, err = io.Copy(w, response.Body)
-	return err
+	if err != nil {
+		return err
+	}
+
+	for key := range response.Trailer {
+		w.Header().Set(key, response.Trailer.Get(key))
+	}
+
+	return nil
 }
 
 func (r *forwardedResponse) String() string {
@@ -425,8 +442,66 @@ func ForwardedOperationResponse(project string, op *api.Operation) Response {
 
 // Error response
 type errorResponse struct {
-	code int
-	msg  string
+	code     int
+	msg      string
+	id       api.ErrorCode
+	metadata interface{}
+}
+
+// codedError attaches an API error code to an error, reported in the error
+// responses built from it.
+type codedError struct {
+	code api.ErrorCode
+	err  error
+}
+
+func (e *codedError) Error() string {
+	return e.err.Error()
+}
+
+// Cause lets errors.Cause see through the code.
+func (e *codedError) Cause() error {
+	return e.err
+}
+
+// withErrorCode attaches an API error code to an error.
+func withErrorCode(code api.ErrorCode, err error) error {
+	if err == nil {
+		return nil
+	}
+
+	return &codedError{code: code, err: err}
+}
+
+// withNotFoundCode attaches an API error code to an error if it's a not found
+// one, e.g. to tell which object wasn't found.
+func withNotFoundCode(code api.ErrorCode, err error) error {
+	switch errors.Cause(err) {
+	case os.ErrNotExist, sql.ErrNoRows, db.ErrNoSuchObject:
+		return withErrorCode(code, err)
+	}
+
+	return err
+}
+
+// errorCode returns the API error code attached to an error or to one of its
+// causes, or the default code.
+func errorCode(err error, defaultCode api.ErrorCode) api.ErrorCode {
+	for err != nil {
+		coded, ok := err.(*codedError)
+		if ok {
+			return coded.code
+		}
+
+		cause, ok := err.(interface{ Cause() error })
+		if !ok {
+			break
+		}
+
+		err = cause.Cause()
+	}
+
+	return defaultCode
 }
 
 func (r *errorResponse) String() string {
@@ -444,7 +519,12 @@ func (r *errorResponse) Render(w http.ResponseWriter) error {
 		output = io.MultiWriter(buf, captured)
 	}
 
-	err := json.NewEncoder(output).Encode(shared.Jmap{"type": api.ErrorResponse, "error": r.msg, "error_code": r.code})
+	resp := shared.Jmap{"type": api.ErrorResponse, "error": r.msg, "error_code": r.code, "error_id": r.id}
+	if r.metadata != nil {
+		resp["metadata"] = r.metadata
+	}
+
+	err := json.NewEncoder(output).Encode(resp)
 
 	if err != nil {
 		return err
@@ -467,7 +547,7 @@ func NotImplemented(err error) Response {
 	if err != nil {
 		message = err.Error()
 	}
-	return &errorResponse{http.StatusNotImplemented, message}
+	return &errorResponse{http.StatusNotImplemented, message, errorCode(err, api.ErrNotImplemented), nil}
 }
 
 func NotFound(err error) Response {
@@ -475,7 +555,7 @@ func NotFound(err error) Response {
 	if err != nil {
 		message = err.Error()
 	}
-	return &errorResponse{http.StatusNotFound, message}
+	return &errorResponse{http.StatusNotFound, message, errorCode(err, api.ErrNotFound), nil}
 }
 
 func Forbidden(err error) Response {
@@ -483,7 +563,7 @@ func Forbidden(err error) Response {
 	if err != nil {
 		message = err.Error()
 	}
-	return &errorResponse{http.StatusForbidden, message}
+	return &errorResponse{http.StatusForbidden, message, errorCode(err, api.ErrForbidden), nil}
 }
 
 func Conflict(err error) Response {
@@ -491,7 +571,7 @@ func Conflict(err error) Response {
 	if err != nil {
 		message = err.Error()
 	}
-	return &errorResponse{http.StatusConflict, message}
+	return &errorResponse{http.StatusConflict, message, errorCode(err, api.ErrAlreadyExists), nil}
 }
 
 func Unavailable(err error) Response {
@@ -499,19 +579,23 @@ func Unavailable(err error) Response {
 	if err != nil {
 		message = err.Error()
 	}
-	return &errorResponse{http.StatusServiceUnavailable, message}
+	return &errorResponse{http.StatusServiceUnavailable, message, errorCode(err, api.ErrUnavailable), nil}
 }
 
 func BadRequest(err error) Response {
-	return &errorResponse{http.StatusBadRequest, err.Error()}
+	return &errorResponse{http.StatusBadRequest, err.Error(), errorCode(err, api.ErrInvalidRequest), nil}
 }
 
 func InternalError(err error) Response {
-	return &errorResponse{http.StatusInternalServerError, err.Error()}
+	return &errorResponse{http.StatusInternalServerError, err.Error(), errorCode(err, api.ErrInternal), nil}
 }
 
 func PreconditionFailed(err error) Response {
-	return &errorResponse{http.StatusPreconditionFailed, err.Error()}
+	return &errorResponse{http.StatusPreconditionFailed, err.Error(), errorCode(err, api.ErrPreconditionFailed), nil}
+}
+
+func InsufficientStorage(err error, metadata interface{}) Response {
+	return &errorResponse{http.StatusInsufficientStorage, err.Error(), errorCode(err, api.ErrInsufficientStorage), metadata}
 }
 
 /*
@@ -522,11 +606,11 @@ func SmartError(err error) Response {
 	case nil:
 		return EmptySyncResponse
 	case os.ErrNotExist, sql.ErrNoRows, db.ErrNoSuchObject:
-		return NotFound(nil)
+		return &errorResponse{http.StatusNotFound, "not found", errorCode(err, api.ErrNotFound), nil}
 	case os.ErrPermission:
-		return Forbidden(nil)
+		return &errorResponse{http.StatusForbidden, "not authorized", errorCode(err, api.ErrForbidden), nil}
 	case db.ErrAlreadyDefined, sqlite3.ErrConstraintUnique:
-		return Conflict(nil)
+		return &errorResponse{http.StatusConflict, "already exists", errorCode(err, api.ErrAlreadyExists), nil}
 	case dqlite.ErrNoAvailableLeader:
 		return Unavailable(err)
 	default:
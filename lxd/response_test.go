@@ -0,0 +1,33 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"testing"
+
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/lxc/lxd/lxd/db"
+	"github.com/lxc/lxd/shared/api"
+)
+
+func TestErrorCode(t *testing.T) {
+	err := errors.Wrap(withErrorCode(api.ErrContainerRunning, fmt.Errorf("Container is running")), "Failed to delete")
+	assert.Equal(t, api.ErrContainerRunning, errorCode(err, api.ErrInvalidRequest))
+	assert.Equal(t, api.ErrInvalidRequest, errorCode(fmt.Errorf("Invalid"), api.ErrInvalidRequest))
+	assert.Equal(t, api.ErrInternal, errorCode(nil, api.ErrInternal))
+}
+
+func TestSmartErrorCode(t *testing.T) {
+	err := withNotFoundCode(api.ErrContainerNotFound, errors.Wrap(db.ErrNoSuchObject, "Failed to fetch container"))
+	resp := SmartError(err).(*errorResponse)
+	assert.Equal(t, http.StatusNotFound, resp.code)
+	assert.Equal(t, api.ErrContainerNotFound, resp.id)
+
+	// Only not found errors get the code
+	err = withNotFoundCode(api.ErrContainerNotFound, fmt.Errorf("Failed"))
+	resp = SmartError(err).(*errorResponse)
+	assert.Equal(t, http.StatusInternalServerError, resp.code)
+	assert.Equal(t, api.ErrInternal, resp.id)
+}
@@ -0,0 +1,125 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"io/ioutil"
+	"mime"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/lxc/lxd/shared"
+)
+
+// Media types accepted for YAML requests and responses
+var yamlMediaTypes = []string{"application/yaml", "application/x-yaml", "text/yaml"}
+
+func isYAMLMediaType(value string) bool {
+	mediaType, _, err := mime.ParseMediaType(value)
+	if err != nil {
+		return false
+	}
+
+	return shared.StringInSlice(mediaType, yamlMediaTypes)
+}
+
+// isYAMLRequest returns whether the request body is YAML encoded.
+func isYAMLRequest(r *http.Request) bool {
+	return isYAMLMediaType(r.Header.Get("Content-Type"))
+}
+
+// wantsYAML returns whether the client asked for a YAML encoded response.
+func wantsYAML(r *http.Request) bool {
+	for _, value := range r.Header["Accept"] {
+		for _, entry := range strings.Split(value, ",") {
+			if isYAMLMediaType(strings.TrimSpace(entry)) {
+				return true
+			}
+		}
+	}
+
+	return false
+}
+
+// yamlRequestBody replaces a YAML request body with its JSON equivalent so
+// that handlers only ever have to decode JSON.
+func yamlRequestBody(r *http.Request) error {
+	data, err := ioutil.ReadAll(r.Body)
+	if err != nil {
+		return err
+	}
+
+	body := json.RawMessage{}
+	if len(bytes.TrimSpace(data)) > 0 {
+		err = shared.UnmarshalYAML(data, &body)
+		if err != nil {
+			return err
+		}
+	}
+
+	r.Body = shared.BytesReadCloser{Buf: bytes.NewBuffer(body)}
+	r.ContentLength = int64(len(body))
+	r.Header.Set("Content-Type", "application/json")
+	r.Header.Set("Content-Length", strconv.Itoa(len(body)))
+
+	return nil
+}
+
+// yamlResponse renders a JSON response as YAML.
+type yamlResponse struct {
+	resp Response
+}
+
+// YAMLResponse wraps the given response to render it as YAML if it's one of
+// the JSON responses. Other responses (files, websockets, ...) are left as
+// they are.
+func YAMLResponse(resp Response) Response {
+	switch resp.(type) {
+	case *syncResponse, *errorResponse, *operationResponse, *forwardedOperationResponse:
+		return &yamlResponse{resp: resp}
+	}
+
+	return resp
+}
+
+func (r *yamlResponse) Render(w http.ResponseWriter) error {
+	recorder := &yamlRecorder{header: w.Header(), code: http.StatusOK}
+	err := r.resp.Render(recorder)
+	if err != nil {
+		return err
+	}
+
+	body, err := shared.MarshalYAML(json.RawMessage(recorder.body.Bytes()))
+	if err != nil {
+		return err
+	}
+
+	w.Header().Set("Content-Type", "application/yaml")
+	w.WriteHeader(recorder.code)
+	_, err = w.Write(body)
+	return err
+}
+
+func (r *yamlResponse) String() string {
+	return r.resp.String()
+}
+
+// yamlRecorder captures the JSON rendered by a response.
+type yamlRecorder struct {
+	header http.Header
+	code   int
+	body   bytes.Buffer
+}
+
+func (w *yamlRecorder) Header() http.Header {
+	return w.header
+}
+
+func (w *yamlRecorder) Write(data []byte) (int, error) {
+	return w.body.Write(data)
+}
+
+func (w *yamlRecorder) WriteHeader(code int) {
+	w.code = code
+}
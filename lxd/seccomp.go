@@ -5,6 +5,7 @@ import (
 	"io/ioutil"
 	"os"
 	"path"
+	"strings"
 
 	"github.com/lxc/lxd/shared"
 	"github.com/lxc/lxd/shared/osarch"
@@ -73,6 +74,14 @@ func ContainerNeedsSeccomp(c container) bool {
 		"security.syscalls.blacklist",
 	}
 
+	if shared.IsTrue(config["security.seccomp.log_only"]) {
+		return true
+	}
+
+	if seccompNotifyNeeded(config) {
+		return true
+	}
+
 	for _, k := range keys {
 		_, hasKey := config[k]
 		if hasKey {
@@ -106,14 +115,36 @@ func getSeccompProfileContent(c container) (string, error) {
 
 	policy := SECCOMP_HEADER
 
+	// The syscalls which aren't denied are logged instead of allowed, the
+	// whitelist being what's being learned
+	logOnly := shared.IsTrue(config["security.seccomp.log_only"])
+
+	notify := ""
+	if config["security.seccomp.path_rules"] != "" {
+		notify += SECCOMP_NOTIFY_POLICY
+	}
+
+	if shared.IsTrue(config["security.proc_filter"]) {
+		notify += SECCOMP_PROC_FILTER_POLICY
+	}
+
 	whitelist := config["security.syscalls.whitelist"]
-	if whitelist != "" {
+	if whitelist != "" && !logOnly {
 		policy += "whitelist\n[all]\n"
 		policy += whitelist
+		if !strings.HasSuffix(whitelist, "\n") {
+			policy += "\n"
+		}
+		policy += notify
 		return policy, nil
 	}
 
-	policy += "blacklist\n"
+	if logOnly {
+		policy += SECCOMP_LOG_POLICY
+	} else {
+		policy += "blacklist\n"
+	}
+	policy += notify
 
 	default_, ok := config["security.syscalls.blacklist_default"]
 	if !ok || shared.IsTrue(default_) {
@@ -0,0 +1,350 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/gorilla/mux"
+
+	"github.com/lxc/lxd/shared"
+	"github.com/lxc/lxd/shared/api"
+	"github.com/lxc/lxd/shared/logger"
+
+	log "github.com/lxc/lxd/shared/log15"
+)
+
+var containerSeccompProfileCmd = Command{
+	name: "containers/{name}/seccomp/profile",
+	post: containerSeccompProfilePost,
+}
+
+// Policy of the containers with security.seccomp.log_only, logging the
+// syscalls which aren't denied rather than allowing them
+const SECCOMP_LOG_POLICY = `blacklist log
+`
+
+// Seccomp action recorded in the audit messages of logged syscalls
+// (SECCOMP_RET_LOG)
+const seccompLogAction = "0x7ffc0000"
+
+// Audit architectures as found in the seccomp audit messages, using the
+// names understood by scmp_sys_resolver
+var seccompLogArchitectures = map[string]string{
+	"c000003e": "x86_64",
+	"40000003": "x86",
+	"c00000b7": "aarch64",
+	"40000028": "arm",
+	"c0000015": "ppc64le",
+	"80000015": "ppc64",
+	"80000016": "s390x",
+}
+
+// seccompLogRecord is a syscall logged by the container seccomp filter as
+// stored in the seccomp.log file of the container.
+type seccompLogRecord struct {
+	Timestamp    time.Time `json:"timestamp"`
+	PID          int64     `json:"pid"`
+	Command      string    `json:"command"`
+	Architecture string    `json:"architecture"`
+	Syscall      string    `json:"syscall"`
+}
+
+// seccompLogParse parses a seccomp audit message, as found in the kernel log,
+// returning nil if it isn't a syscall logged by a seccomp filter.
+func seccompLogParse(line string) (*seccompLogRecord, string, error) {
+	idx := strings.Index(line, "type=1326 ")
+	if idx < 0 {
+		return nil, "", nil
+	}
+
+	fields := map[string]string{}
+	for _, field := range strings.Fields(line[idx:]) {
+		parts := strings.SplitN(field, "=", 2)
+		if len(parts) != 2 {
+			continue
+		}
+
+		fields[parts[0]] = strings.Trim(parts[1], "\"")
+	}
+
+	if fields["code"] != seccompLogAction {
+		return nil, "", nil
+	}
+
+	pid, err := strconv.ParseInt(fields["pid"], 10, 64)
+	if err != nil {
+		return nil, "", fmt.Errorf("Invalid pid in seccomp audit message: %s", fields["pid"])
+	}
+
+	if fields["syscall"] == "" {
+		return nil, "", fmt.Errorf("Missing syscall in seccomp audit message")
+	}
+
+	record := seccompLogRecord{
+		PID:          pid,
+		Command:      fields["comm"],
+		Architecture: fields["arch"],
+	}
+
+	// audit(<seconds>.<milliseconds>:<serial>):
+	record.Timestamp = time.Now().UTC()
+	stampIdx := strings.Index(line, "audit(")
+	if stampIdx >= 0 {
+		stamp := strings.SplitN(line[stampIdx+len("audit("):], ":", 2)[0]
+		parts := strings.SplitN(stamp, ".", 2)
+		seconds, err := strconv.ParseInt(parts[0], 10, 64)
+		if err == nil {
+			millis := int64(0)
+			if len(parts) == 2 {
+				millis, _ = strconv.ParseInt(parts[1], 10, 64)
+			}
+
+			record.Timestamp = time.Unix(seconds, millis*int64(time.Millisecond)).UTC()
+		}
+	}
+
+	arch, ok := seccompLogArchitectures[record.Architecture]
+	if ok {
+		record.Architecture = arch
+	}
+
+	return &record, fields["syscall"], nil
+}
+
+// seccompLogger collects the syscalls logged by the containers running with
+// security.seccomp.log_only from the kernel log.
+type seccompLogger struct {
+	mu         sync.Mutex
+	once       sync.Once
+	containers map[string]container
+	pids       map[int64]container
+	syscalls   map[string]string
+}
+
+var seccompLog = &seccompLogger{
+	containers: map[string]container{},
+	pids:       map[int64]container{},
+	syscalls:   map[string]string{},
+}
+
+// register starts collecting the syscalls logged by a container.
+func (l *seccompLogger) register(c container) {
+	l.mu.Lock()
+	l.containers[projectPrefix(c.Project(), c.Name())] = c
+	l.mu.Unlock()
+
+	l.once.Do(func() {
+		go l.run()
+	})
+}
+
+// unregister stops collecting the syscalls logged by a container.
+func (l *seccompLogger) unregister(c container) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	delete(l.containers, projectPrefix(c.Project(), c.Name()))
+	for pid, owner := range l.pids {
+		if owner.Project() == c.Project() && owner.Name() == c.Name() {
+			delete(l.pids, pid)
+		}
+	}
+}
+
+// lookup returns the container a process belongs to. Must be called with
+// the lock held.
+func (l *seccompLogger) lookup(pid int64) container {
+	c, ok := l.pids[pid]
+	if ok {
+		return c
+	}
+
+	// Rebuild the cache from the current processes of the containers
+	l.pids = map[int64]container{}
+	for _, c := range l.containers {
+		initPID := c.InitPID()
+		if initPID <= 0 {
+			continue
+		}
+
+		for _, p := range containerProcesses(initPID) {
+			l.pids[p] = c
+		}
+	}
+
+	return l.pids[pid]
+}
+
+// syscallName resolves a syscall number, falling back to the number itself
+// if scmp_sys_resolver isn't available. Must be called with the lock held.
+func (l *seccompLogger) syscallName(arch string, nr string) string {
+	key := fmt.Sprintf("%s/%s", arch, nr)
+	name, ok := l.syscalls[key]
+	if ok {
+		return name
+	}
+
+	name = nr
+	out, err := shared.RunCommand("scmp_sys_resolver", "-a", arch, nr)
+	if err == nil && strings.TrimSpace(out) != "" {
+		name = strings.TrimSpace(out)
+	}
+
+	l.syscalls[key] = name
+	return name
+}
+
+// handle records a kernel log message in the log of the container it
+// originates from.
+func (l *seccompLogger) handle(line string) {
+	record, nr, err := seccompLogParse(line)
+	if err != nil {
+		logger.Debug("Failed to parse seccomp audit message", log.Ctx{"err": err})
+		return
+	}
+
+	if record == nil {
+		return
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if len(l.containers) == 0 {
+		return
+	}
+
+	c := l.lookup(record.PID)
+	if c == nil {
+		return
+	}
+
+	record.Syscall = l.syscallName(record.Architecture, nr)
+
+	data, err := json.Marshal(record)
+	if err != nil {
+		return
+	}
+
+	f, err := os.OpenFile(seccompLogPath(c), os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0600)
+	if err != nil {
+		logger.Error("Failed to open seccomp log", log.Ctx{"container": c.Name(), "err": err})
+		return
+	}
+	defer f.Close()
+
+	_, err = f.Write(append(data, '\n'))
+	if err != nil {
+		logger.Error("Failed to write seccomp log", log.Ctx{"container": c.Name(), "err": err})
+	}
+}
+
+// run reads the kernel log, one record per read.
+func (l *seccompLogger) run() {
+	f, err := os.Open("/dev/kmsg")
+	if err != nil {
+		logger.Error("Failed to open the kernel log, syscalls of log-only seccomp filters won't be recorded", log.Ctx{"err": err})
+		return
+	}
+	defer f.Close()
+
+	// Only new messages are of interest
+	_, err = f.Seek(0, io.SeekEnd)
+	if err != nil {
+		logger.Warn("Failed to seek to the end of the kernel log", log.Ctx{"err": err})
+	}
+
+	buf := make([]byte, 8192)
+	for {
+		n, err := f.Read(buf)
+		if err != nil {
+			// Messages were overwritten before we could read them
+			pathErr, ok := err.(*os.PathError)
+			if ok && pathErr.Err == syscall.EPIPE {
+				continue
+			}
+
+			logger.Error("Failed to read the kernel log", log.Ctx{"err": err})
+			return
+		}
+
+		l.handle(string(buf[:n]))
+	}
+}
+
+func seccompLogPath(c container) string {
+	return filepath.Join(c.LogPath(), "seccomp.log")
+}
+
+// seccompLogSyscalls returns the sorted list of the syscalls found in a
+// seccomp.log file.
+func seccompLogSyscalls(content string) []string {
+	syscalls := []string{}
+	for _, line := range strings.Split(content, "\n") {
+		if line == "" {
+			continue
+		}
+
+		record := seccompLogRecord{}
+		err := json.Unmarshal([]byte(line), &record)
+		if err != nil || record.Syscall == "" {
+			continue
+		}
+
+		if !shared.StringInSlice(record.Syscall, syscalls) {
+			syscalls = append(syscalls, record.Syscall)
+		}
+	}
+
+	sort.Strings(syscalls)
+	return syscalls
+}
+
+// /1.0/containers/<name>/seccomp/profile
+// Generate a syscall whitelist from the syscalls logged by the container
+func containerSeccompProfilePost(d *Daemon, r *http.Request) Response {
+	project := projectParam(r)
+	name := mux.Vars(r)["name"]
+
+	// Handle requests targeted to a container on a different node
+	response, err := ForwardedResponseIfContainerIsRemote(d, r, project, name)
+	if err != nil {
+		return SmartError(err)
+	}
+	if response != nil {
+		return response
+	}
+
+	c, err := containerLoadByProjectAndName(d.State(), project, name)
+	if err != nil {
+		return SmartError(err)
+	}
+
+	content, err := ioutil.ReadFile(seccompLogPath(c))
+	if err != nil {
+		if os.IsNotExist(err) {
+			return BadRequest(fmt.Errorf("No syscall was logged for the container, set security.seccomp.log_only and run the workload first"))
+		}
+
+		return SmartError(err)
+	}
+
+	syscalls := seccompLogSyscalls(string(content))
+	profile := api.ContainerSeccompProfile{
+		Syscalls:  syscalls,
+		Whitelist: strings.Join(syscalls, "\n"),
+	}
+
+	return SyncResponse(true, profile)
+}
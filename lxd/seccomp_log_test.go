@@ -0,0 +1,42 @@
+package main
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSeccompLogParse(t *testing.T) {
+	line := `5,1234,5678901,-;audit: type=1326 audit(1571234567.250:45): auid=4294967295 uid=1000000 gid=1000000 ses=4294967295 pid=4321 comm="nginx" exe="/usr/sbin/nginx" sig=0 arch=c000003e syscall=288 compat=0 ip=0x7f0a3c code=0x7ffc0000`
+
+	record, nr, err := seccompLogParse(line)
+	require.NoError(t, err)
+	require.NotNil(t, record)
+
+	assert.Equal(t, "288", nr)
+	assert.Equal(t, int64(4321), record.PID)
+	assert.Equal(t, "nginx", record.Command)
+	assert.Equal(t, "x86_64", record.Architecture)
+	assert.Equal(t, time.Unix(1571234567, 250000000).UTC(), record.Timestamp)
+
+	// Syscalls killed by a filter aren't logged ones
+	record, _, err = seccompLogParse(`audit: type=1326 audit(1571234567.250:46): pid=4321 comm="nginx" arch=c000003e syscall=246 code=0x0`)
+	assert.NoError(t, err)
+	assert.Nil(t, record)
+
+	record, _, err = seccompLogParse(`audit: type=1400 audit(1571234567.250:47): apparmor="DENIED"`)
+	assert.NoError(t, err)
+	assert.Nil(t, record)
+}
+
+func TestSeccompLogSyscalls(t *testing.T) {
+	content := `{"timestamp":"2019-10-16T14:02:47Z","pid":4321,"command":"nginx","architecture":"x86_64","syscall":"read"}
+{"timestamp":"2019-10-16T14:02:47Z","pid":4321,"command":"nginx","architecture":"x86_64","syscall":"accept4"}
+{"timestamp":"2019-10-16T14:02:48Z","pid":4322,"command":"nginx","architecture":"x86_64","syscall":"read"}
+not json
+`
+
+	assert.Equal(t, []string{"accept4", "read"}, seccompLogSyscalls(content))
+}
@@ -0,0 +1,519 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"io/ioutil"
+	"net"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"syscall"
+	"unsafe"
+
+	"github.com/lxc/lxd/shared"
+	"github.com/lxc/lxd/shared/logger"
+
+	log "github.com/lxc/lxd/shared/log15"
+)
+
+// Syscalls forwarded to LXD by the seccomp filter of the containers with
+// security.seccomp.path_rules
+const SECCOMP_NOTIFY_POLICY = `mkdir notify
+mkdirat notify
+symlink notify
+symlinkat notify
+`
+
+// Size of struct seccomp_notify_proxy_msg, the header of the messages sent by
+// LXC, followed by struct seccomp_notif, struct seccomp_notif_resp and the
+// cookie
+const seccompNotifyHeaderSize = 32
+
+// Let the kernel perform the syscall (SECCOMP_USER_NOTIF_FLAG_CONTINUE)
+const seccompUserNotifFlagContinue = 1
+
+// Intercepted syscalls per audit architecture
+var seccompNotifySyscalls = map[uint32]map[int32]string{
+	0xc000003e: {83: "mkdir", 258: "mkdirat", 88: "symlink", 266: "symlinkat", 2: "open", 257: "openat"}, // x86_64
+	0xc00000b7: {34: "mkdirat", 36: "symlinkat", 56: "openat"},                                           // aarch64
+}
+
+var seccompNativeEndian binary.ByteOrder = binary.LittleEndian
+
+func init() {
+	i := uint16(1)
+	if *(*byte)(unsafe.Pointer(&i)) == 0 {
+		seccompNativeEndian = binary.BigEndian
+	}
+}
+
+// seccompNotifyContinueSupported returns whether the kernel supports seccomp
+// user notifications and letting it perform the notified syscalls.
+func seccompNotifyContinueSupported() bool {
+	actions, err := ioutil.ReadFile("/proc/sys/kernel/seccomp/actions_avail")
+	if err != nil || !shared.StringInSlice("user_notif", strings.Fields(string(actions))) {
+		return false
+	}
+
+	uname, err := shared.Uname()
+	if err != nil {
+		return false
+	}
+
+	return seccompKernelAtLeast(uname.Release, 5, 5)
+}
+
+// seccompKernelAtLeast compares a kernel release (e.g. 5.4.0-42-generic)
+// with a major and minor version.
+func seccompKernelAtLeast(release string, major int, minor int) bool {
+	fields := strings.SplitN(release, ".", 3)
+	if len(fields) < 2 {
+		return false
+	}
+
+	relMajor, err := strconv.Atoi(fields[0])
+	if err != nil {
+		return false
+	}
+
+	relMinor, err := strconv.Atoi(strings.TrimRightFunc(fields[1], func(r rune) bool { return r < '0' || r > '9' }))
+	if err != nil {
+		return false
+	}
+
+	return relMajor > major || (relMajor == major && relMinor >= minor)
+}
+
+// seccompPathRule remaps the paths under a directory to another directory.
+type seccompPathRule struct {
+	source string
+	target string
+}
+
+// seccompParsePathRules parses the value of security.seccomp.path_rules, a
+// comma separated list of <source>=<target> directories, returning the rules
+// from the most specific source to the least specific one.
+func seccompParsePathRules(value string) ([]seccompPathRule, error) {
+	rules := []seccompPathRule{}
+	for _, entry := range strings.Split(value, ",") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+
+		fields := strings.SplitN(entry, "=", 2)
+		if len(fields) != 2 {
+			return nil, fmt.Errorf("Invalid path rule '%s', expecting <source>=<target>", entry)
+		}
+
+		source := filepath.Clean(fields[0])
+		target := filepath.Clean(fields[1])
+		if !filepath.IsAbs(source) || !filepath.IsAbs(target) {
+			return nil, fmt.Errorf("Invalid path rule '%s', paths must be absolute", entry)
+		}
+
+		if source == "/" {
+			return nil, fmt.Errorf("Invalid path rule '%s', the root directory can't be remapped", entry)
+		}
+
+		rules = append(rules, seccompPathRule{source: source, target: target})
+	}
+
+	sort.SliceStable(rules, func(i, j int) bool {
+		return len(rules[i].source) > len(rules[j].source)
+	})
+
+	return rules, nil
+}
+
+// seccompRemapPath applies the first matching rule to an absolute path,
+// returning false if no rule applies.
+func seccompRemapPath(rules []seccompPathRule, path string) (string, bool) {
+	if !filepath.IsAbs(path) {
+		return path, false
+	}
+
+	path = filepath.Clean(path)
+	for _, rule := range rules {
+		if path == rule.source {
+			return rule.target, true
+		}
+
+		if strings.HasPrefix(path, rule.source+"/") {
+			return filepath.Join(rule.target, strings.TrimPrefix(path, rule.source)), true
+		}
+	}
+
+	return path, false
+}
+
+// seccompNotifyNeeded returns whether some syscalls of a container are
+// forwarded to LXD.
+func seccompNotifyNeeded(config map[string]string) bool {
+	return config["security.seccomp.path_rules"] != "" || shared.IsTrue(config["security.proc_filter"])
+}
+
+// seccompNotifySocketPath returns the path of the socket LXC forwards the
+// intercepted syscalls of a container to.
+func seccompNotifySocketPath(c container) string {
+	return filepath.Join(seccompPath, fmt.Sprintf("%d.sock", c.Id()))
+}
+
+// seccompNotifyServer handles the syscalls of the containers with
+// security.seccomp.path_rules or security.proc_filter, forwarded by LXC
+// through a socket per container.
+type seccompNotifyServer struct {
+	mu          sync.Mutex
+	execPath    string
+	listeners   map[int]net.Listener
+	rules       map[int][]seccompPathRule
+	procFilters map[int]*seccompProcFilter
+}
+
+var seccompNotify = &seccompNotifyServer{
+	listeners:   map[int]net.Listener{},
+	rules:       map[int][]seccompPathRule{},
+	procFilters: map[int]*seccompProcFilter{},
+}
+
+// register starts listening for the syscalls of a container, before it's
+// started.
+func (s *seccompNotifyServer) register(c container, execPath string) error {
+	rules, err := seccompParsePathRules(c.ExpandedConfig()["security.seccomp.path_rules"])
+	if err != nil {
+		return err
+	}
+
+	s.unregister(c)
+
+	err = os.MkdirAll(seccompPath, 0700)
+	if err != nil {
+		return err
+	}
+
+	path := seccompNotifySocketPath(c)
+	os.Remove(path)
+
+	listener, err := net.Listen("unixpacket", path)
+	if err != nil {
+		return err
+	}
+
+	err = os.Chmod(path, 0600)
+	if err != nil {
+		listener.Close()
+		return err
+	}
+
+	s.mu.Lock()
+	s.execPath = execPath
+	s.listeners[c.Id()] = listener
+	s.rules[c.Id()] = rules
+	if shared.IsTrue(c.ExpandedConfig()["security.proc_filter"]) {
+		s.procFilters[c.Id()] = newSeccompProcFilter(c)
+	}
+	s.mu.Unlock()
+
+	go s.serve(c.Name(), c.Id(), listener)
+	return nil
+}
+
+// update replaces the path rules of a running container.
+func (s *seccompNotifyServer) update(c container) error {
+	rules, err := seccompParsePathRules(c.ExpandedConfig()["security.seccomp.path_rules"])
+	if err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	_, ok := s.listeners[c.Id()]
+	if !ok {
+		if len(rules) > 0 {
+			return fmt.Errorf("Seccomp path rules can only be added to a running container which was started with some")
+		}
+
+		return nil
+	}
+
+	s.rules[c.Id()] = rules
+	return nil
+}
+
+// unregister stops listening for the syscalls of a container.
+func (s *seccompNotifyServer) unregister(c container) {
+	s.mu.Lock()
+	listener, ok := s.listeners[c.Id()]
+	delete(s.listeners, c.Id())
+	delete(s.rules, c.Id())
+	delete(s.procFilters, c.Id())
+	s.mu.Unlock()
+
+	if ok {
+		listener.Close()
+		os.Remove(seccompNotifySocketPath(c))
+	}
+}
+
+func (s *seccompNotifyServer) serve(name string, id int, listener net.Listener) {
+	for {
+		conn, err := listener.Accept()
+		if err != nil {
+			// The listener was closed
+			return
+		}
+
+		go s.handleConn(name, id, conn.(*net.UnixConn))
+	}
+}
+
+func (s *seccompNotifyServer) handleConn(name string, id int, conn *net.UnixConn) {
+	defer conn.Close()
+
+	buf := make([]byte, 4096)
+	oob := make([]byte, syscall.CmsgSpace(4))
+	for {
+		n, oobn, _, _, err := conn.ReadMsgUnix(buf, oob)
+		if err != nil || n == 0 {
+			return
+		}
+
+		// LXC passes /proc/<pid>/mem to read the syscall arguments from
+		memFd := -1
+		msgs, err := syscall.ParseSocketControlMessage(oob[:oobn])
+		if err == nil && len(msgs) > 0 {
+			fds, err := syscall.ParseUnixRights(&msgs[0])
+			if err == nil && len(fds) > 0 {
+				memFd = fds[0]
+				for _, fd := range fds[1:] {
+					syscall.Close(fd)
+				}
+			}
+		}
+
+		s.mu.Lock()
+		rules := s.rules[id]
+		procFilter := s.procFilters[id]
+		execPath := s.execPath
+		s.mu.Unlock()
+
+		err = seccompNotifyHandle(execPath, buf[:n], memFd, rules, procFilter)
+		if memFd >= 0 {
+			syscall.Close(memFd)
+		}
+		if err != nil {
+			logger.Warn("Failed to handle intercepted syscall", log.Ctx{"container": name, "err": err})
+		}
+
+		_, err = conn.Write(buf[:n])
+		if err != nil {
+			return
+		}
+	}
+}
+
+// seccompNotifyHandle handles a syscall forwarded by LXC, filling in the
+// response part of the message. Unless the syscall is performed with
+// remapped paths or denied access to the /proc entry of a foreign process,
+// the kernel is let to perform it.
+func seccompNotifyHandle(execPath string, msg []byte, memFd int, rules []seccompPathRule, procFilter *seccompProcFilter) error {
+	if len(msg) < seccompNotifyHeaderSize {
+		return fmt.Errorf("Short message (%d bytes)", len(msg))
+	}
+
+	notifSize := int(seccompNativeEndian.Uint16(msg[16:18]))
+	respSize := int(seccompNativeEndian.Uint16(msg[18:20]))
+	if notifSize < 80 || respSize < 24 || len(msg) < seccompNotifyHeaderSize+notifSize+respSize {
+		return fmt.Errorf("Invalid message sizes")
+	}
+
+	notif := msg[seccompNotifyHeaderSize : seccompNotifyHeaderSize+notifSize]
+	resp := msg[seccompNotifyHeaderSize+notifSize : seccompNotifyHeaderSize+notifSize+respSize]
+
+	// struct seccomp_notif_resp, letting the kernel perform the syscall
+	// unless told otherwise
+	for i := range resp {
+		resp[i] = 0
+	}
+	copy(resp[0:8], notif[0:8])
+	seccompNativeEndian.PutUint32(resp[20:24], seccompUserNotifFlagContinue)
+
+	// struct seccomp_notif
+	pid := seccompNativeEndian.Uint32(notif[8:12])
+	nr := int32(seccompNativeEndian.Uint32(notif[16:20]))
+	arch := seccompNativeEndian.Uint32(notif[20:24])
+	args := make([]uint64, 6)
+	for i := range args {
+		args[i] = seccompNativeEndian.Uint64(notif[32+8*i : 40+8*i])
+	}
+
+	syscallName := seccompNotifySyscalls[arch][nr]
+	if syscallName == "" || memFd < 0 {
+		return nil
+	}
+
+	// Only absolute paths are remapped, the directory file descriptor of
+	// the *at syscalls is then ignored
+	pathArg := map[string]int{"mkdir": 0, "mkdirat": 1, "symlink": 1, "symlinkat": 2, "open": 0, "openat": 1}[syscallName]
+	path, err := seccompReadString(memFd, args[pathArg])
+	if err != nil {
+		return err
+	}
+
+	if strings.HasPrefix(syscallName, "open") {
+		if procFilter == nil {
+			return nil
+		}
+
+		// Relative paths, such as from a file descriptor of /proc, are
+		// filtered as well
+		dirfd := seccompAtFdcwd
+		if syscallName == "openat" {
+			dirfd = int(int32(args[0]))
+		}
+
+		path, err = seccompResolvePath(pid, dirfd, path)
+		if err != nil {
+			return err
+		}
+
+		procPid, ok := seccompProcPid(path)
+		if !ok {
+			return nil
+		}
+
+		allowed, err := procFilter.allowed(pid, procPid)
+		if !allowed {
+			errno := syscall.ENOENT
+			seccompNativeEndian.PutUint32(resp[20:24], 0)
+			seccompNativeEndian.PutUint32(resp[16:20], uint32(-int32(errno)))
+		}
+
+		return err
+	}
+
+	remapped, ok := seccompRemapPath(rules, path)
+	if !ok {
+		return nil
+	}
+
+	uid, gid, umask, err := seccompProcessCredentials(pid)
+	if err != nil {
+		return err
+	}
+
+	var cmdArgs []string
+	if strings.HasPrefix(syscallName, "mkdir") {
+		mode := args[pathArg+1] & 07777 &^ uint64(umask)
+		cmdArgs = []string{"mkdir", fmt.Sprintf("%d", pid), fmt.Sprintf("%d", uid), fmt.Sprintf("%d", gid), fmt.Sprintf("%o", mode), remapped}
+	} else {
+		target, err := seccompReadString(memFd, args[0])
+		if err != nil {
+			return err
+		}
+
+		target, _ = seccompRemapPath(rules, target)
+		cmdArgs = []string{"symlink", fmt.Sprintf("%d", pid), fmt.Sprintf("%d", uid), fmt.Sprintf("%d", gid), target, remapped}
+	}
+
+	errno := seccompForkSyscall(execPath, cmdArgs)
+	seccompNativeEndian.PutUint32(resp[20:24], 0)
+	if errno != 0 {
+		seccompNativeEndian.PutUint32(resp[16:20], uint32(-int32(errno)))
+	}
+
+	return nil
+}
+
+// seccompForkSyscall performs a syscall on behalf of a process of a
+// container, returning its errno.
+func seccompForkSyscall(execPath string, args []string) syscall.Errno {
+	_, err := shared.RunCommand(execPath, append([]string{"forksyscall"}, args...)...)
+	if err == nil {
+		return 0
+	}
+
+	runErr, ok := err.(shared.RunError)
+	if !ok {
+		return syscall.EPERM
+	}
+
+	exitErr, ok := runErr.Err.(*exec.ExitError)
+	if !ok {
+		return syscall.EPERM
+	}
+
+	status, ok := exitErr.Sys().(syscall.WaitStatus)
+	if !ok || status.ExitStatus() <= 0 {
+		return syscall.EPERM
+	}
+
+	return syscall.Errno(status.ExitStatus())
+}
+
+// seccompReadString reads a NUL terminated string from the memory of a
+// process.
+func seccompReadString(memFd int, addr uint64) (string, error) {
+	buf := make([]byte, 4096)
+	n, err := syscall.Pread(memFd, buf, int64(addr))
+	if err != nil {
+		return "", err
+	}
+
+	end := bytes.IndexByte(buf[:n], 0)
+	if end < 0 {
+		return "", fmt.Errorf("Path too long")
+	}
+
+	return string(buf[:end]), nil
+}
+
+// seccompProcessCredentials returns the filesystem uid and gid (as seen from
+// the host) and umask of a process.
+func seccompProcessCredentials(pid uint32) (int64, int64, int64, error) {
+	content, err := ioutil.ReadFile(fmt.Sprintf("/proc/%d/status", pid))
+	if err != nil {
+		return -1, -1, -1, err
+	}
+
+	uid := int64(-1)
+	gid := int64(-1)
+	umask := int64(022)
+	scan := bufio.NewScanner(bytes.NewReader(content))
+	for scan.Scan() {
+		fields := strings.Fields(scan.Text())
+		if len(fields) < 2 {
+			continue
+		}
+
+		switch fields[0] {
+		case "Umask:":
+			umask, err = strconv.ParseInt(fields[1], 8, 64)
+		case "Uid:":
+			if len(fields) == 5 {
+				uid, err = strconv.ParseInt(fields[4], 10, 64)
+			}
+		case "Gid:":
+			if len(fields) == 5 {
+				gid, err = strconv.ParseInt(fields[4], 10, 64)
+			}
+		}
+		if err != nil {
+			return -1, -1, -1, err
+		}
+	}
+
+	if uid < 0 || gid < 0 {
+		return -1, -1, -1, fmt.Errorf("Failed to get the credentials of process %d", pid)
+	}
+
+	return uid, gid, umask, nil
+}
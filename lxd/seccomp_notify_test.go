@@ -0,0 +1,54 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSeccompParsePathRules(t *testing.T) {
+	rules, err := seccompParsePathRules("/usr=/overlay/usr, /usr/local/=/srv/local")
+	require.NoError(t, err)
+	assert.Equal(t, []seccompPathRule{
+		{source: "/usr/local", target: "/srv/local"},
+		{source: "/usr", target: "/overlay/usr"},
+	}, rules)
+
+	_, err = seccompParsePathRules("/usr")
+	assert.Error(t, err)
+
+	_, err = seccompParsePathRules("usr=/overlay/usr")
+	assert.Error(t, err)
+
+	_, err = seccompParsePathRules("/=/overlay")
+	assert.Error(t, err)
+}
+
+func TestSeccompRemapPath(t *testing.T) {
+	rules, err := seccompParsePathRules("/usr=/overlay/usr,/usr/local=/srv/local")
+	require.NoError(t, err)
+
+	path, ok := seccompRemapPath(rules, "/usr/local/bin")
+	assert.True(t, ok)
+	assert.Equal(t, "/srv/local/bin", path)
+
+	path, ok = seccompRemapPath(rules, "/usr")
+	assert.True(t, ok)
+	assert.Equal(t, "/overlay/usr", path)
+
+	_, ok = seccompRemapPath(rules, "/usrlocal")
+	assert.False(t, ok)
+
+	_, ok = seccompRemapPath(rules, "usr/local")
+	assert.False(t, ok)
+}
+
+func TestSeccompKernelAtLeast(t *testing.T) {
+	assert.True(t, seccompKernelAtLeast("5.5.0", 5, 5))
+	assert.True(t, seccompKernelAtLeast("5.15.0-91-generic", 5, 5))
+	assert.True(t, seccompKernelAtLeast("6.1.0", 5, 5))
+	assert.False(t, seccompKernelAtLeast("5.4.0-42-generic", 5, 5))
+	assert.False(t, seccompKernelAtLeast("4.19.0", 5, 5))
+	assert.False(t, seccompKernelAtLeast("invalid", 5, 5))
+}
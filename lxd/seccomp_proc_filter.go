@@ -0,0 +1,258 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/lxc/lxd/shared"
+)
+
+// Syscalls forwarded to LXD by the seccomp filter of the containers with
+// security.proc_filter
+const SECCOMP_PROC_FILTER_POLICY = `open notify
+openat notify
+`
+
+// How long the PIDs of a container are trusted before being listed again,
+// which is also how long a PID found not to belong to it stays denied
+const seccompProcFilterMaxAge = time.Second
+
+// How long unknown PIDs are denied without listing the PIDs of a container
+// again, so that a container looking up random PIDs can't have them listed
+// more than a few times per second
+const seccompProcFilterMinAge = 100 * time.Millisecond
+
+// Value of the directory file descriptor of the *at syscalls standing for
+// the working directory
+const seccompAtFdcwd = -100
+
+// seccompResolvePath returns the absolute path, as seen by a process, of a
+// path relative to one of its directory file descriptors, or to its working
+// directory for seccompAtFdcwd.
+func seccompResolvePath(pid uint32, dirfd int, path string) (string, error) {
+	if filepath.IsAbs(path) {
+		return path, nil
+	}
+
+	link := fmt.Sprintf("/proc/%d/cwd", pid)
+	if dirfd != seccompAtFdcwd {
+		link = fmt.Sprintf("/proc/%d/fd/%d", pid, dirfd)
+	}
+
+	dir, err := os.Readlink(link)
+	if err != nil {
+		return "", err
+	}
+
+	return filepath.Join(dir, path), nil
+}
+
+// seccompProcPid returns the PID of an absolute /proc/<pid> path, returning
+// false for any other path (including /proc/self).
+func seccompProcPid(path string) (int, bool) {
+	if !strings.HasPrefix(path, "/proc/") {
+		return 0, false
+	}
+
+	path = filepath.Clean(path)
+	if !strings.HasPrefix(path, "/proc/") {
+		return 0, false
+	}
+
+	name := strings.TrimPrefix(path, "/proc/")
+	end := strings.IndexByte(name, '/')
+	if end >= 0 {
+		name = name[:end]
+	}
+
+	pid, err := strconv.Atoi(name)
+	if err != nil || pid <= 0 {
+		return 0, false
+	}
+
+	return pid, true
+}
+
+// seccompNSpid returns the NSpid field of /proc/<pid>/status, the PIDs of a
+// task from the outermost to the innermost PID namespace.
+func seccompNSpid(status []byte) ([]int, error) {
+	scan := bufio.NewScanner(bytes.NewReader(status))
+	for scan.Scan() {
+		fields := strings.Fields(scan.Text())
+		if len(fields) < 2 || fields[0] != "NSpid:" {
+			continue
+		}
+
+		pids := []int{}
+		for _, field := range fields[1:] {
+			pid, err := strconv.Atoi(field)
+			if err != nil {
+				return nil, err
+			}
+
+			pids = append(pids, pid)
+		}
+
+		return pids, nil
+	}
+
+	return nil, fmt.Errorf("No NSpid field")
+}
+
+// seccompProcFilterScan returns the PIDs of the tasks of a cgroup tree, as
+// seen from the PID namespace at the given level.
+func seccompProcFilterScan(cgroup string, level int) (map[int]bool, error) {
+	pids := map[int]bool{}
+	err := filepath.Walk(cgroup, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			// Cgroups come and go
+			return nil
+		}
+
+		if info.IsDir() || info.Name() != "cgroup.procs" {
+			return nil
+		}
+
+		content, err := ioutil.ReadFile(path)
+		if err != nil {
+			return nil
+		}
+
+		for _, line := range strings.Fields(string(content)) {
+			tasks, err := ioutil.ReadDir(fmt.Sprintf("/proc/%s/task", line))
+			if err != nil {
+				continue
+			}
+
+			for _, task := range tasks {
+				status, err := ioutil.ReadFile(fmt.Sprintf("/proc/%s/task/%s/status", line, task.Name()))
+				if err != nil {
+					continue
+				}
+
+				nspid, err := seccompNSpid(status)
+				if err != nil || len(nspid) <= level {
+					continue
+				}
+
+				pids[nspid[level]] = true
+			}
+		}
+
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return pids, nil
+}
+
+// seccompProcFilter tells whether the processes of a container may access
+// the /proc entry of a PID, only the PIDs of the tasks of the container's
+// cgroup being allowed.
+type seccompProcFilter struct {
+	mu sync.Mutex
+
+	cgroup func() (string, error)
+	scan   func(cgroup string, level int) (map[int]bool, error)
+
+	// Level of the PID namespace of the container, -1 until known
+	level int
+
+	pids    map[int]bool
+	updated time.Time
+
+	// PIDs found not to belong to the container, by when they were found so
+	denied map[int]time.Time
+}
+
+func newSeccompProcFilter(c container) *seccompProcFilter {
+	return &seccompProcFilter{
+		cgroup: func() (string, error) {
+			return ebpfCgroupPath(c.InitPID())
+		},
+		scan:   seccompProcFilterScan,
+		level:  -1,
+		denied: map[int]time.Time{},
+	}
+}
+
+// allowed returns whether the given process of the container may access the
+// /proc entry of a PID. The PIDs of the container are listed again when not
+// found or no longer fresh, so that new processes are only hidden for a
+// fraction of a second. Those rescans are rate limited, a PID found not to
+// belong to the container staying denied until the PIDs are no longer fresh.
+func (f *seccompProcFilter) allowed(caller uint32, pid int) (bool, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	now := time.Now()
+	age := now.Sub(f.updated)
+	if f.pids[pid] && age < seccompProcFilterMaxAge {
+		return true, nil
+	}
+
+	if !f.pids[pid] && age < seccompProcFilterMinAge {
+		return false, nil
+	}
+
+	denied, ok := f.denied[pid]
+	if ok && now.Sub(denied) < seccompProcFilterMaxAge {
+		return false, nil
+	}
+
+	if f.level < 0 {
+		status, err := ioutil.ReadFile(fmt.Sprintf("/proc/%d/status", caller))
+		if err != nil {
+			return true, err
+		}
+
+		nspid, err := seccompNSpid(status)
+		if err != nil {
+			return true, err
+		}
+
+		f.level = len(nspid) - 1
+	}
+
+	cgroup, err := f.cgroup()
+	if err != nil {
+		return true, err
+	}
+
+	pids, err := f.scan(cgroup, f.level)
+	if err != nil {
+		return true, err
+	}
+
+	f.pids = pids
+	f.updated = now
+
+	for deniedPid, denied := range f.denied {
+		if now.Sub(denied) >= seccompProcFilterMaxAge {
+			delete(f.denied, deniedPid)
+		}
+	}
+
+	if !f.pids[pid] {
+		f.denied[pid] = now
+		return false, nil
+	}
+
+	return true, nil
+}
+
+// seccompProcFilterSupported returns whether the cgroup2 hierarchy the
+// processes of the containers are listed from is mounted.
+func seccompProcFilterSupported() bool {
+	return shared.PathExists("/sys/fs/cgroup/cgroup.controllers") || shared.PathExists("/sys/fs/cgroup/unified/cgroup.controllers")
+}
@@ -0,0 +1,244 @@
+package main
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSeccompProcPid(t *testing.T) {
+	cases := map[string]int{
+		"/proc/42":            42,
+		"/proc/42/status":     42,
+		"/proc/42/task/43/fd": 42,
+		"/proc//42/./environ": 42,
+		"/proc/self/status":   0,
+		"/proc/cpuinfo":       0,
+		"/proc/0/status":      0,
+		"/proc/../etc/42":     0,
+		"/etc/passwd":         0,
+		"proc/42/status":      0,
+	}
+
+	for path, expected := range cases {
+		pid, ok := seccompProcPid(path)
+		assert.Equal(t, expected != 0, ok, path)
+		assert.Equal(t, expected, pid, path)
+	}
+}
+
+func TestSeccompNSpid(t *testing.T) {
+	pids, err := seccompNSpid([]byte("Name:\tbash\nPid:\t12345\nNSpid:\t12345\t7\t1\nUid:\t0\t0\t0\t0\n"))
+	require.NoError(t, err)
+	assert.Equal(t, []int{12345, 7, 1}, pids)
+
+	_, err = seccompNSpid([]byte("Name:\tbash\nPid:\t12345\n"))
+	assert.Error(t, err)
+}
+
+// newTestSeccompProcFilter returns a filter of a container whose tasks have
+// the given PIDs, counting the scans of its cgroup.
+func newTestSeccompProcFilter(pids map[int]bool, scans *int) *seccompProcFilter {
+	return &seccompProcFilter{
+		cgroup: func() (string, error) {
+			return "/sys/fs/cgroup/lxc.payload/c1", nil
+		},
+		scan: func(cgroup string, level int) (map[int]bool, error) {
+			*scans++
+
+			result := map[int]bool{}
+			for pid := range pids {
+				result[pid] = true
+			}
+
+			return result, nil
+		},
+		level:  1,
+		denied: map[int]time.Time{},
+	}
+}
+
+func TestSeccompProcFilterAllowed(t *testing.T) {
+	scans := 0
+	pids := map[int]bool{1: true, 42: true}
+	filter := newTestSeccompProcFilter(pids, &scans)
+
+	allowed, err := filter.allowed(1000, 42)
+	require.NoError(t, err)
+	assert.True(t, allowed)
+	assert.Equal(t, 1, scans)
+
+	// Cached
+	allowed, err = filter.allowed(1000, 1)
+	require.NoError(t, err)
+	assert.True(t, allowed)
+	assert.Equal(t, 1, scans)
+
+	// Unknown PIDs are denied right after the PIDs were listed
+	pids[4242] = true
+	allowed, err = filter.allowed(1000, 4242)
+	require.NoError(t, err)
+	assert.False(t, allowed)
+	assert.Equal(t, 1, scans)
+
+	// And looked up again later on
+	filter.updated = filter.updated.Add(-seccompProcFilterMinAge)
+	allowed, err = filter.allowed(1000, 4242)
+	require.NoError(t, err)
+	assert.True(t, allowed)
+	assert.Equal(t, 2, scans)
+
+	filter.updated = filter.updated.Add(-seccompProcFilterMinAge)
+	allowed, err = filter.allowed(1000, 4343)
+	require.NoError(t, err)
+	assert.False(t, allowed)
+	assert.Equal(t, 3, scans)
+
+	// PIDs found not to belong to the container don't trigger rescans
+	filter.updated = filter.updated.Add(-seccompProcFilterMinAge)
+	pids[4343] = true
+	allowed, err = filter.allowed(1000, 4343)
+	require.NoError(t, err)
+	assert.False(t, allowed)
+	assert.Equal(t, 3, scans)
+
+	// Known PIDs are listed again once no longer fresh
+	filter.updated = filter.updated.Add(-seccompProcFilterMaxAge)
+	allowed, err = filter.allowed(1000, 42)
+	require.NoError(t, err)
+	assert.True(t, allowed)
+	assert.Equal(t, 4, scans)
+
+	// Which lets through the PIDs denied before then
+	allowed, err = filter.allowed(1000, 4343)
+	require.NoError(t, err)
+	assert.True(t, allowed)
+	assert.Equal(t, 4, scans)
+
+	// PIDs denied for long enough trigger rescans again
+	filter.denied[4444] = time.Now().Add(-seccompProcFilterMaxAge)
+	filter.updated = filter.updated.Add(-seccompProcFilterMinAge)
+	allowed, err = filter.allowed(1000, 4444)
+	require.NoError(t, err)
+	assert.False(t, allowed)
+	assert.Equal(t, 5, scans)
+}
+
+func TestSeccompResolvePath(t *testing.T) {
+	pid := uint32(os.Getpid())
+
+	path, err := seccompResolvePath(pid, seccompAtFdcwd, "/proc/42/status")
+	require.NoError(t, err)
+	assert.Equal(t, "/proc/42/status", path)
+
+	cwd, err := os.Getwd()
+	require.NoError(t, err)
+
+	path, err = seccompResolvePath(pid, seccompAtFdcwd, "42/status")
+	require.NoError(t, err)
+	assert.Equal(t, filepath.Join(cwd, "42/status"), path)
+
+	dir, err := os.Open("/proc")
+	require.NoError(t, err)
+	defer dir.Close()
+
+	path, err = seccompResolvePath(pid, int(dir.Fd()), "42/status")
+	require.NoError(t, err)
+	assert.Equal(t, "/proc/42/status", path)
+}
+
+// seccompTestOpenat returns a message forwarded by LXC for an openat
+// syscall of the given process, the path being at the given address.
+func seccompTestOpenat(pid uint32, addr uint64) []byte {
+	msg := make([]byte, seccompNotifyHeaderSize+80+24)
+	seccompNativeEndian.PutUint16(msg[16:18], 80)
+	seccompNativeEndian.PutUint16(msg[18:20], 24)
+
+	notif := msg[seccompNotifyHeaderSize:]
+	seccompNativeEndian.PutUint64(notif[0:8], 1)
+	seccompNativeEndian.PutUint32(notif[8:12], pid)
+	seccompNativeEndian.PutUint32(notif[16:20], 257)
+	seccompNativeEndian.PutUint32(notif[20:24], 0xc000003e)
+	seccompNativeEndian.PutUint64(notif[32:40], uint64(seccompAtFdcwd&0xffffffff))
+	seccompNativeEndian.PutUint64(notif[40:48], addr)
+
+	return msg
+}
+
+// seccompTestMemFd returns a file standing for the memory of a process,
+// holding the given strings at addresses 0, 4096, ...
+func seccompTestMemFd(t testing.TB, paths ...string) (*os.File, func()) {
+	f, err := ioutil.TempFile("", "lxd-seccomp-")
+	require.NoError(t, err)
+
+	for i, path := range paths {
+		_, err := f.WriteAt(append([]byte(path), 0), int64(i*4096))
+		require.NoError(t, err)
+	}
+
+	return f, func() {
+		f.Close()
+		os.Remove(f.Name())
+	}
+}
+
+func TestSeccompNotifyHandleProcFilter(t *testing.T) {
+	mem, cleanup := seccompTestMemFd(t, "/proc/42/status", "/proc/4242/status", "/etc/passwd")
+	defer cleanup()
+
+	scans := 0
+	filter := newTestSeccompProcFilter(map[int]bool{1: true, 42: true}, &scans)
+
+	cases := []struct {
+		addr  uint64
+		errno syscall.Errno
+	}{
+		{0, 0},
+		{4096, syscall.ENOENT},
+		{8192, 0},
+	}
+
+	for _, c := range cases {
+		msg := seccompTestOpenat(1000, c.addr)
+		err := seccompNotifyHandle("", msg, int(mem.Fd()), nil, filter)
+		require.NoError(t, err)
+
+		resp := msg[seccompNotifyHeaderSize+80:]
+		if c.errno == 0 {
+			assert.Equal(t, uint32(seccompUserNotifFlagContinue), seccompNativeEndian.Uint32(resp[20:24]))
+			assert.Equal(t, uint32(0), seccompNativeEndian.Uint32(resp[16:20]))
+		} else {
+			assert.Equal(t, uint32(0), seccompNativeEndian.Uint32(resp[20:24]))
+			assert.Equal(t, uint32(-int32(c.errno)), seccompNativeEndian.Uint32(resp[16:20]))
+		}
+	}
+}
+
+// BenchmarkSeccompProcFilter measures the handling of an openat syscall
+// denied access to the /proc entry of a foreign process.
+func BenchmarkSeccompProcFilter(b *testing.B) {
+	mem, cleanup := seccompTestMemFd(b, "/proc/4242/status")
+	defer cleanup()
+
+	scans := 0
+	pids := map[int]bool{}
+	for i := 1; i < 200; i++ {
+		pids[i] = true
+	}
+	filter := newTestSeccompProcFilter(pids, &scans)
+
+	msg := seccompTestOpenat(1000, 0)
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		err := seccompNotifyHandle("", msg, int(mem.Fd()), nil, filter)
+		if err != nil {
+			b.Fatal(err)
+		}
+	}
+}
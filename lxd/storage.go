@@ -178,6 +178,11 @@ type storage interface {
 	ContainerCanRestore(target container, source container) error
 	ContainerDelete(c container) error
 	ContainerCopy(target container, source container, containerOnly bool) error
+	// ContainerCopyModes returns the modes ("copy", "reflink" or
+	// "snapshot") supported to copy a container within the pool, the most
+	// efficient one first.
+	ContainerCopyModes() []string
+	ContainerCopyWithMode(target container, source container, containerOnly bool, mode string) error
 	ContainerRefresh(target container, source container, snapshots []container) error
 	ContainerMount(c container) (bool, error)
 	ContainerUmount(c container, path string) (bool, error)
@@ -34,11 +34,16 @@ type storageBtrfs struct {
 var btrfsVersion = ""
 
 func (s *storageBtrfs) getBtrfsMountOptions() string {
+	mountOptions := "user_subvol_rm_allowed"
 	if s.pool.Config["btrfs.mount_options"] != "" {
-		return s.pool.Config["btrfs.mount_options"]
+		mountOptions = s.pool.Config["btrfs.mount_options"]
 	}
 
-	return "user_subvol_rm_allowed"
+	if s.pool.Config["compression"] != "" {
+		mountOptions = fmt.Sprintf("%s,compress=%s", mountOptions, btrfsCompressionAlgorithm(s.pool.Config["compression"]))
+	}
+
+	return mountOptions
 }
 
 func (s *storageBtrfs) setBtrfsMountOptions(mountOptions string) {
@@ -523,8 +528,9 @@ func (s *storageBtrfs) StoragePoolUpdate(writable *api.StoragePoolPut,
 
 	// "rsync.bwlimit" requires no on-disk modifications.
 
-	if shared.StringInSlice("btrfs.mount_options", changedConfig) {
+	if shared.StringInSlice("btrfs.mount_options", changedConfig) || shared.StringInSlice("compression", changedConfig) {
 		s.setBtrfsMountOptions(writable.Config["btrfs.mount_options"])
+		s.pool.Config["compression"] = writable.Config["compression"]
 		s.remount |= syscall.MS_REMOUNT
 		_, err := s.StoragePoolMount()
 		if err != nil {
@@ -1206,6 +1212,41 @@ func (s *storageBtrfs) ContainerCopy(target container, source container, contain
 	return nil
 }
 
+func (s *storageBtrfs) ContainerCopyModes() []string {
+	return []string{"snapshot", "copy"}
+}
+
+func (s *storageBtrfs) ContainerCopyWithMode(target container, source container, containerOnly bool, mode string) error {
+	if mode != "copy" {
+		return s.ContainerCopy(target, source, containerOnly)
+	}
+
+	logger.Debugf("Fully copying BTRFS container storage %s to %s", source.Name(), target.Name())
+
+	// The storage pool needs to be mounted.
+	_, err := s.StoragePoolMount()
+	if err != nil {
+		return err
+	}
+
+	ourStart, err := source.StorageStart()
+	if err != nil {
+		return err
+	}
+	if ourStart {
+		defer source.StorageStop()
+	}
+
+	// The rsync based copy used across pools makes full copies
+	err = s.doCrossPoolContainerCopy(target, source, containerOnly, false, nil)
+	if err != nil {
+		return err
+	}
+
+	logger.Debugf("Fully copied BTRFS container storage %s to %s", source.Name(), target.Name())
+	return nil
+}
+
 func (s *storageBtrfs) ContainerRefresh(target container, source container, snapshots []container) error {
 	logger.Debugf("Refreshing BTRFS container storage for %s from %s", target.Name(), source.Name())
 
@@ -1399,6 +1399,35 @@ func (s *storageCeph) ContainerCopy(target container, source container,
 	return nil
 }
 
+func (s *storageCeph) ContainerCopyModes() []string {
+	return []string{"snapshot", "copy"}
+}
+
+func (s *storageCeph) ContainerCopyWithMode(target container, source container, containerOnly bool, mode string) error {
+	if mode != "copy" {
+		return s.ContainerCopy(target, source, containerOnly)
+	}
+
+	logger.Debugf("Fully copying RBD container storage %s to %s", source.Name(), target.Name())
+
+	ourStart, err := source.StorageStart()
+	if err != nil {
+		return err
+	}
+	if ourStart {
+		defer source.StorageStop()
+	}
+
+	// The rsync based copy used across pools makes full copies
+	err = s.doCrossPoolContainerCopy(target, source, containerOnly, false, nil)
+	if err != nil {
+		return err
+	}
+
+	logger.Debugf("Fully copied RBD container storage %s to %s", source.Name(), target.Name())
+	return nil
+}
+
 func (s *storageCeph) ContainerRefresh(target container, source container, snapshots []container) error {
 	logger.Debugf(`Refreshing RBD container storage for %s from %s`, target.Name(), source.Name())
 
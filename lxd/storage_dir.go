@@ -648,7 +648,7 @@ func (s *storageDir) ContainerDelete(container container) error {
 	return nil
 }
 
-func (s *storageDir) copyContainer(target container, source container) error {
+func (s *storageDir) copyContainer(target container, source container, reflink bool) error {
 	_, sourcePool, _ := source.Storage().GetContainerPoolInfo()
 	_, targetPool, _ := target.Storage().GetContainerPoolInfo()
 	sourceContainerMntPoint := getContainerMountPoint(source.Project(), sourcePool, source.Name())
@@ -667,10 +667,17 @@ func (s *storageDir) copyContainer(target container, source container) error {
 		return err
 	}
 
-	bwlimit := s.pool.Config["rsync.bwlimit"]
-	output, err := rsyncLocalCopy(sourceContainerMntPoint, targetContainerMntPoint, bwlimit)
-	if err != nil {
-		return fmt.Errorf("failed to rsync container: %s: %s", string(output), err)
+	if reflink {
+		err = reflinkLocalCopy(sourceContainerMntPoint, targetContainerMntPoint)
+		if err != nil {
+			return err
+		}
+	} else {
+		bwlimit := s.pool.Config["rsync.bwlimit"]
+		output, err := rsyncLocalCopy(sourceContainerMntPoint, targetContainerMntPoint, bwlimit)
+		if err != nil {
+			return fmt.Errorf("failed to rsync container: %s: %s", string(output), err)
+		}
 	}
 
 	err = target.TemplateApply("copy")
@@ -681,7 +688,7 @@ func (s *storageDir) copyContainer(target container, source container) error {
 	return nil
 }
 
-func (s *storageDir) copySnapshot(target container, targetPool string, source container, sourcePool string) error {
+func (s *storageDir) copySnapshot(target container, targetPool string, source container, sourcePool string, reflink bool) error {
 	sourceName := source.Name()
 	targetName := target.Name()
 	sourceContainerMntPoint := getSnapshotMountPoint(source.Project(), sourcePool, sourceName)
@@ -696,6 +703,10 @@ func (s *storageDir) copySnapshot(target container, targetPool string, source co
 		return err
 	}
 
+	if reflink {
+		return reflinkLocalCopy(sourceContainerMntPoint, targetContainerMntPoint)
+	}
+
 	bwlimit := s.pool.Config["rsync.bwlimit"]
 	output, err := rsyncLocalCopy(sourceContainerMntPoint, targetContainerMntPoint, bwlimit)
 	if err != nil {
@@ -705,10 +716,44 @@ func (s *storageDir) copySnapshot(target container, targetPool string, source co
 	return nil
 }
 
+// reflinkLocalCopy copies a directory using copy-on-write reflinks, this
+// requires the backing filesystem to support them (btrfs or XFS).
+func reflinkLocalCopy(source string, dest string) error {
+	err := os.MkdirAll(dest, 0755)
+	if err != nil {
+		return err
+	}
+
+	output, err := shared.RunCommand("cp", "-a", "--reflink=always", fmt.Sprintf("%s/.", source), dest)
+	if err != nil {
+		return fmt.Errorf("failed to reflink copy container: %s: %s", output, err)
+	}
+
+	return nil
+}
+
+// dirReflinkSupported checks whether reflinks can be created at the given path.
+func dirReflinkSupported(path string) bool {
+	f, err := ioutil.TempFile(path, ".lxd_reflink_")
+	if err != nil {
+		return false
+	}
+	f.Close()
+	defer os.Remove(f.Name())
+
+	_, err = shared.RunCommand("cp", "--reflink=always", f.Name(), f.Name()+".copy")
+	if err != nil {
+		return false
+	}
+	os.Remove(f.Name() + ".copy")
+
+	return true
+}
+
 func (s *storageDir) ContainerCopy(target container, source container, containerOnly bool) error {
 	logger.Debugf("Copying DIR container storage %s to %s", source.Name(), target.Name())
 
-	err := s.doContainerCopy(target, source, containerOnly, false, nil)
+	err := s.doContainerCopy(target, source, containerOnly, false, nil, false)
 	if err != nil {
 		return err
 	}
@@ -717,7 +762,37 @@ func (s *storageDir) ContainerCopy(target container, source container, container
 	return nil
 }
 
-func (s *storageDir) doContainerCopy(target container, source container, containerOnly bool, refresh bool, refreshSnapshots []container) error {
+// ContainerCopyReflink copies a container using reflinks rather than rsync so
+// that the data is shared between the source and target until modified.
+func (s *storageDir) ContainerCopyReflink(target container, source container, containerOnly bool) error {
+	logger.Debugf("Reflink copying DIR container storage %s to %s", source.Name(), target.Name())
+
+	err := s.doContainerCopy(target, source, containerOnly, false, nil, true)
+	if err != nil {
+		return err
+	}
+
+	logger.Debugf("Reflink copied DIR container storage %s to %s", source.Name(), target.Name())
+	return nil
+}
+
+func (s *storageDir) ContainerCopyModes() []string {
+	if dirReflinkSupported(shared.VarPath("storage-pools", s.pool.Name)) {
+		return []string{"reflink", "copy"}
+	}
+
+	return []string{"copy"}
+}
+
+func (s *storageDir) ContainerCopyWithMode(target container, source container, containerOnly bool, mode string) error {
+	if mode == "reflink" {
+		return s.ContainerCopyReflink(target, source, containerOnly)
+	}
+
+	return s.ContainerCopy(target, source, containerOnly)
+}
+
+func (s *storageDir) doContainerCopy(target container, source container, containerOnly bool, refresh bool, refreshSnapshots []container, reflink bool) error {
 	_, err := s.StoragePoolMount()
 	if err != nil {
 		return err
@@ -758,7 +833,7 @@ func (s *storageDir) doContainerCopy(target container, source container, contain
 		srcState = srcStorage.GetState()
 	}
 
-	err = s.copyContainer(target, source)
+	err = s.copyContainer(target, source, reflink)
 	if err != nil {
 		return err
 	}
@@ -795,7 +870,7 @@ func (s *storageDir) doContainerCopy(target container, source container, contain
 			return err
 		}
 
-		err = s.copySnapshot(targetSnapshot, targetPool, sourceSnapshot, sourcePool)
+		err = s.copySnapshot(targetSnapshot, targetPool, sourceSnapshot, sourcePool, reflink)
 		if err != nil {
 			return err
 		}
@@ -807,7 +882,7 @@ func (s *storageDir) doContainerCopy(target container, source container, contain
 func (s *storageDir) ContainerRefresh(target container, source container, snapshots []container) error {
 	logger.Debugf("Refreshing DIR container storage for %s from %s", target.Name(), source.Name())
 
-	err := s.doContainerCopy(target, source, len(snapshots) == 0, true, snapshots)
+	err := s.doContainerCopy(target, source, len(snapshots) == 0, true, snapshots, false)
 	if err != nil {
 		return err
 	}
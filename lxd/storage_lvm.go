@@ -718,6 +718,8 @@ func (s *storageLvm) StoragePoolUpdate(writable *api.StoragePoolPut, changedConf
 	// "volume.block.filesystem" requires no on-disk modifications.
 	// "volume.size" requires no on-disk modifications.
 	// "rsync.bwlimit" requires no on-disk modifications.
+	// "lvm.thinpool_metadata_threshold" requires no on-disk modifications.
+	// "volume.encryption_pbkdf_*" only apply to the volumes created later.
 
 	revert := true
 
@@ -960,7 +962,7 @@ func (s *storageLvm) ContainerCreate(container container) error {
 		}
 	}
 
-	err = lvmCreateLv(container.Project(), poolName, thinPoolName, containerLvmName, lvFsType, lvSize, storagePoolVolumeAPIEndpointContainers, s.useThinpool)
+	err = s.containerLvCreate(container.Project(), poolName, thinPoolName, containerLvmName, lvFsType, lvSize)
 	if err != nil {
 		return err
 	}
@@ -1010,6 +1012,16 @@ func (s *storageLvm) ContainerCreateFromImage(container container, fingerprint s
 	containerName := container.Name()
 	containerLvmName := containerNameToLVName(containerName)
 
+	if s.usesEncryption() {
+		err := s.containerCreateFromImageEncrypted(container, fingerprint)
+		if err != nil {
+			return err
+		}
+
+		logger.Debugf("Created encrypted LVM storage volume for container \"%s\" on storage pool \"%s\"", s.volume.Name, s.pool.Name)
+		return nil
+	}
+
 	var err error
 	if s.useThinpool {
 		err = s.containerCreateFromImageThinLv(container, fingerprint)
@@ -1103,7 +1115,12 @@ func lvmContainerDeleteInternal(project, poolName string, ctName string, isSnaps
 
 	lvExists, _ := storageLVExists(containerLvmDevPath)
 	if lvExists {
-		err := removeLV(project, vgName, storagePoolVolumeAPIEndpointContainers, containerLvmName)
+		err := lvmEncryptionClose(containerLvmDevPath)
+		if err != nil {
+			return err
+		}
+
+		err = removeLV(project, vgName, storagePoolVolumeAPIEndpointContainers, containerLvmName)
 		if err != nil {
 			return err
 		}
@@ -1142,7 +1159,7 @@ func (s *storageLvm) ContainerDelete(container container) error {
 func (s *storageLvm) ContainerCopy(target container, source container, containerOnly bool) error {
 	logger.Debugf("Copying LVM container storage for container %s to %s", source.Name(), target.Name())
 
-	err := s.doContainerCopy(target, source, containerOnly, false, nil)
+	err := s.doContainerCopy(target, source, containerOnly, false, nil, false)
 	if err != nil {
 		return err
 	}
@@ -1151,7 +1168,27 @@ func (s *storageLvm) ContainerCopy(target container, source container, container
 	return nil
 }
 
-func (s *storageLvm) doContainerCopy(target container, source container, containerOnly bool, refresh bool, refreshSnapshots []container) error {
+func (s *storageLvm) ContainerCopyModes() []string {
+	if s.useThinpool {
+		return []string{"snapshot", "copy"}
+	}
+
+	return []string{"copy"}
+}
+
+func (s *storageLvm) ContainerCopyWithMode(target container, source container, containerOnly bool, mode string) error {
+	logger.Debugf("Copying LVM container storage for container %s to %s (%s)", source.Name(), target.Name(), mode)
+
+	err := s.doContainerCopy(target, source, containerOnly, false, nil, mode == "copy")
+	if err != nil {
+		return err
+	}
+
+	logger.Debugf("Copied LVM container storage for container %s to %s (%s)", source.Name(), target.Name(), mode)
+	return nil
+}
+
+func (s *storageLvm) doContainerCopy(target container, source container, containerOnly bool, refresh bool, refreshSnapshots []container, full bool) error {
 	ourStart, err := source.StorageStart()
 	if err != nil {
 		return err
@@ -1186,7 +1223,7 @@ func (s *storageLvm) doContainerCopy(target container, source container, contain
 		srcState = srcStorage.GetState()
 	}
 
-	err = s.copyContainer(target, source, refresh)
+	err = s.copyContainer(target, source, refresh, full)
 	if err != nil {
 		return err
 	}
@@ -1226,7 +1263,7 @@ func (s *storageLvm) doContainerCopy(target container, source container, contain
 			return err
 		}
 
-		err = s.copySnapshot(targetSnapshot, sourceSnapshot, refresh)
+		err = s.copySnapshot(targetSnapshot, sourceSnapshot, refresh, full)
 		if err != nil {
 			return err
 		}
@@ -1240,7 +1277,7 @@ func (s *storageLvm) doContainerCopy(target container, source container, contain
 func (s *storageLvm) ContainerRefresh(target container, source container, snapshots []container) error {
 	logger.Debugf("Refreshing LVM container storage for %s from %s", target.Name(), source.Name())
 
-	err := s.doContainerCopy(target, source, len(snapshots) == 0, true, snapshots)
+	err := s.doContainerCopy(target, source, len(snapshots) == 0, true, snapshots, false)
 	if err != nil {
 		return err
 	}
@@ -1291,7 +1328,14 @@ func (s *storageLvm) doContainerMount(project, name string, snap bool) (bool, er
 			}
 		}
 
-		mounterr = tryMount(containerLvmPath, containerMntPoint, lvFsType, mountFlags, mountOptions)
+		var containerFsPath string
+		containerFsPath, mounterr = s.encryptionOpen(containerLvmPath)
+		if mounterr == nil {
+			mounterr = tryMount(containerFsPath, containerMntPoint, lvFsType, mountFlags, mountOptions)
+			if mounterr != nil && containerFsPath != containerLvmPath {
+				lvmEncryptionCloseQuiet(containerLvmPath)
+			}
+		}
 		ourMount = true
 	}
 
@@ -1343,6 +1387,11 @@ func (s *storageLvm) umount(project, name string, path string) (bool, error) {
 		ourUmount = true
 	}
 
+	if imgerr == nil && s.usesEncryption() {
+		containerLvmPath := getLvmDevPath(project, s.getOnDiskPoolName(), storagePoolVolumeAPIEndpointContainers, containerNameToLVName(name))
+		imgerr = lvmEncryptionClose(containerLvmPath)
+	}
+
 	lxdStorageMapLock.Lock()
 	if waitChannel, ok := lxdStorageOngoingOperationMap[containerUmountLockID]; ok {
 		close(waitChannel)
@@ -1612,7 +1661,12 @@ func (s *storageLvm) ContainerSnapshotStart(container container) (bool, error) {
 			}
 		}
 
-		err = tryMount(containerLvmPath, containerMntPoint, lvFsType, mountFlags, mountOptions)
+		containerFsPath, err := s.encryptionOpen(containerLvmPath)
+		if err != nil {
+			return false, err
+		}
+
+		err = tryMount(containerFsPath, containerMntPoint, lvFsType, mountFlags, mountOptions)
 		if err != nil {
 			logger.Errorf(`Failed to mount LVM snapshot "%s" with filesystem "%s" options "%s" onto "%s": %s`, s.volume.Name, lvFsType, mntOptString, containerMntPoint, err)
 			return false, err
@@ -1644,6 +1698,11 @@ func (s *storageLvm) ContainerSnapshotStop(container container) (bool, error) {
 	}
 
 	containerLvmPath := getLvmDevPath(container.Project(), poolName, storagePoolVolumeAPIEndpointContainers, containerNameToLVName(containerName))
+	err := lvmEncryptionClose(containerLvmPath)
+	if err != nil {
+		return false, err
+	}
+
 	wasWritableAtCheck, err := lvmLvIsWritable(containerLvmPath)
 	if err != nil {
 		return false, err
@@ -1863,8 +1922,7 @@ func (s *storageLvm) doContainerBackupLoad(project, containerName string, privil
 	}
 
 	if !snapshot {
-		err = lvmCreateLv(project, poolName, thinPoolName, containerLvmName, lvFsType, lvSize,
-			storagePoolVolumeAPIEndpointContainers, s.useThinpool)
+		err = s.containerLvCreate(project, poolName, thinPoolName, containerLvmName, lvFsType, lvSize)
 	} else {
 		cname, _, _ := containerGetParentAndSnapshotName(containerName)
 		_, err = s.createSnapshotLV(project, poolName, cname, storagePoolVolumeAPIEndpointContainers,
@@ -2106,7 +2164,11 @@ func (s *storageLvm) StorageEntitySetQuota(volumeType int, size int64, data inte
 	case storagePoolVolumeTypeContainer:
 		c = data.(container)
 		ctName := c.Name()
-		if c.IsRunning() {
+		if s.usesEncryption() {
+			return fmt.Errorf("Resizing encrypted LVM storage volumes isn't supported")
+		}
+
+		if c.IsRunning() && !s.canGrowLive(size) {
 			msg := fmt.Sprintf(`Cannot resize LVM storage volume `+
 				`for container "%s" when it is running`,
 				ctName)
@@ -2136,6 +2198,8 @@ func (s *storageLvm) StorageEntitySetQuota(volumeType int, size int64, data inte
 
 	if size < oldSize {
 		err = s.lvReduce(lvDevPath, size, fsType, mountpoint, volumeType, data)
+	} else if c != nil && c.IsRunning() {
+		err = s.lvExtendLive(lvDevPath, size, fsType, c)
 	} else if size > oldSize {
 		err = s.lvExtend(lvDevPath, size, fsType, mountpoint, volumeType, data)
 	}
@@ -2192,6 +2256,11 @@ func (s *storageLvm) StoragePoolResources() (*api.ResourcesStoragePool, error) {
 			return nil, err
 		}
 		res.Space.Used = total - free
+	} else {
+		res.Thinpool, err = lvmThinpoolUsage(s.getOnDiskPoolName(), s.getLvmThinpoolName())
+		if err != nil {
+			return nil, err
+		}
 	}
 
 	return &res, nil
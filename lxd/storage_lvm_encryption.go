@@ -0,0 +1,219 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"path/filepath"
+	"strings"
+
+	"github.com/lxc/lxd/shared"
+	"github.com/lxc/lxd/shared/logger"
+
+	log "github.com/lxc/lxd/shared/log15"
+)
+
+// Prefix of the device-mapper names of the opened LUKS volumes
+const lvmCryptPrefix = "lxd_crypt"
+
+func (s *storageLvm) usesEncryption() bool {
+	return shared.IsTrue(s.pool.Config["volume.encryption"])
+}
+
+// lvmCryptName returns the device-mapper name of the opened LUKS volume on a
+// LV, escaping the dashes the way LVM does for its own device-mapper names.
+func lvmCryptName(lvPath string) string {
+	vgName := filepath.Base(filepath.Dir(lvPath))
+	lvName := filepath.Base(lvPath)
+
+	return fmt.Sprintf("%s-%s-%s", lvmCryptPrefix,
+		strings.Replace(vgName, "-", "--", -1), strings.Replace(lvName, "-", "--", -1))
+}
+
+// lvmCryptPath returns the path of the opened LUKS volume on a LV.
+func lvmCryptPath(lvPath string) string {
+	return filepath.Join("/dev/mapper", lvmCryptName(lvPath))
+}
+
+// lvmLuksFormatArgs returns the arguments of cryptsetup formatting a LV as a
+// LUKS2 volume, the key being read from the standard input.
+func lvmLuksFormatArgs(config map[string]string, lvPath string) []string {
+	args := []string{"luksFormat", "--batch-mode", "--type", "luks2", "--pbkdf", "argon2id"}
+
+	if config["volume.encryption_pbkdf_memory"] != "" {
+		args = append(args, "--pbkdf-memory", config["volume.encryption_pbkdf_memory"])
+	}
+
+	if config["volume.encryption_pbkdf_iterations"] != "" {
+		args = append(args, "--pbkdf-force-iterations", config["volume.encryption_pbkdf_iterations"])
+	}
+
+	if config["volume.encryption_pbkdf_parallel"] != "" {
+		args = append(args, "--pbkdf-parallel", config["volume.encryption_pbkdf_parallel"])
+	}
+
+	return append(args, "--key-file=-", lvPath)
+}
+
+// encryptionKey returns the key of the LUKS volumes of the pool, read from
+// volume.encryption_key_file, set in volume.encryption_passphrase or else the
+// storage master key, typically provisioned from a HSM.
+func (s *storageLvm) encryptionKey() ([]byte, error) {
+	if s.pool.Config["volume.encryption_key_file"] != "" {
+		return ioutil.ReadFile(s.pool.Config["volume.encryption_key_file"])
+	}
+
+	if s.pool.Config["volume.encryption_passphrase"] != "" {
+		return []byte(s.pool.Config["volume.encryption_passphrase"]), nil
+	}
+
+	masterKey, err := storageMasterKey()
+	if err != nil {
+		return nil, err
+	}
+
+	if masterKey == nil {
+		return nil, fmt.Errorf("No encryption key: volume.encryption_key_file and volume.encryption_passphrase aren't set and there's no storage master key")
+	}
+
+	return masterKey, nil
+}
+
+// encryptionFormat formats a LV as a LUKS2 volume and opens it.
+func (s *storageLvm) encryptionFormat(lvPath string) (string, error) {
+	key, err := s.encryptionKey()
+	if err != nil {
+		return "", err
+	}
+
+	err = shared.RunCommandWithFds(bytes.NewReader(key), nil, "cryptsetup", lvmLuksFormatArgs(s.pool.Config, lvPath)...)
+	if err != nil {
+		return "", fmt.Errorf("Failed to format LUKS volume on \"%s\": %v", lvPath, err)
+	}
+
+	return s.encryptionOpen(lvPath)
+}
+
+// encryptionOpen opens the LUKS volume on a LV if it isn't open yet, and
+// returns the path of the device to use. The path of the LV itself is
+// returned for the LVs which aren't encrypted, such as the ones created
+// before the encryption was enabled.
+func (s *storageLvm) encryptionOpen(lvPath string) (string, error) {
+	if !s.usesEncryption() {
+		return lvPath, nil
+	}
+
+	cryptPath := lvmCryptPath(lvPath)
+	if shared.PathExists(cryptPath) {
+		return cryptPath, nil
+	}
+
+	_, err := shared.RunCommand("cryptsetup", "isLuks", lvPath)
+	if err != nil {
+		return lvPath, nil
+	}
+
+	key, err := s.encryptionKey()
+	if err != nil {
+		return "", err
+	}
+
+	err = shared.RunCommandWithFds(bytes.NewReader(key), nil, "cryptsetup", "open", "--type", "luks2", "--key-file=-", lvPath, lvmCryptName(lvPath))
+	if err != nil {
+		return "", fmt.Errorf("Failed to open LUKS volume on \"%s\": %v", lvPath, err)
+	}
+
+	return cryptPath, nil
+}
+
+// lvmEncryptionClose closes the LUKS volume on a LV, if open.
+func lvmEncryptionClose(lvPath string) error {
+	if !shared.PathExists(lvmCryptPath(lvPath)) {
+		return nil
+	}
+
+	output, err := shared.TryRunCommand("cryptsetup", "close", lvmCryptName(lvPath))
+	if err != nil {
+		return fmt.Errorf("Failed to close LUKS volume on \"%s\": %s", lvPath, output)
+	}
+
+	return nil
+}
+
+// lvmEncryptionCloseQuiet closes the LUKS volume on a LV, only logging a
+// failure.
+func lvmEncryptionCloseQuiet(lvPath string) {
+	err := lvmEncryptionClose(lvPath)
+	if err != nil {
+		logger.Warn("Failed to close LUKS volume", log.Ctx{"lv": lvPath, "err": err})
+	}
+}
+
+// containerLvCreate creates the LV of a container with its filesystem,
+// within a LUKS volume if the pool is encrypted.
+func (s *storageLvm) containerLvCreate(project, vgName string, thinPoolName string, lvName string, lvFsType string, lvSize string) error {
+	if !s.usesEncryption() {
+		return lvmCreateLv(project, vgName, thinPoolName, lvName, lvFsType, lvSize, storagePoolVolumeAPIEndpointContainers, s.useThinpool)
+	}
+
+	err := lvmCreateLvDevice(project, vgName, thinPoolName, lvName, lvSize, storagePoolVolumeAPIEndpointContainers, s.useThinpool)
+	if err != nil {
+		return err
+	}
+
+	lvPath := getLvmDevPath(project, vgName, storagePoolVolumeAPIEndpointContainers, lvName)
+	cryptPath, err := s.encryptionFormat(lvPath)
+	if err != nil {
+		return err
+	}
+	defer lvmEncryptionCloseQuiet(lvPath)
+
+	output, err := makeFSType(cryptPath, lvFsType, nil)
+	if err != nil {
+		logger.Errorf("Filesystem creation failed: %s", output)
+		return fmt.Errorf("Error making filesystem on encrypted LV: %v", err)
+	}
+
+	return nil
+}
+
+// containerCreateFromImageEncrypted creates the rootfs of a container on an
+// encrypted pool. As snapshots of the image LV wouldn't be encrypted, the
+// image is unpacked into a new LV instead.
+func (s *storageLvm) containerCreateFromImageEncrypted(c container, fingerprint string) error {
+	err := s.ContainerCreate(c)
+	if err != nil {
+		return err
+	}
+
+	revert := true
+	defer func() {
+		if !revert {
+			return
+		}
+		s.ContainerDelete(c)
+	}()
+
+	ourMount, err := s.ContainerMount(c)
+	if err != nil {
+		return err
+	}
+	if ourMount {
+		defer s.ContainerUmount(c, c.Path())
+	}
+
+	imagePath := shared.VarPath("images", fingerprint)
+	containerMntPoint := getContainerMountPoint(c.Project(), s.pool.Name, c.Name())
+	err = unpackImage(imagePath, containerMntPoint, storageTypeLvm, s.s.OS.RunningInUserNS, nil)
+	if err != nil {
+		return err
+	}
+
+	err = c.TemplateApply("create")
+	if err != nil {
+		return err
+	}
+
+	revert = false
+	return nil
+}
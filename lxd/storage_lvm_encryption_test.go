@@ -0,0 +1,31 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestLvmCryptName(t *testing.T) {
+	assert.Equal(t, "lxd_crypt-pool1-containers_c1", lvmCryptName("/dev/pool1/containers_c1"))
+	assert.Equal(t, "lxd_crypt-my--pool-containers_p1_c--1", lvmCryptName("/dev/my-pool/containers_p1_c-1"))
+	assert.Equal(t, "/dev/mapper/lxd_crypt-pool1-containers_c1", lvmCryptPath("/dev/pool1/containers_c1"))
+}
+
+func TestLvmLuksFormatArgs(t *testing.T) {
+	args := lvmLuksFormatArgs(map[string]string{}, "/dev/pool1/containers_c1")
+	assert.Equal(t, []string{
+		"luksFormat", "--batch-mode", "--type", "luks2", "--pbkdf", "argon2id",
+		"--key-file=-", "/dev/pool1/containers_c1"}, args)
+
+	config := map[string]string{
+		"volume.encryption_pbkdf_memory":     "65536",
+		"volume.encryption_pbkdf_iterations": "4",
+		"volume.encryption_pbkdf_parallel":   "2",
+	}
+	args = lvmLuksFormatArgs(config, "/dev/pool1/containers_c1")
+	assert.Equal(t, []string{
+		"luksFormat", "--batch-mode", "--type", "luks2", "--pbkdf", "argon2id",
+		"--pbkdf-memory", "65536", "--pbkdf-force-iterations", "4", "--pbkdf-parallel", "2",
+		"--key-file=-", "/dev/pool1/containers_c1"}, args)
+}
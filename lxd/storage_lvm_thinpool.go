@@ -0,0 +1,144 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/lxc/lxd/lxd/state"
+	"github.com/lxc/lxd/lxd/task"
+	"github.com/lxc/lxd/shared"
+	"github.com/lxc/lxd/shared/api"
+	"github.com/lxc/lxd/shared/logger"
+
+	log "github.com/lxc/lxd/shared/log15"
+)
+
+// Default of the lvm.thinpool_metadata_threshold key, in percent
+const lvmThinpoolMetadataThreshold = 80
+
+// Storage pools whose thin pool metadata usage is above the threshold
+var lvmThinpoolMetadataAlertsLock sync.Mutex
+var lvmThinpoolMetadataAlerts = map[string]bool{}
+
+// lvmThinpoolParseUsage parses the output of
+// "lvs --separator , -o lv_size,data_percent,lv_metadata_size,metadata_percent"
+// for a thin pool, sizes being in bytes.
+func lvmThinpoolParseUsage(output string) (*api.ResourcesStoragePoolThinpool, error) {
+	fields := strings.Split(strings.TrimSpace(output), ",")
+	if len(fields) != 4 {
+		return nil, fmt.Errorf("Unexpected thin pool usage: %s", output)
+	}
+
+	values := make([]float64, len(fields))
+	for i, field := range fields {
+		value, err := strconv.ParseFloat(strings.TrimSpace(field), 64)
+		if err != nil {
+			return nil, fmt.Errorf("Unexpected thin pool usage: %s", output)
+		}
+
+		values[i] = value
+	}
+
+	usage := api.ResourcesStoragePoolThinpool{
+		DataTotal:     uint64(values[0]),
+		MetadataTotal: uint64(values[2]),
+	}
+	usage.DataUsed = uint64(values[0] * values[1] / 100)
+	usage.MetadataUsed = uint64(values[2] * values[3] / 100)
+
+	return &usage, nil
+}
+
+// lvmThinpoolUsage returns the data and metadata usage of a thin pool.
+func lvmThinpoolUsage(vgName string, poolName string) (*api.ResourcesStoragePoolThinpool, error) {
+	output, err := shared.TryRunCommand("lvs", "--noheadings", "--units", "b", "--nosuffix",
+		"--separator", ",", "-o", "lv_size,data_percent,lv_metadata_size,metadata_percent",
+		fmt.Sprintf("%s/%s", vgName, poolName))
+	if err != nil {
+		return nil, err
+	}
+
+	return lvmThinpoolParseUsage(output)
+}
+
+// lvmThinpoolMetadataCheck sends a lifecycle event when the metadata usage of
+// the thin pool of a storage pool goes above lvm.thinpool_metadata_threshold.
+func lvmThinpoolMetadataCheck(s *state.State, pool *api.StoragePool) {
+	if pool.Driver != "lvm" {
+		return
+	}
+
+	if pool.Config["lvm.use_thinpool"] != "" && !shared.IsTrue(pool.Config["lvm.use_thinpool"]) {
+		return
+	}
+
+	vgName := pool.Config["lvm.vg_name"]
+	if vgName == "" {
+		vgName = pool.Name
+	}
+
+	poolName := pool.Config["lvm.thinpool_name"]
+	if poolName == "" {
+		poolName = "LXDThinPool"
+	}
+
+	usage, err := lvmThinpoolUsage(vgName, poolName)
+	if err != nil {
+		logger.Debug("Failed to get thin pool usage", log.Ctx{"pool": pool.Name, "err": err})
+		return
+	}
+
+	if usage.MetadataTotal == 0 {
+		return
+	}
+
+	threshold := float64(lvmThinpoolMetadataThreshold)
+	value, err := strconv.ParseFloat(pool.Config["lvm.thinpool_metadata_threshold"], 64)
+	if err == nil {
+		threshold = value
+	}
+
+	percent := float64(usage.MetadataUsed) * 100 / float64(usage.MetadataTotal)
+	exceeded := percent >= threshold
+
+	lvmThinpoolMetadataAlertsLock.Lock()
+	previous := lvmThinpoolMetadataAlerts[pool.Name]
+	lvmThinpoolMetadataAlerts[pool.Name] = exceeded
+	lvmThinpoolMetadataAlertsLock.Unlock()
+
+	if !exceeded || previous {
+		return
+	}
+
+	logger.Warn("Thin pool metadata usage is above the threshold", log.Ctx{"pool": pool.Name, "usage": percent, "threshold": threshold})
+	eventSendLifecycle("", "storage-pool-thinpool-metadata-exceeded",
+		fmt.Sprintf("/1.0/storage-pools/%s", pool.Name), map[string]interface{}{
+			"metadata_used":  usage.MetadataUsed,
+			"metadata_total": usage.MetadataTotal,
+			"threshold":      threshold,
+		})
+}
+
+func lvmThinpoolMetadataTask(d *Daemon) (task.Func, task.Schedule) {
+	f := func(ctx context.Context) {
+		pools, err := d.cluster.StoragePools()
+		if err != nil {
+			return
+		}
+
+		for _, name := range pools {
+			_, pool, err := d.cluster.StoragePoolGet(name)
+			if err != nil {
+				continue
+			}
+
+			lvmThinpoolMetadataCheck(d.State(), pool)
+		}
+	}
+
+	return f, task.Every(5 * time.Minute)
+}
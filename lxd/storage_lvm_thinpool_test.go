@@ -0,0 +1,20 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestLvmThinpoolParseUsage(t *testing.T) {
+	usage, err := lvmThinpoolParseUsage("  53687091200,20.00,54525952,50.00\n")
+	require.NoError(t, err)
+	assert.Equal(t, uint64(53687091200), usage.DataTotal)
+	assert.Equal(t, uint64(10737418240), usage.DataUsed)
+	assert.Equal(t, uint64(54525952), usage.MetadataTotal)
+	assert.Equal(t, uint64(27262976), usage.MetadataUsed)
+
+	_, err = lvmThinpoolParseUsage("53687091200,20.00")
+	assert.Error(t, err)
+}
@@ -58,6 +58,42 @@ func (s *storageLvm) lvExtend(lvPath string, lvSize int64, fsType string, fsMntP
 	return growFileSystem(fsType, lvPath, fsMntPoint)
 }
 
+// canGrowLive returns whether the volume can be grown to the given size while
+// it's in use by a running container, which requires a thin pool and an ext4
+// or xfs filesystem.
+func (s *storageLvm) canGrowLive(size int64) bool {
+	if !s.useThinpool || !shared.StringInSlice(s.getLvmFilesystem(), []string{"ext4", "xfs"}) {
+		return false
+	}
+
+	oldSize, err := shared.ParseByteSizeString(s.volume.Config["size"])
+	if err != nil {
+		return false
+	}
+
+	return size > oldSize
+}
+
+// lvExtendLive grows the root volume of a running container along with its
+// filesystem, without stopping it.
+func (s *storageLvm) lvExtendLive(lvPath string, lvSize int64, fsType string, c container) error {
+	// Round the size to closest 512 bytes
+	lvSize = int64(lvSize/512) * 512
+	lvSizeString := shared.GetByteSizeString(lvSize, 0)
+
+	msg, err := shared.TryRunCommand(
+		"lvextend",
+		"-L", lvSizeString,
+		"-f",
+		lvPath)
+	if err != nil {
+		logger.Errorf("Could not extend LV \"%s\": %s", lvPath, msg)
+		return fmt.Errorf("could not extend LV \"%s\": %s", lvPath, msg)
+	}
+
+	return growFileSystemLive(fsType, lvPath, c)
+}
+
 func (s *storageLvm) lvReduce(lvPath string, lvSize int64, fsType string, fsMntPoint string, volumeType int, data interface{}) error {
 	var err error
 	var msg string
@@ -314,6 +350,15 @@ func (s *storageLvm) copyContainerThinpool(target container, source container, r
 	containerLvDevPath := getLvmDevPath(target.Project(), poolName,
 		storagePoolVolumeAPIEndpointContainers, containerLvmName)
 
+	// The snapshot is in the same LUKS volume as its origin
+	containerFsPath, err := s.encryptionOpen(containerLvDevPath)
+	if err != nil {
+		return err
+	}
+	if containerFsPath != containerLvDevPath {
+		defer lvmEncryptionCloseQuiet(containerLvDevPath)
+	}
+
 	// If btrfstune sees two btrfs filesystems with the same UUID it
 	// gets confused and wants both of them unmounted. So unmount
 	// the source as well.
@@ -328,7 +373,7 @@ func (s *storageLvm) copyContainerThinpool(target container, source container, r
 		}
 	}
 
-	msg, err := fsGenerateNewUUID(LVFilesystem, containerLvDevPath)
+	msg, err := fsGenerateNewUUID(LVFilesystem, containerFsPath)
 	if err != nil {
 		logger.Errorf("Failed to create new \"%s\" UUID for container \"%s\" on storage pool \"%s\": %s", LVFilesystem, containerName, s.pool.Name, msg)
 		return err
@@ -337,7 +382,7 @@ func (s *storageLvm) copyContainerThinpool(target container, source container, r
 	return nil
 }
 
-func (s *storageLvm) copySnapshot(target container, source container, refresh bool) error {
+func (s *storageLvm) copySnapshot(target container, source container, refresh bool, full bool) error {
 	sourcePool, err := source.StoragePool()
 	if err != nil {
 		return err
@@ -352,7 +397,7 @@ func (s *storageLvm) copySnapshot(target container, source container, refresh bo
 		return err
 	}
 
-	if s.useThinpool && sourcePool == s.pool.Name && !refresh {
+	if s.useThinpool && sourcePool == s.pool.Name && !refresh && !full {
 		err = s.copyContainerThinpool(target, source, true)
 	} else {
 		err = s.copyContainerLv(target, source, true, refresh)
@@ -441,7 +486,7 @@ func (s *storageLvm) copyContainerLv(target container, source container, readonl
 }
 
 // Copy an lvm container.
-func (s *storageLvm) copyContainer(target container, source container, refresh bool) error {
+func (s *storageLvm) copyContainer(target container, source container, refresh bool, full bool) error {
 	targetPool, err := target.StoragePool()
 	if err != nil {
 		return err
@@ -458,7 +503,7 @@ func (s *storageLvm) copyContainer(target container, source container, refresh b
 		return err
 	}
 
-	if s.useThinpool && targetPool == sourcePool && !refresh {
+	if s.useThinpool && targetPool == sourcePool && !refresh && !full {
 		// If the storage pool uses a thinpool we can have snapshots of
 		// snapshots.
 		err = s.copyContainerThinpool(target, source, false)
@@ -824,6 +869,24 @@ func getPrefixedLvName(project, volumeType string, lvmVolume string) string {
 }
 
 func lvmCreateLv(project, vgName string, thinPoolName string, lvName string, lvFsType string, lvSize string, volumeType string, makeThinLv bool) error {
+	err := lvmCreateLvDevice(project, vgName, thinPoolName, lvName, lvSize, volumeType, makeThinLv)
+	if err != nil {
+		return err
+	}
+
+	fsPath := getLvmDevPath(project, vgName, volumeType, lvName)
+
+	output, err := makeFSType(fsPath, lvFsType, nil)
+	if err != nil {
+		logger.Errorf("Filesystem creation failed: %s", output)
+		return fmt.Errorf("Error making filesystem on image LV: %v", err)
+	}
+
+	return nil
+}
+
+// lvmCreateLvDevice creates a LV without a filesystem.
+func lvmCreateLvDevice(project, vgName string, thinPoolName string, lvName string, lvSize string, volumeType string, makeThinLv bool) error {
 	var output string
 	var err error
 
@@ -848,14 +911,6 @@ func lvmCreateLv(project, vgName string, thinPoolName string, lvName string, lvF
 		return fmt.Errorf("Could not create thin LV named %s", lvmPoolVolumeName)
 	}
 
-	fsPath := getLvmDevPath(project, vgName, volumeType, lvName)
-
-	output, err = makeFSType(fsPath, lvFsType, nil)
-	if err != nil {
-		logger.Errorf("Filesystem creation failed: %s", output)
-		return fmt.Errorf("Error making filesystem on image LV: %v", err)
-	}
-
 	return nil
 }
 
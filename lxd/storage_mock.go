@@ -135,6 +135,14 @@ func (s *storageMock) ContainerCopy(target container, source container, containe
 	return nil
 }
 
+func (s *storageMock) ContainerCopyModes() []string {
+	return []string{"copy"}
+}
+
+func (s *storageMock) ContainerCopyWithMode(target container, source container, containerOnly bool, mode string) error {
+	return nil
+}
+
 func (s *storageMock) ContainerRefresh(target container, source container, snapshots []container) error {
 	return nil
 }
@@ -0,0 +1,188 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/lxc/lxd/lxd/cluster"
+	"github.com/lxc/lxd/shared/api"
+)
+
+var healthCmd = Command{
+	name: "health",
+	get:  healthGet,
+}
+
+// storageOpsSemaphore limits the number of storage heavy operations (creation,
+// copy, import and restore of containers) running concurrently on the node.
+type storageOpsSemaphore struct {
+	mu sync.Mutex
+
+	// Maximum number of concurrent operations, 0 for no limit
+	limit   int64
+	running int64
+	waiting int64
+
+	// Closed whenever a slot may have become available
+	changed chan struct{}
+}
+
+var storageOps = newStorageOpsSemaphore(4)
+
+func newStorageOpsSemaphore(limit int64) *storageOpsSemaphore {
+	return &storageOpsSemaphore{limit: limit, changed: make(chan struct{})}
+}
+
+// notify wakes the operations waiting for a slot up, the lock being held.
+func (s *storageOpsSemaphore) notify() {
+	close(s.changed)
+	s.changed = make(chan struct{})
+}
+
+// setLimit changes the maximum number of concurrent operations, running ones
+// being left to complete.
+func (s *storageOpsSemaphore) setLimit(limit int64) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if limit != s.limit {
+		s.limit = limit
+		s.notify()
+	}
+}
+
+// acquire takes a slot, waiting for up to the given timeout for one to be
+// released. It returns false if none was.
+func (s *storageOpsSemaphore) acquire(timeout time.Duration) bool {
+	deadline := time.NewTimer(timeout)
+	defer deadline.Stop()
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for s.limit > 0 && s.running >= s.limit {
+		changed := s.changed
+		s.waiting++
+		s.mu.Unlock()
+
+		select {
+		case <-changed:
+			s.mu.Lock()
+			s.waiting--
+		case <-deadline.C:
+			s.mu.Lock()
+			s.waiting--
+			return false
+		}
+	}
+
+	s.running++
+	return true
+}
+
+// release gives a slot back.
+func (s *storageOpsSemaphore) release() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.running--
+	s.notify()
+}
+
+// stats returns the current usage of the semaphore.
+func (s *storageOpsSemaphore) stats() api.HealthStorageOps {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return api.HealthStorageOps{
+		Running: s.running,
+		Waiting: s.waiting,
+		Limit:   s.limit,
+	}
+}
+
+// storageOpsLimit runs a handler starting a storage heavy operation once a
+// slot of storage.max_concurrent_ops is available, the slot being released
+// once the operation is done running. A 503 error is returned if no slot was
+// released within storage.ops_wait_timeout seconds.
+func storageOpsLimit(d *Daemon, handler func() Response) Response {
+	limit, err := cluster.ConfigGetInt64(d.cluster, "storage.max_concurrent_ops")
+	if err != nil {
+		return SmartError(err)
+	}
+
+	timeout, err := cluster.ConfigGetInt64(d.cluster, "storage.ops_wait_timeout")
+	if err != nil {
+		return SmartError(err)
+	}
+
+	wait := time.Duration(timeout) * time.Second
+
+	storageOps.setLimit(limit)
+	if !storageOps.acquire(wait) {
+		return Unavailable(fmt.Errorf("Too many concurrent storage operations, try again later"))
+	}
+
+	response := handler()
+
+	opResponse, ok := response.(*operationResponse)
+	if !ok {
+		// Failed or forwarded to another node
+		storageOps.release()
+		return response
+	}
+
+	storageOpsHold(storageOps, opResponse.op, wait)
+	return response
+}
+
+// storageOpsHold hands a slot over to an operation which didn't run yet. The
+// slot is released once the operation is done running, even if cancelled in
+// the meantime, or if it's cancelled or still not running after the given
+// timeout.
+func storageOpsHold(s *storageOpsSemaphore, op *operation, timeout time.Duration) {
+	var once sync.Once
+	release := func() {
+		once.Do(s.release)
+	}
+
+	started := make(chan struct{})
+	run := op.onRun
+	op.onRun = func(op *operation) error {
+		close(started)
+		defer release()
+
+		return run(op)
+	}
+
+	go func() {
+		timer := time.NewTimer(timeout)
+		defer timer.Stop()
+
+		select {
+		case <-started:
+			return
+		case <-op.chanDone:
+		case <-timer.C:
+		}
+
+		// Unless it started running in the meantime
+		select {
+		case <-started:
+		default:
+			release()
+		}
+	}()
+}
+
+// /1.0/health
+// Get the state of the daemon
+func healthGet(d *Daemon, r *http.Request) Response {
+	health := api.Health{
+		StorageOps: storageOps.stats(),
+	}
+
+	return SyncResponse(true, health)
+}
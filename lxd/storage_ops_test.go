@@ -0,0 +1,107 @@
+package main
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestStorageOpsSemaphore(t *testing.T) {
+	s := newStorageOpsSemaphore(2)
+
+	assert.True(t, s.acquire(time.Millisecond))
+	assert.True(t, s.acquire(time.Millisecond))
+	assert.False(t, s.acquire(10*time.Millisecond))
+	assert.Equal(t, int64(2), s.stats().Running)
+	assert.Equal(t, int64(0), s.stats().Waiting)
+
+	// A waiting operation gets the released slot
+	acquired := make(chan bool)
+	go func() {
+		acquired <- s.acquire(time.Second)
+	}()
+
+	for s.stats().Waiting == 0 {
+		time.Sleep(time.Millisecond)
+	}
+
+	s.release()
+	assert.True(t, <-acquired)
+	assert.Equal(t, int64(2), s.stats().Running)
+
+	// Raising the limit wakes the waiting operations up
+	go func() {
+		acquired <- s.acquire(time.Second)
+	}()
+
+	for s.stats().Waiting == 0 {
+		time.Sleep(time.Millisecond)
+	}
+
+	s.setLimit(3)
+	assert.True(t, <-acquired)
+	assert.Equal(t, int64(3), s.stats().Running)
+
+	// No limit
+	s.setLimit(0)
+	assert.True(t, s.acquire(time.Millisecond))
+	assert.Equal(t, int64(4), s.stats().Running)
+}
+
+// Wait for the slots of a semaphore to be released.
+func waitStorageOpsReleased(t *testing.T, s *storageOpsSemaphore) {
+	for i := 0; i < 1000 && s.stats().Running > 0; i++ {
+		time.Sleep(time.Millisecond)
+	}
+
+	assert.Equal(t, int64(0), s.stats().Running)
+}
+
+func TestStorageOpsHold(t *testing.T) {
+	s := newStorageOpsSemaphore(1)
+
+	// Released once done running, even if cancelled in the meantime
+	running := make(chan struct{})
+	proceed := make(chan struct{})
+	op := &operation{chanDone: make(chan error)}
+	op.onRun = func(op *operation) error {
+		close(running)
+		<-proceed
+		return nil
+	}
+
+	assert.True(t, s.acquire(time.Millisecond))
+	storageOpsHold(s, op, time.Minute)
+
+	done := make(chan error)
+	go func() {
+		done <- op.onRun(op)
+	}()
+
+	<-running
+	close(op.chanDone)
+	time.Sleep(10 * time.Millisecond)
+	assert.Equal(t, int64(1), s.stats().Running)
+
+	close(proceed)
+	assert.NoError(t, <-done)
+	waitStorageOpsReleased(t, s)
+
+	// Cancelled before running
+	op = &operation{chanDone: make(chan error)}
+	op.onRun = func(op *operation) error { return nil }
+
+	assert.True(t, s.acquire(time.Millisecond))
+	storageOpsHold(s, op, time.Minute)
+	close(op.chanDone)
+	waitStorageOpsReleased(t, s)
+
+	// Never run
+	op = &operation{chanDone: make(chan error)}
+	op.onRun = func(op *operation) error { return nil }
+
+	assert.True(t, s.acquire(time.Millisecond))
+	storageOpsHold(s, op, time.Millisecond)
+	waitStorageOpsReleased(t, s)
+}
@@ -0,0 +1,184 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"sort"
+	"strconv"
+	"sync"
+
+	"github.com/gorilla/mux"
+
+	"github.com/lxc/lxd/lxd/cluster"
+	"github.com/lxc/lxd/lxd/state"
+	"github.com/lxc/lxd/shared"
+	"github.com/lxc/lxd/shared/api"
+)
+
+var storagePoolOvercommitCmd = Command{
+	name: "storage-pools/{name}/overcommit",
+	get:  storagePoolOvercommitGet,
+}
+
+// Overcommit ratios whose crossing is notified through a lifecycle event
+var storagePoolOvercommitThresholds = []float64{0.8, 0.9, 1}
+
+// Highest threshold crossed by each storage pool
+var storagePoolOvercommitLevelsLock sync.Mutex
+var storagePoolOvercommitLevels = map[string]float64{}
+
+// storageVolumeAllocated returns the space reserved for a volume, that is its
+// quota or the default quota of the pool. Volumes without quota count as 0.
+func storageVolumeAllocated(volumeConfig map[string]string, poolConfig map[string]string) uint64 {
+	size := volumeConfig["size"]
+	if size == "" {
+		size = poolConfig["volume.size"]
+	}
+
+	if size == "" {
+		return 0
+	}
+
+	value, err := shared.ParseByteSizeString(size)
+	if err != nil || value < 0 {
+		return 0
+	}
+
+	return uint64(value)
+}
+
+// storagePoolOvercommit returns the space allocated to the volumes of a
+// storage pool on this node compared to its capacity.
+func storagePoolOvercommit(s *state.State, poolName string) (*api.StoragePoolOvercommit, error) {
+	poolID, pool, err := s.Cluster.StoragePoolGet(poolName)
+	if err != nil {
+		return nil, err
+	}
+
+	storage, err := storagePoolInit(s, poolName)
+	if err != nil {
+		return nil, err
+	}
+
+	res, err := storage.StoragePoolResources()
+	if err != nil {
+		return nil, err
+	}
+
+	volumes, err := s.Cluster.StoragePoolNodeVolumesGet(poolID, supportedVolumeTypes)
+	if err != nil {
+		return nil, err
+	}
+
+	result := api.StoragePoolOvercommit{
+		Physical: res.Space.Total,
+		Volumes:  []api.StoragePoolOvercommitVolume{},
+	}
+
+	for _, volume := range volumes {
+		allocated := storageVolumeAllocated(volume.Config, pool.Config)
+		result.Allocated += allocated
+		result.Volumes = append(result.Volumes, api.StoragePoolOvercommitVolume{
+			Name:      volume.Name,
+			Type:      volume.Type,
+			Allocated: allocated,
+		})
+	}
+
+	sort.Slice(result.Volumes, func(i, j int) bool {
+		if result.Volumes[i].Type != result.Volumes[j].Type {
+			return result.Volumes[i].Type < result.Volumes[j].Type
+		}
+
+		return result.Volumes[i].Name < result.Volumes[j].Name
+	})
+
+	if result.Physical > 0 {
+		result.Ratio = float64(result.Allocated) / float64(result.Physical)
+	}
+
+	return &result, nil
+}
+
+// storagePoolOvercommitCheck returns a 507 error if creating a volume with
+// the given config would take the overcommit ratio of the pool above
+// storage.overcommit_limit. It returns nil if the volume fits or the ratio
+// can't be determined.
+func storagePoolOvercommitCheck(d *Daemon, poolName string, volumeConfig map[string]string) Response {
+	value, err := cluster.ConfigGetString(d.cluster, "storage.overcommit_limit")
+	if err != nil {
+		return SmartError(err)
+	}
+
+	limit, err := strconv.ParseFloat(value, 64)
+	if err != nil || limit <= 0 {
+		return nil
+	}
+
+	_, pool, err := d.cluster.StoragePoolGet(poolName)
+	if err != nil {
+		return nil
+	}
+
+	overcommit, err := storagePoolOvercommit(d.State(), poolName)
+	if err != nil || overcommit.Physical == 0 {
+		return nil
+	}
+
+	allocated := overcommit.Allocated + storageVolumeAllocated(volumeConfig, pool.Config)
+	ratio := float64(allocated) / float64(overcommit.Physical)
+	if ratio <= limit {
+		return nil
+	}
+
+	return InsufficientStorage(fmt.Errorf("Creating the volume would overcommit storage pool \"%s\" by a ratio of %.2f (limit is %.2f)", poolName, ratio, limit), overcommit)
+}
+
+// storagePoolOvercommitNotify sends a lifecycle event when the overcommit
+// ratio of a storage pool crosses one of the thresholds.
+func storagePoolOvercommitNotify(s *state.State, poolName string) {
+	overcommit, err := storagePoolOvercommit(s, poolName)
+	if err != nil {
+		return
+	}
+
+	level := float64(0)
+	for _, threshold := range storagePoolOvercommitThresholds {
+		if overcommit.Ratio >= threshold {
+			level = threshold
+		}
+	}
+
+	storagePoolOvercommitLevelsLock.Lock()
+	previous := storagePoolOvercommitLevels[poolName]
+	storagePoolOvercommitLevels[poolName] = level
+	storagePoolOvercommitLevelsLock.Unlock()
+
+	if level <= previous {
+		return
+	}
+
+	eventSendLifecycle("", "storage-pool-overcommitted",
+		fmt.Sprintf("/1.0/storage-pools/%s", poolName), map[string]interface{}{
+			"threshold": level,
+			"ratio":     overcommit.Ratio,
+		})
+}
+
+// /1.0/storage-pools/{name}/overcommit
+// Get the space allocated to the volumes of a storage pool
+func storagePoolOvercommitGet(d *Daemon, r *http.Request) Response {
+	// If a target was specified, forward the request to the relevant node.
+	response := ForwardedResponseIfTargetIsRemote(d, r)
+	if response != nil {
+		return response
+	}
+
+	poolName := mux.Vars(r)["name"]
+	overcommit, err := storagePoolOvercommit(d.State(), poolName)
+	if err != nil {
+		return SmartError(err)
+	}
+
+	return SyncResponse(true, overcommit)
+}
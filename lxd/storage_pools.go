@@ -277,7 +277,7 @@ func storagePoolGet(d *Daemon, r *http.Request) Response {
 	// Get the existing storage pool.
 	poolID, pool, err := d.cluster.StoragePoolGet(poolName)
 	if err != nil {
-		return SmartError(err)
+		return SmartError(withNotFoundCode(api.ErrStoragePoolNotFound, err))
 	}
 
 	// Get all users of the storage pool.
@@ -287,6 +287,10 @@ func storagePoolGet(d *Daemon, r *http.Request) Response {
 	}
 	pool.UsedBy = poolUsedBy
 
+	if pool.Driver == "zfs" {
+		pool.DedupRatio = storagePoolDedupRatio(d, poolName, pool.Config)
+	}
+
 	targetNode := queryParam(r, "target")
 
 	clustered, err := cluster.Enabled(d.db)
@@ -0,0 +1,132 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"path/filepath"
+
+	"github.com/gorilla/mux"
+
+	"github.com/lxc/lxd/lxd/db"
+	"github.com/lxc/lxd/shared"
+	"github.com/lxc/lxd/shared/logger"
+)
+
+var storagePoolCompressCmd = Command{
+	name: "storage-pools/{name}/compress",
+	post: storagePoolCompressPost,
+}
+
+// Supported values of the compression storage pool key
+var storagePoolCompressionAlgorithms = []string{"lz4", "zstd", "gzip", "off"}
+
+// btrfsCompressionAlgorithm returns the name btrfs uses for a value of the
+// compression key.
+func btrfsCompressionAlgorithm(value string) string {
+	switch value {
+	case "gzip":
+		return "zlib"
+	case "off":
+		return "no"
+	}
+
+	return value
+}
+
+// zfsSetCompression sets the compression of the pool dataset, inherited by
+// all the datasets of the pool. Only newly written data gets compressed.
+func (s *storageZfs) zfsSetCompression(value string) error {
+	dataset := s.getOnDiskPoolName()
+
+	var msg string
+	var err error
+	if value == "" {
+		msg, err = shared.RunCommand("zfs", "inherit", "compression", dataset)
+	} else {
+		msg, err = shared.RunCommand("zfs", "set", fmt.Sprintf("compression=%s", value), dataset)
+	}
+	if err != nil {
+		return fmt.Errorf("Failed to set the compression of ZFS dataset \"%s\": %s", dataset, msg)
+	}
+
+	return nil
+}
+
+// btrfsCompressVolumes rewrites the data of the containers and custom volumes
+// of the pool compressed. Snapshots and images are read-only and left as is.
+func (s *storageBtrfs) btrfsCompressVolumes(value string) error {
+	_, err := s.StoragePoolMount()
+	if err != nil {
+		return err
+	}
+
+	poolMntPoint := getStoragePoolMountPoint(s.pool.Name)
+	for _, dir := range []string{"containers", "custom"} {
+		path := filepath.Join(poolMntPoint, dir)
+		if !shared.PathExists(path) {
+			continue
+		}
+
+		msg, err := shared.RunCommand("btrfs", "filesystem", "defragment", "-r", fmt.Sprintf("-c%s", btrfsCompressionAlgorithm(value)), path)
+		if err != nil {
+			return fmt.Errorf("Failed to compress \"%s\": %s", path, msg)
+		}
+	}
+
+	return nil
+}
+
+// /1.0/storage-pools/{name}/compress
+// Apply the compression of a storage pool to its existing volumes
+func storagePoolCompressPost(d *Daemon, r *http.Request) Response {
+	// If a target was specified, forward the request to the relevant node.
+	response := ForwardedResponseIfTargetIsRemote(d, r)
+	if response != nil {
+		return response
+	}
+
+	poolName := mux.Vars(r)["name"]
+	_, pool, err := d.cluster.StoragePoolGet(poolName)
+	if err != nil {
+		return SmartError(err)
+	}
+
+	if pool.Driver != "btrfs" && pool.Driver != "zfs" {
+		return BadRequest(fmt.Errorf("Compression isn't supported by %s storage pools", pool.Driver))
+	}
+
+	value := pool.Config["compression"]
+	if value == "" || value == "off" {
+		return BadRequest(fmt.Errorf("Compression isn't enabled on storage pool \"%s\"", poolName))
+	}
+
+	storage, err := storagePoolInit(d.State(), poolName)
+	if err != nil {
+		return SmartError(err)
+	}
+
+	run := func(op *operation) error {
+		logger.Infof("Compressing storage pool \"%s\" with %s", poolName, value)
+
+		var err error
+		switch s := storage.(type) {
+		case *storageZfs:
+			err = s.zfsSetCompression(value)
+		case *storageBtrfs:
+			err = s.btrfsCompressVolumes(value)
+		}
+		if err != nil {
+			return err
+		}
+
+		logger.Infof("Compressed storage pool \"%s\"", poolName)
+		return nil
+	}
+
+	op, err := operationCreate(d.cluster, "", operationClassTask, db.OperationStoragePoolCompress, nil, nil, run, nil, nil)
+	if err != nil {
+		return InternalError(err)
+	}
+
+	return OperationResponse(op)
+}
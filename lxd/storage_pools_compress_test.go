@@ -0,0 +1,21 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestBtrfsCompressionAlgorithm(t *testing.T) {
+	assert.Equal(t, "zlib", btrfsCompressionAlgorithm("gzip"))
+	assert.Equal(t, "zstd", btrfsCompressionAlgorithm("zstd"))
+	assert.Equal(t, "no", btrfsCompressionAlgorithm("off"))
+}
+
+func TestStoragePoolValidateConfigCompression(t *testing.T) {
+	assert.NoError(t, storagePoolValidateConfig("p", "zfs", map[string]string{"compression": "lz4"}, nil))
+	assert.NoError(t, storagePoolValidateConfig("p", "btrfs", map[string]string{"compression": "zstd"}, nil))
+	assert.Error(t, storagePoolValidateConfig("p", "btrfs", map[string]string{"compression": "lz4"}, nil))
+	assert.Error(t, storagePoolValidateConfig("p", "zfs", map[string]string{"compression": "lzo"}, nil))
+	assert.Error(t, storagePoolValidateConfig("p", "dir", map[string]string{"compression": "zstd"}, nil))
+}
@@ -2,6 +2,7 @@ package main
 
 import (
 	"fmt"
+	"path/filepath"
 	"strconv"
 	"strings"
 	"syscall"
@@ -17,7 +18,8 @@ func updateStoragePoolError(unchangeable []string, driverName string) error {
 var changeableStoragePoolProperties = map[string][]string{
 	"btrfs": {
 		"rsync.bwlimit",
-		"btrfs.mount_options"},
+		"btrfs.mount_options",
+		"compression"},
 
 	"ceph": {
 		"volume.block.filesystem",
@@ -28,17 +30,23 @@ var changeableStoragePoolProperties = map[string][]string{
 		"rsync.bwlimit"},
 
 	"lvm": {
+		"lvm.thinpool_metadata_threshold",
 		"lvm.thinpool_name",
 		"lvm.vg_name",
 		"volume.block.filesystem",
 		"volume.block.mount_options",
+		"volume.encryption_pbkdf_iterations",
+		"volume.encryption_pbkdf_memory",
+		"volume.encryption_pbkdf_parallel",
 		"volume.size"},
 
 	"zfs": {
+		"compression",
 		"rsync_bwlimit",
 		"volume.zfs.remove_snapshots",
 		"volume.zfs.use_refquota",
-		"zfs.clone_copy"},
+		"zfs.clone_copy",
+		"zfs.dedup"},
 }
 
 var storagePoolConfigKeys = map[string]func(value string) error{
@@ -64,10 +72,35 @@ var storagePoolConfigKeys = map[string]func(value string) error{
 	"ceph.rbd.clone_copy": shared.IsBool,
 	"ceph.user.name":      shared.IsAny,
 
+	// valid drivers: btrfs, zfs
+	"compression": func(value string) error {
+		if value == "" {
+			return nil
+		}
+
+		return shared.IsOneOf(value, storagePoolCompressionAlgorithms)
+	},
+
 	// valid drivers: lvm
 	"lvm.thinpool_name": shared.IsAny,
-	"lvm.use_thinpool":  shared.IsBool,
-	"lvm.vg_name":       shared.IsAny,
+	"lvm.thinpool_metadata_threshold": func(value string) error {
+		if value == "" {
+			return nil
+		}
+
+		threshold, err := strconv.ParseFloat(value, 64)
+		if err != nil {
+			return err
+		}
+
+		if threshold < 0 || threshold > 100 {
+			return fmt.Errorf("Threshold must be a percentage between 0 and 100")
+		}
+
+		return nil
+	},
+	"lvm.use_thinpool": shared.IsBool,
+	"lvm.vg_name":      shared.IsAny,
 
 	// valid drivers: btrfs, lvm, zfs
 	"size": func(value string) error {
@@ -96,6 +129,20 @@ var storagePoolConfigKeys = map[string]func(value string) error{
 	},
 	"volume.block.mount_options": shared.IsAny,
 
+	// valid drivers: lvm
+	"volume.encryption": shared.IsBool,
+	"volume.encryption_key_file": func(value string) error {
+		if value == "" || filepath.IsAbs(value) {
+			return nil
+		}
+
+		return fmt.Errorf("The key file must be an absolute path")
+	},
+	"volume.encryption_passphrase":       shared.IsAny,
+	"volume.encryption_pbkdf_iterations": shared.IsUint32,
+	"volume.encryption_pbkdf_memory":     shared.IsUint32,
+	"volume.encryption_pbkdf_parallel":   shared.IsUint32,
+
 	// valid drivers: ceph, lvm
 	"volume.size": func(value string) error {
 		if value == "" {
@@ -112,8 +159,17 @@ var storagePoolConfigKeys = map[string]func(value string) error{
 
 	// valid drivers: zfs
 	"zfs.clone_copy": shared.IsBool,
-	"zfs.pool_name":  shared.IsAny,
-	"rsync.bwlimit":  shared.IsAny,
+	"zfs.dedup":      shared.IsBool,
+	"zfs.encryption": shared.IsBool,
+	"zfs.encryption.key_format": func(value string) error {
+		if value == "" {
+			return nil
+		}
+
+		return shared.IsOneOf(value, zfsEncryptionKeyFormats)
+	},
+	"zfs.pool_name": shared.IsAny,
+	"rsync.bwlimit": shared.IsAny,
 }
 
 func storagePoolValidateConfig(name string, driver string, config map[string]string, oldConfig map[string]string) error {
@@ -160,7 +216,7 @@ func storagePoolValidateConfig(name string, driver string, config map[string]str
 		}
 
 		if driver != "lvm" {
-			if prfx(key, "lvm.") {
+			if prfx(key, "lvm.") || prfx(key, "volume.encryption") {
 				return fmt.Errorf("the key %s cannot be used with %s storage pools", key, strings.ToUpper(driver))
 			}
 		}
@@ -171,6 +227,16 @@ func storagePoolValidateConfig(name string, driver string, config map[string]str
 			}
 		}
 
+		if driver != "btrfs" && driver != "zfs" {
+			if key == "compression" {
+				return fmt.Errorf("the key %s cannot be used with %s storage pools", key, strings.ToUpper(driver))
+			}
+		}
+
+		if driver == "btrfs" && key == "compression" && val == "lz4" {
+			return fmt.Errorf("lz4 compression isn't supported by BTRFS storage pools")
+		}
+
 		if driver != "zfs" {
 			if prfx(key, "volume.zfs.") || prfx(key, "zfs.") {
 				return fmt.Errorf("the key %s cannot be used with %s storage pools", key, strings.ToUpper(driver))
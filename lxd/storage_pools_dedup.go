@@ -0,0 +1,187 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/gorilla/mux"
+
+	"github.com/lxc/lxd/lxd/db"
+	"github.com/lxc/lxd/shared"
+	"github.com/lxc/lxd/shared/logger"
+
+	log "github.com/lxc/lxd/shared/log15"
+)
+
+var storagePoolDedupScrubCmd = Command{
+	name: "storage-pools/{name}/dedup-scrub",
+	post: storagePoolDedupScrubPost,
+}
+
+// Memory the dedup table of a zpool is expected to need per TiB of storage
+const zfsDedupMemoryPerTiB = 1024 * 1024 * 1024
+
+// zfsDedupMemoryRequired returns the memory the dedup table of a zpool of the
+// given size is expected to need.
+func zfsDedupMemoryRequired(size uint64) uint64 {
+	return size / (1024 * 1024 * 1024 * 1024 / zfsDedupMemoryPerTiB)
+}
+
+// zfsParseDedupRatio parses the dedupratio property of a zpool (e.g. 1.42x).
+func zfsParseDedupRatio(value string) (float64, error) {
+	return strconv.ParseFloat(strings.TrimSuffix(strings.TrimSpace(value), "x"), 64)
+}
+
+// zfsZpoolName returns the name of the zpool holding the pool dataset.
+func (s *storageZfs) zfsZpoolName() string {
+	return strings.SplitN(s.getOnDiskPoolName(), "/", 2)[0]
+}
+
+// zfsZpoolGet returns a property of the zpool holding the pool dataset.
+func (s *storageZfs) zfsZpoolGet(property string) (string, error) {
+	zpool := s.zfsZpoolName()
+
+	output, err := shared.RunCommand("zpool", "get", "-Hp", "-o", "value", property, zpool)
+	if err != nil {
+		return "", fmt.Errorf("Failed to get the %s of zpool \"%s\": %s", property, zpool, output)
+	}
+
+	return strings.TrimSpace(output), nil
+}
+
+// zfsSetDedup sets the deduplication of the pool dataset, inherited by all
+// the datasets of the pool. Only newly written data gets deduplicated.
+func (s *storageZfs) zfsSetDedup(value string) error {
+	dataset := s.getOnDiskPoolName()
+
+	var msg string
+	var err error
+	if shared.IsTrue(value) {
+		s.zfsDedupCheckMemory()
+		msg, err = shared.RunCommand("zfs", "set", "dedup=on", dataset)
+	} else {
+		msg, err = shared.RunCommand("zfs", "inherit", "dedup", dataset)
+	}
+	if err != nil {
+		return fmt.Errorf("Failed to set the deduplication of ZFS dataset \"%s\": %s", dataset, msg)
+	}
+
+	return nil
+}
+
+// zfsDedupCheckMemory warns when the host lacks the memory the dedup table
+// of the zpool is expected to need, the lookups going to disk otherwise.
+func (s *storageZfs) zfsDedupCheckMemory() {
+	value, err := s.zfsZpoolGet("size")
+	if err != nil {
+		logger.Warn("Failed to check the memory needed by ZFS deduplication", log.Ctx{"pool": s.pool.Name, "err": err})
+		return
+	}
+
+	size, err := strconv.ParseUint(value, 10, 64)
+	if err != nil {
+		return
+	}
+
+	memoryTotal, err := shared.DeviceTotalMemory()
+	if err != nil {
+		return
+	}
+
+	required := zfsDedupMemoryRequired(size)
+	if uint64(memoryTotal) < required {
+		logger.Warn("Not enough memory for the ZFS dedup table, deduplication will slow down writes", log.Ctx{"pool": s.pool.Name, "zpool": s.zfsZpoolName(), "memory": memoryTotal, "required": required})
+	}
+}
+
+// zfsDedupRatio returns the deduplication ratio of the zpool holding the pool
+// dataset.
+func (s *storageZfs) zfsDedupRatio() (float64, error) {
+	value, err := s.zfsZpoolGet("dedupratio")
+	if err != nil {
+		return 0, err
+	}
+
+	return zfsParseDedupRatio(value)
+}
+
+// storagePoolDedupRatio returns the deduplication ratio of a storage pool,
+// 0 if it isn't deduplicated.
+func storagePoolDedupRatio(d *Daemon, poolName string, config map[string]string) float64 {
+	if !shared.IsTrue(config["zfs.dedup"]) {
+		return 0
+	}
+
+	storage, err := storagePoolInit(d.State(), poolName)
+	if err != nil {
+		return 0
+	}
+
+	s, ok := storage.(*storageZfs)
+	if !ok {
+		return 0
+	}
+
+	ratio, err := s.zfsDedupRatio()
+	if err != nil {
+		logger.Warn("Failed to get the deduplication ratio of storage pool", log.Ctx{"pool": poolName, "err": err})
+		return 0
+	}
+
+	return ratio
+}
+
+// /1.0/storage-pools/{name}/dedup-scrub
+// Scrub the zpool of a deduplicated storage pool
+func storagePoolDedupScrubPost(d *Daemon, r *http.Request) Response {
+	// If a target was specified, forward the request to the relevant node.
+	response := ForwardedResponseIfTargetIsRemote(d, r)
+	if response != nil {
+		return response
+	}
+
+	poolName := mux.Vars(r)["name"]
+	_, pool, err := d.cluster.StoragePoolGet(poolName)
+	if err != nil {
+		return SmartError(err)
+	}
+
+	if pool.Driver != "zfs" {
+		return BadRequest(fmt.Errorf("Deduplication isn't supported by %s storage pools", pool.Driver))
+	}
+
+	if !shared.IsTrue(pool.Config["zfs.dedup"]) {
+		return BadRequest(fmt.Errorf("Deduplication isn't enabled on storage pool \"%s\"", poolName))
+	}
+
+	storage, err := storagePoolInit(d.State(), poolName)
+	if err != nil {
+		return SmartError(err)
+	}
+
+	s, ok := storage.(*storageZfs)
+	if !ok {
+		return InternalError(fmt.Errorf("Storage pool \"%s\" isn't a ZFS pool", poolName))
+	}
+
+	run := func(op *operation) error {
+		zpool := s.zfsZpoolName()
+		logger.Infof("Scrubbing zpool \"%s\" of storage pool \"%s\"", zpool, poolName)
+
+		msg, err := shared.RunCommand("zpool", "scrub", zpool)
+		if err != nil {
+			return fmt.Errorf("Failed to scrub zpool \"%s\": %s", zpool, msg)
+		}
+
+		return nil
+	}
+
+	op, err := operationCreate(d.cluster, "", operationClassTask, db.OperationStoragePoolDedupScrub, nil, nil, run, nil, nil)
+	if err != nil {
+		return InternalError(err)
+	}
+
+	return OperationResponse(op)
+}
@@ -0,0 +1,33 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestZfsDedupMemoryRequired(t *testing.T) {
+	assert.Equal(t, uint64(1024*1024*1024), zfsDedupMemoryRequired(1024*1024*1024*1024))
+	assert.Equal(t, uint64(512*1024*1024), zfsDedupMemoryRequired(512*1024*1024*1024))
+	assert.Equal(t, uint64(0), zfsDedupMemoryRequired(0))
+}
+
+func TestZfsParseDedupRatio(t *testing.T) {
+	ratio, err := zfsParseDedupRatio("1.42x\n")
+	require.NoError(t, err)
+	assert.Equal(t, 1.42, ratio)
+
+	ratio, err = zfsParseDedupRatio("1.00")
+	require.NoError(t, err)
+	assert.Equal(t, 1.0, ratio)
+
+	_, err = zfsParseDedupRatio("-")
+	assert.Error(t, err)
+}
+
+func TestStoragePoolValidateConfigDedup(t *testing.T) {
+	assert.NoError(t, storagePoolValidateConfig("p", "zfs", map[string]string{"zfs.dedup": "on"}, nil))
+	assert.Error(t, storagePoolValidateConfig("p", "zfs", map[string]string{"zfs.dedup": "sha512"}, nil))
+	assert.Error(t, storagePoolValidateConfig("p", "btrfs", map[string]string{"zfs.dedup": "on"}, nil))
+}
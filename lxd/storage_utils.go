@@ -281,6 +281,42 @@ func growFileSystem(fsType string, devPath string, mntpoint string) error {
 	return nil
 }
 
+// growFileSystemLive grows the filesystem of a block device mounted as the
+// root filesystem of a running container.
+func growFileSystemLive(fsType string, devPath string, c container) error {
+	var msg string
+	var err error
+	switch fsType {
+	case "": // if not specified, default to ext4
+		fallthrough
+	case "ext4":
+		// resize2fs needs the device node which isn't available in
+		// the container, while the volume is also mounted on the host
+		msg, err = shared.TryRunCommand("resize2fs", devPath)
+	case "xfs":
+		// Use the xfs_growfs of the host on the host mount point of the
+		// volume rather than running whatever the container holds
+		var poolName string
+		poolName, err = c.StoragePool()
+		if err != nil {
+			return err
+		}
+
+		msg, err = shared.TryRunCommand("xfs_growfs", getContainerMountPoint(c.Project(), poolName, c.Name()))
+	default:
+		return fmt.Errorf(`Growing a mounted %s filesystem isn't supported`, fsType)
+	}
+
+	if err != nil {
+		errorMsg := fmt.Sprintf(`Could not extend underlying %s filesystem for "%s": %s`, fsType, devPath, msg)
+		logger.Errorf(errorMsg)
+		return fmt.Errorf(errorMsg)
+	}
+
+	logger.Debugf(`extended underlying %s filesystem for "%s" of running container "%s"`, fsType, devPath, c.Name())
+	return nil
+}
+
 func shrinkFileSystem(fsType string, devPath string, mntpoint string, byteSize int64) error {
 	strSize := fmt.Sprintf("%dK", byteSize/1024)
 
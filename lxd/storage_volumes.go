@@ -258,6 +258,12 @@ func storagePoolVolumesTypePost(d *Daemon, r *http.Request) Response {
 
 	poolName := mux.Vars(r)["name"]
 
+	// Refuse volumes overcommitting the pool beyond the configured limit
+	response = storagePoolOvercommitCheck(d, poolName, req.Config)
+	if response != nil {
+		return response
+	}
+
 	switch req.Source.Type {
 	case "":
 		return doVolumeCreateOrCopy(d, poolName, &req)
@@ -272,7 +278,13 @@ func storagePoolVolumesTypePost(d *Daemon, r *http.Request) Response {
 
 func doVolumeCreateOrCopy(d *Daemon, poolName string, req *api.StorageVolumesPost) Response {
 	doWork := func() error {
-		return storagePoolVolumeCreateInternal(d.State(), poolName, req)
+		err := storagePoolVolumeCreateInternal(d.State(), poolName, req)
+		if err != nil {
+			return err
+		}
+
+		storagePoolOvercommitNotify(d.State(), poolName)
+		return nil
 	}
 
 	if req.Source.Name == "" {
@@ -338,6 +350,12 @@ func storagePoolVolumesPost(d *Daemon, r *http.Request) Response {
 
 	poolName := mux.Vars(r)["name"]
 
+	// Refuse volumes overcommitting the pool beyond the configured limit
+	response = storagePoolOvercommitCheck(d, poolName, req.Config)
+	if response != nil {
+		return response
+	}
+
 	switch req.Source.Type {
 	case "":
 		return doVolumeCreateOrCopy(d, poolName, &req)
@@ -0,0 +1,218 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"syscall"
+
+	"github.com/gorilla/mux"
+
+	"github.com/lxc/lxd/lxd/db"
+	"github.com/lxc/lxd/lxd/types"
+	"github.com/lxc/lxd/shared"
+	"github.com/lxc/lxd/shared/api"
+)
+
+// Registered ahead of storagePoolVolumeTypeContainerCmd whose name pattern
+// would match it too.
+var storagePoolVolumeTypeResizeCmd = Command{
+	name: "storage-pools/{pool}/volumes/{type}/{name}/resize",
+	post: storagePoolVolumeTypeResizePost,
+}
+
+// storageVolumeFilesystemUsage returns the space used on a filesystem.
+func storageVolumeFilesystemUsage(path string) (int64, error) {
+	var stat syscall.Statfs_t
+	err := syscall.Statfs(path, &stat)
+	if err != nil {
+		return -1, err
+	}
+
+	return int64(stat.Blocks-stat.Bfree) * int64(stat.Bsize), nil
+}
+
+// storageVolumeResizeCheck makes sure that the data of a volume fits in its
+// new size.
+func storageVolumeResizeCheck(used int64, size int64) error {
+	if size < used {
+		return fmt.Errorf("The new size %s is smaller than the %s used on the volume", shared.GetByteSizeString(size, 0), shared.GetByteSizeString(used, 0))
+	}
+
+	return nil
+}
+
+func storagePoolVolumeTypeResizePost(d *Daemon, r *http.Request) Response {
+	project := projectParam(r)
+	poolName := mux.Vars(r)["pool"]
+	volumeName := mux.Vars(r)["name"]
+	volumeTypeName := mux.Vars(r)["type"]
+
+	// Convert the volume type name to our internal integer representation.
+	volumeType, err := storagePoolVolumeTypeNameToType(volumeTypeName)
+	if err != nil {
+		return BadRequest(err)
+	}
+
+	if volumeType != storagePoolVolumeTypeContainer && volumeType != storagePoolVolumeTypeCustom {
+		return BadRequest(fmt.Errorf("Resizing storage volumes of type %s is not allowed", volumeTypeName))
+	}
+
+	req := api.StorageVolumeResizePost{}
+	err = json.NewDecoder(r.Body).Decode(&req)
+	if err != nil {
+		return BadRequest(err)
+	}
+
+	size, err := shared.ParseByteSizeString(req.Size)
+	if err != nil {
+		return BadRequest(err)
+	}
+
+	if size <= 0 {
+		return BadRequest(fmt.Errorf("No size provided"))
+	}
+
+	poolID, err := d.cluster.StoragePoolGetID(poolName)
+	if err != nil {
+		return SmartError(err)
+	}
+
+	response := ForwardedResponseIfTargetIsRemote(d, r)
+	if response != nil {
+		return response
+	}
+
+	if volumeType == storagePoolVolumeTypeContainer {
+		response, err = ForwardedResponseIfContainerIsRemote(d, r, project, volumeName)
+		if err != nil {
+			return SmartError(err)
+		}
+	} else {
+		response = ForwardedResponseIfVolumeIsRemote(d, r, poolID, volumeName, volumeType)
+	}
+	if response != nil {
+		return response
+	}
+
+	if volumeType == storagePoolVolumeTypeContainer {
+		return storagePoolVolumeContainerResize(d, project, poolName, volumeName, size)
+	}
+
+	return storagePoolVolumeCustomResize(d, poolID, poolName, volumeName, size)
+}
+
+// storagePoolVolumeContainerResize resizes the root volume of a container
+// through the size of its root disk device, growing the volume right away
+// when the container is running if the storage driver supports it.
+func storagePoolVolumeContainerResize(d *Daemon, project string, poolName string, name string, size int64) Response {
+	c, err := containerLoadByProjectAndName(d.State(), project, name)
+	if err != nil {
+		return SmartError(err)
+	}
+
+	rootDiskName, rootDisk, err := shared.GetRootDiskDevice(c.ExpandedDevices())
+	if err != nil {
+		return BadRequest(err)
+	}
+
+	if rootDisk["pool"] != poolName {
+		return BadRequest(fmt.Errorf("The root disk of container '%s' isn't on storage pool '%s'", name, poolName))
+	}
+
+	ourStart, err := c.StorageStart()
+	if err != nil {
+		return SmartError(err)
+	}
+	if ourStart {
+		defer c.StorageStop()
+	}
+
+	used, err := storageVolumeFilesystemUsage(c.Path())
+	if err != nil {
+		return SmartError(err)
+	}
+
+	err = storageVolumeResizeCheck(used, size)
+	if err != nil {
+		return BadRequest(err)
+	}
+
+	// Override the root disk device inherited from a profile
+	localDevices := c.LocalDevices()
+	devices := types.Devices{}
+	err = shared.DeepCopy(&localDevices, &devices)
+	if err != nil {
+		return SmartError(err)
+	}
+
+	if devices[rootDiskName] == nil {
+		devices[rootDiskName] = types.Device{}
+		for k, v := range rootDisk {
+			devices[rootDiskName][k] = v
+		}
+	}
+	devices[rootDiskName]["size"] = shared.GetByteSizeString(size, 0)
+
+	args := db.ContainerArgs{
+		Architecture: c.Architecture(),
+		Config:       c.LocalConfig(),
+		Description:  c.Description(),
+		Devices:      devices,
+		Ephemeral:    c.IsEphemeral(),
+		Profiles:     c.Profiles(),
+		Project:      c.Project(),
+	}
+
+	err = c.Update(args, true)
+	if err != nil {
+		return SmartError(err)
+	}
+
+	return EmptySyncResponse
+}
+
+// storagePoolVolumeCustomResize resizes a custom volume through its size
+// config key.
+func storagePoolVolumeCustomResize(d *Daemon, poolID int64, poolName string, name string, size int64) Response {
+	_, volume, err := d.cluster.StoragePoolNodeVolumeGetType(name, storagePoolVolumeTypeCustom, poolID)
+	if err != nil {
+		return SmartError(err)
+	}
+
+	s, err := storagePoolVolumeInit(d.State(), "default", poolName, name, storagePoolVolumeTypeCustom)
+	if err != nil {
+		return SmartError(err)
+	}
+
+	ourMount, err := s.StoragePoolVolumeMount()
+	if err != nil {
+		return SmartError(err)
+	}
+
+	used, err := storageVolumeFilesystemUsage(getStoragePoolVolumeMountPoint(poolName, name))
+	if ourMount {
+		s.StoragePoolVolumeUmount()
+	}
+	if err != nil {
+		return SmartError(err)
+	}
+
+	err = storageVolumeResizeCheck(used, size)
+	if err != nil {
+		return BadRequest(err)
+	}
+
+	config := map[string]string{}
+	for k, v := range volume.Config {
+		config[k] = v
+	}
+	config["size"] = shared.GetByteSizeString(size, 0)
+
+	err = storagePoolVolumeUpdate(d.State(), poolName, name, storagePoolVolumeTypeCustom, volume.Description, config)
+	if err != nil {
+		return SmartError(err)
+	}
+
+	return EmptySyncResponse
+}
@@ -0,0 +1,29 @@
+package main
+
+import (
+	"io/ioutil"
+	"os"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestStorageVolumeResizeCheck(t *testing.T) {
+	assert.NoError(t, storageVolumeResizeCheck(1024, 2048))
+	assert.NoError(t, storageVolumeResizeCheck(2048, 2048))
+	assert.Error(t, storageVolumeResizeCheck(4096, 2048))
+}
+
+func TestStorageVolumeFilesystemUsage(t *testing.T) {
+	dir, err := ioutil.TempDir("", "lxd_resize_")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	used, err := storageVolumeFilesystemUsage(dir)
+	require.NoError(t, err)
+	assert.True(t, used >= 0)
+
+	_, err = storageVolumeFilesystemUsage(dir + "/missing")
+	assert.Error(t, err)
+}
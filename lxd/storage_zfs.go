@@ -153,6 +153,21 @@ func (s *storageZfs) StoragePoolCreate() error {
 		return err
 	}
 
+	// Leave the properties of an existing dataset alone unless asked for
+	if s.pool.Config["compression"] != "" {
+		err = s.zfsSetCompression(s.pool.Config["compression"])
+		if err != nil {
+			return err
+		}
+	}
+
+	if s.pool.Config["zfs.dedup"] != "" {
+		err = s.zfsSetDedup(s.pool.Config["zfs.dedup"])
+		if err != nil {
+			return err
+		}
+	}
+
 	revert = false
 
 	logger.Infof("Created ZFS storage pool \"%s\"", s.pool.Name)
@@ -659,6 +674,20 @@ func (s *storageZfs) StoragePoolUpdate(writable *api.StoragePoolPut, changedConf
 	// "volume.zfs.remove_snapshots" requires no on-disk modifications.
 	// "volume.zfs.use_refquota" requires no on-disk modifications.
 
+	if shared.StringInSlice("compression", changedConfig) {
+		err := s.zfsSetCompression(writable.Config["compression"])
+		if err != nil {
+			return err
+		}
+	}
+
+	if shared.StringInSlice("zfs.dedup", changedConfig) {
+		err := s.zfsSetDedup(writable.Config["zfs.dedup"])
+		if err != nil {
+			return err
+		}
+	}
+
 	logger.Infof(`Updated ZFS storage pool "%s"`, s.pool.Name)
 	return nil
 }
@@ -818,6 +847,10 @@ func (s *storageZfs) ContainerUmount(c container, path string) (bool, error) {
 		return false, imgerr
 	}
 
+	if ourUmount {
+		s.encryptionUnloadKey(c.Project(), name)
+	}
+
 	logger.Debugf("Unmounted ZFS storage volume for container \"%s\" on storage pool \"%s\"", s.volume.Name, s.pool.Name)
 	return ourUmount, nil
 }
@@ -861,6 +894,16 @@ func (s *storageZfs) ContainerCreateFromImage(container container, fingerprint s
 	fs := fmt.Sprintf("containers/%s", volumeName)
 	containerPoolVolumeMntPoint := getContainerMountPoint(container.Project(), s.pool.Name, containerName)
 
+	if s.usesEncryption() {
+		err := s.containerCreateFromImageEncrypted(container, fingerprint)
+		if err != nil {
+			return err
+		}
+
+		logger.Debugf("Created encrypted ZFS storage volume for container \"%s\" on storage pool \"%s\"", s.volume.Name, s.pool.Name)
+		return nil
+	}
+
 	poolName := s.getOnDiskPoolName()
 	fsImage := fmt.Sprintf("images/%s", fingerprint)
 
@@ -1307,6 +1350,11 @@ func (s *storageZfs) ContainerCopy(target container, source container, container
 		return s.doCrossPoolContainerCopy(target, source, containerOnly, false, nil)
 	}
 
+	// Clones would share the encryption key of the source container
+	if s.usesEncryption() {
+		return s.doCrossPoolContainerCopy(target, source, containerOnly, false, nil)
+	}
+
 	snapshots, err := source.Snapshots()
 	if err != nil {
 		return err
@@ -1417,6 +1465,40 @@ func (s *storageZfs) ContainerCopy(target container, source container, container
 	return nil
 }
 
+func (s *storageZfs) ContainerCopyModes() []string {
+	// Clones would share the encryption key of the source container
+	if s.usesEncryption() {
+		return []string{"copy"}
+	}
+
+	return []string{"snapshot", "copy"}
+}
+
+func (s *storageZfs) ContainerCopyWithMode(target container, source container, containerOnly bool, mode string) error {
+	if mode != "copy" {
+		return s.ContainerCopy(target, source, containerOnly)
+	}
+
+	logger.Debugf("Fully copying ZFS container storage %s to %s", source.Name(), target.Name())
+
+	ourStart, err := source.StorageStart()
+	if err != nil {
+		return err
+	}
+	if ourStart {
+		defer source.StorageStop()
+	}
+
+	// The rsync based copy used across pools makes full copies
+	err = s.doCrossPoolContainerCopy(target, source, containerOnly, false, nil)
+	if err != nil {
+		return err
+	}
+
+	logger.Debugf("Fully copied ZFS container storage %s to %s", source.Name(), target.Name())
+	return nil
+}
+
 func (s *storageZfs) ContainerRefresh(target container, source container, snapshots []container) error {
 	logger.Debugf("Refreshing ZFS container storage for %s from %s", target.Name(), source.Name())
 
@@ -1826,9 +1908,15 @@ func (s *storageZfs) ContainerSnapshotStart(container container) (bool, error) {
 	sourceSnap := fmt.Sprintf("snapshot-%s", sName)
 	destFs := fmt.Sprintf("snapshots/%s/%s", projectPrefix(container.Project(), cName), sName)
 
+	// The clone shares the encryption key of the container
+	_, err := s.encryptionLoadKey(container.Project(), cName)
+	if err != nil {
+		return false, err
+	}
+
 	poolName := s.getOnDiskPoolName()
 	snapshotMntPoint := getSnapshotMountPoint(container.Project(), s.pool.Name, container.Name())
-	err := zfsPoolVolumeClone(container.Project(), poolName, sourceFs, sourceSnap, destFs, snapshotMntPoint)
+	err = zfsPoolVolumeClone(container.Project(), poolName, sourceFs, sourceSnap, destFs, snapshotMntPoint)
 	if err != nil {
 		return false, err
 	}
@@ -0,0 +1,490 @@
+package main
+
+import (
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/hex"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"strings"
+
+	"github.com/gorilla/mux"
+
+	"github.com/lxc/lxd/shared"
+	"github.com/lxc/lxd/shared/logger"
+
+	log "github.com/lxc/lxd/shared/log15"
+)
+
+var containerEncryptionKeyCmd = Command{
+	name: "containers/{name}/encryption-key",
+	post: containerEncryptionKeyPost,
+}
+
+// Supported values of zfs.encryption.key_format
+var zfsEncryptionKeyFormats = []string{"hex", "passphrase", "raw"}
+
+// Prefix of the encryption keys wrapped with the storage master key
+const storageKeyWrappedPrefix = "aes-gcm:"
+
+// storageMasterKey returns the key used to wrap the encryption keys of the
+// storage volumes before storing them in the database, or nil if there's none.
+// The key is 32 bytes hex-encoded in the storage-master.key file of the LXD
+// directory, typically provisioned from a HSM.
+func storageMasterKey() ([]byte, error) {
+	content, err := ioutil.ReadFile(shared.VarPath("storage-master.key"))
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+
+		return nil, err
+	}
+
+	key, err := hex.DecodeString(strings.TrimSpace(string(content)))
+	if err != nil || len(key) != 32 {
+		return nil, fmt.Errorf("The storage master key must be 32 bytes hex-encoded")
+	}
+
+	return key, nil
+}
+
+// storageKeyWrap encrypts a storage volume key with the master key, if any.
+func storageKeyWrap(masterKey []byte, key string) (string, error) {
+	if masterKey == nil {
+		return key, nil
+	}
+
+	block, err := aes.NewCipher(masterKey)
+	if err != nil {
+		return "", err
+	}
+
+	gcm, err := cipher.NewGCM(block)
+	if err != nil {
+		return "", err
+	}
+
+	nonce := make([]byte, gcm.NonceSize())
+	_, err = rand.Read(nonce)
+	if err != nil {
+		return "", err
+	}
+
+	sealed := gcm.Seal(nonce, nonce, []byte(key), nil)
+	return storageKeyWrappedPrefix + base64.StdEncoding.EncodeToString(sealed), nil
+}
+
+// storageKeyUnwrap decrypts a storage volume key wrapped by storageKeyWrap.
+func storageKeyUnwrap(masterKey []byte, value string) (string, error) {
+	if !strings.HasPrefix(value, storageKeyWrappedPrefix) {
+		return value, nil
+	}
+
+	if masterKey == nil {
+		return "", fmt.Errorf("The encryption key is wrapped but no storage master key is available")
+	}
+
+	sealed, err := base64.StdEncoding.DecodeString(strings.TrimPrefix(value, storageKeyWrappedPrefix))
+	if err != nil {
+		return "", err
+	}
+
+	block, err := aes.NewCipher(masterKey)
+	if err != nil {
+		return "", err
+	}
+
+	gcm, err := cipher.NewGCM(block)
+	if err != nil {
+		return "", err
+	}
+
+	if len(sealed) < gcm.NonceSize() {
+		return "", fmt.Errorf("Invalid wrapped encryption key")
+	}
+
+	key, err := gcm.Open(nil, sealed[:gcm.NonceSize()], sealed[gcm.NonceSize():], nil)
+	if err != nil {
+		return "", fmt.Errorf("Failed to unwrap the encryption key: %v", err)
+	}
+
+	return string(key), nil
+}
+
+// zfsEncryptionKeyMaterial returns what is to be passed to zfs for a key
+// stored in the database (32 bytes hex-encoded) and a key format.
+func zfsEncryptionKeyMaterial(format string, key string) ([]byte, error) {
+	if format == "raw" {
+		return hex.DecodeString(key)
+	}
+
+	return []byte(key), nil
+}
+
+// zfsRunWithKey runs a zfs command reading the key from its standard input.
+func zfsRunWithKey(key []byte, args ...string) error {
+	return shared.RunCommandWithFds(bytes.NewReader(key), nil, "zfs", args...)
+}
+
+func (s *storageZfs) usesEncryption() bool {
+	return shared.IsTrue(s.pool.Config["zfs.encryption"])
+}
+
+func (s *storageZfs) getEncryptionKeyFormat() string {
+	if s.pool.Config["zfs.encryption.key_format"] != "" {
+		return s.pool.Config["zfs.encryption.key_format"]
+	}
+
+	return "hex"
+}
+
+func (s *storageZfs) encryptionVolumeID(project, name string) (int64, error) {
+	volumeID, _, err := s.s.Cluster.StoragePoolNodeVolumeGetTypeByProject(project, name, storagePoolVolumeTypeContainer, s.poolID)
+	if err != nil {
+		return -1, err
+	}
+
+	return volumeID, nil
+}
+
+// encryptionKeyNew generates a new key, returning it wrapped for storage in
+// the database and as passed to zfs.
+func (s *storageZfs) encryptionKeyNew() (string, []byte, error) {
+	buf := make([]byte, 32)
+	_, err := rand.Read(buf)
+	if err != nil {
+		return "", nil, err
+	}
+	key := hex.EncodeToString(buf)
+
+	masterKey, err := storageMasterKey()
+	if err != nil {
+		return "", nil, err
+	}
+
+	wrapped, err := storageKeyWrap(masterKey, key)
+	if err != nil {
+		return "", nil, err
+	}
+
+	material, err := zfsEncryptionKeyMaterial(s.getEncryptionKeyFormat(), key)
+	if err != nil {
+		return "", nil, err
+	}
+
+	return wrapped, material, nil
+}
+
+// encryptionKeyMaterial returns what is to be passed to zfs for a key stored
+// in the database.
+func (s *storageZfs) encryptionKeyMaterial(wrapped string) ([]byte, error) {
+	masterKey, err := storageMasterKey()
+	if err != nil {
+		return nil, err
+	}
+
+	key, err := storageKeyUnwrap(masterKey, wrapped)
+	if err != nil {
+		return nil, err
+	}
+
+	return zfsEncryptionKeyMaterial(s.getEncryptionKeyFormat(), key)
+}
+
+// encryptionKeyGenerate generates a new key for the rootfs of a container and
+// stores it in the database.
+func (s *storageZfs) encryptionKeyGenerate(project, name string) ([]byte, error) {
+	volumeID, err := s.encryptionVolumeID(project, name)
+	if err != nil {
+		return nil, err
+	}
+
+	wrapped, key, err := s.encryptionKeyNew()
+	if err != nil {
+		return nil, err
+	}
+
+	err = s.s.Cluster.StorageVolumeEncryptionKeySet(volumeID, wrapped)
+	if err != nil {
+		return nil, err
+	}
+
+	return key, nil
+}
+
+// encryptionKeyGet returns the key of the rootfs of a container.
+func (s *storageZfs) encryptionKeyGet(project, name string) ([]byte, error) {
+	volumeID, err := s.encryptionVolumeID(project, name)
+	if err != nil {
+		return nil, err
+	}
+
+	wrapped, err := s.s.Cluster.StorageVolumeEncryptionKeyGet(volumeID)
+	if err != nil {
+		return nil, err
+	}
+
+	return s.encryptionKeyMaterial(wrapped)
+}
+
+// encryptionCreate creates the encrypted dataset of the rootfs of a container.
+func (s *storageZfs) encryptionCreate(project, name string, dataset string, properties ...string) error {
+	key, err := s.encryptionKeyGenerate(project, name)
+	if err != nil {
+		return err
+	}
+
+	args := []string{"create",
+		"-o", "encryption=on",
+		"-o", fmt.Sprintf("keyformat=%s", s.getEncryptionKeyFormat()),
+		"-o", "keylocation=prompt"}
+	for _, prop := range properties {
+		args = append(args, "-o", prop)
+	}
+	args = append(args, "-p", dataset)
+
+	return zfsRunWithKey(key, args...)
+}
+
+// encryptionLoadKey loads the key of the rootfs of a container if the pool is
+// encrypted and the key isn't loaded yet.
+func (s *storageZfs) encryptionLoadKey(project, name string) (bool, error) {
+	if !s.usesEncryption() {
+		return false, nil
+	}
+
+	fs := fmt.Sprintf("containers/%s", projectPrefix(project, name))
+	status, err := zfsFilesystemEntityPropertyGet(s.getOnDiskPoolName(), fs, "keystatus")
+	if err != nil {
+		return false, err
+	}
+
+	// Datasets created before the encryption was enabled
+	if status == "-" || status == "available" {
+		return false, nil
+	}
+
+	key, err := s.encryptionKeyGet(project, name)
+	if err != nil {
+		return false, fmt.Errorf("Failed to retrieve the encryption key of container \"%s\": %v", name, err)
+	}
+
+	err = zfsRunWithKey(key, "load-key", fmt.Sprintf("%s/%s", s.getOnDiskPoolName(), fs))
+	if err != nil {
+		// The key may have been replaced without the replacement being
+		// committed to the database
+		nextErr := s.encryptionLoadNextKey(project, name)
+		if nextErr != nil {
+			return false, err
+		}
+	}
+
+	return true, nil
+}
+
+// encryptionLoadNextKey loads the rootfs of a container with the key it was
+// being rotated to, committing it if that works.
+func (s *storageZfs) encryptionLoadNextKey(project, name string) error {
+	volumeID, err := s.encryptionVolumeID(project, name)
+	if err != nil {
+		return err
+	}
+
+	wrapped, err := s.s.Cluster.StorageVolumeEncryptionNextKeyGet(volumeID)
+	if err != nil {
+		return err
+	}
+
+	if wrapped == "" {
+		return fmt.Errorf("No key rotation in progress")
+	}
+
+	key, err := s.encryptionKeyMaterial(wrapped)
+	if err != nil {
+		return err
+	}
+
+	fs := fmt.Sprintf("containers/%s", projectPrefix(project, name))
+	err = zfsRunWithKey(key, "load-key", fmt.Sprintf("%s/%s", s.getOnDiskPoolName(), fs))
+	if err != nil {
+		return err
+	}
+
+	logger.Warn("Completing an interrupted encryption key rotation", log.Ctx{"container": name})
+
+	return s.s.Cluster.StorageVolumeEncryptionKeyCommit(volumeID)
+}
+
+// encryptionUnloadKey unloads the key of the rootfs of a container, failing
+// silently if the dataset or one of its clones is still mounted.
+func (s *storageZfs) encryptionUnloadKey(project, name string) {
+	if !s.usesEncryption() {
+		return
+	}
+
+	fs := fmt.Sprintf("containers/%s", projectPrefix(project, name))
+	status, err := zfsFilesystemEntityPropertyGet(s.getOnDiskPoolName(), fs, "keystatus")
+	if err != nil || status != "available" {
+		return
+	}
+
+	_, err = shared.RunCommand("zfs", "unload-key", fmt.Sprintf("%s/%s", s.getOnDiskPoolName(), fs))
+	if err != nil {
+		logger.Debug("Failed to unload encryption key", log.Ctx{"container": name, "err": err})
+	}
+}
+
+// encryptionKeyRotate replaces the key of the rootfs of a container, which
+// may be running.
+func (s *storageZfs) encryptionKeyRotate(project, name string) error {
+	if !s.usesEncryption() {
+		return fmt.Errorf("Storage pool \"%s\" isn't encrypted", s.pool.Name)
+	}
+
+	loaded, err := s.encryptionLoadKey(project, name)
+	if err != nil {
+		return err
+	}
+	if loaded {
+		defer s.encryptionUnloadKey(project, name)
+	}
+
+	volumeID, err := s.encryptionVolumeID(project, name)
+	if err != nil {
+		return err
+	}
+
+	dataset := fmt.Sprintf("%s/containers/%s", s.getOnDiskPoolName(), projectPrefix(project, name))
+
+	// Settle an interrupted rotation first, checking which of the keys the
+	// dataset uses
+	pending, err := s.s.Cluster.StorageVolumeEncryptionNextKeyGet(volumeID)
+	if err != nil {
+		return err
+	}
+
+	if pending != "" {
+		key, err := s.encryptionKeyMaterial(pending)
+		if err != nil {
+			return err
+		}
+
+		err = zfsRunWithKey(key, "load-key", "-n", dataset)
+		if err == nil {
+			err = s.s.Cluster.StorageVolumeEncryptionKeyCommit(volumeID)
+		} else {
+			err = s.s.Cluster.StorageVolumeEncryptionNextKeySet(volumeID, "")
+		}
+		if err != nil {
+			return err
+		}
+	}
+
+	// Both keys are kept until the dataset uses the new one, so that it
+	// can always be loaded
+	wrapped, key, err := s.encryptionKeyNew()
+	if err != nil {
+		return err
+	}
+
+	err = s.s.Cluster.StorageVolumeEncryptionNextKeySet(volumeID, wrapped)
+	if err != nil {
+		return err
+	}
+
+	err = zfsRunWithKey(key, "change-key",
+		"-o", fmt.Sprintf("keyformat=%s", s.getEncryptionKeyFormat()),
+		"-o", "keylocation=prompt", dataset)
+	if err != nil {
+		// The dataset still uses the old key
+		revertErr := s.s.Cluster.StorageVolumeEncryptionNextKeySet(volumeID, "")
+		if revertErr != nil {
+			logger.Error("Failed to discard the new encryption key", log.Ctx{"container": name, "err": revertErr})
+		}
+
+		return err
+	}
+
+	return s.s.Cluster.StorageVolumeEncryptionKeyCommit(volumeID)
+}
+
+// containerCreateFromImageEncrypted creates the rootfs of a container on an
+// encrypted pool. As clones share the encryption key of their origin, the
+// image is unpacked into a new dataset rather than cloned.
+func (s *storageZfs) containerCreateFromImageEncrypted(c container, fingerprint string) error {
+	err := s.doContainerCreate(c.Project(), c.Name(), c.IsPrivileged())
+	if err != nil {
+		s.doContainerDelete(c.Project(), c.Name())
+		return err
+	}
+
+	revert := true
+	defer func() {
+		if !revert {
+			return
+		}
+		s.ContainerDelete(c)
+	}()
+
+	ourMount, err := s.ContainerMount(c)
+	if err != nil {
+		return err
+	}
+	if ourMount {
+		defer s.ContainerUmount(c, c.Path())
+	}
+
+	imagePath := shared.VarPath("images", fingerprint)
+	containerMntPoint := getContainerMountPoint(c.Project(), s.pool.Name, c.Name())
+	err = unpackImage(imagePath, containerMntPoint, storageTypeZfs, s.s.OS.RunningInUserNS, nil)
+	if err != nil {
+		return err
+	}
+
+	err = c.TemplateApply("create")
+	if err != nil {
+		return err
+	}
+
+	revert = false
+	return nil
+}
+
+// /1.0/containers/<name>/encryption-key
+// Rotate the encryption key of the container rootfs
+func containerEncryptionKeyPost(d *Daemon, r *http.Request) Response {
+	project := projectParam(r)
+	name := mux.Vars(r)["name"]
+
+	// Handle requests targeted to a container on a different node
+	response, err := ForwardedResponseIfContainerIsRemote(d, r, project, name)
+	if err != nil {
+		return SmartError(err)
+	}
+	if response != nil {
+		return response
+	}
+
+	c, err := containerLoadByProjectAndName(d.State(), project, name)
+	if err != nil {
+		return SmartError(err)
+	}
+
+	storage, ok := c.Storage().(*storageZfs)
+	if !ok {
+		return BadRequest(fmt.Errorf("Encryption keys can only be rotated on ZFS storage pools"))
+	}
+
+	err = storage.encryptionKeyRotate(c.Project(), c.Name())
+	if err != nil {
+		return SmartError(err)
+	}
+
+	return EmptySyncResponse
+}
@@ -0,0 +1,45 @@
+package main
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestStorageKeyWrap(t *testing.T) {
+	masterKey := bytes.Repeat([]byte{0x42}, 32)
+	key := "00112233445566778899aabbccddeeff00112233445566778899aabbccddeeff"
+
+	wrapped, err := storageKeyWrap(masterKey, key)
+	require.NoError(t, err)
+	assert.NotContains(t, wrapped, key)
+
+	unwrapped, err := storageKeyUnwrap(masterKey, wrapped)
+	require.NoError(t, err)
+	assert.Equal(t, key, unwrapped)
+
+	_, err = storageKeyUnwrap(bytes.Repeat([]byte{0x43}, 32), wrapped)
+	assert.Error(t, err)
+
+	_, err = storageKeyUnwrap(nil, wrapped)
+	assert.Error(t, err)
+
+	// Keys stored without master key
+	unwrapped, err = storageKeyUnwrap(masterKey, key)
+	require.NoError(t, err)
+	assert.Equal(t, key, unwrapped)
+}
+
+func TestZfsEncryptionKeyMaterial(t *testing.T) {
+	key := "00ff"
+
+	material, err := zfsEncryptionKeyMaterial("raw", key)
+	require.NoError(t, err)
+	assert.Equal(t, []byte{0x00, 0xff}, material)
+
+	material, err = zfsEncryptionKeyMaterial("hex", key)
+	require.NoError(t, err)
+	assert.Equal(t, []byte(key), material)
+}
@@ -678,6 +678,11 @@ func (s *storageZfs) doContainerMount(project, name string, privileged bool) (bo
 
 	ourMount := false
 	if !shared.IsMountPoint(containerPoolVolumeMntPoint) {
+		_, err := s.encryptionLoadKey(project, name)
+		if err != nil {
+			return false, err
+		}
+
 		source := fmt.Sprintf("%s/%s", s.getOnDiskPoolName(), fs)
 		zfsMountOptions := fmt.Sprintf("rw,zfsutil,mntpoint=%s", containerPoolVolumeMntPoint)
 		mounterr := tryMount(source, containerPoolVolumeMntPoint, "zfs", 0, zfsMountOptions)
@@ -798,14 +803,22 @@ func (s *storageZfs) doContainerCreate(project, name string, privileged bool) er
 	containerPoolVolumeMntPoint := getContainerMountPoint(project, s.pool.Name, containerName)
 
 	// Create volume.
-	msg, err := zfsPoolVolumeCreate(dataset, "mountpoint=none", "canmount=noauto")
-	if err != nil {
-		logger.Errorf("Failed to create ZFS storage volume for container \"%s\" on storage pool \"%s\": %s", s.volume.Name, s.pool.Name, msg)
-		return err
+	if s.usesEncryption() {
+		err := s.encryptionCreate(project, name, dataset, "mountpoint=none", "canmount=noauto")
+		if err != nil {
+			logger.Errorf("Failed to create encrypted ZFS storage volume for container \"%s\" on storage pool \"%s\": %s", s.volume.Name, s.pool.Name, err)
+			return err
+		}
+	} else {
+		msg, err := zfsPoolVolumeCreate(dataset, "mountpoint=none", "canmount=noauto")
+		if err != nil {
+			logger.Errorf("Failed to create ZFS storage volume for container \"%s\" on storage pool \"%s\": %s", s.volume.Name, s.pool.Name, msg)
+			return err
+		}
 	}
 
 	// Set mountpoint.
-	err = zfsPoolVolumeSet(poolName, fs, "mountpoint", containerPoolVolumeMntPoint)
+	err := zfsPoolVolumeSet(poolName, fs, "mountpoint", containerPoolVolumeMntPoint)
 	if err != nil {
 		return err
 	}
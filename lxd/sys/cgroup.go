@@ -2,6 +2,8 @@ package sys
 
 import (
 	"fmt"
+	"io/ioutil"
+	"strings"
 
 	"github.com/lxc/lxd/shared"
 	"github.com/lxc/lxd/shared/logger"
@@ -27,6 +29,13 @@ func (s *OS) initCGroup() {
 			logger.Warnf(cGroups[i].warn)
 		}
 	}
+
+	// The memory protection is only available on the unified hierarchy
+	controllers, err := ioutil.ReadFile("/sys/fs/cgroup/cgroup.controllers")
+	if err == nil {
+		s.CGroupMemoryUnified = shared.StringInSlice("memory", strings.Fields(string(controllers)))
+		s.CGroupCPUUnified = shared.StringInSlice("cpu", strings.Fields(string(controllers)))
+	}
 }
 
 func cGroupMissing(name, message string) string {
@@ -57,11 +57,15 @@ type OS struct {
 	CGroupNetPrioController bool
 	CGroupPidsController    bool
 	CGroupSwapAccounting    bool
+	CGroupMemoryUnified     bool
+	CGroupCPUUnified        bool
 	InotifyWatch            InotifyInfo
 	NetnsGetifaddrs         bool
 	UeventInjection         bool
 	VFS3Fscaps              bool
 	Shiftfs                 bool
+	TimeNamespace           bool
+	SeccompListener         bool
 
 	MockMode bool // If true some APIs will be mocked (for testing)
 }
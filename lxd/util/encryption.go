@@ -2,8 +2,10 @@ package util
 
 import (
 	"bytes"
+	"crypto/rand"
 	"encoding/hex"
 	"fmt"
+	"io"
 	"io/ioutil"
 	"path/filepath"
 
@@ -13,6 +15,23 @@ import (
 	"golang.org/x/crypto/scrypt"
 )
 
+// PasswordHash returns the encoded secret of a password, a random salt
+// followed by the scrypt hash of the password, hex encoded.
+func PasswordHash(password string) (string, error) {
+	buf := make([]byte, 32)
+	_, err := io.ReadFull(rand.Reader, buf)
+	if err != nil {
+		return "", err
+	}
+
+	hash, err := scrypt.Key([]byte(password), buf, 1<<14, 8, 1, 64)
+	if err != nil {
+		return "", err
+	}
+
+	return hex.EncodeToString(append(buf, hash...)), nil
+}
+
 // PasswordCheck validates the provided password against the encoded secret
 func PasswordCheck(secret string, password string) error {
 	// No password set
@@ -13,6 +13,6 @@ func LoadModule(module string) error {
 		return nil
 	}
 
-	_, err := shared.RunCommand("modprobe", module)
+	_, err := shared.RunCommand("modprobe", "--", module)
 	return err
 }
@@ -0,0 +1,420 @@
+package main
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/gorilla/mux"
+
+	"github.com/lxc/lxd/lxd/db"
+	"github.com/lxc/lxd/lxd/util"
+	"github.com/lxc/lxd/shared"
+	"github.com/lxc/lxd/shared/api"
+	"github.com/lxc/lxd/shared/version"
+)
+
+var webhooksCmd = Command{
+	name: "webhooks",
+	get:  webhooksGet,
+	post: webhooksPost,
+}
+
+var webhookCmd = Command{
+	name:   "webhooks/{id}",
+	get:    webhookGet,
+	delete: webhookDelete,
+}
+
+var webhookStatusCmd = Command{
+	name: "webhooks/{id}/status",
+	get:  webhookStatusGet,
+}
+
+// How long the list of webhooks is cached for, changes made through another
+// cluster node are picked up after at most that long.
+const webhooksCacheExpiry = 30 * time.Second
+
+var webhookTypes = []string{"lifecycle", "logging", "operation"}
+
+// Highest number of retries of a delivery and longest delay between them
+const webhookRetryMaxLimit = 10
+const webhookRetryMaxDelay = time.Minute
+
+// Number of events waiting for delivery to a webhook past which new ones are
+// dropped
+const webhookQueueSize = 64
+
+type webhookEvent struct {
+	project string
+	event   api.Event
+}
+
+var webhooksQueue = make(chan webhookEvent, 1024)
+
+var webhooksLock sync.Mutex
+var webhooksCache []db.Webhook
+var webhooksCacheTime time.Time
+var webhooksStatus = map[int64]*api.WebhookStatus{}
+
+// Queues of the events to deliver, each webhook having its own goroutine
+// delivering them in turn
+var webhooksQueues = map[int64]chan []byte{}
+
+func webhookToAPI(webhook db.Webhook) api.Webhook {
+	return api.Webhook{
+		ID:       webhook.ID,
+		URL:      webhook.URL,
+		Events:   webhook.Events,
+		RetryMax: webhook.RetryMax,
+	}
+}
+
+func webhookID(r *http.Request) (int64, error) {
+	id, err := strconv.ParseInt(mux.Vars(r)["id"], 10, 64)
+	if err != nil {
+		return -1, fmt.Errorf("Invalid webhook ID")
+	}
+
+	return id, nil
+}
+
+func webhooksGet(d *Daemon, r *http.Request) Response {
+	recursion := util.IsRecursionRequest(r)
+
+	webhooks, err := d.cluster.Webhooks()
+	if err != nil {
+		return SmartError(err)
+	}
+
+	if recursion {
+		result := []api.Webhook{}
+		for _, webhook := range webhooks {
+			result = append(result, webhookToAPI(webhook))
+		}
+
+		return SyncResponse(true, result)
+	}
+
+	result := []string{}
+	for _, webhook := range webhooks {
+		result = append(result, fmt.Sprintf("/%s/webhooks/%d", version.APIVersion, webhook.ID))
+	}
+
+	return SyncResponse(true, result)
+}
+
+func webhooksPost(d *Daemon, r *http.Request) Response {
+	req := api.WebhooksPost{RetryMax: -1}
+	err := shared.ReadToJSON(r.Body, &req)
+	if err != nil {
+		return BadRequest(err)
+	}
+
+	u, err := url.Parse(req.URL)
+	if err != nil || !shared.StringInSlice(u.Scheme, []string{"http", "https"}) || u.Host == "" {
+		return BadRequest(fmt.Errorf("Invalid webhook URL: %s", req.URL))
+	}
+
+	for _, eventType := range req.Events {
+		if !shared.StringInSlice(eventType, webhookTypes) {
+			return BadRequest(fmt.Errorf("Invalid event type: %s", eventType))
+		}
+	}
+
+	if req.RetryMax < 0 {
+		req.RetryMax = 3
+	}
+
+	if req.RetryMax > webhookRetryMaxLimit {
+		return BadRequest(fmt.Errorf("The number of retries can't exceed %d", webhookRetryMaxLimit))
+	}
+
+	id, err := d.cluster.WebhookCreate(db.Webhook{
+		URL:      req.URL,
+		Secret:   req.Secret,
+		Events:   req.Events,
+		RetryMax: req.RetryMax,
+	})
+	if err != nil {
+		return SmartError(err)
+	}
+
+	webhooksInvalidate()
+
+	return SyncResponseLocation(true, nil, fmt.Sprintf("/%s/webhooks/%d", version.APIVersion, id))
+}
+
+func webhookGet(d *Daemon, r *http.Request) Response {
+	id, err := webhookID(r)
+	if err != nil {
+		return BadRequest(err)
+	}
+
+	webhook, err := d.cluster.WebhookGet(id)
+	if err != nil {
+		return SmartError(err)
+	}
+
+	return SyncResponse(true, webhookToAPI(*webhook))
+}
+
+func webhookDelete(d *Daemon, r *http.Request) Response {
+	id, err := webhookID(r)
+	if err != nil {
+		return BadRequest(err)
+	}
+
+	err = d.cluster.WebhookDelete(id)
+	if err != nil {
+		return SmartError(err)
+	}
+
+	webhooksInvalidate()
+
+	webhooksLock.Lock()
+	delete(webhooksStatus, id)
+	queue, ok := webhooksQueues[id]
+	if ok {
+		close(queue)
+		delete(webhooksQueues, id)
+	}
+	webhooksLock.Unlock()
+
+	return EmptySyncResponse
+}
+
+// webhookStatusGet returns the delivery state of a webhook on this node, each
+// node delivering its own events.
+func webhookStatusGet(d *Daemon, r *http.Request) Response {
+	id, err := webhookID(r)
+	if err != nil {
+		return BadRequest(err)
+	}
+
+	_, err = d.cluster.WebhookGet(id)
+	if err != nil {
+		return SmartError(err)
+	}
+
+	var location string
+	err = d.cluster.Transaction(func(tx *db.ClusterTx) error {
+		var err error
+		location, err = tx.NodeName()
+		return err
+	})
+	if err != nil {
+		return SmartError(err)
+	}
+
+	status := api.WebhookStatus{}
+
+	webhooksLock.Lock()
+	if webhooksStatus[id] != nil {
+		status = *webhooksStatus[id]
+	}
+	webhooksLock.Unlock()
+
+	status.Location = location
+
+	return SyncResponse(true, status)
+}
+
+func webhooksInvalidate() {
+	webhooksLock.Lock()
+	webhooksCacheTime = time.Time{}
+	webhooksLock.Unlock()
+}
+
+// webhooksSend queues an event for delivery to the webhooks, events are
+// dropped if the queue is full.
+func webhooksSend(project string, event api.Event) {
+	select {
+	case webhooksQueue <- webhookEvent{project: project, event: event}:
+	default:
+	}
+}
+
+// webhooksDispatch delivers the queued events to the matching webhooks.
+func webhooksDispatch(d *Daemon) {
+	for entry := range webhooksQueue {
+		webhooksLock.Lock()
+		if time.Since(webhooksCacheTime) > webhooksCacheExpiry {
+			webhooks, err := d.cluster.Webhooks()
+			if err == nil {
+				webhooksCache = webhooks
+				webhooksCacheTime = time.Now()
+				webhooksPrune(webhooks)
+			}
+		}
+		webhooks := webhooksCache
+		webhooksLock.Unlock()
+
+		if len(webhooks) == 0 {
+			continue
+		}
+
+		body, err := json.Marshal(entry.event)
+		if err != nil {
+			continue
+		}
+
+		for _, webhook := range webhooks {
+			if len(webhook.Events) > 0 && !shared.StringInSlice(entry.event.Type, webhook.Events) {
+				continue
+			}
+
+			webhookQueue(d, webhook, body)
+		}
+	}
+}
+
+// webhooksPrune stops delivering events to the webhooks which were deleted,
+// webhooksLock must be held.
+func webhooksPrune(webhooks []db.Webhook) {
+	ids := map[int64]bool{}
+	for _, webhook := range webhooks {
+		ids[webhook.ID] = true
+	}
+
+	for id, queue := range webhooksQueues {
+		if !ids[id] {
+			close(queue)
+			delete(webhooksQueues, id)
+		}
+	}
+}
+
+// webhookQueue queues an event for delivery to a webhook, starting the
+// goroutine delivering its events if needed. Events are dropped, and counted
+// as failures, while the queue is full.
+func webhookQueue(d *Daemon, webhook db.Webhook, body []byte) {
+	webhooksLock.Lock()
+	defer webhooksLock.Unlock()
+
+	queue, ok := webhooksQueues[webhook.ID]
+	if !ok {
+		queue = make(chan []byte, webhookQueueSize)
+		webhooksQueues[webhook.ID] = queue
+
+		go func() {
+			for body := range queue {
+				webhookDeliver(d, webhook, body)
+			}
+		}()
+	}
+
+	select {
+	case queue <- body:
+	default:
+		webhookStatusUpdate(webhook.ID, fmt.Errorf("Delivery queue full"))
+	}
+}
+
+// webhookStatusUpdate records the outcome of a delivery, webhooksLock must be
+// held.
+func webhookStatusUpdate(id int64, err error) {
+	status := webhooksStatus[id]
+	if status == nil {
+		status = &api.WebhookStatus{}
+		webhooksStatus[id] = status
+	}
+
+	if err != nil {
+		status.FailureCount++
+		status.LastFailure = time.Now()
+		status.LastError = err.Error()
+		return
+	}
+
+	status.Deliveries++
+	status.LastDelivery = time.Now()
+}
+
+// webhookRetryDelay returns the delay before a retry of a delivery, doubling
+// after every attempt.
+func webhookRetryDelay(attempt int) time.Duration {
+	delay := time.Second
+	for i := 1; i < attempt && delay < webhookRetryMaxDelay; i++ {
+		delay *= 2
+	}
+
+	if delay > webhookRetryMaxDelay {
+		delay = webhookRetryMaxDelay
+	}
+
+	return delay
+}
+
+// webhookSignature returns the HMAC-SHA256 of the body using the webhook
+// secret.
+func webhookSignature(secret string, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write(body)
+	return fmt.Sprintf("sha256=%s", hex.EncodeToString(mac.Sum(nil)))
+}
+
+// webhookDeliver posts an event to a webhook, retrying with an exponential
+// backoff on failure.
+func webhookDeliver(d *Daemon, webhook db.Webhook, body []byte) {
+	client := &http.Client{
+		Timeout:   10 * time.Second,
+		Transport: &http.Transport{Proxy: d.proxy},
+	}
+
+	retryMax := webhook.RetryMax
+	if retryMax > webhookRetryMaxLimit {
+		retryMax = webhookRetryMaxLimit
+	}
+
+	var err error
+	for attempt := 0; attempt <= retryMax; attempt++ {
+		if attempt > 0 {
+			time.Sleep(webhookRetryDelay(attempt))
+		}
+
+		// Failures aren't logged, as the logs would be queued back to the
+		// webhooks as logging events
+		err = webhookPost(client, webhook, body)
+		if err == nil {
+			break
+		}
+	}
+
+	webhooksLock.Lock()
+	webhookStatusUpdate(webhook.ID, err)
+	webhooksLock.Unlock()
+}
+
+func webhookPost(client *http.Client, webhook db.Webhook, body []byte) error {
+	req, err := http.NewRequest("POST", webhook.URL, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", version.UserAgent)
+	if webhook.Secret != "" {
+		req.Header.Set("X-LXD-Signature", webhookSignature(webhook.Secret, body))
+	}
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return fmt.Errorf("Unexpected HTTP status: %s", resp.Status)
+	}
+
+	return nil
+}
@@ -0,0 +1,16 @@
+package main
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestWebhookRetryDelay(t *testing.T) {
+	assert.Equal(t, time.Second, webhookRetryDelay(1))
+	assert.Equal(t, 8*time.Second, webhookRetryDelay(4))
+
+	// Capped rather than overflowing
+	assert.Equal(t, webhookRetryMaxDelay, webhookRetryDelay(100))
+}
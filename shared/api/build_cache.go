@@ -0,0 +1,26 @@
+package api
+
+// BuildCacheStats represents the state of the container build cache
+//
+// API extension: container_build_cache
+type BuildCacheStats struct {
+	Entries   int64 `json:"entries" yaml:"entries"`
+	Size      int64 `json:"size" yaml:"size"`
+	MaxSize   int64 `json:"max_size" yaml:"max_size"`
+	Hits      int64 `json:"hits" yaml:"hits"`
+	Misses    int64 `json:"misses" yaml:"misses"`
+	Evictions int64 `json:"evictions" yaml:"evictions"`
+
+	// API extension: image_pull_through_cache
+	Images ImageCacheStats `json:"images" yaml:"images"`
+}
+
+// ImageCacheStats represents the use of the cached copies of the remote images
+// containers are created from
+//
+// API extension: image_pull_through_cache
+type ImageCacheStats struct {
+	Hits        int64 `json:"hits" yaml:"hits"`
+	ClusterHits int64 `json:"cluster_hits" yaml:"cluster_hits"`
+	Misses      int64 `json:"misses" yaml:"misses"`
+}
@@ -0,0 +1,9 @@
+package api
+
+// CertificatesReload represents the server certificate loaded by a reload
+//
+// API extension: certificates_reload
+type CertificatesReload struct {
+	// Fingerprint of the certificate now served
+	Fingerprint string `json:"fingerprint" yaml:"fingerprint"`
+}
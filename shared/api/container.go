@@ -73,6 +73,21 @@ type Container struct {
 
 	// API extension: clustering
 	Location string `json:"location" yaml:"location"`
+
+	// API extension: container_hide_firmware
+	Security ContainerSecurity `json:"security" yaml:"security"`
+}
+
+// ContainerSecurity represents the security features applied to a LXD
+// container
+//
+// API extension: container_hide_firmware
+type ContainerSecurity struct {
+	// Whether /sys/firmware is hidden from the container
+	HideFirmware bool `json:"hide_firmware" yaml:"hide_firmware"`
+
+	// Whether the parameters of the kernel modules are hidden from the container
+	HideModuleParams bool `json:"hide_module_params" yaml:"hide_module_params"`
 }
 
 // ContainerFull is a combination of Container, ContainerState and CotnainerSnapshot
@@ -138,4 +153,10 @@ type ContainerSource struct {
 
 	// API extension: container_copy_project
 	Project string `json:"project,omitempty" yaml:"project,omitempty"`
+
+	// API extension: container_copy_mode
+	CopyMode string `json:"copy_mode,omitempty" yaml:"copy_mode,omitempty"`
+
+	// API extension: container_build_cache
+	BuildSteps []string `json:"build_steps,omitempty" yaml:"build_steps,omitempty"`
 }
@@ -9,6 +9,9 @@ type ContainerBackupsPost struct {
 	ExpiryDate       time.Time `json:"expiry" yaml:"expiry"`
 	ContainerOnly    bool      `json:"container_only" yaml:"container_only"`
 	OptimizedStorage bool      `json:"optimized_storage" yaml:"optimized_storage"`
+
+	// API extension: backup_xattrs
+	Xattrs bool `json:"xattrs" yaml:"xattrs"`
 }
 
 // ContainerBackup represents a LXD container backup
@@ -19,6 +22,9 @@ type ContainerBackup struct {
 	ExpiryDate       time.Time `json:"expiry_date" yaml:"expiry_date"`
 	ContainerOnly    bool      `json:"container_only" yaml:"container_only"`
 	OptimizedStorage bool      `json:"optimized_storage" yaml:"optimized_storage"`
+
+	// API extension: backup_xattrs
+	Xattrs bool `json:"xattrs" yaml:"xattrs"`
 }
 
 // ContainerBackupPost represents the fields available for the renaming of a
@@ -0,0 +1,35 @@
+package api
+
+// ContainerBandwidth represents the network traffic of a LXD container
+//
+// API extension: container_bandwidth
+type ContainerBandwidth struct {
+	// Day of the month on which the monthly counters reset (UTC)
+	ResetOn int `json:"reset_on" yaml:"reset_on"`
+
+	// Traffic of each day, oldest first
+	Daily []ContainerBandwidthDay `json:"daily" yaml:"daily"`
+
+	// Traffic of each month, oldest first, the last one being the current
+	// month
+	Monthly []ContainerBandwidthMonth `json:"monthly" yaml:"monthly"`
+}
+
+// ContainerBandwidthDay represents the network traffic of a LXD container over a day
+//
+// API extension: container_bandwidth
+type ContainerBandwidthDay struct {
+	Date    string `json:"date" yaml:"date"`
+	RxBytes int64  `json:"rx_bytes" yaml:"rx_bytes"`
+	TxBytes int64  `json:"tx_bytes" yaml:"tx_bytes"`
+}
+
+// ContainerBandwidthMonth represents the network traffic of a LXD container over a month
+//
+// API extension: container_bandwidth
+type ContainerBandwidthMonth struct {
+	Start   string `json:"start" yaml:"start"`
+	End     string `json:"end" yaml:"end"`
+	RxBytes int64  `json:"rx_bytes" yaml:"rx_bytes"`
+	TxBytes int64  `json:"tx_bytes" yaml:"tx_bytes"`
+}
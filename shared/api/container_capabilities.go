@@ -0,0 +1,31 @@
+package api
+
+// ContainerCapabilities represents the capabilities held by the processes of a LXD container
+//
+// API extension: container_capabilities_audit
+type ContainerCapabilities struct {
+	// Capabilities the container configuration allows
+	Granted []string `json:"granted" yaml:"granted"`
+
+	// Union of the effective capabilities of all the processes
+	Effective []string `json:"effective" yaml:"effective"`
+
+	// Capabilities held by some processes but not granted by the configuration
+	Anomalies []string `json:"anomalies" yaml:"anomalies"`
+
+	Processes []ContainerCapabilitiesProcess `json:"processes" yaml:"processes"`
+}
+
+// ContainerCapabilitiesProcess represents the capability sets of a process of a LXD container
+//
+// API extension: container_capabilities_audit
+type ContainerCapabilitiesProcess struct {
+	PID         int64    `json:"pid" yaml:"pid"`
+	Name        string   `json:"name" yaml:"name"`
+	Inheritable []string `json:"inheritable" yaml:"inheritable"`
+	Permitted   []string `json:"permitted" yaml:"permitted"`
+	Effective   []string `json:"effective" yaml:"effective"`
+	Bounding    []string `json:"bounding" yaml:"bounding"`
+	Ambient     []string `json:"ambient" yaml:"ambient"`
+	Anomalies   []string `json:"anomalies" yaml:"anomalies"`
+}
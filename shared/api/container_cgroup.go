@@ -0,0 +1,20 @@
+package api
+
+// ContainerCgroupEvent represents a change of a cgroup counter or state of a LXD container
+//
+// API extension: container_cgroup_trace
+type ContainerCgroupEvent struct {
+	// Cgroup subsystem (cpu, memory, freezer or cgroup)
+	Subsystem string `json:"subsystem" yaml:"subsystem"`
+
+	// Event type (like throttled_periods, oom_kill or frozen)
+	Type string `json:"type" yaml:"type"`
+
+	// New value of the counter or state
+	Value int64 `json:"value" yaml:"value"`
+
+	Container string `json:"container" yaml:"container"`
+
+	// Time of the event in nanoseconds since the epoch
+	Timestamp int64 `json:"timestamp" yaml:"timestamp"`
+}
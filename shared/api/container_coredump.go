@@ -0,0 +1,18 @@
+package api
+
+import (
+	"time"
+)
+
+// ContainerCoredump represents a core dump of a process of a container
+//
+// API extension: container_coredumps
+type ContainerCoredump struct {
+	ID        string    `json:"id" yaml:"id"`
+	PID       int64     `json:"pid" yaml:"pid"`
+	HostPID   int64     `json:"host_pid" yaml:"host_pid"`
+	Signal    int       `json:"signal" yaml:"signal"`
+	Command   string    `json:"command" yaml:"command"`
+	Timestamp time.Time `json:"timestamp" yaml:"timestamp"`
+	Size      int64     `json:"size" yaml:"size"`
+}
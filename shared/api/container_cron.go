@@ -0,0 +1,39 @@
+package api
+
+import (
+	"time"
+)
+
+// ContainerCronJobsPost represents the fields of a new scheduled command
+//
+// API extension: container_cron
+type ContainerCronJobsPost struct {
+	Command       []string `json:"command" yaml:"command"`
+	Schedule      string   `json:"schedule" yaml:"schedule"`
+	Timeout       int      `json:"timeout" yaml:"timeout"`
+	CaptureOutput bool     `json:"capture_output" yaml:"capture_output"`
+}
+
+// ContainerCronJob represents a command run on a schedule inside a container
+//
+// API extension: container_cron
+type ContainerCronJob struct {
+	ID            int64     `json:"id" yaml:"id"`
+	Command       []string  `json:"command" yaml:"command"`
+	Schedule      string    `json:"schedule" yaml:"schedule"`
+	Timeout       int       `json:"timeout" yaml:"timeout"`
+	CaptureOutput bool      `json:"capture_output" yaml:"capture_output"`
+	CreatedAt     time.Time `json:"created_at" yaml:"created_at"`
+}
+
+// ContainerCronJobRun represents the result of a run of a scheduled command
+//
+// API extension: container_cron
+type ContainerCronJobRun struct {
+	StartedAt  time.Time `json:"started_at" yaml:"started_at"`
+	FinishedAt time.Time `json:"finished_at" yaml:"finished_at"`
+	ExitCode   int       `json:"exit_code" yaml:"exit_code"`
+	Stdout     string    `json:"stdout" yaml:"stdout"`
+	Stderr     string    `json:"stderr" yaml:"stderr"`
+	Error      string    `json:"error" yaml:"error"`
+}
@@ -0,0 +1,33 @@
+package api
+
+// ContainerDrift represents the differences between the configuration of a
+// LXD container and the one of its profiles
+//
+// API extension: container_drift
+type ContainerDrift struct {
+	// Keys set on the container and in none of its profiles
+	Added map[string]string `json:"added" yaml:"added"`
+
+	// Keys of the profiles unset on the container (with their profile value)
+	Removed map[string]string `json:"removed" yaml:"removed"`
+
+	// Keys of the profiles overridden with a different value on the container
+	Changed map[string]ContainerDriftValue `json:"changed" yaml:"changed"`
+}
+
+// ContainerDriftValue represents the two values of a key overridden by a LXD
+// container
+//
+// API extension: container_drift
+type ContainerDriftValue struct {
+	Profile   string `json:"profile" yaml:"profile"`
+	Container string `json:"container" yaml:"container"`
+}
+
+// ContainerDriftPost represents a request to revert the drift of a LXD
+// container
+//
+// API extension: container_drift
+type ContainerDriftPost struct {
+	Remediate bool `json:"remediate" yaml:"remediate"`
+}
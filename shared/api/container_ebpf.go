@@ -0,0 +1,17 @@
+package api
+
+import (
+	"time"
+)
+
+// ContainerEbpfProgram represents an eBPF program loaded into a container
+//
+// API extension: container_ebpf
+type ContainerEbpfProgram struct {
+	ID       uint32    `json:"id" yaml:"id"`
+	Name     string    `json:"name" yaml:"name"`
+	Hook     string    `json:"hook" yaml:"hook"`
+	Target   string    `json:"target" yaml:"target"`
+	Tag      string    `json:"tag" yaml:"tag"`
+	LoadedAt time.Time `json:"loaded_at" yaml:"loaded_at"`
+}
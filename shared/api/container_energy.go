@@ -0,0 +1,23 @@
+package api
+
+// ContainerEnergy represents the estimated energy consumption of a LXD container
+//
+// API extension: container_energy
+type ContainerEnergy struct {
+	// Energy consumed in Joules
+	Energy float64 `json:"energy" yaml:"energy"`
+
+	// Power in Watts (averaged over the last 5 seconds)
+	Power float64 `json:"power" yaml:"power"`
+
+	// Per RAPL domain breakdown (package, core, uncore and dram)
+	Domains map[string]ContainerEnergyDomain `json:"domains" yaml:"domains"`
+}
+
+// ContainerEnergyDomain represents the energy consumption of a LXD container for a RAPL domain
+//
+// API extension: container_energy
+type ContainerEnergyDomain struct {
+	Energy float64 `json:"energy" yaml:"energy"`
+	Power  float64 `json:"power" yaml:"power"`
+}
@@ -18,4 +18,31 @@ type ContainerExecPost struct {
 
 	// API extension: container_exec_recording
 	RecordOutput bool `json:"record-output" yaml:"record-output"`
+
+	// API extension: container_exec_limits
+	Timeout        int   `json:"timeout" yaml:"timeout"`
+	MaxOutputBytes int64 `json:"max_output_bytes" yaml:"max_output_bytes"`
+
+	// API extension: container_exec_secrets
+	SecretRefs []string `json:"secret_refs" yaml:"secret_refs"`
+
+	// API extension: container_exec_dry_run
+	DryRun bool `json:"dry_run" yaml:"dry_run"`
+
+	// API extension: container_exec_stdin
+	Mode string `json:"mode" yaml:"mode"`
+}
+
+// ContainerExecDryRun represents what a LXD container exec request would run
+//
+// API extension: container_exec_dry_run
+type ContainerExecDryRun struct {
+	Command     []string          `json:"command" yaml:"command"`
+	Environment map[string]string `json:"environment" yaml:"environment"`
+	Secrets     []string          `json:"secrets" yaml:"secrets"`
+	Cwd         string            `json:"cwd" yaml:"cwd"`
+	UID         int64             `json:"uid" yaml:"uid"`
+	GID         int64             `json:"gid" yaml:"gid"`
+	HostUID     int64             `json:"host_uid" yaml:"host_uid"`
+	HostGID     int64             `json:"host_gid" yaml:"host_gid"`
 }
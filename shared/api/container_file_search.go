@@ -0,0 +1,18 @@
+package api
+
+import (
+	"time"
+)
+
+// ContainerFileSearchResult represents a file of a LXD container matching a search
+//
+// API extension: container_file_search
+type ContainerFileSearchResult struct {
+	Path string `json:"path" yaml:"path"`
+
+	// One of file, directory, symlink or other
+	Type string `json:"type" yaml:"type"`
+
+	Size       int64     `json:"size" yaml:"size"`
+	ModifiedAt time.Time `json:"modified_at" yaml:"modified_at"`
+}
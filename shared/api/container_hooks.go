@@ -0,0 +1,19 @@
+package api
+
+import (
+	"time"
+)
+
+// ContainerHookEvent represents a lifecycle event of a LXD container posted to
+// its hooks
+//
+// API extension: container_hooks
+type ContainerHookEvent struct {
+	Container string    `json:"container" yaml:"container"`
+	Project   string    `json:"project" yaml:"project"`
+	Event     string    `json:"event" yaml:"event"`
+	Timestamp time.Time `json:"timestamp" yaml:"timestamp"`
+
+	// Details of the event (e.g. snapshot name)
+	Metadata map[string]interface{} `json:"metadata" yaml:"metadata"`
+}
@@ -0,0 +1,34 @@
+package api
+
+// ContainerMounts represents the mount table of a LXD container
+//
+// API extension: container_mounts_audit
+type ContainerMounts struct {
+	Mounts []ContainerMount `json:"mounts" yaml:"mounts"`
+
+	// Mounts which are unusual for a container
+	Anomalies []ContainerMountAnomaly `json:"anomalies" yaml:"anomalies"`
+}
+
+// ContainerMount represents a mount point of a LXD container
+//
+// API extension: container_mounts_audit
+type ContainerMount struct {
+	Device     string `json:"device" yaml:"device"`
+	Path       string `json:"path" yaml:"path"`
+	FSType     string `json:"fstype" yaml:"fstype"`
+	Options    string `json:"options" yaml:"options"`
+	MajorMinor string `json:"major_minor" yaml:"major_minor"`
+
+	// Path within the filesystem mounted at the mount point, other than /
+	// for bind mounts
+	Root string `json:"root" yaml:"root"`
+}
+
+// ContainerMountAnomaly represents a flagged mount point of a LXD container
+//
+// API extension: container_mounts_audit
+type ContainerMountAnomaly struct {
+	Path   string `json:"path" yaml:"path"`
+	Reason string `json:"reason" yaml:"reason"`
+}
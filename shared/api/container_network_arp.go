@@ -0,0 +1,22 @@
+package api
+
+// ContainerNetworkARPEntriesPost represents the fields of a new static
+// ARP/NDP entry of a container
+//
+// API extension: container_network_arp
+type ContainerNetworkARPEntriesPost struct {
+	IP        string `json:"ip" yaml:"ip"`
+	MAC       string `json:"mac" yaml:"mac"`
+	Interface string `json:"interface" yaml:"interface"`
+}
+
+// ContainerNetworkARPEntry represents an entry of the ARP (IPv4) or NDP
+// (IPv6) table of a container
+//
+// API extension: container_network_arp
+type ContainerNetworkARPEntry struct {
+	IP        string `json:"ip" yaml:"ip"`
+	MAC       string `json:"mac" yaml:"mac"`
+	Interface string `json:"interface" yaml:"interface"`
+	State     string `json:"state" yaml:"state"`
+}
@@ -0,0 +1,26 @@
+package api
+
+// ContainerNetworkRoutesPost represents the fields of a new route of a
+// container
+//
+// API extension: container_network_routes
+type ContainerNetworkRoutesPost struct {
+	Destination string `json:"destination" yaml:"destination"`
+	Gateway     string `json:"gateway" yaml:"gateway"`
+	Interface   string `json:"interface" yaml:"interface"`
+	Metric      int    `json:"metric" yaml:"metric"`
+}
+
+// ContainerNetworkRoute represents a route of the main routing table of a
+// container
+//
+// API extension: container_network_routes
+type ContainerNetworkRoute struct {
+	ID          string `json:"id" yaml:"id"`
+	Destination string `json:"destination" yaml:"destination"`
+	Gateway     string `json:"gateway" yaml:"gateway"`
+	Interface   string `json:"interface" yaml:"interface"`
+	Metric      int    `json:"metric" yaml:"metric"`
+	Protocol    string `json:"protocol" yaml:"protocol"`
+	Scope       string `json:"scope" yaml:"scope"`
+}
@@ -0,0 +1,27 @@
+package api
+
+// ContainerPerfPageFaults represents the major page faults of the processes of a LXD container
+//
+// API extension: container_perf_pagefaults
+type ContainerPerfPageFaults struct {
+	// Sampling duration in seconds
+	Duration float64 `json:"duration" yaml:"duration"`
+
+	// Major page faults of all the sampled processes
+	Total uint64 `json:"total" yaml:"total"`
+
+	// Processes with the most major page faults, most faulting first
+	Processes []ContainerPerfPageFaultsProcess `json:"processes" yaml:"processes"`
+}
+
+// ContainerPerfPageFaultsProcess represents the major page faults of a process of a LXD container
+//
+// API extension: container_perf_pagefaults
+type ContainerPerfPageFaultsProcess struct {
+	PID     int64  `json:"pid" yaml:"pid"`
+	Command string `json:"command" yaml:"command"`
+	Faults  uint64 `json:"faults" yaml:"faults"`
+
+	// Major page faults per second
+	Rate float64 `json:"rate" yaml:"rate"`
+}
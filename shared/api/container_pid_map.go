@@ -0,0 +1,14 @@
+package api
+
+// ContainerPidMapEntry represents the pids of a process of a LXD container
+//
+// API extension: container_pid_map
+type ContainerPidMapEntry struct {
+	// Pid as seen from within the container
+	ContainerPID int64 `json:"container_pid" yaml:"container_pid"`
+
+	// Pid as seen from the host
+	HostPID int64 `json:"host_pid" yaml:"host_pid"`
+
+	Command string `json:"command" yaml:"command"`
+}
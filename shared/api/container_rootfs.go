@@ -0,0 +1,13 @@
+package api
+
+// ContainerRootfsSync represents the outcome of syncing a tarball into the rootfs of a LXD container
+//
+// API extension: container_rootfs_sync
+type ContainerRootfsSync struct {
+	Added     int64 `json:"added" yaml:"added"`
+	Modified  int64 `json:"modified" yaml:"modified"`
+	Unchanged int64 `json:"unchanged" yaml:"unchanged"`
+
+	// Name of the snapshot taken before syncing, if any
+	Snapshot string `json:"snapshot" yaml:"snapshot"`
+}
@@ -0,0 +1,12 @@
+package api
+
+// ContainerSeccompProfile represents a syscall whitelist generated from the syscalls logged by a LXD container
+//
+// API extension: container_seccomp_log_only
+type ContainerSeccompProfile struct {
+	// Sorted list of the syscalls the container used
+	Syscalls []string `json:"syscalls" yaml:"syscalls"`
+
+	// Value suitable for security.syscalls.whitelist
+	Whitelist string `json:"whitelist" yaml:"whitelist"`
+}
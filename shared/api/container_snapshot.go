@@ -11,6 +11,13 @@ type ContainerSnapshotsPost struct {
 
 	// API extension: snapshot_expiry_creation
 	ExpiresAt *time.Time `json:"expires_at" yaml:"expires_at"`
+
+	// API extension: container_build_cache
+	Cache     bool   `json:"cache" yaml:"cache"`
+	CacheStep string `json:"cache_step" yaml:"cache_step"`
+
+	// API extension: snapshot_retention
+	Keep bool `json:"keep" yaml:"keep"`
 }
 
 // ContainerSnapshotPost represents the fields required to rename/move a LXD container snapshot
@@ -32,6 +39,9 @@ type ContainerSnapshotPut struct {
 	Ephemeral    bool                         `json:"ephemeral" yaml:"ephemeral"`
 	Profiles     []string                     `json:"profiles" yaml:"profiles"`
 	ExpiresAt    time.Time                    `json:"expires_at" yaml:"expires_at"`
+
+	// API extension: snapshot_retention
+	Keep bool `json:"keep" yaml:"keep"`
 }
 
 // ContainerSnapshot represents a LXD conainer snapshot
@@ -20,6 +20,25 @@ type ContainerState struct {
 
 	// API extension: container_cpu_time
 	CPU ContainerStateCPU `json:"cpu" yaml:"cpu"`
+
+	// API extension: container_time_namespace
+	TimeNamespace *ContainerStateTimeNamespace `json:"time_namespace" yaml:"time_namespace"`
+
+	// API extension: container_ephemeral_state
+	Ephemeral bool `json:"ephemeral" yaml:"ephemeral"`
+
+	// API extension: proxy_connection_count
+	Proxy map[string]ContainerStateProxy `json:"proxy" yaml:"proxy"`
+
+	// API extension: container_open_files
+	OpenFiles int64 `json:"open_files" yaml:"open_files"`
+}
+
+// ContainerStateProxy represents the state of a proxy device of a LXD container
+//
+// API extension: proxy_connection_count
+type ContainerStateProxy struct {
+	Connections int64 `json:"connections" yaml:"connections"`
 }
 
 // ContainerStateDisk represents the disk information section of a LXD container's state
@@ -27,6 +46,16 @@ type ContainerStateDisk struct {
 	Usage int64 `json:"usage" yaml:"usage"`
 }
 
+// ContainerDiskQuota represents the disk usage of a LXD container whose root
+// disk is nearly full, as returned along with 507 errors
+//
+// API extension: container_quota_check
+type ContainerDiskQuota struct {
+	Usage    int64 `json:"usage" yaml:"usage"`
+	Quota    int64 `json:"quota" yaml:"quota"`
+	Headroom int64 `json:"headroom" yaml:"headroom"`
+}
+
 // ContainerStateCPU represents the cpu information section of a LXD container's state
 //
 // API extension: container_cpu_time
@@ -34,12 +63,52 @@ type ContainerStateCPU struct {
 	Usage int64 `json:"usage" yaml:"usage"`
 }
 
+// ContainerStateTimeNamespace represents the time namespace section of a LXD container's state
+//
+// API extension: container_time_namespace
+type ContainerStateTimeNamespace struct {
+	OffsetSeconds int64 `json:"offset_seconds" yaml:"offset_seconds"`
+}
+
 // ContainerStateMemory represents the memory information section of a LXD container's state
 type ContainerStateMemory struct {
 	Usage         int64 `json:"usage" yaml:"usage"`
 	UsagePeak     int64 `json:"usage_peak" yaml:"usage_peak"`
 	SwapUsage     int64 `json:"swap_usage" yaml:"swap_usage"`
 	SwapUsagePeak int64 `json:"swap_usage_peak" yaml:"swap_usage_peak"`
+
+	// API extension: container_memory_balloon
+	Limit    int64 `json:"limit" yaml:"limit"`
+	LimitMin int64 `json:"limit_min" yaml:"limit_min"`
+	LimitMax int64 `json:"limit_max" yaml:"limit_max"`
+
+	// API extension: container_memory_stats
+	Stats *ContainerStateMemoryStats `json:"memory_stats" yaml:"memory_stats"`
+}
+
+// ContainerStateMemoryStats represents the detailed memory usage of a LXD container
+//
+// API extension: container_memory_stats
+type ContainerStateMemoryStats struct {
+	Usage int64 `json:"usage" yaml:"usage"`
+
+	// Anonymous memory, cache and memory mapped files in bytes
+	RSS        int64 `json:"rss" yaml:"rss"`
+	Cache      int64 `json:"cache" yaml:"cache"`
+	MappedFile int64 `json:"mapped_file" yaml:"mapped_file"`
+
+	PageFaults      int64 `json:"pgfault" yaml:"pgfault"`
+	MajorPageFaults int64 `json:"pgmajfault" yaml:"pgmajfault"`
+
+	// LRU lists in bytes
+	InactiveAnon int64 `json:"inactive_anon" yaml:"inactive_anon"`
+	ActiveAnon   int64 `json:"active_anon" yaml:"active_anon"`
+	InactiveFile int64 `json:"inactive_file" yaml:"inactive_file"`
+	ActiveFile   int64 `json:"active_file" yaml:"active_file"`
+	Unevictable  int64 `json:"unevictable" yaml:"unevictable"`
+
+	SlabReclaimable   int64 `json:"slab_reclaimable" yaml:"slab_reclaimable"`
+	SlabUnreclaimable int64 `json:"slab_unreclaimable" yaml:"slab_unreclaimable"`
 }
 
 // ContainerStateNetwork represents the network information section of a LXD container's state
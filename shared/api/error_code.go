@@ -0,0 +1,96 @@
+package api
+
+// ErrorCode represents a machine-readable LXD error, more specific than the
+// HTTP status code of the error responses
+//
+// Codes are never renumbered, new ones only get added to the ranges of their
+// category.
+//
+// API extension: api_error_codes
+type ErrorCode int
+
+// LXD error codes
+const (
+	// Unknown errors and responses from servers without error codes
+	ErrUnknown ErrorCode = 0
+
+	// Generic errors (1000-1999)
+	ErrInvalidRequest      ErrorCode = 1000
+	ErrForbidden           ErrorCode = 1001
+	ErrNotFound            ErrorCode = 1002
+	ErrAlreadyExists       ErrorCode = 1003
+	ErrPreconditionFailed  ErrorCode = 1004
+	ErrInternal            ErrorCode = 1005
+	ErrNotImplemented      ErrorCode = 1006
+	ErrUnavailable         ErrorCode = 1007
+	ErrInsufficientStorage ErrorCode = 1008
+	ErrInvalidConfig       ErrorCode = 1009
+
+	// API extension: api_idempotency_keys
+	ErrIdempotencyKeyInUse    ErrorCode = 1010
+	ErrIdempotencyKeyMismatch ErrorCode = 1011
+
+	// Containers (2000-2999)
+	ErrContainerNotFound   ErrorCode = 2000
+	ErrContainerRunning    ErrorCode = 2001
+	ErrContainerNotRunning ErrorCode = 2002
+
+	// Images (3000-3999)
+	ErrImageNotFound ErrorCode = 3000
+
+	// Storage (4000-4999)
+	ErrStoragePoolNotFound ErrorCode = 4000
+
+	// Networks (5000-5999)
+	ErrNetworkNotFound ErrorCode = 5000
+
+	// Profiles and projects (6000-6999)
+	ErrProfileNotFound ErrorCode = 6000
+	ErrProjectNotFound ErrorCode = 6001
+)
+
+// String returns a suitable string representation for the error code
+func (c ErrorCode) String() string {
+	name, ok := map[ErrorCode]string{
+		ErrUnknown:                "Unknown",
+		ErrInvalidRequest:         "Invalid request",
+		ErrForbidden:              "Forbidden",
+		ErrNotFound:               "Not found",
+		ErrAlreadyExists:          "Already exists",
+		ErrPreconditionFailed:     "Precondition failed",
+		ErrInternal:               "Internal error",
+		ErrNotImplemented:         "Not implemented",
+		ErrUnavailable:            "Unavailable",
+		ErrInsufficientStorage:    "Insufficient storage",
+		ErrInvalidConfig:          "Invalid configuration",
+		ErrIdempotencyKeyInUse:    "Idempotency key in use",
+		ErrIdempotencyKeyMismatch: "Idempotency key mismatch",
+		ErrContainerNotFound:      "Container not found",
+		ErrContainerRunning:       "Container running",
+		ErrContainerNotRunning:    "Container not running",
+		ErrImageNotFound:          "Image not found",
+		ErrStoragePoolNotFound:    "Storage pool not found",
+		ErrNetworkNotFound:        "Network not found",
+		ErrProfileNotFound:        "Profile not found",
+		ErrProjectNotFound:        "Project not found",
+	}[c]
+	if !ok {
+		return "Unknown"
+	}
+
+	return name
+}
+
+// ResponseError represents an error returned by the LXD API
+//
+// API extension: api_error_codes
+type ResponseError struct {
+	StatusCode int
+	Code       ErrorCode
+	Message    string
+}
+
+// Error returns the message of the error
+func (e ResponseError) Error() string {
+	return e.Message
+}
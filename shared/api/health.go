@@ -0,0 +1,20 @@
+package api
+
+// Health represents the state of the LXD daemon
+//
+// API extension: storage_max_concurrent_ops
+type Health struct {
+	StorageOps HealthStorageOps `json:"storage_ops" yaml:"storage_ops"`
+}
+
+// HealthStorageOps represents the storage heavy operations running on the LXD
+// daemon
+//
+// API extension: storage_max_concurrent_ops
+type HealthStorageOps struct {
+	Running int64 `json:"running" yaml:"running"`
+	Waiting int64 `json:"waiting" yaml:"waiting"`
+
+	// Maximum number of concurrent operations, 0 for no limit
+	Limit int64 `json:"limit" yaml:"limit"`
+}
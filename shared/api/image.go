@@ -119,3 +119,14 @@ type ImageMetadataTemplate struct {
 	Template   string            `json:"template" yaml:"template"`
 	Properties map[string]string `json:"properties" yaml:"properties"`
 }
+
+// ImageReplica represents the replication state of an image on a cluster member
+//
+// API extension: images_replication
+type ImageReplica struct {
+	Node      string    `json:"node" yaml:"node"`
+	Status    string    `json:"status" yaml:"status"`
+	Progress  int       `json:"progress" yaml:"progress"`
+	Error     string    `json:"error" yaml:"error"`
+	UpdatedAt time.Time `json:"updated_at" yaml:"updated_at"`
+}
@@ -0,0 +1,27 @@
+package api
+
+// ImageSignature represents the sigstore signature of a LXD image
+//
+// API extension: images_signature
+type ImageSignature struct {
+	ImageSignaturePut `yaml:",inline"`
+
+	// Whether the signature passes the signature policy of the server
+	Verified bool `json:"verified" yaml:"verified"`
+
+	// Policy check failing the verification, if any
+	Error string `json:"error" yaml:"error"`
+}
+
+// ImageSignaturePut represents the modifiable fields of the signature of a LXD
+// image
+//
+// API extension: images_signature
+type ImageSignaturePut struct {
+	// JSON sigstore bundle signing the image fingerprint
+	Bundle string `json:"bundle" yaml:"bundle"`
+
+	// Whether containers can be created from the image without a valid
+	// signature
+	Exempt bool `json:"exempt" yaml:"exempt"`
+}
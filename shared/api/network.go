@@ -62,6 +62,68 @@ type NetworkLease struct {
 	Location string `json:"location" yaml:"location"`
 }
 
+// NetworkForwardsPost represents the fields of a new port forward
+//
+// API extension: network_forwards
+type NetworkForwardsPost struct {
+	ListenAddress string `json:"listen_address" yaml:"listen_address"`
+	TargetAddress string `json:"target_address" yaml:"target_address"`
+	Protocol      string `json:"protocol" yaml:"protocol"`
+	Description   string `json:"description" yaml:"description"`
+}
+
+// NetworkForward represents a port forward to a container of the network
+//
+// API extension: network_forwards
+type NetworkForward struct {
+	NetworkForwardsPost `yaml:",inline"`
+
+	ID       int64  `json:"id" yaml:"id"`
+	Location string `json:"location" yaml:"location"`
+}
+
+// NetworkLoadBalancerBackend represents a container port traffic is balanced to
+//
+// API extension: network_load_balancers
+type NetworkLoadBalancerBackend struct {
+	Container string `json:"container" yaml:"container"`
+	Project   string `json:"project" yaml:"project"`
+	Port      int    `json:"port" yaml:"port"`
+}
+
+// NetworkLoadBalancersPost represents the fields of a new load balancer
+//
+// API extension: network_load_balancers
+type NetworkLoadBalancersPost struct {
+	ListenAddress       string                       `json:"listen_address" yaml:"listen_address"`
+	Protocol            string                       `json:"protocol" yaml:"protocol"`
+	Algorithm           string                       `json:"algorithm" yaml:"algorithm"`
+	Backends            []NetworkLoadBalancerBackend `json:"backends" yaml:"backends"`
+	HealthCheckInterval int                          `json:"health_check_interval" yaml:"health_check_interval"`
+	Description         string                       `json:"description" yaml:"description"`
+}
+
+// NetworkLoadBalancer represents a load balancer of the network
+//
+// API extension: network_load_balancers
+type NetworkLoadBalancer struct {
+	NetworkLoadBalancersPost `yaml:",inline"`
+
+	ID       int64                              `json:"id" yaml:"id"`
+	Location string                             `json:"location" yaml:"location"`
+	Status   []NetworkLoadBalancerBackendStatus `json:"status" yaml:"status"`
+}
+
+// NetworkLoadBalancerBackendStatus represents the health of a load balancer backend
+//
+// API extension: network_load_balancers
+type NetworkLoadBalancerBackendStatus struct {
+	NetworkLoadBalancerBackend `yaml:",inline"`
+
+	Address string `json:"address" yaml:"address"`
+	Healthy bool   `json:"healthy" yaml:"healthy"`
+}
+
 // NetworkState represents the network state
 type NetworkState struct {
 	Addresses []NetworkStateAddress `json:"addresses" yaml:"addresses"`
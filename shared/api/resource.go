@@ -81,6 +81,9 @@ type ResourcesMemory struct {
 type ResourcesStoragePool struct {
 	Space  ResourcesStoragePoolSpace  `json:"space,omitempty" yaml:"space,omitempty"`
 	Inodes ResourcesStoragePoolInodes `json:"inodes,omitempty" yaml:"inodes,omitempty"`
+
+	// API extension: storage_lvm_thinpool_metadata
+	Thinpool *ResourcesStoragePoolThinpool `json:"thinpool,omitempty" yaml:"thinpool,omitempty"`
 }
 
 // ResourcesStoragePoolSpace represents the space available to a given storage pool
@@ -96,3 +99,56 @@ type ResourcesStoragePoolInodes struct {
 	Used  uint64 `json:"used" yaml:"used"`
 	Total uint64 `json:"total" yaml:"total"`
 }
+
+// ResourcesStoragePoolThinpool represents the data and metadata usage of the
+// thin pool backing a storage pool
+// API extension: storage_lvm_thinpool_metadata
+type ResourcesStoragePoolThinpool struct {
+	DataUsed      uint64 `json:"data_used" yaml:"data_used"`
+	DataTotal     uint64 `json:"data_total" yaml:"data_total"`
+	MetadataUsed  uint64 `json:"metadata_used" yaml:"metadata_used"`
+	MetadataTotal uint64 `json:"metadata_total" yaml:"metadata_total"`
+}
+
+// ResourcesInfiniband represents the infiniband virtual functions of the system
+// API extension: infiniband_vf_pool
+type ResourcesInfiniband struct {
+	VFs       []ResourcesInfinibandVF `json:"vfs" yaml:"vfs"`
+	Total     uint64                  `json:"total" yaml:"total"`
+	Allocated uint64                  `json:"allocated" yaml:"allocated"`
+}
+
+// ResourcesInfinibandVF represents an infiniband virtual function and its allocation
+// API extension: infiniband_vf_pool
+type ResourcesInfinibandVF struct {
+	Parent     string `json:"parent" yaml:"parent"`
+	Index      int    `json:"index" yaml:"index"`
+	PCIAddress string `json:"pci_address" yaml:"pci_address"`
+	Name       string `json:"name" yaml:"name"`
+	Allocated  bool   `json:"allocated" yaml:"allocated"`
+	Owner      string `json:"owner" yaml:"owner"`
+}
+
+// ResourcesPCI represents the IOMMU groups of the PCI devices of the system
+// API extension: container_pci_vfio
+type ResourcesPCI struct {
+	IOMMUGroups []ResourcesPCIIOMMUGroup `json:"iommu_groups" yaml:"iommu_groups"`
+	Total       uint64                   `json:"total" yaml:"total"`
+}
+
+// ResourcesPCIIOMMUGroup represents an IOMMU group and its PCI devices
+// API extension: container_pci_vfio
+type ResourcesPCIIOMMUGroup struct {
+	ID      uint64               `json:"id" yaml:"id"`
+	Devices []ResourcesPCIDevice `json:"devices" yaml:"devices"`
+}
+
+// ResourcesPCIDevice represents a PCI device
+// API extension: container_pci_vfio
+type ResourcesPCIDevice struct {
+	PCIAddress string `json:"pci_address" yaml:"pci_address"`
+	Driver     string `json:"driver" yaml:"driver"`
+	Class      string `json:"class" yaml:"class"`
+	VendorID   string `json:"vendor_id" yaml:"vendor_id"`
+	ProductID  string `json:"product_id" yaml:"product_id"`
+}
@@ -23,6 +23,10 @@ type StoragePool struct {
 	// API extension: clustering
 	Status    string   `json:"status" yaml:"status"`
 	Locations []string `json:"locations" yaml:"locations"`
+
+	// Deduplication ratio of the pool, 0 if not deduplicated
+	// API extension: storage_zfs_dedup
+	DedupRatio float64 `json:"dedup_ratio,omitempty" yaml:"dedup_ratio,omitempty"`
 }
 
 // StoragePoolPut represents the modifiable fields of a LXD storage pool.
@@ -40,3 +44,28 @@ type StoragePoolPut struct {
 func (storagePool *StoragePool) Writable() StoragePoolPut {
 	return storagePool.StoragePoolPut
 }
+
+// StoragePoolOvercommit represents the space allocated to the volumes of a LXD storage pool
+//
+// API extension: storage_overcommit
+type StoragePoolOvercommit struct {
+	// Capacity of the pool in bytes
+	Physical uint64 `json:"physical" yaml:"physical"`
+
+	// Sum of the quotas of the volumes in bytes
+	Allocated uint64 `json:"allocated" yaml:"allocated"`
+
+	// Allocated space divided by the capacity
+	Ratio float64 `json:"ratio" yaml:"ratio"`
+
+	Volumes []StoragePoolOvercommitVolume `json:"volumes" yaml:"volumes"`
+}
+
+// StoragePoolOvercommitVolume represents the space allocated to a LXD storage volume
+//
+// API extension: storage_overcommit
+type StoragePoolOvercommitVolume struct {
+	Name      string `json:"name" yaml:"name"`
+	Type      string `json:"type" yaml:"type"`
+	Allocated uint64 `json:"allocated" yaml:"allocated"`
+}
@@ -32,6 +32,14 @@ type StorageVolumePost struct {
 	VolumeOnly bool `json:"volume_only" yaml:"volume_only"`
 }
 
+// StorageVolumeResizePost represents the fields required to resize a LXD
+// storage pool volume
+//
+// API extension: storage_volume_live_resize
+type StorageVolumeResizePost struct {
+	Size string `json:"size" yaml:"size"`
+}
+
 // StorageVolumePostTarget represents the migration target host and operation
 //
 // API extension: storage_api_remote_volume_handling
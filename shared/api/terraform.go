@@ -0,0 +1,35 @@
+package api
+
+// TerraformState represents a Terraform state snapshot (format version 4)
+//
+// API extension: terraform_state
+type TerraformState struct {
+	Version          int                    `json:"version" yaml:"version"`
+	TerraformVersion string                 `json:"terraform_version" yaml:"terraform_version"`
+	Serial           int64                  `json:"serial" yaml:"serial"`
+	Lineage          string                 `json:"lineage" yaml:"lineage"`
+	Outputs          map[string]interface{} `json:"outputs" yaml:"outputs"`
+	Resources        []TerraformResource    `json:"resources" yaml:"resources"`
+}
+
+// TerraformResource represents a resource in a Terraform state
+//
+// API extension: terraform_state
+type TerraformResource struct {
+	Mode      string                      `json:"mode" yaml:"mode"`
+	Type      string                      `json:"type" yaml:"type"`
+	Name      string                      `json:"name" yaml:"name"`
+	Provider  string                      `json:"provider" yaml:"provider"`
+	Instances []TerraformResourceInstance `json:"instances" yaml:"instances"`
+}
+
+// TerraformResourceInstance represents an instance of a resource in a
+// Terraform state
+//
+// API extension: terraform_state
+type TerraformResourceInstance struct {
+	SchemaVersion int                    `json:"schema_version" yaml:"schema_version"`
+	Attributes    map[string]interface{} `json:"attributes" yaml:"attributes"`
+	Private       string                 `json:"private,omitempty" yaml:"private,omitempty"`
+	Dependencies  []string               `json:"dependencies,omitempty" yaml:"dependencies,omitempty"`
+}
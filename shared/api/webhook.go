@@ -0,0 +1,37 @@
+package api
+
+import (
+	"time"
+)
+
+// WebhooksPost represents the fields of a new LXD webhook
+//
+// API extension: webhooks
+type WebhooksPost struct {
+	URL      string   `json:"url" yaml:"url"`
+	Secret   string   `json:"secret" yaml:"secret"`
+	Events   []string `json:"events" yaml:"events"`
+	RetryMax int      `json:"retry_max" yaml:"retry_max"`
+}
+
+// Webhook represents a LXD webhook
+//
+// API extension: webhooks
+type Webhook struct {
+	ID       int64    `json:"id" yaml:"id"`
+	URL      string   `json:"url" yaml:"url"`
+	Events   []string `json:"events" yaml:"events"`
+	RetryMax int      `json:"retry_max" yaml:"retry_max"`
+}
+
+// WebhookStatus represents the delivery state of a LXD webhook on a node
+//
+// API extension: webhooks
+type WebhookStatus struct {
+	LastDelivery time.Time `json:"last_delivery" yaml:"last_delivery"`
+	LastFailure  time.Time `json:"last_failure" yaml:"last_failure"`
+	LastError    string    `json:"last_error" yaml:"last_error"`
+	Deliveries   int64     `json:"deliveries" yaml:"deliveries"`
+	FailureCount int64     `json:"failure_count" yaml:"failure_count"`
+	Location     string    `json:"location" yaml:"location"`
+}
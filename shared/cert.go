@@ -87,6 +87,15 @@ type CertInfo struct {
 	ca      *x509.Certificate
 }
 
+// NewCertInfo returns a CertInfo object for the given key pair and optional CA
+// certificate.
+func NewCertInfo(keypair tls.Certificate, ca *x509.Certificate) *CertInfo {
+	return &CertInfo{
+		keypair: keypair,
+		ca:      ca,
+	}
+}
+
 // KeyPair returns the public/private key pair.
 func (c *CertInfo) KeyPair() tls.Certificate {
 	return c.keypair
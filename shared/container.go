@@ -2,6 +2,8 @@ package shared
 
 import (
 	"fmt"
+	"net/url"
+	"path"
 	"regexp"
 	"strconv"
 	"strings"
@@ -9,6 +11,7 @@ import (
 
 	"github.com/pkg/errors"
 	"gopkg.in/robfig/cron.v2"
+	"gopkg.in/yaml.v2"
 )
 
 type ContainerAction string
@@ -60,6 +63,19 @@ func IsUint32(value string) error {
 	return nil
 }
 
+func IsUint64(value string) error {
+	if value == "" {
+		return nil
+	}
+
+	_, err := strconv.ParseUint(value, 10, 64)
+	if err != nil {
+		return fmt.Errorf("Invalid value for uint64: %s: %v", value, err)
+	}
+
+	return nil
+}
+
 func IsPriority(value string) error {
 	if value == "" {
 		return nil
@@ -101,10 +117,270 @@ func IsOneOf(value string, valid []string) error {
 	return nil
 }
 
+func IsHTTPSURL(value string) error {
+	if value == "" {
+		return nil
+	}
+
+	u, err := url.Parse(value)
+	if err != nil {
+		return err
+	}
+
+	if u.Scheme != "https" || u.Host == "" {
+		return fmt.Errorf("Invalid URL '%s', must be an HTTPS URL", value)
+	}
+
+	return nil
+}
+
 func IsAny(value string) error {
 	return nil
 }
 
+// Linux capabilities, as named in capabilities(7) without their CAP_ prefix
+var capabilityNames = []string{
+	"chown", "dac_override", "dac_read_search", "fowner", "fsetid", "kill",
+	"setgid", "setuid", "setpcap", "linux_immutable", "net_bind_service",
+	"net_broadcast", "net_admin", "net_raw", "ipc_lock", "ipc_owner",
+	"sys_module", "sys_rawio", "sys_chroot", "sys_ptrace", "sys_pacct",
+	"sys_admin", "sys_boot", "sys_nice", "sys_resource", "sys_time",
+	"sys_tty_config", "mknod", "lease", "audit_write", "audit_control",
+	"setfcap", "mac_override", "mac_admin", "syslog", "wake_alarm",
+	"block_suspend", "audit_read", "perfmon", "bpf", "checkpoint_restore",
+}
+
+// ParseCapabilities parses a comma-separated list of Linux capabilities (like
+// "CAP_NET_ADMIN,CAP_SYS_PTRACE") and returns their names the way LXC expects
+// them (like "net_admin").
+func ParseCapabilities(value string) ([]string, error) {
+	caps := []string{}
+	for _, entry := range strings.Split(value, ",") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+
+		name := strings.TrimPrefix(strings.ToLower(entry), "cap_")
+		if !StringInSlice(name, capabilityNames) {
+			return nil, fmt.Errorf("Invalid capability: %s", entry)
+		}
+
+		if !StringInSlice(name, caps) {
+			caps = append(caps, name)
+		}
+	}
+
+	return caps, nil
+}
+
+// DecodeCapabilities returns the names (like "CAP_NET_ADMIN") of the
+// capabilities set in a capability bitmask, in bit order.
+func DecodeCapabilities(mask uint64) []string {
+	caps := []string{}
+	for bit := uint(0); bit < 64; bit++ {
+		if mask&(1<<bit) == 0 {
+			continue
+		}
+
+		if int(bit) < len(capabilityNames) {
+			caps = append(caps, "CAP_"+strings.ToUpper(capabilityNames[bit]))
+		} else {
+			caps = append(caps, fmt.Sprintf("CAP_%d", bit))
+		}
+	}
+
+	return caps
+}
+
+// EncodeCapabilities returns the bitmask of a list of capabilities, using the
+// names returned by ParseCapabilities.
+func EncodeCapabilities(names []string) uint64 {
+	mask := uint64(0)
+	for bit, name := range capabilityNames {
+		if StringInSlice(name, names) {
+			mask |= 1 << uint(bit)
+		}
+	}
+
+	return mask
+}
+
+// Process resource limits, as named in getrlimit(2) without their RLIMIT_
+// prefix, in resource number order
+var ulimitTypes = []string{
+	"cpu", "fsize", "data", "stack", "core", "rss", "nproc", "nofile",
+	"memlock", "as", "locks", "sigpending", "msgqueue", "nice", "rtprio",
+	"rttime",
+}
+
+// ContainerUlimit is a process resource limit of limits.ulimits
+type ContainerUlimit struct {
+	Type string `yaml:"type" json:"type"`
+	Soft string `yaml:"soft" json:"soft"`
+	Hard string `yaml:"hard" json:"hard"`
+
+	// Resource number, as passed to setrlimit(2)
+	Resource int `yaml:"-" json:"resource"`
+}
+
+func isUlimitValue(value string) error {
+	if value == "unlimited" {
+		return nil
+	}
+
+	_, err := strconv.ParseUint(value, 10, 64)
+	if err != nil {
+		return fmt.Errorf("Invalid limit '%s', must be a number or 'unlimited'", value)
+	}
+
+	return nil
+}
+
+// ParseUlimits parses the YAML list of limits.ulimits (like
+// "[{type: nofile, soft: 1024, hard: 2048}]"). A missing hard limit defaults
+// to the soft one.
+func ParseUlimits(value string) ([]ContainerUlimit, error) {
+	ulimits := []ContainerUlimit{}
+	err := yaml.Unmarshal([]byte(value), &ulimits)
+	if err != nil {
+		return nil, errors.Wrap(err, "Invalid limits.ulimits")
+	}
+
+	seen := map[string]bool{}
+	for i, ulimit := range ulimits {
+		ulimit.Type = strings.TrimPrefix(strings.ToLower(ulimit.Type), "rlimit_")
+		ulimit.Resource = -1
+		for resource, name := range ulimitTypes {
+			if name == ulimit.Type {
+				ulimit.Resource = resource
+			}
+		}
+
+		if ulimit.Resource < 0 {
+			return nil, fmt.Errorf("Invalid limit type '%s'", ulimit.Type)
+		}
+
+		if seen[ulimit.Type] {
+			return nil, fmt.Errorf("Limit '%s' is set more than once", ulimit.Type)
+		}
+		seen[ulimit.Type] = true
+
+		if ulimit.Soft == "" {
+			return nil, fmt.Errorf("Missing soft limit of '%s'", ulimit.Type)
+		}
+
+		if ulimit.Hard == "" {
+			ulimit.Hard = ulimit.Soft
+		}
+
+		for _, limit := range []string{ulimit.Soft, ulimit.Hard} {
+			err := isUlimitValue(limit)
+			if err != nil {
+				return nil, err
+			}
+		}
+
+		if ulimit.Soft == "unlimited" && ulimit.Hard != "unlimited" {
+			return nil, fmt.Errorf("The soft limit of '%s' can't be higher than its hard limit", ulimit.Type)
+		}
+
+		if ulimit.Soft != "unlimited" && ulimit.Hard != "unlimited" {
+			soft, _ := strconv.ParseUint(ulimit.Soft, 10, 64)
+			hard, _ := strconv.ParseUint(ulimit.Hard, 10, 64)
+			if soft > hard {
+				return nil, fmt.Errorf("The soft limit of '%s' can't be higher than its hard limit", ulimit.Type)
+			}
+		}
+
+		ulimits[i] = ulimit
+	}
+
+	return ulimits, nil
+}
+
+// Weights of the priority.cpu and priority.io classes
+var priorityClassWeights = map[string]int64{
+	"low":      10,
+	"medium":   100,
+	"high":     500,
+	"critical": 1000,
+}
+
+// ParsePriorityWeight returns the cgroup weight (between 1 and 10000) of a
+// priority class (low, medium, high or critical) or custom weight.
+func ParsePriorityWeight(value string) (int64, error) {
+	weight, ok := priorityClassWeights[value]
+	if ok {
+		return weight, nil
+	}
+
+	weight, err := strconv.ParseInt(value, 10, 64)
+	if err != nil || weight < 1 || weight > 10000 {
+		return -1, fmt.Errorf("Invalid priority '%s', must be low, medium, high, critical or a weight between 1 and 10000", value)
+	}
+
+	return weight, nil
+}
+
+func IsPriorityWeight(value string) error {
+	if value == "" {
+		return nil
+	}
+
+	_, err := ParsePriorityWeight(value)
+	return err
+}
+
+// IsMemoryLimit validates a memory limit, either a percentage of the host's
+// memory or a size in bytes.
+func IsMemoryLimit(value string) error {
+	if value == "" {
+		return nil
+	}
+
+	if strings.HasSuffix(value, "%") {
+		_, err := strconv.ParseInt(strings.TrimSuffix(value, "%"), 10, 64)
+		if err != nil {
+			return err
+		}
+
+		return nil
+	}
+
+	_, err := ParseByteSizeString(value)
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
+
+// IsOOMScoreAdj validates an OOM killer score adjustment, between -1000 and
+// 1000.
+func IsOOMScoreAdj(value string) error {
+	if value == "" {
+		return nil
+	}
+
+	valueInt, err := strconv.ParseInt(value, 10, 64)
+	if err != nil {
+		return fmt.Errorf("Invalid value for an integer: %s", value)
+	}
+
+	if valueInt < -1000 || valueInt > 1000 {
+		return fmt.Errorf("Invalid OOM score adjustment '%s'. Must be between -1000 and 1000", value)
+	}
+
+	return nil
+}
+
+// IsCapabilityList validates a comma-separated list of Linux capabilities.
+func IsCapabilityList(value string) error {
+	_, err := ParseCapabilities(value)
+	return err
+}
+
 // IsRootDiskDevice returns true if the given device representation is
 // configured as root disk for a container. It typically get passed a specific
 // entry of api.Container.Devices.
@@ -143,11 +419,75 @@ func GetRootDiskDevice(devices map[string]map[string]string) (string, map[string
 // to an appropriate checker function, which validates whether or not a
 // given value is syntactically legal.
 var KnownContainerConfigKeys = map[string]func(value string) error{
+	"bandwidth.alert_bytes": IsUint64,
+	"bandwidth.quota_bytes": IsUint64,
+	"bandwidth.reset_on": func(value string) error {
+		if value == "" {
+			return nil
+		}
+
+		day, err := strconv.Atoi(value)
+		if err != nil || day < 1 || day > 31 {
+			return fmt.Errorf("Invalid day of the month: %s", value)
+		}
+
+		return nil
+	},
+
 	"boot.autostart":             IsBool,
 	"boot.autostart.delay":       IsInt64,
 	"boot.autostart.priority":    IsInt64,
 	"boot.stop.priority":         IsInt64,
 	"boot.host_shutdown_timeout": IsInt64,
+	"boot.host_hooks.timeout":    IsInt64,
+
+	"boot.depends":               IsAny,
+	"boot.depends.max_wait":      IsUint32,
+	"boot.health_check.command":  IsAny,
+	"boot.health_check.interval": IsUint32,
+	"boot.health_check.timeout":  IsUint32,
+
+	"console.clipboard_passthrough": IsBool,
+
+	"coredumps.retention": IsUint32,
+	"coredumps.size_limit": func(value string) error {
+		if value == "" {
+			return nil
+		}
+
+		_, err := ParseByteSizeString(value)
+		return err
+	},
+
+	"hooks.start_url":    IsHTTPSURL,
+	"hooks.stop_url":     IsHTTPSURL,
+	"hooks.snapshot_url": IsHTTPSURL,
+	"hooks.secret":       IsAny,
+
+	"hooks.pre_snapshot_command":  IsAny,
+	"hooks.post_snapshot_command": IsAny,
+	"hooks.snapshot_timeout":      IsUint32,
+
+	"restart.policy": func(value string) error {
+		return IsOneOf(value, []string{"on-failure", "always", "never"})
+	},
+	"restart.max_retries": IsUint32,
+	"restart.interval": func(value string) error {
+		if value == "" {
+			return nil
+		}
+
+		interval, err := time.ParseDuration(value)
+		if err != nil {
+			return err
+		}
+
+		if interval <= 0 {
+			return fmt.Errorf("Invalid restart interval, must be positive: %s", value)
+		}
+
+		return nil
+	},
 
 	"limits.cpu": func(value string) error {
 		if value == "" {
@@ -205,34 +545,69 @@ var KnownContainerConfigKeys = map[string]func(value string) error{
 
 		return nil
 	},
+	"limits.cpu.nodes": func(value string) error {
+		if value == "" {
+			return nil
+		}
+
+		match, _ := regexp.MatchString("^[0-9]+(-[0-9]+)?(,[0-9]+(-[0-9]+)?)*$", value)
+		if !match {
+			return fmt.Errorf("Invalid NUMA node list syntax")
+		}
+
+		return nil
+	},
+	"limits.cpu.period": func(value string) error {
+		if value == "" {
+			return nil
+		}
+
+		period, err := time.ParseDuration(value)
+		if err != nil {
+			return err
+		}
+
+		// Range accepted by the CFS scheduler
+		if period < time.Millisecond || period > time.Second {
+			return fmt.Errorf("The CPU period must be between 1ms and 1s")
+		}
+
+		return nil
+	},
 	"limits.cpu.priority": IsPriority,
 
 	"limits.disk.priority": IsPriority,
 
-	"limits.memory": func(value string) error {
+	"limits.files_warning": func(value string) error {
 		if value == "" {
 			return nil
 		}
 
 		if strings.HasSuffix(value, "%") {
-			_, err := strconv.ParseInt(strings.TrimSuffix(value, "%"), 10, 64)
+			percent, err := strconv.ParseInt(strings.TrimSuffix(value, "%"), 10, 64)
 			if err != nil {
 				return err
 			}
 
-			return nil
-		}
+			if percent < 0 || percent > 100 {
+				return fmt.Errorf("Invalid percentage: %s", value)
+			}
 
-		_, err := ParseByteSizeString(value)
-		if err != nil {
-			return err
+			return nil
 		}
 
-		return nil
+		return IsUint64(value)
 	},
+
+	"limits.memory": IsMemoryLimit,
 	"limits.memory.enforce": func(value string) error {
 		return IsOneOf(value, []string{"soft", "hard"})
 	},
+	"limits.memory.min":           IsMemoryLimit,
+	"limits.memory.low":           IsMemoryLimit,
+	"limits.memory.guarantee":     IsMemoryLimit,
+	"limits.memory.max":           IsMemoryLimit,
+	"limits.memory.balloon.step":  IsMemoryLimit,
 	"limits.memory.swap":          IsBool,
 	"limits.memory.swap.priority": IsPriority,
 
@@ -240,22 +615,47 @@ var KnownContainerConfigKeys = map[string]func(value string) error{
 
 	"limits.processes": IsInt64,
 
+	"limits.ulimits": func(value string) error {
+		_, err := ParseUlimits(value)
+		return err
+	},
+
 	"linux.kernel_modules": IsAny,
+	"linux.thp_mode": func(value string) error {
+		return IsOneOf(value, []string{"always", "madvise", "never"})
+	},
 
 	"migration.incremental.memory":            IsBool,
 	"migration.incremental.memory.iterations": IsUint32,
 	"migration.incremental.memory.goal":       IsUint32,
 
+	"priority.cpu": IsPriorityWeight,
+	"priority.io":  IsPriorityWeight,
+
 	"nvidia.runtime":             IsBool,
 	"nvidia.driver.capabilities": IsAny,
 	"nvidia.require.cuda":        IsAny,
 	"nvidia.require.driver":      IsAny,
 
+	"oom_score_adj": IsOOMScoreAdj,
+
+	"security.console_auth": func(value string) error {
+		return IsOneOf(value, []string{"password"})
+	},
+	"security.console_auth.password": IsAny,
+
 	"security.nesting":       IsBool,
 	"security.privileged":    IsBool,
+	"security.proc_filter":   IsBool,
 	"security.devlxd":        IsBool,
 	"security.devlxd.images": IsBool,
 
+	"security.hide_firmware":      IsBool,
+	"security.hide_module_params": IsBool,
+
+	"security.capabilities.add":  IsCapabilityList,
+	"security.capabilities.drop": IsCapabilityList,
+
 	"security.protection.delete": IsBool,
 	"security.protection.shift":  IsBool,
 
@@ -263,6 +663,46 @@ var KnownContainerConfigKeys = map[string]func(value string) error{
 	"security.idmap.isolated": IsBool,
 	"security.idmap.size":     IsUint32,
 
+	"security.ipc": func(value string) error {
+		return IsOneOf(value, []string{"isolated", "shared", "host"})
+	},
+	"security.ipc.shared_with": IsAny,
+
+	"security.time_namespace":                IsBool,
+	"security.time_namespace.offset_seconds": IsInt64,
+
+	"security.seccomp.log_only": IsBool,
+	"security.seccomp.path_rules": func(value string) error {
+		for _, rule := range strings.Split(value, ",") {
+			rule = strings.TrimSpace(rule)
+			if rule == "" {
+				continue
+			}
+
+			fields := strings.SplitN(rule, "=", 2)
+			if len(fields) != 2 || !strings.HasPrefix(fields[0], "/") || !strings.HasPrefix(fields[1], "/") {
+				return fmt.Errorf("Invalid path rule '%s', expecting <source>=<target> absolute paths", rule)
+			}
+
+			if path.Clean(fields[0]) == "/" {
+				return fmt.Errorf("Invalid path rule '%s', the root directory can't be remapped", rule)
+			}
+		}
+
+		return nil
+	},
+
+	"security.secrets.whitelist": func(value string) error {
+		for _, pattern := range strings.Split(value, ",") {
+			_, err := path.Match(strings.TrimSpace(pattern), "")
+			if err != nil {
+				return fmt.Errorf("Invalid secret pattern '%s'", pattern)
+			}
+		}
+
+		return nil
+	},
+
 	"security.syscalls.blacklist_default": IsBool,
 	"security.syscalls.blacklist_compat":  IsBool,
 	"security.syscalls.blacklist":         IsAny,
@@ -291,6 +731,7 @@ var KnownContainerConfigKeys = map[string]func(value string) error{
 		_, err := GetSnapshotExpiry(time.Time{}, value)
 		return err
 	},
+	"snapshots.max_count": IsUint32,
 
 	// Caller is responsible for full validation of any raw.* value
 	"raw.apparmor": IsAny,
@@ -300,12 +741,24 @@ var KnownContainerConfigKeys = map[string]func(value string) error{
 
 	"volatile.apply_template":   IsAny,
 	"volatile.base_image":       IsAny,
+	"volatile.base_container":   IsAny,
 	"volatile.last_state.idmap": IsAny,
 	"volatile.last_state.power": IsAny,
 	"volatile.idmap.base":       IsAny,
 	"volatile.idmap.current":    IsAny,
 	"volatile.idmap.next":       IsAny,
 	"volatile.apply_quota":      IsAny,
+
+	// Hash of the current build layer and number of build steps taken
+	// from the build cache at creation
+	"volatile.build.hash":         IsAny,
+	"volatile.build.cached_steps": IsAny,
+
+	// Whether a snapshot is exempt from the snapshot retention policy
+	"volatile.snapshot.keep": IsBool,
+
+	// Whether the deletion of a stopped ephemeral container failed
+	"volatile.ephemeral.delete_failed": IsBool,
 }
 
 // ConfigKeyChecker returns a function that will check whether or not
@@ -331,6 +784,14 @@ func ConfigKeyChecker(key string) (func(value string) error, error) {
 		if strings.HasSuffix(key, ".host_name") {
 			return IsAny, nil
 		}
+
+		if strings.HasSuffix(key, ".vf") || strings.HasSuffix(key, ".vf_name") {
+			return IsAny, nil
+		}
+
+		if strings.HasSuffix(key, ".drivers") || strings.HasSuffix(key, ".iommu_group") {
+			return IsAny, nil
+		}
 	}
 
 	if strings.HasPrefix(key, "environment.") {
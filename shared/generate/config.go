@@ -0,0 +1,43 @@
+package main
+
+import (
+	"fmt"
+
+	"github.com/spf13/cobra"
+
+	"github.com/lxc/lxd/shared/generate/config"
+)
+
+// Return a new config command.
+func newConfig() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "config [sub-command]",
+		Short: "Configuration-related code generation.",
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return fmt.Errorf("Not implemented")
+		},
+	}
+
+	cmd.AddCommand(newConfigSchema())
+
+	return cmd
+}
+
+func newConfigSchema() *cobra.Command {
+	var source string
+	var target string
+
+	cmd := &cobra.Command{
+		Use:   "schema",
+		Short: "Generate the JSON schema of the configuration keys.",
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return config.Schema(source, target)
+		},
+	}
+
+	flags := cmd.Flags()
+	flags.StringVarP(&source, "source", "s", "config_schema.go", "source file declaring the schema structs")
+	flags.StringVarP(&target, "target", "t", "-", "target source file to generate")
+
+	return cmd
+}
@@ -0,0 +1,338 @@
+package config
+
+import (
+	"encoding/json"
+	"fmt"
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"io/ioutil"
+	"os"
+	"reflect"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/pkg/errors"
+
+	"github.com/lxc/lxd/shared"
+)
+
+// key is the description of a single configuration key.
+type key struct {
+	Name        string
+	Type        string
+	Format      string
+	Default     string
+	Values      []string
+	Pattern     string
+	Required    bool
+	Live        bool
+	Description string
+}
+
+// Schema parses the configuration schema structs declared in the given
+// source file and writes a Go source file declaring the matching JSON
+// schema document as the configSchema constant.
+func Schema(source string, target string) error {
+	container, devices, err := parseSchemaStructs(source)
+	if err != nil {
+		return err
+	}
+
+	document := map[string]interface{}{
+		"$schema": "http://json-schema.org/draft-07/schema#",
+		"title":   "LXD container and device configuration",
+		"type":    "object",
+		"properties": map[string]interface{}{
+			"config": schemaObject(container),
+			"devices": map[string]interface{}{
+				"type":                 "object",
+				"additionalProperties": map[string]interface{}{"oneOf": schemaDevices(devices)},
+			},
+		},
+	}
+
+	data, err := json.MarshalIndent(document, "", "  ")
+	if err != nil {
+		return errors.Wrap(err, "Encode JSON schema")
+	}
+
+	content := fmt.Sprintf(`package %s
+
+// The code below was generated by %s - DO NOT EDIT!
+
+const configSchema = %s
+`, os.Getenv("GOPACKAGE"), os.Args[0], strconv.Quote(string(data)))
+
+	if target == "-" {
+		_, err = os.Stdout.Write([]byte(content))
+	} else {
+		err = ioutil.WriteFile(target, []byte(content), 0644)
+	}
+
+	if err != nil {
+		return errors.Wrapf(err, "Write target source file '%s'", target)
+	}
+
+	return nil
+}
+
+// parseSchemaStructs returns the container keys and the keys of each device
+// type found in the given source file.
+func parseSchemaStructs(source string) ([]key, map[string][]key, error) {
+	fset := token.NewFileSet()
+	file, err := parser.ParseFile(fset, source, nil, 0)
+	if err != nil {
+		return nil, nil, errors.Wrapf(err, "Parse Go source file '%s'", source)
+	}
+
+	container := []key{}
+	devices := map[string][]key{}
+
+	for _, decl := range file.Decls {
+		gen, ok := decl.(*ast.GenDecl)
+		if !ok || gen.Tok != token.TYPE {
+			continue
+		}
+
+		for _, spec := range gen.Specs {
+			typeSpec := spec.(*ast.TypeSpec)
+			str, ok := typeSpec.Type.(*ast.StructType)
+			if !ok {
+				continue
+			}
+
+			scope := ""
+			device := ""
+			keys := []key{}
+
+			for _, field := range str.Fields.List {
+				if field.Tag == nil {
+					continue
+				}
+
+				value, err := strconv.Unquote(field.Tag.Value)
+				if err != nil {
+					return nil, nil, errors.Wrapf(err, "Invalid tag in %s", typeSpec.Name.Name)
+				}
+				tag := reflect.StructTag(value)
+
+				// The blank field holds the struct's scope
+				if len(field.Names) == 1 && field.Names[0].Name == "_" {
+					scope = tag.Get("schema")
+					device = tag.Get("device")
+					continue
+				}
+
+				k, err := parseSchemaField(field, tag)
+				if err != nil {
+					return nil, nil, errors.Wrapf(err, "Invalid field in %s", typeSpec.Name.Name)
+				}
+
+				keys = append(keys, k)
+			}
+
+			switch scope {
+			case "container":
+				container = append(container, keys...)
+			case "device":
+				if device == "" {
+					return nil, nil, fmt.Errorf("No device type for %s", typeSpec.Name.Name)
+				}
+
+				devices[device] = append(devices[device], keys...)
+			}
+		}
+	}
+
+	return container, devices, nil
+}
+
+func parseSchemaField(field *ast.Field, tag reflect.StructTag) (key, error) {
+	k := key{
+		Name:        tag.Get("key"),
+		Default:     tag.Get("default"),
+		Pattern:     tag.Get("pattern"),
+		Required:    tag.Get("required") == "yes",
+		Live:        shared.StringInSlice(tag.Get("live"), []string{"yes", "n/a"}),
+		Description: tag.Get("description"),
+	}
+
+	if k.Name == "" {
+		return k, fmt.Errorf("Missing key tag")
+	}
+
+	if tag.Get("values") != "" {
+		k.Values = strings.Split(tag.Get("values"), ",")
+	}
+
+	ident, ok := field.Type.(*ast.Ident)
+	if !ok {
+		return k, fmt.Errorf("Unsupported type for %s", k.Name)
+	}
+
+	switch ident.Name {
+	case "bool":
+		k.Type = "boolean"
+	case "int64":
+		k.Type = "integer"
+	case "string":
+		k.Type = "string"
+	default:
+		return k, fmt.Errorf("Unsupported type for %s: %s", k.Name, ident.Name)
+	}
+
+	// Custom formats (e.g. "size") are plain strings in the schema
+	k.Format = tag.Get("type")
+
+	if k.Pattern != "" {
+		_, err := regexp.Compile(k.Pattern)
+		if err != nil {
+			return k, errors.Wrapf(err, "Invalid pattern for %s", k.Name)
+		}
+	}
+
+	return k, nil
+}
+
+// schemaProperty renders the JSON schema of a single key.
+func schemaProperty(k key) map[string]interface{} {
+	property := map[string]interface{}{
+		"type":              k.Type,
+		"description":       k.Description,
+		"x-lxd-live-update": k.Live,
+	}
+
+	if k.Format != "" {
+		property["format"] = k.Format
+	}
+
+	if k.Pattern != "" {
+		property["pattern"] = k.Pattern
+	}
+
+	if len(k.Values) > 0 {
+		property["enum"] = k.Values
+	}
+
+	if k.Default != "" {
+		// Only use defaults matching the key's type, keep the others
+		// (e.g. "unlimited" for a size) as documentation.
+		var value interface{} = k.Default
+		var err error
+		switch k.Type {
+		case "boolean":
+			value, err = strconv.ParseBool(k.Default)
+		case "integer":
+			value, err = strconv.ParseInt(k.Default, 10, 64)
+		case "string":
+			err = schemaStringValid(k, k.Default)
+		}
+
+		if err == nil {
+			property["default"] = value
+		} else {
+			property["x-lxd-default"] = k.Default
+		}
+	}
+
+	return property
+}
+
+// Values of the keys of the "size" format
+var schemaSizeRegexp = regexp.MustCompile(`^(?i)[0-9]+(\.[0-9]+)? ?([kmgtpe]i?)?b?$`)
+
+// schemaStringValid checks that a string value fits the enum, pattern and
+// format of a key.
+func schemaStringValid(k key, value string) error {
+	if len(k.Values) > 0 && !shared.StringInSlice(value, k.Values) {
+		return fmt.Errorf("'%s' isn't one of %v", value, k.Values)
+	}
+
+	if k.Pattern != "" {
+		match, err := regexp.MatchString(k.Pattern, value)
+		if err != nil {
+			return err
+		}
+
+		if !match {
+			return fmt.Errorf("'%s' doesn't match '%s'", value, k.Pattern)
+		}
+	}
+
+	if k.Format == "size" && !schemaSizeRegexp.MatchString(value) {
+		return fmt.Errorf("'%s' isn't a size", value)
+	}
+
+	return nil
+}
+
+// schemaObject renders the JSON schema of a set of keys, namespaces (keys
+// ending with "*") are turned into pattern properties.
+func schemaObject(keys []key) map[string]interface{} {
+	properties := map[string]interface{}{}
+	patterns := map[string]interface{}{}
+	required := []string{}
+
+	for _, k := range keys {
+		if strings.HasSuffix(k.Name, "*") {
+			pattern := "^" + regexp.QuoteMeta(strings.TrimSuffix(k.Name, "*"))
+			patterns[pattern] = schemaProperty(k)
+			continue
+		}
+
+		properties[k.Name] = schemaProperty(k)
+		if k.Required {
+			required = append(required, k.Name)
+		}
+	}
+
+	object := map[string]interface{}{
+		"type":                 "object",
+		"properties":           properties,
+		"additionalProperties": false,
+	}
+
+	if len(patterns) > 0 {
+		object["patternProperties"] = patterns
+	}
+
+	if len(required) > 0 {
+		sort.Strings(required)
+		object["required"] = required
+	}
+
+	return object
+}
+
+// schemaDevices renders the JSON schema of each device type.
+func schemaDevices(devices map[string][]key) []interface{} {
+	types := []string{}
+	for name := range devices {
+		types = append(types, name)
+	}
+	sort.Strings(types)
+
+	schemas := []interface{}{}
+	for _, name := range types {
+		object := schemaObject(devices[name])
+		object["title"] = name
+		object["properties"].(map[string]interface{})["type"] = map[string]interface{}{
+			"type":        "string",
+			"enum":        []string{name},
+			"description": "The device type",
+		}
+
+		required := []string{"type"}
+		if object["required"] != nil {
+			required = append(required, object["required"].([]string)...)
+		}
+		object["required"] = required
+
+		schemas = append(schemas, object)
+	}
+
+	return schemas
+}
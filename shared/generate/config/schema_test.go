@@ -0,0 +1,56 @@
+package config
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const testSchemaSource = `package main
+
+type containerConfigSchema struct {
+	_ struct{} ` + "`schema:\"container\"`" + `
+
+	BootAutostart bool   ` + "`key:\"boot.autostart\" default:\"false\" live:\"n/a\" description:\"Autostart\"`" + `
+	User          string ` + "`key:\"user.*\" live:\"yes\"`" + `
+}
+
+type deviceDiskConfigSchema struct {
+	_ struct{} ` + "`schema:\"device\" device:\"disk\"`" + `
+
+	Path string ` + "`key:\"path\" required:\"yes\"`" + `
+	Size string ` + "`key:\"size\" type:\"size\" default:\"unlimited\"`" + `
+}
+`
+
+func TestParseSchemaStructs(t *testing.T) {
+	dir, err := ioutil.TempDir("", "lxd-generate-")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	source := filepath.Join(dir, "config_schema.go")
+	require.NoError(t, ioutil.WriteFile(source, []byte(testSchemaSource), 0644))
+
+	container, devices, err := parseSchemaStructs(source)
+	require.NoError(t, err)
+
+	require.Len(t, container, 2)
+	assert.Equal(t, key{Name: "boot.autostart", Type: "boolean", Default: "false", Live: true, Description: "Autostart"}, container[0])
+	assert.Equal(t, key{Name: "user.*", Type: "string", Live: true}, container[1])
+
+	require.Len(t, devices["disk"], 2)
+	assert.True(t, devices["disk"][0].Required)
+	assert.Equal(t, "size", devices["disk"][1].Format)
+
+	object := schemaObject(container)
+	assert.Contains(t, object["properties"], "boot.autostart")
+	assert.Contains(t, object["patternProperties"], "^user\\.")
+
+	property := schemaProperty(devices["disk"][1])
+	assert.Equal(t, "unlimited", property["x-lxd-default"])
+	assert.Nil(t, property["default"])
+}
@@ -18,6 +18,7 @@ used in LXD's source code.`,
 		},
 	}
 	cmd.AddCommand(newDb())
+	cmd.AddCommand(newConfig())
 
 	return cmd
 }
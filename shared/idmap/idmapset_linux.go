@@ -73,10 +73,6 @@ func (e *IdmapEntry) HostidsIntersect(i IdmapEntry) bool {
 			return true
 		case is_between(i.Hostid, e.Hostid, e.Hostid+e.Maprange):
 			return true
-		case is_between(e.Hostid+e.Maprange, i.Hostid, i.Hostid+i.Maprange):
-			return true
-		case is_between(i.Hostid+i.Maprange, e.Hostid, e.Hostid+e.Maprange):
-			return true
 		}
 	}
 
@@ -292,6 +288,33 @@ func (m *IdmapSet) Equals(other *IdmapSet) bool {
 	return reflect.DeepEqual(expandSortIdmap(m), expandSortIdmap(other))
 }
 
+// Contains returns true if the host ids of the entry are fully covered by the set.
+func (m *IdmapSet) Contains(i IdmapEntry) bool {
+	covered := func(isuid bool) bool {
+		for _, e := range m.Idmap {
+			if (isuid && !e.Isuid) || (!isuid && !e.Isgid) {
+				continue
+			}
+
+			if i.Hostid >= e.Hostid && i.Hostid+i.Maprange <= e.Hostid+e.Maprange {
+				return true
+			}
+		}
+
+		return false
+	}
+
+	if i.Isuid && !covered(true) {
+		return false
+	}
+
+	if i.Isgid && !covered(false) {
+		return false
+	}
+
+	return true
+}
+
 func (m IdmapSet) Len() int {
 	return len(m.Idmap)
 }
@@ -627,6 +650,169 @@ func (set *IdmapSet) UnshiftRootfs(p string, skipper func(dir string, absPath st
 	return set.doUidshiftIntoContainer(p, false, "out", skipper)
 }
 
+/*
+ * Remap a rootfs shifted with this set so that it matches newSet instead.
+ * Each file is only chowned once, going from the current host id to the
+ * container id and then to the new host id. If any file fails, the files
+ * which were already remapped get their previous ownership restored by
+ * walking the rootfs again, so that no per-file state needs to be kept.
+ */
+func (set *IdmapSet) RemapRootfs(p string, newSet *IdmapSet, skipper func(dir string, absPath string, fi os.FileInfo) bool, progress func(count int64)) error {
+	// Compute the new host id for a host id in the current map
+	remapIds := func(uid int64, gid int64) (int64, int64) {
+		if set != nil {
+			uid, gid = set.ShiftFromNs(uid, gid)
+		}
+
+		if newSet != nil {
+			uid, gid = newSet.ShiftIntoNs(uid, gid)
+		}
+
+		return uid, gid
+	}
+
+	// And the reverse for rollbacks
+	revertIds := func(uid int64, gid int64) (int64, int64) {
+		if newSet != nil {
+			uid, gid = newSet.ShiftFromNs(uid, gid)
+		}
+
+		if set != nil {
+			uid, gid = set.ShiftIntoNs(uid, gid)
+		}
+
+		return uid, gid
+	}
+
+	// Expand any symlink before the final path component
+	tmp := filepath.Dir(p)
+	tmp, err := filepath.EvalSymlinks(tmp)
+	if err != nil {
+		return errors.Wrap(err, "Expand symlinks")
+	}
+	dir := filepath.Join(tmp, filepath.Base(p))
+	dir = strings.TrimRight(dir, "/")
+
+	if !shared.PathExists(dir) {
+		return fmt.Errorf("No such file or directory: %q", dir)
+	}
+
+	if atomic.LoadInt32(&VFS3Fscaps) == VFS3FscapsUnknown {
+		if SupportsVFS3Fscaps(dir) {
+			atomic.StoreInt32(&VFS3Fscaps, VFS3FscapsSupported)
+		} else {
+			atomic.StoreInt32(&VFS3Fscaps, VFS3FscapsUnsupported)
+		}
+	}
+
+	oldRootUid := int64(0)
+	if set != nil {
+		oldRootUid, _ = set.ShiftIntoNs(0, 0)
+	}
+
+	newRootUid := int64(0)
+	if newSet != nil {
+		newRootUid, _ = newSet.ShiftIntoNs(0, 0)
+	}
+
+	// Walk the rootfs, remapping at most limit files (all if negative).
+	// Hardlinked inodes are only remapped once, only those being tracked.
+	errLimit := fmt.Errorf("Remap limit reached")
+	walk := func(shift func(uid int64, gid int64) (int64, int64), rootUid int64, limit int64, count *int64) error {
+		hardLinks := map[uint64]bool{}
+
+		err := filepath.Walk(dir, func(path string, fi os.FileInfo, err error) error {
+			if err != nil {
+				return err
+			}
+
+			if limit >= 0 && *count >= limit {
+				return errLimit
+			}
+
+			if skipper != nil && skipper(dir, path, fi) {
+				return filepath.SkipDir
+			}
+
+			intUid, intGid, _, _, inode, nlink, err := shared.GetFileStat(path)
+			if err != nil {
+				return err
+			}
+
+			if nlink >= 2 {
+				// File was already remapped through hardlink
+				if hardLinks[inode] {
+					return nil
+				}
+
+				hardLinks[inode] = true
+			}
+
+			symlink := fi.Mode()&os.ModeSymlink != 0
+
+			// Dump capabilities
+			caps := []byte{}
+			if !symlink {
+				caps, err = GetCaps(path)
+				if err != nil {
+					return err
+				}
+			}
+
+			// Shift owner
+			newUid, newGid := shift(int64(intUid), int64(intGid))
+			err = ShiftOwner(dir, path, int(newUid), int(newGid))
+			if err != nil {
+				return err
+			}
+			*count++
+
+			if !symlink {
+				// Shift POSIX ACLs
+				err = ShiftACL(path, shift)
+				if err != nil {
+					return err
+				}
+
+				// Restore the capabilities cleared by the chown, those
+				// of a non-root owner needing VFS v3 capabilities
+				if len(caps) != 0 && (rootUid == 0 || atomic.LoadInt32(&VFS3Fscaps) == VFS3FscapsSupported) {
+					err = SetCaps(path, caps, rootUid)
+					if err != nil {
+						logger.Warnf("Unable to set file capabilities on %s", path)
+					}
+				}
+			}
+
+			if progress != nil && limit < 0 {
+				progress(*count)
+			}
+
+			return nil
+		})
+		if err == errLimit {
+			return nil
+		}
+
+		return err
+	}
+
+	count := int64(0)
+	err = walk(remapIds, newRootUid, -1, &count)
+	if err == nil {
+		return nil
+	}
+
+	// Restore the previous ownership of everything we touched
+	reverted := int64(0)
+	rollbackErr := walk(revertIds, oldRootUid, count, &reverted)
+	if rollbackErr != nil {
+		logger.Errorf("Failed to restore the ownership of %s: %v", dir, rollbackErr)
+	}
+
+	return err
+}
+
 func (set *IdmapSet) ShiftFile(p string) error {
 	return set.ShiftRootfs(p, nil)
 }
@@ -735,6 +921,42 @@ func getFromProc(fname string) ([][]int64, error) {
 	return entries, nil
 }
 
+/*
+ * Get all the subordinate uid and gid ranges delegated to a user, returns nil
+ * if the shadow files aren't present
+ */
+func ShadowIdmapSet(rootfs string, username string) (*IdmapSet, error) {
+	subuidPath := path.Join(rootfs, "/etc/subuid")
+	subgidPath := path.Join(rootfs, "/etc/subgid")
+	if !shared.PathExists(subuidPath) || !shared.PathExists(subgidPath) {
+		return nil, nil
+	}
+
+	idmapset := new(IdmapSet)
+
+	entries, err := getFromShadow(subuidPath, username)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, entry := range entries {
+		e := IdmapEntry{Isuid: true, Nsid: 0, Hostid: entry[0], Maprange: entry[1]}
+		idmapset.Idmap = append(idmapset.Idmap, e)
+	}
+
+	entries, err = getFromShadow(subgidPath, username)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, entry := range entries {
+		e := IdmapEntry{Isgid: true, Nsid: 0, Hostid: entry[0], Maprange: entry[1]}
+		idmapset.Idmap = append(idmapset.Idmap, e)
+	}
+
+	return idmapset, nil
+}
+
 /*
  * Create a new default idmap
  */
@@ -105,3 +105,55 @@ func TestIdmapSetIntersects(t *testing.T) {
 		return
 	}
 }
+
+func TestIdmapEntryHostidsIntersect(t *testing.T) {
+	orig := IdmapEntry{Isuid: true, Isgid: true, Hostid: 1000, Nsid: 1000, Maprange: 1}
+
+	if !orig.HostidsIntersect(IdmapEntry{Isuid: true, Hostid: 1000, Nsid: 0, Maprange: 1}) {
+		t.Error("ranges don't intersect")
+		return
+	}
+
+	if !orig.HostidsIntersect(IdmapEntry{Isgid: true, Hostid: 990, Nsid: 0, Maprange: 20}) {
+		t.Error("ranges don't intersect")
+		return
+	}
+
+	if orig.HostidsIntersect(IdmapEntry{Isuid: true, Isgid: true, Hostid: 1001, Nsid: 0, Maprange: 10}) {
+		t.Error("adjacent ranges intersect")
+		return
+	}
+
+	if orig.HostidsIntersect(IdmapEntry{Isuid: true, Isgid: true, Hostid: 990, Nsid: 0, Maprange: 10}) {
+		t.Error("adjacent ranges intersect")
+		return
+	}
+}
+
+func TestIdmapSetContains(t *testing.T) {
+	orig := IdmapSet{Idmap: []IdmapEntry{
+		{Isuid: true, Hostid: 1000, Nsid: 0, Maprange: 1},
+		{Isuid: true, Hostid: 165536, Nsid: 0, Maprange: 65536},
+		{Isgid: true, Hostid: 165536, Nsid: 0, Maprange: 65536},
+	}}
+
+	if !orig.Contains(IdmapEntry{Isuid: true, Isgid: true, Hostid: 165536, Nsid: 0, Maprange: 65536}) {
+		t.Error("range isn't contained")
+		return
+	}
+
+	if !orig.Contains(IdmapEntry{Isuid: true, Hostid: 1000, Nsid: 1000, Maprange: 1}) {
+		t.Error("range isn't contained")
+		return
+	}
+
+	if orig.Contains(IdmapEntry{Isuid: true, Isgid: true, Hostid: 1000, Nsid: 1000, Maprange: 1}) {
+		t.Error("range is contained")
+		return
+	}
+
+	if orig.Contains(IdmapEntry{Isuid: true, Hostid: 165537, Nsid: 0, Maprange: 65536}) {
+		t.Error("range is contained")
+		return
+	}
+}
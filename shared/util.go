@@ -916,7 +916,18 @@ func (e RunError) Error() string {
 }
 
 func RunCommand(name string, arg ...string) (string, error) {
-	output, err := exec.Command(name, arg...).CombinedOutput()
+	return RunCommandWithEnv(nil, name, arg...)
+}
+
+// RunCommandWithEnv runs a command like RunCommand, adding the given
+// environment variables to the current ones.
+func RunCommandWithEnv(env []string, name string, arg ...string) (string, error) {
+	cmd := exec.Command(name, arg...)
+	if env != nil {
+		cmd.Env = append(os.Environ(), env...)
+	}
+
+	output, err := cmd.CombinedOutput()
 	if err != nil {
 		err := RunError{
 			msg: fmt.Sprintf("Failed to run: %s %s: %s", name, strings.Join(arg, " "), strings.TrimSpace(string(output))),
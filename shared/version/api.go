@@ -147,6 +147,104 @@ var APIExtensions = []string{
 	"id_map_current",
 	"event_location",
 	"storage_api_remote_volume_snapshots",
+	"device_kernel_modules",
+	"container_time_namespace",
+	"container_raw_idmap_validation",
+	"container_idmap_remap",
+	"container_rename_rollback",
+	"container_copy_mode",
+	"container_cloud_init_metadata",
+	"container_console_auth",
+	"gpu_mps",
+	"container_energy",
+	"container_ephemeral_state",
+	"container_host_hooks",
+	"device_plugins",
+	"container_restart_policy",
+	"container_mdns",
+	"container_list_filters",
+	"config_schema",
+	"events_sse",
+	"webhooks",
+	"opa_authorization",
+	"ldap_authentication",
+	"api_yaml",
+	"terraform_state",
+	"container_build_cache",
+	"file_acl",
+	"backup_xattrs",
+	"container_capabilities",
+	"container_exec_limits",
+	"container_cgroup_trace",
+	"container_priority_classes",
+	"key_store",
+	"container_ipc_namespace",
+	"container_memory_balloon",
+	"container_quota_check",
+	"console_mock_mode",
+	"container_capabilities_audit",
+	"container_memory_stats",
+	"proxy_connection_count",
+	"container_tun_device",
+	"infiniband_vf_pool",
+	"container_boot_health_gates",
+	"container_seccomp_log_only",
+	"images_gc",
+	"network_bgp_evpn",
+	"storage_overcommit",
+	"storage_lvm_thinpool_metadata",
+	"storage_zfs_encryption",
+	"network_forwards",
+	"network_load_balancers",
+	"migration_sriov",
+	"container_thp_mode",
+	"api_error_codes",
+	"api_idempotency_keys",
+	"container_rootfs_sync",
+	"container_pid_map",
+	"api_debug_pprof",
+	"container_seccomp_path_rules",
+	"container_file_search",
+	"container_exec_secrets",
+	"container_memory_protection",
+	"container_pci_vfio",
+	"container_ebpf",
+	"container_coredumps",
+	"container_cron",
+	"container_network_routes",
+	"container_network_arp",
+	"container_nic_queues",
+	"container_exec_dry_run",
+	"storage_compression",
+	"images_import_vagrant",
+	"container_iso_boot",
+	"images_replication",
+	"snapshot_retention",
+	"container_oom_score_adj",
+	"storage_volume_live_resize",
+	"container_bandwidth",
+	"console_clipboard_passthrough",
+	"container_exec_stdin",
+	"container_vlan",
+	"container_drift",
+	"console_viewers",
+	"container_numa",
+	"container_cpu_period",
+	"container_proc_filter",
+	"container_hide_firmware",
+	"container_open_files",
+	"container_hooks",
+	"storage_zfs_dedup",
+	"image_pull_through_cache",
+	"container_snapshot_hooks",
+	"storage_max_concurrent_ops",
+	"images_build_dockerfile",
+	"container_ulimits",
+	"images_signature",
+	"storage_lvm_encryption",
+	"container_perf_pagefaults",
+	"container_mounts_audit",
+	"certificates_reload",
 }
 
 // APIExtensionsCount returns the number of available API extensions.
@@ -0,0 +1,102 @@
+package shared
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"strconv"
+
+	"gopkg.in/yaml.v2"
+)
+
+// MarshalYAML returns the YAML encoding of v. The value goes through its JSON
+// encoding first so that the keys and omitted fields match the JSON API.
+func MarshalYAML(v interface{}) ([]byte, error) {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return nil, err
+	}
+
+	// Keep numbers as they are rather than going through float64
+	var generic interface{}
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.UseNumber()
+	err = decoder.Decode(&generic)
+	if err != nil {
+		return nil, err
+	}
+
+	return yaml.Marshal(yamlNumbers(generic))
+}
+
+// yamlNumbers replaces the JSON numbers of a decoded value with integers or
+// floats, which yaml.v2 would otherwise quote as strings.
+func yamlNumbers(v interface{}) interface{} {
+	switch value := v.(type) {
+	case json.Number:
+		i, err := value.Int64()
+		if err == nil {
+			return i
+		}
+
+		u, err := strconv.ParseUint(value.String(), 10, 64)
+		if err == nil {
+			return u
+		}
+
+		f, err := value.Float64()
+		if err == nil {
+			return f
+		}
+
+		return value.String()
+	case map[string]interface{}:
+		for k, item := range value {
+			value[k] = yamlNumbers(item)
+		}
+	case []interface{}:
+		for i, item := range value {
+			value[i] = yamlNumbers(item)
+		}
+	}
+
+	return v
+}
+
+// UnmarshalYAML parses the YAML encoded data and stores the result in v,
+// using the JSON field names of v.
+func UnmarshalYAML(data []byte, v interface{}) error {
+	var generic interface{}
+	err := yaml.Unmarshal(data, &generic)
+	if err != nil {
+		return err
+	}
+
+	content, err := json.Marshal(yamlStringKeys(generic))
+	if err != nil {
+		return err
+	}
+
+	return json.Unmarshal(content, v)
+}
+
+// yamlStringKeys replaces the maps of a decoded value with maps indexed by
+// strings, yaml.v2 decoding mappings as map[interface{}]interface{} which
+// can't be encoded to JSON.
+func yamlStringKeys(v interface{}) interface{} {
+	switch value := v.(type) {
+	case map[interface{}]interface{}:
+		result := map[string]interface{}{}
+		for k, item := range value {
+			result[fmt.Sprintf("%v", k)] = yamlStringKeys(item)
+		}
+
+		return result
+	case []interface{}:
+		for i, item := range value {
+			value[i] = yamlStringKeys(item)
+		}
+	}
+
+	return v
+}
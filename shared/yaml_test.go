@@ -0,0 +1,66 @@
+package shared
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type yamlTestObject struct {
+	Name        string            `json:"name" yaml:"ignored"`
+	Description string            `json:"description,omitempty"`
+	Config      map[string]string `json:"config"`
+	Size        int64             `json:"size"`
+	Devices     []string          `json:"devices"`
+}
+
+func TestMarshalYAML(t *testing.T) {
+	obj := yamlTestObject{
+		Name:   "c1",
+		Config: map[string]string{"limits.cpu": "2", "security.nesting": "true"},
+		Size:   9007199254740993,
+	}
+
+	data, err := MarshalYAML(obj)
+	require.NoError(t, err)
+	assert.Equal(t, `config:
+  limits.cpu: "2"
+  security.nesting: "true"
+devices: null
+name: c1
+size: 9007199254740993
+`, string(data))
+}
+
+func TestUnmarshalYAML_RoundTrip(t *testing.T) {
+	obj := yamlTestObject{
+		Name:        "c1",
+		Description: "yes",
+		Config:      map[string]string{"user.count": "10", "user.empty": ""},
+		Size:        -1,
+		Devices:     []string{"eth0", "root"},
+	}
+
+	data, err := MarshalYAML(obj)
+	require.NoError(t, err)
+
+	result := yamlTestObject{}
+	err = UnmarshalYAML(data, &result)
+	require.NoError(t, err)
+	assert.Equal(t, obj, result)
+}
+
+func TestUnmarshalYAML_RawMessage(t *testing.T) {
+	raw := json.RawMessage{}
+	err := UnmarshalYAML([]byte("name: c1\nconfig:\n  limits.memory: 1GB\n"), &raw)
+	require.NoError(t, err)
+	assert.JSONEq(t, `{"name": "c1", "config": {"limits.memory": "1GB"}}`, string(raw))
+}
+
+func TestUnmarshalYAML_Invalid(t *testing.T) {
+	result := yamlTestObject{}
+	err := UnmarshalYAML([]byte("name: [c1"), &result)
+	assert.Error(t, err)
+}
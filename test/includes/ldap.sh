@@ -0,0 +1,28 @@
+# Test helper for LDAP authentication
+
+start_ldap_server() {
+
+    (
+        cd ldap-server || return
+        go get -d ./...
+        go build ./...
+    )
+    # shellcheck disable=SC2039
+    local users_file tcp_port
+    users_file="$1/ldap-server-users.csv"
+    tcp_port="$(local_tcp_port)"
+    touch "$users_file"
+
+    ldap-server/ldap-server -endpoint "localhost:$tcp_port" -users "$users_file" &
+    set +x
+    echo $! > "${TEST_DIR}/ldap-server.pid"
+    echo "ldap://localhost:$tcp_port" > "${TEST_DIR}/ldap-server.endpoint"
+    echo "$users_file" > "${TEST_DIR}/ldap-server.users"
+}
+
+kill_ldap_server() {
+    # shellcheck disable=SC2039
+    local pidfile="$1/ldap-server.pid"
+    kill "$(cat "$pidfile")" || true
+    rm -f ldap-server/ldap-server
+}
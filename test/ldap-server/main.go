@@ -0,0 +1,143 @@
+package main
+
+import (
+	"encoding/csv"
+	"encoding/pem"
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"net"
+	"os"
+	"strings"
+
+	"github.com/nmcclain/ldap"
+)
+
+const baseDN = "dc=lxd,dc=test"
+
+type flags struct {
+	Endpoint string
+	BindDN   string
+	BindPass string
+	Users    string
+}
+
+// directory serves the users listed in a CSV file (uid, certificate path and
+// semicolon separated groups). The file is read again on each query so that
+// tests can change it while the server runs.
+type directory struct {
+	flags  *flags
+	logger *log.Logger
+}
+
+func (d *directory) Bind(bindDN, bindSimplePw string, conn net.Conn) (ldap.LDAPResultCode, error) {
+	if bindDN == d.flags.BindDN && bindSimplePw == d.flags.BindPass {
+		return ldap.LDAPResultSuccess, nil
+	}
+
+	d.logger.Printf("rejected bind as %q", bindDN)
+	return ldap.LDAPResultInvalidCredentials, nil
+}
+
+func (d *directory) Search(boundDN string, req ldap.SearchRequest, conn net.Conn) (ldap.ServerSearchResult, error) {
+	entries, err := d.load()
+	if err != nil {
+		d.logger.Printf("failed to load users: %v", err)
+		return ldap.ServerSearchResult{ResultCode: ldap.LDAPResultOperationsError}, err
+	}
+
+	d.logger.Printf("search %q under %q", req.Filter, req.BaseDN)
+	return ldap.ServerSearchResult{Entries: entries, ResultCode: ldap.LDAPResultSuccess}, nil
+}
+
+func (d *directory) load() ([]*ldap.Entry, error) {
+	f, err := os.Open(d.flags.Users)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	rows, err := csv.NewReader(f).ReadAll()
+	if err != nil {
+		return nil, err
+	}
+
+	entries := []*ldap.Entry{}
+	members := map[string][]string{}
+	for i, row := range rows {
+		if len(row) != 3 {
+			return nil, fmt.Errorf("invalid length on row %d", i+1)
+		}
+
+		content, err := ioutil.ReadFile(row[1])
+		if err != nil {
+			return nil, err
+		}
+
+		block, _ := pem.Decode(content)
+		if block == nil {
+			return nil, fmt.Errorf("invalid certificate on row %d", i+1)
+		}
+
+		dn := fmt.Sprintf("uid=%s,ou=users,%s", row[0], baseDN)
+		entries = append(entries, &ldap.Entry{
+			DN: dn,
+			Attributes: []*ldap.EntryAttribute{
+				{Name: "objectClass", Values: []string{"inetOrgPerson"}},
+				{Name: "uid", Values: []string{row[0]}},
+				{Name: "userCertificate;binary", Values: []string{string(block.Bytes)}},
+			},
+		})
+
+		for _, group := range strings.Split(row[2], ";") {
+			if group != "" {
+				members[group] = append(members[group], dn)
+			}
+		}
+	}
+
+	for group, dns := range members {
+		entries = append(entries, &ldap.Entry{
+			DN: fmt.Sprintf("cn=%s,ou=groups,%s", group, baseDN),
+			Attributes: []*ldap.EntryAttribute{
+				{Name: "objectClass", Values: []string{"groupOfNames"}},
+				{Name: "cn", Values: []string{group}},
+				{Name: "member", Values: dns},
+			},
+		})
+	}
+
+	return entries, nil
+}
+
+func main() {
+	flags := parseFlags()
+	logger := log.New(os.Stdout, "", log.Ldate|log.Ltime)
+
+	d := &directory{flags: flags, logger: logger}
+	s := ldap.NewServer()
+	s.BindFunc("", d)
+	s.SearchFunc("", d)
+
+	logger.Printf("serving %s on %s", baseDN, flags.Endpoint)
+	if err := s.ListenAndServe(flags.Endpoint); err != nil {
+		panic(err)
+	}
+}
+
+func parseFlags() *flags {
+	endpoint := flag.String("endpoint", "localhost:3389", "service endpoint")
+	bindDN := flag.String("bind-dn", "cn=admin,"+baseDN, "DN allowed to bind")
+	bindPass := flag.String("bind-password", "secret", "password of the bind DN")
+	users := flag.String("users", "users.csv", "CSV file with users (uid, certificate path and groups)")
+	flag.Parse()
+	f := &flags{
+		Endpoint: *endpoint,
+		BindDN:   *bindDN,
+		BindPass: *bindPass,
+		Users:    *users,
+	}
+
+	return f
+}
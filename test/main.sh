@@ -46,7 +46,7 @@ import_subdir_files() {
 import_subdir_files includes
 
 echo "==> Checking for dependencies"
-check_dependencies lxd lxc curl dnsmasq jq git xgettext sqlite3 msgmerge msgfmt shuf setfacl uuidgen socat
+check_dependencies lxd lxc curl dnsmasq jq git xgettext sqlite3 msgmerge msgfmt shuf setfacl uuidgen socat openssl
 
 if [ "${USER:-'root'}" != "root" ]; then
   echo "The testsuite must be run as root." >&2
@@ -94,6 +94,7 @@ cleanup() {
   echo "==> Cleaning up"
 
   kill_external_auth_daemon "$TEST_DIR"
+  kill_ldap_server "$TEST_DIR"
   cleanup_lxds "$TEST_DIR"
 
   echo ""
@@ -133,6 +134,7 @@ LXD_ADDR=$(cat "${LXD_DIR}/lxd.addr")
 export LXD_ADDR
 
 start_external_auth_daemon "${LXD_DIR}"
+start_ldap_server "${LXD_DIR}"
 
 run_test() {
   TEST_CURRENT=${1}
@@ -213,8 +215,10 @@ run_test test_storage_driver_ceph "ceph storage driver"
 run_test test_resources "resources"
 run_test test_kernel_limits "kernel limits"
 run_test test_macaroon_auth "macaroon authentication"
+run_test test_ldap_auth "LDAP authentication"
 run_test test_console "console"
 run_test test_query "query"
+run_test test_api_yaml "YAML API requests and responses"
 run_test test_proxy_device "proxy device"
 run_test test_storage_local_volume_handling "storage local volume handling"
 run_test test_backup_import "backup import"
@@ -0,0 +1,49 @@
+test_api_yaml() {
+  ensure_import_testimage
+
+  # shellcheck disable=SC2039
+  local pool
+  pool=$(lxc profile device get default root pool)
+
+  lxc init testimage c-yaml -c user.foo=bar
+  lxc profile create p-yaml
+  lxc profile set p-yaml limits.cpu 1
+  lxc network create lxdt$$ ipv4.address=none ipv6.address=none
+  lxc storage volume create "${pool}" v-yaml size=10MB
+
+  yaml_curl() {
+    curl -s --unix-socket "${LXD_DIR}/unix.socket" "$@"
+  }
+
+  # Round-trip each object through YAML and make sure nothing changed
+  for path in containers/c-yaml profiles/p-yaml networks/lxdt$$ storage-pools/${pool}/volumes/custom/v-yaml; do
+    before=$(yaml_curl "lxd/1.0/${path}" | jq -S .metadata)
+
+    yaml_curl -H "Accept: application/yaml" "lxd/1.0/${path}" > "${TEST_DIR}/get.yaml"
+    grep -q "^type: sync" "${TEST_DIR}/get.yaml"
+    sed -n '/^metadata:$/,/^[^ ]/{/^ /p}' "${TEST_DIR}/get.yaml" | sed 's/^    //' > "${TEST_DIR}/put.yaml"
+
+    yaml_curl -X PUT -H "Content-Type: application/yaml" --data-binary "@${TEST_DIR}/put.yaml" "lxd/1.0/${path}" | jq -r .status_code | grep -q 200
+
+    after=$(yaml_curl "lxd/1.0/${path}" | jq -S .metadata)
+    [ "${before}" = "${after}" ]
+  done
+
+  # YAML changes are applied
+  printf 'config:\n  user.yaml: "true"\n' | yaml_curl -X PATCH -H "Content-Type: application/yaml" --data-binary @- "lxd/1.0/profiles/p-yaml"
+  [ "$(lxc profile get p-yaml user.yaml)" = "true" ]
+  [ "$(lxc profile get p-yaml limits.cpu)" = "1" ]
+
+  # Errors are rendered as YAML and invalid YAML is rejected
+  yaml_curl -H "Accept: application/yaml" "lxd/1.0/containers/missing" | grep -q "^error_code: 404"
+  printf 'config: [' | yaml_curl -X PATCH -H "Content-Type: application/yaml" --data-binary @- "lxd/1.0/profiles/p-yaml" | jq -r .error_code | grep -q 400
+
+  # JSON is still the default
+  yaml_curl "lxd/1.0/profiles/p-yaml" | jq -r .metadata.name | grep -q p-yaml
+
+  rm -f "${TEST_DIR}/get.yaml" "${TEST_DIR}/put.yaml"
+  lxc delete c-yaml
+  lxc profile delete p-yaml
+  lxc network delete lxdt$$
+  lxc storage volume delete "${pool}" v-yaml
+}
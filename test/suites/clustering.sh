@@ -1159,6 +1159,9 @@ test_clustering_image_replication() {
   [ "$images_minimal_replica1" = "" ] || false
   [ "$images_minimal_replica2" = "" ] || false
 
+  # Wait for the replicas before completing the image creation
+  LXD_DIR="${LXD_ONE_DIR}" lxc config set images.replication_factor 3
+
   # Import the test image on node1
   LXD_DIR="${LXD_ONE_DIR}" ensure_import_testimage
 
@@ -1214,6 +1217,9 @@ test_clustering_image_replication() {
   [ -f "${LXD_TWO_DIR}/images/${fingerprint}" ] || false
   [ -f "${LXD_THREE_DIR}/images/${fingerprint}" ] || false
 
+  # The replication state is reported for all three nodes
+  [ "$(LXD_DIR="${LXD_ONE_DIR}" lxc query "/1.0/images/${fingerprint}/replicas" | jq -r '.[].status' | grep -c complete)" = "3" ] || false
+
   # Delete the imported image
   LXD_DIR="${LXD_ONE_DIR}" lxc image delete testimage
   [ ! -f "${LXD_ONE_DIR}/images/${fingerprint}" ] || false
@@ -1251,6 +1257,7 @@ test_clustering_image_replication() {
 
   # Disable the image replication
   LXD_DIR="${LXD_TWO_DIR}" lxc config set cluster.images_minimal_replica 1
+  LXD_DIR="${LXD_TWO_DIR}" lxc config set images.replication_factor 1
   LXD_DIR="${LXD_ONE_DIR}" lxc info | grep -q 'cluster.images_minimal_replica: "1"'
   LXD_DIR="${LXD_TWO_DIR}" lxc info | grep -q 'cluster.images_minimal_replica: "1"'
   LXD_DIR="${LXD_THREE_DIR}" lxc info | grep -q 'cluster.images_minimal_replica: "1"'
@@ -17,6 +17,12 @@ test_filemanip() {
   err=$(my_curl -o /dev/null -w "%{http_code}" -X GET "https://${LXD_ADDR}/1.0/containers/filemanip/files?path=/tmp/foo")
   [ "${err}" -eq "404" ]
 
+  # POSIX ACLs are applied on push and returned on pull, if supported
+  if my_curl -X POST -H "X-LXD-ACL: user::rw-,user:1000:r--,group::r--,other::---" --data-binary "acl" "https://${LXD_ADDR}/1.0/containers/filemanip/files?path=/root/acl" | grep -q '"status_code":200'; then
+    my_curl -D - -o /dev/null "https://${LXD_ADDR}/1.0/containers/filemanip/files?path=/root/acl" | grep -qi "^X-LXD-ACL: user::rw-,user:1000:r--,group::r--,mask::r--,other::---"
+    lxc exec filemanip -- rm /root/acl
+  fi
+
   # lxc {push|pull} -r
   mkdir "${TEST_DIR}"/source
   mkdir "${TEST_DIR}"/source/another_level
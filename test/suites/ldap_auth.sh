@@ -0,0 +1,59 @@
+test_ldap_auth() {
+    # shellcheck disable=SC2039
+    local ldap_endpoint users_file conf
+    # shellcheck disable=SC2086
+    ldap_endpoint="$(cat ${TEST_DIR}/ldap-server.endpoint)"
+    # shellcheck disable=SC2086
+    users_file="$(cat ${TEST_DIR}/ldap-server.users)"
+
+    ensure_has_localhost_remote "$LXD_ADDR"
+
+    # client certificates only known to the LDAP server
+    conf=$(mktemp -d -p "${TEST_DIR}" XXX)
+    openssl req -x509 -newkey rsa:2048 -nodes -days 1 -subj "/CN=user1" \
+        -keyout "${conf}/user1.key" -out "${conf}/user1.crt" 2>/dev/null
+    openssl req -x509 -newkey rsa:2048 -nodes -days 1 -subj "/CN=user1" \
+        -keyout "${conf}/fake.key" -out "${conf}/fake.crt" 2>/dev/null
+    echo "user1,${conf}/user1.crt,lxd" > "${users_file}"
+
+    auth_state() {
+        curl -s -k --cert "${conf}/${1}.crt" --key "${conf}/${1}.key" "https://${LXD_ADDR}/1.0" | jq -r .metadata.auth
+    }
+
+    [ "$(auth_state user1)" = "untrusted" ]
+
+    lxc config set auth.ldap.uri "${ldap_endpoint}"
+    lxc config set auth.ldap.base_dn "dc=lxd,dc=test"
+    lxc config set auth.ldap.bind_dn "cn=admin,dc=lxd,dc=test"
+    lxc config set auth.ldap.bind_password secret
+
+    # the LDAP user with the matching certificate is trusted
+    [ "$(auth_state user1)" = "trusted" ]
+
+    # a certificate with the same common name isn't
+    [ "$(auth_state fake)" = "untrusted" ]
+
+    # invalid filters are rejected
+    ! lxc config set auth.ldap.user_filter "(uid=*)" || false
+
+    # group membership is required when a group filter is set
+    lxc config set auth.ldap.group_filter "(&(cn=admins)(member=%s))"
+    [ "$(auth_state user1)" = "untrusted" ]
+    lxc config set auth.ldap.group_filter "(&(cn=lxd)(member=%s))"
+    [ "$(auth_state user1)" = "trusted" ]
+
+    # the trust store is used if the LDAP server can't be reached
+    lxc config set auth.ldap.uri "ldap://localhost:$(local_tcp_port)"
+    [ "$(auth_state user1)" = "untrusted" ]
+    lxc info localhost: | grep -q "auth: trusted"
+
+    # cleanup
+    lxc config unset auth.ldap.uri
+    lxc config unset auth.ldap.base_dn
+    lxc config unset auth.ldap.bind_dn
+    lxc config unset auth.ldap.bind_password
+    lxc config unset auth.ldap.group_filter
+    lxc config unset core.https_address
+    rm -rf "${conf}"
+    : > "${users_file}"
+}